@@ -0,0 +1,93 @@
+// Copyright 2022 The go-confero Authors
+// This file is part of go-confero.
+//
+// go-confero is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-confero is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-confero. If not, see <http://www.gnu.org/licenses/>.
+
+// ancientstore serves a chain freezer to other processes over RPC, allowing
+// multiple nodes on the same host to share a single copy of the ancient chain
+// history.
+package main
+
+import (
+	"flag"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/confero-network/go-confero/cmd/utils"
+	"github.com/confero-network/go-confero/core/rawdb"
+	"github.com/confero-network/go-confero/ethdb/remoteancient"
+	"github.com/confero-network/go-confero/log"
+	"github.com/confero-network/go-confero/node"
+	"github.com/confero-network/go-confero/rpc"
+)
+
+func main() {
+	var (
+		datadir   = flag.String("datadir", "", "root directory of the ancient data")
+		ipcPath   = flag.String("ipcpath", "", "filename for the IPC socket/pipe (empty = IPC disabled)")
+		httpAddr  = flag.String("http", "", "HTTP listen address, e.g. 127.0.0.1:8560 (empty = HTTP disabled)")
+		readonly  = flag.Bool("readonly", false, "open the freezer in read only mode, rejecting all writes")
+		verbosity = flag.Int("verbosity", int(log.LvlInfo), "log verbosity (0-5)")
+		vmodule   = flag.String("vmodule", "", "log verbosity pattern")
+	)
+	flag.Parse()
+
+	glogger := log.NewGlogHandler(log.StreamHandler(os.Stderr, log.TerminalFormat(false)))
+	glogger.Verbosity(log.Lvl(*verbosity))
+	glogger.Vmodule(*vmodule)
+	log.Root().SetHandler(glogger)
+
+	if *datadir == "" {
+		utils.Fatalf("Use -datadir to specify the ancient directory")
+	}
+	if *ipcPath == "" && *httpAddr == "" {
+		utils.Fatalf("Use -ipcpath and/or -http to specify at least one endpoint")
+	}
+	freezer, err := rawdb.NewChainFreezer(*datadir, "", *readonly)
+	if err != nil {
+		utils.Fatalf("Failed to open ancient store: %v", err)
+	}
+	defer freezer.Close()
+
+	if *ipcPath != "" {
+		listener, _, err := rpc.StartIPCEndpoint(*ipcPath, []rpc.API{{
+			Namespace: remoteancient.Namespace,
+			Service:   remoteancient.NewAPI(freezer),
+		}})
+		if err != nil {
+			utils.Fatalf("Failed to start IPC endpoint: %v", err)
+		}
+		defer listener.Close()
+		log.Info("IPC endpoint opened", "url", *ipcPath)
+	}
+	if *httpAddr != "" {
+		server, err := remoteancient.NewServer(freezer)
+		if err != nil {
+			utils.Fatalf("Failed to create server: %v", err)
+		}
+		defer server.Stop()
+
+		httpSrv, addr, err := node.StartHTTPEndpoint(*httpAddr, rpc.DefaultHTTPTimeouts, server)
+		if err != nil {
+			utils.Fatalf("Failed to start HTTP endpoint: %v", err)
+		}
+		defer httpSrv.Close()
+		log.Info("HTTP endpoint opened", "url", "http://"+addr.String())
+	}
+	sigc := make(chan os.Signal, 1)
+	signal.Notify(sigc, syscall.SIGINT, syscall.SIGTERM)
+	<-sigc
+	log.Info("Shutting down ancient store")
+}
@@ -30,6 +30,7 @@ import (
 	"github.com/confero-network/go-confero/eth/protocols/eth"
 	"github.com/confero-network/go-confero/internal/utesting"
 	"github.com/confero-network/go-confero/p2p"
+	"github.com/confero-network/go-confero/p2p/enode"
 	"github.com/confero-network/go-confero/p2p/rlpx"
 )
 
@@ -46,25 +47,31 @@ var (
 // dial attempts to dial the given node and perform a handshake,
 // returning the created Conn if successful.
 func (s *Suite) dial() (*Conn, error) {
-	// dial
-	fd, err := net.Dial("tcp", fmt.Sprintf("%v:%d", s.Dest.IP(), s.Dest.TCP()))
+	conn, err := dialNode(s.Dest)
 	if err != nil {
 		return nil, err
 	}
-	conn := Conn{Conn: rlpx.NewConn(fd, s.Dest.Pubkey())}
-	// do encHandshake
-	conn.ourKey, _ = crypto.GenerateKey()
-	_, err = conn.Handshake(conn.ourKey)
-	if err != nil {
-		conn.Close()
-		return nil, err
-	}
 	// set default p2p capabilities
 	conn.caps = []p2p.Cap{
 		{Name: "eth", Version: 66},
 		{Name: "eth", Version: 67},
 	}
 	conn.ourHighestProtoVersion = 67
+	return conn, nil
+}
+
+// dialNode dials the given node and performs the encryption handshake.
+func dialNode(dest *enode.Node) (*Conn, error) {
+	fd, err := net.Dial("tcp", fmt.Sprintf("%v:%d", dest.IP(), dest.TCP()))
+	if err != nil {
+		return nil, err
+	}
+	conn := Conn{Conn: rlpx.NewConn(fd, dest.Pubkey())}
+	conn.ourKey, _ = crypto.GenerateKey()
+	if _, err = conn.Handshake(conn.ourKey); err != nil {
+		conn.Close()
+		return nil, err
+	}
 	return &conn, nil
 }
 
@@ -0,0 +1,194 @@
+// Copyright 2022 The go-confero Authors
+// This file is part of go-confero.
+//
+// go-confero is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-confero is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-confero. If not, see <http://www.gnu.org/licenses/>.
+
+package ethtest
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/confero-network/go-confero/p2p"
+	"github.com/confero-network/go-confero/p2p/enode"
+	"github.com/confero-network/go-confero/rlp"
+)
+
+// replayProtocolLengths are the message counts of the protocols which can be
+// replayed, used to compute the message code offsets.
+var replayProtocolLengths = map[string]uint64{"eth": 17, "snap": 8}
+
+// ReplayConfig configures a replay.
+type ReplayConfig struct {
+	Dest    *enode.Node          // node to replay the messages to
+	Records []*p2p.CaptureRecord // captured messages of a single peer
+	Timing  bool                 // keep the recorded time between messages
+	Linger  time.Duration        // time to wait for responses after the last message
+	Output  io.Writer            // receives the replay log
+}
+
+// Replay connects to a node as a simulated peer and sends it the messages
+// which the captured peer sent to the recording node, in the recorded order.
+// Messages sent by the node are written to the output and pings are answered.
+func Replay(cfg ReplayConfig) error {
+	caps, err := replayCaps(cfg.Records)
+	if err != nil {
+		return err
+	}
+	conn, err := dialNode(cfg.Dest)
+	if err != nil {
+		return fmt.Errorf("dial failed: %v", err)
+	}
+	defer conn.Close()
+	for _, c := range caps {
+		conn.caps = append(conn.caps, c)
+		switch c.Name {
+		case "eth":
+			conn.ourHighestProtoVersion = c.Version
+		case "snap":
+			conn.ourHighestSnapProtoVersion = c.Version
+		}
+	}
+	if err := conn.handshake(); err != nil {
+		return err
+	}
+	if conn.negotiatedProtoVersion != conn.ourHighestProtoVersion {
+		return fmt.Errorf("node doesn't support eth/%d", conn.ourHighestProtoVersion)
+	}
+	offsets := replayOffsets(caps)
+
+	var (
+		start = time.Now()
+		wmu   sync.Mutex
+		errc  = make(chan error, 1)
+	)
+	logf := func(format string, args ...interface{}) {
+		fmt.Fprintf(cfg.Output, "%10v ", time.Since(start).Round(time.Millisecond))
+		fmt.Fprintf(cfg.Output, format+"\n", args...)
+	}
+	write := func(code uint64, payload []byte) error {
+		wmu.Lock()
+		defer wmu.Unlock()
+		_, err := conn.Conn.Write(code, payload)
+		return err
+	}
+
+	// Read the messages of the node until the connection is closed.
+	go func() {
+		for {
+			code, data, _, err := conn.Conn.Read()
+			if err != nil {
+				errc <- err
+				return
+			}
+			switch code {
+			case uint64((Ping{}).Code()):
+				pong, _ := rlp.EncodeToBytes(Pong{})
+				write(uint64((Pong{}).Code()), pong)
+			case uint64((Disconnect{}).Code()):
+				var msg Disconnect
+				rlp.DecodeBytes(data, &msg)
+				errc <- fmt.Errorf("disconnected by node: %v", msg.Reason)
+				return
+			default:
+				name, version, rel := replayProtocol(caps, offsets, code)
+				logf("< %s/%d %#02x %d bytes", name, version, rel, len(data))
+			}
+		}
+	}()
+
+	// Send the captured messages.
+	var last uint64
+	for _, rec := range cfg.Records {
+		if !rec.Ingress {
+			continue
+		}
+		if cfg.Timing && last != 0 && rec.Time > last {
+			select {
+			case <-time.After(time.Duration(rec.Time - last)):
+			case err := <-errc:
+				return err
+			}
+		}
+		last = rec.Time
+		offset, ok := offsets[rec.Protocol]
+		if !ok {
+			continue
+		}
+		if err := write(offset+rec.Code, rec.Payload); err != nil {
+			return fmt.Errorf("write failed: %v", err)
+		}
+		logf("> %s/%d %#02x %d bytes", rec.Protocol, rec.Version, rec.Code, len(rec.Payload))
+	}
+
+	// Wait for the responses to the last messages.
+	select {
+	case <-time.After(cfg.Linger):
+	case err := <-errc:
+		return err
+	}
+	msg, _ := rlp.EncodeToBytes(Disconnect{Reason: p2p.DiscQuitting})
+	write(uint64((Disconnect{}).Code()), msg)
+	return nil
+}
+
+// replayCaps returns the capabilities used by the captured peer. Only
+// protocols with known message counts are included.
+func replayCaps(records []*p2p.CaptureRecord) ([]p2p.Cap, error) {
+	versions := make(map[string]uint)
+	for _, rec := range records {
+		if _, ok := replayProtocolLengths[rec.Protocol]; !ok {
+			continue
+		}
+		if v, ok := versions[rec.Protocol]; ok && v != rec.Version {
+			return nil, fmt.Errorf("capture contains %s/%d and %s/%d", rec.Protocol, v, rec.Protocol, rec.Version)
+		}
+		versions[rec.Protocol] = rec.Version
+	}
+	if _, ok := versions["eth"]; !ok {
+		return nil, fmt.Errorf("capture contains no eth messages")
+	}
+	caps := make([]p2p.Cap, 0, len(versions))
+	for name, version := range versions {
+		caps = append(caps, p2p.Cap{Name: name, Version: version})
+	}
+	sort.Slice(caps, func(i, j int) bool { return caps[i].Name < caps[j].Name })
+	return caps, nil
+}
+
+// replayOffsets computes the message code offsets of the negotiated protocols.
+// Capabilities must be sorted by name.
+func replayOffsets(caps []p2p.Cap) map[string]uint64 {
+	offsets := make(map[string]uint64)
+	offset := uint64(16) // base protocol length
+	for _, c := range caps {
+		offsets[c.Name] = offset
+		offset += replayProtocolLengths[c.Name]
+	}
+	return offsets
+}
+
+// replayProtocol maps a message code to the protocol it belongs to.
+func replayProtocol(caps []p2p.Cap, offsets map[string]uint64, code uint64) (string, uint, uint64) {
+	for _, c := range caps {
+		offset := offsets[c.Name]
+		if code >= offset && code < offset+replayProtocolLengths[c.Name] {
+			return c.Name, c.Version, code - offset
+		}
+	}
+	return "p2p", 5, code
+}
@@ -17,15 +17,20 @@
 package ethtest
 
 import (
+	"bytes"
 	"os"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/confero-network/go-confero/eth"
 	"github.com/confero-network/go-confero/eth/ethconfig"
+	ethproto "github.com/confero-network/go-confero/eth/protocols/eth"
 	"github.com/confero-network/go-confero/internal/utesting"
 	"github.com/confero-network/go-confero/node"
 	"github.com/confero-network/go-confero/p2p"
+	"github.com/confero-network/go-confero/p2p/enode"
+	"github.com/confero-network/go-confero/rlp"
 )
 
 var (
@@ -76,6 +81,49 @@ func TestSnapSuite(t *testing.T) {
 	}
 }
 
+func TestReplay(t *testing.T) {
+	gcofe, err := runGcofe()
+	if err != nil {
+		t.Fatalf("could not run gcofe: %v", err)
+	}
+	defer gcofe.Close()
+
+	chain, err := loadChain(halfchainFile, genesisFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	status, _ := rlp.EncodeToBytes(&Status{
+		ProtocolVersion: 67,
+		NetworkID:       chain.chainConfig.ChainID.Uint64(),
+		TD:              chain.TD(),
+		Head:            chain.blocks[chain.Len()-1].Hash(),
+		Genesis:         chain.blocks[0].Hash(),
+		ForkID:          chain.ForkID(),
+	})
+	request, _ := rlp.EncodeToBytes(&ethproto.GetBlockHeadersPacket66{
+		RequestId:             1,
+		GetBlockHeadersPacket: &ethproto.GetBlockHeadersPacket{Origin: ethproto.HashOrNumber{Number: 1}, Amount: 2},
+	})
+	peer := enode.ID{1}
+	records := []*p2p.CaptureRecord{
+		{Time: 1, Peer: peer, Protocol: "eth", Version: 67, Ingress: true, Code: ethproto.StatusMsg, Payload: status},
+		{Time: 2, Peer: peer, Protocol: "eth", Version: 67, Ingress: true, Code: ethproto.GetBlockHeadersMsg, Payload: request},
+	}
+	var out bytes.Buffer
+	err = Replay(ReplayConfig{
+		Dest:    gcofe.Server().Self(),
+		Records: records,
+		Linger:  time.Second,
+		Output:  &out,
+	})
+	if err != nil {
+		t.Fatalf("replay failed: %v\n%s", err, out.String())
+	}
+	if !strings.Contains(out.String(), "< eth/67 0x04") {
+		t.Fatalf("no block headers received:\n%s", out.String())
+	}
+}
+
 // runGcofe creates and starts a gcofe node
 func runGcofe() (*node.Node, error) {
 	stack, err := node.New(&node.Config{
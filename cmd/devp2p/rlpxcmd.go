@@ -18,11 +18,15 @@ package main
 
 import (
 	"fmt"
+	"io"
 	"net"
+	"os"
+	"time"
 
 	"github.com/confero-network/go-confero/cmd/devp2p/internal/ethtest"
 	"github.com/confero-network/go-confero/crypto"
 	"github.com/confero-network/go-confero/p2p"
+	"github.com/confero-network/go-confero/p2p/enode"
 	"github.com/confero-network/go-confero/p2p/rlpx"
 	"github.com/confero-network/go-confero/rlp"
 	"github.com/urfave/cli/v2"
@@ -36,6 +40,7 @@ var (
 			rlpxPingCommand,
 			rlpxEthTestCommand,
 			rlpxSnapTestCommand,
+			rlpxReplayCommand,
 		},
 	}
 	rlpxPingCommand = &cli.Command{
@@ -63,6 +68,33 @@ var (
 			testTAPFlag,
 		},
 	}
+	rlpxReplayCommand = &cli.Command{
+		Name:      "replay",
+		Usage:     "Replays a traffic capture against a node",
+		ArgsUsage: "<node> <capture file>",
+		Action:    rlpxReplay,
+		Flags: []cli.Flag{
+			replayPeerFlag,
+			replayTimingFlag,
+			replayLingerFlag,
+		},
+	}
+)
+
+var (
+	replayPeerFlag = &cli.StringFlag{
+		Name:  "peer",
+		Usage: "ID of the captured peer to replay (defaults to the first peer in the capture)",
+	}
+	replayTimingFlag = &cli.BoolFlag{
+		Name:  "timing",
+		Usage: "Keep the recorded time between messages",
+	}
+	replayLingerFlag = &cli.DurationFlag{
+		Name:  "linger",
+		Usage: "Time to wait for responses after the last message",
+		Value: 5 * time.Second,
+	}
 )
 
 func rlpxPing(ctx *cli.Context) error {
@@ -123,3 +155,62 @@ func rlpxSnapTest(ctx *cli.Context) error {
 	}
 	return runTests(ctx, suite.SnapTests())
 }
+
+// rlpxReplay replays the messages of a captured peer against a node.
+func rlpxReplay(ctx *cli.Context) error {
+	if ctx.NArg() < 2 {
+		exit("missing path to capture file as command-line argument")
+	}
+	dest := getNodeArg(ctx)
+	records, err := loadCapture(ctx.Args().Get(1), ctx.String(replayPeerFlag.Name))
+	if err != nil {
+		exit(err)
+	}
+	return ethtest.Replay(ethtest.ReplayConfig{
+		Dest:    dest,
+		Records: records,
+		Timing:  ctx.Bool(replayTimingFlag.Name),
+		Linger:  ctx.Duration(replayLingerFlag.Name),
+		Output:  os.Stdout,
+	})
+}
+
+// loadCapture reads the records of a single peer from a capture file. If peer
+// is empty, the first peer in the capture is selected.
+func loadCapture(file string, peer string) ([]*p2p.CaptureRecord, error) {
+	var id enode.ID
+	if peer != "" {
+		var err error
+		if id, err = enode.ParseID(peer); err != nil {
+			return nil, fmt.Errorf("invalid peer ID: %v", err)
+		}
+	}
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	cr, err := p2p.NewCaptureReader(f)
+	if err != nil {
+		return nil, err
+	}
+	var records []*p2p.CaptureRecord
+	for {
+		rec, err := cr.Read()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+		if id == (enode.ID{}) {
+			id = rec.Peer
+		}
+		if rec.Peer == id {
+			records = append(records, rec)
+		}
+	}
+	if len(records) == 0 {
+		return nil, fmt.Errorf("no records of peer %v in capture", id)
+	}
+	return records, nil
+}
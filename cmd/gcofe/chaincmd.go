@@ -35,6 +35,7 @@ import (
 	"github.com/confero-network/go-confero/core/types"
 	"github.com/confero-network/go-confero/crypto"
 	"github.com/confero-network/go-confero/ethdb"
+	"github.com/confero-network/go-confero/internal/era"
 	"github.com/confero-network/go-confero/internal/flags"
 	"github.com/confero-network/go-confero/log"
 	"github.com/confero-network/go-confero/metrics"
@@ -115,6 +116,47 @@ Optional second and third arguments control the first and
 last block to write. In this mode, the file will be appended
 if already existing. If the file ends with .gz, the output will
 be gzipped.`,
+	}
+	importHistoryCommand = &cli.Command{
+		Action:    importHistory,
+		Name:      "import-history",
+		Usage:     "Import an era1 block history archive",
+		ArgsUsage: "<dir>",
+		Flags: flags.Merge([]cli.Flag{
+			utils.TxLookupLimitFlag,
+		}, utils.DatabasePathFlags, utils.NetworkFlags),
+		Description: `
+The import-history command imports blocks, receipts and total difficulties from
+the era1 files in a directory. The data is written directly into the ancient store
+without executing the blocks, so the database must not contain any blocks beyond
+the genesis. The files are verified against the checksum file of the directory
+and their accumulator roots before importing.`,
+	}
+	exportHistoryCommand = &cli.Command{
+		Action:    exportHistory,
+		Name:      "export-history",
+		Usage:     "Export blockchain history to era1 files",
+		ArgsUsage: "<dir> <first> <last>",
+		Flags: flags.Merge([]cli.Flag{
+			utils.CacheFlag,
+			utils.SyncModeFlag,
+		}, utils.DatabasePathFlags, utils.NetworkFlags),
+		Description: `
+The export-history command writes the blocks between the first and last block,
+together with their receipts and total difficulties, into era1 files of 8192
+blocks each. The first block must be at an epoch boundary. A checksum file
+listing the sha256 checksums of the era1 files is written next to them.`,
+	}
+	verifyHistoryCommand = &cli.Command{
+		Action:    verifyHistory,
+		Name:      "verify-history",
+		Usage:     "Verify an era1 block history archive",
+		ArgsUsage: "<dir>",
+		Flags:     utils.NetworkFlags,
+		Description: `
+The verify-history command checks the era1 files in a directory against their
+checksums, verifies that all blocks are linked and consistent with their receipts
+and total difficulties, and recomputes the accumulator root of every file.`,
 	}
 	importPreimagesCommand = &cli.Command{
 		Action:    importPreimages,
@@ -331,6 +373,70 @@ func exportChain(ctx *cli.Context) error {
 	return nil
 }
 
+func importHistory(ctx *cli.Context) error {
+	if ctx.Args().Len() != 1 {
+		utils.Fatalf("usage: %s", ctx.Command.ArgsUsage)
+	}
+	stack, _ := makeConfigNode(ctx)
+	defer stack.Close()
+
+	chain, db := utils.MakeChain(ctx, stack)
+	defer db.Close()
+	defer chain.Stop()
+
+	start := time.Now()
+	if err := utils.ImportHistory(chain, ctx.Args().First(), historyNetwork(ctx)); err != nil {
+		utils.Fatalf("Import error: %v\n", err)
+	}
+	fmt.Printf("Import done in %v\n", time.Since(start))
+	return nil
+}
+
+func exportHistory(ctx *cli.Context) error {
+	if ctx.Args().Len() != 3 {
+		utils.Fatalf("usage: %s", ctx.Command.ArgsUsage)
+	}
+	stack, _ := makeConfigNode(ctx)
+	defer stack.Close()
+
+	chain, _ := utils.MakeChain(ctx, stack)
+	start := time.Now()
+
+	first, ferr := strconv.ParseUint(ctx.Args().Get(1), 10, 64)
+	last, lerr := strconv.ParseUint(ctx.Args().Get(2), 10, 64)
+	if ferr != nil || lerr != nil {
+		utils.Fatalf("Export error in parsing parameters: block number not an integer\n")
+	}
+	if first > last {
+		utils.Fatalf("Export error: first block %d larger than last block %d\n", first, last)
+	}
+	if err := utils.ExportHistory(chain, ctx.Args().First(), historyNetwork(ctx), first, last, era.MaxEra1Size); err != nil {
+		utils.Fatalf("Export error: %v\n", err)
+	}
+	fmt.Printf("Export done in %v\n", time.Since(start))
+	return nil
+}
+
+func verifyHistory(ctx *cli.Context) error {
+	if ctx.Args().Len() != 1 {
+		utils.Fatalf("usage: %s", ctx.Command.ArgsUsage)
+	}
+	if err := utils.VerifyHistory(ctx.Args().First(), historyNetwork(ctx)); err != nil {
+		utils.Fatalf("Verification failed: %v\n", err)
+	}
+	return nil
+}
+
+// historyNetwork returns the network name used in era1 file names.
+func historyNetwork(ctx *cli.Context) string {
+	for _, flag := range utils.TestnetFlags {
+		if name := flag.Names()[0]; ctx.Bool(name) {
+			return name
+		}
+	}
+	return "mainnet"
+}
+
 // importPreimages imports preimage data from the specified file.
 func importPreimages(ctx *cli.Context) error {
 	if ctx.Args().Len() < 1 {
@@ -192,6 +192,11 @@ func makeFullNode(ctx *cli.Context) (*node.Node, ethapi.Backend) {
 		utils.RegisterGraphQLService(stack, backend, filterSystem, &cfg.Node)
 	}
 
+	// Configure the Protobuf-over-HTTP API if requested.
+	if ctx.IsSet(utils.HTTPProtoEnabledFlag.Name) {
+		utils.RegisterProtoService(stack, backend, filterSystem, &cfg.Node)
+	}
+
 	// Add the Confero Stats daemon if requested.
 	if cfg.Ethstats.URL != "" {
 		utils.RegisterEthStatsService(stack, backend, cfg.Ethstats.URL)
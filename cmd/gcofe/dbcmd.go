@@ -18,6 +18,7 @@ package main
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"os"
 	"os/signal"
@@ -31,13 +32,16 @@ import (
 	"github.com/confero-network/go-confero/common"
 	"github.com/confero-network/go-confero/common/hexutil"
 	"github.com/confero-network/go-confero/console/prompt"
+	"github.com/confero-network/go-confero/core"
 	"github.com/confero-network/go-confero/core/rawdb"
 	"github.com/confero-network/go-confero/core/state/snapshot"
 	"github.com/confero-network/go-confero/core/types"
 	"github.com/confero-network/go-confero/crypto"
 	"github.com/confero-network/go-confero/ethdb"
+	"github.com/confero-network/go-confero/event"
 	"github.com/confero-network/go-confero/internal/flags"
 	"github.com/confero-network/go-confero/log"
+	"github.com/confero-network/go-confero/params"
 	"github.com/confero-network/go-confero/trie"
 	"github.com/olekukonko/tablewriter"
 	"github.com/urfave/cli/v2"
@@ -71,6 +75,7 @@ Remove blockchain and state databases`,
 			dbMetadataCmd,
 			dbMigrateFreezerCmd,
 			dbCheckStateContentCmd,
+			dbLogIndexCmd,
 		},
 	}
 	dbInspectCmd = &cli.Command{
@@ -206,6 +211,18 @@ WARNING: This is a low-level operation which may cause database corruption!`,
 		Description: `The freezer-migrate command checks your database for receipts in a legacy format and updates those.
 WARNING: please back-up the receipt files in your ancients before running this command.`,
 	}
+	dbLogIndexCmd = &cli.Command{
+		Action:    buildLogIndex,
+		Name:      "logindex",
+		Usage:     "Build the log index of the existing chain",
+		ArgsUsage: "",
+		Flags: flags.Merge([]cli.Flag{
+			utils.SyncModeFlag,
+		}, utils.NetworkFlags, utils.DatabasePathFlags),
+		Description: `The logindex command builds the exact log index (see --logindex) for all
+blocks of the chain which are already imported, so it can be used for log filtering
+as soon as the node is started with --logindex. Building can be aborted and resumed.`,
+	}
 )
 
 func removeDB(ctx *cli.Context) error {
@@ -830,3 +847,79 @@ func dbHasLegacyReceipts(db ethdb.Database, firstIdx uint64) (bool, uint64, erro
 	legacy, err = types.IsLegacyStoredReceipts(first)
 	return legacy, firstIdx, err
 }
+
+// offlineChain implements core.ChainIndexerChain on top of a database which is
+// not used by a running node, so the chain head never changes.
+type offlineChain struct {
+	db ethdb.Database
+}
+
+func (c *offlineChain) CurrentHeader() *types.Header {
+	hash := rawdb.ReadHeadBlockHash(c.db)
+	number := rawdb.ReadHeaderNumber(c.db, hash)
+	if number == nil {
+		return nil
+	}
+	return rawdb.ReadHeader(c.db, hash, *number)
+}
+
+func (c *offlineChain) SubscribeChainHeadEvent(ch chan<- core.ChainHeadEvent) event.Subscription {
+	return event.NewSubscription(func(quit <-chan struct{}) error {
+		<-quit
+		return nil
+	})
+}
+
+// buildLogIndex indexes the logs of the chain up to the head block.
+func buildLogIndex(ctx *cli.Context) error {
+	stack, _ := makeConfigNode(ctx)
+	defer stack.Close()
+
+	db := utils.MakeChainDatabase(ctx, stack, false)
+	defer db.Close()
+
+	chain := &offlineChain{db: db}
+	head := chain.CurrentHeader()
+	if head == nil {
+		return errors.New("head block not found")
+	}
+	var (
+		size     = params.BloomBitsBlocks
+		confirms = uint64(params.BloomConfirms)
+		target   uint64
+	)
+	if number := head.Number.Uint64(); number+1 >= confirms {
+		target = (number + 1 - confirms) / size
+	}
+	indexer := core.NewLogIndexer(db, size, confirms)
+	indexer.Start(chain)
+	defer indexer.Close()
+
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(interrupt)
+
+	var (
+		start  = time.Now()
+		logged time.Time
+		ticker = time.NewTicker(time.Second)
+	)
+	defer ticker.Stop()
+	for {
+		sections, _, _ := indexer.Sections()
+		if sections >= target {
+			log.Info("Log index built", "sections", sections, "blocks", sections*size, "elapsed", common.PrettyDuration(time.Since(start)))
+			return nil
+		}
+		if time.Since(logged) > 8*time.Second {
+			log.Info("Building log index", "sections", sections, "target", target, "elapsed", common.PrettyDuration(time.Since(start)))
+			logged = time.Now()
+		}
+		select {
+		case <-ticker.C:
+		case <-interrupt:
+			log.Info("Log index building interrupted, resume by running the command again", "sections", sections)
+			return nil
+		}
+	}
+}
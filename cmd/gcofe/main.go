@@ -39,6 +39,7 @@ import (
 	"github.com/confero-network/go-confero/log"
 	"github.com/confero-network/go-confero/metrics"
 	"github.com/confero-network/go-confero/node"
+	"github.com/confero-network/go-confero/tracing"
 
 	// Force-load the tracer engines to trigger registration
 	_ "github.com/confero-network/go-confero/eth/tracers/js"
@@ -94,7 +95,15 @@ var (
 		utils.ExitWhenSyncedFlag,
 		utils.GCModeFlag,
 		utils.SnapshotFlag,
+		utils.SnapNoServeFlag,
+		utils.SnapServeThreadsFlag,
+		utils.SnapServePeerShareFlag,
+		utils.SnapServeMaxQueuedFlag,
+		utils.SnapServeMaxWaitFlag,
+		utils.SyncFromFlag,
+		utils.SyncFromSignersFlag,
 		utils.TxLookupLimitFlag,
+		utils.LogIndexFlag,
 		utils.LightServeFlag,
 		utils.LightIngressFlag,
 		utils.LightEgressFlag,
@@ -123,6 +132,9 @@ var (
 		utils.DiscoveryPortFlag,
 		utils.MaxPeersFlag,
 		utils.MaxPendingPeersFlag,
+		utils.BandwidthIngressFlag,
+		utils.BandwidthEgressFlag,
+		utils.NetCaptureFlag,
 		utils.MiningEnabledFlag,
 		utils.MinerThreadsFlag,
 		utils.MinerNotifyFlag,
@@ -144,6 +156,7 @@ var (
 		utils.DeveloperPeriodFlag,
 		utils.DeveloperGasLimitFlag,
 		utils.VMEnableDebugFlag,
+		utils.VMWitnessFlag,
 		utils.NetworkIdFlag,
 		utils.EthStatsURLFlag,
 		utils.FakePoWFlag,
@@ -170,8 +183,12 @@ var (
 		utils.GraphQLEnabledFlag,
 		utils.GraphQLCORSDomainFlag,
 		utils.GraphQLVirtualHostsFlag,
+		utils.GraphQLMaxCostFlag,
+		utils.GraphQLMaxDepthFlag,
+		utils.GraphQLTimeoutFlag,
 		utils.HTTPApiFlag,
 		utils.HTTPPathPrefixFlag,
+		utils.HTTPProtoEnabledFlag,
 		utils.WSEnabledFlag,
 		utils.WSListenAddrFlag,
 		utils.WSPortFlag,
@@ -184,6 +201,14 @@ var (
 		utils.RPCGlobalGasCapFlag,
 		utils.RPCGlobalEVMTimeoutFlag,
 		utils.RPCGlobalTxFeeCapFlag,
+		utils.RPCLogsMaxBlockRangeFlag,
+		utils.RPCLogsMaxResultsFlag,
+		utils.RPCAPIKeysFlag,
+		utils.RPCRateLimitFlag,
+		utils.RPCRateLimitBurstFlag,
+		utils.RPCExpensiveRateLimitFlag,
+		utils.RPCExpensiveRateLimitBurstFlag,
+		utils.RPCMaxConcurrentFlag,
 		utils.AllowUnprotectedTxs,
 	}
 
@@ -202,6 +227,9 @@ var (
 		utils.MetricsInfluxDBTokenFlag,
 		utils.MetricsInfluxDBBucketFlag,
 		utils.MetricsInfluxDBOrganizationFlag,
+		utils.TracingEndpointFlag,
+		utils.TracingFileFlag,
+		utils.TracingSampleRatioFlag,
 	}
 )
 
@@ -215,6 +243,9 @@ func init() {
 		initCommand,
 		importCommand,
 		exportCommand,
+		importHistoryCommand,
+		exportHistoryCommand,
+		verifyHistoryCommand,
 		importPreimagesCommand,
 		exportPreimagesCommand,
 		removedbCommand,
@@ -258,6 +289,7 @@ func init() {
 	}
 	app.After = func(ctx *cli.Context) error {
 		debug.Exit()
+		tracing.Stop()
 		prompt.Stdin.Close() // Resets terminal mode.
 		return nil
 	}
@@ -336,6 +368,9 @@ func prepare(ctx *cli.Context) {
 
 	// Start system runtime metrics collection
 	go metrics.CollectProcessMetrics(3 * time.Second)
+
+	// Start tracing of RPC calls if enabled
+	utils.SetupTracing(ctx)
 }
 
 // gcofe is the main entry point into the system if no special subcommand is ran.
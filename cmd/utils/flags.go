@@ -65,6 +65,8 @@ import (
 	"github.com/confero-network/go-confero/p2p/nat"
 	"github.com/confero-network/go-confero/p2p/netutil"
 	"github.com/confero-network/go-confero/params"
+	"github.com/confero-network/go-confero/protorpc"
+	"github.com/confero-network/go-confero/tracing"
 	"github.com/confero-network/go-confero/rpc"
 	pcsclite "github.com/gballet/go-libpcsclite"
 	gopsutil "github.com/shirou/gopsutil/mem"
@@ -96,6 +98,16 @@ var (
 		Usage:    "Root directory for ancient data (default = inside chaindata)",
 		Category: flags.EthCategory,
 	}
+	AncientRemoteFlag = &cli.StringFlag{
+		Name:     "datadir.ancient.remote",
+		Usage:    "Endpoint (IPC path or URL) of a shared read-only remote ancient store, serving the history below the local one",
+		Category: flags.EthCategory,
+	}
+	AncientEraFlag = &flags.DirectoryFlag{
+		Name:     "datadir.era",
+		Usage:    "Directory of era1 history files served as read-only ancient store, below the local one",
+		Category: flags.EthCategory,
+	}
 	MinFreeDiskSpaceFlag = &flags.DirectoryFlag{
 		Name:     "datadir.minfreedisk",
 		Usage:    "Minimum free disk space in MB, once reached triggers auto shut down (default = --cache.gc converted to MB, 0 = disabled)",
@@ -237,12 +249,46 @@ var (
 		Value:    true,
 		Category: flags.EthCategory,
 	}
+	SnapNoServeFlag = &cli.BoolFlag{
+		Name:     "snap.noserve",
+		Usage:    "Disables serving snap sync requests to other peers, the protocol only runs while snap syncing",
+		Category: flags.EthCategory,
+	}
+	SnapServeThreadsFlag = &cli.IntFlag{
+		Name:     "snap.threads",
+		Usage:    "Maximum number of snap requests served concurrently",
+		Value:    ethconfig.Defaults.SnapServeThreads,
+		Category: flags.EthCategory,
+	}
+	SnapServePeerShareFlag = &cli.Float64Flag{
+		Name:     "snap.peershare",
+		Usage:    "Average share of the snap serving threads a single peer can use (0-1)",
+		Value:    ethconfig.Defaults.SnapServePeerShare,
+		Category: flags.EthCategory,
+	}
+	SnapServeMaxQueuedFlag = &cli.IntFlag{
+		Name:     "snap.maxqueued",
+		Usage:    "Maximum number of snap requests waiting to be served",
+		Value:    ethconfig.Defaults.SnapServeMaxQueued,
+		Category: flags.EthCategory,
+	}
+	SnapServeMaxWaitFlag = &cli.DurationFlag{
+		Name:     "snap.maxwait",
+		Usage:    "Maximum time a snap request waits before it's served with a capped response",
+		Value:    ethconfig.Defaults.SnapServeMaxWait,
+		Category: flags.EthCategory,
+	}
 	TxLookupLimitFlag = &cli.Uint64Flag{
 		Name:     "txlookuplimit",
 		Usage:    "Number of recent blocks to maintain transactions index for (default = about one year, 0 = entire chain)",
 		Value:    ethconfig.Defaults.TxLookupLimit,
 		Category: flags.EthCategory,
 	}
+	LogIndexFlag = &cli.BoolFlag{
+		Name:     "logindex",
+		Usage:    "Maintain an exact index of log addresses and topics for fast log filtering",
+		Category: flags.EthCategory,
+	}
 	LightKDFFlag = &cli.BoolFlag{
 		Name:     "lightkdf",
 		Usage:    "Reduce key-derivation RAM & CPU usage at some expense of KDF strength",
@@ -253,6 +299,16 @@ var (
 		Usage:    "Comma separated block number-to-hash mappings to require for peering (<number>=<hash>)",
 		Category: flags.EthCategory,
 	}
+	SyncFromFlag = &cli.StringFlag{
+		Name:     "syncfrom",
+		Usage:    "Trusted block to sync the chain from instead of the genesis (<number>:<hash>:<td> or checkpoint file)",
+		Category: flags.EthCategory,
+	}
+	SyncFromSignersFlag = &cli.StringFlag{
+		Name:     "syncfrom.signers",
+		Usage:    "Comma separated addresses of trusted checkpoint file signers",
+		Category: flags.EthCategory,
+	}
 	LegacyWhitelistFlag = &cli.StringFlag{
 		Name:     "whitelist",
 		Usage:    "Comma separated block number-to-hash mappings to enforce (<number>=<hash>) (deprecated in favor of --eth.requiredblocks)",
@@ -593,6 +649,11 @@ var (
 		Usage:    "Record information useful for VM and contract debugging",
 		Category: flags.VMCategory,
 	}
+	VMWitnessFlag = &cli.BoolFlag{
+		Name:     "vmwitness",
+		Usage:    "Collect stateless execution witnesses while importing blocks",
+		Category: flags.VMCategory,
+	}
 
 	// API options.
 	RPCGlobalGasCapFlag = &cli.Uint64Flag{
@@ -613,6 +674,46 @@ var (
 		Value:    ethconfig.Defaults.RPCTxFeeCap,
 		Category: flags.APICategory,
 	}
+	RPCLogsMaxBlockRangeFlag = &cli.Uint64Flag{
+		Name:     "rpc.logs.maxrange",
+		Usage:    "Maximum number of blocks scanned by an eth_getLogs query (0 = no limit)",
+		Category: flags.APICategory,
+	}
+	RPCLogsMaxResultsFlag = &cli.IntFlag{
+		Name:     "rpc.logs.maxresults",
+		Usage:    "Maximum number of logs returned by an eth_getLogs query (0 = no limit)",
+		Category: flags.APICategory,
+	}
+	RPCAPIKeysFlag = &cli.StringFlag{
+		Name:     "rpc.apikeys",
+		Usage:    "Path to a JSON file of API keys required on the HTTP, WebSocket and Protobuf RPC endpoints",
+		Category: flags.APICategory,
+	}
+	RPCRateLimitFlag = &cli.Float64Flag{
+		Name:     "rpc.ratelimit",
+		Usage:    "Maximum number of HTTP and WebSocket RPC calls per second per client (0 = no limit)",
+		Category: flags.APICategory,
+	}
+	RPCRateLimitBurstFlag = &cli.IntFlag{
+		Name:     "rpc.ratelimit.burst",
+		Usage:    "Maximum burst of HTTP and WebSocket RPC calls per client (0 = rate limit)",
+		Category: flags.APICategory,
+	}
+	RPCExpensiveRateLimitFlag = &cli.Float64Flag{
+		Name:     "rpc.ratelimit.expensive",
+		Usage:    "Maximum number of expensive RPC calls (eth_call, eth_estimateGas, eth_getLogs, debug_trace*, proto_GetLogs) per second per client (0 = no limit)",
+		Category: flags.APICategory,
+	}
+	RPCExpensiveRateLimitBurstFlag = &cli.IntFlag{
+		Name:     "rpc.ratelimit.expensive.burst",
+		Usage:    "Maximum burst of expensive RPC calls per client (0 = expensive rate limit)",
+		Category: flags.APICategory,
+	}
+	RPCMaxConcurrentFlag = &cli.IntFlag{
+		Name:     "rpc.maxconcurrent",
+		Usage:    "Maximum number of RPC calls processed at once per client (0 = no limit)",
+		Category: flags.APICategory,
+	}
 	// Authenticated RPC HTTP settings
 	AuthListenFlag = &cli.StringFlag{
 		Name:     "authrpc.addr",
@@ -713,6 +814,11 @@ var (
 		Value:    "",
 		Category: flags.APICategory,
 	}
+	HTTPProtoEnabledFlag = &cli.BoolFlag{
+		Name:     "http.proto",
+		Usage:    "Enable the Protobuf-over-HTTP API on the HTTP-RPC server at /proto. Uses the CORS, virtual host, API key and rate limit settings of the HTTP-RPC server.",
+		Category: flags.APICategory,
+	}
 	GraphQLEnabledFlag = &cli.BoolFlag{
 		Name:     "graphql",
 		Usage:    "Enable GraphQL on the HTTP-RPC server. Note that GraphQL can only be started if an HTTP server is started as well.",
@@ -730,6 +836,24 @@ var (
 		Value:    strings.Join(node.DefaultConfig.GraphQLVirtualHosts, ","),
 		Category: flags.APICategory,
 	}
+	GraphQLMaxCostFlag = &cli.Uint64Flag{
+		Name:     "graphql.maxcost",
+		Usage:    "Maximum estimated cost of a GraphQL query (0 = no limit)",
+		Value:    node.DefaultConfig.GraphQLMaxCost,
+		Category: flags.APICategory,
+	}
+	GraphQLMaxDepthFlag = &cli.IntFlag{
+		Name:     "graphql.maxdepth",
+		Usage:    "Maximum nesting depth of a GraphQL query (0 = no limit)",
+		Value:    node.DefaultConfig.GraphQLMaxDepth,
+		Category: flags.APICategory,
+	}
+	GraphQLTimeoutFlag = &cli.DurationFlag{
+		Name:     "graphql.timeout",
+		Usage:    "Maximum execution time of a GraphQL query (0 = no limit)",
+		Value:    node.DefaultConfig.GraphQLTimeout,
+		Category: flags.APICategory,
+	}
 	WSEnabledFlag = &cli.BoolFlag{
 		Name:     "ws",
 		Usage:    "Enable the WS-RPC server",
@@ -794,6 +918,21 @@ var (
 		Value:    node.DefaultConfig.P2P.MaxPendingPeers,
 		Category: flags.NetworkingCategory,
 	}
+	BandwidthIngressFlag = &cli.IntFlag{
+		Name:     "bandwidth.ingress",
+		Usage:    "Maximum inbound bandwidth of all peer connections in bytes per second (0 = unlimited)",
+		Category: flags.NetworkingCategory,
+	}
+	BandwidthEgressFlag = &cli.IntFlag{
+		Name:     "bandwidth.egress",
+		Usage:    "Maximum outbound bandwidth of all peer connections in bytes per second (0 = unlimited)",
+		Category: flags.NetworkingCategory,
+	}
+	NetCaptureFlag = &cli.StringFlag{
+		Name:     "netcapture",
+		Usage:    "Records all protocol messages exchanged with peers to the given file, which must not exist",
+		Category: flags.NetworkingCategory,
+	}
 	ListenPortFlag = &cli.IntFlag{
 		Name:     "port",
 		Usage:    "Network listening port",
@@ -818,7 +957,7 @@ var (
 	}
 	NATFlag = &cli.StringFlag{
 		Name:     "nat",
-		Usage:    "NAT port mapping mechanism (any|none|upnp|pmp|extip:<IP>)",
+		Usage:    "NAT port mapping mechanism (any|none|upnp|pmp|extip:<IP>|discover)",
 		Value:    "any",
 		Category: flags.NetworkingCategory,
 	}
@@ -977,6 +1116,24 @@ var (
 		Value:    metrics.DefaultConfig.InfluxDBOrganization,
 		Category: flags.MetricsCategory,
 	}
+
+	// Tracing settings
+	TracingEndpointFlag = &cli.StringFlag{
+		Name:     "tracing.endpoint",
+		Usage:    "Enable tracing of RPC calls and export the traces to this OTLP/HTTP endpoint (e.g. http://localhost:4318/v1/traces)",
+		Category: flags.MetricsCategory,
+	}
+	TracingFileFlag = &cli.StringFlag{
+		Name:     "tracing.file",
+		Usage:    "Enable tracing of RPC calls and append the traces to this file, in the OTLP JSON encoding",
+		Category: flags.MetricsCategory,
+	}
+	TracingSampleRatioFlag = &cli.Float64Flag{
+		Name:     "tracing.sampleratio",
+		Usage:    "Fraction of the RPC calls which are traced, unless the caller decides via a traceparent header",
+		Value:    1,
+		Category: flags.MetricsCategory,
+	}
 )
 
 var (
@@ -997,6 +1154,8 @@ var (
 	DatabasePathFlags = []cli.Flag{
 		DataDirFlag,
 		AncientFlag,
+		AncientRemoteFlag,
+		AncientEraFlag,
 		RemoteDBFlag,
 	}
 )
@@ -1212,6 +1371,15 @@ func setGraphQL(ctx *cli.Context, cfg *node.Config) {
 	if ctx.IsSet(GraphQLVirtualHostsFlag.Name) {
 		cfg.GraphQLVirtualHosts = SplitAndTrim(ctx.String(GraphQLVirtualHostsFlag.Name))
 	}
+	if ctx.IsSet(GraphQLMaxCostFlag.Name) {
+		cfg.GraphQLMaxCost = ctx.Uint64(GraphQLMaxCostFlag.Name)
+	}
+	if ctx.IsSet(GraphQLMaxDepthFlag.Name) {
+		cfg.GraphQLMaxDepth = ctx.Int(GraphQLMaxDepthFlag.Name)
+	}
+	if ctx.IsSet(GraphQLTimeoutFlag.Name) {
+		cfg.GraphQLTimeout = ctx.Duration(GraphQLTimeoutFlag.Name)
+	}
 }
 
 // setWS creates the WebSocket RPC listener interface string from the set
@@ -1420,6 +1588,15 @@ func SetP2PConfig(ctx *cli.Context, cfg *p2p.Config) {
 	if ctx.IsSet(MaxPendingPeersFlag.Name) {
 		cfg.MaxPendingPeers = ctx.Int(MaxPendingPeersFlag.Name)
 	}
+	if ctx.IsSet(BandwidthIngressFlag.Name) {
+		cfg.BandwidthLimit.Ingress = ctx.Int(BandwidthIngressFlag.Name)
+	}
+	if ctx.IsSet(BandwidthEgressFlag.Name) {
+		cfg.BandwidthLimit.Egress = ctx.Int(BandwidthEgressFlag.Name)
+	}
+	if ctx.IsSet(NetCaptureFlag.Name) {
+		cfg.CaptureFile = ctx.String(NetCaptureFlag.Name)
+	}
 	if ctx.IsSet(NoDiscoverFlag.Name) || lightClient {
 		cfg.NoDiscovery = true
 	}
@@ -1452,6 +1629,25 @@ func SetP2PConfig(ctx *cli.Context, cfg *p2p.Config) {
 	}
 }
 
+// setRPCRateLimits applies the RPC rate limiting flags to the config.
+func setRPCRateLimits(ctx *cli.Context, cfg *rpc.RateLimitConfig) {
+	if ctx.IsSet(RPCRateLimitFlag.Name) {
+		cfg.Rate = ctx.Float64(RPCRateLimitFlag.Name)
+	}
+	if ctx.IsSet(RPCRateLimitBurstFlag.Name) {
+		cfg.Burst = ctx.Int(RPCRateLimitBurstFlag.Name)
+	}
+	if ctx.IsSet(RPCExpensiveRateLimitFlag.Name) {
+		cfg.ExpensiveRate = ctx.Float64(RPCExpensiveRateLimitFlag.Name)
+	}
+	if ctx.IsSet(RPCExpensiveRateLimitBurstFlag.Name) {
+		cfg.ExpensiveBurst = ctx.Int(RPCExpensiveRateLimitBurstFlag.Name)
+	}
+	if ctx.IsSet(RPCMaxConcurrentFlag.Name) {
+		cfg.MaxConcurrent = ctx.Int(RPCMaxConcurrentFlag.Name)
+	}
+}
+
 // SetNodeConfig applies node-related command line flags to the config.
 func SetNodeConfig(ctx *cli.Context, cfg *node.Config) {
 	SetP2PConfig(ctx, &cfg.P2P)
@@ -1466,6 +1662,10 @@ func SetNodeConfig(ctx *cli.Context, cfg *node.Config) {
 	if ctx.IsSet(JWTSecretFlag.Name) {
 		cfg.JWTSecret = ctx.String(JWTSecretFlag.Name)
 	}
+	if ctx.IsSet(RPCAPIKeysFlag.Name) {
+		cfg.APIKeysFile = ctx.String(RPCAPIKeysFlag.Name)
+	}
+	setRPCRateLimits(ctx, &cfg.RPCRateLimits)
 
 	if ctx.IsSet(ExternalSignerFlag.Name) {
 		cfg.ExternalSigner = ctx.String(ExternalSignerFlag.Name)
@@ -1683,6 +1883,41 @@ func setRequiredBlocks(ctx *cli.Context, cfg *ethconfig.Config) {
 	}
 }
 
+// setSyncFrom configures the trusted block to sync the chain from.
+func setSyncFrom(ctx *cli.Context, cfg *ethconfig.Config) {
+	if !ctx.IsSet(SyncFromFlag.Name) {
+		return
+	}
+	var (
+		value   = ctx.String(SyncFromFlag.Name)
+		signers []common.Address
+	)
+	if ctx.IsSet(SyncFromSignersFlag.Name) {
+		for _, account := range SplitAndTrim(ctx.String(SyncFromSignersFlag.Name)) {
+			if !common.IsHexAddress(account) {
+				Fatalf("Invalid checkpoint signer address: %s", account)
+			}
+			signers = append(signers, common.HexToAddress(account))
+		}
+	}
+	var (
+		cp  *ethconfig.SyncCheckpoint
+		err error
+	)
+	if _, statErr := os.Stat(value); statErr == nil {
+		cp, err = ethconfig.LoadSyncCheckpoint(value, signers)
+	} else {
+		if len(signers) > 0 {
+			Fatalf("Option %q requires a checkpoint file", SyncFromSignersFlag.Name)
+		}
+		cp, err = ethconfig.ParseSyncCheckpoint(value)
+	}
+	if err != nil {
+		Fatalf("Invalid sync checkpoint: %v", err)
+	}
+	cfg.SyncFrom = cp
+}
+
 // CheckExclusive verifies that only a single instance of the provided flags was
 // set by the user. Each flag might optionally be followed by a string type to
 // specialize it further.
@@ -1747,6 +1982,7 @@ func SetEthConfig(ctx *cli.Context, stack *node.Node, cfg *ethconfig.Config) {
 	setEthash(ctx, cfg)
 	setMiner(ctx, &cfg.Miner)
 	setRequiredBlocks(ctx, cfg)
+	setSyncFrom(ctx, cfg)
 	setLes(ctx, cfg)
 
 	// Cap the cache allowance and tune the garbage collector
@@ -1782,6 +2018,12 @@ func SetEthConfig(ctx *cli.Context, stack *node.Node, cfg *ethconfig.Config) {
 	if ctx.IsSet(AncientFlag.Name) {
 		cfg.DatabaseFreezer = ctx.String(AncientFlag.Name)
 	}
+	if ctx.IsSet(AncientRemoteFlag.Name) {
+		cfg.DatabaseAncients = ctx.String(AncientRemoteFlag.Name)
+	}
+	if ctx.IsSet(AncientEraFlag.Name) {
+		cfg.DatabaseEra = ctx.String(AncientEraFlag.Name)
+	}
 
 	if gcmode := ctx.String(GCModeFlag.Name); gcmode != "full" && gcmode != "archive" {
 		Fatalf("--%s must be either 'full' or 'archive'", GCModeFlag.Name)
@@ -1801,6 +2043,9 @@ func SetEthConfig(ctx *cli.Context, stack *node.Node, cfg *ethconfig.Config) {
 	if ctx.IsSet(TxLookupLimitFlag.Name) {
 		cfg.TxLookupLimit = ctx.Uint64(TxLookupLimitFlag.Name)
 	}
+	if ctx.IsSet(LogIndexFlag.Name) {
+		cfg.LogIndex = ctx.Bool(LogIndexFlag.Name)
+	}
 	if ctx.IsSet(CacheFlag.Name) || ctx.IsSet(CacheTrieFlag.Name) {
 		cfg.TrieCleanCache = ctx.Int(CacheFlag.Name) * ctx.Int(CacheTrieFlag.Name) / 100
 	}
@@ -1828,6 +2073,21 @@ func SetEthConfig(ctx *cli.Context, stack *node.Node, cfg *ethconfig.Config) {
 			cfg.SnapshotCache = 0 // Disabled
 		}
 	}
+	if ctx.IsSet(SnapNoServeFlag.Name) {
+		cfg.SnapNoServe = ctx.Bool(SnapNoServeFlag.Name)
+	}
+	if ctx.IsSet(SnapServeThreadsFlag.Name) {
+		cfg.SnapServeThreads = ctx.Int(SnapServeThreadsFlag.Name)
+	}
+	if ctx.IsSet(SnapServePeerShareFlag.Name) {
+		cfg.SnapServePeerShare = ctx.Float64(SnapServePeerShareFlag.Name)
+	}
+	if ctx.IsSet(SnapServeMaxQueuedFlag.Name) {
+		cfg.SnapServeMaxQueued = ctx.Int(SnapServeMaxQueuedFlag.Name)
+	}
+	if ctx.IsSet(SnapServeMaxWaitFlag.Name) {
+		cfg.SnapServeMaxWait = ctx.Duration(SnapServeMaxWaitFlag.Name)
+	}
 	if ctx.IsSet(DocRootFlag.Name) {
 		cfg.DocRoot = ctx.String(DocRootFlag.Name)
 	}
@@ -1835,6 +2095,9 @@ func SetEthConfig(ctx *cli.Context, stack *node.Node, cfg *ethconfig.Config) {
 		// TODO(fjl): force-enable this in --dev mode
 		cfg.EnablePreimageRecording = ctx.Bool(VMEnableDebugFlag.Name)
 	}
+	if ctx.IsSet(VMWitnessFlag.Name) {
+		cfg.EnableWitnessCollection = ctx.Bool(VMWitnessFlag.Name)
+	}
 
 	if ctx.IsSet(RPCGlobalGasCapFlag.Name) {
 		cfg.RPCGasCap = ctx.Uint64(RPCGlobalGasCapFlag.Name)
@@ -1847,6 +2110,12 @@ func SetEthConfig(ctx *cli.Context, stack *node.Node, cfg *ethconfig.Config) {
 	if ctx.IsSet(RPCGlobalEVMTimeoutFlag.Name) {
 		cfg.RPCEVMTimeout = ctx.Duration(RPCGlobalEVMTimeoutFlag.Name)
 	}
+	if ctx.IsSet(RPCLogsMaxBlockRangeFlag.Name) {
+		cfg.RPCLogsMaxBlockRange = ctx.Uint64(RPCLogsMaxBlockRangeFlag.Name)
+	}
+	if ctx.IsSet(RPCLogsMaxResultsFlag.Name) {
+		cfg.RPCLogsMaxResults = ctx.Int(RPCLogsMaxResultsFlag.Name)
+	}
 	if ctx.IsSet(RPCGlobalTxFeeCapFlag.Name) {
 		cfg.RPCTxFeeCap = ctx.Float64(RPCGlobalTxFeeCapFlag.Name)
 	}
@@ -2025,17 +2294,28 @@ func RegisterEthStatsService(stack *node.Node, backend ethapi.Backend, url strin
 
 // RegisterGraphQLService adds the GraphQL API to the node.
 func RegisterGraphQLService(stack *node.Node, backend ethapi.Backend, filterSystem *filters.FilterSystem, cfg *node.Config) {
-	err := graphql.New(stack, backend, filterSystem, cfg.GraphQLCors, cfg.GraphQLVirtualHosts)
+	err := graphql.New(stack, backend, filterSystem, cfg.GraphQLCors, cfg.GraphQLVirtualHosts, graphql.Config{
+		MaxCost:  cfg.GraphQLMaxCost,
+		MaxDepth: cfg.GraphQLMaxDepth,
+		Timeout:  cfg.GraphQLTimeout,
+	})
 	if err != nil {
 		Fatalf("Failed to register the GraphQL service: %v", err)
 	}
 }
 
+// RegisterProtoService adds the Protobuf-over-HTTP API to the node.
+func RegisterProtoService(stack *node.Node, backend ethapi.Backend, filterSystem *filters.FilterSystem, cfg *node.Config) {
+	protorpc.New(stack, backend, filterSystem, cfg.HTTPCors, cfg.HTTPVirtualHosts)
+}
+
 // RegisterFilterAPI adds the eth log filtering RPC API to the node.
 func RegisterFilterAPI(stack *node.Node, backend ethapi.Backend, ethcfg *ethconfig.Config) *filters.FilterSystem {
 	isLightClient := ethcfg.SyncMode == downloader.LightSync
 	filterSystem := filters.NewFilterSystem(backend, filters.Config{
-		LogCacheSize: ethcfg.FilterLogCacheSize,
+		LogCacheSize:  ethcfg.FilterLogCacheSize,
+		MaxBlockRange: ethcfg.RPCLogsMaxBlockRange,
+		MaxLogs:       ethcfg.RPCLogsMaxResults,
 	})
 	stack.RegisterAPIs([]rpc.API{{
 		Namespace: "eth",
@@ -2103,6 +2383,22 @@ func SetupMetrics(ctx *cli.Context) {
 	}
 }
 
+// SetupTracing enables tracing of RPC calls if an exporter is configured.
+func SetupTracing(ctx *cli.Context) {
+	config := tracing.Config{
+		Endpoint:    ctx.String(TracingEndpointFlag.Name),
+		File:        ctx.String(TracingFileFlag.Name),
+		SampleRatio: ctx.Float64(TracingSampleRatioFlag.Name),
+	}
+	if config.Endpoint == "" && config.File == "" {
+		return
+	}
+	log.Info("Enabling tracing of RPC calls", "endpoint", config.Endpoint, "file", config.File, "sampleratio", config.SampleRatio)
+	if err := tracing.Setup(config); err != nil {
+		Fatalf("Failed to set up tracing: %v", err)
+	}
+}
+
 func SplitTagsFlag(tagsFlag string) map[string]string {
 	tags := strings.Split(tagsFlag, ",")
 	tagsMap := map[string]string{}
@@ -2135,6 +2431,10 @@ func MakeChainDatabase(ctx *cli.Context, stack *node.Node, readonly bool) ethdb.
 		chainDb, err = remotedb.New(ctx.String(RemoteDBFlag.Name))
 	case ctx.String(SyncModeFlag.Name) == "light":
 		chainDb, err = stack.OpenDatabase("lightchaindata", cache, handles, "", readonly)
+	case ctx.IsSet(AncientRemoteFlag.Name):
+		chainDb, err = stack.OpenDatabaseWithRemoteAncients("chaindata", cache, handles, ctx.String(AncientFlag.Name), ctx.String(AncientRemoteFlag.Name), "", readonly)
+	case ctx.IsSet(AncientEraFlag.Name):
+		chainDb, err = stack.OpenDatabaseWithEraAncients("chaindata", cache, handles, ctx.String(AncientFlag.Name), ctx.String(AncientEraFlag.Name), "", readonly)
 	default:
 		chainDb, err = stack.OpenDatabaseWithFreezer("chaindata", cache, handles, ctx.String(AncientFlag.Name), "", readonly)
 	}
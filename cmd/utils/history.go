@@ -0,0 +1,401 @@
+// Copyright 2022 The go-confero Authors
+// This file is part of go-confero.
+//
+// go-confero is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-confero is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-confero. If not, see <http://www.gnu.org/licenses/>.
+
+package utils
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/confero-network/go-confero/common"
+	"github.com/confero-network/go-confero/core"
+	"github.com/confero-network/go-confero/core/types"
+	"github.com/confero-network/go-confero/internal/era"
+	"github.com/confero-network/go-confero/log"
+	"github.com/confero-network/go-confero/trie"
+)
+
+// historyChecksums is the name of the file listing the sha256 checksums of the
+// era1 files in a history directory.
+const historyChecksums = "checksums.txt"
+
+// ExportHistory exports the blocks in [first, last] with their receipts and
+// total difficulties into era1 files of step blocks each.
+func ExportHistory(bc *core.BlockChain, dir, network string, first, last, step uint64) error {
+	log.Info("Exporting blockchain history", "dir", dir)
+	if head := bc.CurrentBlock().NumberU64(); head < last {
+		log.Warn("Last block beyond head, setting last = head", "head", head, "last", last)
+		last = head
+	}
+	if step == 0 || step > era.MaxEra1Size {
+		return fmt.Errorf("invalid epoch size %d", step)
+	}
+	if first%step != 0 {
+		return fmt.Errorf("first block %d is not at an epoch boundary", first)
+	}
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		return fmt.Errorf("error creating output directory: %w", err)
+	}
+	var (
+		start     = time.Now()
+		reported  = time.Now()
+		checksums []string
+	)
+	for i := first; i <= last; i += step {
+		name, err := exportEpoch(bc, dir, network, i, min(i+step-1, last), int(i/step))
+		if err != nil {
+			return err
+		}
+		sum, err := fileChecksum(filepath.Join(dir, name))
+		if err != nil {
+			return err
+		}
+		checksums = append(checksums, sum+" "+name)
+
+		if time.Since(reported) >= 8*time.Second {
+			log.Info("Exporting blocks", "exported", i+step-first, "elapsed", common.PrettyDuration(time.Since(start)))
+			reported = time.Now()
+		}
+	}
+	if err := os.WriteFile(filepath.Join(dir, historyChecksums), []byte(strings.Join(checksums, "\n")+"\n"), os.ModePerm); err != nil {
+		return err
+	}
+	log.Info("Exported blockchain history", "dir", dir, "elapsed", common.PrettyDuration(time.Since(start)))
+	return nil
+}
+
+// exportEpoch writes the blocks in [from, to] into an era1 file and returns its
+// name.
+func exportEpoch(bc *core.BlockChain, dir, network string, from, to uint64, epoch int) (string, error) {
+	tmp := filepath.Join(dir, fmt.Sprintf("%s-%05d.era1.tmp", network, epoch))
+	f, err := os.Create(tmp)
+	if err != nil {
+		return "", fmt.Errorf("could not create era file: %w", err)
+	}
+	defer os.Remove(tmp)
+	defer f.Close()
+
+	w := era.NewBuilder(f)
+	for n := from; n <= to; n++ {
+		block := bc.GetBlockByNumber(n)
+		if block == nil {
+			return "", fmt.Errorf("export failed on #%d: not found", n)
+		}
+		receipts := bc.GetReceiptsByHash(block.Hash())
+		if receipts == nil {
+			return "", fmt.Errorf("export failed on #%d: receipts not found", n)
+		}
+		td := bc.GetTd(block.Hash(), n)
+		if td == nil {
+			return "", fmt.Errorf("export failed on #%d: total difficulty not found", n)
+		}
+		if err := w.Add(block, receipts, td); err != nil {
+			return "", err
+		}
+	}
+	root, err := w.Finalize()
+	if err != nil {
+		return "", fmt.Errorf("export failed to finalize %d: %w", epoch, err)
+	}
+	if err := f.Close(); err != nil {
+		return "", err
+	}
+	name := era.Filename(network, epoch, root)
+	if err := os.Rename(tmp, filepath.Join(dir, name)); err != nil {
+		return "", err
+	}
+	return name, nil
+}
+
+// ImportHistory imports the era1 files of a directory. The blocks, receipts
+// and total difficulties are written into the ancient store without executing
+// the blocks, so the chain must not contain blocks beyond the genesis yet.
+func ImportHistory(chain *core.BlockChain, dir, network string) error {
+	if head := chain.CurrentFastBlock().NumberU64(); head != 0 {
+		return fmt.Errorf("history import only supported when starting from genesis, head is #%d", head)
+	}
+	files, err := readHistoryDir(dir, network)
+	if err != nil {
+		return err
+	}
+	var (
+		start    = time.Now()
+		reported = time.Now()
+		imported = 0
+		checker  = newHistoryChecker(chain.Genesis().Header())
+	)
+	for _, name := range files {
+		err := forEachEpochBatch(filepath.Join(dir, name), checker, func(blocks []*types.Block, receipts []types.Receipts) error {
+			// The genesis block is already present.
+			if blocks[0].NumberU64() == 0 {
+				blocks, receipts = blocks[1:], receipts[1:]
+			}
+			if len(blocks) == 0 {
+				return nil
+			}
+			headers := make([]*types.Header, len(blocks))
+			for i, block := range blocks {
+				headers[i] = block.Header()
+			}
+			if _, err := chain.InsertHeaderChain(headers, 100); err != nil {
+				return fmt.Errorf("error inserting headers: %w", err)
+			}
+			if _, err := chain.InsertReceiptChain(blocks, receipts, math.MaxUint64); err != nil {
+				return fmt.Errorf("error inserting body receipt chain: %w", err)
+			}
+			imported += len(blocks)
+			if time.Since(reported) >= 8*time.Second {
+				log.Info("Importing history", "file", name, "imported", imported, "elapsed", common.PrettyDuration(time.Since(start)))
+				reported = time.Now()
+			}
+			return nil
+		})
+		if err != nil {
+			return fmt.Errorf("error importing %s: %w", name, err)
+		}
+	}
+	log.Info("Imported history", "blocks", imported, "elapsed", common.PrettyDuration(time.Since(start)))
+	return nil
+}
+
+// VerifyHistory checks the era1 files of a directory against their checksums,
+// verifies the block, receipt and total difficulty data of every block and
+// recomputes the accumulator roots.
+func VerifyHistory(dir, network string) error {
+	files, err := readHistoryDir(dir, network)
+	if err != nil {
+		return err
+	}
+	start := time.Now()
+	checker := newHistoryChecker(nil)
+	for _, name := range files {
+		err := forEachEpochBatch(filepath.Join(dir, name), checker, func([]*types.Block, []types.Receipts) error {
+			return nil
+		})
+		if err != nil {
+			return fmt.Errorf("error verifying %s: %w", name, err)
+		}
+		log.Info("Verified era file", "file", name)
+	}
+	log.Info("Verified history", "files", len(files), "elapsed", common.PrettyDuration(time.Since(start)))
+	return nil
+}
+
+// readHistoryDir lists the era1 files of a directory and checks them against
+// the checksum file, if present.
+func readHistoryDir(dir, network string) ([]string, error) {
+	files, err := era.ReadDir(dir, network)
+	if err != nil {
+		return nil, err
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no era1 files of network %q found in %s", network, dir)
+	}
+	f, err := os.Open(filepath.Join(dir, historyChecksums))
+	if errors.Is(err, os.ErrNotExist) {
+		log.Warn("No checksum file found, skipping checksum verification", "dir", dir)
+		return files, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	checksums := make(map[string]string)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 2 {
+			checksums[fields[1]] = fields[0]
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	for _, name := range files {
+		want, ok := checksums[name]
+		if !ok {
+			return nil, fmt.Errorf("no checksum for %s", name)
+		}
+		have, err := fileChecksum(filepath.Join(dir, name))
+		if err != nil {
+			return nil, err
+		}
+		if have != want {
+			return nil, fmt.Errorf("checksum mismatch for %s: have %s, want %s", name, have, want)
+		}
+	}
+	return files, nil
+}
+
+// forEachEpochBatch verifies the blocks of an era1 file and passes them to fn
+// in batches. The accumulator root is checked before any block is passed on.
+func forEachEpochBatch(path string, checker *historyChecker, fn func([]*types.Block, []types.Receipts) error) error {
+	e, err := era.Open(path)
+	if err != nil {
+		return err
+	}
+	defer e.Close()
+
+	hashes, tds, err := verifyAccumulator(e, path)
+	if err != nil {
+		return err
+	}
+	var (
+		it       = era.NewIterator(e)
+		blocks   []*types.Block
+		receipts []types.Receipts
+	)
+	for i := 0; it.Next(); i++ {
+		// The blocks must be the ones committed to by the accumulator.
+		if hash := it.Block().Hash(); hash != hashes[i] {
+			return fmt.Errorf("block #%d: hash mismatch: have %x, want %x", it.Block().NumberU64(), hash, hashes[i])
+		}
+		if td := it.TotalDifficulty(); td.Cmp(tds[i]) != 0 {
+			return fmt.Errorf("block #%d: total difficulty mismatch: have %v, want %v", it.Block().NumberU64(), td, tds[i])
+		}
+		if err := checker.check(it.Block(), it.Receipts(), it.TotalDifficulty()); err != nil {
+			return err
+		}
+		blocks = append(blocks, it.Block())
+		receipts = append(receipts, it.Receipts())
+
+		if len(blocks) == importBatchSize {
+			if err := fn(blocks, receipts); err != nil {
+				return err
+			}
+			blocks, receipts = nil, nil
+		}
+	}
+	if err := it.Error(); err != nil {
+		return err
+	}
+	if len(blocks) > 0 {
+		return fn(blocks, receipts)
+	}
+	return nil
+}
+
+// verifyAccumulator recomputes the accumulator root of an era1 file from its
+// headers and total difficulties, checks it against the stored root and the
+// file name, and returns the block hashes and total difficulties.
+func verifyAccumulator(e *era.Era, path string) ([]common.Hash, []*big.Int, error) {
+	var (
+		hashes = make([]common.Hash, 0, e.Count())
+		tds    = make([]*big.Int, 0, e.Count())
+	)
+	for n := e.Start(); n < e.Start()+e.Count(); n++ {
+		header, err := e.GetHeaderByNumber(n)
+		if err != nil {
+			return nil, nil, err
+		}
+		td, err := e.GetTD(n)
+		if err != nil {
+			return nil, nil, err
+		}
+		hashes = append(hashes, header.Hash())
+		tds = append(tds, td)
+	}
+	root, err := era.ComputeAccumulator(hashes, tds)
+	if err != nil {
+		return nil, nil, err
+	}
+	if stored, err := e.Accumulator(); err != nil {
+		return nil, nil, err
+	} else if stored != root {
+		return nil, nil, fmt.Errorf("accumulator mismatch: have %x, want %x", root, stored)
+	}
+	if !strings.Contains(filepath.Base(path), root.Hex()[2:10]) {
+		return nil, nil, fmt.Errorf("file name doesn't match accumulator root %x", root)
+	}
+	return hashes, tds, nil
+}
+
+// historyChecker verifies the consistency of consecutive blocks.
+type historyChecker struct {
+	genesis *types.Header
+	prev    *types.Header
+	prevTd  *big.Int
+}
+
+func newHistoryChecker(genesis *types.Header) *historyChecker {
+	return &historyChecker{genesis: genesis}
+}
+
+// check verifies a block against its receipts, its total difficulty and the
+// previous block.
+func (c *historyChecker) check(block *types.Block, receipts types.Receipts, td *big.Int) error {
+	number := block.NumberU64()
+	switch {
+	case c.prev != nil:
+		if number != c.prev.Number.Uint64()+1 || block.ParentHash() != c.prev.Hash() {
+			return fmt.Errorf("block #%d [%x..] not linked to previous block #%d [%x..]", number, block.Hash().Bytes()[:4], c.prev.Number, c.prev.Hash().Bytes()[:4])
+		}
+		if want := new(big.Int).Add(c.prevTd, block.Difficulty()); td.Cmp(want) != 0 {
+			return fmt.Errorf("block #%d: total difficulty mismatch: have %v, want %v", number, td, want)
+		}
+	case number == 0:
+		if c.genesis != nil && block.Hash() != c.genesis.Hash() {
+			return fmt.Errorf("genesis mismatch: have %x, want %x", block.Hash(), c.genesis.Hash())
+		}
+		if td.Cmp(block.Difficulty()) != 0 {
+			return fmt.Errorf("genesis total difficulty mismatch: have %v, want %v", td, block.Difficulty())
+		}
+	case c.genesis != nil:
+		return fmt.Errorf("history starts at block #%d, want genesis", number)
+	}
+	if hash := types.DeriveSha(block.Transactions(), trie.NewStackTrie(nil)); hash != block.TxHash() {
+		return fmt.Errorf("block #%d: transaction root mismatch: have %x, want %x", number, hash, block.TxHash())
+	}
+	if hash := types.CalcUncleHash(block.Uncles()); hash != block.UncleHash() {
+		return fmt.Errorf("block #%d: uncle root mismatch: have %x, want %x", number, hash, block.UncleHash())
+	}
+	if hash := types.DeriveSha(receipts, trie.NewStackTrie(nil)); hash != block.ReceiptHash() {
+		return fmt.Errorf("block #%d: receipt root mismatch: have %x, want %x", number, hash, block.ReceiptHash())
+	}
+	c.prev, c.prevTd = block.Header(), td
+	return nil
+}
+
+// fileChecksum returns the hex encoded sha256 checksum of a file.
+func fileChecksum(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return common.Bytes2Hex(h.Sum(nil)), nil
+}
+
+func min(a, b uint64) uint64 {
+	if a < b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,194 @@
+// Copyright 2022 The go-confero Authors
+// This file is part of go-confero.
+//
+// go-confero is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-confero is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-confero. If not, see <http://www.gnu.org/licenses/>.
+
+package utils
+
+import (
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/confero-network/go-confero/common"
+	"github.com/confero-network/go-confero/consensus/ethash"
+	"github.com/confero-network/go-confero/core"
+	"github.com/confero-network/go-confero/core/rawdb"
+	"github.com/confero-network/go-confero/core/types"
+	"github.com/confero-network/go-confero/core/vm"
+	"github.com/confero-network/go-confero/crypto"
+	"github.com/confero-network/go-confero/internal/era"
+	"github.com/confero-network/go-confero/params"
+)
+
+func TestHistoryImportAndExport(t *testing.T) {
+	var (
+		key, _  = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+		address = crypto.PubkeyToAddress(key.PublicKey)
+		genesis = &core.Genesis{
+			Config: params.TestChainConfig,
+			Alloc:  core.GenesisAlloc{address: {Balance: big.NewInt(1000000000000000000)}},
+		}
+		signer = types.LatestSigner(genesis.Config)
+		count  = 100
+		step   = uint64(16)
+	)
+	db := rawdb.NewMemoryDatabase()
+	genesis.MustCommit(db)
+	chain, err := core.NewBlockChain(db, nil, genesis.Config, ethash.NewFaker(), vm.Config{}, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer chain.Stop()
+
+	blocks, _ := core.GenerateChain(genesis.Config, chain.Genesis(), ethash.NewFaker(), db, count, func(i int, g *core.BlockGen) {
+		tx, _ := types.SignNewTx(key, signer, &types.LegacyTx{
+			Nonce:    uint64(i),
+			To:       &common.Address{0xaa},
+			Value:    big.NewInt(1000),
+			Gas:      params.TxGas,
+			GasPrice: g.BaseFee(),
+		})
+		g.AddTx(tx)
+	})
+	if _, err := chain.InsertChain(blocks); err != nil {
+		t.Fatal(err)
+	}
+
+	// Export and verify the history.
+	dir := t.TempDir()
+	if err := ExportHistory(chain, dir, "test", 0, uint64(count), step); err != nil {
+		t.Fatalf("error exporting history: %v", err)
+	}
+	files, err := era.ReadDir(dir, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (uint64(count) + step) / step; len(files) != int(want) {
+		t.Fatalf("wrong number of era files: have %d, want %d", len(files), want)
+	}
+	if err := VerifyHistory(dir, "test"); err != nil {
+		t.Fatalf("error verifying history: %v", err)
+	}
+
+	// Import the history into a new chain.
+	db2, err := rawdb.NewDatabaseWithFreezer(rawdb.NewMemoryDatabase(), t.TempDir(), "", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db2.Close()
+	genesis.MustCommit(db2)
+	imported, err := core.NewBlockChain(db2, nil, genesis.Config, ethash.NewFaker(), vm.Config{}, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer imported.Stop()
+
+	if err := ImportHistory(imported, dir, "test"); err != nil {
+		t.Fatalf("error importing history: %v", err)
+	}
+	if head := imported.CurrentFastBlock().NumberU64(); head != uint64(count) {
+		t.Fatalf("wrong head after import: have %d, want %d", head, count)
+	}
+	if frozen, _ := db2.Ancients(); frozen != uint64(count)+1 {
+		t.Fatalf("wrong number of frozen blocks: have %d, want %d", frozen, count+1)
+	}
+	for _, want := range blocks {
+		have := imported.GetBlockByNumber(want.NumberU64())
+		if have == nil || have.Hash() != want.Hash() {
+			t.Fatalf("block %d mismatch", want.NumberU64())
+		}
+		if td, wantTd := imported.GetTd(have.Hash(), have.NumberU64()), chain.GetTd(want.Hash(), want.NumberU64()); td.Cmp(wantTd) != 0 {
+			t.Fatalf("block %d: td mismatch: have %v, want %v", want.NumberU64(), td, wantTd)
+		}
+		if receipts := imported.GetReceiptsByHash(have.Hash()); len(receipts) != 1 || receipts[0].TxHash != want.Transactions()[0].Hash() {
+			t.Fatalf("block %d: receipts mismatch", want.NumberU64())
+		}
+	}
+	// A second import must fail.
+	if err := ImportHistory(imported, dir, "test"); err == nil {
+		t.Fatal("no error importing into non-empty chain")
+	}
+}
+
+func TestHistoryVerifyCorrupted(t *testing.T) {
+	var (
+		db      = rawdb.NewMemoryDatabase()
+		genesis = &core.Genesis{Config: params.TestChainConfig}
+	)
+	genesis.MustCommit(db)
+	chain, _ := core.NewBlockChain(db, nil, genesis.Config, ethash.NewFaker(), vm.Config{}, nil, nil)
+	defer chain.Stop()
+	blocks, _ := core.GenerateChain(genesis.Config, chain.Genesis(), ethash.NewFaker(), db, 10, nil)
+	if _, err := chain.InsertChain(blocks); err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	if err := ExportHistory(chain, dir, "test", 0, 10, 16); err != nil {
+		t.Fatal(err)
+	}
+	files, _ := era.ReadDir(dir, "test")
+	path := filepath.Join(dir, files[0])
+	data, _ := os.ReadFile(path)
+	data[len(data)/2] ^= 0xff
+	os.WriteFile(path, data, 0644)
+
+	if err := VerifyHistory(dir, "test"); err == nil {
+		t.Fatal("no error verifying corrupted history")
+	}
+}
+
+func TestHistoryImportUnverified(t *testing.T) {
+	var (
+		db      = rawdb.NewMemoryDatabase()
+		genesis = &core.Genesis{Config: params.TestChainConfig}
+		count   = importBatchSize + 10
+	)
+	genesis.MustCommit(db)
+	chain, _ := core.NewBlockChain(db, nil, genesis.Config, ethash.NewFaker(), vm.Config{}, nil, nil)
+	defer chain.Stop()
+	blocks, _ := core.GenerateChain(genesis.Config, chain.Genesis(), ethash.NewFaker(), db, count, nil)
+	if _, err := chain.InsertChain(blocks); err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	if err := ExportHistory(chain, dir, "test", 0, uint64(count), era.MaxEra1Size); err != nil {
+		t.Fatal(err)
+	}
+	// Name the file after another accumulator root.
+	files, _ := era.ReadDir(dir, "test")
+	os.Rename(filepath.Join(dir, files[0]), filepath.Join(dir, era.Filename("test", 0, common.Hash{0xde, 0xad, 0xbe, 0xef})))
+	os.Remove(filepath.Join(dir, historyChecksums))
+
+	db2, err := rawdb.NewDatabaseWithFreezer(rawdb.NewMemoryDatabase(), t.TempDir(), "", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db2.Close()
+	genesis.MustCommit(db2)
+	imported, _ := core.NewBlockChain(db2, nil, genesis.Config, ethash.NewFaker(), vm.Config{}, nil, nil)
+	defer imported.Stop()
+
+	if err := ImportHistory(imported, dir, "test"); err == nil {
+		t.Fatal("no error importing history with wrong accumulator root")
+	}
+	// None of the blocks of the epoch may be imported.
+	if header := imported.GetHeaderByNumber(1); header != nil {
+		t.Fatal("block of unverified epoch imported")
+	}
+	if frozen, _ := db2.Ancients(); frozen != 0 {
+		t.Fatalf("blocks of unverified epoch frozen: %d", frozen)
+	}
+}
@@ -34,6 +34,7 @@ import (
 	"github.com/confero-network/go-confero/core/rawdb"
 	"github.com/confero-network/go-confero/core/state"
 	"github.com/confero-network/go-confero/core/state/snapshot"
+	"github.com/confero-network/go-confero/core/stateless"
 	"github.com/confero-network/go-confero/core/types"
 	"github.com/confero-network/go-confero/core/vm"
 	"github.com/confero-network/go-confero/ethdb"
@@ -88,9 +89,11 @@ const (
 	bodyCacheLimit      = 256
 	blockCacheLimit     = 256
 	receiptsCacheLimit  = 32
+	witnessCacheLimit   = 32
 	txLookupCacheLimit  = 1024
 	maxFutureBlocks     = 256
 	maxTimeFutureBlocks = 30
+	stateDiffQueueSize  = 256
 	TriesInMemory       = 128
 
 	// BlockChainVersion ensures that an incompatible database forces a resync from scratch.
@@ -182,9 +185,14 @@ type BlockChain struct {
 	chainHeadFeed event.Feed
 	logsFeed      event.Feed
 	blockProcFeed event.Feed
+	finalityFeed  event.Feed
 	scope         event.SubscriptionScope
 	genesisBlock  *types.Block
 
+	stateDiffFeed  event.Feed
+	stateDiffScope event.SubscriptionScope // State diffs are only tracked if subscribed to
+	stateDiffQueue chan StateDiffEvent     // State diffs waiting for delivery, absorbing short subscriber stalls
+
 	// This mutex synchronizes chain write operations.
 	// Readers don't need to take it, they can just read the database.
 	chainmu *syncx.ClosableMutex
@@ -200,6 +208,7 @@ type BlockChain struct {
 	receiptsCache *lru.Cache     // Cache for the most recent receipts per block
 	blockCache    *lru.Cache     // Cache for the most recent entire blocks
 	txLookupCache *lru.Cache     // Cache for the most recent transaction lookup data.
+	witnessCache  *lru.Cache     // Cache for the execution witnesses of the most recent blocks, if collected
 	futureBlocks  *lru.Cache     // future blocks are blocks added for later processing
 
 	wg            sync.WaitGroup //
@@ -227,6 +236,7 @@ func NewBlockChain(db ethdb.Database, cacheConfig *CacheConfig, chainConfig *par
 	receiptsCache, _ := lru.New(receiptsCacheLimit)
 	blockCache, _ := lru.New(blockCacheLimit)
 	txLookupCache, _ := lru.New(txLookupCacheLimit)
+	witnessCache, _ := lru.New(witnessCacheLimit)
 	futureBlocks, _ := lru.New(maxFutureBlocks)
 
 	bc := &BlockChain{
@@ -239,16 +249,18 @@ func NewBlockChain(db ethdb.Database, cacheConfig *CacheConfig, chainConfig *par
 			Journal:   cacheConfig.TrieCleanJournal,
 			Preimages: cacheConfig.Preimages,
 		}),
-		quit:          make(chan struct{}),
-		chainmu:       syncx.NewClosableMutex(),
-		bodyCache:     bodyCache,
-		bodyRLPCache:  bodyRLPCache,
-		receiptsCache: receiptsCache,
-		blockCache:    blockCache,
-		txLookupCache: txLookupCache,
-		futureBlocks:  futureBlocks,
-		engine:        engine,
-		vmConfig:      vmConfig,
+		quit:           make(chan struct{}),
+		chainmu:        syncx.NewClosableMutex(),
+		bodyCache:      bodyCache,
+		bodyRLPCache:   bodyRLPCache,
+		receiptsCache:  receiptsCache,
+		blockCache:     blockCache,
+		txLookupCache:  txLookupCache,
+		witnessCache:   witnessCache,
+		futureBlocks:   futureBlocks,
+		stateDiffQueue: make(chan StateDiffEvent, stateDiffQueueSize),
+		engine:         engine,
+		vmConfig:       vmConfig,
 	}
 	bc.forker = NewForkChoice(bc, shouldPreserve)
 	bc.validator = NewBlockValidator(chainConfig, bc, engine)
@@ -388,6 +400,10 @@ func NewBlockChain(db ethdb.Database, cacheConfig *CacheConfig, chainConfig *par
 	bc.wg.Add(1)
 	go bc.updateFutureBlocks()
 
+	// Start state diff delivery.
+	bc.wg.Add(1)
+	go bc.deliverStateDiffs()
+
 	// Start tx indexer/unindexer.
 	if txLookupLimit != nil {
 		bc.txLookupLimit = *txLookupLimit
@@ -518,6 +534,7 @@ func (bc *BlockChain) SetFinalized(block *types.Block) {
 		rawdb.WriteFinalizedBlockHash(bc.db, common.Hash{})
 		headFinalizedBlockGauge.Update(0)
 	}
+	bc.finalityFeed.Send(FinalityEvent{Safe: bc.CurrentSafeBlock(), Finalized: block})
 }
 
 // SetSafe sets the safe block.
@@ -528,6 +545,7 @@ func (bc *BlockChain) SetSafe(block *types.Block) {
 	} else {
 		headSafeBlockGauge.Update(0)
 	}
+	bc.finalityFeed.Send(FinalityEvent{Safe: block, Finalized: bc.CurrentFinalizedBlock()})
 }
 
 // setHeadBeyondRoot rewinds the local chain to a new head with the extra condition
@@ -839,6 +857,7 @@ func (bc *BlockChain) Stop() {
 
 	// Unsubscribe all subscriptions registered from blockchain.
 	bc.scope.Close()
+	bc.stateDiffScope.Close()
 
 	// Signal shutdown to all goroutines.
 	close(bc.quit)
@@ -873,7 +892,9 @@ func (bc *BlockChain) Stop() {
 		for _, offset := range []uint64{0, 1, TriesInMemory - 1} {
 			if number := bc.CurrentBlock().NumberU64(); number > offset {
 				recent := bc.GetBlockByNumber(number - offset)
-
+				if recent == nil {
+					continue // History below a sync checkpoint not yet backfilled
+				}
 				log.Info("Writing cached state to disk", "block", recent.Number(), "hash", recent.Hash(), "root", recent.Root())
 				if err := triedb.Commit(recent.Root(), true, nil); err != nil {
 					log.Error("Failed to commit recent state trie", "err", err)
@@ -1274,6 +1295,15 @@ func (bc *BlockChain) writeBlockWithState(block *types.Block, receipts []*types.
 	if err != nil {
 		return err
 	}
+	if diff := state.StateDiff(); diff != nil {
+		// Every imported block must have its diff delivered, so if the queue is
+		// full, the import waits for the subscribers to catch up.
+		select {
+		case bc.stateDiffQueue <- StateDiffEvent{Block: block, Diff: diff}:
+		case <-bc.quit:
+			return errChainStopped
+		}
+	}
 	triedb := bc.stateCache.TrieDB()
 
 	// If we're running an archive node, always flush
@@ -1644,8 +1674,22 @@ func (bc *BlockChain) insertChain(chain types.Blocks, verifySeals, setHead bool)
 			return it.index, err
 		}
 
-		// Enable prefetching to pull in trie node paths while processing transactions
-		statedb.StartPrefetcher("chain")
+		// Enable prefetching to pull in trie node paths while processing transactions,
+		// unless the witness is collected, which needs the nodes resolved by the
+		// execution itself.
+		var witness *stateless.Witness
+		if bc.vmConfig.EnableWitnessCollection {
+			witness, err = stateless.NewWitness(block.Header(), bc)
+			if err != nil {
+				return it.index, err
+			}
+			statedb.EnableWitness(witness)
+		} else {
+			statedb.StartPrefetcher("chain")
+		}
+		if bc.stateDiffScope.Count() > 0 {
+			statedb.EnableStateDiff()
+		}
 		activeState = statedb
 
 		// If we have a followup block, run that against the current state to pre-cache
@@ -1715,6 +1759,9 @@ func (bc *BlockChain) insertChain(chain types.Blocks, verifySeals, setHead bool)
 		if err != nil {
 			return it.index, err
 		}
+		if witness != nil {
+			bc.witnessCache.Add(block.Hash(), witness)
+		}
 		// Update the metrics touched during block commit
 		accountCommitTimer.Update(statedb.AccountCommits)   // Account commits are complete, we can mark them
 		storageCommitTimer.Update(statedb.StorageCommits)   // Storage commits are complete, we can mark them
@@ -2230,6 +2277,20 @@ func (bc *BlockChain) updateFutureBlocks() {
 	}
 }
 
+// deliverStateDiffs sends the queued state diffs to the subscribers, so a slow
+// subscriber only stalls the block import once the queue fills up.
+func (bc *BlockChain) deliverStateDiffs() {
+	defer bc.wg.Done()
+	for {
+		select {
+		case ev := <-bc.stateDiffQueue:
+			bc.stateDiffFeed.Send(ev)
+		case <-bc.quit:
+			return
+		}
+	}
+}
+
 // skipBlock returns 'true', if the block being imported can be skipped over, meaning
 // that the block does not need to be processed but can be considered already fully 'done'.
 func (bc *BlockChain) skipBlock(err error, it *insertIterator) bool {
@@ -2300,6 +2361,12 @@ func (bc *BlockChain) maintainTxIndex(ancients uint64) {
 	indexBlocks := func(tail *uint64, head uint64, done chan struct{}) {
 		defer func() { done <- struct{}{} }()
 
+		// If the chain was initialised from a checkpoint, the transactions are
+		// indexed while backfilling the missing blocks.
+		if rawdb.ReadHistoryTail(bc.db) != nil {
+			return
+		}
+
 		// If the user just upgraded Gcofe to a new version which supports transaction
 		// index pruning, write the new tail and remove anything older.
 		if tail == nil {
@@ -0,0 +1,174 @@
+// Copyright 2022 The go-confero Authors
+// This file is part of the go-confero library.
+//
+// The go-confero library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-confero library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-confero library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/confero-network/go-confero/common"
+	"github.com/confero-network/go-confero/core/rawdb"
+	"github.com/confero-network/go-confero/core/types"
+	"github.com/confero-network/go-confero/log"
+	"github.com/confero-network/go-confero/trie"
+)
+
+var (
+	// errHistoryComplete is returned when inserting history into a chain which
+	// isn't missing any blocks.
+	errHistoryComplete = errors.New("chain history complete")
+
+	// ErrCheckpointMismatch is returned when the history of a chain initialised
+	// from a checkpoint doesn't link to the genesis block with the total
+	// difficulty of the checkpoint. The checkpoint isn't part of this network.
+	ErrCheckpointMismatch = errors.New("sync checkpoint contradicts the genesis block")
+)
+
+// InitFromCheckpoint makes a trusted checkpoint block the head of an empty
+// chain, without any of the blocks between the genesis and the checkpoint. The
+// state of the block must be present already. The missing blocks are filled in
+// by InsertHistory, from the checkpoint backwards.
+func (bc *BlockChain) InitFromCheckpoint(block *types.Block, receipts types.Receipts, td *big.Int) error {
+	if block.NumberU64() == 0 {
+		return errors.New("checkpoint is the genesis block")
+	}
+	if _, err := trie.NewStateTrie(common.Hash{}, block.Root(), bc.stateCache.TrieDB()); err != nil {
+		return err
+	}
+	if !bc.chainmu.TryLock() {
+		return errChainStopped
+	}
+	defer bc.chainmu.Unlock()
+
+	if head := bc.CurrentHeader().Number.Uint64(); head != 0 {
+		return fmt.Errorf("chain not empty, head header is #%d", head)
+	}
+	batch := bc.db.NewBatch()
+	rawdb.WriteTd(batch, block.Hash(), block.NumberU64(), td)
+	rawdb.WriteBlock(batch, block)
+	rawdb.WriteReceipts(batch, block.Hash(), block.NumberU64(), receipts)
+	rawdb.WriteHistoryTail(batch, block.NumberU64())
+	rawdb.WriteTxIndexTail(batch, block.NumberU64())
+	if err := batch.Write(); err != nil {
+		return err
+	}
+	bc.writeHeadBlock(block)
+
+	// Destroy any existing state snapshot and regenerate it in the background.
+	if bc.snaps != nil {
+		bc.snaps.Rebuild(block.Root())
+	}
+	bc.chainHeadFeed.Send(ChainHeadEvent{Block: block})
+	log.Info("Initialised chain from checkpoint", "number", block.Number(), "hash", block.Hash(), "td", td)
+	return nil
+}
+
+// HistoryTail returns the number of the oldest block of a chain initialised
+// from a checkpoint, as long as the blocks below it are missing.
+func (bc *BlockChain) HistoryTail() (uint64, bool) {
+	if tail := rawdb.ReadHistoryTail(bc.db); tail != nil {
+		return *tail, true
+	}
+	return 0, false
+}
+
+// InsertHistory inserts a batch of blocks with their receipts right below the
+// oldest block of a chain initialised from a checkpoint. The blocks must be
+// ordered from the highest to the lowest and link to the oldest block by their
+// hashes. Their bodies and receipts must be checked against the headers by the
+// caller. Once the history reaches the genesis block, the total difficulty of
+// the checkpoint is verified.
+func (bc *BlockChain) InsertHistory(blocks types.Blocks, receipts []types.Receipts) error {
+	bc.wg.Add(1)
+	defer bc.wg.Done()
+
+	tail, ok := bc.HistoryTail()
+	if !ok {
+		return errHistoryComplete
+	}
+	child := bc.GetHeaderByNumber(tail)
+	if child == nil {
+		return fmt.Errorf("history tail #%d unavailable", tail)
+	}
+	td := bc.GetTd(child.Hash(), tail)
+	if td == nil {
+		return fmt.Errorf("total difficulty of history tail #%d unavailable", tail)
+	}
+	batch := bc.db.NewBatch()
+	for i, block := range blocks {
+		if child.Number.Uint64() == 1 {
+			return errHistoryComplete
+		}
+		if block.NumberU64() != child.Number.Uint64()-1 || block.Hash() != child.ParentHash {
+			return fmt.Errorf("non contiguous history insert: item %d is #%d [%x..], want #%d [%x..]", i, block.NumberU64(),
+				block.Hash().Bytes()[:4], child.Number.Uint64()-1, child.ParentHash.Bytes()[:4])
+		}
+		td = new(big.Int).Sub(td, child.Difficulty)
+
+		rawdb.WriteTd(batch, block.Hash(), block.NumberU64(), td)
+		rawdb.WriteBlock(batch, block)
+		rawdb.WriteReceipts(batch, block.Hash(), block.NumberU64(), receipts[i])
+		rawdb.WriteCanonicalHash(batch, block.Hash(), block.NumberU64())
+		rawdb.WriteTxLookupEntriesByBlock(batch, block)
+		child = block.Header()
+	}
+	number := child.Number.Uint64()
+	if number == 1 {
+		// The history is complete, the chain must link to the genesis block with
+		// the total difficulty of the checkpoint.
+		genesis := bc.genesisBlock
+		if child.ParentHash != genesis.Hash() {
+			return fmt.Errorf("%w: history links to [%x..], want [%x..]", ErrCheckpointMismatch, child.ParentHash.Bytes()[:4], genesis.Hash().Bytes()[:4])
+		}
+		if want := new(big.Int).Add(bc.GetTd(genesis.Hash(), 0), child.Difficulty); td.Cmp(want) != 0 {
+			return fmt.Errorf("%w: total difficulty off by %v", ErrCheckpointMismatch, new(big.Int).Sub(td, want))
+		}
+		rawdb.DeleteHistoryTail(batch)
+		rawdb.WriteTxIndexTail(batch, 0)
+	} else {
+		rawdb.WriteHistoryTail(batch, number)
+		rawdb.WriteTxIndexTail(batch, number)
+	}
+	if err := batch.Write(); err != nil {
+		return err
+	}
+	if number == 1 {
+		log.Info("Chain history complete", "blocks", tail-1)
+	}
+	return nil
+}
+
+// DiscardCheckpoint resets a chain initialised from a checkpoint to the genesis
+// block, dropping all blocks retrieved since. It's used once the checkpoint
+// turned out not to be part of the chain of the genesis block.
+func (bc *BlockChain) DiscardCheckpoint() error {
+	if _, ok := bc.HistoryTail(); !ok {
+		return errHistoryComplete
+	}
+	if err := bc.Reset(); err != nil {
+		return err
+	}
+	batch := bc.db.NewBatch()
+	rawdb.DeleteHistoryTail(batch)
+	rawdb.WriteTxIndexTail(batch, 0)
+	if err := batch.Write(); err != nil {
+		return err
+	}
+	log.Warn("Discarded chain initialised from checkpoint")
+	return nil
+}
@@ -0,0 +1,191 @@
+// Copyright 2022 The go-confero Authors
+// This file is part of the go-confero library.
+//
+// The go-confero library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-confero library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-confero library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/confero-network/go-confero/common"
+	"github.com/confero-network/go-confero/consensus/ethash"
+	"github.com/confero-network/go-confero/core/rawdb"
+	"github.com/confero-network/go-confero/core/types"
+	"github.com/confero-network/go-confero/core/vm"
+	"github.com/confero-network/go-confero/crypto"
+	"github.com/confero-network/go-confero/params"
+)
+
+// newCheckpointTestChain creates an empty chain which has the state of the last
+// one of n generated blocks, along with the blocks, their receipts and the total
+// difficulty of the last block.
+func newCheckpointTestChain(t *testing.T, n int) (*BlockChain, []*types.Block, []types.Receipts, *big.Int) {
+	var (
+		key, _  = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+		address = crypto.PubkeyToAddress(key.PublicKey)
+		db      = rawdb.NewMemoryDatabase()
+		gspec   = &Genesis{
+			Config: params.TestChainConfig,
+			Alloc:  GenesisAlloc{address: {Balance: big.NewInt(1000000000000000)}},
+		}
+		genesis = gspec.MustCommit(db)
+		signer  = types.LatestSigner(gspec.Config)
+	)
+	// The generated states are committed to the database, the blocks are not.
+	blocks, receipts := GenerateChain(gspec.Config, genesis, ethash.NewFaker(), db, n, func(i int, b *BlockGen) {
+		tx, err := types.SignTx(types.NewTransaction(b.TxNonce(address), common.Address{0x01}, big.NewInt(1000), params.TxGas, b.header.BaseFee, nil), signer, key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		b.AddTx(tx)
+	})
+	chain, err := NewBlockChain(db, nil, gspec.Config, ethash.NewFaker(), vm.Config{}, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to create chain: %v", err)
+	}
+	td := new(big.Int).Set(genesis.Difficulty())
+	for _, block := range blocks {
+		td.Add(td, block.Difficulty())
+	}
+	return chain, blocks, receipts, td
+}
+
+// reverseHistory returns the given blocks and receipts from the highest to the
+// lowest.
+func reverseHistory(blocks []*types.Block, receipts []types.Receipts) (types.Blocks, []types.Receipts) {
+	var (
+		rblocks   = make(types.Blocks, len(blocks))
+		rreceipts = make([]types.Receipts, len(receipts))
+	)
+	for i := range blocks {
+		rblocks[len(blocks)-1-i] = blocks[i]
+		rreceipts[len(blocks)-1-i] = receipts[i]
+	}
+	return rblocks, rreceipts
+}
+
+// Tests that a chain initialised from a checkpoint has the checkpoint as its
+// head, and that the history below it can be backfilled down to the genesis.
+func TestInitFromCheckpoint(t *testing.T) {
+	chain, blocks, receipts, td := newCheckpointTestChain(t, 32)
+	defer chain.Stop()
+
+	head := blocks[len(blocks)-1]
+	if err := chain.InitFromCheckpoint(head, receipts[len(receipts)-1], td); err != nil {
+		t.Fatalf("failed to initialise chain: %v", err)
+	}
+	if chain.CurrentBlock().Hash() != head.Hash() {
+		t.Fatalf("head mismatch: have #%d, want #%d", chain.CurrentBlock().NumberU64(), head.NumberU64())
+	}
+	if err := chain.InitFromCheckpoint(head, receipts[len(receipts)-1], td); err == nil {
+		t.Fatal("initialised non-empty chain")
+	}
+	if tail, ok := chain.HistoryTail(); !ok || tail != head.NumberU64() {
+		t.Fatalf("history tail mismatch: have #%d (%v), want #%d", tail, ok, head.NumberU64())
+	}
+	if chain.GetBlockByNumber(1) != nil {
+		t.Fatal("history available before backfill")
+	}
+	// Backfill the history in batches, from the checkpoint backwards.
+	history, historyReceipts := reverseHistory(blocks[:len(blocks)-1], receipts[:len(receipts)-1])
+	for i := 0; i < len(history); i += 10 {
+		end := i + 10
+		if end > len(history) {
+			end = len(history)
+		}
+		if err := chain.InsertHistory(history[i:end], historyReceipts[i:end]); err != nil {
+			t.Fatalf("failed to insert history batch %d: %v", i/10, err)
+		}
+	}
+	if tail, ok := chain.HistoryTail(); ok {
+		t.Fatalf("history incomplete, tail #%d", tail)
+	}
+	for _, block := range blocks {
+		if have := chain.GetBlockByNumber(block.NumberU64()); have == nil || have.Hash() != block.Hash() {
+			t.Fatalf("block #%d missing after backfill", block.NumberU64())
+		}
+		if have := chain.GetReceiptsByHash(block.Hash()); len(have) != len(block.Transactions()) {
+			t.Fatalf("receipts of block #%d missing after backfill", block.NumberU64())
+		}
+		for _, tx := range block.Transactions() {
+			if lookup := rawdb.ReadTxLookupEntry(chain.db, tx.Hash()); lookup == nil || *lookup != block.NumberU64() {
+				t.Fatalf("tx lookup of block #%d missing after backfill", block.NumberU64())
+			}
+		}
+	}
+	if err := chain.InsertHistory(history[:1], historyReceipts[:1]); err != errHistoryComplete {
+		t.Fatalf("history insert into complete chain: have %v, want %v", err, errHistoryComplete)
+	}
+}
+
+// Tests that a chain initialised from the first block has no history to fetch,
+// it only needs to be linked to the genesis block.
+func TestInitFromFirstBlock(t *testing.T) {
+	chain, blocks, receipts, td := newCheckpointTestChain(t, 1)
+	defer chain.Stop()
+
+	if err := chain.InitFromCheckpoint(blocks[0], receipts[0], td); err != nil {
+		t.Fatalf("failed to initialise chain: %v", err)
+	}
+	if err := chain.InsertHistory(nil, nil); err != nil {
+		t.Fatalf("failed to link first block to the genesis: %v", err)
+	}
+	if tail, ok := chain.HistoryTail(); ok {
+		t.Fatalf("history incomplete, tail #%d", tail)
+	}
+}
+
+// Tests that history which doesn't link to the oldest block, or which reveals a
+// wrong checkpoint total difficulty, is rejected, and that the chain of such a
+// checkpoint can be discarded.
+func TestInsertHistoryInvalid(t *testing.T) {
+	chain, blocks, receipts, td := newCheckpointTestChain(t, 8)
+	defer chain.Stop()
+
+	head := blocks[len(blocks)-1]
+	if err := chain.InitFromCheckpoint(head, receipts[len(receipts)-1], new(big.Int).Add(td, common.Big1)); err != nil {
+		t.Fatalf("failed to initialise chain: %v", err)
+	}
+	history, historyReceipts := reverseHistory(blocks[:len(blocks)-1], receipts[:len(receipts)-1])
+
+	// A gap below the oldest block must be rejected.
+	if err := chain.InsertHistory(history[1:], historyReceipts[1:]); err == nil {
+		t.Fatal("non contiguous history inserted")
+	}
+	if tail, _ := chain.HistoryTail(); tail != head.NumberU64() {
+		t.Fatalf("history tail moved after failed insert: have #%d, want #%d", tail, head.NumberU64())
+	}
+	// Linking to the genesis reveals the total difficulty of the checkpoint was wrong.
+	if err := chain.InsertHistory(history, historyReceipts); !errors.Is(err, ErrCheckpointMismatch) {
+		t.Fatalf("history with wrong checkpoint total difficulty: have %v, want %v", err, ErrCheckpointMismatch)
+	}
+	if _, ok := chain.HistoryTail(); !ok {
+		t.Fatal("history marked complete after failed insert")
+	}
+	if err := chain.DiscardCheckpoint(); err != nil {
+		t.Fatalf("failed to discard checkpoint: %v", err)
+	}
+	if head := chain.CurrentBlock(); head.NumberU64() != 0 {
+		t.Fatalf("head not reset after discarding checkpoint: #%d", head.NumberU64())
+	}
+	if chain.GetBlockByHash(blocks[len(blocks)-1].Hash()) != nil {
+		t.Fatal("checkpoint block kept after discarding checkpoint")
+	}
+	if _, ok := chain.HistoryTail(); ok {
+		t.Fatal("history tail kept after discarding checkpoint")
+	}
+}
@@ -24,6 +24,7 @@ import (
 	"github.com/confero-network/go-confero/core/rawdb"
 	"github.com/confero-network/go-confero/core/state"
 	"github.com/confero-network/go-confero/core/state/snapshot"
+	"github.com/confero-network/go-confero/core/stateless"
 	"github.com/confero-network/go-confero/core/types"
 	"github.com/confero-network/go-confero/core/vm"
 	"github.com/confero-network/go-confero/event"
@@ -225,6 +226,16 @@ func (bc *BlockChain) GetReceiptsByHash(hash common.Hash) types.Receipts {
 	return receipts
 }
 
+// GetWitness retrieves the execution witness collected while importing the
+// block with the given hash. It returns nil if witness collection is disabled or
+// the block is not among the most recently imported ones.
+func (bc *BlockChain) GetWitness(hash common.Hash) *stateless.Witness {
+	if witness, ok := bc.witnessCache.Get(hash); ok {
+		return witness.(*stateless.Witness)
+	}
+	return nil
+}
+
 // GetUnclesInChain retrieves all the uncles from a given block backwards until
 // a specific distance is reached.
 func (bc *BlockChain) GetUnclesInChain(block *types.Block, length int) []*types.Header {
@@ -401,6 +412,17 @@ func (bc *BlockChain) SubscribeLogsEvent(ch chan<- []*types.Log) event.Subscript
 	return bc.scope.Track(bc.logsFeed.Subscribe(ch))
 }
 
+// SubscribeFinalityEvent registers a subscription of FinalityEvent.
+func (bc *BlockChain) SubscribeFinalityEvent(ch chan<- FinalityEvent) event.Subscription {
+	return bc.scope.Track(bc.finalityFeed.Subscribe(ch))
+}
+
+// SubscribeStateDiffEvent registers a subscription of StateDiffEvent. State
+// diffs are only collected for blocks imported while there are subscribers.
+func (bc *BlockChain) SubscribeStateDiffEvent(ch chan<- StateDiffEvent) event.Subscription {
+	return bc.stateDiffScope.Track(bc.stateDiffFeed.Subscribe(ch))
+}
+
 // SubscribeBlockProcessingEvent registers a subscription of bool where true means
 // block processing has started while false means it has stopped.
 func (bc *BlockChain) SubscribeBlockProcessingEvent(ch chan<- bool) event.Subscription {
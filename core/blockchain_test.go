@@ -1515,6 +1515,69 @@ func TestEIP155Transition(t *testing.T) {
 	}
 }
 
+// Tests that the state diffs of the imported blocks are delivered to the state
+// diff subscribers, queueing them while the subscribers are busy.
+func TestStateDiffEvents(t *testing.T) {
+	var (
+		db      = rawdb.NewMemoryDatabase()
+		key, _  = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+		address = crypto.PubkeyToAddress(key.PublicKey)
+		funds   = big.NewInt(1000000000)
+		theAddr = common.Address{1}
+		gspec   = &Genesis{
+			Config: &params.ChainConfig{
+				ChainID:        big.NewInt(1),
+				HomesteadBlock: new(big.Int),
+				EIP155Block:    new(big.Int),
+				EIP150Block:    new(big.Int),
+				EIP158Block:    new(big.Int),
+			},
+			Alloc: GenesisAlloc{address: {Balance: funds}},
+		}
+		genesis = gspec.MustCommit(db)
+	)
+	blockchain, _ := NewBlockChain(db, nil, gspec.Config, ethash.NewFaker(), vm.Config{}, nil, nil)
+	defer blockchain.Stop()
+
+	blocks, _ := GenerateChain(gspec.Config, genesis, ethash.NewFaker(), db, 3, func(i int, block *BlockGen) {
+		block.SetCoinbase(common.Address{0xc0})
+		tx, err := types.SignTx(types.NewTransaction(block.TxNonce(address), theAddr, big.NewInt(1000), 21000, new(big.Int), nil), types.LatestSigner(gspec.Config), key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		block.AddTx(tx)
+	})
+	// The subscriber doesn't read the diffs until the import is done, which the
+	// queue must absorb without stalling the import.
+	diffs := make(chan StateDiffEvent)
+	sub := blockchain.SubscribeStateDiffEvent(diffs)
+	defer sub.Unsubscribe()
+
+	if _, err := blockchain.InsertChain(blocks); err != nil {
+		t.Fatal(err)
+	}
+	for i, block := range blocks {
+		ev := <-diffs
+		if ev.Block.Hash() != block.Hash() {
+			t.Fatalf("block %d: hash mismatch: have %x, want %x", i, ev.Block.Hash(), block.Hash())
+		}
+		if ev.Diff.Root != block.Root() {
+			t.Errorf("block %d: root mismatch: have %x, want %x", i, ev.Diff.Root, block.Root())
+		}
+		if len(ev.Diff.Accounts) != 3 {
+			t.Errorf("block %d: changed account count mismatch: have %d, want 3", i, len(ev.Diff.Accounts))
+		}
+		sender := ev.Diff.Accounts[address]
+		if sender == nil || uint64(sender.Before.Nonce) != uint64(i) || uint64(sender.After.Nonce) != uint64(i+1) {
+			t.Errorf("block %d: sender nonce change missing", i)
+		}
+		recipient := ev.Diff.Accounts[theAddr]
+		if recipient == nil || recipient.After.Balance.ToInt().Int64() != int64(1000*(i+1)) {
+			t.Errorf("block %d: recipient balance change missing", i)
+		}
+	}
+}
+
 func TestEIP161AccountRemoval(t *testing.T) {
 	// Configure and generate a sample block chain
 	var (
@@ -18,6 +18,7 @@ package core
 
 import (
 	"github.com/confero-network/go-confero/common"
+	"github.com/confero-network/go-confero/core/state"
 	"github.com/confero-network/go-confero/core/types"
 )
 
@@ -41,3 +42,15 @@ type ChainSideEvent struct {
 }
 
 type ChainHeadEvent struct{ Block *types.Block }
+
+// FinalityEvent is posted when the safe or finalized block of the chain is set.
+type FinalityEvent struct {
+	Safe      *types.Block // nil if unknown
+	Finalized *types.Block // nil if unknown
+}
+
+// StateDiffEvent is posted when the state of an imported block is committed.
+type StateDiffEvent struct {
+	Block *types.Block
+	Diff  *state.StateDiff
+}
@@ -0,0 +1,127 @@
+// Copyright 2022 The go-confero Authors
+// This file is part of the go-confero library.
+//
+// The go-confero library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-confero library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-confero library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/confero-network/go-confero/common"
+	"github.com/confero-network/go-confero/core/rawdb"
+	"github.com/confero-network/go-confero/core/types"
+	"github.com/confero-network/go-confero/ethdb"
+)
+
+const (
+	// logIndexThrottling is the time to wait between processing two consecutive
+	// index sections. It's useful during chain upgrades to prevent disk overload.
+	logIndexThrottling = 100 * time.Millisecond
+)
+
+// LogIndexer implements a core.ChainIndexer, building up an exact index of the
+// blocks and log positions containing each log address and topic.
+//
+// Entries are keyed by block number and record the block hash, so entries left
+// behind by blocks which were reorged out of the canonical chain are detected
+// when reading the index, and overwritten when the section is indexed again.
+type LogIndexer struct {
+	db    ethdb.Database // database instance to write index data and metadata into
+	batch ethdb.Batch    // batch collecting the index entries of the current section
+}
+
+// logIndexValue identifies an indexed address or topic.
+type logIndexValue struct {
+	kind  byte
+	value string
+}
+
+// NewLogIndexer returns a chain indexer that generates the log index of the
+// canonical chain for fast logs filtering.
+func NewLogIndexer(db ethdb.Database, size, confirms uint64) *ChainIndexer {
+	backend := &LogIndexer{db: db}
+	table := rawdb.NewTable(db, string(rawdb.LogIndexPrefix))
+
+	return NewChainIndexer(db, table, backend, size, confirms, logIndexThrottling, "logindex")
+}
+
+// Reset implements core.ChainIndexerBackend, starting a new log index section.
+func (l *LogIndexer) Reset(ctx context.Context, section uint64, lastSectionHead common.Hash) error {
+	l.batch = l.db.NewBatch()
+	return nil
+}
+
+// Process implements core.ChainIndexerBackend, adding the logs of a new header
+// into the index.
+func (l *LogIndexer) Process(ctx context.Context, header *types.Header) error {
+	var (
+		hash    = header.Hash()
+		number  = header.Number.Uint64()
+		entries = make(map[logIndexValue]*rawdb.LogIndexEntry)
+		index   uint
+	)
+	if header.Bloom == (types.Bloom{}) {
+		return nil
+	}
+	receipts := rawdb.ReadRawReceipts(l.db, hash, number)
+	if receipts == nil {
+		return fmt.Errorf("missing receipts of block %d [%x]", number, hash)
+	}
+	add := func(kind byte, value []byte) {
+		key := logIndexValue{kind, string(value)}
+		entry := entries[key]
+		if entry == nil {
+			entry = &rawdb.LogIndexEntry{Number: number, Hash: hash}
+			entries[key] = entry
+		}
+		// A log may contain the same topic at several positions, which are
+		// indexed separately, but it is listed only once per position.
+		if n := len(entry.Indices); n == 0 || entry.Indices[n-1] != index {
+			entry.Indices = append(entry.Indices, index)
+		}
+	}
+	for _, receipt := range receipts {
+		for _, log := range receipt.Logs {
+			add(rawdb.LogIndexAddress, log.Address.Bytes())
+			for i, topic := range log.Topics {
+				add(rawdb.LogIndexTopic(i), topic.Bytes())
+			}
+			index++
+		}
+	}
+	for key, entry := range entries {
+		rawdb.WriteLogIndexEntry(l.batch, key.kind, []byte(key.value), entry)
+	}
+	if l.batch.ValueSize() >= ethdb.IdealBatchSize {
+		if err := l.batch.Write(); err != nil {
+			return err
+		}
+		l.batch.Reset()
+	}
+	return nil
+}
+
+// Commit implements core.ChainIndexerBackend, writing out the remaining entries
+// of the section into the database.
+func (l *LogIndexer) Commit() error {
+	return l.batch.Write()
+}
+
+// Prune returns an empty error since we don't support pruning here.
+func (l *LogIndexer) Prune(threshold uint64) error {
+	return nil
+}
@@ -0,0 +1,110 @@
+// Copyright 2022 The go-confero Authors
+// This file is part of the go-confero library.
+//
+// The go-confero library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-confero library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-confero library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"context"
+	"math/big"
+	"reflect"
+	"testing"
+
+	"github.com/confero-network/go-confero/common"
+	"github.com/confero-network/go-confero/core/rawdb"
+	"github.com/confero-network/go-confero/core/types"
+)
+
+func TestLogIndexer(t *testing.T) {
+	var (
+		db      = rawdb.NewMemoryDatabase()
+		addr1   = common.HexToAddress("0x1")
+		addr2   = common.HexToAddress("0x2")
+		topic1  = common.HexToHash("0x11")
+		topic2  = common.HexToHash("0x22")
+		indexer = &LogIndexer{db: db}
+	)
+	// Block 1 has three logs in two receipts, block 2 none and block 3 one.
+	blocks := [][]*types.Receipt{
+		{
+			{Logs: []*types.Log{{Address: addr1, Topics: []common.Hash{topic1, topic1}}}},
+			{Logs: []*types.Log{{Address: addr2, Topics: []common.Hash{topic2}}, {Address: addr1}}},
+		},
+		nil,
+		{
+			{Logs: []*types.Log{{Address: addr2, Topics: []common.Hash{topic2, topic1}}}},
+		},
+	}
+	if err := indexer.Reset(context.Background(), 0, common.Hash{}); err != nil {
+		t.Fatal(err)
+	}
+	var hashes []common.Hash
+	for i, receipts := range blocks {
+		header := &types.Header{Number: big.NewInt(int64(i + 1))}
+		for _, receipt := range receipts {
+			receipt.Bloom = types.CreateBloom(types.Receipts{receipt})
+			header.Bloom.Add(receipt.Logs[0].Address.Bytes())
+		}
+		rawdb.WriteReceipts(db, header.Hash(), header.Number.Uint64(), receipts)
+		if err := indexer.Process(context.Background(), header); err != nil {
+			t.Fatal(err)
+		}
+		hashes = append(hashes, header.Hash())
+	}
+	if err := indexer.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		kind     byte
+		value    []byte
+		from, to uint64
+		want     []*rawdb.LogIndexEntry
+	}{
+		{rawdb.LogIndexAddress, addr1.Bytes(), 0, 10, []*rawdb.LogIndexEntry{
+			{Number: 1, Hash: hashes[0], Indices: []uint{0, 2}},
+		}},
+		{rawdb.LogIndexAddress, addr2.Bytes(), 0, 10, []*rawdb.LogIndexEntry{
+			{Number: 1, Hash: hashes[0], Indices: []uint{1}},
+			{Number: 3, Hash: hashes[2], Indices: []uint{0}},
+		}},
+		{rawdb.LogIndexAddress, addr2.Bytes(), 2, 3, []*rawdb.LogIndexEntry{
+			{Number: 3, Hash: hashes[2], Indices: []uint{0}},
+		}},
+		{rawdb.LogIndexAddress, addr2.Bytes(), 0, 2, []*rawdb.LogIndexEntry{
+			{Number: 1, Hash: hashes[0], Indices: []uint{1}},
+		}},
+		{rawdb.LogIndexTopic(0), topic1.Bytes(), 0, 10, []*rawdb.LogIndexEntry{
+			{Number: 1, Hash: hashes[0], Indices: []uint{0}},
+		}},
+		{rawdb.LogIndexTopic(1), topic1.Bytes(), 0, 10, []*rawdb.LogIndexEntry{
+			{Number: 1, Hash: hashes[0], Indices: []uint{0}},
+			{Number: 3, Hash: hashes[2], Indices: []uint{0}},
+		}},
+		{rawdb.LogIndexTopic(0), topic2.Bytes(), 0, 10, []*rawdb.LogIndexEntry{
+			{Number: 1, Hash: hashes[0], Indices: []uint{1}},
+			{Number: 3, Hash: hashes[2], Indices: []uint{0}},
+		}},
+		{rawdb.LogIndexTopic(2), topic1.Bytes(), 0, 10, nil},
+	}
+	for i, test := range tests {
+		have := rawdb.ReadLogIndexEntries(db, test.kind, test.value, test.from, test.to)
+		if !reflect.DeepEqual(have, test.want) {
+			t.Errorf("test %d: entries mismatch", i)
+			for _, entry := range have {
+				t.Logf("have %+v", entry)
+			}
+		}
+	}
+}
@@ -278,6 +278,33 @@ func WriteTxIndexTail(db ethdb.KeyValueWriter, number uint64) {
 	}
 }
 
+// ReadHistoryTail retrieves the number of the oldest block of a chain synced
+// from a checkpoint, whose older blocks are still missing. If the entry is
+// non-existent in the database, the chain history is complete.
+func ReadHistoryTail(db ethdb.KeyValueReader) *uint64 {
+	data, _ := db.Get(historyTailKey)
+	if len(data) != 8 {
+		return nil
+	}
+	number := binary.BigEndian.Uint64(data)
+	return &number
+}
+
+// WriteHistoryTail stores the number of the oldest block of a chain synced
+// from a checkpoint into the database.
+func WriteHistoryTail(db ethdb.KeyValueWriter, number uint64) {
+	if err := db.Put(historyTailKey, encodeBlockNumber(number)); err != nil {
+		log.Crit("Failed to store the history tail", "err", err)
+	}
+}
+
+// DeleteHistoryTail removes the history tail once the chain history is complete.
+func DeleteHistoryTail(db ethdb.KeyValueWriter) {
+	if err := db.Delete(historyTailKey); err != nil {
+		log.Crit("Failed to delete the history tail", "err", err)
+	}
+}
+
 // ReadFastTxLookupLimit retrieves the tx lookup limit used in fast sync.
 func ReadFastTxLookupLimit(db ethdb.KeyValueReader) *uint64 {
 	data, _ := db.Get(fastTxLookupLimitKey)
@@ -18,6 +18,7 @@ package rawdb
 
 import (
 	"bytes"
+	"encoding/binary"
 	"math/big"
 
 	"github.com/confero-network/go-confero/common"
@@ -175,3 +176,57 @@ func DeleteBloombits(db ethdb.Database, bit uint, from uint64, to uint64) {
 		log.Crit("Failed to delete bloom bits", "err", it.Error())
 	}
 }
+
+// LogIndexAddress is the log index kind of log addresses.
+const LogIndexAddress byte = 0
+
+// LogIndexTopic returns the log index kind of the topics at the given position.
+func LogIndexTopic(position int) byte {
+	return byte(1 + position)
+}
+
+// LogIndexEntry lists the logs of a block containing an indexed address or topic.
+type LogIndexEntry struct {
+	Number  uint64      `rlp:"-"`
+	Hash    common.Hash // hash of the indexed block, used to detect reorged entries
+	Indices []uint      // positions of the logs within the block
+}
+
+// ReadLogIndexEntries retrieves the log index entries of an address or topic in
+// the given block range.
+func ReadLogIndexEntries(db ethdb.Iteratee, kind byte, value []byte, from uint64, to uint64) []*LogIndexEntry {
+	prefix := logIndexKeyPrefix(kind, value)
+	it := db.NewIterator(prefix, encodeBlockNumber(from))
+	defer it.Release()
+
+	var entries []*LogIndexEntry
+	for it.Next() {
+		key := it.Key()
+		if len(key) != len(prefix)+8 {
+			continue
+		}
+		number := binary.BigEndian.Uint64(key[len(prefix):])
+		if number > to {
+			break
+		}
+		entry := new(LogIndexEntry)
+		if err := rlp.DecodeBytes(it.Value(), entry); err != nil {
+			log.Error("Invalid log index entry RLP", "number", number, "err", err)
+			continue
+		}
+		entry.Number = number
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
+// WriteLogIndexEntry stores the log index entry of an address or topic in a block.
+func WriteLogIndexEntry(db ethdb.KeyValueWriter, kind byte, value []byte, entry *LogIndexEntry) {
+	data, err := rlp.EncodeToBytes(entry)
+	if err != nil {
+		log.Crit("Failed to encode log index entry", "err", err)
+	}
+	if err := db.Put(logIndexKey(kind, value, entry.Number), data); err != nil {
+		log.Crit("Failed to store log index entry", "err", err)
+	}
+}
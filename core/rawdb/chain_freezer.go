@@ -39,16 +39,16 @@ const (
 	freezerBatchLimit = 30000
 )
 
-// chainFreezer is a wrapper of freezer with additional chain freezing feature.
-// The background thread will keep moving ancient chain segments from key-value
-// database to flat files for saving space on live database.
+// chainFreezer is a wrapper of an ancient store, usually a freezer, with additional
+// chain freezing feature. The background thread will keep moving ancient chain
+// segments from key-value database to flat files for saving space on live database.
 type chainFreezer struct {
 	// WARNING: The `threshold` field is accessed atomically. On 32 bit platforms, only
 	// 64-bit aligned fields can be atomic. The struct is guaranteed to be so aligned,
 	// so take advantage of that (https://golang.org/pkg/sync/atomic/#pkg-note-BUG).
 	threshold uint64 // Number of recent blocks not to freeze (params.FullImmutabilityThreshold apart from tests)
 
-	*Freezer
+	ethdb.AncientStore
 	quit    chan struct{}
 	wg      sync.WaitGroup
 	trigger chan chan struct{} // Manual blocking freeze trigger, test determinism
@@ -60,17 +60,22 @@ func newChainFreezer(datadir string, namespace string, readonly bool, maxTableSi
 	if err != nil {
 		return nil, err
 	}
+	return wrapChainFreezer(freezer), nil
+}
+
+// wrapChainFreezer adds the chain freezing feature to an ancient store.
+func wrapChainFreezer(store ethdb.AncientStore) *chainFreezer {
 	return &chainFreezer{
-		Freezer:   freezer,
-		threshold: params.FullImmutabilityThreshold,
-		quit:      make(chan struct{}),
-		trigger:   make(chan chan struct{}),
-	}, nil
+		AncientStore: store,
+		threshold:    params.FullImmutabilityThreshold,
+		quit:         make(chan struct{}),
+		trigger:      make(chan chan struct{}),
+	}
 }
 
 // Close closes the chain freezer instance and terminates the background thread.
 func (f *chainFreezer) Close() error {
-	err := f.Freezer.Close()
+	err := f.AncientStore.Close()
 	select {
 	case <-f.quit:
 	default:
@@ -123,7 +128,7 @@ func (f *chainFreezer) freeze(db ethdb.KeyValueStore) {
 		}
 		number := ReadHeaderNumber(nfdb, hash)
 		threshold := atomic.LoadUint64(&f.threshold)
-		frozen := atomic.LoadUint64(&f.frozen)
+		frozen, _ := f.Ancients()
 		switch {
 		case number == nil:
 			log.Error("Current full block number unavailable", "hash", hash)
@@ -140,6 +145,11 @@ func (f *chainFreezer) freeze(db ethdb.KeyValueStore) {
 			backoff = true
 			continue
 		}
+		if tail := ReadHistoryTail(nfdb); tail != nil {
+			log.Debug("Chain history incomplete, waiting for backfill", "tail", *tail)
+			backoff = true
+			continue
+		}
 		head := ReadHeader(nfdb, hash, *number)
 		if head == nil {
 			log.Error("Current full block unavailable", "number", *number, "hash", hash)
@@ -184,7 +194,7 @@ func (f *chainFreezer) freeze(db ethdb.KeyValueStore) {
 
 		// Wipe out side chains also and track dangling side chains
 		var dangling []common.Hash
-		frozen = atomic.LoadUint64(&f.frozen) // Needs reload after during freezeRange
+		frozen, _ = f.Ancients() // Needs reload after during freezeRange
 		for number := first; number < frozen; number++ {
 			// Always keep the genesis block in active database
 			if number != 0 {
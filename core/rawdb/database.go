@@ -65,7 +65,10 @@ func (frdb *freezerdb) Close() error {
 // a freeze cycle completes, without having to sleep for a minute to trigger the
 // automatic background run.
 func (frdb *freezerdb) Freeze(threshold uint64) error {
-	if frdb.AncientStore.(*chainFreezer).readonly {
+	if _, ok := frdb.AncientStore.(*chainFreezer); !ok {
+		return errNotSupported
+	}
+	if frdb.AncientStore.(*chainFreezer).AncientReadOnly() {
 		return errReadOnly
 	}
 	// Set the freezer threshold to a temporary value
@@ -158,6 +161,11 @@ func (db *nofreezedb) MigrateTable(kind string, convert convertLegacyFn) error {
 	return errNotSupported
 }
 
+// AncientReadOnly returns true as we don't have a backing chain freezer.
+func (db *nofreezedb) AncientReadOnly() bool {
+	return true
+}
+
 // AncientDatadir returns an error as we don't have a backing chain freezer.
 func (db *nofreezedb) AncientDatadir() (string, error) {
 	return "", errNotSupported
@@ -202,6 +210,38 @@ func NewDatabaseWithFreezer(db ethdb.KeyValueStore, ancient string, namespace st
 	if err != nil {
 		return nil, err
 	}
+	return newFreezerDatabase(db, ancient, frdb)
+}
+
+// NewDatabaseWithLayeredFreezer creates a high level database on top of a given
+// key-value data store with a freezer moving immutable chain segments into cold
+// storage, layered on top of a read-only ancient store holding the oldest chain
+// segment (e.g. one shared with other processes). Only the chain segment past
+// the end of the read-only store is frozen into the local freezer, which is
+// opened in the passed root ancient directory. The read-only store is closed
+// along with the database, but not if the database can't be created.
+func NewDatabaseWithLayeredFreezer(db ethdb.KeyValueStore, lower ethdb.AncientStore, ancient string, namespace string, readonly bool) (ethdb.Database, error) {
+	freezer, err := NewFreezer(resolveChainFreezerDir(ancient), namespace, readonly, freezerTableSize, chainFreezerNoSnappy)
+	if err != nil {
+		return nil, err
+	}
+	layered, err := newLayeredFreezer(lower, freezer)
+	if err != nil {
+		freezer.Close()
+		return nil, err
+	}
+	frdb, err := newFreezerDatabase(db, ancient, wrapChainFreezer(layered))
+	if err != nil {
+		freezer.Close()
+		return nil, err
+	}
+	return frdb, nil
+}
+
+// newFreezerDatabase combines a key-value data store with a chain freezer after
+// checking their consistency, and starts moving the immutable chain segments
+// from the former into the latter.
+func newFreezerDatabase(db ethdb.KeyValueStore, ancient string, frdb *chainFreezer) (ethdb.Database, error) {
 	// Since the freezer can be stored separately from the user's key-value database,
 	// there's a fairly high probability that the user requests invalid combinations
 	// of the freezer and database. Ensure that we don't shoot ourselves in the foot
@@ -267,7 +307,7 @@ func NewDatabaseWithFreezer(db ethdb.KeyValueStore, ancient string, namespace st
 		}
 	}
 	// Freezer is consistent with the key-value database, permit combining the two
-	if !frdb.readonly {
+	if !frdb.AncientReadOnly() {
 		frdb.wg.Add(1)
 		go func() {
 			frdb.freeze(db)
@@ -281,6 +321,37 @@ func NewDatabaseWithFreezer(db ethdb.KeyValueStore, ancient string, namespace st
 	}, nil
 }
 
+// NewDatabaseWithAncientStore creates a high level database on top of a given
+// key-value data store, attaching an externally managed ancient store to it (e.g.
+// one shared with other processes). Unlike NewDatabaseWithFreezer, no background
+// freezing is done, the ancient store is expected to be populated by its owner.
+func NewDatabaseWithAncientStore(db ethdb.KeyValueStore, ancients ethdb.AncientStore) (ethdb.Database, error) {
+	// Refuse to combine stores belonging to different networks, the rest of the
+	// consistency checks are done by the blockchain on startup.
+	if kvgenesis, _ := db.Get(headerHashKey(0)); len(kvgenesis) > 0 {
+		if frozen, _ := ancients.Ancients(); frozen > 0 {
+			frgenesis, err := ancients.Ancient(chainFreezerHashTable, 0)
+			if err != nil {
+				return nil, fmt.Errorf("failed to retrieve genesis from ancient %v", err)
+			} else if !bytes.Equal(kvgenesis, frgenesis) {
+				return nil, fmt.Errorf("genesis mismatch: %#x (leveldb) != %#x (ancients)", kvgenesis, frgenesis)
+			}
+		}
+	}
+	return &freezerdb{
+		KeyValueStore: db,
+		AncientStore:  ancients,
+	}, nil
+}
+
+// NewChainFreezer opens the chain segment freezer located in the given root
+// ancient directory as a standalone ancient store, without a key-value store
+// or background freezing attached. It is meant to be used by processes serving
+// the ancient chain data to others.
+func NewChainFreezer(ancient string, namespace string, readonly bool) (*Freezer, error) {
+	return NewFreezer(resolveChainFreezerDir(ancient), namespace, readonly, freezerTableSize, chainFreezerNoSnappy)
+}
+
 // NewMemoryDatabase creates an ephemeral in-memory key-value database without a
 // freezer moving immutable chain segments into cold storage.
 func NewMemoryDatabase() ethdb.Database {
@@ -321,6 +392,22 @@ func NewLevelDBDatabaseWithFreezer(file string, cache int, handles int, ancient
 	return frdb, nil
 }
 
+// NewLevelDBDatabaseWithLayeredFreezer creates a persistent key-value database
+// with a freezer moving immutable chain segments into cold storage, layered on
+// top of a read-only ancient store holding the oldest chain segment.
+func NewLevelDBDatabaseWithLayeredFreezer(file string, cache int, handles int, lower ethdb.AncientStore, ancient string, namespace string, readonly bool) (ethdb.Database, error) {
+	kvdb, err := leveldb.New(file, cache, handles, namespace, readonly)
+	if err != nil {
+		return nil, err
+	}
+	frdb, err := NewDatabaseWithLayeredFreezer(kvdb, lower, ancient, namespace, readonly)
+	if err != nil {
+		kvdb.Close()
+		return nil, err
+	}
+	return frdb, nil
+}
+
 type counter uint64
 
 func (c counter) String() string {
@@ -376,6 +463,7 @@ func InspectDatabase(db ethdb.Database, keyPrefix, keyStart []byte) error {
 		storageSnaps    stat
 		preimages       stat
 		bloomBits       stat
+		logIndex        stat
 		beaconHeaders   stat
 		cliqueSnaps     stat
 
@@ -437,6 +525,10 @@ func InspectDatabase(db ethdb.Database, keyPrefix, keyStart []byte) error {
 			bloomBits.Add(size)
 		case bytes.HasPrefix(key, BloomBitsIndexPrefix):
 			bloomBits.Add(size)
+		case bytes.HasPrefix(key, logIndexPrefix) && (len(key) == len(logIndexPrefix)+1+common.AddressLength+8 || len(key) == len(logIndexPrefix)+1+common.HashLength+8):
+			logIndex.Add(size)
+		case bytes.HasPrefix(key, LogIndexPrefix):
+			logIndex.Add(size)
 		case bytes.HasPrefix(key, skeletonHeaderPrefix) && len(key) == (len(skeletonHeaderPrefix)+8):
 			beaconHeaders.Add(size)
 		case bytes.HasPrefix(key, []byte("clique-")) && len(key) == 7+common.HashLength:
@@ -456,6 +548,7 @@ func InspectDatabase(db ethdb.Database, keyPrefix, keyStart []byte) error {
 				lastPivotKey, fastTrieProgressKey, snapshotDisabledKey, SnapshotRootKey, snapshotJournalKey,
 				snapshotGeneratorKey, snapshotRecoveryKey, txIndexTailKey, fastTxLookupLimitKey,
 				uncleanShutdownKey, badBlockKey, transitionStatusKey, skeletonSyncStatusKey,
+				historyTailKey,
 			} {
 				if bytes.Equal(key, meta) {
 					metadata.Add(size)
@@ -496,6 +589,7 @@ func InspectDatabase(db ethdb.Database, keyPrefix, keyStart []byte) error {
 		{"Key-Value store", "Block hash->number", hashNumPairings.Size(), hashNumPairings.Count()},
 		{"Key-Value store", "Transaction index", txLookups.Size(), txLookups.Count()},
 		{"Key-Value store", "Bloombit index", bloomBits.Size(), bloomBits.Count()},
+		{"Key-Value store", "Log index", logIndex.Size(), logIndex.Count()},
 		{"Key-Value store", "Contract codes", codes.Size(), codes.Count()},
 		{"Key-Value store", "Trie nodes", tries.Size(), tries.Count()},
 		{"Key-Value store", "Trie preimages", preimages.Size(), preimages.Count()},
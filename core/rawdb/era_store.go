@@ -0,0 +1,324 @@
+// Copyright 2022 The go-confero Authors
+// This file is part of the go-confero library.
+//
+// The go-confero library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-confero library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-confero library. If not, see <http://www.gnu.org/licenses/>.
+
+package rawdb
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/confero-network/go-confero/core/types"
+	"github.com/confero-network/go-confero/crypto"
+	"github.com/confero-network/go-confero/ethdb"
+	"github.com/confero-network/go-confero/internal/era"
+	"github.com/confero-network/go-confero/log"
+	"github.com/confero-network/go-confero/rlp"
+	lru "github.com/hashicorp/golang-lru"
+)
+
+// eraStoreOpenFiles is the maximum number of era files kept open.
+const eraStoreOpenFiles = 64
+
+// eraFile is an era file mounted in an EraStore.
+type eraFile struct {
+	path  string
+	start uint64
+	count uint64
+}
+
+// openEraFile is an open era file, shared by the readers of the store. It is
+// closed once it has been evicted from the open files and its last reader is
+// done.
+type openEraFile struct {
+	*era.Era
+	refs    int  // number of readers using the file, protected by the store lock
+	evicted bool // whether the file was evicted from the open files
+}
+
+// EraStore is a read-only ancient store backed by a directory of era1 files.
+// The chain segment stored in the files is served in the format of the chain
+// freezer tables, so a node's freezer can be layered on top of it to store the
+// newer blocks. The directory is only read, it can be shared by many nodes.
+type EraStore struct {
+	dir   string
+	files []eraFile // mounted files, ordered by block number
+	items uint64    // number of blocks in the files
+	size  uint64    // total size of the files
+
+	lock sync.Mutex
+	open *lru.Cache // open files by index, evicted under the lock
+}
+
+// NewEraStore mounts the era1 files of a directory. The files must belong to
+// a single network and contain a contiguous chain segment starting at the
+// genesis block.
+func NewEraStore(dir string) (*EraStore, error) {
+	network, err := eraNetwork(dir)
+	if err != nil {
+		return nil, err
+	}
+	names, err := era.ReadDir(dir, network)
+	if err != nil {
+		return nil, err
+	}
+	s := &EraStore{dir: dir}
+	s.open, _ = lru.NewWithEvict(eraStoreOpenFiles, func(key, value interface{}) {
+		f := value.(*openEraFile)
+		f.evicted = true
+		if f.refs == 0 {
+			f.Close()
+		}
+	})
+	for _, name := range names {
+		path := filepath.Join(dir, name)
+		e, err := era.Open(path)
+		if err != nil {
+			s.Close()
+			return nil, fmt.Errorf("failed to open %s: %v", name, err)
+		}
+		if e.Start() != s.items {
+			e.Close()
+			s.Close()
+			return nil, fmt.Errorf("era file %s starts at block %d, want %d", name, e.Start(), s.items)
+		}
+		info, err := os.Stat(path)
+		if err != nil {
+			e.Close()
+			s.Close()
+			return nil, err
+		}
+		s.files = append(s.files, eraFile{path: path, start: e.Start(), count: e.Count()})
+		s.items += e.Count()
+		s.size += uint64(info.Size())
+		s.open.Add(len(s.files)-1, &openEraFile{Era: e})
+	}
+	log.Info("Mounted era history", "dir", dir, "network", network, "files", len(s.files), "blocks", s.items)
+	return s, nil
+}
+
+// eraNetwork returns the network name of the era1 files in a directory.
+func eraNetwork(dir string) (string, error) {
+	matches, err := filepath.Glob(filepath.Join(dir, "*.era1"))
+	if err != nil {
+		return "", err
+	}
+	networks := make(map[string]struct{})
+	for _, match := range matches {
+		if parts := strings.Split(filepath.Base(match), "-"); len(parts) == 3 {
+			networks[parts[0]] = struct{}{}
+		}
+	}
+	switch len(networks) {
+	case 0:
+		return "", fmt.Errorf("no era1 files found in %s", dir)
+	case 1:
+		for network := range networks {
+			return network, nil
+		}
+	}
+	return "", fmt.Errorf("era1 files of %d networks found in %s", len(networks), dir)
+}
+
+// file returns the open era file containing the given block. The file stays
+// open until the returned release function is called.
+func (s *EraStore) file(number uint64) (*era.Era, func(), error) {
+	if number >= s.items {
+		return nil, nil, errOutOfBounds
+	}
+	index := sort.Search(len(s.files), func(i int) bool {
+		return s.files[i].start+s.files[i].count > number
+	})
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	var f *openEraFile
+	if cached, ok := s.open.Get(index); ok {
+		f = cached.(*openEraFile)
+	} else {
+		e, err := era.Open(s.files[index].path)
+		if err != nil {
+			return nil, nil, err
+		}
+		f = &openEraFile{Era: e}
+		s.open.Add(index, f)
+	}
+	f.refs++
+	return f.Era, func() { s.release(f) }, nil
+}
+
+// release marks a reader of an open file done, closing the file if it was
+// evicted in the meantime.
+func (s *EraStore) release(f *openEraFile) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if f.refs--; f.refs == 0 && f.evicted {
+		f.Close()
+	}
+}
+
+// HasAncient returns an indicator whether the specified data exists.
+func (s *EraStore) HasAncient(kind string, number uint64) (bool, error) {
+	if _, ok := chainFreezerNoSnappy[kind]; !ok {
+		return false, errUnknownTable
+	}
+	return number < s.items, nil
+}
+
+// Ancient retrieves an item of the given kind, encoded as in the chain freezer.
+func (s *EraStore) Ancient(kind string, number uint64) ([]byte, error) {
+	e, release, err := s.file(number)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	switch kind {
+	case chainFreezerHeaderTable:
+		return e.GetRawHeaderByNumber(number)
+
+	case chainFreezerHashTable:
+		header, err := e.GetRawHeaderByNumber(number)
+		if err != nil {
+			return nil, err
+		}
+		return crypto.Keccak256(header), nil
+
+	case chainFreezerBodiesTable:
+		return e.GetRawBodyByNumber(number)
+
+	case chainFreezerReceiptTable:
+		receipts, err := e.GetReceiptsByNumber(number)
+		if err != nil {
+			return nil, err
+		}
+		stored := make([]*types.ReceiptForStorage, len(receipts))
+		for i, receipt := range receipts {
+			stored[i] = (*types.ReceiptForStorage)(receipt)
+		}
+		return rlp.EncodeToBytes(stored)
+
+	case chainFreezerDifficultyTable:
+		td, err := e.GetTD(number)
+		if err != nil {
+			return nil, err
+		}
+		return rlp.EncodeToBytes(td)
+	}
+	return nil, errUnknownTable
+}
+
+// AncientRange retrieves multiple items in sequence, starting from the index
+// 'start'. It returns at most 'count' items and at least one item, but will
+// otherwise return as many items as fit into maxBytes.
+func (s *EraStore) AncientRange(kind string, start, count, maxBytes uint64) ([][]byte, error) {
+	var (
+		items [][]byte
+		size  uint64
+	)
+	for number := start; number < start+count && number < s.items; number++ {
+		item, err := s.Ancient(kind, number)
+		if err != nil {
+			return nil, err
+		}
+		if len(items) > 0 && maxBytes != 0 && size+uint64(len(item)) > maxBytes {
+			break
+		}
+		items = append(items, item)
+		size += uint64(len(item))
+	}
+	if len(items) == 0 {
+		return nil, errOutOfBounds
+	}
+	return items, nil
+}
+
+// Ancients returns the number of blocks in the era files.
+func (s *EraStore) Ancients() (uint64, error) {
+	return s.items, nil
+}
+
+// Tail returns the number of the first block, which is always the genesis.
+func (s *EraStore) Tail() (uint64, error) {
+	return 0, nil
+}
+
+// AncientSize returns the total size of the era files. The files store all
+// kinds of data together, so the size is the same for every kind.
+func (s *EraStore) AncientSize(kind string) (uint64, error) {
+	if _, ok := chainFreezerNoSnappy[kind]; !ok {
+		return 0, errUnknownTable
+	}
+	return s.size, nil
+}
+
+// ReadAncients runs the given read operation. The files are immutable, so no
+// locking is needed.
+func (s *EraStore) ReadAncients(fn func(ethdb.AncientReaderOp) error) error {
+	return fn(s)
+}
+
+// AncientReadOnly returns true, era files are never written.
+func (s *EraStore) AncientReadOnly() bool {
+	return true
+}
+
+// ModifyAncients is not supported by the read-only store.
+func (s *EraStore) ModifyAncients(func(ethdb.AncientWriteOp) error) (int64, error) {
+	return 0, errReadOnly
+}
+
+// TruncateHead is not supported by the read-only store, unless it doesn't
+// remove any items.
+func (s *EraStore) TruncateHead(items uint64) error {
+	if items >= s.items {
+		return nil
+	}
+	return errReadOnly
+}
+
+// TruncateTail is not supported by the read-only store, unless it doesn't
+// remove any items.
+func (s *EraStore) TruncateTail(tail uint64) error {
+	if tail == 0 {
+		return nil
+	}
+	return errReadOnly
+}
+
+// Sync is a no-op, the store is never written.
+func (s *EraStore) Sync() error {
+	return nil
+}
+
+// MigrateTable is not supported by the read-only store.
+func (s *EraStore) MigrateTable(string, func([]byte) ([]byte, error)) error {
+	return errReadOnly
+}
+
+// Close closes all open era files, the files still in use are closed when
+// their readers are done.
+func (s *EraStore) Close() error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.open.Purge()
+	return nil
+}
@@ -0,0 +1,238 @@
+// Copyright 2022 The go-confero Authors
+// This file is part of the go-confero library.
+//
+// The go-confero library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-confero library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-confero library. If not, see <http://www.gnu.org/licenses/>.
+
+package rawdb
+
+import (
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/confero-network/go-confero/common"
+	"github.com/confero-network/go-confero/core/types"
+	"github.com/confero-network/go-confero/ethdb"
+	"github.com/confero-network/go-confero/ethdb/memorydb"
+	"github.com/confero-network/go-confero/internal/era"
+	"github.com/confero-network/go-confero/params"
+)
+
+// writeTestEraFiles writes a chain of blocks into era files of the given size.
+func writeTestEraFiles(t *testing.T, dir string, blocks, step int) ([]*types.Block, []types.Receipts) {
+	var (
+		chain    []*types.Block
+		receipts []types.Receipts
+		parent   common.Hash
+		td       = new(big.Int)
+		builder  *era.Builder
+		file     *os.File
+		err      error
+	)
+	finalize := func(epoch int) {
+		root, err := builder.Finalize()
+		if err != nil {
+			t.Fatal(err)
+		}
+		file.Close()
+		if err := os.Rename(file.Name(), filepath.Join(dir, era.Filename("test", epoch, root))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i := 0; i < blocks; i++ {
+		if i%step == 0 {
+			if builder != nil {
+				finalize(i/step - 1)
+			}
+			if file, err = os.Create(filepath.Join(dir, "tmp")); err != nil {
+				t.Fatal(err)
+			}
+			builder = era.NewBuilder(file)
+		}
+		tx := types.NewTransaction(uint64(i), common.Address{0x01}, big.NewInt(1), 21000, big.NewInt(1), nil)
+		receipt := &types.Receipt{
+			Status:            types.ReceiptStatusSuccessful,
+			CumulativeGasUsed: 21000,
+			Logs:              []*types.Log{{Address: common.Address{byte(i)}, Topics: []common.Hash{{0x02}}, Data: []byte{0x03}}},
+			TxHash:            tx.Hash(),
+		}
+		receipt.Bloom = types.CreateBloom(types.Receipts{receipt})
+		header := &types.Header{ParentHash: parent, Number: big.NewInt(int64(i)), Difficulty: big.NewInt(int64(i + 1))}
+		block := types.NewBlock(header, []*types.Transaction{tx}, nil, []*types.Receipt{receipt}, newHasher())
+
+		td.Add(td, block.Difficulty())
+		if err := builder.Add(block, types.Receipts{receipt}, td); err != nil {
+			t.Fatal(err)
+		}
+		chain = append(chain, block)
+		receipts = append(receipts, types.Receipts{receipt})
+		parent = block.Hash()
+	}
+	finalize((blocks - 1) / step)
+	return chain, receipts
+}
+
+func TestEraStore(t *testing.T) {
+	dir := t.TempDir()
+	blocks, receipts := writeTestEraFiles(t, dir, 50, 16)
+
+	store, err := NewEraStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	db, err := NewDatabaseWithAncientStore(memorydb.New(), store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	if frozen, _ := db.Ancients(); frozen != uint64(len(blocks)) {
+		t.Fatalf("wrong item count: have %d, want %d", frozen, len(blocks))
+	}
+	var td = new(big.Int)
+	for i, block := range blocks {
+		number := block.NumberU64()
+		td.Add(td, block.Difficulty())
+
+		if hash := ReadCanonicalHash(db, number); hash != block.Hash() {
+			t.Fatalf("block %d: wrong canonical hash %x", number, hash)
+		}
+		if header := ReadHeader(db, block.Hash(), number); header == nil || header.Hash() != block.Hash() {
+			t.Fatalf("block %d: wrong header", number)
+		}
+		if body := ReadBody(db, block.Hash(), number); body == nil || len(body.Transactions) != 1 || body.Transactions[0].Hash() != block.Transactions()[0].Hash() {
+			t.Fatalf("block %d: wrong body", number)
+		}
+		have := ReadReceipts(db, block.Hash(), number, params.TestChainConfig)
+		if len(have) != 1 || have[0].TxHash != receipts[i][0].TxHash || len(have[0].Logs) != 1 || have[0].Logs[0].Address != receipts[i][0].Logs[0].Address {
+			t.Fatalf("block %d: wrong receipts", number)
+		}
+		if have := ReadTd(db, block.Hash(), number); have == nil || have.Cmp(td) != 0 {
+			t.Fatalf("block %d: wrong td: have %v, want %v", number, have, td)
+		}
+	}
+	items, err := db.AncientRange(chainFreezerHashTable, 10, 20, 0)
+	if err != nil || len(items) != 20 {
+		t.Fatalf("wrong range: %d items, err %v", len(items), err)
+	}
+	for i, item := range items {
+		if common.BytesToHash(item) != blocks[10+i].Hash() {
+			t.Fatalf("range item %d: wrong hash", i)
+		}
+	}
+	if _, err := db.Ancient(chainFreezerHashTable, uint64(len(blocks))); err == nil {
+		t.Fatal("no error reading beyond the stored blocks")
+	}
+
+	// The store is read-only.
+	if !db.AncientReadOnly() {
+		t.Fatal("store not read only")
+	}
+	if _, err := db.ModifyAncients(func(op ethdb.AncientWriteOp) error { return nil }); err != errReadOnly {
+		t.Fatalf("wrong error for write: %v", err)
+	}
+	if err := db.TruncateHead(10); err != errReadOnly {
+		t.Fatalf("wrong error for truncation: %v", err)
+	}
+}
+
+func TestEraStoreEviction(t *testing.T) {
+	dir := t.TempDir()
+	blocks, _ := writeTestEraFiles(t, dir, 50, 16)
+
+	store, err := NewEraStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	// Evict the open files while one of them is in use.
+	e, release, err := store.file(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	store.lock.Lock()
+	store.open.Purge()
+	store.lock.Unlock()
+
+	if header, err := e.GetHeaderByNumber(0); err != nil || header.Hash() != blocks[0].Hash() {
+		t.Fatalf("evicted file not readable while in use: %v", err)
+	}
+	release()
+	if _, err := e.GetHeaderByNumber(0); err == nil {
+		t.Fatal("evicted file not closed after use")
+	}
+	// The file is reopened by later reads.
+	if hash, err := store.Ancient(chainFreezerHashTable, 0); err != nil || common.BytesToHash(hash) != blocks[0].Hash() {
+		t.Fatalf("wrong hash after eviction: %x, err %v", hash, err)
+	}
+}
+
+func TestEraStoreGap(t *testing.T) {
+	dir := t.TempDir()
+	writeTestEraFiles(t, dir, 50, 16)
+
+	files, _ := era.ReadDir(dir, "test")
+	os.Remove(filepath.Join(dir, files[1]))
+	if _, err := NewEraStore(dir); err == nil {
+		t.Fatal("no error for missing era file")
+	}
+}
+
+// Tests that the blocks after the end of the era files are frozen into a local
+// freezer layered on top of them.
+func TestEraStoreLayered(t *testing.T) {
+	dir := t.TempDir()
+	blocks, _ := writeTestEraFiles(t, dir, 50, 16)
+
+	parent := blocks[len(blocks)-1]
+	for i := 0; i < 20; i++ {
+		block := types.NewBlockWithHeader(&types.Header{
+			Number:     new(big.Int).Add(parent.Number(), common.Big1),
+			ParentHash: parent.Hash(),
+			Difficulty: big.NewInt(1),
+		})
+		blocks = append(blocks, block)
+		parent = block
+	}
+	kvdb := memorydb.New()
+	writeTestChain(kvdb, blocks)
+
+	store, err := NewEraStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	db, err := NewDatabaseWithLayeredFreezer(kvdb, store, t.TempDir(), "", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	if err := db.(*freezerdb).Freeze(0); err != nil {
+		t.Fatal(err)
+	}
+	if frozen, _ := db.Ancients(); frozen != uint64(len(blocks)) {
+		t.Fatalf("wrong item count: have %d, want %d", frozen, len(blocks))
+	}
+	for _, block := range blocks {
+		number := block.NumberU64()
+		if header := ReadHeader(db, block.Hash(), number); header == nil || header.Hash() != block.Hash() {
+			t.Fatalf("block %d: wrong header", number)
+		}
+		if has, _ := kvdb.Has(headerKey(number, block.Hash())); number >= 50 && has {
+			t.Fatalf("block %d: not moved out of the key-value store", number)
+		}
+	}
+}
@@ -230,6 +230,11 @@ func (f *Freezer) ReadAncients(fn func(ethdb.AncientReaderOp) error) (err error)
 	return fn(f)
 }
 
+// AncientReadOnly reports whether the freezer was opened in read only mode.
+func (f *Freezer) AncientReadOnly() bool {
+	return f.readonly
+}
+
 // ModifyAncients runs the given write operation.
 func (f *Freezer) ModifyAncients(fn func(ethdb.AncientWriteOp) error) (writeSize int64, err error) {
 	if f.readonly {
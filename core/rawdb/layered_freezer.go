@@ -0,0 +1,235 @@
+// Copyright 2022 The go-confero Authors
+// This file is part of the go-confero library.
+//
+// The go-confero library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-confero library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-confero library. If not, see <http://www.gnu.org/licenses/>.
+
+package rawdb
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/confero-network/go-confero/core/types"
+	"github.com/confero-network/go-confero/ethdb"
+	"github.com/confero-network/go-confero/rlp"
+)
+
+// errLowerReadOnly is returned when an operation would modify the items served
+// by the read-only lower store of a layered freezer.
+var errLowerReadOnly = errors.New("items of the read-only lower ancient store can't be modified")
+
+// layeredFreezer is an ancient store combining a read-only lower store, such as
+// a store shared with other processes, with a local freezer. The lower store
+// serves the items below the first item of the local freezer, all newer items
+// are appended to and served by the local freezer.
+type layeredFreezer struct {
+	lower ethdb.AncientStore // Read-only store serving the oldest items
+	upper *Freezer           // Local freezer storing the items from base on
+	base  uint64             // Number of the first item stored in the local freezer
+}
+
+// newLayeredFreezer layers the given local chain freezer on top of a read-only
+// lower store. An empty local freezer continues where the lower store ends, a
+// non-empty one where its first block header says.
+func newLayeredFreezer(lower ethdb.AncientStore, upper *Freezer) (*layeredFreezer, error) {
+	lowered, err := lower.Ancients()
+	if err != nil {
+		return nil, err
+	}
+	f := &layeredFreezer{lower: lower, upper: upper, base: lowered}
+	if frozen, _ := upper.Ancients(); frozen > 0 {
+		blob, err := upper.Ancient(chainFreezerHeaderTable, 0)
+		if err != nil {
+			return nil, fmt.Errorf("failed to retrieve first local ancient header: %v", err)
+		}
+		header := new(types.Header)
+		if err := rlp.DecodeBytes(blob, header); err != nil {
+			return nil, fmt.Errorf("invalid first local ancient header: %v", err)
+		}
+		f.base = header.Number.Uint64()
+	}
+	if lowered < f.base {
+		return nil, fmt.Errorf("gap (#%d) in the chain between the lower ancients and the local freezer (#%d)", lowered, f.base)
+	}
+	return f, nil
+}
+
+// HasAncient returns an indicator whether the specified data exists.
+func (f *layeredFreezer) HasAncient(kind string, number uint64) (bool, error) {
+	if number >= f.base {
+		return f.upper.HasAncient(kind, number-f.base)
+	}
+	return f.lower.HasAncient(kind, number)
+}
+
+// Ancient retrieves an ancient binary blob from the layer storing it.
+func (f *layeredFreezer) Ancient(kind string, number uint64) ([]byte, error) {
+	if number >= f.base {
+		return f.upper.Ancient(kind, number-f.base)
+	}
+	return f.lower.Ancient(kind, number)
+}
+
+// AncientRange retrieves multiple items in sequence, starting from the index
+// 'start'. Ranges crossing the layers are served from both, within the same
+// limits as a single freezer.
+func (f *layeredFreezer) AncientRange(kind string, start, count, maxBytes uint64) ([][]byte, error) {
+	if start >= f.base {
+		return f.upper.AncientRange(kind, start-f.base, count, maxBytes)
+	}
+	lowerCount := count
+	if start+count > f.base {
+		lowerCount = f.base - start
+	}
+	items, err := f.lower.AncientRange(kind, start, lowerCount, maxBytes)
+	if err != nil || lowerCount == count || uint64(len(items)) < lowerCount {
+		return items, err
+	}
+	var size uint64
+	for _, item := range items {
+		size += uint64(len(item))
+	}
+	if size >= maxBytes {
+		return items, nil
+	}
+	// Continue in the local freezer, which might not have the items yet
+	more, err := f.upper.AncientRange(kind, 0, count-lowerCount, maxBytes-size)
+	if err != nil {
+		return items, nil
+	}
+	for _, item := range more {
+		if size += uint64(len(item)); size > maxBytes {
+			break
+		}
+		items = append(items, item)
+	}
+	return items, nil
+}
+
+// Ancients returns the number of items in both layers.
+func (f *layeredFreezer) Ancients() (uint64, error) {
+	frozen, err := f.upper.Ancients()
+	return f.base + frozen, err
+}
+
+// Tail returns the number of the first stored item.
+func (f *layeredFreezer) Tail() (uint64, error) {
+	if f.base == 0 {
+		return f.upper.Tail()
+	}
+	return f.lower.Tail()
+}
+
+// AncientSize returns the ancient size of the specified category in both layers.
+func (f *layeredFreezer) AncientSize(kind string) (uint64, error) {
+	size, err := f.upper.AncientSize(kind)
+	if err != nil || f.base == 0 {
+		return size, err
+	}
+	lowerSize, err := f.lower.AncientSize(kind)
+	return size + lowerSize, err
+}
+
+// ReadAncients runs the given read operation while ensuring that no writes take
+// place on the local freezer. The lower store is read-only.
+func (f *layeredFreezer) ReadAncients(fn func(ethdb.AncientReaderOp) error) error {
+	return f.upper.ReadAncients(func(ethdb.AncientReaderOp) error {
+		return fn(f)
+	})
+}
+
+// AncientReadOnly reports whether the local freezer was opened in read only mode.
+func (f *layeredFreezer) AncientReadOnly() bool {
+	return f.upper.AncientReadOnly()
+}
+
+// ModifyAncients runs the given write operation against the local freezer.
+func (f *layeredFreezer) ModifyAncients(fn func(ethdb.AncientWriteOp) error) (int64, error) {
+	return f.upper.ModifyAncients(func(op ethdb.AncientWriteOp) error {
+		return fn(&layeredWriteOp{op: op, base: f.base})
+	})
+}
+
+// TruncateHead discards all but the first n ancient data. Only items of the
+// local freezer can be discarded.
+func (f *layeredFreezer) TruncateHead(items uint64) error {
+	if items < f.base {
+		return errLowerReadOnly
+	}
+	return f.upper.TruncateHead(items - f.base)
+}
+
+// TruncateTail discards the first n ancient data. Only items of the local freezer
+// can be discarded, which requires the lower store to be empty.
+func (f *layeredFreezer) TruncateTail(tail uint64) error {
+	if f.base == 0 {
+		return f.upper.TruncateTail(tail)
+	}
+	if current, err := f.lower.Tail(); err != nil || tail <= current {
+		return err
+	}
+	return errLowerReadOnly
+}
+
+// Sync flushes the local freezer to disk.
+func (f *layeredFreezer) Sync() error {
+	return f.upper.Sync()
+}
+
+// MigrateTable migrates the given table of the local freezer, which requires
+// the lower store to be empty.
+func (f *layeredFreezer) MigrateTable(kind string, convert func([]byte) ([]byte, error)) error {
+	if f.base > 0 {
+		return errLowerReadOnly
+	}
+	return f.upper.MigrateTable(kind, convert)
+}
+
+// Close closes both layers.
+func (f *layeredFreezer) Close() error {
+	var errs []error
+	if err := f.upper.Close(); err != nil {
+		errs = append(errs, err)
+	}
+	if err := f.lower.Close(); err != nil {
+		errs = append(errs, err)
+	}
+	if len(errs) != 0 {
+		return fmt.Errorf("%v", errs)
+	}
+	return nil
+}
+
+// layeredWriteOp translates the item numbers of a write operation on a layered
+// freezer to the ones of the local freezer.
+type layeredWriteOp struct {
+	op   ethdb.AncientWriteOp
+	base uint64
+}
+
+// Append adds an RLP-encoded item.
+func (op *layeredWriteOp) Append(kind string, number uint64, item interface{}) error {
+	if number < op.base {
+		return errLowerReadOnly
+	}
+	return op.op.Append(kind, number-op.base, item)
+}
+
+// AppendRaw adds an item without RLP-encoding it.
+func (op *layeredWriteOp) AppendRaw(kind string, number uint64, item []byte) error {
+	if number < op.base {
+		return errLowerReadOnly
+	}
+	return op.op.AppendRaw(kind, number-op.base, item)
+}
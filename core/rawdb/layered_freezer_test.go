@@ -0,0 +1,143 @@
+// Copyright 2022 The go-confero Authors
+// This file is part of the go-confero library.
+//
+// The go-confero library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-confero library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-confero library. If not, see <http://www.gnu.org/licenses/>.
+
+package rawdb
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/confero-network/go-confero/common"
+	"github.com/confero-network/go-confero/core/types"
+	"github.com/confero-network/go-confero/ethdb"
+	"github.com/confero-network/go-confero/ethdb/memorydb"
+)
+
+// writeTestChain writes the given blocks into a key-value store as the canonical
+// chain, with the last one as the head.
+func writeTestChain(db ethdb.KeyValueWriter, blocks []*types.Block) {
+	td := new(big.Int)
+	for _, block := range blocks {
+		td.Add(td, block.Difficulty())
+		WriteBlock(db, block)
+		WriteReceipts(db, block.Hash(), block.NumberU64(), types.Receipts{})
+		WriteTd(db, block.Hash(), block.NumberU64(), td)
+		WriteCanonicalHash(db, block.Hash(), block.NumberU64())
+	}
+	head := blocks[len(blocks)-1].Hash()
+	WriteHeadHeaderHash(db, head)
+	WriteHeadBlockHash(db, head)
+}
+
+func TestLayeredFreezer(t *testing.T) {
+	var (
+		blocks []*types.Block
+		parent common.Hash
+	)
+	for i := 0; i < 40; i++ {
+		block := types.NewBlockWithHeader(&types.Header{
+			Number:     big.NewInt(int64(i)),
+			ParentHash: parent,
+			Difficulty: big.NewInt(1),
+		})
+		blocks = append(blocks, block)
+		parent = block.Hash()
+	}
+	// Freeze the first half of the chain into the shared store
+	lowerDir := t.TempDir()
+	shared, err := NewDatabaseWithFreezer(memorydb.New(), lowerDir, "", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeTestChain(shared, blocks[:20])
+	if err := shared.(*freezerdb).Freeze(0); err != nil {
+		t.Fatal(err)
+	}
+	shared.Close()
+
+	// Layer a local freezer on top of it, which must freeze the second half
+	var (
+		kvdb     = memorydb.New()
+		upperDir = t.TempDir()
+	)
+	writeTestChain(kvdb, blocks)
+	open := func() ethdb.Database {
+		lower, err := NewChainFreezer(lowerDir, "", true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		db, err := NewDatabaseWithLayeredFreezer(kvdb, lower, upperDir, "", false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return db
+	}
+	db := open()
+	if frozen, _ := db.Ancients(); frozen != 20 {
+		t.Fatalf("wrong item count before freezing: have %d, want 20", frozen)
+	}
+	if err := db.(*freezerdb).Freeze(0); err != nil {
+		t.Fatal(err)
+	}
+	if frozen, _ := db.Ancients(); frozen != 40 {
+		t.Fatalf("wrong item count after freezing: have %d, want 40", frozen)
+	}
+	for _, block := range blocks {
+		number := block.NumberU64()
+		if hash := ReadCanonicalHash(db, number); hash != block.Hash() {
+			t.Fatalf("block %d: wrong canonical hash %x", number, hash)
+		}
+		if header := ReadHeader(db, block.Hash(), number); header == nil || header.Hash() != block.Hash() {
+			t.Fatalf("block %d: wrong header", number)
+		}
+		if has, _ := kvdb.Has(headerKey(number, block.Hash())); number >= 20 && has {
+			t.Fatalf("block %d: not moved out of the key-value store", number)
+		}
+	}
+	items, err := db.AncientRange(chainFreezerHashTable, 15, 10, 1024)
+	if err != nil || len(items) != 10 {
+		t.Fatalf("wrong range across layers: %d items, err %v", len(items), err)
+	}
+	for i, item := range items {
+		if hash := blocks[15+i].Hash(); common.BytesToHash(item) != hash {
+			t.Fatalf("range item %d: wrong hash %x, want %x", i, item, hash)
+		}
+	}
+	// Only the local part can be truncated
+	if err := db.TruncateHead(10); err != errLowerReadOnly {
+		t.Fatalf("wrong error truncating the shared store: have %v, want %v", err, errLowerReadOnly)
+	}
+	if err := db.TruncateHead(30); err != nil {
+		t.Fatal("failed to truncate local freezer:", err)
+	}
+	if frozen, _ := db.Ancients(); frozen != 30 {
+		t.Fatalf("wrong item count after truncation: have %d, want 30", frozen)
+	}
+	db.Close()
+
+	// Reopen and check the layers are split at the same block
+	db = open()
+	defer db.Close()
+
+	if frozen, _ := db.Ancients(); frozen != 30 {
+		t.Fatalf("wrong item count after reopen: have %d, want 30", frozen)
+	}
+	for _, number := range []uint64{19, 20, 29} {
+		if hash := ReadCanonicalHash(db, number); hash != blocks[number].Hash() {
+			t.Fatalf("block %d: wrong canonical hash after reopen %x", number, hash)
+		}
+	}
+}
@@ -72,6 +72,10 @@ var (
 	// txIndexTailKey tracks the oldest block whose transactions have been indexed.
 	txIndexTailKey = []byte("TransactionIndexTail")
 
+	// historyTailKey tracks the oldest block of a chain synced from a checkpoint
+	// while the blocks below it are being backfilled.
+	historyTailKey = []byte("HistoryTail")
+
 	// fastTxLookupLimitKey tracks the transaction lookup limit during fast sync.
 	fastTxLookupLimitKey = []byte("FastTransactionLookupLimit")
 
@@ -99,6 +103,7 @@ var (
 	SnapshotStoragePrefix = []byte("o") // SnapshotStoragePrefix + account hash + storage hash -> storage trie value
 	CodePrefix            = []byte("c") // CodePrefix + code hash -> account code
 	skeletonHeaderPrefix  = []byte("S") // skeletonHeaderPrefix + num (uint64 big endian) -> header
+	logIndexPrefix        = []byte("x") // logIndexPrefix + kind (1 byte) + address or topic + num (uint64 big endian) -> log index entry
 
 	PreimagePrefix = []byte("secure-key-")       // PreimagePrefix + hash -> preimage
 	configPrefix   = []byte("confero-config-")  // config prefix for the db
@@ -106,6 +111,7 @@ var (
 
 	// Chain index prefixes (use `i` + single byte to avoid mixing data types).
 	BloomBitsIndexPrefix = []byte("iB") // BloomBitsIndexPrefix is the data table of a chain indexer to track its progress
+	LogIndexPrefix       = []byte("iL") // LogIndexPrefix is the data table of the log indexer to track its progress
 
 	preimageCounter    = metrics.NewRegisteredCounter("db/preimage/total", nil)
 	preimageHitCounter = metrics.NewRegisteredCounter("db/preimage/hits", nil)
@@ -191,6 +197,16 @@ func bloomBitsKey(bit uint, section uint64, hash common.Hash) []byte {
 	return key
 }
 
+// logIndexKeyPrefix = logIndexPrefix + kind + value
+func logIndexKeyPrefix(kind byte, value []byte) []byte {
+	return append(append(append([]byte{}, logIndexPrefix...), kind), value...)
+}
+
+// logIndexKey = logIndexPrefix + kind + value + num (uint64 big endian)
+func logIndexKey(kind byte, value []byte, number uint64) []byte {
+	return append(logIndexKeyPrefix(kind, value), encodeBlockNumber(number)...)
+}
+
 // skeletonHeaderKey = skeletonHeaderPrefix + num (uint64 big endian)
 func skeletonHeaderKey(number uint64) []byte {
 	return append(skeletonHeaderPrefix, encodeBlockNumber(number)...)
@@ -91,6 +91,11 @@ func (t *table) ModifyAncients(fn func(ethdb.AncientWriteOp) error) (int64, erro
 	return t.db.ModifyAncients(fn)
 }
 
+// AncientReadOnly returns whether the underlying ancient store is read only.
+func (t *table) AncientReadOnly() bool {
+	return t.db.AncientReadOnly()
+}
+
 func (t *table) ReadAncients(fn func(reader ethdb.AncientReaderOp) error) (err error) {
 	return t.db.ReadAncients(fn)
 }
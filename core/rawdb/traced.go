@@ -0,0 +1,60 @@
+// Copyright 2022 The go-confero Authors
+// This file is part of the go-confero library.
+//
+// The go-confero library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-confero library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-confero library. If not, see <http://www.gnu.org/licenses/>.
+
+package rawdb
+
+import (
+	"context"
+
+	"github.com/confero-network/go-confero/ethdb"
+	"github.com/confero-network/go-confero/tracing"
+)
+
+// tracedReader is a wrapper around a database reader that records each access
+// as a span of a trace.
+type tracedReader struct {
+	ctx   context.Context
+	db    ethdb.KeyValueReader
+	table string
+}
+
+// NewTracedReader returns a database reader recording its accesses as spans of
+// the trace carried by ctx, annotated with the given table name. If ctx isn't
+// traced, db is returned as is.
+func NewTracedReader(ctx context.Context, db ethdb.KeyValueReader, table string) ethdb.KeyValueReader {
+	if !tracing.Traced(ctx) {
+		return db
+	}
+	return &tracedReader{ctx: ctx, db: db, table: table}
+}
+
+// Has retrieves if a key is present in the database.
+func (r *tracedReader) Has(key []byte) (bool, error) {
+	_, span := tracing.StartSpan(r.ctx, "ethdb.has", tracing.String("db.table", r.table))
+	ok, err := r.db.Has(key)
+	span.SetAttributes(tracing.Bool("db.found", ok))
+	span.End()
+	return ok, err
+}
+
+// Get retrieves the given key if it's present in the database.
+func (r *tracedReader) Get(key []byte) ([]byte, error) {
+	_, span := tracing.StartSpan(r.ctx, "ethdb.get", tracing.String("db.table", r.table))
+	enc, err := r.db.Get(key)
+	span.SetAttributes(tracing.Bool("db.found", err == nil), tracing.Int("db.value_size", len(enc)))
+	span.End()
+	return enc, err
+}
@@ -17,6 +17,7 @@
 package state
 
 import (
+	"context"
 	"errors"
 	"fmt"
 
@@ -114,6 +115,18 @@ type Trie interface {
 	// nodes of the longest existing prefix of the key (at least the root), ending
 	// with the node that proves the absence of the key.
 	Prove(key []byte, fromLevel uint, proofDb ethdb.KeyValueWriter) error
+
+	// EnableWitness starts tracking all the trie nodes resolved from the database,
+	// so they can be included in a stateless execution witness.
+	EnableWitness()
+
+	// Witness returns the rlp-encoded blobs of all the trie nodes resolved from the
+	// database since witness tracking was enabled.
+	Witness() map[string]struct{}
+
+	// SetTraceContext sets the context carrying the trace span under which the
+	// node resolutions of the trie are traced.
+	SetTraceContext(ctx context.Context)
 }
 
 // NewDatabase creates a backing store for state. The returned database is safe for
@@ -171,10 +184,16 @@ func (db *cachingDB) CopyTrie(t Trie) Trie {
 
 // ContractCode retrieves a particular contract's code.
 func (db *cachingDB) ContractCode(addrHash, codeHash common.Hash) ([]byte, error) {
+	return db.contractCode(context.Background(), codeHash)
+}
+
+// contractCode retrieves a particular contract's code, tracing the database reads
+// under the span carried by ctx.
+func (db *cachingDB) contractCode(ctx context.Context, codeHash common.Hash) ([]byte, error) {
 	if code := db.codeCache.Get(nil, codeHash.Bytes()); len(code) > 0 {
 		return code, nil
 	}
-	code := rawdb.ReadCode(db.db.DiskDB(), codeHash)
+	code := rawdb.ReadCode(rawdb.NewTracedReader(ctx, db.db.DiskDB(), "code"), codeHash)
 	if len(code) > 0 {
 		db.codeCache.Set(codeHash.Bytes(), code)
 		db.codeSizeCache.Add(codeHash, len(code))
@@ -183,6 +202,16 @@ func (db *cachingDB) ContractCode(addrHash, codeHash common.Hash) ([]byte, error
 	return nil, errors.New("not found")
 }
 
+// contractCode retrieves a particular contract's code from the database. The
+// database reads are traced under the span carried by ctx, if the database
+// supports it.
+func contractCode(ctx context.Context, db Database, addrHash, codeHash common.Hash) ([]byte, error) {
+	if cdb, ok := db.(*cachingDB); ok {
+		return cdb.contractCode(ctx, codeHash)
+	}
+	return db.ContractCode(addrHash, codeHash)
+}
+
 // ContractCodeWithPrefix retrieves a particular contract's code. If the
 // code can't be found in the cache, then check the existence with **new**
 // db scheme.
@@ -23,6 +23,7 @@ import (
 
 	"github.com/confero-network/go-confero/common"
 	"github.com/confero-network/go-confero/common/hexutil"
+	"github.com/confero-network/go-confero/core/state/snapshot"
 	"github.com/confero-network/go-confero/core/types"
 	"github.com/confero-network/go-confero/log"
 	"github.com/confero-network/go-confero/rlp"
@@ -136,13 +137,15 @@ func (s *StateDB) DumpToCollector(c DumpCollector, conf *DumpConfig) (nextKey []
 		start            = time.Now()
 		logged           = time.Now()
 	)
-	log.Info("Trie dumping started", "root", s.trie.Hash())
-	c.OnRoot(s.trie.Hash())
+	root := s.trie.Hash()
+	log.Info("Trie dumping started", "root", root)
+	c.OnRoot(root)
 
-	it := trie.NewIterator(s.trie.NodeIterator(conf.Start))
+	it := s.accountIterator(root, conf.Start)
+	defer it.Release()
 	for it.Next() {
 		var data types.StateAccount
-		if err := rlp.DecodeBytes(it.Value, &data); err != nil {
+		if err := rlp.DecodeBytes(it.Value(), &data); err != nil {
 			panic(err)
 		}
 		account := DumpAccount{
@@ -150,16 +153,16 @@ func (s *StateDB) DumpToCollector(c DumpCollector, conf *DumpConfig) (nextKey []
 			Nonce:     data.Nonce,
 			Root:      data.Root[:],
 			CodeHash:  data.CodeHash,
-			SecureKey: it.Key,
+			SecureKey: it.Key(),
 		}
-		addrBytes := s.trie.GetKey(it.Key)
+		addrBytes := s.trie.GetKey(it.Key())
 		if addrBytes == nil {
 			// Preimage missing
 			missingPreimages++
 			if conf.OnlyWithAddresses {
 				continue
 			}
-			account.SecureKey = it.Key
+			account.SecureKey = it.Key()
 		}
 		addr := common.BytesToAddress(addrBytes)
 		obj := newObject(s, addr, data)
@@ -168,30 +171,37 @@ func (s *StateDB) DumpToCollector(c DumpCollector, conf *DumpConfig) (nextKey []
 		}
 		if !conf.SkipStorage {
 			account.Storage = make(map[common.Hash]string)
-			storageIt := trie.NewIterator(obj.getTrie(s.db).NodeIterator(nil))
+			storageIt := s.storageIterator(root, common.BytesToHash(it.Key()), obj, nil)
 			for storageIt.Next() {
-				_, content, _, err := rlp.Split(storageIt.Value)
+				_, content, _, err := rlp.Split(storageIt.Value())
 				if err != nil {
 					log.Error("Failed to decode the value returned by iterator", "error", err)
 					continue
 				}
-				account.Storage[common.BytesToHash(s.trie.GetKey(storageIt.Key))] = common.Bytes2Hex(content)
+				account.Storage[common.BytesToHash(s.trie.GetKey(storageIt.Key()))] = common.Bytes2Hex(content)
 			}
+			if err := storageIt.Error(); err != nil {
+				log.Error("Failed to iterate account storage", "account", addr, "err", err)
+			}
+			storageIt.Release()
 		}
 		c.OnAccount(addr, account)
 		accounts++
 		if time.Since(logged) > 8*time.Second {
-			log.Info("Trie dumping in progress", "at", it.Key, "accounts", accounts,
+			log.Info("Trie dumping in progress", "at", it.Key(), "accounts", accounts,
 				"elapsed", common.PrettyDuration(time.Since(start)))
 			logged = time.Now()
 		}
 		if conf.Max > 0 && accounts >= conf.Max {
 			if it.Next() {
-				nextKey = it.Key
+				nextKey = it.Key()
 			}
 			break
 		}
 	}
+	if err := it.Error(); err != nil {
+		log.Error("Failed to iterate accounts", "err", err)
+	}
 	if missingPreimages > 0 {
 		log.Warn("Dump incomplete due to missing preimages", "missing", missingPreimages)
 	}
@@ -201,6 +211,53 @@ func (s *StateDB) DumpToCollector(c DumpCollector, conf *DumpConfig) (nextKey []
 	return nextKey
 }
 
+// IterateStorage calls fn for the storage slots of the given account in hashed
+// key order, starting at the given hashed key, until fn returns false. Next to
+// the slot value, fn receives the hashed slot key and its preimage, if known.
+//
+// If the storage of the account is unmodified since the state was opened and a
+// snapshot is available for the original root, the slots are read from the
+// snapshot, otherwise the (updated) storage trie is walked.
+func (s *StateDB) IterateStorage(addr common.Address, start []byte, fn func(hash common.Hash, key []byte, value common.Hash) bool) error {
+	obj := s.getStateObject(addr)
+	if obj == nil {
+		return nil
+	}
+	var (
+		it dumpIterator
+		tr = s.trie // Trie to resolve key preimages through
+	)
+	if s.snap != nil && len(obj.dirtyStorage) == 0 && len(obj.pendingStorage) == 0 {
+		// The object's root is only updated when pending storage is flushed, so
+		// it matching the snapshot means the storage wasn't touched at all.
+		if acc, err := s.snap.Account(obj.addrHash); err == nil && acc != nil {
+			root := emptyRoot
+			if len(acc.Root) > 0 {
+				root = common.BytesToHash(acc.Root)
+			}
+			if root == obj.data.Root {
+				it = s.storageIterator(s.originalRoot, obj.addrHash, obj, start)
+			}
+		}
+	}
+	if it == nil {
+		tr = s.StorageTrie(addr)
+		it = &trieDumpIterator{trie.NewIterator(tr.NodeIterator(start))}
+	}
+	defer it.Release()
+
+	for it.Next() {
+		_, content, _, err := rlp.Split(it.Value())
+		if err != nil {
+			return err
+		}
+		if !fn(common.BytesToHash(it.Key()), tr.GetKey(it.Key()), common.BytesToHash(content)) {
+			return nil
+		}
+	}
+	return it.Error()
+}
+
 // RawDump returns the entire state an a single large object
 func (s *StateDB) RawDump(opts *DumpConfig) Dump {
 	dump := &Dump{
@@ -233,3 +290,101 @@ func (s *StateDB) IteratorDump(opts *DumpConfig) IteratorDump {
 	iterator.Next = s.DumpToCollector(iterator, opts)
 	return *iterator
 }
+
+// dumpIterator iterates over the hashed keys and consensus encoded values of the
+// accounts or storage slots of a state, backed either by the tries or by the
+// state snapshot.
+type dumpIterator interface {
+	Next() bool
+	Key() []byte
+	Value() []byte
+	Error() error
+	Release()
+}
+
+// accountIterator returns an iterator over the accounts of the state with the
+// given root, starting at the given hashed key. The snapshot is used if one is
+// available for the root, otherwise the account trie is walked.
+func (s *StateDB) accountIterator(root common.Hash, start []byte) dumpIterator {
+	if s.snaps != nil && s.snaps.Snapshot(root) != nil {
+		it, err := s.snaps.AccountIterator(root, seekHash(start))
+		if err == nil {
+			return &snapAccountIterator{it: it}
+		}
+		log.Debug("Falling back to trie for account dump", "root", root, "err", err)
+	}
+	return &trieDumpIterator{trie.NewIterator(s.trie.NodeIterator(start))}
+}
+
+// storageIterator returns an iterator over the storage slots of the given
+// account in the state with the given root, starting at the given hashed key.
+// The snapshot is used if one is available for the root, otherwise the storage
+// trie of the object is walked.
+func (s *StateDB) storageIterator(root common.Hash, addrHash common.Hash, obj *stateObject, start []byte) dumpIterator {
+	if s.snaps != nil && s.snaps.Snapshot(root) != nil {
+		it, err := s.snaps.StorageIterator(root, addrHash, seekHash(start))
+		if err == nil {
+			return &snapStorageIterator{it}
+		}
+		log.Debug("Falling back to trie for storage dump", "root", root, "account", addrHash, "err", err)
+	}
+	return &trieDumpIterator{trie.NewIterator(obj.getTrie(s.db).NodeIterator(start))}
+}
+
+// seekHash converts a (possibly partial) hashed key into a seek position for
+// the snapshot iterators, which is the first hash having the key as prefix.
+func seekHash(start []byte) common.Hash {
+	var seek common.Hash
+	copy(seek[:], start)
+	return seek
+}
+
+// trieDumpIterator is a dumpIterator walking the leaves of a trie.
+type trieDumpIterator struct {
+	it *trie.Iterator
+}
+
+func (it *trieDumpIterator) Next() bool    { return it.it.Next() }
+func (it *trieDumpIterator) Key() []byte   { return it.it.Key }
+func (it *trieDumpIterator) Value() []byte { return it.it.Value }
+func (it *trieDumpIterator) Error() error  { return it.it.Err }
+func (it *trieDumpIterator) Release()      {}
+
+// snapAccountIterator is a dumpIterator over the accounts of a state snapshot,
+// converting the slim snapshot encoding into the consensus one.
+type snapAccountIterator struct {
+	it    snapshot.AccountIterator
+	value []byte
+	err   error
+}
+
+func (it *snapAccountIterator) Next() bool {
+	if it.err != nil || !it.it.Next() {
+		return false
+	}
+	it.value, it.err = snapshot.FullAccountRLP(it.it.Account())
+	return it.err == nil
+}
+
+func (it *snapAccountIterator) Key() []byte   { return it.it.Hash().Bytes() }
+func (it *snapAccountIterator) Value() []byte { return it.value }
+func (it *snapAccountIterator) Release()      { it.it.Release() }
+
+func (it *snapAccountIterator) Error() error {
+	if it.err != nil {
+		return it.err
+	}
+	return it.it.Error()
+}
+
+// snapStorageIterator is a dumpIterator over the storage slots of an account in
+// a state snapshot.
+type snapStorageIterator struct {
+	it snapshot.StorageIterator
+}
+
+func (it *snapStorageIterator) Next() bool    { return it.it.Next() }
+func (it *snapStorageIterator) Key() []byte   { return it.it.Hash().Bytes() }
+func (it *snapStorageIterator) Value() []byte { return it.it.Slot() }
+func (it *snapStorageIterator) Error() error  { return it.it.Error() }
+func (it *snapStorageIterator) Release()      { it.it.Release() }
@@ -28,6 +28,7 @@ import (
 	"github.com/confero-network/go-confero/crypto"
 	"github.com/confero-network/go-confero/metrics"
 	"github.com/confero-network/go-confero/rlp"
+	"github.com/confero-network/go-confero/tracing"
 	"github.com/confero-network/go-confero/trie"
 )
 
@@ -165,6 +166,12 @@ func (s *stateObject) getTrie(db Database) Trie {
 				s.setError(fmt.Errorf("can't create storage trie: %v", err))
 			}
 		}
+		if s.db.witness != nil {
+			s.trie.EnableWitness()
+		}
+		if s.db.traceCtx != nil {
+			s.trie.SetTraceContext(s.db.traceCtx)
+		}
 	}
 	return s.trie
 }
@@ -197,6 +204,15 @@ func (s *stateObject) GetCommittedState(db Database, key common.Hash) common.Has
 	if value, cached := s.originStorage[key]; cached {
 		return value
 	}
+	var (
+		ctx  = s.db.traceCtx
+		span *tracing.Span
+	)
+	if tracing.Traced(ctx) {
+		ctx, span = tracing.StartSpan(ctx, "state.storage", tracing.Stringer("state.address", s.address), tracing.Stringer("state.slot", key))
+		defer span.End()
+	}
+
 	// If no live objects are available, attempt to use snapshots
 	var (
 		enc []byte
@@ -212,20 +228,32 @@ func (s *stateObject) GetCommittedState(db Database, key common.Hash) common.Has
 		if _, destructed := s.db.snapDestructs[s.addrHash]; destructed {
 			return common.Hash{}
 		}
+		_, snapSpan := tracing.StartSpan(ctx, "snapshot.storage")
 		start := time.Now()
 		enc, err = s.db.snap.Storage(s.addrHash, crypto.Keccak256Hash(key.Bytes()))
 		if metrics.EnabledExpensive {
 			s.db.SnapshotStorageReads += time.Since(start)
 		}
+		snapSpan.SetError(err)
+		snapSpan.End()
 	}
 	// If the snapshot is unavailable or reading from it fails, load from the database.
-	if s.db.snap == nil || err != nil {
+	// When building a witness, the trie is consulted regardless, so that all the
+	// trie nodes needed to prove the slot get resolved.
+	if s.db.snap == nil || err != nil || s.db.witness != nil {
+		tr := s.getTrie(db)
+		if span != nil {
+			// Trace the node resolutions as part of the slot read.
+			tr.SetTraceContext(ctx)
+			defer tr.SetTraceContext(s.db.traceCtx)
+		}
 		start := time.Now()
-		enc, err = s.getTrie(db).TryGet(key.Bytes())
+		enc, err = tr.TryGet(key.Bytes())
 		if metrics.EnabledExpensive {
 			s.db.StorageReads += time.Since(start)
 		}
 		if err != nil {
+			span.SetError(err)
 			s.setError(err)
 			return common.Hash{}
 		}
@@ -327,6 +355,9 @@ func (s *stateObject) updateTrie(db Database) Trie {
 		if value == s.originStorage[key] {
 			continue
 		}
+		if s.db.diffs != nil {
+			s.db.diffs.writeSlot(s.address, key, s.originStorage[key])
+		}
 		s.originStorage[key] = value
 
 		var v []byte
@@ -462,10 +493,25 @@ func (s *stateObject) Code(db Database) []byte {
 	if bytes.Equal(s.CodeHash(), emptyCodeHash) {
 		return nil
 	}
-	code, err := db.ContractCode(s.addrHash, common.BytesToHash(s.CodeHash()))
+	var (
+		ctx  = s.db.traceCtx
+		span *tracing.Span
+	)
+	if tracing.Traced(ctx) {
+		ctx, span = tracing.StartSpan(ctx, "state.code", tracing.Stringer("state.address", s.address))
+	}
+	code, err := contractCode(ctx, db, s.addrHash, common.BytesToHash(s.CodeHash()))
 	if err != nil {
+		span.SetError(err)
 		s.setError(fmt.Errorf("can't load code hash %x: %v", s.CodeHash(), err))
 	}
+	if span != nil {
+		span.SetAttributes(tracing.Int("state.code_size", len(code)))
+		span.End()
+	}
+	if s.db.witness != nil {
+		s.db.witness.AddCode(code)
+	}
 	s.code = code
 	return code
 }
@@ -480,6 +526,11 @@ func (s *stateObject) CodeSize(db Database) int {
 	if bytes.Equal(s.CodeHash(), emptyCodeHash) {
 		return 0
 	}
+	// Stateless execution needs the full code to derive the size, so load it
+	// if a witness is being built.
+	if s.db.witness != nil {
+		return len(s.Code(db))
+	}
 	size, err := db.ContractCodeSize(s.addrHash, common.BytesToHash(s.CodeHash()))
 	if err != nil {
 		s.setError(fmt.Errorf("can't load code size %x: %v", s.CodeHash(), err))
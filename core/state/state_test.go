@@ -23,6 +23,7 @@ import (
 
 	"github.com/confero-network/go-confero/common"
 	"github.com/confero-network/go-confero/core/rawdb"
+	"github.com/confero-network/go-confero/core/state/snapshot"
 	"github.com/confero-network/go-confero/crypto"
 	"github.com/confero-network/go-confero/ethdb"
 	"github.com/confero-network/go-confero/trie"
@@ -91,6 +92,69 @@ func TestDump(t *testing.T) {
 	}
 }
 
+func TestSnapshotDump(t *testing.T) {
+	db := rawdb.NewMemoryDatabase()
+	sdb := NewDatabaseWithConfig(db, &trie.Config{Preimages: true})
+	state, _ := New(common.Hash{}, sdb, nil)
+
+	// Create a few accounts, one of them with some storage
+	contract := common.BytesToAddress([]byte{0x01, 0x02})
+	for i := byte(0); i < 16; i++ {
+		state.AddBalance(common.BytesToAddress([]byte{i}), big.NewInt(int64(i)+1))
+		state.SetState(contract, common.BytesToHash([]byte{i}), common.BytesToHash([]byte{i + 1}))
+	}
+	state.SetCode(contract, []byte{3, 3, 3})
+	root, _ := state.Commit(false)
+	if err := sdb.TrieDB().Commit(root, false, nil); err != nil {
+		t.Fatalf("failed to commit state: %v", err)
+	}
+	state, _ = New(root, sdb, nil)
+	want := string(state.Dump(nil))
+
+	// Generate the snapshot, then drop all trie nodes except the root to ensure
+	// the dump is served from the snapshot
+	snaps, err := snapshot.New(db, sdb.TrieDB(), 16, root, false, true, false)
+	if err != nil {
+		t.Fatalf("failed to create snapshot: %v", err)
+	}
+	it := db.NewIterator(nil, nil)
+	for it.Next() {
+		if len(it.Key()) == common.HashLength && !bytes.Equal(it.Key(), root[:]) {
+			db.Delete(it.Key())
+		}
+	}
+	it.Release()
+
+	sdb = NewDatabaseWithConfig(db, &trie.Config{Preimages: true})
+	state, err = New(root, sdb, snaps)
+	if err != nil {
+		t.Fatalf("failed to open state: %v", err)
+	}
+	if got := string(state.Dump(nil)); got != want {
+		t.Errorf("snapshot dump mismatch:\ngot: %s\nwant: %s\n", got, want)
+	}
+	// Iterate a partial storage range from the snapshot too
+	var slots []common.Hash
+	err = state.IterateStorage(contract, nil, func(hash common.Hash, key []byte, value common.Hash) bool {
+		if want := common.BytesToHash([]byte{common.BytesToHash(key)[31] + 1}); value != want {
+			t.Errorf("slot %x: value mismatch: have %x, want %x", key, value, want)
+		}
+		slots = append(slots, hash)
+		return len(slots) < 10
+	})
+	if err != nil {
+		t.Fatalf("failed to iterate storage: %v", err)
+	}
+	if len(slots) != 10 {
+		t.Fatalf("storage slot count mismatch: have %d, want %d", len(slots), 10)
+	}
+	for i := 1; i < len(slots); i++ {
+		if bytes.Compare(slots[i-1][:], slots[i][:]) >= 0 {
+			t.Errorf("storage slots not ordered: %x >= %x", slots[i-1], slots[i])
+		}
+	}
+}
+
 func TestNull(t *testing.T) {
 	s := newStateTest()
 	address := common.HexToAddress("0x823140710bf13990e4500136726d8b55")
@@ -18,6 +18,7 @@
 package state
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"math/big"
@@ -27,11 +28,13 @@ import (
 	"github.com/confero-network/go-confero/common"
 	"github.com/confero-network/go-confero/core/rawdb"
 	"github.com/confero-network/go-confero/core/state/snapshot"
+	"github.com/confero-network/go-confero/core/stateless"
 	"github.com/confero-network/go-confero/core/types"
 	"github.com/confero-network/go-confero/crypto"
 	"github.com/confero-network/go-confero/log"
 	"github.com/confero-network/go-confero/metrics"
 	"github.com/confero-network/go-confero/rlp"
+	"github.com/confero-network/go-confero/tracing"
 	"github.com/confero-network/go-confero/trie"
 )
 
@@ -102,6 +105,16 @@ type StateDB struct {
 	// Per-transaction access list
 	accessList *accessList
 
+	// Witness collects the state accessed during execution, nil if disabled
+	witness *stateless.Witness
+
+	// Context carrying the trace span of the state reads, nil if not traced
+	traceCtx context.Context
+
+	// State diff tracking, nil if disabled
+	diffs     *diffTracker
+	stateDiff *StateDiff
+
 	// Journal of state modifications. This is the backbone of
 	// Snapshot and RevertToSnapshot.
 	journal        *journal
@@ -165,11 +178,56 @@ func (s *StateDB) StartPrefetcher(namespace string) {
 		s.prefetcher.close()
 		s.prefetcher = nil
 	}
-	if s.snap != nil {
+	if s.snap != nil && s.witness == nil {
 		s.prefetcher = newTriePrefetcher(s.db, s.originalRoot, namespace)
 	}
 }
 
+// EnableWitness starts collecting all the state accessed from now on (accounts,
+// storage slots, trie nodes and codes) into the given witness. Trie prefetching
+// is stopped, as the accesses need to be done on the tries owned by the state.
+//
+// The trie nodes are gathered each time the state root is computed, so the root
+// must be derived via IntermediateRoot before the witness is complete.
+func (s *StateDB) EnableWitness(witness *stateless.Witness) {
+	s.StopPrefetcher()
+	s.witness = witness
+	s.trie.EnableWitness()
+}
+
+// SetTraceContext sets the context carrying the trace span under which the
+// state reads are traced from now on, along with the trie node resolutions and
+// database reads they cause.
+func (s *StateDB) SetTraceContext(ctx context.Context) {
+	s.traceCtx = ctx
+	s.trie.SetTraceContext(ctx)
+	for _, obj := range s.stateObjects {
+		if obj.trie != nil {
+			obj.trie.SetTraceContext(ctx)
+		}
+	}
+}
+
+// Witness retrieves the witness being collected, or nil if witness collection
+// is disabled.
+func (s *StateDB) Witness() *stateless.Witness {
+	return s.witness
+}
+
+// EnableStateDiff starts tracking the original values of the state modified
+// from now on, so that the difference can be retrieved via StateDiff after the
+// state is committed. It must be called on a freshly opened state, tracking
+// stops at the next Commit.
+func (s *StateDB) EnableStateDiff() {
+	s.diffs = newDiffTracker()
+}
+
+// StateDiff retrieves the set of accounts changed by the last Commit, or nil if
+// state diff tracking is disabled or the state was not committed yet.
+func (s *StateDB) StateDiff() *StateDiff {
+	return s.stateDiff
+}
+
 // StopPrefetcher terminates a running prefetcher and reports any leftover stats
 // from the gathered metrics.
 func (s *StateDB) StopPrefetcher() {
@@ -339,6 +397,20 @@ func (s *StateDB) GetStorageProof(a common.Address, key common.Hash) ([][]byte,
 	return proof, err
 }
 
+// GetStorageMultiProof returns a single Merkle multiproof for all the given
+// storage slots, containing every trie node only once.
+func (s *StateDB) GetStorageMultiProof(a common.Address, keys []common.Hash) (trie.MultiProof, error) {
+	tr := s.StorageTrie(a)
+	if tr == nil {
+		return nil, errors.New("storage trie for requested address does not exist")
+	}
+	hashes := make([][]byte, len(keys))
+	for i, key := range keys {
+		hashes[i] = crypto.Keccak256(key.Bytes())
+	}
+	return trie.ProveMulti(tr, hashes)
+}
+
 // GetCommittedState retrieves a value from the given account's committed storage trie.
 func (s *StateDB) GetCommittedState(addr common.Address, hash common.Hash) common.Hash {
 	stateObject := s.getStateObject(addr)
@@ -508,16 +580,40 @@ func (s *StateDB) getDeletedStateObject(addr common.Address) *stateObject {
 	if obj := s.stateObjects[addr]; obj != nil {
 		return obj
 	}
+	// When building a witness, the trie is consulted even if the snapshot can
+	// serve the account, so that all the trie nodes needed to prove the account
+	// (or its absence) get resolved.
+	if s.witness != nil && s.snap != nil {
+		if _, err := s.trie.TryGetAccount(addr.Bytes()); err != nil {
+			s.setError(fmt.Errorf("getDeleteStateObject (%x) error: %w", addr.Bytes(), err))
+			return nil
+		}
+	}
+	var (
+		ctx  = s.traceCtx
+		span *tracing.Span
+	)
+	if tracing.Traced(ctx) {
+		ctx, span = tracing.StartSpan(ctx, "state.account", tracing.Stringer("state.address", addr))
+		defer span.End()
+	}
+
 	// If no live objects are available, attempt to use snapshots
 	var data *types.StateAccount
 	if s.snap != nil {
+		_, snapSpan := tracing.StartSpan(ctx, "snapshot.account")
 		start := time.Now()
 		acc, err := s.snap.Account(crypto.HashData(s.hasher, addr.Bytes()))
 		if metrics.EnabledExpensive {
 			s.SnapshotAccountReads += time.Since(start)
 		}
+		snapSpan.SetError(err)
+		snapSpan.End()
 		if err == nil {
 			if acc == nil {
+				if s.diffs != nil {
+					s.diffs.loadAccount(addr, nil)
+				}
 				return nil
 			}
 			data = &types.StateAccount{
@@ -536,6 +632,11 @@ func (s *StateDB) getDeletedStateObject(addr common.Address) *stateObject {
 	}
 	// If snapshot unavailable or reading from it failed, load from the database
 	if data == nil {
+		if span != nil {
+			// Trace the node resolutions as part of the account read.
+			s.trie.SetTraceContext(ctx)
+			defer s.trie.SetTraceContext(s.traceCtx)
+		}
 		start := time.Now()
 		var err error
 		data, err = s.trie.TryGetAccount(addr.Bytes())
@@ -543,13 +644,20 @@ func (s *StateDB) getDeletedStateObject(addr common.Address) *stateObject {
 			s.AccountReads += time.Since(start)
 		}
 		if err != nil {
+			span.SetError(err)
 			s.setError(fmt.Errorf("getDeleteStateObject (%x) error: %w", addr.Bytes(), err))
 			return nil
 		}
 		if data == nil {
+			if s.diffs != nil {
+				s.diffs.loadAccount(addr, nil)
+			}
 			return nil
 		}
 	}
+	if s.diffs != nil {
+		s.diffs.loadAccount(addr, data)
+	}
 	// Insert into the live set
 	obj := newObject(s, addr, *data)
 	s.setStateObject(obj)
@@ -581,11 +689,20 @@ func (s *StateDB) createObject(addr common.Address) (newobj, prev *stateObject)
 			s.snapDestructs[prev.addrHash] = struct{}{}
 		}
 	}
+	var prevdiffdestruct bool
+	if s.diffs != nil && prev != nil {
+		prevdiffdestruct = s.diffs.destruct(addr)
+	}
 	newobj = newObject(s, addr, types.StateAccount{})
+	if prev != nil && prev.trie != nil && s.witness != nil {
+		// The previous object is about to be dropped from the live set, save the
+		// storage trie nodes it accessed.
+		s.witness.AddState(prev.trie.Witness())
+	}
 	if prev == nil {
 		s.journal.append(createObjectChange{account: &addr})
 	} else {
-		s.journal.append(resetObjectChange{prev: prev, prevdestruct: prevdestruct})
+		s.journal.append(resetObjectChange{prev: prev, prevdestruct: prevdestruct, prevdiffdestruct: prevdiffdestruct})
 	}
 	s.setStateObject(newobj)
 	if prev != nil && !prev.deleted {
@@ -788,6 +905,9 @@ func (s *StateDB) Finalise(deleteEmptyObjects bool) {
 		}
 		if obj.suicided || (deleteEmptyObjects && obj.empty()) {
 			obj.deleted = true
+			if s.diffs != nil {
+				s.diffs.destruct(addr)
+			}
 
 			// If state snapshotting is active, also mark the destruction there.
 			// Note, we can't do this only at the end of a block because multiple
@@ -876,7 +996,19 @@ func (s *StateDB) IntermediateRoot(deleteEmptyObjects bool) common.Hash {
 	if metrics.EnabledExpensive {
 		defer func(start time.Time) { s.AccountHashes += time.Since(start) }(time.Now())
 	}
-	return s.trie.Hash()
+	root := s.trie.Hash()
+
+	// If witness building is enabled, gather all the trie nodes resolved so far,
+	// including the ones needed to restructure the tries after deletions.
+	if s.witness != nil {
+		s.witness.AddState(s.trie.Witness())
+		for _, obj := range s.stateObjects {
+			if obj.trie != nil {
+				s.witness.AddState(obj.trie.Witness())
+			}
+		}
+	}
+	return root
 }
 
 // Prepare sets the current transaction hash and index which are
@@ -902,6 +1034,13 @@ func (s *StateDB) Commit(deleteEmptyObjects bool) (common.Hash, error) {
 	// Finalize any pending changes and merge everything into the tries
 	s.IntermediateRoot(deleteEmptyObjects)
 
+	// If diff tracking is enabled, derive the changes before the objects get
+	// flushed and marked clean
+	var diff *StateDiff
+	if s.diffs != nil {
+		diff = s.diffs.diff(s)
+	}
+
 	// Commit objects to the trie, measuring the elapsed time
 	var (
 		accountTrieNodes int
@@ -990,6 +1129,10 @@ func (s *StateDB) Commit(deleteEmptyObjects bool) (common.Hash, error) {
 		return common.Hash{}, err
 	}
 	s.originalRoot = root
+	if diff != nil {
+		diff.Root = root
+		s.stateDiff, s.diffs = diff, nil
+	}
 	return root, err
 }
 
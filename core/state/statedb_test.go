@@ -18,11 +18,15 @@ package state
 
 import (
 	"bytes"
+	"context"
 	"encoding/binary"
+	"encoding/json"
 	"fmt"
 	"math"
 	"math/big"
 	"math/rand"
+	"os"
+	"path/filepath"
 	"reflect"
 	"strings"
 	"sync"
@@ -30,8 +34,11 @@ import (
 	"testing/quick"
 
 	"github.com/confero-network/go-confero/common"
+	"github.com/confero-network/go-confero/common/hexutil"
 	"github.com/confero-network/go-confero/core/rawdb"
 	"github.com/confero-network/go-confero/core/types"
+	"github.com/confero-network/go-confero/crypto"
+	"github.com/confero-network/go-confero/tracing"
 )
 
 // Tests that updating a state trie does not leak any database writes prior to
@@ -954,3 +961,139 @@ func TestFlushOrderDataLoss(t *testing.T) {
 		}
 	}
 }
+
+// Tests that the state diff tracked during a state transition contains exactly
+// the changed accounts and slots, with their original and updated values.
+func TestStateDiff(t *testing.T) {
+	var (
+		db       = NewDatabase(rawdb.NewMemoryDatabase())
+		state, _ = New(common.Hash{}, db, nil)
+
+		changed   = common.Address{0x01}
+		destroyed = common.Address{0x02}
+		created   = common.Address{0x03}
+		untouched = common.Address{0x04}
+		reverted  = common.Address{0x05}
+	)
+	state.SetBalance(changed, big.NewInt(1))
+	state.SetState(changed, common.Hash{0x01}, common.Hash{0x01})
+	state.SetState(changed, common.Hash{0x02}, common.Hash{0x02})
+	state.SetNonce(destroyed, 1)
+	state.SetCode(destroyed, []byte{0xde, 0xad})
+	state.SetBalance(untouched, big.NewInt(4))
+	state.SetBalance(reverted, big.NewInt(5))
+	root, _ := state.Commit(true)
+
+	state, _ = New(root, db, nil)
+	state.EnableStateDiff()
+	state.AddBalance(changed, big.NewInt(1))
+	state.SetState(changed, common.Hash{0x01}, common.Hash{0x05})
+	state.SetState(changed, common.Hash{0x03}, common.Hash{0x06})
+	state.SetState(changed, common.Hash{0x03}, common.Hash{}) // Reverted within block
+	state.GetState(changed, common.Hash{0x02})
+	state.Suicide(destroyed)
+	state.SetCode(created, []byte{0xbe, 0xef})
+	state.SetState(created, common.Hash{0x01}, common.Hash{0x07})
+	state.GetBalance(untouched)
+	snap := state.Snapshot()
+	state.CreateAccount(reverted) // Re-creation reverted within block
+	state.RevertToSnapshot(snap)
+	root, _ = state.Commit(true)
+
+	want := &StateDiff{
+		Root: root,
+		Accounts: map[common.Address]*AccountDiff{
+			changed: {
+				Before: &DiffAccount{Balance: (*hexutil.Big)(big.NewInt(1)), CodeHash: common.BytesToHash(emptyCodeHash)},
+				After:  &DiffAccount{Balance: (*hexutil.Big)(big.NewInt(2)), CodeHash: common.BytesToHash(emptyCodeHash)},
+				Storage: map[common.Hash]StorageDiff{
+					{0x01}: {Before: common.Hash{0x01}, After: common.Hash{0x05}},
+				},
+			},
+			destroyed: {
+				Before:    &DiffAccount{Nonce: 1, Balance: new(hexutil.Big), CodeHash: crypto.Keccak256Hash([]byte{0xde, 0xad}), Code: []byte{0xde, 0xad}},
+				Destroyed: true,
+			},
+			created: {
+				After: &DiffAccount{Balance: new(hexutil.Big), CodeHash: crypto.Keccak256Hash([]byte{0xbe, 0xef}), Code: []byte{0xbe, 0xef}},
+				Storage: map[common.Hash]StorageDiff{
+					{0x01}: {After: common.Hash{0x07}},
+				},
+			},
+		},
+	}
+	if have := state.StateDiff(); !reflect.DeepEqual(have, want) {
+		haveJSON, _ := json.MarshalIndent(have, "", "  ")
+		wantJSON, _ := json.MarshalIndent(want, "", "  ")
+		t.Fatalf("state diff mismatch:\nhave %s\nwant %s", haveJSON, wantJSON)
+	}
+}
+
+func TestStateReadTracing(t *testing.T) {
+	// Commit an account with code and storage to disk, among others such that
+	// its trie nodes need resolving.
+	diskdb := rawdb.NewMemoryDatabase()
+	state, _ := New(common.Hash{}, NewDatabase(diskdb), nil)
+	for i := byte(0); i < 100; i++ {
+		state.AddBalance(common.Address{i}, big.NewInt(1))
+	}
+	addr := common.Address{1}
+	state.SetCode(addr, []byte{1, 2, 3})
+	state.SetState(addr, common.Hash{1}, common.Hash{2})
+	root, _ := state.Commit(false)
+	if err := state.Database().TrieDB().Commit(root, false, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	file := filepath.Join(t.TempDir(), "traces.json")
+	if err := tracing.Setup(tracing.Config{File: file, SampleRatio: 1}); err != nil {
+		t.Fatal(err)
+	}
+	defer tracing.Stop()
+	ctx, span := tracing.StartTrace(context.Background(), "call", "")
+	state, _ = New(root, NewDatabase(diskdb), nil)
+	state.SetTraceContext(ctx)
+	state.GetCode(addr)
+	state.GetState(addr, common.Hash{1})
+	span.End()
+	tracing.Stop()
+
+	// The state reads are traced down to the database accesses.
+	data, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var req struct {
+		ResourceSpans []struct {
+			ScopeSpans []struct {
+				Spans []struct {
+					Name       string `json:"name"`
+					Attributes []struct {
+						Key   string `json:"key"`
+						Value struct {
+							StringValue string `json:"stringValue"`
+						} `json:"value"`
+					} `json:"attributes"`
+				} `json:"spans"`
+			} `json:"scopeSpans"`
+		} `json:"resourceSpans"`
+	}
+	if err := json.Unmarshal(data, &req); err != nil {
+		t.Fatal(err)
+	}
+	have := make(map[string]bool)
+	for _, span := range req.ResourceSpans[0].ScopeSpans[0].Spans {
+		name := span.Name
+		for _, attr := range span.Attributes {
+			if attr.Key == "db.table" {
+				name += "/" + attr.Value.StringValue
+			}
+		}
+		have[name] = true
+	}
+	for _, name := range []string{"call", "state.account", "state.code", "state.storage", "trie.resolve", "ethdb.get/trie", "ethdb.get/code"} {
+		if !have[name] {
+			t.Errorf("span %s not recorded, have %v", name, have)
+		}
+	}
+}
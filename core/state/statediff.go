@@ -0,0 +1,178 @@
+// Copyright 2022 The go-confero Authors
+// This file is part of the go-confero library.
+//
+// The go-confero library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-confero library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-confero library. If not, see <http://www.gnu.org/licenses/>.
+
+package state
+
+import (
+	"bytes"
+	"math/big"
+
+	"github.com/confero-network/go-confero/common"
+	"github.com/confero-network/go-confero/common/hexutil"
+	"github.com/confero-network/go-confero/core/types"
+	"github.com/confero-network/go-confero/crypto"
+)
+
+// StateDiff is the set of accounts changed by a state transition, along with
+// their values before and after it.
+type StateDiff struct {
+	Root     common.Hash                     `json:"root"` // State root after the transition
+	Accounts map[common.Address]*AccountDiff `json:"accounts"`
+}
+
+// AccountDiff describes the change of a single account.
+type AccountDiff struct {
+	Before *DiffAccount `json:"before"` // nil if the account did not exist
+	After  *DiffAccount `json:"after"`  // nil if the account was deleted
+
+	// Destroyed is set if the account was self-destructed or re-created, which
+	// wipes its storage. Only the slots accessed during the transition are listed
+	// in Storage, the before values of slots first written after the wipe are not
+	// known and reported as zero.
+	Destroyed bool                        `json:"destroyed,omitempty"`
+	Storage   map[common.Hash]StorageDiff `json:"storage,omitempty"`
+}
+
+// DiffAccount is the content of an account on one side of a diff. The code is
+// only filled in if it was changed.
+type DiffAccount struct {
+	Nonce    hexutil.Uint64 `json:"nonce"`
+	Balance  *hexutil.Big   `json:"balance"`
+	CodeHash common.Hash    `json:"codeHash"`
+	Code     hexutil.Bytes  `json:"code,omitempty"`
+}
+
+// StorageDiff is the change of a single storage slot.
+type StorageDiff struct {
+	Before common.Hash `json:"before"`
+	After  common.Hash `json:"after"`
+}
+
+// diffTracker records the original values of the state touched by a StateDB,
+// so that the difference can be derived when the state is committed.
+type diffTracker struct {
+	accounts  map[common.Address]*types.StateAccount         // Original account data, nil if non-existent
+	storage   map[common.Address]map[common.Hash]common.Hash // Original values of the written slots
+	destructs map[common.Address]struct{}                    // Accounts whose storage was wiped
+}
+
+func newDiffTracker() *diffTracker {
+	return &diffTracker{
+		accounts:  make(map[common.Address]*types.StateAccount),
+		storage:   make(map[common.Address]map[common.Hash]common.Hash),
+		destructs: make(map[common.Address]struct{}),
+	}
+}
+
+// loadAccount records the original data of an account when it is first loaded
+// from the database.
+func (t *diffTracker) loadAccount(addr common.Address, data *types.StateAccount) {
+	if _, ok := t.accounts[addr]; ok {
+		return
+	}
+	if data != nil {
+		data = &types.StateAccount{
+			Nonce:    data.Nonce,
+			Balance:  new(big.Int).Set(data.Balance),
+			Root:     data.Root,
+			CodeHash: common.CopyBytes(data.CodeHash),
+		}
+	}
+	t.accounts[addr] = data
+}
+
+// writeSlot records the original value of a storage slot when it is first
+// flushed into the storage trie.
+func (t *diffTracker) writeSlot(addr common.Address, key, prev common.Hash) {
+	slots := t.storage[addr]
+	if slots == nil {
+		slots = make(map[common.Hash]common.Hash)
+		t.storage[addr] = slots
+	}
+	if _, ok := slots[key]; !ok {
+		slots[key] = prev
+	}
+}
+
+// destruct records that the storage of an account was wiped, returning whether
+// it was already recorded before.
+func (t *diffTracker) destruct(addr common.Address) bool {
+	_, ok := t.destructs[addr]
+	t.destructs[addr] = struct{}{}
+	return ok
+}
+
+// undestruct drops the wipe of an account, used when the re-creation that
+// caused it is reverted.
+func (t *diffTracker) undestruct(addr common.Address) {
+	delete(t.destructs, addr)
+}
+
+// diff derives the state difference between the recorded original values and
+// the current live objects of the state.
+func (t *diffTracker) diff(s *StateDB) *StateDiff {
+	diff := &StateDiff{Accounts: make(map[common.Address]*AccountDiff)}
+	for addr, origin := range t.accounts {
+		var (
+			obj   = s.stateObjects[addr]
+			entry = new(AccountDiff)
+		)
+		if origin != nil {
+			entry.Before = &DiffAccount{
+				Nonce:    hexutil.Uint64(origin.Nonce),
+				Balance:  (*hexutil.Big)(origin.Balance),
+				CodeHash: common.BytesToHash(origin.CodeHash),
+			}
+			_, entry.Destroyed = t.destructs[addr]
+		}
+		if obj != nil && !obj.deleted {
+			entry.After = &DiffAccount{
+				Nonce:    hexutil.Uint64(obj.data.Nonce),
+				Balance:  (*hexutil.Big)(new(big.Int).Set(obj.data.Balance)),
+				CodeHash: common.BytesToHash(obj.data.CodeHash),
+			}
+		}
+		for key, prev := range t.storage[addr] {
+			var value common.Hash
+			if entry.After != nil {
+				value = obj.originStorage[key]
+			}
+			if value != prev {
+				if entry.Storage == nil {
+					entry.Storage = make(map[common.Hash]StorageDiff)
+				}
+				entry.Storage[key] = StorageDiff{Before: prev, After: value}
+			}
+		}
+		// Attach the codes if they changed, skip the account if nothing did
+		codeChanged := (entry.Before == nil) != (entry.After == nil) ||
+			(entry.Before != nil && entry.Before.CodeHash != entry.After.CodeHash)
+		if codeChanged {
+			if entry.Before != nil && !bytes.Equal(origin.CodeHash, emptyCodeHash) {
+				entry.Before.Code, _ = s.db.ContractCode(crypto.Keccak256Hash(addr[:]), entry.Before.CodeHash)
+			}
+			if entry.After != nil && !bytes.Equal(obj.data.CodeHash, emptyCodeHash) {
+				entry.After.Code = obj.Code(s.db)
+			}
+		}
+		if !codeChanged && !entry.Destroyed && len(entry.Storage) == 0 && (entry.Before == nil ||
+			(entry.Before.Nonce == entry.After.Nonce && entry.Before.Balance.ToInt().Cmp(entry.After.Balance.ToInt()) == 0)) {
+			continue
+		}
+		diff.Accounts[addr] = entry
+	}
+	return diff
+}
@@ -36,10 +36,17 @@ import (
 // StateProcessor implements Processor.
 type StateProcessor struct {
 	config *params.ChainConfig // Chain configuration options
-	bc     *BlockChain         // Canonical block chain
+	bc     processorChain      // Canonical block chain
 	engine consensus.Engine    // Consensus engine used for block rewards
 }
 
+// processorChain is the chain access needed by the StateProcessor, satisfied
+// by the BlockChain as well as by stateless execution witnesses.
+type processorChain interface {
+	ChainContext
+	consensus.ChainHeaderReader
+}
+
 // NewStateProcessor initialises a new StateProcessor.
 func NewStateProcessor(config *params.ChainConfig, bc *BlockChain, engine consensus.Engine) *StateProcessor {
 	return &StateProcessor{
@@ -71,6 +78,14 @@ func (p *StateProcessor) Process(block *types.Block, statedb *state.StateDB, cfg
 		misc.ApplyDAOHardFork(statedb)
 	}
 	blockContext := NewEVMBlockContext(header, p.bc, nil)
+	if witness := statedb.Witness(); witness != nil {
+		// Record the headers needed to serve the BLOCKHASH opcode statelessly
+		getHash := blockContext.GetHash
+		blockContext.GetHash = func(n uint64) common.Hash {
+			witness.AddBlockHash(n)
+			return getHash(n)
+		}
+	}
 	vmenv := vm.NewEVM(blockContext, vm.TxContext{}, statedb, p.config, cfg)
 	// Iterate over and process the individual transactions
 	for i, tx := range block.Transactions() {
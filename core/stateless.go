@@ -0,0 +1,128 @@
+// Copyright 2022 The go-confero Authors
+// This file is part of the go-confero library.
+//
+// The go-confero library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-confero library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-confero library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/confero-network/go-confero/common"
+	"github.com/confero-network/go-confero/consensus"
+	"github.com/confero-network/go-confero/core/state"
+	"github.com/confero-network/go-confero/core/stateless"
+	"github.com/confero-network/go-confero/core/types"
+	"github.com/confero-network/go-confero/core/vm"
+	"github.com/confero-network/go-confero/params"
+)
+
+// ExecuteStateless runs a stateless execution of the given block, based purely
+// on the state contained in the witness. The post-state is validated against the
+// block header the same way as for a regular import (gas used, bloom, receipt
+// and state roots), so a nil error means the witness proves the block valid.
+//
+// Note, only the execution is verified, it's up to the caller to ensure that the
+// block header itself (and the parent header within the witness) is trusted.
+func ExecuteStateless(config *params.ChainConfig, engine consensus.Engine, block *types.Block, witness *stateless.Witness) error {
+	// Ensure the witness was made for the block
+	context := witness.Context()
+	if context == nil {
+		return errors.New("witness context header missing")
+	}
+	if context.Number.Uint64() != block.NumberU64() {
+		return fmt.Errorf("witness context number mismatch: have %d, want %d", context.Number, block.NumberU64())
+	}
+	if context.Hash() != block.Hash() {
+		return fmt.Errorf("witness context mismatch: have %x, want %x", context.Hash(), block.Hash())
+	}
+	if len(witness.Headers) == 0 {
+		return errors.New("witness parent header missing")
+	}
+	if parent := witness.Headers[0]; parent.Hash() != block.ParentHash() {
+		return fmt.Errorf("witness parent mismatch: have %x, want %x", parent.Hash(), block.ParentHash())
+	}
+	// Create and populate the state database to serve as the stateless backend
+	db := state.NewDatabase(witness.MakeHashDB())
+	statedb, err := state.New(witness.Root(), db, nil)
+	if err != nil {
+		return err
+	}
+	// Run the stateless block execution and validate the results
+	var (
+		chain     = &witnessChain{config: config, engine: engine, witness: witness}
+		processor = &StateProcessor{config: config, bc: chain, engine: engine}
+		validator = &BlockValidator{config: config, engine: engine}
+	)
+	receipts, _, usedGas, err := processor.Process(block, statedb, vm.Config{})
+	if err != nil {
+		return err
+	}
+	if err := validator.ValidateState(block, statedb, receipts, usedGas); err != nil {
+		return err
+	}
+	// Any state missing from the witness surfaces as a database error
+	if err := statedb.Error(); err != nil {
+		return fmt.Errorf("incomplete witness: %w", err)
+	}
+	return nil
+}
+
+// witnessChain is a chain context backed by the headers of a witness, used to
+// serve the header accesses of a stateless block execution.
+type witnessChain struct {
+	config  *params.ChainConfig
+	engine  consensus.Engine
+	witness *stateless.Witness
+}
+
+// Engine retrieves the chain's consensus engine.
+func (c *witnessChain) Engine() consensus.Engine { return c.engine }
+
+// Config retrieves the chain's fork configuration.
+func (c *witnessChain) Config() *params.ChainConfig { return c.config }
+
+// CurrentHeader retrieves the parent of the executed block, the most recent
+// header known to the witness.
+func (c *witnessChain) CurrentHeader() *types.Header { return c.witness.Headers[0] }
+
+// GetHeader retrieves a block header embedded in the witness.
+func (c *witnessChain) GetHeader(hash common.Hash, number uint64) *types.Header {
+	return c.witness.GetHeader(hash, number)
+}
+
+// GetHeaderByNumber retrieves a block header embedded in the witness by number.
+func (c *witnessChain) GetHeaderByNumber(number uint64) *types.Header {
+	for _, header := range c.witness.Headers {
+		if header.Number.Uint64() == number {
+			return header
+		}
+	}
+	return nil
+}
+
+// GetHeaderByHash retrieves a block header embedded in the witness by hash.
+func (c *witnessChain) GetHeaderByHash(hash common.Hash) *types.Header {
+	for _, header := range c.witness.Headers {
+		if header.Hash() == hash {
+			return header
+		}
+	}
+	return nil
+}
+
+// GetTd is not supported by witnesses, total difficulties are not embedded.
+func (c *witnessChain) GetTd(hash common.Hash, number uint64) *big.Int { return nil }
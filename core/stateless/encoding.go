@@ -0,0 +1,101 @@
+// Copyright 2022 The go-confero Authors
+// This file is part of the go-confero library.
+//
+// The go-confero library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-confero library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-confero library. If not, see <http://www.gnu.org/licenses/>.
+
+package stateless
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"sort"
+
+	"github.com/confero-network/go-confero/core/types"
+	"github.com/confero-network/go-confero/rlp"
+)
+
+// extWitness is a witness RLP encoding for transferring across clients. The
+// codes and state nodes are sorted to make the encoding deterministic.
+type extWitness struct {
+	Context *types.Header
+	Headers []*types.Header
+	Codes   [][]byte
+	State   [][]byte
+}
+
+// toExtWitness converts our internal witness representation to the consensus one.
+func (w *Witness) toExtWitness() *extWitness {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	ext := &extWitness{
+		Context: w.context,
+		Headers: w.Headers,
+		Codes:   make([][]byte, 0, len(w.Codes)),
+		State:   make([][]byte, 0, len(w.State)),
+	}
+	for code := range w.Codes {
+		ext.Codes = append(ext.Codes, []byte(code))
+	}
+	for node := range w.State {
+		ext.State = append(ext.State, []byte(node))
+	}
+	sort.Slice(ext.Codes, func(i, j int) bool { return bytes.Compare(ext.Codes[i], ext.Codes[j]) < 0 })
+	sort.Slice(ext.State, func(i, j int) bool { return bytes.Compare(ext.State[i], ext.State[j]) < 0 })
+	return ext
+}
+
+// fromExtWitness converts the consensus witness format into our internal one.
+func (w *Witness) fromExtWitness(ext *extWitness) error {
+	if ext.Context == nil {
+		return errors.New("witness context header missing")
+	}
+	if len(ext.Headers) == 0 {
+		return errors.New("witness parent header missing")
+	}
+	// Ensure the embedded headers form a chain leading up to the context
+	child := ext.Context
+	for i, header := range ext.Headers {
+		if header.Hash() != child.ParentHash {
+			return errors.New("witness headers not contiguous")
+		}
+		child = ext.Headers[i]
+	}
+	w.context, w.Headers = ext.Context, ext.Headers
+
+	w.Codes = make(map[string]struct{}, len(ext.Codes))
+	for _, code := range ext.Codes {
+		w.Codes[string(code)] = struct{}{}
+	}
+	w.State = make(map[string]struct{}, len(ext.State))
+	for _, node := range ext.State {
+		w.State[string(node)] = struct{}{}
+	}
+	return nil
+}
+
+// EncodeRLP serializes a witness as RLP.
+func (w *Witness) EncodeRLP(wr io.Writer) error {
+	return rlp.Encode(wr, w.toExtWitness())
+}
+
+// DecodeRLP decodes a witness from RLP.
+func (w *Witness) DecodeRLP(s *rlp.Stream) error {
+	var ext extWitness
+	if err := s.Decode(&ext); err != nil {
+		return err
+	}
+	return w.fromExtWitness(&ext)
+}
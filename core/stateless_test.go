@@ -0,0 +1,189 @@
+// Copyright 2022 The go-confero Authors
+// This file is part of the go-confero library.
+//
+// The go-confero library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-confero library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-confero library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/confero-network/go-confero/common"
+	"github.com/confero-network/go-confero/consensus/ethash"
+	"github.com/confero-network/go-confero/core/rawdb"
+	"github.com/confero-network/go-confero/core/stateless"
+	"github.com/confero-network/go-confero/core/types"
+	"github.com/confero-network/go-confero/core/vm"
+	"github.com/confero-network/go-confero/crypto"
+	"github.com/confero-network/go-confero/params"
+	"github.com/confero-network/go-confero/rlp"
+)
+
+// Tests that witnesses collected during block processing are enough to execute
+// the blocks statelessly, and that they survive an RLP roundtrip.
+func TestStatelessExecution(t *testing.T) {
+	var (
+		aa = common.HexToAddress("0x000000000000000000000000000000000000aaaa")
+		bb = common.HexToAddress("0x000000000000000000000000000000000000bbbb")
+
+		engine  = ethash.NewFaker()
+		key, _  = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+		address = crypto.PubkeyToAddress(key.PublicKey)
+		gspec   = &Genesis{
+			Config: params.TestChainConfig,
+			Alloc: GenesisAlloc{
+				address: {Balance: big.NewInt(1000000000000000000)},
+				// The address 0xAAAA stores the previous block hash at slot NUMBER,
+				// clears slot NUMBER-2 and stores the code size of 0xBBBB at slot 0.
+				aa: {
+					Code: append(append([]byte{
+						byte(vm.PUSH1), 1, byte(vm.NUMBER), byte(vm.SUB), byte(vm.BLOCKHASH), byte(vm.NUMBER), byte(vm.SSTORE),
+						byte(vm.PUSH1), 0, byte(vm.PUSH1), 2, byte(vm.NUMBER), byte(vm.SUB), byte(vm.SSTORE),
+						byte(vm.PUSH20)}, bb.Bytes()...),
+						byte(vm.EXTCODESIZE), byte(vm.PUSH1), 0, byte(vm.SSTORE), byte(vm.STOP),
+					),
+					Balance: big.NewInt(0),
+				},
+				bb: {
+					Code:    []byte{byte(vm.PC), byte(vm.STOP)},
+					Balance: big.NewInt(0),
+				},
+			},
+		}
+		db      = rawdb.NewMemoryDatabase()
+		genesis = gspec.MustCommit(db)
+		signer  = types.LatestSigner(gspec.Config)
+	)
+	blocks, _ := GenerateChain(gspec.Config, genesis, engine, db, 8, func(i int, b *BlockGen) {
+		tx, _ := types.SignTx(types.NewTransaction(b.TxNonce(address), aa, common.Big0, 100000, b.header.BaseFee, nil), signer, key)
+		b.AddTx(tx)
+	})
+	diskdb := rawdb.NewMemoryDatabase()
+	gspec.MustCommit(diskdb)
+
+	chain, err := NewBlockChain(diskdb, nil, gspec.Config, engine, vm.Config{}, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to create tester chain: %v", err)
+	}
+	defer chain.Stop()
+
+	if n, err := chain.InsertChain(blocks); err != nil {
+		t.Fatalf("block %d: failed to insert into chain: %v", n, err)
+	}
+	for _, block := range blocks {
+		parent := chain.GetHeader(block.ParentHash(), block.NumberU64()-1)
+		statedb, err := chain.StateAt(parent.Root)
+		if err != nil {
+			t.Fatalf("block %d: failed to open parent state: %v", block.NumberU64(), err)
+		}
+		witness, err := stateless.NewWitness(block.Header(), chain)
+		if err != nil {
+			t.Fatalf("block %d: failed to create witness: %v", block.NumberU64(), err)
+		}
+		statedb.EnableWitness(witness)
+
+		receipts, _, usedGas, err := chain.Processor().Process(block, statedb, vm.Config{})
+		if err != nil {
+			t.Fatalf("block %d: failed to process: %v", block.NumberU64(), err)
+		}
+		if err := chain.Validator().ValidateState(block, statedb, receipts, usedGas); err != nil {
+			t.Fatalf("block %d: failed to validate: %v", block.NumberU64(), err)
+		}
+		if len(witness.Codes) != 2 {
+			t.Errorf("block %d: codes mismatch: have %d, want 2", block.NumberU64(), len(witness.Codes))
+		}
+		// Roundtrip the witness through RLP and execute the block statelessly
+		blob, err := rlp.EncodeToBytes(witness)
+		if err != nil {
+			t.Fatalf("block %d: failed to encode witness: %v", block.NumberU64(), err)
+		}
+		decoded := new(stateless.Witness)
+		if err := rlp.DecodeBytes(blob, decoded); err != nil {
+			t.Fatalf("block %d: failed to decode witness: %v", block.NumberU64(), err)
+		}
+		if err := ExecuteStateless(gspec.Config, engine, block, decoded); err != nil {
+			t.Fatalf("block %d: stateless execution failed: %v", block.NumberU64(), err)
+		}
+		// The witness of another block must be rejected
+		if block.NumberU64() > 1 {
+			if err := ExecuteStateless(gspec.Config, engine, blocks[block.NumberU64()-2], decoded); err == nil {
+				t.Errorf("block %d: stateless execution succeeded with the witness of the next block", block.NumberU64()-1)
+			}
+		}
+		header := block.Header()
+		header.Extra = []byte("sibling")
+		sibling := types.NewBlockWithHeader(header).WithBody(block.Transactions(), block.Uncles())
+		if err := ExecuteStateless(gspec.Config, engine, sibling, decoded); err == nil {
+			t.Errorf("block %d: stateless execution succeeded with the witness of a sibling", block.NumberU64())
+		}
+		// Dropping any trie node from the witness must break the execution
+		for node := range decoded.State {
+			broken := decoded.Copy()
+			delete(broken.State, node)
+			if err := ExecuteStateless(gspec.Config, engine, block, broken); err == nil {
+				t.Errorf("block %d: stateless execution succeeded with missing node %x", block.NumberU64(), node)
+			}
+		}
+	}
+}
+
+// Tests that the witnesses collected during block import, if enabled, are
+// enough to execute the blocks statelessly.
+func TestWitnessCollection(t *testing.T) {
+	var (
+		engine  = ethash.NewFaker()
+		key, _  = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+		address = crypto.PubkeyToAddress(key.PublicKey)
+		gspec   = &Genesis{
+			Config: params.TestChainConfig,
+			Alloc:  GenesisAlloc{address: {Balance: big.NewInt(1000000000000000000)}},
+		}
+		db      = rawdb.NewMemoryDatabase()
+		genesis = gspec.MustCommit(db)
+		signer  = types.LatestSigner(gspec.Config)
+	)
+	blocks, _ := GenerateChain(gspec.Config, genesis, engine, db, 4, func(i int, b *BlockGen) {
+		tx, _ := types.SignTx(types.NewTransaction(b.TxNonce(address), common.Address{byte(i + 1)}, big.NewInt(1000), params.TxGas, b.header.BaseFee, nil), signer, key)
+		b.AddTx(tx)
+	})
+	for _, collect := range []bool{false, true} {
+		diskdb := rawdb.NewMemoryDatabase()
+		gspec.MustCommit(diskdb)
+
+		chain, err := NewBlockChain(diskdb, nil, gspec.Config, engine, vm.Config{EnableWitnessCollection: collect}, nil, nil)
+		if err != nil {
+			t.Fatalf("failed to create tester chain: %v", err)
+		}
+		if n, err := chain.InsertChain(blocks); err != nil {
+			t.Fatalf("block %d: failed to insert into chain: %v", n, err)
+		}
+		for _, block := range blocks {
+			witness := chain.GetWitness(block.Hash())
+			if !collect {
+				if witness != nil {
+					t.Errorf("block %d: witness collected while disabled", block.NumberU64())
+				}
+				continue
+			}
+			if witness == nil {
+				t.Fatalf("block %d: witness not collected", block.NumberU64())
+			}
+			if err := ExecuteStateless(gspec.Config, engine, block, witness); err != nil {
+				t.Fatalf("block %d: stateless execution failed: %v", block.NumberU64(), err)
+			}
+		}
+		chain.Stop()
+	}
+}
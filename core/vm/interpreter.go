@@ -30,6 +30,7 @@ type Config struct {
 	Tracer                  EVMLogger // Opcode logger
 	NoBaseFee               bool      // Forces the EIP-1559 baseFee to 0 (needed for 0 price calls)
 	EnablePreimageRecording bool      // Enables recording of SHA3/keccak preimages
+	EnableWitnessCollection bool      // Enables collecting stateless execution witnesses during block import
 
 	JumpTable *JumpTable // EVM instruction table, automatically populated if unset
 
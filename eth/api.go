@@ -33,7 +33,9 @@ import (
 	"github.com/confero-network/go-confero/core"
 	"github.com/confero-network/go-confero/core/rawdb"
 	"github.com/confero-network/go-confero/core/state"
+	"github.com/confero-network/go-confero/core/stateless"
 	"github.com/confero-network/go-confero/core/types"
+	"github.com/confero-network/go-confero/core/vm"
 	"github.com/confero-network/go-confero/internal/ethapi"
 	"github.com/confero-network/go-confero/log"
 	"github.com/confero-network/go-confero/rlp"
@@ -337,47 +339,81 @@ const AccountRangeMaxResults = 256
 
 // AccountRange enumerates all accounts in the given block and start point in paging request
 func (api *DebugAPI) AccountRange(blockNrOrHash rpc.BlockNumberOrHash, start hexutil.Bytes, maxResults int, nocode, nostorage, incompletes bool) (state.IteratorDump, error) {
-	var stateDb *state.StateDB
-	var err error
+	stateDb, err := api.dumpState(blockNrOrHash)
+	if err != nil {
+		return state.IteratorDump{}, err
+	}
+	return stateDb.IteratorDump(accountRangeConfig(start, maxResults, nocode, nostorage, incompletes)), nil
+}
+
+// AccountStream is the streaming variant of AccountRange. Instead of returning
+// a single batch, it dumps the whole state starting at the given key, sending
+// batches of at most maxResults accounts as separate notifications until all
+// accounts are delivered (signalled by an empty next key) or the subscription
+// is cancelled.
+func (api *DebugAPI) AccountStream(ctx context.Context, blockNrOrHash rpc.BlockNumberOrHash, start hexutil.Bytes, maxResults int, nocode, nostorage, incompletes bool) (*rpc.Subscription, error) {
+	notifier, supported := rpc.NotifierFromContext(ctx)
+	if !supported {
+		return &rpc.Subscription{}, rpc.ErrNotificationsUnsupported
+	}
+	stateDb, err := api.dumpState(blockNrOrHash)
+	if err != nil {
+		return nil, err
+	}
+	rpcSub := notifier.CreateSubscription()
 
+	go streamAccounts(stateDb, accountRangeConfig(start, maxResults, nocode, nostorage, incompletes), streamSender(notifier, rpcSub))
+	return rpcSub, nil
+}
+
+// streamAccounts dumps the accounts of the state in batches, passing each batch
+// to send until all accounts are delivered or send fails.
+func streamAccounts(stateDb *state.StateDB, opts *state.DumpConfig, send func(interface{}) error) {
+	for {
+		batch := stateDb.IteratorDump(opts)
+		if err := send(batch); err != nil || batch.Next == nil {
+			return
+		}
+		opts.Start = batch.Next
+	}
+}
+
+// dumpState retrieves the state to dump for the given block.
+func (api *DebugAPI) dumpState(blockNrOrHash rpc.BlockNumberOrHash) (*state.StateDB, error) {
 	if number, ok := blockNrOrHash.Number(); ok {
 		if number == rpc.PendingBlockNumber {
 			// If we're dumping the pending state, we need to request
 			// both the pending block as well as the pending state from
 			// the miner and operate on those
-			_, stateDb = api.eth.miner.Pending()
+			_, stateDb := api.eth.miner.Pending()
+			return stateDb, nil
+		}
+		var block *types.Block
+		if number == rpc.LatestBlockNumber {
+			block = api.eth.blockchain.CurrentBlock()
+		} else if number == rpc.FinalizedBlockNumber {
+			block = api.eth.blockchain.CurrentFinalizedBlock()
+		} else if number == rpc.SafeBlockNumber {
+			block = api.eth.blockchain.CurrentSafeBlock()
 		} else {
-			var block *types.Block
-			if number == rpc.LatestBlockNumber {
-				block = api.eth.blockchain.CurrentBlock()
-			} else if number == rpc.FinalizedBlockNumber {
-				block = api.eth.blockchain.CurrentFinalizedBlock()
-			} else if number == rpc.SafeBlockNumber {
-				block = api.eth.blockchain.CurrentSafeBlock()
-			} else {
-				block = api.eth.blockchain.GetBlockByNumber(uint64(number))
-			}
-			if block == nil {
-				return state.IteratorDump{}, fmt.Errorf("block #%d not found", number)
-			}
-			stateDb, err = api.eth.BlockChain().StateAt(block.Root())
-			if err != nil {
-				return state.IteratorDump{}, err
-			}
+			block = api.eth.blockchain.GetBlockByNumber(uint64(number))
 		}
+		if block == nil {
+			return nil, fmt.Errorf("block #%d not found", number)
+		}
+		return api.eth.BlockChain().StateAt(block.Root())
 	} else if hash, ok := blockNrOrHash.Hash(); ok {
 		block := api.eth.blockchain.GetBlockByHash(hash)
 		if block == nil {
-			return state.IteratorDump{}, fmt.Errorf("block %s not found", hash.Hex())
-		}
-		stateDb, err = api.eth.BlockChain().StateAt(block.Root())
-		if err != nil {
-			return state.IteratorDump{}, err
+			return nil, fmt.Errorf("block %s not found", hash.Hex())
 		}
-	} else {
-		return state.IteratorDump{}, errors.New("either block number or block hash must be specified")
+		return api.eth.BlockChain().StateAt(block.Root())
 	}
+	return nil, errors.New("either block number or block hash must be specified")
+}
 
+// accountRangeConfig assembles the dump options of an account range request.
+func accountRangeConfig(start hexutil.Bytes, maxResults int, nocode, nostorage, incompletes bool) *state.DumpConfig {
 	opts := &state.DumpConfig{
 		SkipCode:          nocode,
 		SkipStorage:       nostorage,
@@ -388,7 +424,38 @@ func (api *DebugAPI) AccountRange(blockNrOrHash rpc.BlockNumberOrHash, start hex
 	if maxResults > AccountRangeMaxResults || maxResults <= 0 {
 		opts.Max = AccountRangeMaxResults
 	}
-	return stateDb.IteratorDump(opts), nil
+	return opts
+}
+
+// errStreamClosed is returned when sending a batch of a streaming dump whose
+// subscription is gone.
+var errStreamClosed = errors.New("stream closed")
+
+// streamSender returns the function sending the batches of a streaming dump as
+// notifications of the subscription.
+func streamSender(notifier *rpc.Notifier, sub *rpc.Subscription) func(interface{}) error {
+	return func(batch interface{}) error {
+		if err := notifier.Notify(sub.ID, batch); err != nil {
+			return err
+		}
+		if streamClosed(notifier, sub) {
+			return errStreamClosed
+		}
+		return nil
+	}
+}
+
+// streamClosed reports whether a streaming dump subscription was cancelled or
+// its connection dropped.
+func streamClosed(notifier *rpc.Notifier, sub *rpc.Subscription) bool {
+	select {
+	case <-sub.Err():
+		return true
+	case <-notifier.Closed():
+		return true
+	default:
+		return false
+	}
 }
 
 // StorageRangeResult is the result of a debug_storageRangeAt API call.
@@ -415,32 +482,83 @@ func (api *DebugAPI) StorageRangeAt(blockHash common.Hash, txIndex int, contract
 	if err != nil {
 		return StorageRangeResult{}, err
 	}
-	st := statedb.StorageTrie(contractAddress)
-	if st == nil {
+	if !statedb.Exist(contractAddress) {
 		return StorageRangeResult{}, fmt.Errorf("account %x doesn't exist", contractAddress)
 	}
-	return storageRangeAt(st, keyStart, maxResult)
+	return storageRangeAt(statedb, contractAddress, keyStart, maxResult)
 }
 
-func storageRangeAt(st state.Trie, start []byte, maxResult int) (StorageRangeResult, error) {
-	it := trie.NewIterator(st.NodeIterator(start))
-	result := StorageRangeResult{Storage: storageMap{}}
-	for i := 0; i < maxResult && it.Next(); i++ {
-		_, content, _, err := rlp.Split(it.Value)
+// StorageStream is the streaming variant of StorageRangeAt. It sends the storage
+// of the contract at the given block height and transaction index, starting at
+// the given key, in batches of at most maxResult slots as separate notifications
+// until all slots are delivered (signalled by a nil next key) or the
+// subscription is cancelled.
+func (api *DebugAPI) StorageStream(ctx context.Context, blockHash common.Hash, txIndex int, contractAddress common.Address, keyStart hexutil.Bytes, maxResult int) (*rpc.Subscription, error) {
+	notifier, supported := rpc.NotifierFromContext(ctx)
+	if !supported {
+		return &rpc.Subscription{}, rpc.ErrNotificationsUnsupported
+	}
+	if maxResult <= 0 {
+		return nil, errors.New("batch size must be positive")
+	}
+	block := api.eth.blockchain.GetBlockByHash(blockHash)
+	if block == nil {
+		return nil, fmt.Errorf("block %#x not found", blockHash)
+	}
+	_, _, statedb, err := api.eth.stateAtTransaction(block, txIndex, 0)
+	if err != nil {
+		return nil, err
+	}
+	if !statedb.Exist(contractAddress) {
+		return nil, fmt.Errorf("account %x doesn't exist", contractAddress)
+	}
+	rpcSub := notifier.CreateSubscription()
+
+	go func() {
+		if err := streamStorage(statedb, contractAddress, keyStart, maxResult, streamSender(notifier, rpcSub)); err != nil {
+			log.Warn("Storage stream failed", "block", blockHash, "account", contractAddress, "err", err)
+		}
+	}()
+	return rpcSub, nil
+}
+
+// streamStorage retrieves the storage of the given account in batches of
+// maxResult slots, passing each batch to send until all slots are delivered or
+// send fails.
+func streamStorage(statedb *state.StateDB, addr common.Address, start []byte, maxResult int, send func(interface{}) error) error {
+	for {
+		batch, err := storageRangeAt(statedb, addr, start, maxResult)
 		if err != nil {
-			return StorageRangeResult{}, err
+			return err
 		}
-		e := storageEntry{Value: common.BytesToHash(content)}
-		if preimage := st.GetKey(it.Key); preimage != nil {
-			preimage := common.BytesToHash(preimage)
-			e.Key = &preimage
+		if err := send(batch); err != nil || batch.NextKey == nil {
+			return nil
 		}
-		result.Storage[common.BytesToHash(it.Key)] = e
+		start = batch.NextKey.Bytes()
 	}
-	// Add the 'next key' so clients can continue downloading.
-	if it.Next() {
-		next := common.BytesToHash(it.Key)
-		result.NextKey = &next
+}
+
+// storageRangeAt retrieves a batch of maxResult storage slots of the given
+// account, starting at the given hashed key. The slots are read from the state
+// snapshot if the storage is untouched by the replayed transactions.
+func storageRangeAt(statedb *state.StateDB, addr common.Address, start []byte, maxResult int) (StorageRangeResult, error) {
+	result := StorageRangeResult{Storage: storageMap{}}
+	err := statedb.IterateStorage(addr, start, func(hash common.Hash, key []byte, value common.Hash) bool {
+		// Add the 'next key' so clients can continue downloading.
+		if len(result.Storage) >= maxResult {
+			result.NextKey = &hash
+			return false
+		}
+		e := storageEntry{Value: value}
+		if key != nil {
+			preimage := common.BytesToHash(key)
+			e.Key = &preimage
+		}
+		result.Storage[hash] = e
+		return true
+	})
+	if err != nil {
+		return StorageRangeResult{}, err
 	}
 	return result, nil
 }
@@ -588,3 +706,215 @@ func (api *DebugAPI) GetAccessibleState(from, to rpc.BlockNumber) (uint64, error
 	}
 	return 0, errors.New("no state found")
 }
+
+// ExecutionWitness returns the RLP encoded witness of all the state accessed by
+// the given block, which is enough to execute the block without a state database
+// (see core.ExecuteStateless). The witness collected during import is returned
+// if available, otherwise the block is re-executed on top of its parent state.
+func (api *DebugAPI) ExecutionWitness(ctx context.Context, blockNrOrHash rpc.BlockNumberOrHash) (hexutil.Bytes, error) {
+	if number, ok := blockNrOrHash.Number(); ok && number == rpc.PendingBlockNumber {
+		return nil, errors.New("witness of the pending block is not supported")
+	}
+	block, err := api.eth.APIBackend.BlockByNumberOrHash(ctx, blockNrOrHash)
+	if err != nil {
+		return nil, err
+	}
+	if block == nil {
+		return nil, errors.New("block not found")
+	}
+	if block.NumberU64() == 0 {
+		return nil, errors.New("genesis is not executable")
+	}
+	bc := api.eth.BlockChain()
+	if witness := bc.GetWitness(block.Hash()); witness != nil {
+		return rlp.EncodeToBytes(witness)
+	}
+	parent := bc.GetHeader(block.ParentHash(), block.NumberU64()-1)
+	if parent == nil {
+		return nil, fmt.Errorf("parent %x not found", block.ParentHash())
+	}
+	statedb, err := bc.StateAt(parent.Root)
+	if err != nil {
+		return nil, err
+	}
+	witness, err := stateless.NewWitness(block.Header(), bc)
+	if err != nil {
+		return nil, err
+	}
+	statedb.EnableWitness(witness)
+
+	receipts, _, usedGas, err := bc.Processor().Process(block, statedb, vm.Config{})
+	if err != nil {
+		return nil, err
+	}
+	if err := bc.Validator().ValidateState(block, statedb, receipts, usedGas); err != nil {
+		return nil, err
+	}
+	if err := statedb.Error(); err != nil {
+		return nil, err
+	}
+	return rlp.EncodeToBytes(witness)
+}
+
+// stateDiffReexec is the number of blocks the state diff replay is willing to go
+// back and re-execute to produce the missing historical state.
+const stateDiffReexec = uint64(128)
+
+// BlockStateDiff is the state diff of a single block. Reorg is set on a live
+// diff whose block is not a descendant of the previously delivered one, Gap on
+// a live diff whose block descends from it, but the diffs of the blocks in
+// between are missing, e.g. because their state was already known and they
+// were not executed.
+type BlockStateDiff struct {
+	Number     hexutil.Uint64 `json:"number"`
+	Hash       common.Hash    `json:"hash"`
+	ParentHash common.Hash    `json:"parentHash"`
+	Reorg      bool           `json:"reorg,omitempty"`
+	Gap        bool           `json:"gap,omitempty"`
+	*state.StateDiff
+}
+
+func newBlockStateDiff(block *types.Block, diff *state.StateDiff) *BlockStateDiff {
+	return &BlockStateDiff{
+		Number:     hexutil.Uint64(block.NumberU64()),
+		Hash:       block.Hash(),
+		ParentHash: block.ParentHash(),
+		StateDiff:  diff,
+	}
+}
+
+// StateDiff re-executes the given block on top of its parent state and returns
+// the accounts changed by it, along with their values before and after.
+func (api *DebugAPI) StateDiff(ctx context.Context, blockNrOrHash rpc.BlockNumberOrHash) (*BlockStateDiff, error) {
+	if number, ok := blockNrOrHash.Number(); ok && number == rpc.PendingBlockNumber {
+		return nil, errors.New("state diff of the pending block is not supported")
+	}
+	block, err := api.eth.APIBackend.BlockByNumberOrHash(ctx, blockNrOrHash)
+	if err != nil {
+		return nil, err
+	}
+	if block == nil {
+		return nil, errors.New("block not found")
+	}
+	diff, _, err := api.eth.stateDiffAtBlock(block, stateDiffReexec, nil)
+	if err != nil {
+		return nil, err
+	}
+	return newBlockStateDiff(block, diff), nil
+}
+
+// StateDiffs creates a subscription that fires with the state diff of every
+// block imported from now on, including the blocks of a reorg, which are marked
+// as such. If fromBlock is given, the diffs of the canonical blocks starting at
+// it are replayed first.
+func (api *DebugAPI) StateDiffs(ctx context.Context, fromBlock *rpc.BlockNumber) (*rpc.Subscription, error) {
+	notifier, supported := rpc.NotifierFromContext(ctx)
+	if !supported {
+		return &rpc.Subscription{}, rpc.ErrNotificationsUnsupported
+	}
+	var next uint64 // First canonical block to replay
+	if fromBlock != nil {
+		switch {
+		case *fromBlock == rpc.PendingBlockNumber:
+			return nil, errors.New("state diff of the pending block is not supported")
+		case *fromBlock < 0:
+			next = api.eth.blockchain.CurrentBlock().NumberU64()
+		default:
+			next = uint64(*fromBlock)
+		}
+		if next == 0 {
+			next = 1 // Genesis has no diff
+		}
+	}
+	rpcSub := notifier.CreateSubscription()
+
+	go func() {
+		// Replay the canonical blocks until the head, along with any blocks
+		// imported in the meantime once subscribed to the live diffs. The latter
+		// are remembered to skip their live diffs.
+		var (
+			statedb  *state.StateDB
+			last     common.Hash // Hash of the last delivered block
+			lastNum  uint64      // Number of the last delivered block
+			replayed map[common.Hash]struct{}
+		)
+		replay := func() bool {
+			for ; next > 0 && next <= api.eth.blockchain.CurrentBlock().NumberU64(); next++ {
+				block := api.eth.blockchain.GetBlockByNumber(next)
+				if block == nil {
+					log.Warn("State diff replay failed", "number", next, "err", "block not found")
+					return false
+				}
+				var (
+					diff *state.StateDiff
+					err  error
+				)
+				if diff, statedb, err = api.eth.stateDiffAtBlock(block, stateDiffReexec, statedb); err != nil {
+					log.Warn("State diff replay failed", "number", next, "err", err)
+					return false
+				}
+				if err := notifier.Notify(rpcSub.ID, newBlockStateDiff(block, diff)); err != nil || streamClosed(notifier, rpcSub) {
+					return false
+				}
+				if last, lastNum = block.Hash(), next; replayed != nil {
+					replayed[last] = struct{}{}
+				}
+			}
+			return true
+		}
+		if !replay() {
+			return
+		}
+		diffs := make(chan core.StateDiffEvent, 16)
+		diffsSub := api.eth.blockchain.SubscribeStateDiffEvent(diffs)
+		defer diffsSub.Unsubscribe()
+
+		replayed = make(map[common.Hash]struct{})
+		if !replay() {
+			return
+		}
+		for {
+			select {
+			case ev := <-diffs:
+				// Skip the blocks already delivered by the replay
+				hash := ev.Block.Hash()
+				if _, ok := replayed[hash]; ok {
+					delete(replayed, hash)
+					continue
+				}
+				res := newBlockStateDiff(ev.Block, ev.Diff)
+				if last != (common.Hash{}) && ev.Block.ParentHash() != last {
+					if api.descends(ev.Block, last, lastNum) {
+						res.Gap = true
+					} else {
+						res.Reorg = true
+					}
+				}
+				last, lastNum = hash, ev.Block.NumberU64()
+
+				if err := notifier.Notify(rpcSub.ID, res); err != nil {
+					log.Debug("State diff delivery failed", "err", err)
+					return
+				}
+			case <-diffsSub.Err():
+				return
+			case <-rpcSub.Err():
+				return
+			case <-notifier.Closed():
+				return
+			}
+		}
+	}()
+	return rpcSub, nil
+}
+
+// descends reports whether the given block is a descendant of the block with
+// the given hash and number.
+func (api *DebugAPI) descends(block *types.Block, hash common.Hash, number uint64) bool {
+	if block.NumberU64() <= number {
+		return false
+	}
+	maxNonCanonical := stateDiffReexec
+	ancestor, _ := api.eth.blockchain.GetAncestor(block.Hash(), block.NumberU64(), block.NumberU64()-number, &maxNonCanonical)
+	return ancestor == hash
+}
@@ -242,6 +242,10 @@ func (b *EthAPIBackend) SubscribeChainSideEvent(ch chan<- core.ChainSideEvent) e
 	return b.eth.BlockChain().SubscribeChainSideEvent(ch)
 }
 
+func (b *EthAPIBackend) SubscribeFinalityEvent(ch chan<- core.FinalityEvent) event.Subscription {
+	return b.eth.BlockChain().SubscribeFinalityEvent(ch)
+}
+
 func (b *EthAPIBackend) SubscribeLogsEvent(ch chan<- []*types.Log) event.Subscription {
 	return b.eth.BlockChain().SubscribeLogsEvent(ch)
 }
@@ -341,6 +345,16 @@ func (b *EthAPIBackend) BloomStatus() (uint64, uint64) {
 	return params.BloomBitsBlocks, sections
 }
 
+// LogIndexStatus returns the section size of the log index and the number of
+// indexed sections, which is zero if the index is disabled.
+func (b *EthAPIBackend) LogIndexStatus() (uint64, uint64) {
+	if b.eth.logIndexer == nil {
+		return params.BloomBitsBlocks, 0
+	}
+	sections, _, _ := b.eth.logIndexer.Sections()
+	return params.BloomBitsBlocks, sections
+}
+
 func (b *EthAPIBackend) ServiceFilter(ctx context.Context, session *bloombits.MatcherSession) {
 	for i := 0; i < bloomFilterThreads; i++ {
 		go session.Multiplex(bloomRetrievalBatch, bloomRetrievalWait, b.eth.bloomRequests)
@@ -18,17 +18,27 @@ package eth
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"math/big"
 	"reflect"
 	"sort"
 	"testing"
+	"time"
 
 	"github.com/davecgh/go-spew/spew"
 	"github.com/confero-network/go-confero/common"
+	"github.com/confero-network/go-confero/common/hexutil"
+	"github.com/confero-network/go-confero/consensus/ethash"
+	"github.com/confero-network/go-confero/core"
 	"github.com/confero-network/go-confero/core/rawdb"
 	"github.com/confero-network/go-confero/core/state"
+	"github.com/confero-network/go-confero/core/state/snapshot"
+	"github.com/confero-network/go-confero/core/vm"
 	"github.com/confero-network/go-confero/crypto"
+	"github.com/confero-network/go-confero/params"
+	"github.com/confero-network/go-confero/rlp"
+	"github.com/confero-network/go-confero/rpc"
 	"github.com/confero-network/go-confero/trie"
 )
 
@@ -209,7 +219,7 @@ func TestStorageRangeAt(t *testing.T) {
 		},
 	}
 	for _, test := range tests {
-		result, err := storageRangeAt(state.StorageTrie(addr), test.start, test.limit)
+		result, err := storageRangeAt(state, addr, test.start, test.limit)
 		if err != nil {
 			t.Error(err)
 		}
@@ -219,3 +229,237 @@ func TestStorageRangeAt(t *testing.T) {
 		}
 	}
 }
+
+// newStreamTester creates a chain of one block on top of a genesis with the
+// given accounts and storage of the contract, and an RPC client of the debug
+// API serving it.
+// The stream tester puts an account and a storage slot of the contract into the
+// state snapshot, but not into the tries. The streams only deliver them if they
+// are served from the snapshot.
+var (
+	snapOnlyAccount   = common.Address{0x5a}
+	snapOnlySlot      = common.HexToHash("0x5a")
+	snapOnlySlotValue = common.HexToHash("0x5a5a")
+)
+
+func newStreamTester(t *testing.T, accounts []common.Address, contract common.Address, storage map[common.Hash]common.Hash) (*core.BlockChain, *rpc.Client) {
+	var (
+		db    = rawdb.NewMemoryDatabase()
+		alloc = core.GenesisAlloc{contract: {Balance: big.NewInt(1), Code: []byte{0x00}, Storage: storage}}
+	)
+	for _, addr := range accounts {
+		alloc[addr] = core.GenesisAccount{Balance: big.NewInt(1)}
+	}
+	gspec := &core.Genesis{Config: params.TestChainConfig, Alloc: alloc}
+	genesis := gspec.MustCommit(db)
+
+	// The account dump needs the preimages of the account keys.
+	cacheConfig := &core.CacheConfig{TrieCleanLimit: 16, TrieDirtyLimit: 16, TrieTimeLimit: time.Minute, Preimages: true, SnapshotLimit: 16, SnapshotWait: true}
+	chain, err := core.NewBlockChain(db, cacheConfig, gspec.Config, ethash.NewFaker(), vm.Config{}, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to create chain: %v", err)
+	}
+	if chain.Snapshots() == nil || chain.Snapshots().Snapshot(genesis.Root()) == nil {
+		t.Fatal("state snapshot not available")
+	}
+	accountHash := crypto.Keccak256Hash(snapOnlyAccount.Bytes())
+	rawdb.WritePreimages(db, map[common.Hash][]byte{accountHash: snapOnlyAccount.Bytes()})
+	rawdb.WriteAccountSnapshot(db, accountHash, snapshot.SlimAccountRLP(0, big.NewInt(1), common.Hash{}, crypto.Keccak256(nil)))
+	slot, _ := rlp.EncodeToBytes(common.TrimLeftZeroes(snapOnlySlotValue[:]))
+	rawdb.WriteStorageSnapshot(db, crypto.Keccak256Hash(contract.Bytes()), crypto.Keccak256Hash(snapOnlySlot.Bytes()), slot)
+	blocks, _ := core.GenerateChain(gspec.Config, genesis, ethash.NewFaker(), db, 1, nil)
+	if _, err := chain.InsertChain(blocks); err != nil {
+		t.Fatalf("failed to insert chain: %v", err)
+	}
+	server := rpc.NewServer()
+	if err := server.RegisterName("debug", NewDebugAPI(&Confero{blockchain: chain, chainDb: db})); err != nil {
+		t.Fatalf("failed to register API: %v", err)
+	}
+	client := rpc.DialInProc(server)
+	t.Cleanup(func() {
+		client.Close()
+		server.Stop()
+		chain.Stop()
+	})
+	return chain, client
+}
+
+// sortedHashes returns the hashes of the given keys in the order of the trie.
+func sortedHashes(keys [][]byte) []common.Hash {
+	hashes := make([]common.Hash, len(keys))
+	for i, key := range keys {
+		hashes[i] = crypto.Keccak256Hash(key)
+	}
+	sort.Slice(hashes, func(i, j int) bool { return bytes.Compare(hashes[i][:], hashes[j][:]) < 0 })
+	return hashes
+}
+
+func TestAccountStream(t *testing.T) {
+	t.Parallel()
+
+	var (
+		accounts = make([]common.Address, 10)
+		keys     = make([][]byte, 0, len(accounts)+1)
+		contract = common.Address{0xcc}
+	)
+	for i := range accounts {
+		accounts[i] = common.BytesToAddress([]byte{byte(i + 1)})
+		keys = append(keys, accounts[i].Bytes())
+	}
+	keys = append(keys, contract.Bytes(), snapOnlyAccount.Bytes())
+	hashes := sortedHashes(keys)
+
+	_, client := newStreamTester(t, accounts, contract, nil)
+	genesis := rpc.BlockNumberOrHashWithNumber(0)
+
+	tests := []struct {
+		start   []byte
+		max     int
+		batches int
+		want    int // number of accounts delivered
+	}{
+		{nil, 3, 4, len(keys)},                   // full iteration
+		{hashes[6].Bytes(), 2, 3, len(keys) - 6}, // start key and limit
+	}
+	for i, test := range tests {
+		var (
+			ch   = make(chan state.IteratorDump)
+			seen = make(map[common.Address]bool)
+		)
+		sub, err := client.Subscribe(context.Background(), "debug", ch, "accountStream", genesis, hexutil.Bytes(test.start), test.max, true, true, false)
+		if err != nil {
+			t.Fatalf("test %d: failed to subscribe: %v", i, err)
+		}
+		var batches int
+		for last := false; !last; batches++ {
+			select {
+			case batch := <-ch:
+				if len(batch.Accounts) > test.max {
+					t.Fatalf("test %d: batch too large: have %d, want at most %d", i, len(batch.Accounts), test.max)
+				}
+				for addr := range batch.Accounts {
+					if seen[addr] {
+						t.Fatalf("test %d: account %x delivered twice", i, addr)
+					}
+					seen[addr] = true
+				}
+				last = batch.Next == nil
+			case err := <-sub.Err():
+				t.Fatalf("test %d: subscription failed: %v", i, err)
+			case <-time.After(5 * time.Second):
+				t.Fatalf("test %d: stream stalled", i)
+			}
+		}
+		sub.Unsubscribe()
+		if test.start == nil && !seen[snapOnlyAccount] {
+			t.Errorf("test %d: accounts not streamed from the snapshot", i)
+		}
+		if batches != test.batches || len(seen) != test.want {
+			t.Errorf("test %d: wrong stream: have %d batches of %d accounts, want %d of %d", i, batches, len(seen), test.batches, test.want)
+		}
+	}
+}
+
+func TestStorageStream(t *testing.T) {
+	t.Parallel()
+
+	var (
+		contract = common.Address{0xcc}
+		storage  = make(map[common.Hash]common.Hash)
+		values   = make(map[common.Hash]common.Hash) // values by hashed key
+		keys     [][]byte
+	)
+	for i := 0; i < 10; i++ {
+		key := common.BytesToHash([]byte{byte(i + 1)})
+		storage[key] = common.BytesToHash([]byte{0xff, byte(i + 1)})
+		values[crypto.Keccak256Hash(key.Bytes())] = storage[key]
+		keys = append(keys, key.Bytes())
+	}
+	values[crypto.Keccak256Hash(snapOnlySlot.Bytes())] = snapOnlySlotValue
+	keys = append(keys, snapOnlySlot.Bytes())
+	hashes := sortedHashes(keys)
+
+	chain, client := newStreamTester(t, nil, contract, storage)
+	block := chain.CurrentBlock().Hash()
+
+	tests := []struct {
+		start   []byte
+		max     int
+		batches int
+		first   common.Hash // first slot delivered
+	}{
+		{nil, 3, 4, hashes[0]},               // full iteration
+		{hashes[4].Bytes(), 3, 3, hashes[4]}, // start key and limit
+	}
+	for i, test := range tests {
+		var (
+			ch   = make(chan StorageRangeResult)
+			seen []common.Hash
+		)
+		sub, err := client.Subscribe(context.Background(), "debug", ch, "storageStream", block, 0, contract, hexutil.Bytes(test.start), test.max)
+		if err != nil {
+			t.Fatalf("test %d: failed to subscribe: %v", i, err)
+		}
+		var batches int
+		for last := false; !last; batches++ {
+			select {
+			case batch := <-ch:
+				if len(batch.Storage) > test.max {
+					t.Fatalf("test %d: batch too large: have %d, want at most %d", i, len(batch.Storage), test.max)
+				}
+				for hash, entry := range batch.Storage {
+					if values[hash] != entry.Value {
+						t.Fatalf("test %d: wrong slot %x: %v", i, hash, entry)
+					}
+					seen = append(seen, hash)
+				}
+				last = batch.NextKey == nil
+			case err := <-sub.Err():
+				t.Fatalf("test %d: subscription failed: %v", i, err)
+			case <-time.After(5 * time.Second):
+				t.Fatalf("test %d: stream stalled", i)
+			}
+		}
+		sub.Unsubscribe()
+		sort.Slice(seen, func(i, j int) bool { return bytes.Compare(seen[i][:], seen[j][:]) < 0 })
+		if batches != test.batches || !reflect.DeepEqual(seen, hashes[len(hashes)-len(seen):]) || seen[0] != test.first {
+			t.Errorf("test %d: wrong stream: have %d batches of slots %x, want %d batches from %x", i, batches, seen, test.batches, test.first)
+		}
+	}
+}
+
+// Tests that the streaming dumps stop once their subscription is gone.
+func TestStreamCancellation(t *testing.T) {
+	t.Parallel()
+
+	var (
+		statedb, _ = state.New(common.Hash{}, state.NewDatabaseWithConfig(rawdb.NewMemoryDatabase(), &trie.Config{Preimages: true}), nil)
+		contract   = common.Address{0xcc}
+	)
+	statedb.SetBalance(contract, big.NewInt(1))
+	for i := 0; i < 10; i++ {
+		statedb.SetBalance(common.BytesToAddress([]byte{byte(i + 1)}), big.NewInt(1))
+		statedb.SetState(contract, common.BytesToHash([]byte{byte(i + 1)}), common.Hash{0x01})
+	}
+	statedb.Commit(true)
+
+	// The subscription is gone after the second batch.
+	var sent int
+	send := func(interface{}) error {
+		if sent++; sent == 2 {
+			return errStreamClosed
+		}
+		return nil
+	}
+	streamAccounts(statedb, &state.DumpConfig{Max: 1}, send)
+	if sent != 2 {
+		t.Errorf("account stream sent %d batches after cancellation, want 2", sent)
+	}
+	sent = 0
+	if err := streamStorage(statedb, contract, nil, 1, send); err != nil {
+		t.Fatalf("storage stream failed: %v", err)
+	}
+	if sent != 2 {
+		t.Errorf("storage stream sent %d batches after cancellation, want 2", sent)
+	}
+}
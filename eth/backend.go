@@ -83,6 +83,7 @@ type Confero struct {
 
 	bloomRequests     chan chan *bloombits.Retrieval // Channel receiving bloom data retrieval requests
 	bloomIndexer      *core.ChainIndexer             // Bloom indexer operating during block imports
+	logIndexer        *core.ChainIndexer             // Log indexer operating during block imports, nil if disabled
 	closeBloomHandler chan struct{}
 
 	APIBackend *EthAPIBackend
@@ -131,7 +132,20 @@ func New(stack *node.Node, config *ethconfig.Config) (*Confero, error) {
 	ethashConfig.NotifyFull = config.Miner.NotifyFull
 
 	// Assemble the Confero object
-	chainDb, err := stack.OpenDatabaseWithFreezer("chaindata", config.DatabaseCache, config.DatabaseHandles, config.DatabaseFreezer, "eth/db/chaindata/", false)
+	var (
+		chainDb ethdb.Database
+		err     error
+	)
+	switch {
+	case config.DatabaseAncients != "":
+		log.Info("Using remote ancient store", "endpoint", config.DatabaseAncients)
+		chainDb, err = stack.OpenDatabaseWithRemoteAncients("chaindata", config.DatabaseCache, config.DatabaseHandles, config.DatabaseFreezer, config.DatabaseAncients, "eth/db/chaindata/", false)
+	case config.DatabaseEra != "":
+		log.Info("Using era history as ancient store", "dir", config.DatabaseEra)
+		chainDb, err = stack.OpenDatabaseWithEraAncients("chaindata", config.DatabaseCache, config.DatabaseHandles, config.DatabaseFreezer, config.DatabaseEra, "eth/db/chaindata/", false)
+	default:
+		chainDb, err = stack.OpenDatabaseWithFreezer("chaindata", config.DatabaseCache, config.DatabaseHandles, config.DatabaseFreezer, "eth/db/chaindata/", false)
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -188,6 +202,7 @@ func New(stack *node.Node, config *ethconfig.Config) (*Confero, error) {
 	var (
 		vmConfig = vm.Config{
 			EnablePreimageRecording: config.EnablePreimageRecording,
+			EnableWitnessCollection: config.EnableWitnessCollection,
 		}
 		cacheConfig = &core.CacheConfig{
 			TrieCleanLimit:      config.TrieCleanCache,
@@ -212,6 +227,10 @@ func New(stack *node.Node, config *ethconfig.Config) (*Confero, error) {
 		rawdb.WriteChainConfig(chainDb, genesisHash, chainConfig)
 	}
 	eth.bloomIndexer.Start(eth.blockchain)
+	if config.LogIndex {
+		eth.logIndexer = core.NewLogIndexer(chainDb, params.BloomBitsBlocks, params.BloomConfirms)
+		eth.logIndexer.Start(eth.blockchain)
+	}
 
 	if config.TxPool.Journal != "" {
 		config.TxPool.Journal = stack.ResolvePath(config.TxPool.Journal)
@@ -235,6 +254,14 @@ func New(stack *node.Node, config *ethconfig.Config) (*Confero, error) {
 		EventMux:       eth.eventMux,
 		Checkpoint:     checkpoint,
 		RequiredBlocks: config.RequiredBlocks,
+		SyncFrom:       config.SyncFrom,
+		SnapServing: snap.NewServingQueue(snap.ServingConfig{
+			Disabled:  config.SnapNoServe,
+			Threads:   config.SnapServeThreads,
+			PeerShare: config.SnapServePeerShare,
+			MaxQueued: config.SnapServeMaxQueued,
+			MaxWait:   config.SnapServeMaxWait,
+		}),
 	}); err != nil {
 		return nil, err
 	}
@@ -509,7 +536,8 @@ func (s *Confero) SyncMode() downloader.SyncMode {
 // network protocols to start.
 func (s *Confero) Protocols() []p2p.Protocol {
 	protos := eth.MakeProtocols((*ethHandler)(s.handler), s.networkID, s.ethDialCandidates)
-	if s.config.SnapshotCache > 0 {
+	// Nodes which don't serve snap requests only run the protocol to snap sync.
+	if s.config.SnapshotCache > 0 && (!s.config.SnapNoServe || atomic.LoadUint32(&s.handler.snapSync) == 1) {
 		protos = append(protos, snap.MakeProtocols((*snapHandler)(s.handler), s.snapDialCandidates)...)
 	}
 	return protos
@@ -550,6 +578,9 @@ func (s *Confero) Stop() error {
 	// Then stop everything else.
 	s.bloomIndexer.Close()
 	close(s.closeBloomHandler)
+	if s.logIndexer != nil {
+		s.logIndexer.Close()
+	}
 	s.txPool.Stop()
 	s.miner.Close()
 	s.blockchain.Stop()
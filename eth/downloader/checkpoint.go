@@ -0,0 +1,348 @@
+// Copyright 2022 The go-confero Authors
+// This file is part of the go-confero library.
+//
+// The go-confero library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-confero library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-confero library. If not, see <http://www.gnu.org/licenses/>.
+
+package downloader
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"sync/atomic"
+	"time"
+
+	"github.com/confero-network/go-confero/common"
+	"github.com/confero-network/go-confero/core/rawdb"
+	"github.com/confero-network/go-confero/core/types"
+	"github.com/confero-network/go-confero/log"
+)
+
+// historyRetryInterval is the time to wait before asking the peers again if
+// none of them could deliver the requested blocks.
+const historyRetryInterval = 3 * time.Second
+
+// historyWindow is the number of blocks retrieved at once when backfilling the
+// history. The blocks of a window are held in memory until all of them arrived,
+// as the chain is extended from the newest block to the oldest.
+var historyWindow = 8 * MaxHeaderFetch
+
+// errHistoryUnavailable is returned if a peer doesn't have the requested blocks.
+var errHistoryUnavailable = errors.New("history unavailable")
+
+// CheckpointSync makes a trusted checkpoint block the head of an empty chain.
+// The body and receipts of the block are retrieved from the peers and its state
+// is snap synced, the blocks below it are retrieved by BackfillHistory.
+func (d *Downloader) CheckpointSync(header *types.Header, td *big.Int) (err error) {
+	// Make sure only one goroutine is ever allowed past this point at once
+	if !atomic.CompareAndSwapInt32(&d.synchronising, 0, 1) {
+		return errBusy
+	}
+	defer atomic.StoreInt32(&d.synchronising, 0)
+
+	d.mux.Post(StartEvent{})
+	defer func() {
+		if err != nil {
+			d.mux.Post(FailedEvent{err})
+		} else {
+			d.mux.Post(DoneEvent{header})
+		}
+	}()
+	// Snap sync uses the snapshot namespace to store potentially flakey data
+	// until the sync finishes, pause the snapshot maintenance in the meantime.
+	if snapshots := d.blockchain.Snapshots(); snapshots != nil {
+		snapshots.Disable()
+	}
+	cancel := make(chan struct{})
+	d.cancelLock.Lock()
+	d.cancelCh = cancel
+	d.cancelPeer = ""
+	d.cancelLock.Unlock()
+
+	defer d.Cancel() // No matter what, we can't leave the cancel channel open
+
+	atomic.StoreUint32(&d.mode, uint32(SnapSync))
+	atomic.StoreInt32(&d.committed, 0)
+
+	// Retrieve the state and the block of the checkpoint concurrently.
+	log.Info("Retrieving checkpoint block", "number", header.Number, "hash", header.Hash())
+	sync := d.syncState(header.Root)
+	defer sync.Cancel()
+
+	blocks, receipts, err := d.fetchHistoryBlocks([]*types.Header{header}, []common.Hash{header.Hash()}, cancel)
+	if err != nil {
+		return err
+	}
+	select {
+	case <-sync.done:
+		if err := sync.Wait(); err != nil {
+			return err
+		}
+	case <-cancel:
+		return errCanceled
+	}
+	rawdb.WriteLastPivotNumber(d.stateDB, header.Number.Uint64())
+	if err := d.blockchain.InitFromCheckpoint(blocks[0], receipts[0], td); err != nil {
+		return err
+	}
+	atomic.StoreInt32(&d.committed, 1)
+	return nil
+}
+
+// BackfillHistory retrieves the blocks below the oldest block of a chain which
+// was initialised by CheckpointSync, from the oldest block backwards to the
+// genesis. The headers are filled in concurrently from all peers into skeletons
+// retrieved from single peers, their bodies and receipts are retrieved by the
+// concurrent fetchers of the chain sync, using a queue of their own. It blocks
+// until the history is complete, or the downloader is terminated.
+func (d *Downloader) BackfillHistory() error {
+	var (
+		start    = time.Now()
+		reported = time.Now()
+	)
+	for {
+		tail, ok := d.blockchain.HistoryTail()
+		if !ok {
+			return nil
+		}
+		if tail <= 1 {
+			// Only the genesis block is below the tail, which is always present
+			return d.blockchain.InsertHistory(nil, nil)
+		}
+		child := d.blockchain.GetHeaderByHash(rawdb.ReadCanonicalHash(d.stateDB, tail))
+		if child == nil {
+			return fmt.Errorf("history tail #%d unavailable", tail)
+		}
+		// Retrieve full windows as long as possible, then full header batches and
+		// finally the few blocks left above the genesis.
+		count := uint64(historyWindow)
+		if missing := tail - 1; missing < count {
+			count = missing - missing%uint64(MaxHeaderFetch)
+			if count == 0 {
+				count = missing
+			}
+		}
+		blocks, receipts, err := d.fetchHistoryWindow(child, int(count))
+		switch {
+		case errors.Is(err, errCanceled):
+			return err
+		case err != nil:
+			log.Debug("History retrieval failed", "number", tail-1, "err", err)
+			select {
+			case <-time.After(historyRetryInterval):
+				continue
+			case <-d.quitCh:
+				return errCanceled
+			}
+		}
+		// Insert the window from the newest block to the oldest
+		for i, j := 0, len(blocks)-1; i < j; i, j = i+1, j-1 {
+			blocks[i], blocks[j] = blocks[j], blocks[i]
+			receipts[i], receipts[j] = receipts[j], receipts[i]
+		}
+		if err := d.blockchain.InsertHistory(blocks, receipts); err != nil {
+			return err
+		}
+		if time.Since(reported) >= 8*time.Second {
+			log.Info("Backfilling chain history", "number", blocks[len(blocks)-1].Number(), "elapsed", common.PrettyDuration(time.Since(start)))
+			reported = time.Now()
+		}
+	}
+}
+
+// fetchHistoryWindow retrieves the count blocks right below the given header
+// with their receipts, in ascending order.
+func (d *Downloader) fetchHistoryWindow(child *types.Header, count int) (types.Blocks, []types.Receipts, error) {
+	headers, hashes, err := d.fetchHistoryHeaders(child, count)
+	if err != nil {
+		return nil, nil, err
+	}
+	return d.fetchHistoryBlocks(headers, hashes, nil)
+}
+
+// historyDownloader returns a downloader sharing the peers and the chain of d,
+// with a queue of its own, such that history can be retrieved by the concurrent
+// fetchers alongside the chain sync. It is cancelled along with the given
+// channel, or when d is terminated, and must be cancelled after use.
+func (d *Downloader) historyDownloader(cancel <-chan struct{}) *Downloader {
+	h := &Downloader{
+		queue:      newQueue(blockCacheMaxItems, blockCacheInitialItems),
+		peers:      d.peers,
+		stateDB:    d.stateDB,
+		blockchain: d.blockchain,
+		dropPeer:   d.dropPeer,
+		cancelCh:   make(chan struct{}),
+		quitCh:     d.quitCh,
+	}
+	go func() {
+		select {
+		case <-cancel:
+		case <-d.quitCh:
+		case <-h.cancelCh:
+		}
+		h.cancel()
+	}()
+	return h
+}
+
+// fetchHistoryHeaders retrieves the count headers right below the given header,
+// in ascending order. Full batches of headers are filled in concurrently from all
+// peers into a skeleton retrieved from one of them, fewer headers are retrieved
+// from one peer directly. The peers are asked in turn until one of them delivers
+// a skeleton, or the headers directly.
+func (d *Downloader) fetchHistoryHeaders(child *types.Header, count int) ([]*types.Header, []common.Hash, error) {
+	for _, p := range d.peers.AllPeers() {
+		var (
+			headers []*types.Header
+			hashes  []common.Hash
+			err     error
+		)
+		if count%MaxHeaderFetch == 0 {
+			headers, hashes, err = d.fillHistorySkeleton(p, child, count)
+		} else {
+			headers, hashes, err = d.fetchHistoryHeadersFrom(p, child, count)
+		}
+		switch {
+		case err == nil:
+			return headers, hashes, nil
+		case errors.Is(err, errCanceled):
+			return nil, nil, err
+		case errors.Is(err, errBadPeer):
+			p.log.Debug("Dropping peer delivering invalid history", "err", err)
+			if d.dropPeer != nil {
+				d.dropPeer(p.id, dropReason(err))
+			}
+		default:
+			p.log.Trace("History header retrieval failed", "err", err)
+		}
+	}
+	return nil, nil, errPeersUnavailable
+}
+
+// fillHistorySkeleton retrieves a skeleton of the count headers right below the
+// given header from a peer, and fills it in concurrently from all peers. The
+// headers are checked to link to the given header, so any mismatch is the fault
+// of the peer delivering the skeleton.
+func (d *Downloader) fillHistorySkeleton(p *peerConnection, child *types.Header, count int) ([]*types.Header, []common.Hash, error) {
+	h := d.historyDownloader(nil)
+	defer h.Cancel()
+
+	var (
+		batches = count / MaxHeaderFetch
+		from    = child.Number.Uint64() - uint64(count)
+	)
+	skeleton, hashes, err := h.fetchHeadersByNumber(p, from+uint64(MaxHeaderFetch)-1, batches, MaxHeaderFetch-1, false)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(skeleton) != batches {
+		return nil, nil, errHistoryUnavailable
+	}
+	for i, header := range skeleton {
+		if want := from + uint64((i+1)*MaxHeaderFetch) - 1; header.Number.Uint64() != want {
+			return nil, nil, fmt.Errorf("%w: skeleton header #%d, want #%d", errBadPeer, header.Number, want)
+		}
+	}
+	if hashes[batches-1] != child.ParentHash {
+		return nil, nil, fmt.Errorf("%w: skeleton header #%d hash mismatch: have %x, want %x", errBadPeer, from+uint64(count)-1, hashes[batches-1], child.ParentHash)
+	}
+	h.queue.ScheduleSkeleton(from, skeleton)
+	err = h.concurrentFetch((*headerQueue)(h), false)
+	headers, hashes, _ := h.queue.RetrieveHeaders()
+	if err != nil {
+		return nil, nil, err
+	}
+	// The batches are linked to the skeleton, make sure the skeleton is linked
+	for i := MaxHeaderFetch; i < len(headers); i += MaxHeaderFetch {
+		if headers[i].ParentHash != hashes[i-1] {
+			return nil, nil, fmt.Errorf("%w: skeleton broken at header #%d", errBadPeer, headers[i].Number)
+		}
+	}
+	return headers, hashes, nil
+}
+
+// fetchHistoryHeadersFrom retrieves the count headers right below the given
+// header from a peer directly. The headers are checked against the hash of the
+// header's parent, so any mismatch is the peer's fault.
+func (d *Downloader) fetchHistoryHeadersFrom(p *peerConnection, child *types.Header, count int) ([]*types.Header, []common.Hash, error) {
+	h := d.historyDownloader(nil)
+	defer h.Cancel()
+
+	headers, hashes, err := h.fetchHeadersByHash(p, child.ParentHash, count, 0, true)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(headers) != count {
+		return nil, nil, errHistoryUnavailable
+	}
+	want := child.ParentHash
+	for i, header := range headers {
+		if hashes[i] != want || header.Number.Uint64() != child.Number.Uint64()-uint64(i)-1 {
+			return nil, nil, fmt.Errorf("%w: header #%d mismatch", errBadPeer, header.Number)
+		}
+		want = header.ParentHash
+	}
+	for i, j := 0, len(headers)-1; i < j; i, j = i+1, j-1 {
+		headers[i], headers[j] = headers[j], headers[i]
+		hashes[i], hashes[j] = hashes[j], hashes[i]
+	}
+	return headers, hashes, nil
+}
+
+// fetchHistoryBlocks retrieves the bodies and receipts of a batch of consecutive
+// headers in ascending order, concurrently from all peers. The data is checked
+// against the headers by the queue. The retrieval is aborted when the given
+// channel is closed.
+func (d *Downloader) fetchHistoryBlocks(headers []*types.Header, hashes []common.Hash, cancel <-chan struct{}) (types.Blocks, []types.Receipts, error) {
+	h := d.historyDownloader(cancel)
+
+	from := headers[0].Number.Uint64()
+	h.queue.Prepare(from, SnapSync)
+	if scheduled := h.queue.Schedule(headers, hashes, from); len(scheduled) != len(headers) {
+		h.Cancel()
+		return nil, nil, fmt.Errorf("%w: %d of %d headers scheduled", errInvalidChain, len(scheduled), len(headers))
+	}
+	// All blocks are scheduled, let the fetchers terminate once they're retrieved
+	for _, ch := range []chan bool{h.queue.blockWakeCh, h.queue.receiptWakeCh} {
+		ch <- false
+	}
+	var results []*fetchResult
+	collect := func() error {
+		for {
+			batch := h.queue.Results(true)
+			if len(batch) == 0 {
+				return nil
+			}
+			results = append(results, batch...)
+		}
+	}
+	fetchers := []func() error{
+		func() error { return h.fetchBodies(from, false) },
+		func() error { return h.fetchReceipts(from, false) },
+		collect, // Results only end when the queue is closed after the fetchers
+	}
+	if err := h.spawnSync(fetchers); err != nil {
+		return nil, nil, err
+	}
+	if len(results) != len(headers) {
+		return nil, nil, errHistoryUnavailable
+	}
+	blocks := make(types.Blocks, len(results))
+	receipts := make([]types.Receipts, len(results))
+	for i, result := range results {
+		blocks[i] = types.NewBlockWithHeader(result.Header).WithBody(result.Transactions, result.Uncles)
+		receipts[i] = result.Receipts
+	}
+	return blocks, receipts, nil
+}
@@ -0,0 +1,73 @@
+// Copyright 2022 The go-confero Authors
+// This file is part of the go-confero library.
+//
+// The go-confero library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-confero library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-confero library. If not, see <http://www.gnu.org/licenses/>.
+
+package downloader
+
+import (
+	"testing"
+	"time"
+
+	"github.com/confero-network/go-confero/eth/protocols/eth"
+)
+
+// Tests that the history below a checkpoint is backfilled from multiple peers,
+// through header skeletons as well as directly for the blocks left at the end.
+func TestBackfillHistory(t *testing.T) {
+	tester := newTester(t)
+	defer tester.terminate()
+
+	chain := testChainBase.shorten(800)
+	for _, id := range []string{"peer-1", "peer-2", "peer-3"} {
+		tester.newPeer(id, eth.ETH66, chain.blocks[1:])
+	}
+	// Initialise the chain from the last block, with its state copied over
+	it := testDB.NewIterator(nil, nil)
+	for it.Next() {
+		tester.downloader.stateDB.Put(it.Key(), it.Value())
+	}
+	it.Release()
+
+	var (
+		source     = tester.peers["peer-1"].chain
+		checkpoint = chain.blocks[len(chain.blocks)-1]
+	)
+	receipts := source.GetReceiptsByHash(checkpoint.Hash())
+	td := source.GetTd(checkpoint.Hash(), checkpoint.NumberU64())
+	if err := tester.chain.InitFromCheckpoint(checkpoint, receipts, td); err != nil {
+		t.Fatalf("failed to initialise chain: %v", err)
+	}
+	errc := make(chan error, 1)
+	go func() { errc <- tester.downloader.BackfillHistory() }()
+	select {
+	case err := <-errc:
+		if err != nil {
+			t.Fatalf("failed to backfill history: %v", err)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("history backfill timed out")
+	}
+	if tail, ok := tester.chain.HistoryTail(); ok {
+		t.Fatalf("history incomplete, tail #%d", tail)
+	}
+	for _, block := range chain.blocks {
+		if have := tester.chain.GetBlockByNumber(block.NumberU64()); have == nil || have.Hash() != block.Hash() {
+			t.Fatalf("block #%d missing after backfill", block.NumberU64())
+		}
+		if have, want := tester.chain.GetReceiptsByHash(block.Hash()), source.GetReceiptsByHash(block.Hash()); len(have) != len(want) {
+			t.Fatalf("receipts of block #%d mismatch: have %d, want %d", block.NumberU64(), len(have), len(want))
+		}
+	}
+}
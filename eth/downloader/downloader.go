@@ -34,6 +34,7 @@ import (
 	"github.com/confero-network/go-confero/ethdb"
 	"github.com/confero-network/go-confero/event"
 	"github.com/confero-network/go-confero/log"
+	"github.com/confero-network/go-confero/p2p"
 	"github.com/confero-network/go-confero/params"
 )
 
@@ -82,8 +83,10 @@ var (
 	ErrMergeTransition         = errors.New("legacy sync reached the merge")
 )
 
-// peerDropFn is a callback type for dropping a peer detected as malicious.
-type peerDropFn func(id string)
+// peerDropFn is a callback type for dropping a peer detected as malicious or
+// unresponsive. The reason distinguishes timeouts and stalls (DiscReadTimeout)
+// from invalid data (DiscProtocolError) and otherwise useless peers.
+type peerDropFn func(id string, reason p2p.DiscReason)
 
 // badBlockFn is a callback for the async beacon sync to notify the caller that
 // the origin header requested to sync to, produced a chain with a bad block.
@@ -206,6 +209,16 @@ type BlockChain interface {
 
 	// Snapshots returns the blockchain snapshot tree to paused it during sync.
 	Snapshots() *snapshot.Tree
+
+	// InitFromCheckpoint makes a trusted checkpoint block the head of an empty chain.
+	InitFromCheckpoint(*types.Block, types.Receipts, *big.Int) error
+
+	// HistoryTail returns the oldest block of a chain initialised from a checkpoint,
+	// as long as the blocks below it are missing.
+	HistoryTail() (uint64, bool)
+
+	// InsertHistory inserts a batch of blocks below the oldest block of the chain.
+	InsertHistory(types.Blocks, []types.Receipts) error
 }
 
 // New creates a new downloader to fetch hashes and blocks from remote peers.
@@ -328,6 +341,18 @@ func (d *Downloader) UnregisterPeer(id string) error {
 	return nil
 }
 
+// dropReason returns the reason for dropping a peer which caused a sync error.
+func dropReason(err error) p2p.DiscReason {
+	switch {
+	case errors.Is(err, errTimeout), errors.Is(err, errStallingPeer):
+		return p2p.DiscReadTimeout
+	case errors.Is(err, errInvalidChain), errors.Is(err, errBadPeer), errors.Is(err, errInvalidAncestor):
+		return p2p.DiscProtocolError
+	default:
+		return p2p.DiscUselessPeer
+	}
+}
+
 // LegacySync tries to sync up our local block chain with a remote peer, both
 // adding various sanity checks as well as wrapping it with various log entries.
 func (d *Downloader) LegacySync(id string, head common.Hash, td, ttd *big.Int, mode SyncMode) error {
@@ -346,7 +371,7 @@ func (d *Downloader) LegacySync(id string, head common.Hash, td, ttd *big.Int, m
 			// Timeouts can occur if e.g. compaction hits at the wrong time, and can be ignored
 			log.Warn("Downloader wants to drop peer, but peerdrop-function is not set", "peer", id)
 		} else {
-			d.dropPeer(id)
+			d.dropPeer(id, dropReason(err))
 		}
 		return err
 	}
@@ -584,7 +609,14 @@ func (d *Downloader) syncWithPeer(p *peerConnection, hash common.Hash, td, ttd *
 			d.ancientLimit = 0
 			log.Info("Disabling direct-ancient mode", "origin", origin, "ancient", frozen-1)
 		} else if d.ancientLimit > 0 {
-			log.Debug("Enabling direct-ancient mode", "ancient", d.ancientLimit)
+			// Read-only ancient stores, e.g. mounted era history, can't be extended,
+			// keep all new block data in the active store in that case.
+			if d.stateDB.AncientReadOnly() {
+				d.ancientLimit = 0
+				log.Info("Disabling direct-ancient mode, ancient store is read only")
+			} else {
+				log.Debug("Enabling direct-ancient mode", "ancient", d.ancientLimit)
+			}
 		}
 		// Rewind the ancient store and blockchain if reorg happens.
 		if origin+1 < frozen {
@@ -840,6 +872,10 @@ func (d *Downloader) findAncestor(p *peerConnection, remoteHeader *types.Header)
 		if floor < int64(d.genesis)-1 {
 			floor = int64(d.genesis) - 1
 		}
+	} else if tail, ok := d.blockchain.HistoryTail(); ok && floor < int64(tail)-1 {
+		// If the chain was initialised from a checkpoint, the blocks below its
+		// oldest block are still missing.
+		floor = int64(tail) - 1
 	}
 
 	ancestor, err := d.findAncestorSpanSearch(p, mode, remoteHeight, localHeight, floor)
@@ -1035,7 +1071,7 @@ func (d *Downloader) fetchHeaders(p *peerConnection, from uint64, head uint64) e
 		default:
 			// Header retrieval either timed out, or the peer failed in some strange way
 			// (e.g. disconnect). Consider the master peer bad and drop
-			d.dropPeer(p.id)
+			d.dropPeer(p.id, dropReason(err))
 
 			// Finish the sync gracefully instead of dumping the gathered data though
 			for _, ch := range []chan bool{d.queue.blockWakeCh, d.queue.receiptWakeCh} {
@@ -38,6 +38,7 @@ import (
 	"github.com/confero-network/go-confero/eth/protocols/snap"
 	"github.com/confero-network/go-confero/event"
 	"github.com/confero-network/go-confero/log"
+	"github.com/confero-network/go-confero/p2p"
 	"github.com/confero-network/go-confero/params"
 	"github.com/confero-network/go-confero/rlp"
 	"github.com/confero-network/go-confero/trie"
@@ -138,7 +139,7 @@ func (dl *downloadTester) newPeer(id string, version uint, blocks []*types.Block
 }
 
 // dropPeer simulates a hard peer removal from the connection pool.
-func (dl *downloadTester) dropPeer(id string) {
+func (dl *downloadTester) dropPeer(id string, reason p2p.DiscReason) {
 	dl.lock.Lock()
 	defer dl.lock.Unlock()
 
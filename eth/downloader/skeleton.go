@@ -30,6 +30,7 @@ import (
 	"github.com/confero-network/go-confero/eth/protocols/eth"
 	"github.com/confero-network/go-confero/ethdb"
 	"github.com/confero-network/go-confero/log"
+	"github.com/confero-network/go-confero/p2p"
 )
 
 // scratchHeaders is the number of headers to store in a scratch space to allow
@@ -760,7 +761,7 @@ func (s *skeleton) executeTask(peer *peerConnection, req *headerRequest) {
 		// gone stale and monitor them. However, in that case too, we need a way
 		// to protect against malicious peers never responding, so it would need
 		// a second, hard-timeout mechanism.
-		s.drop(peer.id)
+		s.drop(peer.id, p2p.DiscReadTimeout)
 
 	case res := <-resCh:
 		// Headers successfully retrieved, update the metrics
@@ -922,7 +923,7 @@ func (s *skeleton) processResponse(res *headerResponse) (linked bool, merged boo
 			for i := 0; i < requestHeaders; i++ {
 				s.scratchSpace[i] = nil
 			}
-			s.drop(s.scratchOwners[0])
+			s.drop(s.scratchOwners[0], p2p.DiscProtocolError)
 			s.scratchOwners[0] = ""
 			break
 		}
@@ -30,6 +30,7 @@ import (
 	"github.com/confero-network/go-confero/core/types"
 	"github.com/confero-network/go-confero/eth/protocols/eth"
 	"github.com/confero-network/go-confero/log"
+	"github.com/confero-network/go-confero/p2p"
 )
 
 // hookedBackfiller is a tester backfiller with all interface methods mocked and
@@ -773,7 +774,7 @@ func TestSkeletonSyncRetrievals(t *testing.T) {
 		}
 		// Create a peer dropper to track malicious peers
 		dropped := make(map[string]int)
-		drop := func(peer string) {
+		drop := func(peer string, reason p2p.DiscReason) {
 			if p := peerset.Peer(peer); p != nil {
 				atomic.AddUint64(&p.peer.(*skeletonTestPeer).dropped, 1)
 			}
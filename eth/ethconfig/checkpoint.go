@@ -0,0 +1,125 @@
+// Copyright 2022 The go-confero Authors
+// This file is part of the go-confero library.
+//
+// The go-confero library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-confero library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-confero library. If not, see <http://www.gnu.org/licenses/>.
+
+package ethconfig
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/confero-network/go-confero/common"
+	"github.com/confero-network/go-confero/common/hexutil"
+	"github.com/confero-network/go-confero/common/math"
+	"github.com/confero-network/go-confero/crypto"
+)
+
+// SyncCheckpoint is a trusted block to start syncing the chain from. The total
+// difficulty of the block is needed to make it the head of the chain before the
+// older blocks are known.
+type SyncCheckpoint struct {
+	Number uint64
+	Hash   common.Hash
+	TD     *big.Int
+}
+
+// signedSyncCheckpoint is the JSON encoding of a checkpoint file.
+type signedSyncCheckpoint struct {
+	Number     math.HexOrDecimal64   `json:"number"`
+	Hash       common.Hash           `json:"hash"`
+	TD         *math.HexOrDecimal256 `json:"td"`
+	Signatures []hexutil.Bytes       `json:"signatures,omitempty"`
+}
+
+// SigHash returns the hash signed by the signers of a checkpoint, which is the
+// keccak256 hash of the big endian block number, the block hash and the 32 byte
+// big endian total difficulty.
+func (c *SyncCheckpoint) SigHash() common.Hash {
+	var number [8]byte
+	binary.BigEndian.PutUint64(number[:], c.Number)
+	return crypto.Keccak256Hash(number[:], c.Hash[:], common.BigToHash(c.TD).Bytes())
+}
+
+// ParseSyncCheckpoint parses a checkpoint given as <number>:<hash>:<td>.
+func ParseSyncCheckpoint(s string) (*SyncCheckpoint, error) {
+	parts := strings.Split(s, ":")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("invalid checkpoint %q, want <number>:<hash>:<td>", s)
+	}
+	number, err := strconv.ParseUint(parts[0], 0, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid checkpoint number %q: %v", parts[0], err)
+	}
+	var hash common.Hash
+	if err := hash.UnmarshalText([]byte(parts[1])); err != nil {
+		return nil, fmt.Errorf("invalid checkpoint hash %q: %v", parts[1], err)
+	}
+	td, ok := math.ParseBig256(parts[2])
+	if !ok || td.Sign() <= 0 {
+		return nil, fmt.Errorf("invalid checkpoint total difficulty %q", parts[2])
+	}
+	return &SyncCheckpoint{Number: number, Hash: hash, TD: td}, nil
+}
+
+// LoadSyncCheckpoint reads a checkpoint from a JSON file. If signers are given,
+// the file must contain a valid signature of at least one of them.
+func LoadSyncCheckpoint(file string, signers []common.Address) (*SyncCheckpoint, error) {
+	blob, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	var enc signedSyncCheckpoint
+	if err := json.Unmarshal(blob, &enc); err != nil {
+		return nil, fmt.Errorf("invalid checkpoint file: %v", err)
+	}
+	if enc.Hash == (common.Hash{}) {
+		return nil, errors.New("checkpoint file has no block hash")
+	}
+	if enc.TD == nil || (*big.Int)(enc.TD).Sign() <= 0 {
+		return nil, errors.New("checkpoint file has no total difficulty")
+	}
+	cp := &SyncCheckpoint{Number: uint64(enc.Number), Hash: enc.Hash, TD: (*big.Int)(enc.TD)}
+	if len(signers) == 0 {
+		return cp, nil
+	}
+	if err := cp.verify(enc.Signatures, signers); err != nil {
+		return nil, err
+	}
+	return cp, nil
+}
+
+// verify checks that one of the signatures was made by a trusted signer.
+func (c *SyncCheckpoint) verify(sigs []hexutil.Bytes, signers []common.Address) error {
+	hash := c.SigHash()
+	for _, sig := range sigs {
+		pubkey, err := crypto.SigToPub(hash[:], sig)
+		if err != nil {
+			continue
+		}
+		addr := crypto.PubkeyToAddress(*pubkey)
+		for _, signer := range signers {
+			if addr == signer {
+				return nil
+			}
+		}
+	}
+	return errors.New("checkpoint is not signed by a trusted signer")
+}
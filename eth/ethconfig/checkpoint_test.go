@@ -0,0 +1,103 @@
+// Copyright 2022 The go-confero Authors
+// This file is part of the go-confero library.
+//
+// The go-confero library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-confero library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-confero library. If not, see <http://www.gnu.org/licenses/>.
+
+package ethconfig
+
+import (
+	"crypto/ecdsa"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/confero-network/go-confero/common"
+	"github.com/confero-network/go-confero/common/hexutil"
+	"github.com/confero-network/go-confero/crypto"
+)
+
+func TestParseSyncCheckpoint(t *testing.T) {
+	hash := common.HexToHash("0x2a")
+	cp, err := ParseSyncCheckpoint(fmt.Sprintf("1000:%s:0x10000", hash.Hex()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cp.Number != 1000 || cp.Hash != hash || cp.TD.Cmp(big.NewInt(0x10000)) != 0 {
+		t.Fatalf("wrong checkpoint: %+v", cp)
+	}
+	for _, s := range []string{"", "1000", "1000:" + hash.Hex(), "x:" + hash.Hex() + ":1", "1000:0x12:1", "1000:" + hash.Hex() + ":0", "1000:" + hash.Hex() + ":x", "1:2:3:4"} {
+		if _, err := ParseSyncCheckpoint(s); err == nil {
+			t.Errorf("no error for %q", s)
+		}
+	}
+}
+
+func TestLoadSyncCheckpoint(t *testing.T) {
+	var (
+		key, _   = crypto.GenerateKey()
+		other, _ = crypto.GenerateKey()
+		signer   = crypto.PubkeyToAddress(key.PublicKey)
+		cp       = &SyncCheckpoint{Number: 0x1234, Hash: common.HexToHash("0x2a"), TD: big.NewInt(0x5678)}
+		dir      = t.TempDir()
+	)
+	sign := func(key *ecdsa.PrivateKey) string {
+		sig, err := crypto.Sign(cp.SigHash().Bytes(), key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return hexutil.Encode(sig)
+	}
+	write := func(name string, sigs ...string) string {
+		content := fmt.Sprintf(`{"number": "0x1234", "hash": "%s", "td": "0x5678"`, cp.Hash.Hex())
+		if len(sigs) > 0 {
+			content += `, "signatures": [`
+			for i, sig := range sigs {
+				if i > 0 {
+					content += ","
+				}
+				content += `"` + sig + `"`
+			}
+			content += "]"
+		}
+		content += "}"
+		file := filepath.Join(dir, name)
+		if err := os.WriteFile(file, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return file
+	}
+
+	// Unsigned checkpoints are accepted without trusted signers.
+	loaded, err := LoadSyncCheckpoint(write("unsigned.json"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded.Number != cp.Number || loaded.Hash != cp.Hash || loaded.TD.Cmp(cp.TD) != 0 {
+		t.Fatalf("wrong checkpoint: %+v", loaded)
+	}
+	if _, err := LoadSyncCheckpoint(write("unsigned.json"), []common.Address{signer}); err == nil {
+		t.Fatal("unsigned checkpoint accepted with trusted signers")
+	}
+	// Any signature of a trusted signer is enough.
+	file := write("signed.json", sign(other), sign(key))
+	if _, err := LoadSyncCheckpoint(file, []common.Address{signer}); err != nil {
+		t.Fatal(err)
+	}
+	file = write("untrusted.json", sign(other))
+	if _, err := LoadSyncCheckpoint(file, []common.Address{signer}); err == nil {
+		t.Fatal("checkpoint accepted without trusted signature")
+	}
+}
@@ -33,6 +33,7 @@ import (
 	"github.com/confero-network/go-confero/core"
 	"github.com/confero-network/go-confero/eth/downloader"
 	"github.com/confero-network/go-confero/eth/gasprice"
+	"github.com/confero-network/go-confero/eth/protocols/snap"
 	"github.com/confero-network/go-confero/ethdb"
 	"github.com/confero-network/go-confero/log"
 	"github.com/confero-network/go-confero/miner"
@@ -83,6 +84,10 @@ var Defaults = Config{
 	TrieDirtyCache:          256,
 	TrieTimeout:             60 * time.Minute,
 	SnapshotCache:           102,
+	SnapServeThreads:        snap.DefaultServingConfig.Threads,
+	SnapServePeerShare:      snap.DefaultServingConfig.PeerShare,
+	SnapServeMaxQueued:      snap.DefaultServingConfig.MaxQueued,
+	SnapServeMaxWait:        snap.DefaultServingConfig.MaxWait,
 	FilterLogCacheSize:      32,
 	Miner: miner.Config{
 		GasCeil:  30000000,
@@ -134,16 +139,29 @@ type Config struct {
 	EthDiscoveryURLs  []string
 	SnapDiscoveryURLs []string
 
+	// Snap protocol serving options
+	SnapNoServe        bool          `toml:",omitempty"` // Whether to stop serving snap requests, the protocol only runs for snap sync
+	SnapServeThreads   int           `toml:",omitempty"` // Number of snap requests served concurrently
+	SnapServePeerShare float64       `toml:",omitempty"` // Share of the serving threads one peer can use on average
+	SnapServeMaxQueued int           `toml:",omitempty"` // Maximum number of snap requests waiting to be served
+	SnapServeMaxWait   time.Duration `toml:",omitempty"` // Maximum time a snap request waits before it's served with a capped response
+
 	NoPruning  bool // Whether to disable pruning and flush everything to disk
 	NoPrefetch bool // Whether to disable prefetching and only load state on demand
 
 	TxLookupLimit uint64 `toml:",omitempty"` // The maximum number of blocks from head whose tx indices are reserved.
+	LogIndex      bool   `toml:",omitempty"` // Whether to maintain an exact log index for fast log filtering
 
 	// RequiredBlocks is a set of block number -> hash mappings which must be in the
 	// canonical chain of all remote peers. Setting the option makes gcofe verify the
 	// presence of these blocks for every new peer connection.
 	RequiredBlocks map[uint64]common.Hash `toml:"-"`
 
+	// SyncFrom is a trusted block to start syncing the chain from. An empty node
+	// snap syncs the state of this block and makes it the head, the older blocks
+	// are backfilled from the block backwards afterwards.
+	SyncFrom *SyncCheckpoint `toml:",omitempty"`
+
 	// Light client options
 	LightServ          int  `toml:",omitempty"` // Maximum percentage of time allowed for serving LES requests
 	LightIngress       int  `toml:",omitempty"` // Incoming bandwidth limit for light servers
@@ -163,6 +181,8 @@ type Config struct {
 	DatabaseHandles    int  `toml:"-"`
 	DatabaseCache      int
 	DatabaseFreezer    string
+	DatabaseAncients   string `toml:",omitempty"` // Endpoint of a read-only remote ancient store layered below the local freezer
+	DatabaseEra        string `toml:",omitempty"` // Directory of era1 history files layered below the local freezer
 
 	TrieCleanCache          int
 	TrieCleanCacheJournal   string        `toml:",omitempty"` // Disk journal directory for trie cache to survive node restarts
@@ -190,6 +210,9 @@ type Config struct {
 	// Enables tracking of SHA3 preimages in the VM
 	EnablePreimageRecording bool
 
+	// Enables collecting stateless execution witnesses during block import
+	EnableWitnessCollection bool
+
 	// Miscellaneous options
 	DocRoot string `toml:"-"`
 
@@ -203,6 +226,14 @@ type Config struct {
 	// send-transaction variants. The unit is cofe.
 	RPCTxFeeCap float64
 
+	// RPCLogsMaxBlockRange is the maximum number of blocks scanned by a log query
+	// (0 = no limit).
+	RPCLogsMaxBlockRange uint64
+
+	// RPCLogsMaxResults is the maximum number of logs returned by a log query
+	// (0 = no limit).
+	RPCLogsMaxResults int
+
 	// Checkpoint is a hardcoded checkpoint which can be nil.
 	Checkpoint *params.TrustedCheckpoint `toml:",omitempty"`
 
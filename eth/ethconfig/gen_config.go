@@ -23,10 +23,17 @@ func (c Config) MarshalTOML() (interface{}, error) {
 		SyncMode                              downloader.SyncMode
 		EthDiscoveryURLs                      []string
 		SnapDiscoveryURLs                     []string
+		SnapNoServe                           bool          `toml:",omitempty"`
+		SnapServeThreads                      int           `toml:",omitempty"`
+		SnapServePeerShare                    float64       `toml:",omitempty"`
+		SnapServeMaxQueued                    int           `toml:",omitempty"`
+		SnapServeMaxWait                      time.Duration `toml:",omitempty"`
 		NoPruning                             bool
 		NoPrefetch                            bool
 		TxLookupLimit                         uint64                 `toml:",omitempty"`
+		LogIndex                              bool                   `toml:",omitempty"`
 		RequiredBlocks                        map[uint64]common.Hash `toml:"-"`
+		SyncFrom                              *SyncCheckpoint        `toml:",omitempty"`
 		LightServ                             int                    `toml:",omitempty"`
 		LightIngress                          int                    `toml:",omitempty"`
 		LightEgress                           int                    `toml:",omitempty"`
@@ -41,6 +48,8 @@ func (c Config) MarshalTOML() (interface{}, error) {
 		DatabaseHandles                       int                    `toml:"-"`
 		DatabaseCache                         int
 		DatabaseFreezer                       string
+		DatabaseAncients                      string `toml:",omitempty"`
+		DatabaseEra                           string `toml:",omitempty"`
 		TrieCleanCache                        int
 		TrieCleanCacheJournal                 string        `toml:",omitempty"`
 		TrieCleanCacheRejournal               time.Duration `toml:",omitempty"`
@@ -54,10 +63,13 @@ func (c Config) MarshalTOML() (interface{}, error) {
 		TxPool                                core.TxPoolConfig
 		GPO                                   gasprice.Config
 		EnablePreimageRecording               bool
+		EnableWitnessCollection               bool
 		DocRoot                               string `toml:"-"`
 		RPCGasCap                             uint64
 		RPCEVMTimeout                         time.Duration
 		RPCTxFeeCap                           float64
+		RPCLogsMaxBlockRange                  uint64
+		RPCLogsMaxResults                     int
 		Checkpoint                            *params.TrustedCheckpoint      `toml:",omitempty"`
 		CheckpointOracle                      *params.CheckpointOracleConfig `toml:",omitempty"`
 		OverrideTerminalTotalDifficulty       *big.Int                       `toml:",omitempty"`
@@ -69,10 +81,17 @@ func (c Config) MarshalTOML() (interface{}, error) {
 	enc.SyncMode = c.SyncMode
 	enc.EthDiscoveryURLs = c.EthDiscoveryURLs
 	enc.SnapDiscoveryURLs = c.SnapDiscoveryURLs
+	enc.SnapNoServe = c.SnapNoServe
+	enc.SnapServeThreads = c.SnapServeThreads
+	enc.SnapServePeerShare = c.SnapServePeerShare
+	enc.SnapServeMaxQueued = c.SnapServeMaxQueued
+	enc.SnapServeMaxWait = c.SnapServeMaxWait
 	enc.NoPruning = c.NoPruning
 	enc.NoPrefetch = c.NoPrefetch
 	enc.TxLookupLimit = c.TxLookupLimit
+	enc.LogIndex = c.LogIndex
 	enc.RequiredBlocks = c.RequiredBlocks
+	enc.SyncFrom = c.SyncFrom
 	enc.LightServ = c.LightServ
 	enc.LightIngress = c.LightIngress
 	enc.LightEgress = c.LightEgress
@@ -87,6 +106,8 @@ func (c Config) MarshalTOML() (interface{}, error) {
 	enc.DatabaseHandles = c.DatabaseHandles
 	enc.DatabaseCache = c.DatabaseCache
 	enc.DatabaseFreezer = c.DatabaseFreezer
+	enc.DatabaseAncients = c.DatabaseAncients
+	enc.DatabaseEra = c.DatabaseEra
 	enc.TrieCleanCache = c.TrieCleanCache
 	enc.TrieCleanCacheJournal = c.TrieCleanCacheJournal
 	enc.TrieCleanCacheRejournal = c.TrieCleanCacheRejournal
@@ -100,10 +121,13 @@ func (c Config) MarshalTOML() (interface{}, error) {
 	enc.TxPool = c.TxPool
 	enc.GPO = c.GPO
 	enc.EnablePreimageRecording = c.EnablePreimageRecording
+	enc.EnableWitnessCollection = c.EnableWitnessCollection
 	enc.DocRoot = c.DocRoot
 	enc.RPCGasCap = c.RPCGasCap
 	enc.RPCEVMTimeout = c.RPCEVMTimeout
 	enc.RPCTxFeeCap = c.RPCTxFeeCap
+	enc.RPCLogsMaxBlockRange = c.RPCLogsMaxBlockRange
+	enc.RPCLogsMaxResults = c.RPCLogsMaxResults
 	enc.Checkpoint = c.Checkpoint
 	enc.CheckpointOracle = c.CheckpointOracle
 	enc.OverrideTerminalTotalDifficulty = c.OverrideTerminalTotalDifficulty
@@ -119,10 +143,17 @@ func (c *Config) UnmarshalTOML(unmarshal func(interface{}) error) error {
 		SyncMode                              *downloader.SyncMode
 		EthDiscoveryURLs                      []string
 		SnapDiscoveryURLs                     []string
+		SnapNoServe                           *bool          `toml:",omitempty"`
+		SnapServeThreads                      *int           `toml:",omitempty"`
+		SnapServePeerShare                    *float64       `toml:",omitempty"`
+		SnapServeMaxQueued                    *int           `toml:",omitempty"`
+		SnapServeMaxWait                      *time.Duration `toml:",omitempty"`
 		NoPruning                             *bool
 		NoPrefetch                            *bool
 		TxLookupLimit                         *uint64                `toml:",omitempty"`
+		LogIndex                              *bool                  `toml:",omitempty"`
 		RequiredBlocks                        map[uint64]common.Hash `toml:"-"`
+		SyncFrom                              *SyncCheckpoint        `toml:",omitempty"`
 		LightServ                             *int                   `toml:",omitempty"`
 		LightIngress                          *int                   `toml:",omitempty"`
 		LightEgress                           *int                   `toml:",omitempty"`
@@ -137,6 +168,8 @@ func (c *Config) UnmarshalTOML(unmarshal func(interface{}) error) error {
 		DatabaseHandles                       *int                   `toml:"-"`
 		DatabaseCache                         *int
 		DatabaseFreezer                       *string
+		DatabaseAncients                      *string `toml:",omitempty"`
+		DatabaseEra                           *string `toml:",omitempty"`
 		TrieCleanCache                        *int
 		TrieCleanCacheJournal                 *string        `toml:",omitempty"`
 		TrieCleanCacheRejournal               *time.Duration `toml:",omitempty"`
@@ -150,10 +183,13 @@ func (c *Config) UnmarshalTOML(unmarshal func(interface{}) error) error {
 		TxPool                                *core.TxPoolConfig
 		GPO                                   *gasprice.Config
 		EnablePreimageRecording               *bool
+		EnableWitnessCollection               *bool
 		DocRoot                               *string `toml:"-"`
 		RPCGasCap                             *uint64
 		RPCEVMTimeout                         *time.Duration
 		RPCTxFeeCap                           *float64
+		RPCLogsMaxBlockRange                  *uint64
+		RPCLogsMaxResults                     *int
 		Checkpoint                            *params.TrustedCheckpoint      `toml:",omitempty"`
 		CheckpointOracle                      *params.CheckpointOracleConfig `toml:",omitempty"`
 		OverrideTerminalTotalDifficulty       *big.Int                       `toml:",omitempty"`
@@ -178,6 +214,21 @@ func (c *Config) UnmarshalTOML(unmarshal func(interface{}) error) error {
 	if dec.SnapDiscoveryURLs != nil {
 		c.SnapDiscoveryURLs = dec.SnapDiscoveryURLs
 	}
+	if dec.SnapNoServe != nil {
+		c.SnapNoServe = *dec.SnapNoServe
+	}
+	if dec.SnapServeThreads != nil {
+		c.SnapServeThreads = *dec.SnapServeThreads
+	}
+	if dec.SnapServePeerShare != nil {
+		c.SnapServePeerShare = *dec.SnapServePeerShare
+	}
+	if dec.SnapServeMaxQueued != nil {
+		c.SnapServeMaxQueued = *dec.SnapServeMaxQueued
+	}
+	if dec.SnapServeMaxWait != nil {
+		c.SnapServeMaxWait = *dec.SnapServeMaxWait
+	}
 	if dec.NoPruning != nil {
 		c.NoPruning = *dec.NoPruning
 	}
@@ -187,9 +238,15 @@ func (c *Config) UnmarshalTOML(unmarshal func(interface{}) error) error {
 	if dec.TxLookupLimit != nil {
 		c.TxLookupLimit = *dec.TxLookupLimit
 	}
+	if dec.LogIndex != nil {
+		c.LogIndex = *dec.LogIndex
+	}
 	if dec.RequiredBlocks != nil {
 		c.RequiredBlocks = dec.RequiredBlocks
 	}
+	if dec.SyncFrom != nil {
+		c.SyncFrom = dec.SyncFrom
+	}
 	if dec.LightServ != nil {
 		c.LightServ = *dec.LightServ
 	}
@@ -232,6 +289,12 @@ func (c *Config) UnmarshalTOML(unmarshal func(interface{}) error) error {
 	if dec.DatabaseFreezer != nil {
 		c.DatabaseFreezer = *dec.DatabaseFreezer
 	}
+	if dec.DatabaseAncients != nil {
+		c.DatabaseAncients = *dec.DatabaseAncients
+	}
+	if dec.DatabaseEra != nil {
+		c.DatabaseEra = *dec.DatabaseEra
+	}
 	if dec.TrieCleanCache != nil {
 		c.TrieCleanCache = *dec.TrieCleanCache
 	}
@@ -271,6 +334,9 @@ func (c *Config) UnmarshalTOML(unmarshal func(interface{}) error) error {
 	if dec.EnablePreimageRecording != nil {
 		c.EnablePreimageRecording = *dec.EnablePreimageRecording
 	}
+	if dec.EnableWitnessCollection != nil {
+		c.EnableWitnessCollection = *dec.EnableWitnessCollection
+	}
 	if dec.DocRoot != nil {
 		c.DocRoot = *dec.DocRoot
 	}
@@ -283,6 +349,12 @@ func (c *Config) UnmarshalTOML(unmarshal func(interface{}) error) error {
 	if dec.RPCTxFeeCap != nil {
 		c.RPCTxFeeCap = *dec.RPCTxFeeCap
 	}
+	if dec.RPCLogsMaxBlockRange != nil {
+		c.RPCLogsMaxBlockRange = *dec.RPCLogsMaxBlockRange
+	}
+	if dec.RPCLogsMaxResults != nil {
+		c.RPCLogsMaxResults = *dec.RPCLogsMaxResults
+	}
 	if dec.Checkpoint != nil {
 		c.Checkpoint = dec.Checkpoint
 	}
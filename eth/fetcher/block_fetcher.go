@@ -29,6 +29,7 @@ import (
 	"github.com/confero-network/go-confero/eth/protocols/eth"
 	"github.com/confero-network/go-confero/log"
 	"github.com/confero-network/go-confero/metrics"
+	"github.com/confero-network/go-confero/p2p"
 	"github.com/confero-network/go-confero/trie"
 )
 
@@ -95,8 +96,10 @@ type headersInsertFn func(headers []*types.Header) (int, error)
 // chainInsertFn is a callback type to insert a batch of blocks into the local chain.
 type chainInsertFn func(types.Blocks) (int, error)
 
-// peerDropFn is a callback type for dropping a peer detected as malicious.
-type peerDropFn func(id string)
+// peerDropFn is a callback type for dropping a peer detected as malicious or
+// unresponsive. The reason distinguishes timeouts (DiscReadTimeout) from invalid
+// data (DiscProtocolError).
+type peerDropFn func(id string, reason p2p.DiscReason)
 
 // blockAnnounce is the hash notification of the availability of a new block in the
 // network.
@@ -490,7 +493,7 @@ func (f *BlockFetcher) loop() {
 								// was already rescheduled at this point, we were
 								// waiting for a catchup. With an unresponsive
 								// peer however, it's a protocol violation.
-								f.dropPeer(peer)
+								f.dropPeer(peer, p2p.DiscReadTimeout)
 							}
 						}(hash)
 					}
@@ -549,7 +552,7 @@ func (f *BlockFetcher) loop() {
 						// was already rescheduled at this point, we were
 						// waiting for a catchup. With an unresponsive
 						// peer however, it's a protocol violation.
-						f.dropPeer(peer)
+						f.dropPeer(peer, p2p.DiscReadTimeout)
 					}
 				}(peer, hashes)
 			}
@@ -579,7 +582,7 @@ func (f *BlockFetcher) loop() {
 					// If the delivered header does not match the promised number, drop the announcer
 					if header.Number.Uint64() != announce.number {
 						log.Trace("Invalid block number fetched", "peer", announce.origin, "hash", header.Hash(), "announced", announce.number, "provided", header.Number)
-						f.dropPeer(announce.origin)
+						f.dropPeer(announce.origin, p2p.DiscProtocolError)
 						f.forgetHash(hash)
 						continue
 					}
@@ -818,7 +821,7 @@ func (f *BlockFetcher) importHeaders(peer string, header *types.Header) {
 		// Validate the header and if something went wrong, drop the peer
 		if err := f.verifyHeader(header); err != nil && err != consensus.ErrFutureBlock {
 			log.Debug("Propagated header verification failed", "peer", peer, "number", header.Number, "hash", hash, "err", err)
-			f.dropPeer(peer)
+			f.dropPeer(peer, p2p.DiscProtocolError)
 			return
 		}
 		// Run the actual import and log any issues
@@ -863,7 +866,7 @@ func (f *BlockFetcher) importBlocks(peer string, block *types.Block) {
 		default:
 			// Something went very wrong, drop the peer
 			log.Debug("Propagated block verification failed", "peer", peer, "number", block.Number(), "hash", hash, "err", err)
-			f.dropPeer(peer)
+			f.dropPeer(peer, p2p.DiscProtocolError)
 			return
 		}
 		// Run the actual import and log any issues
@@ -31,6 +31,7 @@ import (
 	"github.com/confero-network/go-confero/core/types"
 	"github.com/confero-network/go-confero/crypto"
 	"github.com/confero-network/go-confero/eth/protocols/eth"
+	"github.com/confero-network/go-confero/p2p"
 	"github.com/confero-network/go-confero/params"
 	"github.com/confero-network/go-confero/trie"
 )
@@ -186,7 +187,7 @@ func (f *fetcherTester) insertChain(blocks types.Blocks) (int, error) {
 
 // dropPeer is an emulator for the peer removal, simply accumulating the various
 // peers dropped by the fetcher.
-func (f *fetcherTester) dropPeer(peer string) {
+func (f *fetcherTester) dropPeer(peer string, reason p2p.DiscReason) {
 	f.lock.Lock()
 	defer f.lock.Unlock()
 
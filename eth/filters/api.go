@@ -21,6 +21,7 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"math"
 	"math/big"
 	"sync"
 	"time"
@@ -28,7 +29,9 @@ import (
 	"github.com/confero-network/go-confero"
 	"github.com/confero-network/go-confero/common"
 	"github.com/confero-network/go-confero/common/hexutil"
+	"github.com/confero-network/go-confero/core"
 	"github.com/confero-network/go-confero/core/types"
+	"github.com/confero-network/go-confero/event"
 	"github.com/confero-network/go-confero/rpc"
 )
 
@@ -41,6 +44,17 @@ type filter struct {
 	crit     FilterCriteria
 	logs     []*types.Log
 	s        *Subscription // associated subscription in event system
+	stop     func()        // stops the stream of safe or finalized logs
+}
+
+// uninstall stops the delivery of events to the filter.
+func (f *filter) uninstall() {
+	if f.s != nil {
+		f.s.Unsubscribe()
+	}
+	if f.stop != nil {
+		f.stop()
+	}
 }
 
 // FilterAPI offers support to create and manage filters. This will allow external clients to retrieve various
@@ -69,7 +83,7 @@ func NewFilterAPI(system *FilterSystem, lightMode bool) *FilterAPI {
 // timeoutLoop runs at the interval set by 'timeout' and deletes filters
 // that have not been recently used. It is started when the API is created.
 func (api *FilterAPI) timeoutLoop(timeout time.Duration) {
-	var toUninstall []*Subscription
+	var toUninstall []*filter
 	ticker := time.NewTicker(timeout)
 	defer ticker.Stop()
 	for {
@@ -78,7 +92,7 @@ func (api *FilterAPI) timeoutLoop(timeout time.Duration) {
 		for id, f := range api.filters {
 			select {
 			case <-f.deadline.C:
-				toUninstall = append(toUninstall, f.s)
+				toUninstall = append(toUninstall, f)
 				delete(api.filters, id)
 			default:
 				continue
@@ -89,8 +103,8 @@ func (api *FilterAPI) timeoutLoop(timeout time.Duration) {
 		// Unsubscribes are processed outside the lock to avoid the following scenario:
 		// event loop attempts broadcasting events to still active filters while
 		// Unsubscribe is waiting for it to process the uninstall request.
-		for _, s := range toUninstall {
-			s.Unsubscribe()
+		for _, f := range toUninstall {
+			f.uninstall()
 		}
 		toUninstall = nil
 	}
@@ -200,8 +214,17 @@ func (api *FilterAPI) NewBlockFilter() rpc.ID {
 	return headerSub.ID
 }
 
+// HeadsCriteria holds the options of a new heads subscription.
+type HeadsCriteria struct {
+	// Finality is "safe" or "finalized" to receive the headers of the canonical
+	// chain in order as the safe or finalized block advances over them.
+	Finality string `json:"finality"`
+}
+
 // NewHeads send a notification each time a new (header) block is appended to the chain.
-func (api *FilterAPI) NewHeads(ctx context.Context) (*rpc.Subscription, error) {
+// If a finality is requested, notifications are sent for the blocks becoming safe or
+// finalized instead.
+func (api *FilterAPI) NewHeads(ctx context.Context, crit *HeadsCriteria) (*rpc.Subscription, error) {
 	notifier, supported := rpc.NotifierFromContext(ctx)
 	if !supported {
 		return &rpc.Subscription{}, rpc.ErrNotificationsUnsupported
@@ -209,6 +232,17 @@ func (api *FilterAPI) NewHeads(ctx context.Context) (*rpc.Subscription, error) {
 
 	rpcSub := notifier.CreateSubscription()
 
+	if crit != nil && crit.Finality != "" {
+		stream, err := newHeadStream(ctx, api.sys.backend, crit.Finality, func(h *types.Header) error {
+			return notifier.Notify(rpcSub.ID, h)
+		})
+		if err != nil {
+			return nil, err
+		}
+		api.watchStream(rpcSub, notifier, stream.run)
+		return rpcSub, nil
+	}
+
 	go func() {
 		headers := make(chan *types.Header)
 		headersSub := api.events.SubscribeNewHeads(headers)
@@ -231,6 +265,8 @@ func (api *FilterAPI) NewHeads(ctx context.Context) (*rpc.Subscription, error) {
 }
 
 // Logs creates a subscription that fires for all new log that match the given filter criteria.
+// If a finality is requested, the logs are delivered as the safe or finalized block
+// advances over their blocks instead.
 func (api *FilterAPI) Logs(ctx context.Context, crit FilterCriteria) (*rpc.Subscription, error) {
 	notifier, supported := rpc.NotifierFromContext(ctx)
 	if !supported {
@@ -242,6 +278,18 @@ func (api *FilterAPI) Logs(ctx context.Context, crit FilterCriteria) (*rpc.Subsc
 		matchedLogs = make(chan []*types.Log)
 	)
 
+	if crit.Finality != "" {
+		crit.After = nil // only resumable subscriptions carry cursors
+		stream, err := newLogStream(ctx, api.sys, crit, func(ev *confero.LogEvent) error {
+			return notifier.Notify(rpcSub.ID, &ev.Log)
+		})
+		if err != nil {
+			return nil, err
+		}
+		api.watchStream(rpcSub, notifier, stream.run)
+		return rpcSub, nil
+	}
+
 	logsSub, err := api.events.SubscribeLogs(confero.FilterQuery(crit), matchedLogs)
 	if err != nil {
 		return nil, err
@@ -268,6 +316,89 @@ func (api *FilterAPI) Logs(ctx context.Context, crit FilterCriteria) (*rpc.Subsc
 	return rpcSub, nil
 }
 
+// ResumableLogs creates a subscription that delivers all logs matching the given
+// filter criteria in chain order. It starts with the logs of past blocks from
+// "fromBlock", or following the "after" cursor, and continues with the logs of
+// new blocks. Logs of blocks dropped by a reorg are delivered again with the
+// removed flag set, latest first. Every event carries the cursor to resume the
+// subscription from after reconnecting. The "toBlock" criteria is ignored. With
+// a "finality" criteria, the logs are delivered as the safe or finalized block
+// advances over their blocks.
+func (api *FilterAPI) ResumableLogs(ctx context.Context, crit FilterCriteria) (*rpc.Subscription, error) {
+	notifier, supported := rpc.NotifierFromContext(ctx)
+	if !supported {
+		return &rpc.Subscription{}, rpc.ErrNotificationsUnsupported
+	}
+	rpcSub := notifier.CreateSubscription()
+
+	stream, err := newLogStream(ctx, api.sys, crit, func(ev *confero.LogEvent) error {
+		return notifier.Notify(rpcSub.ID, ev)
+	})
+	if err != nil {
+		return nil, err
+	}
+	api.watchStream(rpcSub, notifier, stream.run)
+	return rpcSub, nil
+}
+
+// watchStream runs a chain stream until the subscription ends.
+func (api *FilterAPI) watchStream(rpcSub *rpc.Subscription, notifier *rpc.Notifier, run func(context.Context, <-chan struct{})) {
+	stop := api.startStream(run)
+	go func() {
+		defer stop()
+		select {
+		case <-rpcSub.Err():
+		case <-notifier.Closed():
+		}
+	}()
+}
+
+// startStream runs a chain stream until the returned function is called. The
+// stream is woken up by new heads, and by changes of the safe and finalized
+// blocks if the backend reports them.
+func (api *FilterAPI) startStream(run func(context.Context, <-chan struct{})) func() {
+	var (
+		headers     = make(chan *types.Header)
+		headersSub  = api.events.SubscribeNewHeads(headers)
+		finality    = make(chan core.FinalityEvent)
+		finalitySub event.Subscription
+		wake        = make(chan struct{}, 1)
+		quit        = make(chan struct{})
+		once        sync.Once
+	)
+	if backend, ok := api.sys.backend.(FinalityBackend); ok {
+		finalitySub = backend.SubscribeFinalityEvent(finality)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		defer headersSub.Unsubscribe()
+		if finalitySub != nil {
+			defer finalitySub.Unsubscribe()
+		}
+		for {
+			select {
+			case <-headers:
+			case <-finality:
+			case <-quit:
+				return
+			}
+			// The stream may be busy delivering events, so the event system
+			// must not wait for it.
+			select {
+			case wake <- struct{}{}:
+			default:
+			}
+		}
+	}()
+	go run(ctx, wake)
+	return func() {
+		once.Do(func() {
+			cancel()
+			close(quit)
+		})
+	}
+}
+
 // FilterCriteria represents a request to create a new filter.
 // Same as confero.FilterQuery but with UnmarshalJSON() method.
 type FilterCriteria confero.FilterQuery
@@ -283,7 +414,13 @@ type FilterCriteria confero.FilterQuery
 // again but with the removed property set to true.
 //
 // In case "fromBlock" > "toBlock" an error is returned.
+//
+// If a finality is requested, the filter only returns the logs of safe or finalized
+// blocks, as the safe or finalized block advances over them.
 func (api *FilterAPI) NewFilter(crit FilterCriteria) (rpc.ID, error) {
+	if crit.Finality != "" {
+		return api.newFinalityFilter(crit)
+	}
 	logs := make(chan []*types.Log)
 	logsSub, err := api.events.SubscribeLogs(confero.FilterQuery(crit), logs)
 	if err != nil {
@@ -315,31 +452,83 @@ func (api *FilterAPI) NewFilter(crit FilterCriteria) (rpc.ID, error) {
 	return logsSub.ID, nil
 }
 
+// newFinalityFilter creates a log filter collecting the logs of safe or finalized
+// blocks.
+func (api *FilterAPI) newFinalityFilter(crit FilterCriteria) (rpc.ID, error) {
+	var (
+		id     = rpc.NewID()
+		stream = crit
+	)
+	stream.After = nil
+	s, err := newLogStream(context.Background(), api.sys, stream, func(ev *confero.LogEvent) error {
+		api.filtersMu.Lock()
+		defer api.filtersMu.Unlock()
+		if f, found := api.filters[id]; found {
+			log := ev.Log
+			f.logs = append(f.logs, &log)
+		}
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	api.filtersMu.Lock()
+	api.filters[id] = &filter{typ: LogsSubscription, crit: crit, deadline: time.NewTimer(api.timeout), logs: make([]*types.Log, 0), stop: api.startStream(s.run)}
+	api.filtersMu.Unlock()
+	return id, nil
+}
+
 // GetLogs returns logs matching the given argument that are stored within the state.
+//
+// The results can be paged through by setting the optional limit and after fields
+// of the criteria. The next page is requested by setting after to the position
+// of the last log of the previous page. If the query exceeds the configured
+// limits, the error carries the last block within the limits.
 func (api *FilterAPI) GetLogs(ctx context.Context, crit FilterCriteria) ([]*types.Log, error) {
+	filter, err := api.newLogFilter(crit)
+	if err != nil {
+		return nil, err
+	}
+	// Run the filter and return all the logs
+	logs, err := filter.Logs(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return returnLogs(logs), err
+}
+
+// newLogFilter creates the filter of a one-off log query.
+func (api *FilterAPI) newLogFilter(crit FilterCriteria) (*Filter, error) {
+	if crit.Limit < 0 {
+		return nil, errors.New("negative limit")
+	}
+	if limit := api.sys.cfg.MaxLogs; limit > 0 && crit.Limit > limit {
+		return nil, fmt.Errorf("limit exceeds the maximum of %d logs", limit)
+	}
 	var filter *Filter
 	if crit.BlockHash != nil {
 		// Block filter requested, construct a single-shot filter
 		filter = api.sys.NewBlockFilter(*crit.BlockHash, crit.Addresses, crit.Topics)
 	} else {
-		// Convert the RPC block numbers into internal representations
-		begin := rpc.LatestBlockNumber.Int64()
+		// Convert the RPC block numbers into internal representations, the
+		// range defaults to the latest, safe or finalized block
+		head, err := finalityBlock(crit.Finality)
+		if err != nil {
+			return nil, err
+		}
+		begin := head.Int64()
 		if crit.FromBlock != nil {
 			begin = crit.FromBlock.Int64()
 		}
-		end := rpc.LatestBlockNumber.Int64()
+		end := head.Int64()
 		if crit.ToBlock != nil {
 			end = crit.ToBlock.Int64()
 		}
 		// Construct the range filter
 		filter = api.sys.NewRangeFilter(begin, end, crit.Addresses, crit.Topics)
 	}
-	// Run the filter and return all the logs
-	logs, err := filter.Logs(ctx)
-	if err != nil {
-		return nil, err
-	}
-	return returnLogs(logs), err
+	filter.SetPage(crit.Limit, crit.After)
+	return filter, nil
 }
 
 // UninstallFilter removes the filter with the given filter id.
@@ -351,7 +540,7 @@ func (api *FilterAPI) UninstallFilter(id rpc.ID) bool {
 	}
 	api.filtersMu.Unlock()
 	if found {
-		f.s.Unsubscribe()
+		f.uninstall()
 	}
 
 	return found
@@ -367,23 +556,9 @@ func (api *FilterAPI) GetFilterLogs(ctx context.Context, id rpc.ID) ([]*types.Lo
 	if !found || f.typ != LogsSubscription {
 		return nil, fmt.Errorf("filter not found")
 	}
-
-	var filter *Filter
-	if f.crit.BlockHash != nil {
-		// Block filter requested, construct a single-shot filter
-		filter = api.sys.NewBlockFilter(*f.crit.BlockHash, f.crit.Addresses, f.crit.Topics)
-	} else {
-		// Convert the RPC block numbers into internal representations
-		begin := rpc.LatestBlockNumber.Int64()
-		if f.crit.FromBlock != nil {
-			begin = f.crit.FromBlock.Int64()
-		}
-		end := rpc.LatestBlockNumber.Int64()
-		if f.crit.ToBlock != nil {
-			end = f.crit.ToBlock.Int64()
-		}
-		// Construct the range filter
-		filter = api.sys.NewRangeFilter(begin, end, f.crit.Addresses, f.crit.Topics)
+	filter, err := api.newLogFilter(f.crit)
+	if err != nil {
+		return nil, err
 	}
 	// Run the filter and return all the logs
 	logs, err := filter.Logs(ctx)
@@ -446,11 +621,14 @@ func returnLogs(logs []*types.Log) []*types.Log {
 // UnmarshalJSON sets *args fields with given data.
 func (args *FilterCriteria) UnmarshalJSON(data []byte) error {
 	type input struct {
-		BlockHash *common.Hash     `json:"blockHash"`
-		FromBlock *rpc.BlockNumber `json:"fromBlock"`
-		ToBlock   *rpc.BlockNumber `json:"toBlock"`
-		Addresses interface{}      `json:"address"`
-		Topics    []interface{}    `json:"topics"`
+		BlockHash *common.Hash       `json:"blockHash"`
+		FromBlock *rpc.BlockNumber   `json:"fromBlock"`
+		ToBlock   *rpc.BlockNumber   `json:"toBlock"`
+		Addresses interface{}        `json:"address"`
+		Topics    []interface{}      `json:"topics"`
+		Limit     *rpc.DecimalOrHex  `json:"limit"`
+		After     *confero.LogCursor `json:"after"`
+		Finality  string             `json:"finality"`
 	}
 
 	var raw input
@@ -474,6 +652,19 @@ func (args *FilterCriteria) UnmarshalJSON(data []byte) error {
 		}
 	}
 
+	if raw.Limit != nil {
+		if *raw.Limit > math.MaxInt32 {
+			return errors.New("limit too large")
+		}
+		args.Limit = int(*raw.Limit)
+	}
+	args.After = raw.After
+
+	if _, err := finalityBlock(raw.Finality); err != nil {
+		return err
+	}
+	args.Finality = raw.Finality
+
 	args.Addresses = []common.Address{}
 
 	if raw.Addresses != nil {
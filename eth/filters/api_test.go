@@ -21,6 +21,7 @@ import (
 	"fmt"
 	"testing"
 
+	"github.com/confero-network/go-confero"
 	"github.com/confero-network/go-confero/common"
 	"github.com/confero-network/go-confero/rpc"
 )
@@ -182,4 +183,29 @@ func TestUnmarshalJSONNewFilterArgs(t *testing.T) {
 	if len(test7.Topics[2]) != 0 {
 		t.Fatalf("expected 0 topics, got %d topics", len(test7.Topics[2]))
 	}
+
+	// page
+	var test8 FilterCriteria
+	vector = fmt.Sprintf(`{"limit":100,"after":{"blockNumber":"0x10","blockHash":"%s","logIndex":"0x2"}}`, topic0.Hex())
+	if err := json.Unmarshal([]byte(vector), &test8); err != nil {
+		t.Fatal(err)
+	}
+	if test8.Limit != 100 {
+		t.Fatalf("expected limit 100, got %d", test8.Limit)
+	}
+	if test8.After == nil || test8.After.BlockNumber != 16 || test8.After.BlockHash != topic0 || test8.After.Index != 2 {
+		t.Fatalf("invalid cursor %+v", test8.After)
+	}
+
+	// finality
+	var test9 FilterCriteria
+	if err := json.Unmarshal([]byte(`{"finality":"finalized"}`), &test9); err != nil {
+		t.Fatal(err)
+	}
+	if test9.Finality != confero.FinalityFinalized {
+		t.Fatalf("expected finalized, got %q", test9.Finality)
+	}
+	if err := json.Unmarshal([]byte(`{"finality":"final"}`), &test9); err == nil {
+		t.Fatal("expected error for invalid finality")
+	}
 }
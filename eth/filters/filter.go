@@ -19,14 +19,54 @@ package filters
 import (
 	"context"
 	"errors"
+	"fmt"
 	"math/big"
+	"sort"
 
+	"github.com/confero-network/go-confero"
 	"github.com/confero-network/go-confero/common"
+	"github.com/confero-network/go-confero/common/hexutil"
 	"github.com/confero-network/go-confero/core/bloombits"
+	"github.com/confero-network/go-confero/core/rawdb"
 	"github.com/confero-network/go-confero/core/types"
 	"github.com/confero-network/go-confero/rpc"
 )
 
+var (
+	errInvalidCursor = errors.New("cursor does not refer to a canonical block")
+
+	// errPageFull stops a paginated query once the page is full.
+	errPageFull = errors.New("page full")
+)
+
+// errcodeLimitExceeded is the JSON-RPC error code of LimitError.
+const errcodeLimitExceeded = -32005
+
+// exactMatchChunk is the number of blocks whose log index entries are loaded and
+// intersected at once, bounding the memory used by a query over a long range.
+var exactMatchChunk = uint64(2048) // Variable for testing
+
+// LimitError is returned by log queries exceeding the configured limits. The
+// logs up to and including LastBlock don't exceed the limits, so a client can
+// query them separately and continue from the following block. If LastBlock
+// precedes the first block of the query, a single block exceeds the limits and
+// the results need to be paged through.
+type LimitError struct {
+	Message   string
+	LastBlock uint64
+}
+
+func (e *LimitError) Error() string {
+	return fmt.Sprintf("%s, last block within limits is %d", e.Message, e.LastBlock)
+}
+
+func (e *LimitError) ErrorCode() int { return errcodeLimitExceeded }
+
+// ErrorData returns the last block within the limits.
+func (e *LimitError) ErrorData() interface{} {
+	return map[string]interface{}{"lastBlock": hexutil.Uint64(e.LastBlock)}
+}
+
 // Filter can be used to retrieve and filter logs.
 type Filter struct {
 	sys *FilterSystem
@@ -37,6 +77,10 @@ type Filter struct {
 	block      *common.Hash // Block hash if filtering a single block
 	begin, end int64        // Range interval if filtering multiple blocks
 
+	limit int                // maximum number of logs to return, zero for all
+	after *confero.LogCursor // position after which logs are returned, nil for all
+	count int                // number of logs collected so far
+
 	matcher *bloombits.Matcher
 }
 
@@ -82,6 +126,13 @@ func (sys *FilterSystem) NewBlockFilter(block common.Hash, addresses []common.Ad
 	return filter
 }
 
+// SetPage restricts the results of the filter to a page of at most limit logs
+// following the log at the given position. A zero limit returns all logs, a nil
+// position starts at the beginning of the filter range.
+func (f *Filter) SetPage(limit int, after *confero.LogCursor) {
+	f.limit, f.after = limit, after
+}
+
 // newFilter creates a generic filter that can either filter based on a block hash,
 // or based on range queries. The search criteria needs to be explicitly set.
 func newFilter(sys *FilterSystem, addresses []common.Address, topics [][]common.Hash) *Filter {
@@ -94,9 +145,22 @@ func newFilter(sys *FilterSystem, addresses []common.Address, topics [][]common.
 
 // Logs searches the blockchain for matching log entries, returning all from the
 // first block that contains matches, updating the start of the filter accordingly.
+// If the results exceed the limits of the filter system, a *LimitError is returned.
 func (f *Filter) Logs(ctx context.Context) ([]*types.Log, error) {
+	logs, err := f.logs(ctx)
+	if err == errPageFull {
+		return logs, nil
+	}
+	return logs, err
+}
+
+// logs runs the filter, returning errPageFull if it stopped at a full page.
+func (f *Filter) logs(ctx context.Context) ([]*types.Log, error) {
 	// If we're doing singleton block filtering, execute and return
 	if f.block != nil {
+		if f.after != nil && f.after.BlockHash != *f.block {
+			return nil, errInvalidCursor
+		}
 		header, err := f.sys.backend.HeaderByHash(ctx, *f.block)
 		if err != nil {
 			return nil, err
@@ -104,7 +168,11 @@ func (f *Filter) Logs(ctx context.Context) ([]*types.Log, error) {
 		if header == nil {
 			return nil, errors.New("unknown block")
 		}
-		return f.blockLogs(ctx, header, false)
+		found, err := f.blockLogs(ctx, header, false)
+		if err != nil {
+			return nil, err
+		}
+		return f.collect(nil, found, header.Number.Uint64())
 	}
 	// Short-cut if all we care about is pending logs
 	if f.begin == rpc.PendingBlockNumber.Int64() {
@@ -129,32 +197,84 @@ func (f *Filter) Logs(ctx context.Context) ([]*types.Log, error) {
 	if f.end == rpc.LatestBlockNumber.Int64() || f.end == rpc.PendingBlockNumber.Int64() {
 		end = head
 	}
-	// Gather all indexed logs, and finish with non indexed ones
+	if f.begin == rpc.SafeBlockNumber.Int64() || f.begin == rpc.FinalizedBlockNumber.Int64() {
+		number, err := f.resolveTag(ctx, f.begin)
+		if err != nil {
+			return nil, err
+		}
+		f.begin = int64(number)
+	}
+	if f.end == rpc.SafeBlockNumber.Int64() || f.end == rpc.FinalizedBlockNumber.Int64() {
+		number, err := f.resolveTag(ctx, f.end)
+		if err != nil {
+			return nil, err
+		}
+		end = number
+	}
+	// Continue paging from the cursor, if it is still in the canonical chain
+	if f.after != nil {
+		header, err := f.sys.backend.HeaderByNumber(ctx, rpc.BlockNumber(f.after.BlockNumber))
+		if err != nil {
+			return nil, err
+		}
+		if header == nil || header.Hash() != f.after.BlockHash {
+			return nil, errInvalidCursor
+		}
+		if int64(f.after.BlockNumber) > f.begin {
+			f.begin = int64(f.after.BlockNumber)
+		}
+	}
+	if limit := f.sys.cfg.MaxBlockRange; limit > 0 && f.begin >= 0 && end >= uint64(f.begin) && end-uint64(f.begin) >= limit {
+		return nil, &LimitError{
+			Message:   fmt.Sprintf("block range exceeds the maximum of %d blocks", limit),
+			LastBlock: uint64(f.begin) + limit - 1,
+		}
+	}
+	// Gather the logs covered by the exact log index, then the ones covered by
+	// the bloom bits, and finish with non indexed ones
 	var (
-		logs           []*types.Log
-		err            error
-		size, sections = f.sys.backend.BloomStatus()
+		logs []*types.Log
+		err  error
 	)
-	if indexed := sections * size; indexed > uint64(f.begin) {
+	if backend, ok := f.sys.backend.(LogIndexBackend); ok && f.hasCriteria() {
+		size, sections := backend.LogIndexStatus()
+		if indexed := sections * size; indexed > uint64(f.begin) {
+			if indexed > end {
+				logs, err = f.exactLogs(ctx, end)
+			} else {
+				logs, err = f.exactLogs(ctx, indexed-1)
+			}
+			if err != nil {
+				return logs, err
+			}
+		}
+	}
+	size, sections := f.sys.backend.BloomStatus()
+	if indexed := sections * size; indexed > uint64(f.begin) && uint64(f.begin) <= end {
+		var found []*types.Log
 		if indexed > end {
-			logs, err = f.indexedLogs(ctx, end)
+			found, err = f.indexedLogs(ctx, end)
 		} else {
-			logs, err = f.indexedLogs(ctx, indexed-1)
+			found, err = f.indexedLogs(ctx, indexed-1)
 		}
+		logs = append(logs, found...)
 		if err != nil {
 			return logs, err
 		}
 	}
 	rest, err := f.unindexedLogs(ctx, end)
 	logs = append(logs, rest...)
+	if err != nil {
+		return logs, err
+	}
 	if pending {
 		pendingLogs, err := f.pendingLogs()
 		if err != nil {
 			return nil, err
 		}
-		logs = append(logs, pendingLogs...)
+		return f.collect(logs, pendingLogs, end+1)
 	}
-	return logs, err
+	return logs, nil
 }
 
 // indexedLogs returns the logs matching the filter criteria based on the bloom
@@ -196,7 +316,9 @@ func (f *Filter) indexedLogs(ctx context.Context, end uint64) ([]*types.Log, err
 			if err != nil {
 				return logs, err
 			}
-			logs = append(logs, found...)
+			if logs, err = f.collect(logs, found, number); err != nil {
+				return logs, err
+			}
 
 		case <-ctx.Done():
 			return logs, ctx.Err()
@@ -204,6 +326,140 @@ func (f *Filter) indexedLogs(ctx context.Context, end uint64) ([]*types.Log, err
 	}
 }
 
+// resolveTag returns the number of the safe or finalized block.
+func (f *Filter) resolveTag(ctx context.Context, tag int64) (uint64, error) {
+	header, err := f.sys.backend.HeaderByNumber(ctx, rpc.BlockNumber(tag))
+	if err != nil {
+		return 0, err
+	}
+	if header == nil {
+		name, _ := rpc.BlockNumber(tag).MarshalText()
+		return 0, fmt.Errorf("%s block not found", name)
+	}
+	return header.Number.Uint64(), nil
+}
+
+// logPosition is the position of a log in the chain.
+type logPosition struct {
+	number uint64
+	index  uint
+}
+
+// hasCriteria reports whether the filter restricts the addresses or topics of
+// the logs.
+func (f *Filter) hasCriteria() bool {
+	if len(f.addresses) > 0 {
+		return true
+	}
+	for _, topics := range f.topics {
+		if len(topics) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// exactLogs returns the logs matching the filter criteria based on the exact
+// log index. The range is processed in chunks of blocks, in order.
+func (f *Filter) exactLogs(ctx context.Context, end uint64) ([]*types.Log, error) {
+	var logs []*types.Log
+
+	for f.begin <= int64(end) {
+		if err := ctx.Err(); err != nil {
+			return logs, err
+		}
+		to := uint64(f.begin) + exactMatchChunk - 1
+		if to > end {
+			to = end
+		}
+		for _, number := range f.exactMatches(uint64(f.begin), to) {
+			if err := ctx.Err(); err != nil {
+				return logs, err
+			}
+			f.begin = int64(number) + 1
+
+			header, err := f.sys.backend.HeaderByNumber(ctx, rpc.BlockNumber(number))
+			if header == nil || err != nil {
+				return logs, err
+			}
+			found, err := f.checkMatches(ctx, header)
+			if err != nil {
+				return logs, err
+			}
+			if logs, err = f.collect(logs, found, number); err != nil {
+				return logs, err
+			}
+		}
+		f.begin = int64(to) + 1
+	}
+	return logs, nil
+}
+
+// exactMatches returns the numbers of the canonical blocks within the given
+// range which contain logs matching all address and topic clauses according to
+// the log index. Entries of blocks which were reorged out of the chain are
+// ignored.
+func (f *Filter) exactMatches(from, to uint64) []uint64 {
+	var (
+		db        = f.sys.backend.ChainDb()
+		canonical = make(map[uint64]common.Hash)
+		matches   map[logPosition]bool // positions matching all clauses so far, nil before the first
+	)
+	// lookup returns the positions of the already matching logs which contain
+	// any of the values.
+	lookup := func(kind byte, values [][]byte) map[logPosition]bool {
+		positions := make(map[logPosition]bool)
+		for _, value := range values {
+			for _, entry := range rawdb.ReadLogIndexEntries(db, kind, value, from, to) {
+				hash, ok := canonical[entry.Number]
+				if !ok {
+					hash = rawdb.ReadCanonicalHash(db, entry.Number)
+					canonical[entry.Number] = hash
+				}
+				if entry.Hash != hash {
+					continue
+				}
+				for _, index := range entry.Indices {
+					position := logPosition{entry.Number, index}
+					if matches == nil || matches[position] {
+						positions[position] = true
+					}
+				}
+			}
+		}
+		return positions
+	}
+	if len(f.addresses) > 0 {
+		values := make([][]byte, len(f.addresses))
+		for i, address := range f.addresses {
+			values[i] = address.Bytes()
+		}
+		matches = lookup(rawdb.LogIndexAddress, values)
+	}
+	for i, topics := range f.topics {
+		if len(topics) == 0 || (matches != nil && len(matches) == 0) {
+			continue
+		}
+		values := make([][]byte, len(topics))
+		for j, topic := range topics {
+			values[j] = topic.Bytes()
+		}
+		matches = lookup(rawdb.LogIndexTopic(i), values)
+	}
+	var (
+		numbers []uint64
+		seen    = make(map[uint64]bool)
+	)
+	for position := range matches {
+		if !seen[position.number] {
+			seen[position.number] = true
+			numbers = append(numbers, position.number)
+		}
+	}
+	sort.Slice(numbers, func(i, j int) bool { return numbers[i] < numbers[j] })
+	return numbers
+}
+
 // unindexedLogs returns the logs matching the filter criteria based on raw block
 // iteration and bloom matching.
 func (f *Filter) unindexedLogs(ctx context.Context, end uint64) ([]*types.Log, error) {
@@ -218,11 +474,37 @@ func (f *Filter) unindexedLogs(ctx context.Context, end uint64) ([]*types.Log, e
 		if err != nil {
 			return logs, err
 		}
-		logs = append(logs, found...)
+		if logs, err = f.collect(logs, found, uint64(f.begin)); err != nil {
+			return logs, err
+		}
 	}
 	return logs, nil
 }
 
+// collect adds the matching logs of a fully scanned block to the results. It
+// skips the logs up to the cursor, returns errPageFull once the page is full and
+// a *LimitError if the results exceed the maximum number of logs.
+func (f *Filter) collect(logs []*types.Log, found []*types.Log, number uint64) ([]*types.Log, error) {
+	if f.after != nil && number == f.after.BlockNumber {
+		for len(found) > 0 && found[0].Index <= f.after.Index {
+			found = found[1:]
+		}
+	}
+	if f.limit > 0 && f.count+len(found) >= f.limit {
+		found = found[:f.limit-f.count]
+		f.count += len(found)
+		return append(logs, found...), errPageFull
+	}
+	if limit := f.sys.cfg.MaxLogs; limit > 0 && f.count+len(found) > limit {
+		return nil, &LimitError{
+			Message:   fmt.Sprintf("query returned more than %d results", limit),
+			LastBlock: number - 1,
+		}
+	}
+	f.count += len(found)
+	return append(logs, found...), nil
+}
+
 // blockLogs returns the logs matching the filter criteria within a single block.
 func (f *Filter) blockLogs(ctx context.Context, header *types.Header, skipBloom bool) ([]*types.Log, error) {
 	// Fast track: no filtering criteria
@@ -39,8 +39,10 @@ import (
 
 // Config represents the configuration of the filter system.
 type Config struct {
-	LogCacheSize int           // maximum number of cached blocks (default: 32)
-	Timeout      time.Duration // how long filters stay active (default: 5min)
+	LogCacheSize  int           // maximum number of cached blocks (default: 32)
+	Timeout       time.Duration // how long filters stay active (default: 5min)
+	MaxBlockRange uint64        // maximum number of blocks scanned by a log query (0 = no limit)
+	MaxLogs       int           // maximum number of logs returned by a log query (0 = no limit)
 }
 
 func (cfg Config) withDefaults() Config {
@@ -71,6 +73,14 @@ type Backend interface {
 	ServiceFilter(ctx context.Context, session *bloombits.MatcherSession)
 }
 
+// LogIndexBackend is implemented by backends maintaining an exact log index in
+// their chain database, which filters use instead of the bloom bits if available.
+type LogIndexBackend interface {
+	// LogIndexStatus returns the section size of the log index and the number of
+	// sections indexed.
+	LogIndexStatus() (uint64, uint64)
+}
+
 // FilterSystem holds resources shared by all filters.
 type FilterSystem struct {
 	backend   Backend
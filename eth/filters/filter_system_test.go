@@ -42,6 +42,7 @@ import (
 type testBackend struct {
 	db              ethdb.Database
 	sections        uint64
+	logSections     uint64
 	txFeed          event.Feed
 	logsFeed        event.Feed
 	rmLogsFeed      event.Feed
@@ -58,8 +59,11 @@ func (b *testBackend) HeaderByNumber(ctx context.Context, blockNr rpc.BlockNumbe
 		hash common.Hash
 		num  uint64
 	)
-	if blockNr == rpc.LatestBlockNumber {
+	if blockNr == rpc.LatestBlockNumber || blockNr == rpc.FinalizedBlockNumber {
 		hash = rawdb.ReadHeadBlockHash(b.db)
+		if blockNr == rpc.FinalizedBlockNumber {
+			hash = rawdb.ReadFinalizedBlockHash(b.db)
+		}
 		number := rawdb.ReadHeaderNumber(b.db, hash)
 		if number == nil {
 			return nil, nil
@@ -120,6 +124,10 @@ func (b *testBackend) BloomStatus() (uint64, uint64) {
 	return params.BloomBitsBlocks, b.sections
 }
 
+func (b *testBackend) LogIndexStatus() (uint64, uint64) {
+	return params.BloomBitsBlocks, b.logSections
+}
+
 func (b *testBackend) ServiceFilter(ctx context.Context, session *bloombits.MatcherSession) {
 	requests := make(chan chan *bloombits.Retrieval)
 
@@ -19,8 +19,10 @@ package filters
 import (
 	"context"
 	"math/big"
+	"reflect"
 	"testing"
 
+	"github.com/confero-network/go-confero"
 	"github.com/confero-network/go-confero/common"
 	"github.com/confero-network/go-confero/consensus/ethash"
 	"github.com/confero-network/go-confero/core"
@@ -230,3 +232,185 @@ func TestFilters(t *testing.T) {
 		t.Error("expected 0 log, got", len(logs))
 	}
 }
+
+func TestFilterLimits(t *testing.T) {
+	var (
+		db     = rawdb.NewMemoryDatabase()
+		_, sys = newTestFilterSystem(t, db, Config{MaxBlockRange: 10, MaxLogs: 5})
+		addr   = common.BytesToAddress([]byte("logger"))
+
+		gspec   = core.Genesis{BaseFee: big.NewInt(params.InitialBaseFee)}
+		genesis = gspec.ToBlock()
+	)
+	gspec.MustCommit(db)
+
+	// Every block has three logs.
+	chain, receipts := core.GenerateChain(params.TestChainConfig, genesis, ethash.NewFaker(), db, 20, func(i int, gen *core.BlockGen) {
+		receipt := types.NewReceipt(nil, false, 0)
+		receipt.Logs = []*types.Log{{Address: addr}, {Address: addr}, {Address: addr}}
+		gen.AddUncheckedReceipt(receipt)
+		gen.AddUncheckedTx(types.NewTransaction(uint64(i), common.HexToAddress("0x1"), big.NewInt(1), 1, gen.BaseFee(), nil))
+	})
+	for i, block := range chain {
+		rawdb.WriteBlock(db, block)
+		rawdb.WriteCanonicalHash(db, block.Hash(), block.NumberU64())
+		rawdb.WriteHeadBlockHash(db, block.Hash())
+		rawdb.WriteReceipts(db, block.Hash(), block.NumberU64(), receipts[i])
+	}
+	checkLimitError := func(err error, lastBlock uint64) {
+		t.Helper()
+		limitErr, ok := err.(*LimitError)
+		if !ok {
+			t.Fatalf("expected limit error, got %v", err)
+		}
+		if limitErr.LastBlock != lastBlock {
+			t.Fatalf("wrong last block: have %d, want %d", limitErr.LastBlock, lastBlock)
+		}
+	}
+
+	// The block range is limited.
+	_, err := sys.NewRangeFilter(1, 20, []common.Address{addr}, nil).Logs(context.Background())
+	checkLimitError(err, 10)
+
+	// The number of results is limited, the error carries the last block within the limit.
+	_, err = sys.NewRangeFilter(3, 8, []common.Address{addr}, nil).Logs(context.Background())
+	checkLimitError(err, 3)
+
+	logs, err := sys.NewRangeFilter(3, 3, nil, nil).Logs(context.Background())
+	if err != nil || len(logs) != 3 {
+		t.Fatalf("wrong logs within limits: %d logs, error %v", len(logs), err)
+	}
+
+	// Pages cover all logs in order.
+	var (
+		all   []*types.Log
+		after *confero.LogCursor
+	)
+	for {
+		filter := sys.NewRangeFilter(2, 8, []common.Address{addr}, nil)
+		filter.SetPage(4, after)
+		page, err := filter.Logs(context.Background())
+		if err != nil {
+			t.Fatalf("page %d: %v", len(all)/4, err)
+		}
+		all = append(all, page...)
+		if len(page) < 4 {
+			break
+		}
+		after = confero.CursorOf(page[len(page)-1])
+	}
+	if len(all) != 7*3 {
+		t.Fatalf("wrong number of paged logs: have %d, want %d", len(all), 7*3)
+	}
+	for i, log := range all {
+		if log.BlockNumber != uint64(2+i/3) || log.Index != uint(i%3) {
+			t.Fatalf("log %d: wrong position %d/%d", i, log.BlockNumber, log.Index)
+		}
+	}
+
+	// Cursors of non-canonical blocks are rejected.
+	filter := sys.NewRangeFilter(2, 8, nil, nil)
+	filter.SetPage(4, &confero.LogCursor{BlockNumber: 3, BlockHash: common.Hash{1}})
+	if _, err := filter.Logs(context.Background()); err != errInvalidCursor {
+		t.Fatalf("wrong error for invalid cursor: %v", err)
+	}
+}
+
+func TestFilterLogIndex(t *testing.T) {
+	var (
+		db           = rawdb.NewMemoryDatabase()
+		backend, sys = newTestFilterSystem(t, db, Config{})
+		addr1        = common.BytesToAddress([]byte("addr1"))
+		addr2        = common.BytesToAddress([]byte("addr2"))
+		topic1       = common.BytesToHash([]byte("topic1"))
+		topic2       = common.BytesToHash([]byte("topic2"))
+
+		gspec   = core.Genesis{BaseFee: big.NewInt(params.InitialBaseFee)}
+		genesis = gspec.ToBlock()
+	)
+	gspec.MustCommit(db)
+	backend.logSections = 1
+
+	// Blocks 2 and 5 contain both addresses and topics, but only the first log
+	// of block 2 has addr1 and topic1. Block 8 has a log which is not indexed.
+	chain, receipts := core.GenerateChain(params.TestChainConfig, genesis, ethash.NewFaker(), db, 10, func(i int, gen *core.BlockGen) {
+		receipt := types.NewReceipt(nil, false, 0)
+		switch i + 1 {
+		case 2:
+			receipt.Logs = []*types.Log{{Address: addr1, Topics: []common.Hash{topic1}}, {Address: addr2, Topics: []common.Hash{topic2}}}
+		case 5:
+			receipt.Logs = []*types.Log{{Address: addr1, Topics: []common.Hash{topic2}}, {Address: addr2, Topics: []common.Hash{topic1}}}
+		case 8:
+			receipt.Logs = []*types.Log{{Address: addr1, Topics: []common.Hash{topic1}}}
+		default:
+			return
+		}
+		gen.AddUncheckedReceipt(receipt)
+		gen.AddUncheckedTx(types.NewTransaction(uint64(i), common.HexToAddress("0x1"), big.NewInt(1), 1, gen.BaseFee(), nil))
+	})
+	for i, block := range chain {
+		rawdb.WriteBlock(db, block)
+		rawdb.WriteCanonicalHash(db, block.Hash(), block.NumberU64())
+		rawdb.WriteHeadBlockHash(db, block.Hash())
+		rawdb.WriteReceipts(db, block.Hash(), block.NumberU64(), receipts[i])
+	}
+	index := func(kind byte, value []byte, number uint64, hash common.Hash, indices ...uint) {
+		rawdb.WriteLogIndexEntry(db, kind, value, &rawdb.LogIndexEntry{Number: number, Hash: hash, Indices: indices})
+	}
+	index(rawdb.LogIndexAddress, addr1.Bytes(), 2, chain[1].Hash(), 0)
+	index(rawdb.LogIndexAddress, addr2.Bytes(), 2, chain[1].Hash(), 1)
+	index(rawdb.LogIndexTopic(0), topic1.Bytes(), 2, chain[1].Hash(), 0)
+	index(rawdb.LogIndexTopic(0), topic2.Bytes(), 2, chain[1].Hash(), 1)
+	index(rawdb.LogIndexAddress, addr1.Bytes(), 5, chain[4].Hash(), 0)
+	index(rawdb.LogIndexAddress, addr2.Bytes(), 5, chain[4].Hash(), 1)
+	index(rawdb.LogIndexTopic(0), topic2.Bytes(), 5, chain[4].Hash(), 0)
+	index(rawdb.LogIndexTopic(0), topic1.Bytes(), 5, chain[4].Hash(), 1)
+
+	// Entries left behind by a reorged block are ignored.
+	index(rawdb.LogIndexAddress, addr1.Bytes(), 6, common.Hash{1}, 0)
+	index(rawdb.LogIndexTopic(0), topic1.Bytes(), 6, common.Hash{1}, 0)
+
+	tests := []struct {
+		addresses []common.Address
+		topics    [][]common.Hash
+		want      []uint64 // block numbers of the matching logs
+	}{
+		{[]common.Address{addr1}, nil, []uint64{2, 5}},
+		{[]common.Address{addr1, addr2}, nil, []uint64{2, 2, 5, 5}},
+		{nil, [][]common.Hash{{topic1}}, []uint64{2, 5}},
+		{[]common.Address{addr1}, [][]common.Hash{{topic1}}, []uint64{2}},
+		{[]common.Address{addr2}, [][]common.Hash{{topic1, topic2}}, []uint64{2, 5}},
+		{nil, [][]common.Hash{nil, {topic1}}, nil},
+	}
+	// Check the matches with the range processed at once and in chunks which
+	// split the matching blocks.
+	defer func(chunk uint64) { exactMatchChunk = chunk }(exactMatchChunk)
+	for _, chunk := range []uint64{exactMatchChunk, 2} {
+		exactMatchChunk = chunk
+		for i, test := range tests {
+			logs, err := sys.NewRangeFilter(1, 10, test.addresses, test.topics).Logs(context.Background())
+			if err != nil {
+				t.Fatalf("chunk %d, test %d: %v", chunk, i, err)
+			}
+			var have []uint64
+			for _, log := range logs {
+				have = append(have, log.BlockNumber)
+			}
+			if !reflect.DeepEqual(have, test.want) {
+				t.Errorf("chunk %d, test %d: wrong matching blocks: have %v, want %v", chunk, i, have, test.want)
+			}
+		}
+	}
+	// A cancelled query stops between chunks.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := sys.NewRangeFilter(1, 10, []common.Address{addr1}, nil).Logs(ctx); err != context.Canceled {
+		t.Fatalf("wrong error for cancelled query: have %v, want %v", err, context.Canceled)
+	}
+	// Blocks beyond the indexed sections are filtered without the index.
+	backend.logSections = 0
+	logs, err := sys.NewRangeFilter(1, 10, []common.Address{addr1}, [][]common.Hash{{topic1}}).Logs(context.Background())
+	if err != nil || len(logs) != 2 || logs[1].BlockNumber != 8 {
+		t.Fatalf("wrong logs without index: %d logs, error %v", len(logs), err)
+	}
+}
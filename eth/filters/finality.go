@@ -0,0 +1,137 @@
+// Copyright 2022 The go-confero Authors
+// This file is part of the go-confero library.
+//
+// The go-confero library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-confero library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-confero library. If not, see <http://www.gnu.org/licenses/>.
+
+package filters
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/confero-network/go-confero"
+	"github.com/confero-network/go-confero/core"
+	"github.com/confero-network/go-confero/core/types"
+	"github.com/confero-network/go-confero/event"
+	"github.com/confero-network/go-confero/log"
+	"github.com/confero-network/go-confero/rpc"
+)
+
+// FinalityBackend is implemented by backends posting events when the safe or
+// finalized block changes. Streams following the safe or finalized block are
+// woken up by them, and by new heads otherwise.
+type FinalityBackend interface {
+	SubscribeFinalityEvent(ch chan<- core.FinalityEvent) event.Subscription
+}
+
+// finalityBlock returns the tag of the block followed by streams of the given
+// finality.
+func finalityBlock(finality string) (rpc.BlockNumber, error) {
+	switch finality {
+	case "":
+		return rpc.LatestBlockNumber, nil
+	case confero.FinalitySafe:
+		return rpc.SafeBlockNumber, nil
+	case confero.FinalityFinalized:
+		return rpc.FinalizedBlockNumber, nil
+	}
+	return 0, fmt.Errorf("invalid finality %q", finality)
+}
+
+// headStream delivers the headers of the canonical chain in order, as the safe
+// or finalized block advances over them.
+type headStream struct {
+	backend Backend
+	head    rpc.BlockNumber // safe or finalized block tag
+	pos     *types.Header   // last delivered header
+	send    func(*types.Header) error
+}
+
+// newHeadStream creates a stream delivering the headers following the current
+// safe or finalized block through send.
+func newHeadStream(ctx context.Context, backend Backend, finality string, send func(*types.Header) error) (*headStream, error) {
+	head, err := finalityBlock(finality)
+	if err != nil {
+		return nil, err
+	}
+	pos, err := backend.HeaderByNumber(ctx, head)
+	if err != nil {
+		return nil, err
+	}
+	if pos == nil {
+		return nil, fmt.Errorf("%s block not found", finality)
+	}
+	return &headStream{backend: backend, head: head, pos: pos, send: send}, nil
+}
+
+// run delivers headers until the context is canceled. The stream checks for new
+// safe or finalized blocks whenever a value is received from wake.
+func (s *headStream) run(ctx context.Context, wake <-chan struct{}) {
+	for {
+		progressed, err := s.advance(ctx)
+		if err != nil && ctx.Err() == nil {
+			log.Debug("Header stream failed", "pos", s.pos.Number, "err", err)
+		}
+		if progressed && err == nil {
+			continue
+		}
+		select {
+		case <-wake:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// advance delivers the headers up to the current safe or finalized block. If
+// the last delivered header was reorged out of the chain, the stream continues
+// from its last canonical ancestor. It returns false if the position is at the
+// head of the stream.
+func (s *headStream) advance(ctx context.Context) (bool, error) {
+	head, err := s.backend.HeaderByNumber(ctx, s.head)
+	if err != nil || head == nil {
+		return false, err
+	}
+	for {
+		header, err := s.backend.HeaderByNumber(ctx, rpc.BlockNumber(s.pos.Number.Uint64()))
+		if err != nil {
+			return false, err
+		}
+		if header != nil && header.Hash() == s.pos.Hash() {
+			break
+		}
+		parent, err := s.backend.HeaderByHash(ctx, s.pos.ParentHash)
+		if err != nil || parent == nil {
+			return false, err
+		}
+		s.pos = parent
+	}
+	if s.pos.Number.Cmp(head.Number) >= 0 {
+		return false, nil
+	}
+	for number := s.pos.Number.Uint64() + 1; number <= head.Number.Uint64(); number++ {
+		header, err := s.backend.HeaderByNumber(ctx, rpc.BlockNumber(number))
+		if err != nil || header == nil {
+			return false, err
+		}
+		if header.ParentHash != s.pos.Hash() {
+			return true, nil // reorged while delivering, find the ancestor first
+		}
+		if err := s.send(header); err != nil {
+			return false, err
+		}
+		s.pos = header
+	}
+	return true, nil
+}
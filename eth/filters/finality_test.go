@@ -0,0 +1,137 @@
+// Copyright 2022 The go-confero Authors
+// This file is part of the go-confero library.
+//
+// The go-confero library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-confero library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-confero library. If not, see <http://www.gnu.org/licenses/>.
+
+package filters
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/confero-network/go-confero"
+	"github.com/confero-network/go-confero/common"
+	"github.com/confero-network/go-confero/core"
+	"github.com/confero-network/go-confero/core/rawdb"
+	"github.com/confero-network/go-confero/core/types"
+	"github.com/confero-network/go-confero/params"
+)
+
+func TestFinalizedLogStream(t *testing.T) {
+	var (
+		db     = rawdb.NewMemoryDatabase()
+		_, sys = newTestFilterSystem(t, db, Config{})
+		addr   = common.BytesToAddress([]byte("logger"))
+
+		gspec   = core.Genesis{BaseFee: big.NewInt(params.InitialBaseFee)}
+		genesis = gspec.MustCommit(db)
+	)
+	chain := makeLogChain(db, genesis, 10, common.Address{1}, addr, 1)
+	rawdb.WriteFinalizedBlockHash(db, chain[3].Hash())
+
+	var events []string
+	send := func(ev *confero.LogEvent) error {
+		events = append(events, fmt.Sprintf("%d/%d", ev.Log.BlockNumber, ev.Log.Index))
+		return nil
+	}
+	crit := FilterCriteria{FromBlock: big.NewInt(2), Addresses: []common.Address{addr}, Finality: confero.FinalityFinalized}
+	stream, err := newLogStream(context.Background(), sys, crit, send)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Only the logs up to the finalized block are delivered.
+	drainLogStream(t, stream)
+	if have, want := strings.Join(events, " "), "2/0 3/0 4/0"; have != want {
+		t.Fatalf("wrong finalized logs:\nhave %s\nwant %s", have, want)
+	}
+	// The logs of the next blocks follow the finalized block.
+	events = nil
+	rawdb.WriteFinalizedBlockHash(db, chain[5].Hash())
+	drainLogStream(t, stream)
+	if have, want := strings.Join(events, " "), "5/0 6/0"; have != want {
+		t.Fatalf("wrong finalized logs:\nhave %s\nwant %s", have, want)
+	}
+	// Streams without a start block begin after the finalized block.
+	events = nil
+	crit.FromBlock = nil
+	if stream, err = newLogStream(context.Background(), sys, crit, send); err != nil {
+		t.Fatal(err)
+	}
+	rawdb.WriteFinalizedBlockHash(db, chain[7].Hash())
+	drainLogStream(t, stream)
+	if have, want := strings.Join(events, " "), "7/0 8/0"; have != want {
+		t.Fatalf("wrong finalized logs:\nhave %s\nwant %s", have, want)
+	}
+	// Unknown finality levels are rejected.
+	crit.Finality = "final"
+	if _, err := newLogStream(context.Background(), sys, crit, send); err == nil {
+		t.Fatal("expected error for invalid finality")
+	}
+}
+
+func TestFinalizedHeadStream(t *testing.T) {
+	var (
+		db          = rawdb.NewMemoryDatabase()
+		backend, _  = newTestFilterSystem(t, db, Config{})
+		gspec       = core.Genesis{BaseFee: big.NewInt(params.InitialBaseFee)}
+		genesis     = gspec.MustCommit(db)
+		chain       = makeLogChain(db, genesis, 10, common.Address{1}, common.Address{}, 0)
+		headers     []string
+		advanceFull = func(s *headStream) {
+			for {
+				progressed, err := s.advance(context.Background())
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !progressed {
+					return
+				}
+			}
+		}
+	)
+	rawdb.WriteFinalizedBlockHash(db, chain[1].Hash())
+
+	send := func(h *types.Header) error {
+		headers = append(headers, h.Number.String())
+		return nil
+	}
+	stream, err := newHeadStream(context.Background(), backend, confero.FinalityFinalized, send)
+	if err != nil {
+		t.Fatal(err)
+	}
+	advanceFull(stream)
+	if len(headers) != 0 {
+		t.Fatalf("unexpected headers %v", headers)
+	}
+	// Every header is delivered as the finalized block advances over it.
+	rawdb.WriteFinalizedBlockHash(db, chain[4].Hash())
+	advanceFull(stream)
+	if have, want := strings.Join(headers, " "), "3 4 5"; have != want {
+		t.Fatalf("wrong headers: have %s, want %s", have, want)
+	}
+	// Following a reorg, the stream continues from the common ancestor.
+	headers = nil
+	for i := uint64(5); i <= 10; i++ {
+		rawdb.DeleteCanonicalHash(db, i)
+	}
+	fork := makeLogChain(db, chain[3], 4, common.Address{2}, common.Address{}, 0)
+	rawdb.WriteFinalizedBlockHash(db, fork[2].Hash())
+	advanceFull(stream)
+	if have, want := strings.Join(headers, " "), "5 6 7"; have != want {
+		t.Fatalf("wrong headers after reorg: have %s, want %s", have, want)
+	}
+}
@@ -0,0 +1,287 @@
+// Copyright 2022 The go-confero Authors
+// This file is part of the go-confero library.
+//
+// The go-confero library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-confero library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-confero library. If not, see <http://www.gnu.org/licenses/>.
+
+package filters
+
+import (
+	"context"
+	"errors"
+
+	"github.com/confero-network/go-confero"
+	"github.com/confero-network/go-confero/common"
+	"github.com/confero-network/go-confero/core/types"
+	"github.com/confero-network/go-confero/log"
+	"github.com/confero-network/go-confero/rpc"
+)
+
+const (
+	// logStreamBlocks is the maximum number of blocks scanned at once by a log
+	// stream.
+	logStreamBlocks = 1000
+
+	// logStreamPage is the maximum number of logs retrieved at once by a log
+	// stream.
+	logStreamPage = 1000
+)
+
+var (
+	errUnknownCursor = errors.New("unknown cursor block")
+	errFutureBlock   = errors.New("fromBlock is beyond the current head")
+)
+
+// logStream delivers the logs matching a filter criteria in chain order. Instead
+// of relying on chain events, it reads the logs from the chain at its own pace,
+// so it never drops logs, and follows reorgs by delivering the logs of the
+// dropped blocks again as removed.
+//
+// The position of the stream is the cursor of the last delivered log, or the end
+// of the last scanned block. All matching logs of the chain up to the position
+// were delivered, except for the blocks preceding the start of the stream.
+//
+// The stream follows the head, the safe or the finalized block of the chain.
+type logStream struct {
+	sys       *FilterSystem
+	addresses []common.Address
+	topics    [][]common.Hash
+	head      rpc.BlockNumber   // latest, safe or finalized block tag
+	start     uint64            // first block whose logs are delivered
+	pos       confero.LogCursor // position of the stream
+	send      func(*confero.LogEvent) error
+}
+
+// newLogStream creates a stream delivering the logs matching crit through send,
+// starting after crit.After, at crit.FromBlock or at the current head of the
+// stream.
+func newLogStream(ctx context.Context, sys *FilterSystem, crit FilterCriteria, send func(*confero.LogEvent) error) (*logStream, error) {
+	if crit.BlockHash != nil {
+		return nil, errors.New("block hash filters are not supported")
+	}
+	head, err := finalityBlock(crit.Finality)
+	if err != nil {
+		return nil, err
+	}
+	s := &logStream{
+		sys:       sys,
+		addresses: crit.Addresses,
+		topics:    crit.Topics,
+		head:      head,
+		send:      send,
+	}
+	if crit.After != nil {
+		// Cursors of blocks which were reorged out of the chain are accepted, the
+		// logs delivered from them are removed when the stream starts.
+		header, err := sys.backend.HeaderByHash(ctx, crit.After.BlockHash)
+		if err != nil {
+			return nil, err
+		}
+		if header == nil || header.Number.Uint64() != crit.After.BlockNumber {
+			return nil, errUnknownCursor
+		}
+		s.pos = *crit.After
+		return s, nil
+	}
+	var start *types.Header
+	if crit.FromBlock != nil && crit.FromBlock.Sign() >= 0 {
+		latest, err := sys.backend.HeaderByNumber(ctx, rpc.LatestBlockNumber)
+		if err != nil {
+			return nil, err
+		}
+		number := crit.FromBlock.Uint64()
+		if latest == nil || number > latest.Number.Uint64()+1 {
+			return nil, errFutureBlock
+		}
+		// The stream starts at the end of the block preceding the first one.
+		s.start = number
+		if number > 0 {
+			number--
+		}
+		start, err = sys.backend.HeaderByNumber(ctx, rpc.BlockNumber(number))
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		if start, err = sys.backend.HeaderByNumber(ctx, s.head); err != nil {
+			return nil, err
+		}
+		if start != nil {
+			s.start = start.Number.Uint64() + 1
+		}
+	}
+	if start == nil {
+		return nil, errors.New("unknown start block")
+	}
+	s.pos = confero.LogCursor{BlockNumber: start.Number.Uint64(), BlockHash: start.Hash(), Index: confero.LogCursorBlockEnd}
+	return s, nil
+}
+
+// run delivers logs until the context is canceled. The stream checks for new
+// blocks whenever a value is received from wake. Failures are retried then too.
+func (s *logStream) run(ctx context.Context, wake <-chan struct{}) {
+	for {
+		err := s.rewind(ctx)
+		progressed := false
+		if err == nil {
+			progressed, err = s.advance(ctx)
+		}
+		if err != nil && ctx.Err() == nil {
+			log.Debug("Log stream failed", "pos", s.pos.BlockNumber, "err", err)
+		}
+		if progressed && err == nil {
+			continue
+		}
+		select {
+		case <-wake:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// canonical reports whether the block with the given number and hash is part of
+// the canonical chain.
+func (s *logStream) canonical(ctx context.Context, number uint64, hash common.Hash) (bool, error) {
+	header, err := s.sys.backend.HeaderByNumber(ctx, rpc.BlockNumber(number))
+	if err != nil {
+		return false, err
+	}
+	return header != nil && header.Hash() == hash, nil
+}
+
+// rewind moves the position back to the canonical chain if the block it is in
+// was reorged out, delivering the logs of the dropped blocks as removed, latest
+// first.
+func (s *logStream) rewind(ctx context.Context) error {
+	for {
+		ok, err := s.canonical(ctx, s.pos.BlockNumber, s.pos.BlockHash)
+		if err != nil || ok {
+			return err
+		}
+		header, err := s.sys.backend.HeaderByHash(ctx, s.pos.BlockHash)
+		if err != nil {
+			return err
+		}
+		if header == nil {
+			return errUnknownCursor
+		}
+		list, err := s.sys.cachedGetLogs(ctx, header.Hash(), header.Number.Uint64())
+		if err != nil {
+			return err
+		}
+		var (
+			logs   = filterLogs(flatten(list), nil, nil, s.addresses, s.topics)
+			parent = confero.LogCursor{BlockNumber: header.Number.Uint64() - 1, BlockHash: header.ParentHash, Index: confero.LogCursorBlockEnd}
+		)
+		if header.Number.Uint64() < s.start {
+			logs = nil // never delivered
+		}
+		for i := len(logs) - 1; i >= 0; i-- {
+			if logs[i].Index > s.pos.Index {
+				continue // not delivered yet
+			}
+			removed := *logs[i]
+			removed.Removed = true
+
+			s.pos = parent
+			if i > 0 {
+				s.pos = *confero.CursorOf(logs[i-1])
+			}
+			if err := s.send(&confero.LogEvent{Log: removed, Cursor: s.pos}); err != nil {
+				return err
+			}
+		}
+		s.pos = parent
+	}
+}
+
+// advance delivers the logs of the next blocks of the canonical chain. It
+// returns false if the position is at the head of the stream.
+func (s *logStream) advance(ctx context.Context) (bool, error) {
+	head, err := s.sys.backend.HeaderByNumber(ctx, s.head)
+	if err != nil || head == nil {
+		return false, err
+	}
+	var (
+		begin = s.pos.BlockNumber
+		after *confero.LogCursor
+	)
+	if s.pos.Index == confero.LogCursorBlockEnd {
+		begin++
+	} else {
+		pos := s.pos
+		after = &pos
+	}
+	if begin > head.Number.Uint64() {
+		return false, nil
+	}
+	blocks := uint64(logStreamBlocks)
+	if limit := s.sys.cfg.MaxBlockRange; limit > 0 && limit < blocks {
+		blocks = limit
+	}
+	end := begin + blocks - 1
+	if end > head.Number.Uint64() {
+		end = head.Number.Uint64()
+	}
+	last, err := s.sys.backend.HeaderByNumber(ctx, rpc.BlockNumber(end))
+	if err != nil || last == nil {
+		return false, err
+	}
+	page := logStreamPage
+	if limit := s.sys.cfg.MaxLogs; limit > 0 && limit < page {
+		page = limit
+	}
+	filter := s.sys.NewRangeFilter(int64(begin), int64(end), s.addresses, s.topics)
+	filter.SetPage(page, after)
+	logs, err := filter.Logs(ctx)
+	if err == errInvalidCursor {
+		return true, nil // reorged while scanning, rewind first
+	}
+	if err != nil {
+		return false, err
+	}
+	// The chain may have been reorganized while scanning, in which case the
+	// logs may not belong to a single chain, so drop them and rewind first.
+	if ok, err := s.unchanged(ctx, last, logs); err != nil || !ok {
+		return err == nil, err
+	}
+	for _, log := range logs {
+		s.pos = *confero.CursorOf(log)
+		if err := s.send(&confero.LogEvent{Log: *log, Cursor: s.pos}); err != nil {
+			return false, err
+		}
+	}
+	if len(logs) < page {
+		s.pos = confero.LogCursor{BlockNumber: end, BlockHash: last.Hash(), Index: confero.LogCursorBlockEnd}
+	}
+	return true, nil
+}
+
+// unchanged reports whether the position of the stream, the last scanned block
+// and the blocks of the scanned logs are still canonical.
+func (s *logStream) unchanged(ctx context.Context, last *types.Header, logs []*types.Log) (bool, error) {
+	blocks := map[uint64]common.Hash{
+		s.pos.BlockNumber:    s.pos.BlockHash,
+		last.Number.Uint64(): last.Hash(),
+	}
+	for _, log := range logs {
+		blocks[log.BlockNumber] = log.BlockHash
+	}
+	for number, hash := range blocks {
+		if ok, err := s.canonical(ctx, number, hash); err != nil || !ok {
+			return false, err
+		}
+	}
+	return true, nil
+}
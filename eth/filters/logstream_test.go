@@ -0,0 +1,152 @@
+// Copyright 2022 The go-confero Authors
+// This file is part of the go-confero library.
+//
+// The go-confero library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-confero library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-confero library. If not, see <http://www.gnu.org/licenses/>.
+
+package filters
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/confero-network/go-confero"
+	"github.com/confero-network/go-confero/common"
+	"github.com/confero-network/go-confero/consensus/ethash"
+	"github.com/confero-network/go-confero/core"
+	"github.com/confero-network/go-confero/core/rawdb"
+	"github.com/confero-network/go-confero/core/types"
+	"github.com/confero-network/go-confero/ethdb"
+	"github.com/confero-network/go-confero/params"
+)
+
+// makeLogChain generates blocks on top of parent, each containing the given
+// number of logs of addr, and stores them as the canonical chain.
+func makeLogChain(db ethdb.Database, parent *types.Block, n int, coinbase common.Address, addr common.Address, logs int) []*types.Block {
+	blocks, receipts := core.GenerateChain(params.TestChainConfig, parent, ethash.NewFaker(), db, n, func(i int, gen *core.BlockGen) {
+		gen.SetCoinbase(coinbase)
+		receipt := types.NewReceipt(nil, false, 0)
+		for j := 0; j < logs; j++ {
+			receipt.Logs = append(receipt.Logs, &types.Log{Address: addr})
+		}
+		gen.AddUncheckedReceipt(receipt)
+		gen.AddUncheckedTx(types.NewTransaction(uint64(i), common.HexToAddress("0x1"), big.NewInt(1), 1, gen.BaseFee(), nil))
+	})
+	for i, block := range blocks {
+		rawdb.WriteBlock(db, block)
+		rawdb.WriteCanonicalHash(db, block.Hash(), block.NumberU64())
+		rawdb.WriteHeadBlockHash(db, block.Hash())
+		rawdb.WriteReceipts(db, block.Hash(), block.NumberU64(), receipts[i])
+	}
+	return blocks
+}
+
+// drainLogStream delivers logs until the stream reaches the head of the chain.
+func drainLogStream(t *testing.T, s *logStream) {
+	t.Helper()
+	for {
+		if err := s.rewind(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		progressed, err := s.advance(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !progressed {
+			return
+		}
+	}
+}
+
+func TestLogStream(t *testing.T) {
+	var (
+		db     = rawdb.NewMemoryDatabase()
+		_, sys = newTestFilterSystem(t, db, Config{MaxBlockRange: 4, MaxLogs: 3})
+		addr   = common.BytesToAddress([]byte("logger"))
+
+		gspec   = core.Genesis{BaseFee: big.NewInt(params.InitialBaseFee)}
+		genesis = gspec.MustCommit(db)
+	)
+	// Every block of the first chain has two logs.
+	chain := makeLogChain(db, genesis, 10, common.Address{1}, addr, 2)
+
+	var (
+		events []string
+		last   confero.LogEvent
+	)
+	send := func(ev *confero.LogEvent) error {
+		events = append(events, fmt.Sprintf("%d/%d/%v", ev.Log.BlockNumber, ev.Log.Index, ev.Log.Removed))
+		last = *ev
+		if !ev.Log.Removed && ev.Cursor != *confero.CursorOf(&ev.Log) {
+			t.Fatalf("wrong cursor %+v for log %d/%d", ev.Cursor, ev.Log.BlockNumber, ev.Log.Index)
+		}
+		return nil
+	}
+	crit := FilterCriteria{FromBlock: big.NewInt(7), Addresses: []common.Address{addr}}
+	stream, err := newLogStream(context.Background(), sys, crit, send)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Past logs are delivered in order, across pages and block windows.
+	drainLogStream(t, stream)
+	if have, want := strings.Join(events, " "), "7/0/false 7/1/false 8/0/false 8/1/false 9/0/false 9/1/false 10/0/false 10/1/false"; have != want {
+		t.Fatalf("wrong backfilled logs:\nhave %s\nwant %s", have, want)
+	}
+	resume := last.Cursor
+
+	// Reorg to a longer chain forking after block 8, with a single log per block.
+	events = nil
+	rawdb.DeleteCanonicalHash(db, 9)
+	rawdb.DeleteCanonicalHash(db, 10)
+	fork := makeLogChain(db, chain[7], 3, common.Address{2}, addr, 1)
+
+	drainLogStream(t, stream)
+	if have, want := strings.Join(events, " "), "10/1/true 10/0/true 9/1/true 9/0/true 9/0/false 10/0/false 11/0/false"; have != want {
+		t.Fatalf("wrong reorg logs:\nhave %s\nwant %s", have, want)
+	}
+	if last.Cursor.BlockHash != fork[2].Hash() {
+		t.Fatalf("wrong final cursor %+v", last.Cursor)
+	}
+
+	// Resuming from a cursor of the dropped chain removes the delivered logs first.
+	events = nil
+	crit = FilterCriteria{After: &resume, Addresses: []common.Address{addr}}
+	if stream, err = newLogStream(context.Background(), sys, crit, send); err != nil {
+		t.Fatal(err)
+	}
+	drainLogStream(t, stream)
+	if have, want := strings.Join(events, " "), "10/1/true 10/0/true 9/1/true 9/0/true 9/0/false 10/0/false 11/0/false"; have != want {
+		t.Fatalf("wrong resumed logs:\nhave %s\nwant %s", have, want)
+	}
+	// The cursor of the last removal refers to the end of the fork block.
+	crit = FilterCriteria{After: &confero.LogCursor{BlockNumber: 9, BlockHash: chain[8].Hash(), Index: 0}, Addresses: []common.Address{addr}}
+	events = nil
+	if stream, err = newLogStream(context.Background(), sys, crit, send); err != nil {
+		t.Fatal(err)
+	}
+	if err := stream.rewind(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	want := confero.LogCursor{BlockNumber: 8, BlockHash: chain[7].Hash(), Index: confero.LogCursorBlockEnd}
+	if strings.Join(events, " ") != "9/0/true" || last.Cursor != want {
+		t.Fatalf("wrong removal %v with cursor %+v", events, last.Cursor)
+	}
+	// Unknown cursors are rejected.
+	crit = FilterCriteria{After: &confero.LogCursor{BlockNumber: 9, BlockHash: common.Hash{1}}}
+	if _, err := newLogStream(context.Background(), sys, crit, send); err != errUnknownCursor {
+		t.Fatalf("wrong error for unknown cursor: %v", err)
+	}
+}
@@ -18,6 +18,7 @@ package eth
 
 import (
 	"errors"
+	"fmt"
 	"math"
 	"math/big"
 	"sync"
@@ -29,8 +30,10 @@ import (
 	"github.com/confero-network/go-confero/consensus/beacon"
 	"github.com/confero-network/go-confero/core"
 	"github.com/confero-network/go-confero/core/forkid"
+	"github.com/confero-network/go-confero/core/rawdb"
 	"github.com/confero-network/go-confero/core/types"
 	"github.com/confero-network/go-confero/eth/downloader"
+	"github.com/confero-network/go-confero/eth/ethconfig"
 	"github.com/confero-network/go-confero/eth/fetcher"
 	"github.com/confero-network/go-confero/eth/protocols/eth"
 	"github.com/confero-network/go-confero/eth/protocols/snap"
@@ -87,6 +90,8 @@ type handlerConfig struct {
 	EventMux       *event.TypeMux            // Legacy event mux, deprecate for `feed`
 	Checkpoint     *params.TrustedCheckpoint // Hard coded checkpoint for sync challenges
 	RequiredBlocks map[uint64]common.Hash    // Hard coded map of required block hashes for sync challenges
+	SnapServing    *snap.ServingQueue        // Limiter of serving snap requests, unlimited if nil
+	SyncFrom       *ethconfig.SyncCheckpoint // Trusted block to start syncing the chain from
 }
 
 type handler struct {
@@ -99,6 +104,9 @@ type handler struct {
 	checkpointNumber uint64      // Block number for the sync progress validator to cross reference
 	checkpointHash   common.Hash // Block hash for the sync progress validator to cross reference
 
+	syncFrom       *ethconfig.SyncCheckpoint // Trusted block to start syncing the chain from
+	syncFromStatus uint32                    // Progress of syncing from the trusted block
+
 	database ethdb.Database
 	txpool   txPool
 	chain    *core.BlockChain
@@ -116,6 +124,7 @@ type handler struct {
 	minedBlockSub *event.TypeMuxSubscription
 
 	requiredBlocks map[uint64]common.Hash
+	snapServing    *snap.ServingQueue
 
 	// channels for fetcher, syncer, txsyncLoop
 	quitSync chan struct{}
@@ -141,6 +150,7 @@ func newHandler(config *handlerConfig) (*handler, error) {
 		peers:          newPeerSet(),
 		merger:         config.Merger,
 		requiredBlocks: config.RequiredBlocks,
+		snapServing:    config.SnapServing,
 		quitSync:       make(chan struct{}),
 	}
 	if config.Sync == downloader.FullSync {
@@ -166,6 +176,34 @@ func newHandler(config *handlerConfig) (*handler, error) {
 			h.snapSync = uint32(1)
 		}
 	}
+	// If the chain should start from a trusted block, hold back the legacy sync
+	// until a peer delivers its header. Restarts of an unfinished checkpoint sync
+	// are resumed the same way. A checkpoint contradicting the local chain is a
+	// configuration error.
+	if cp := config.SyncFrom; cp != nil && cp.Number > 0 {
+		if number := rawdb.ReadHeaderNumber(config.Database, cp.Hash); number != nil {
+			if *number != cp.Number {
+				return nil, fmt.Errorf("sync checkpoint number mismatch: block %x is #%d, not #%d", cp.Hash, *number, cp.Number)
+			}
+			if td := rawdb.ReadTd(config.Database, cp.Hash, cp.Number); td != nil && td.Cmp(cp.TD) != 0 {
+				return nil, fmt.Errorf("sync checkpoint total difficulty mismatch: have %v, want %v", td, cp.TD)
+			}
+		}
+		if hash := rawdb.ReadCanonicalHash(config.Database, cp.Number); hash != (common.Hash{}) && hash != cp.Hash {
+			return nil, fmt.Errorf("sync checkpoint hash mismatch: canonical block #%d is %x, not %x", cp.Number, hash, cp.Hash)
+		}
+		if head := h.chain.CurrentBlock().NumberU64(); head == 0 {
+			if atomic.LoadUint32(&h.snapSync) == 0 {
+				log.Warn("Switch sync mode to snap sync for checkpoint sync")
+			}
+			h.snapSync = uint32(1)
+			h.syncFrom = cp
+			h.syncFromStatus = syncFromPending
+			log.Info("Waiting for sync checkpoint header", "number", cp.Number, "hash", cp.Hash)
+		} else if head < cp.Number {
+			log.Warn("Chain already initialised, ignoring sync checkpoint", "head", head, "checkpoint", cp.Number)
+		}
+	}
 	// If we have trusted checkpoints, enforce them on the chain
 	if config.Checkpoint != nil {
 		h.checkpointNumber = (config.Checkpoint.SectionIndex+1)*params.CHTFrequency - 1
@@ -432,7 +470,7 @@ func (h *handler) runEthPeer(peer *eth.Peer, handler eth.Handler) error {
 
 			case <-timeout.C:
 				peer.Log().Warn("Checkpoint challenge timed out, dropping", "addr", peer.RemoteAddr(), "type", peer.Name())
-				h.removePeer(peer.ID())
+				h.removePeer(peer.ID(), p2p.DiscReadTimeout)
 
 			case <-dead:
 				// Peer handler terminated, abort all goroutines
@@ -477,10 +515,16 @@ func (h *handler) runEthPeer(peer *eth.Peer, handler eth.Handler) error {
 				res.Done <- nil
 			case <-timeout.C:
 				peer.Log().Warn("Required block challenge timed out, dropping", "addr", peer.RemoteAddr(), "type", peer.Name())
-				h.removePeer(peer.ID())
+				h.removePeer(peer.ID(), p2p.DiscReadTimeout)
 			}
 		}(number, hash, req)
 	}
+	// If the chain should start from a trusted block, request its header
+	if atomic.LoadUint32(&h.syncFromStatus) == syncFromPending {
+		if err := h.requestSyncCheckpoint(peer, dead); err != nil {
+			return err
+		}
+	}
 	// Handle incoming messages until the connection is torn down
 	return handler(peer)
 }
@@ -500,12 +544,21 @@ func (h *handler) runSnapExtension(peer *snap.Peer, handler snap.Handler) error
 	return handler(peer)
 }
 
-// removePeer requests disconnection of a peer.
-func (h *handler) removePeer(id string) {
+// removePeer requests disconnection of a peer. The reputation of the peer is
+// penalized according to the reason: mildly for timeouts and stalls, severely
+// for invalid data.
+func (h *handler) removePeer(id string, reason p2p.DiscReason) {
 	peer := h.peers.peer(id)
-	if peer != nil {
-		peer.Peer.Disconnect(p2p.DiscUselessPeer)
+	if peer == nil {
+		return
+	}
+	switch reason {
+	case p2p.DiscReadTimeout:
+		peer.Peer.Report(p2p.ScoreTimeout)
+	case p2p.DiscProtocolError:
+		peer.Peer.Report(p2p.ScoreBadData)
 	}
+	peer.Peer.Disconnect(reason)
 }
 
 // unregisterPeer removes a peer from the downloader, fetchers and main peer set.
@@ -33,6 +33,7 @@ import (
 	"github.com/confero-network/go-confero/core/types"
 	"github.com/confero-network/go-confero/core/vm"
 	"github.com/confero-network/go-confero/eth/downloader"
+	"github.com/confero-network/go-confero/eth/ethconfig"
 	"github.com/confero-network/go-confero/eth/protocols/eth"
 	"github.com/confero-network/go-confero/event"
 	"github.com/confero-network/go-confero/p2p"
@@ -745,3 +746,116 @@ func testBroadcastMalformedBlock(t *testing.T, protocol uint) {
 		}
 	}
 }
+
+// Tests that the header of a trusted sync checkpoint is requested from new
+// peers and the checkpoint sync is started if it matches.
+func TestSyncCheckpointRequest(t *testing.T) {
+	t.Run("match", func(t *testing.T) { testSyncCheckpointRequest(t, 0, false) })
+	t.Run("wrong hash", func(t *testing.T) { testSyncCheckpointRequest(t, 0, true) })
+	t.Run("wrong number", func(t *testing.T) { testSyncCheckpointRequest(t, 1, false) })
+}
+
+func testSyncCheckpointRequest(t *testing.T, numberOffset uint64, wrongHash bool) {
+	handler := newTestHandler()
+	defer handler.close()
+
+	response := &types.Header{Number: big.NewInt(1000), Extra: []byte("checkpoint")}
+	handler.handler.syncFrom = &ethconfig.SyncCheckpoint{Number: 1000 + numberOffset, Hash: response.Hash(), TD: big.NewInt(1000000)}
+	atomic.StoreUint32(&handler.handler.syncFromStatus, syncFromPending)
+
+	if op := handler.handler.chainSync.nextSyncOp(); op != nil {
+		t.Fatal("legacy sync scheduled while waiting for the checkpoint")
+	}
+	p2pLocal, p2pRemote := p2p.MsgPipe()
+	defer p2pLocal.Close()
+	defer p2pRemote.Close()
+
+	local := eth.NewPeer(eth.ETH66, p2p.NewPeerPipe(enode.ID{1}, "", nil, p2pLocal), p2pLocal, handler.txpool)
+	remote := eth.NewPeer(eth.ETH66, p2p.NewPeerPipe(enode.ID{2}, "", nil, p2pRemote), p2pRemote, handler.txpool)
+	defer local.Close()
+	defer remote.Close()
+
+	handlerDone := make(chan struct{})
+	go func() {
+		defer close(handlerDone)
+		handler.handler.runEthPeer(local, func(peer *eth.Peer) error {
+			return eth.Handle((*ethHandler)(handler.handler), peer)
+		})
+	}()
+	var (
+		genesis = handler.chain.Genesis()
+		head    = handler.chain.CurrentBlock()
+		td      = handler.chain.GetTd(head.Hash(), head.NumberU64())
+	)
+	if err := remote.Handshake(1, td, head.Hash(), genesis.Hash(), forkid.NewIDWithChain(handler.chain), forkid.NewFilter(handler.chain)); err != nil {
+		t.Fatalf("failed to run protocol handshake")
+	}
+	msg, err := p2pRemote.ReadMsg()
+	if err != nil {
+		t.Fatalf("failed to read checkpoint request: %v", err)
+	}
+	request := new(eth.GetBlockHeadersPacket66)
+	if err := msg.Decode(request); err != nil {
+		t.Fatalf("failed to decode checkpoint request: %v", err)
+	}
+	if query := request.GetBlockHeadersPacket; query.Origin.Hash != response.Hash() || query.Amount != 1 {
+		t.Fatalf("request mismatch: have [%x, %d], want [%x, 1]", query.Origin.Hash, query.Amount, response.Hash())
+	}
+	if wrongHash {
+		response = &types.Header{Number: response.Number}
+	}
+	responseRlp, _ := rlp.EncodeToBytes(response)
+	if err := remote.ReplyBlockHeadersRLP(request.RequestId, []rlp.RawValue{responseRlp}); err != nil {
+		t.Fatalf("failed to answer request: %v", err)
+	}
+
+	want := uint32(syncFromRunning)
+	switch {
+	case wrongHash:
+		<-handlerDone
+		want = syncFromPending
+	case numberOffset != 0:
+		want = syncFromFailed
+	}
+	for start := time.Now(); time.Since(start) < time.Second; time.Sleep(10 * time.Millisecond) {
+		if atomic.LoadUint32(&handler.handler.syncFromStatus) == want {
+			return
+		}
+	}
+	t.Fatalf("sync status mismatch: have %d, want %d", atomic.LoadUint32(&handler.handler.syncFromStatus), want)
+}
+
+// Tests that a sync checkpoint contradicting the local chain is rejected when
+// the handler is created.
+func TestSyncCheckpointLocalMismatch(t *testing.T) {
+	source := newTestHandlerWithBlocks(8)
+	defer source.close()
+
+	block := source.chain.GetBlockByNumber(4)
+	td := source.chain.GetTd(block.Hash(), 4)
+
+	tests := []struct {
+		checkpoint ethconfig.SyncCheckpoint
+		fail       bool
+	}{
+		{ethconfig.SyncCheckpoint{Number: 4, Hash: block.Hash(), TD: td}, false},
+		{ethconfig.SyncCheckpoint{Number: 5, Hash: block.Hash(), TD: td}, true},
+		{ethconfig.SyncCheckpoint{Number: 4, Hash: block.Hash(), TD: new(big.Int).Add(td, common.Big1)}, true},
+		{ethconfig.SyncCheckpoint{Number: 4, Hash: common.Hash{0x01}, TD: td}, true},
+	}
+	for i, tt := range tests {
+		_, err := newHandler(&handlerConfig{
+			Database:   source.db,
+			Chain:      source.chain,
+			TxPool:     source.txpool,
+			Merger:     consensus.NewMerger(rawdb.NewMemoryDatabase()),
+			Network:    1,
+			Sync:       downloader.SnapSync,
+			BloomCache: 1,
+			SyncFrom:   &tt.checkpoint,
+		})
+		if fail := err != nil; fail != tt.fail {
+			t.Errorf("test %d: failure mismatch: have %v, want %v", i, err, tt.fail)
+		}
+	}
+}
@@ -43,6 +43,11 @@ func (h *snapHandler) PeerInfo(id enode.ID) interface{} {
 	return nil
 }
 
+// ServingQueue retrieves the limiter of serving `snap` requests.
+func (h *snapHandler) ServingQueue() *snap.ServingQueue {
+	return h.snapServing
+}
+
 // Handle is invoked from a peer's message handler when it receives a new remote
 // message that the handler couldn't consume and serve itself.
 func (h *snapHandler) Handle(peer *snap.Peer, packet snap.Packet) error {
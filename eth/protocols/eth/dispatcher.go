@@ -171,7 +171,12 @@ func (p *Peer) dispatchResponse(res *Response, metadata func() interface{}) erro
 			// for fresh cancellations too
 			select {
 			case res.Req.sink <- res:
-				return <-res.Done // Response delivered, return any errors
+				// Response delivered, reward the peer if it was accepted
+				err := <-res.Done
+				if err == nil {
+					p.Report(p2p.ScoreUseful)
+				}
+				return err
 			case <-res.Req.cancel:
 				return nil // Request cancelled, silently discard response
 			}
@@ -205,6 +205,7 @@ func handleMessage(backend Backend, peer *Peer) error {
 		return err
 	}
 	if msg.Size > maxMessageSize {
+		peer.Report(p2p.ScoreBadMessage)
 		return fmt.Errorf("%w: %v > %v", errMsgTooLarge, msg.Size, maxMessageSize)
 	}
 	defer msg.Discard()
@@ -226,8 +227,14 @@ func handleMessage(backend Backend, peer *Peer) error {
 			metrics.GetOrRegisterHistogramLazy(h, nil, sampler).Update(time.Since(start).Microseconds())
 		}(time.Now())
 	}
-	if handler := handlers[msg.Code]; handler != nil {
-		return handler(backend, msg, peer)
+	handler := handlers[msg.Code]
+	if handler == nil {
+		peer.Report(p2p.ScoreBadMessage)
+		return fmt.Errorf("%w: %v", errInvalidMsgCode, msg.Code)
 	}
-	return fmt.Errorf("%w: %v", errInvalidMsgCode, msg.Code)
+	if err := handler(backend, msg, peer); err != nil {
+		peer.Report(p2p.ScoreBadMessage)
+		return err
+	}
+	return nil
 }
@@ -18,6 +18,7 @@ package snap
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"time"
 
@@ -78,6 +79,10 @@ type Backend interface {
 	// the remote peer. Only packets not consumed by the protocol handler will
 	// be forwarded to the backend.
 	Handle(peer *Peer, packet Packet) error
+
+	// ServingQueue retrieves the limiter of the work spent on serving the
+	// requests of remote peers. Serving is unlimited if it returns nil.
+	ServingQueue() *ServingQueue
 }
 
 // MakeProtocols constructs the P2P protocol definitions for `snap`.
@@ -88,6 +93,11 @@ func MakeProtocols(backend Backend, dnsdisc enode.Iterator) []p2p.Protocol {
 		return n.Load(&snap) == nil
 	})
 
+	// Nodes which don't serve requests shouldn't be found by syncing peers.
+	attributes := []enr.Entry{&enrEntry{}}
+	if backend.ServingQueue().Disabled() {
+		attributes = nil
+	}
 	protocols := make([]p2p.Protocol, len(ProtocolVersions))
 	for i, version := range ProtocolVersions {
 		version := version // Closure
@@ -107,7 +117,7 @@ func MakeProtocols(backend Backend, dnsdisc enode.Iterator) []p2p.Protocol {
 			PeerInfo: func(id enode.ID) interface{} {
 				return backend.PeerInfo(id)
 			},
-			Attributes:     []enr.Entry{&enrEntry{}},
+			Attributes:     attributes,
 			DialCandidates: dnsdisc,
 		}
 	}
@@ -128,12 +138,19 @@ func Handle(backend Backend, peer *Peer) error {
 // HandleMessage is invoked whenever an inbound message is received from a
 // remote peer on the `snap` protocol. The remote connection is torn down upon
 // returning any error.
-func HandleMessage(backend Backend, peer *Peer) error {
+func HandleMessage(backend Backend, peer *Peer) (err error) {
 	// Read the next message from the remote peer, and ensure it's fully consumed
 	msg, err := peer.rw.ReadMsg()
 	if err != nil {
 		return err
 	}
+	// Penalize the peer for messages failing to be handled. Valid responses to
+	// our requests are rewarded by the syncer.
+	defer func() {
+		if err != nil {
+			peer.Report(p2p.ScoreBadMessage)
+		}
+	}()
 	if msg.Size > maxMessageSize {
 		return fmt.Errorf("%w: %v > %v", errMsgTooLarge, msg.Size, maxMessageSize)
 	}
@@ -159,14 +176,22 @@ func HandleMessage(backend Backend, peer *Peer) error {
 		if err := msg.Decode(&req); err != nil {
 			return fmt.Errorf("%w: message %v: %v", errDecode, msg, err)
 		}
-		// Service the request, potentially returning nothing in case of errors
-		accounts, proofs := ServiceGetAccountRangeQuery(backend.Chain(), &req)
-
-		// Send back anything accumulated (or empty in case of errors)
-		return p2p.Send(peer.rw, AccountRangeMsg, &AccountRangePacket{
-			ID:       req.ID,
-			Accounts: accounts,
-			Proof:    proofs,
+		return serveRequest(backend.ServingQueue(), peer, &req.Bytes, func(serve bool) error {
+			// Service the request, potentially returning nothing in case of errors
+			// or if serving is disabled
+			var (
+				accounts []*AccountData
+				proofs   [][]byte
+			)
+			if serve {
+				accounts, proofs = ServiceGetAccountRangeQuery(backend.Chain(), &req)
+			}
+			// Send back anything accumulated (or empty in case of errors)
+			return p2p.Send(peer.rw, AccountRangeMsg, &AccountRangePacket{
+				ID:       req.ID,
+				Accounts: accounts,
+				Proof:    proofs,
+			})
 		})
 
 	case msg.Code == AccountRangeMsg:
@@ -191,14 +216,22 @@ func HandleMessage(backend Backend, peer *Peer) error {
 		if err := msg.Decode(&req); err != nil {
 			return fmt.Errorf("%w: message %v: %v", errDecode, msg, err)
 		}
-		// Service the request, potentially returning nothing in case of errors
-		slots, proofs := ServiceGetStorageRangesQuery(backend.Chain(), &req)
-
-		// Send back anything accumulated (or empty in case of errors)
-		return p2p.Send(peer.rw, StorageRangesMsg, &StorageRangesPacket{
-			ID:    req.ID,
-			Slots: slots,
-			Proof: proofs,
+		return serveRequest(backend.ServingQueue(), peer, &req.Bytes, func(serve bool) error {
+			// Service the request, potentially returning nothing in case of errors
+			// or if serving is disabled
+			var (
+				slots  [][]*StorageData
+				proofs [][]byte
+			)
+			if serve {
+				slots, proofs = ServiceGetStorageRangesQuery(backend.Chain(), &req)
+			}
+			// Send back anything accumulated (or empty in case of errors)
+			return p2p.Send(peer.rw, StorageRangesMsg, &StorageRangesPacket{
+				ID:    req.ID,
+				Slots: slots,
+				Proof: proofs,
+			})
 		})
 
 	case msg.Code == StorageRangesMsg:
@@ -225,13 +258,18 @@ func HandleMessage(backend Backend, peer *Peer) error {
 		if err := msg.Decode(&req); err != nil {
 			return fmt.Errorf("%w: message %v: %v", errDecode, msg, err)
 		}
-		// Service the request, potentially returning nothing in case of errors
-		codes := ServiceGetByteCodesQuery(backend.Chain(), &req)
-
-		// Send back anything accumulated (or empty in case of errors)
-		return p2p.Send(peer.rw, ByteCodesMsg, &ByteCodesPacket{
-			ID:    req.ID,
-			Codes: codes,
+		return serveRequest(backend.ServingQueue(), peer, &req.Bytes, func(serve bool) error {
+			// Service the request, potentially returning nothing in case of errors
+			// or if serving is disabled
+			var codes [][]byte
+			if serve {
+				codes = ServiceGetByteCodesQuery(backend.Chain(), &req)
+			}
+			// Send back anything accumulated (or empty in case of errors)
+			return p2p.Send(peer.rw, ByteCodesMsg, &ByteCodesPacket{
+				ID:    req.ID,
+				Codes: codes,
+			})
 		})
 
 	case msg.Code == ByteCodesMsg:
@@ -250,15 +288,22 @@ func HandleMessage(backend Backend, peer *Peer) error {
 		if err := msg.Decode(&req); err != nil {
 			return fmt.Errorf("%w: message %v: %v", errDecode, msg, err)
 		}
-		// Service the request, potentially returning nothing in case of errors
-		nodes, err := ServiceGetTrieNodesQuery(backend.Chain(), &req, start)
-		if err != nil {
-			return err
-		}
-		// Send back anything accumulated (or empty in case of errors)
-		return p2p.Send(peer.rw, TrieNodesMsg, &TrieNodesPacket{
-			ID:    req.ID,
-			Nodes: nodes,
+		return serveRequest(backend.ServingQueue(), peer, &req.Bytes, func(serve bool) error {
+			// Service the request, potentially returning nothing in case of errors
+			// or if serving is disabled. The lookup time limit starts when the
+			// request gets served.
+			var nodes [][]byte
+			if serve {
+				var err error
+				if nodes, err = ServiceGetTrieNodesQuery(backend.Chain(), &req, time.Now()); err != nil {
+					return err
+				}
+			}
+			// Send back anything accumulated (or empty in case of errors)
+			return p2p.Send(peer.rw, TrieNodesMsg, &TrieNodesPacket{
+				ID:    req.ID,
+				Nodes: nodes,
+			})
 		})
 
 	case msg.Code == TrieNodesMsg:
@@ -276,6 +321,40 @@ func HandleMessage(backend Backend, peer *Peer) error {
 	}
 }
 
+// serveRequest serves a data retrieval request within the limits of the serving
+// queue. Requests waiting for a serving thread are answered in the background,
+// so the other messages of the peer are handled meanwhile. Throttled requests
+// are served with their response size capped.
+func serveRequest(sq *ServingQueue, peer *Peer, limit *uint64, serve func(serve bool) error) error {
+	if sq.Disabled() {
+		return serve(false)
+	}
+	task := sq.admit(peer.id)
+	if !task.queued() {
+		defer task.release()
+		return serveTask(task, limit, serve)
+	}
+	go func() {
+		task.wait()
+		defer task.release()
+		if err := serveTask(task, limit, serve); err != nil {
+			peer.Log().Debug("Failed to serve snap request", "err", err)
+			if errors.Is(err, errBadRequest) && peer.Peer != nil {
+				peer.Disconnect(p2p.DiscSubprotocolError)
+			}
+		}
+	}()
+	return nil
+}
+
+// serveTask serves a request admitted by the serving queue.
+func serveTask(task *servingTask, limit *uint64, serve func(serve bool) error) error {
+	if task != nil && task.throttled && *limit > throttledResponseLimit {
+		*limit = throttledResponseLimit
+	}
+	return serve(true)
+}
+
 // ServiceGetAccountRangeQuery assembles the response to an account range query.
 // It is exposed to allow external packages to test protocol behavior.
 func ServiceGetAccountRangeQuery(chain *core.BlockChain, req *GetAccountRangePacket) ([]*AccountData, [][]byte) {
@@ -0,0 +1,262 @@
+// Copyright 2022 The go-confero Authors
+// This file is part of the go-confero library.
+//
+// The go-confero library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-confero library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-confero library. If not, see <http://www.gnu.org/licenses/>.
+
+package snap
+
+import (
+	"sync"
+	"time"
+
+	"github.com/confero-network/go-confero/common/mclock"
+	"github.com/confero-network/go-confero/common/prque"
+	"github.com/confero-network/go-confero/metrics"
+)
+
+const (
+	// servingBurstTime is the time a peer needs to recharge its budget from
+	// zero to the maximum. A peer can use the full budget in a burst.
+	servingBurstTime = 10 * time.Second
+
+	// servingCleanupInterval is the number of requests after which the budgets
+	// of idle peers are dropped.
+	servingCleanupInterval = 1000
+
+	// throttledResponseLimit is the maximum size of the responses to throttled
+	// requests. They are kept small instead of empty, so that the requesting
+	// peer still makes progress and doesn't drop this node.
+	throttledResponseLimit = 64 * 1024
+)
+
+var (
+	servedMeter    = metrics.NewRegisteredMeter("snap/serve/served", nil)
+	throttledMeter = metrics.NewRegisteredMeter("snap/serve/throttled", nil)
+	servingTimer   = metrics.NewRegisteredTimer("snap/serve/time", nil)
+	queuedGauge    = metrics.NewRegisteredGauge("snap/serve/queued", nil)
+)
+
+// ServingConfig contains the limits of serving snap requests.
+type ServingConfig struct {
+	Disabled  bool          // Don't serve any requests, only sync from others
+	Threads   int           // Number of requests served concurrently, the overall budget
+	PeerShare float64       // Share of the serving threads one peer can use on average
+	MaxQueued int           // Maximum number of requests waiting to be served
+	MaxWait   time.Duration // Maximum time a request waits to be served
+}
+
+// DefaultServingConfig contains the default serving limits.
+var DefaultServingConfig = ServingConfig{
+	Threads:   4,
+	PeerShare: 0.5,
+	MaxQueued: 256,
+	MaxWait:   3 * time.Second,
+}
+
+// ServingQueue limits the work spent on serving snap requests, similarly to the
+// serving queue of the les server. The requests are served by a limited number
+// of threads, which is the overall serving budget. Every peer has a budget of
+// serving time which recharges at a constant rate, the average share of the
+// threads it can use. Requests waiting for a thread are served in the order of
+// the remaining budgets of their peers, so peers using less than their share
+// are served first, and the replies to peers which overspent their budget are
+// delayed.
+//
+// Requests are throttled if the queue is full or no thread becomes free in time.
+// Throttled requests are still answered, but their response size is capped to
+// throttledResponseLimit.
+type ServingQueue struct {
+	config ServingConfig
+	clock  mclock.Clock
+	rate   float64 // budget recharge per nanosecond
+	burst  float64 // maximum budget of a peer
+
+	mu       sync.Mutex
+	running  int                     // number of requests being served by threads
+	queue    *prque.Prque            // waiting requests by peer budget
+	peers    map[string]*servingPeer // budgets of peers with recent requests
+	requests int                     // number of requests since the last cleanup
+}
+
+// servingPeer is the serving budget of a peer.
+type servingPeer struct {
+	budget  float64 // remaining serving time in nanoseconds, negative if overspent
+	updated mclock.AbsTime
+	tasks   int // number of queued or running requests
+}
+
+// servingTask is a request admitted for serving.
+type servingTask struct {
+	sq        *ServingQueue
+	peer      *servingPeer
+	ready     chan struct{} // closed when the request can be served, nil if not queued
+	index     int           // index in the queue, -1 if not queued
+	started   mclock.AbsTime
+	throttled bool // served without a thread, with a capped response size
+}
+
+// NewServingQueue creates a serving queue with the given limits.
+func NewServingQueue(config ServingConfig) *ServingQueue {
+	return newServingQueue(config, mclock.System{})
+}
+
+func newServingQueue(config ServingConfig, clock mclock.Clock) *ServingQueue {
+	if config.Threads < 1 {
+		config.Threads = 1
+	}
+	if config.PeerShare <= 0 || config.PeerShare > 1 {
+		config.PeerShare = 1
+	}
+	rate := config.PeerShare * float64(config.Threads)
+	return &ServingQueue{
+		config: config,
+		clock:  clock,
+		rate:   rate,
+		burst:  rate * float64(servingBurstTime),
+		queue:  prque.New(setServingTaskIndex),
+		peers:  make(map[string]*servingPeer),
+	}
+}
+
+func setServingTaskIndex(a interface{}, i int) {
+	a.(*servingTask).index = i
+}
+
+// Disabled reports whether serving is disabled.
+func (sq *ServingQueue) Disabled() bool {
+	return sq != nil && sq.config.Disabled
+}
+
+// admit enters a request of the given peer without blocking. The returned task
+// either holds a thread, is throttled because the queue is full, or is queued,
+// in which case wait must be called before serving. The task must be released
+// after serving. A nil queue admits all requests with a nil task.
+func (sq *ServingQueue) admit(id string) *servingTask {
+	if sq == nil {
+		return nil
+	}
+	sq.mu.Lock()
+	defer sq.mu.Unlock()
+
+	now := sq.clock.Now()
+	if sq.requests++; sq.requests >= servingCleanupInterval {
+		sq.cleanup(now)
+	}
+	peer := sq.peers[id]
+	if peer == nil {
+		peer = &servingPeer{budget: sq.burst, updated: now}
+		sq.peers[id] = peer
+	}
+	sq.recharge(peer, now)
+	peer.tasks++
+
+	task := &servingTask{sq: sq, peer: peer, index: -1, started: now}
+	switch {
+	case sq.running < sq.config.Threads && sq.queue.Empty():
+		sq.running++
+
+	case sq.queue.Size() >= sq.config.MaxQueued:
+		task.throttled = true
+
+	default:
+		// Wait for a free thread, after the peers with more budget left.
+		task.ready = make(chan struct{})
+		sq.queue.Push(task, int64(peer.budget))
+		queuedGauge.Update(int64(sq.queue.Size()))
+	}
+	return task
+}
+
+// queued reports whether the task has to wait for a thread.
+func (t *servingTask) queued() bool {
+	return t != nil && t.ready != nil
+}
+
+// wait blocks until a queued request gets a thread. If none becomes free in time,
+// the request is throttled.
+func (t *servingTask) wait() {
+	if !t.queued() {
+		return
+	}
+	sq := t.sq
+	timeout := sq.clock.NewTimer(sq.config.MaxWait)
+	defer timeout.Stop()
+	select {
+	case <-t.ready:
+		return
+	case <-timeout.C():
+	}
+	sq.mu.Lock()
+	defer sq.mu.Unlock()
+	if t.index < 0 {
+		// Dispatched while timing out.
+		return
+	}
+	sq.queue.Remove(t.index)
+	queuedGauge.Update(int64(sq.queue.Size()))
+	t.throttled = true
+	t.started = sq.clock.Now()
+}
+
+// release charges the serving time of a request to its peer and passes the
+// thread on to the best waiting request.
+func (t *servingTask) release() {
+	if t == nil {
+		return
+	}
+	sq := t.sq
+	sq.mu.Lock()
+	defer sq.mu.Unlock()
+
+	now := sq.clock.Now()
+	elapsed := time.Duration(now - t.started)
+	sq.recharge(t.peer, now)
+	t.peer.budget -= float64(elapsed)
+	t.peer.tasks--
+	servingTimer.Update(elapsed)
+
+	if t.throttled {
+		throttledMeter.Mark(1)
+		return
+	}
+	servedMeter.Mark(1)
+	if sq.queue.Empty() {
+		sq.running--
+		return
+	}
+	next := sq.queue.PopItem().(*servingTask)
+	next.index = -1
+	next.started = now
+	queuedGauge.Update(int64(sq.queue.Size()))
+	close(next.ready)
+}
+
+// recharge updates the budget of a peer to the current time.
+func (sq *ServingQueue) recharge(peer *servingPeer, now mclock.AbsTime) {
+	peer.budget += sq.rate * float64(now-peer.updated)
+	if peer.budget > sq.burst {
+		peer.budget = sq.burst
+	}
+	peer.updated = now
+}
+
+// cleanup drops the budgets of idle peers which have fully recharged.
+func (sq *ServingQueue) cleanup(now mclock.AbsTime) {
+	for id, peer := range sq.peers {
+		if peer.tasks == 0 && peer.budget+sq.rate*float64(now-peer.updated) >= sq.burst {
+			delete(sq.peers, id)
+		}
+	}
+	sq.requests = 0
+}
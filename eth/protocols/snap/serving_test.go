@@ -0,0 +1,146 @@
+// Copyright 2022 The go-confero Authors
+// This file is part of the go-confero library.
+//
+// The go-confero library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-confero library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-confero library. If not, see <http://www.gnu.org/licenses/>.
+
+package snap
+
+import (
+	"testing"
+	"time"
+
+	"github.com/confero-network/go-confero/common/mclock"
+)
+
+// waitAsync waits for a queued task in the background and waits until its
+// timer is set.
+func waitAsync(t *testing.T, sq *ServingQueue, clock *mclock.Simulated, id string, results chan *servingTask) *servingTask {
+	t.Helper()
+
+	task := sq.admit(id)
+	if !task.queued() {
+		t.Fatalf("request of %s not queued", id)
+	}
+	go func() {
+		task.wait()
+		results <- task
+	}()
+	clock.WaitForTimers(sq.queue.Size())
+	return task
+}
+
+func TestServingQueueDisabled(t *testing.T) {
+	var sq *ServingQueue
+	if sq.Disabled() {
+		t.Fatal("nil queue reports disabled")
+	}
+	if task := sq.admit("a"); task != nil {
+		t.Fatal("nil queue returned task")
+	}
+	sq = NewServingQueue(ServingConfig{Disabled: true})
+	if !sq.Disabled() {
+		t.Fatal("queue doesn't report disabled")
+	}
+}
+
+func TestServingQueueWait(t *testing.T) {
+	clock := new(mclock.Simulated)
+	sq := newServingQueue(ServingConfig{Threads: 1, PeerShare: 1, MaxQueued: 10, MaxWait: time.Second}, clock)
+
+	task := sq.admit("a")
+	if task.queued() || task.throttled {
+		t.Fatal("first request not served")
+	}
+	results := make(chan *servingTask, 1)
+	waitAsync(t, sq, clock, "b", results)
+
+	task.release()
+	if res := <-results; res.throttled {
+		t.Fatal("waiting request throttled")
+	} else {
+		res.release()
+	}
+	if sq.running != 0 {
+		t.Fatalf("wrong running count after release: %d", sq.running)
+	}
+}
+
+func TestServingQueuePriority(t *testing.T) {
+	clock := new(mclock.Simulated)
+	sq := newServingQueue(ServingConfig{Threads: 1, PeerShare: 1, MaxQueued: 10, MaxWait: time.Minute}, clock)
+
+	// Make peer b spend a part of its budget.
+	task := sq.admit("b")
+	clock.Run(5 * time.Second)
+	task.release()
+
+	task = sq.admit("a")
+	results := make(chan *servingTask, 2)
+	b := waitAsync(t, sq, clock, "b", results)
+	c := waitAsync(t, sq, clock, "c", results)
+
+	task.release()
+	if first := <-results; first != c {
+		t.Fatal("wrong request served first")
+	}
+	c.release()
+	if second := <-results; second != b || b.throttled {
+		t.Fatalf("wrong second request (throttled %v)", second.throttled)
+	}
+	b.release()
+}
+
+func TestServingQueueThrottle(t *testing.T) {
+	clock := new(mclock.Simulated)
+	sq := newServingQueue(ServingConfig{Threads: 1, PeerShare: 0.5, MaxQueued: 1, MaxWait: time.Second}, clock)
+
+	task := sq.admit("a")
+	results := make(chan *servingTask, 1)
+	waitAsync(t, sq, clock, "b", results)
+
+	// The queue is full, the request is served with a capped response.
+	full := sq.admit("c")
+	if full.queued() || !full.throttled {
+		t.Fatal("request not throttled by full queue")
+	}
+	limit := uint64(softResponseLimit)
+	if err := serveTask(full, &limit, func(bool) error { return nil }); err != nil || limit != throttledResponseLimit {
+		t.Fatalf("throttled response limit mismatch: have %d, want %d", limit, throttledResponseLimit)
+	}
+	full.release()
+
+	// The waiting request times out.
+	clock.Run(time.Second)
+	if res := <-results; !res.throttled {
+		t.Fatal("request not throttled after timeout")
+	} else {
+		res.release()
+	}
+	// Peer a overspends its budget, its next request waits behind the other
+	// peers' requests instead of being refused.
+	clock.Run(20 * time.Second)
+	task.release()
+
+	task = sq.admit("c")
+	results = make(chan *servingTask, 2)
+	a := waitAsync(t, sq, clock, "a", results)
+	if a.peer.budget >= 0 {
+		t.Fatalf("peer budget not overspent: %v", a.peer.budget)
+	}
+	task.release()
+	if res := <-results; res != a || a.throttled {
+		t.Fatal("request with overspent budget not served")
+	}
+	a.release()
+}
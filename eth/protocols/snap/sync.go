@@ -40,6 +40,7 @@ import (
 	"github.com/confero-network/go-confero/event"
 	"github.com/confero-network/go-confero/light"
 	"github.com/confero-network/go-confero/log"
+	"github.com/confero-network/go-confero/p2p"
 	"github.com/confero-network/go-confero/p2p/msgrate"
 	"github.com/confero-network/go-confero/rlp"
 	"github.com/confero-network/go-confero/trie"
@@ -119,6 +120,14 @@ var (
 // terminated.
 var ErrCancelled = errors.New("sync cancelled")
 
+// reportPeer reports the behaviour of a sync peer to the p2p reputation system,
+// if the peer supports it.
+func reportPeer(peer SyncPeer, score float64) {
+	if r, ok := peer.(interface{ Report(float64) }); ok {
+		r.Report(score)
+	}
+}
+
 // accountRequest tracks a pending account range request to ensure responses are
 // to actual requests and to validate any security constraints.
 //
@@ -991,6 +1000,7 @@ func (s *Syncer) assignAccountTasks(success chan *accountResponse, fail chan *ac
 		}
 		req.timeout = time.AfterFunc(s.rates.TargetTimeout(), func() {
 			peer.Log().Debug("Account range request timed out", "reqid", reqid)
+			reportPeer(peer, p2p.ScoreTimeout)
 			s.rates.Update(idle, AccountRangeMsg, 0, 0)
 			s.scheduleRevertAccountRequest(req)
 		})
@@ -1102,6 +1112,7 @@ func (s *Syncer) assignBytecodeTasks(success chan *bytecodeResponse, fail chan *
 		}
 		req.timeout = time.AfterFunc(s.rates.TargetTimeout(), func() {
 			peer.Log().Debug("Bytecode request timed out", "reqid", reqid)
+			reportPeer(peer, p2p.ScoreTimeout)
 			s.rates.Update(idle, ByteCodesMsg, 0, 0)
 			s.scheduleRevertBytecodeRequest(req)
 		})
@@ -1249,6 +1260,7 @@ func (s *Syncer) assignStorageTasks(success chan *storageResponse, fail chan *st
 		}
 		req.timeout = time.AfterFunc(s.rates.TargetTimeout(), func() {
 			peer.Log().Debug("Storage request timed out", "reqid", reqid)
+			reportPeer(peer, p2p.ScoreTimeout)
 			s.rates.Update(idle, StorageRangesMsg, 0, 0)
 			s.scheduleRevertStorageRequest(req)
 		})
@@ -1386,6 +1398,7 @@ func (s *Syncer) assignTrienodeHealTasks(success chan *trienodeHealResponse, fai
 		}
 		req.timeout = time.AfterFunc(s.rates.TargetTimeout(), func() {
 			peer.Log().Debug("Trienode heal request timed out", "reqid", reqid)
+			reportPeer(peer, p2p.ScoreTimeout)
 			s.rates.Update(idle, TrieNodesMsg, 0, 0)
 			s.scheduleRevertTrienodeHealRequest(req)
 		})
@@ -1502,6 +1515,7 @@ func (s *Syncer) assignBytecodeHealTasks(success chan *bytecodeHealResponse, fai
 		}
 		req.timeout = time.AfterFunc(s.rates.TargetTimeout(), func() {
 			peer.Log().Debug("Bytecode heal request timed out", "reqid", reqid)
+			reportPeer(peer, p2p.ScoreTimeout)
 			s.rates.Update(idle, ByteCodesMsg, 0, 0)
 			s.scheduleRevertBytecodeHealRequest(req)
 		})
@@ -2417,6 +2431,8 @@ func (s *Syncer) OnAccounts(peer SyncPeer, id uint64, hashes []common.Hash, acco
 	}
 	select {
 	case req.deliver <- response:
+		// Reward the peer for a valid response to our request
+		reportPeer(peer, p2p.ScoreUseful)
 	case <-req.cancel:
 	case <-req.stale:
 	}
@@ -2528,6 +2544,8 @@ func (s *Syncer) onByteCodes(peer SyncPeer, id uint64, bytecodes [][]byte) error
 	}
 	select {
 	case req.deliver <- response:
+		// Reward the peer for a valid response to our request
+		reportPeer(peer, p2p.ScoreUseful)
 	case <-req.cancel:
 	case <-req.stale:
 	}
@@ -2674,6 +2692,8 @@ func (s *Syncer) OnStorage(peer SyncPeer, id uint64, hashes [][]common.Hash, slo
 	}
 	select {
 	case req.deliver <- response:
+		// Reward the peer for a valid response to our request
+		reportPeer(peer, p2p.ScoreUseful)
 	case <-req.cancel:
 	case <-req.stale:
 	}
@@ -2781,6 +2801,8 @@ func (s *Syncer) OnTrieNodes(peer SyncPeer, id uint64, trienodes [][]byte) error
 	}
 	select {
 	case req.deliver <- response:
+		// Reward the peer for a valid response to our request
+		reportPeer(peer, p2p.ScoreUseful)
 	case <-req.cancel:
 	case <-req.stale:
 	}
@@ -2879,6 +2901,8 @@ func (s *Syncer) onHealByteCodes(peer SyncPeer, id uint64, bytecodes [][]byte) e
 	}
 	select {
 	case req.deliver <- response:
+		// Reward the peer for a valid response to our request
+		reportPeer(peer, p2p.ScoreUseful)
 	case <-req.cancel:
 	case <-req.stale:
 	}
@@ -200,3 +200,51 @@ func (eth *Confero) stateAtTransaction(block *types.Block, txIndex int, reexec u
 	}
 	return nil, vm.BlockContext{}, nil, fmt.Errorf("transaction index %d out of range for block %#x", txIndex, block.Hash())
 }
+
+// stateDiffAtBlock recomputes the state diff of a block by re-executing it. The
+// optional base statedb is regarded as the state of the parent block, allowing
+// callers to replay consecutive blocks without regenerating the state for each.
+// Beside the diff, the post-state of the block is returned to be used as the
+// base for the next one.
+func (eth *Confero) stateDiffAtBlock(block *types.Block, reexec uint64, base *state.StateDB) (*state.StateDiff, *state.StateDB, error) {
+	if block.NumberU64() == 0 {
+		return nil, nil, errors.New("no state diff for genesis")
+	}
+	statedb := base
+	if statedb == nil {
+		parent := eth.blockchain.GetBlock(block.ParentHash(), block.NumberU64()-1)
+		if parent == nil {
+			return nil, nil, fmt.Errorf("parent %#x not found", block.ParentHash())
+		}
+		// The diff is derived when committing the state, so avoid the live
+		// database to not leak the replayed junk into it.
+		var err error
+		if statedb, err = eth.StateAtBlock(parent, reexec, nil, false, false); err != nil {
+			return nil, nil, err
+		}
+	}
+	statedb.EnableStateDiff()
+	if _, _, _, err := eth.blockchain.Processor().Process(block, statedb, vm.Config{}); err != nil {
+		return nil, nil, fmt.Errorf("processing block %d failed: %v", block.NumberU64(), err)
+	}
+	root, err := statedb.Commit(eth.blockchain.Config().IsEIP158(block.Number()))
+	if err != nil {
+		return nil, nil, fmt.Errorf("state diff commit failed, number %d root %v: %w", block.NumberU64(), block.Root().Hex(), err)
+	}
+	if root != block.Root() {
+		return nil, nil, fmt.Errorf("state root mismatch for block %d: have %x, want %x", block.NumberU64(), root, block.Root())
+	}
+	// Reopen the post-state, keeping it alive until the next block is replayed
+	database := statedb.Database()
+	next, err := state.New(root, database, nil)
+	if err != nil {
+		return nil, nil, fmt.Errorf("state reset after block %d failed: %v", block.NumberU64(), err)
+	}
+	database.TrieDB().Reference(root, common.Hash{})
+	if base != nil {
+		if parent := eth.blockchain.GetHeader(block.ParentHash(), block.NumberU64()-1); parent != nil {
+			database.TrieDB().Dereference(parent.Root)
+		}
+	}
+	return statedb.StateDiff(), next, nil
+}
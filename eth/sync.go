@@ -23,6 +23,7 @@ import (
 	"time"
 
 	"github.com/confero-network/go-confero/common"
+	"github.com/confero-network/go-confero/core"
 	"github.com/confero-network/go-confero/core/rawdb"
 	"github.com/confero-network/go-confero/core/types"
 	"github.com/confero-network/go-confero/eth/downloader"
@@ -35,6 +36,15 @@ const (
 	defaultMinSyncPeers = 5                // Amount of peers desired to start syncing
 )
 
+// Progress of syncing the chain from a trusted block.
+const (
+	syncFromNone    = iota // No trusted block configured
+	syncFromPending        // Waiting for a peer to deliver the header of the trusted block
+	syncFromRunning        // Syncing the state of the trusted block
+	syncFromDone           // Trusted block is the head, history backfilled in the background
+	syncFromFailed         // Trusted block contradicts the network, sync halted
+)
+
 // syncTransactions starts sending all currently pending transactions to the given peer.
 func (h *handler) syncTransactions(p *eth.Peer) {
 	// Assemble the set of transaction to broadcast or announce to the remote
@@ -69,6 +79,10 @@ type chainSyncer struct {
 	warned      time.Time
 	peerEventCh chan struct{}
 	doneCh      chan error // non-nil when sync is running
+
+	checkpointCh chan *types.Header // delivers the header of the trusted block to sync from
+	checkpoint   *types.Header      // header of the trusted block, once delivered
+	backfillCh   chan error         // non-nil when the history backfill is running
 }
 
 // chainSyncOp is a scheduled sync operation.
@@ -82,8 +96,9 @@ type chainSyncOp struct {
 // newChainSyncer creates a chainSyncer.
 func newChainSyncer(handler *handler) *chainSyncer {
 	return &chainSyncer{
-		handler:     handler,
-		peerEventCh: make(chan struct{}),
+		handler:      handler,
+		peerEventCh:  make(chan struct{}),
+		checkpointCh: make(chan *types.Header),
 	}
 }
 
@@ -114,13 +129,33 @@ func (cs *chainSyncer) loop() {
 	cs.force = time.NewTimer(forceSyncCycle)
 	defer cs.force.Stop()
 
+	// Resume backfilling the history of a chain synced from a trusted block.
+	cs.startBackfill()
+
 	for {
 		if op := cs.nextSyncOp(); op != nil {
 			cs.startSync(op)
 		}
+		// A failed checkpoint sync is retried against the connected peers once
+		// the force timer fires, no need to wait for new peers to deliver the
+		// header again.
+		if cs.forced && cs.checkpoint != nil && cs.handler.peers.len() > 0 {
+			cs.startCheckpointSync(cs.checkpoint)
+		}
 		select {
 		case <-cs.peerEventCh:
 			// Peer information changed, recheck.
+		case header := <-cs.checkpointCh:
+			cs.startCheckpointSync(header)
+
+		case err := <-cs.backfillCh:
+			cs.backfillCh = nil
+			switch {
+			case errors.Is(err, core.ErrCheckpointMismatch):
+				cs.discardCheckpoint(err)
+			case err != nil:
+				log.Error("Chain history backfill failed", "err", err)
+			}
 		case err := <-cs.doneCh:
 			cs.doneCh = nil
 			cs.force.Reset(forceSyncCycle)
@@ -133,6 +168,11 @@ func (cs *chainSyncer) loop() {
 				log.Warn("Local chain is post-merge, waiting for beacon client sync switch-over...")
 				cs.warned = time.Now()
 			}
+			// A finished checkpoint sync leaves the history below the trusted block
+			// to be filled in.
+			if err == nil {
+				cs.startBackfill()
+			}
 		case <-cs.force.C:
 			cs.forced = true
 
@@ -145,6 +185,9 @@ func (cs *chainSyncer) loop() {
 			if cs.doneCh != nil {
 				<-cs.doneCh
 			}
+			if cs.backfillCh != nil {
+				<-cs.backfillCh
+			}
 			return
 		}
 	}
@@ -166,6 +209,12 @@ func (cs *chainSyncer) nextSyncOp() *chainSyncOp {
 	if cs.handler.chain.Config().TerminalTotalDifficultyPassed || cs.handler.merger.TDDReached() {
 		return nil
 	}
+	// If the chain is being synced from a trusted block, hold back until the
+	// block is the head.
+	switch atomic.LoadUint32(&cs.handler.syncFromStatus) {
+	case syncFromPending, syncFromRunning, syncFromFailed:
+		return nil
+	}
 	// Ensure we're at minimum peer count.
 	minPeers := defaultMinSyncPeers
 	if cs.forced {
@@ -231,6 +280,42 @@ func (cs *chainSyncer) startSync(op *chainSyncOp) {
 	go func() { cs.doneCh <- cs.handler.doSync(op) }()
 }
 
+// startCheckpointSync launches the sync from the header of the trusted block,
+// unless it's already running.
+func (cs *chainSyncer) startCheckpointSync(header *types.Header) {
+	if cs.doneCh != nil || !atomic.CompareAndSwapUint32(&cs.handler.syncFromStatus, syncFromPending, syncFromRunning) {
+		return
+	}
+	cs.checkpoint = header
+	cs.doneCh = make(chan error, 1)
+	go func() { cs.doneCh <- cs.handler.doCheckpointSync(header) }()
+}
+
+// discardCheckpoint halts the sync after the history of a chain synced from a
+// trusted block turned out not to link to the genesis block, and resets the
+// chain to the genesis. The trusted block has to be fixed before restarting.
+func (cs *chainSyncer) discardCheckpoint(err error) {
+	atomic.StoreUint32(&cs.handler.syncFromStatus, syncFromFailed)
+	cs.handler.downloader.Cancel()
+	if err := cs.handler.chain.DiscardCheckpoint(); err != nil {
+		log.Error("Failed to discard chain synced from checkpoint", "err", err)
+	}
+	log.Error("Sync checkpoint is not part of this network, sync halted, fix --syncfrom and restart", "err", err)
+}
+
+// startBackfill launches the retrieval of the history below the oldest block of
+// a chain synced from a trusted block, if any of it is missing.
+func (cs *chainSyncer) startBackfill() {
+	if cs.backfillCh != nil {
+		return
+	}
+	if _, ok := cs.handler.chain.HistoryTail(); !ok {
+		return
+	}
+	cs.backfillCh = make(chan error, 1)
+	go func() { cs.backfillCh <- cs.handler.downloader.BackfillHistory() }()
+}
+
 // doSync synchronizes the local blockchain with a remote peer.
 func (h *handler) doSync(op *chainSyncOp) error {
 	if op.mode == downloader.SnapSync {
@@ -281,3 +366,79 @@ func (h *handler) doSync(op *chainSyncOp) error {
 	}
 	return nil
 }
+
+// requestSyncCheckpoint requests the header of the trusted block to sync from
+// and starts the checkpoint sync once a peer delivers it.
+func (h *handler) requestSyncCheckpoint(peer *eth.Peer, dead chan struct{}) error {
+	resCh := make(chan *eth.Response)
+
+	req, err := peer.RequestHeadersByHash(h.syncFrom.Hash, 1, 0, false, resCh)
+	if err != nil {
+		return err
+	}
+	go func() {
+		// Ensure the request gets cancelled in case of error/drop
+		defer req.Close()
+
+		timeout := time.NewTimer(syncChallengeTimeout)
+		defer timeout.Stop()
+
+		select {
+		case res := <-resCh:
+			headers := ([]*types.Header)(*res.Res.(*eth.BlockHeadersPacket))
+			if len(headers) == 0 {
+				// The peer may not be synced up to the checkpoint yet
+				res.Done <- nil
+				return
+			}
+			if len(headers) > 1 {
+				res.Done <- errors.New("too many headers in sync checkpoint response")
+				return
+			}
+			if headers[0].Hash() != h.syncFrom.Hash {
+				res.Done <- errors.New("sync checkpoint hash mismatch")
+				return
+			}
+			res.Done <- nil
+			h.startCheckpointSync(headers[0])
+
+		case <-timeout.C:
+			peer.Log().Debug("Sync checkpoint request timed out")
+
+		case <-dead:
+			// Peer handler terminated, abort all goroutines
+		}
+	}()
+	return nil
+}
+
+// startCheckpointSync starts syncing the chain from the header of the trusted
+// block. A header with a different number than configured means the checkpoint
+// is wrong, the sync is halted until the configuration is fixed.
+func (h *handler) startCheckpointSync(header *types.Header) {
+	if header.Number.Uint64() != h.syncFrom.Number {
+		if atomic.CompareAndSwapUint32(&h.syncFromStatus, syncFromPending, syncFromFailed) {
+			log.Error("Sync checkpoint number mismatch, fix --syncfrom and restart", "hash", h.syncFrom.Hash, "number", header.Number, "want", h.syncFrom.Number)
+		}
+		return
+	}
+	select {
+	case h.chainSync.checkpointCh <- header:
+	case <-h.quitSync:
+	}
+}
+
+// doCheckpointSync makes the trusted block the head of the chain. Its state is
+// snap synced, the blocks below it are backfilled afterwards.
+func (h *handler) doCheckpointSync(header *types.Header) error {
+	log.Info("Starting checkpoint sync", "number", header.Number, "hash", header.Hash())
+	if err := h.downloader.CheckpointSync(header, h.syncFrom.TD); err != nil {
+		log.Warn("Checkpoint sync failed", "err", err)
+		atomic.StoreUint32(&h.syncFromStatus, syncFromPending)
+		return err
+	}
+	atomic.StoreUint32(&h.snapSync, 0)
+	atomic.StoreUint32(&h.syncFromStatus, syncFromDone)
+	log.Info("Checkpoint sync complete", "number", header.Number, "hash", header.Hash())
+	return nil
+}
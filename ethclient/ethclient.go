@@ -318,6 +318,13 @@ func (ec *Client) SubscribeNewHead(ctx context.Context, ch chan<- *types.Header)
 	return ec.c.EthSubscribe(ctx, ch, "newHeads")
 }
 
+// SubscribeNewHeadWithFinality subscribes to notifications about the blocks becoming
+// safe or finalized, depending on the given finality level. Headers are delivered in
+// chain order as the safe or finalized block advances over them.
+func (ec *Client) SubscribeNewHeadWithFinality(ctx context.Context, finality string, ch chan<- *types.Header) (confero.Subscription, error) {
+	return ec.c.EthSubscribe(ctx, ch, "newHeads", map[string]interface{}{"finality": finality})
+}
+
 // State Access
 
 // NetworkID returns the network ID (also known as the chain ID) for this chain.
@@ -387,6 +394,30 @@ func (ec *Client) SubscribeFilterLogs(ctx context.Context, q confero.FilterQuery
 	return ec.c.EthSubscribe(ctx, ch, "logs", arg)
 }
 
+// SubscribeResumableLogs subscribes to all logs matching the query in chain order,
+// starting with the logs of past blocks from q.FromBlock or following q.After.
+// The cursor of the last received event can be used as q.After to resume the
+// subscription without missing or repeating logs. q.ToBlock is ignored.
+func (ec *Client) SubscribeResumableLogs(ctx context.Context, q confero.FilterQuery, ch chan<- confero.LogEvent) (confero.Subscription, error) {
+	if q.BlockHash != nil {
+		return nil, errors.New("resumable log subscriptions don't support BlockHash")
+	}
+	arg := map[string]interface{}{
+		"address": q.Addresses,
+		"topics":  q.Topics,
+	}
+	if q.FromBlock != nil {
+		arg["fromBlock"] = toBlockNumArg(q.FromBlock)
+	}
+	if q.After != nil {
+		arg["after"] = q.After
+	}
+	if q.Finality != "" {
+		arg["finality"] = q.Finality
+	}
+	return ec.c.EthSubscribe(ctx, ch, "resumableLogs", arg)
+}
+
 func toFilterArg(q confero.FilterQuery) (interface{}, error) {
 	arg := map[string]interface{}{
 		"address": q.Addresses,
@@ -405,6 +436,15 @@ func toFilterArg(q confero.FilterQuery) (interface{}, error) {
 		}
 		arg["toBlock"] = toBlockNumArg(q.ToBlock)
 	}
+	if q.Limit != 0 {
+		arg["limit"] = hexutil.Uint64(q.Limit)
+	}
+	if q.After != nil {
+		arg["after"] = q.After
+	}
+	if q.Finality != "" {
+		arg["finality"] = q.Finality
+	}
 	return arg, nil
 }
 
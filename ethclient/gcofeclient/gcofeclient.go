@@ -60,13 +60,14 @@ func (ec *Client) CreateAccessList(ctx context.Context, msg confero.CallMsg) (*t
 
 // AccountResult is the result of a GetProof operation.
 type AccountResult struct {
-	Address      common.Address  `json:"address"`
-	AccountProof []string        `json:"accountProof"`
-	Balance      *big.Int        `json:"balance"`
-	CodeHash     common.Hash     `json:"codeHash"`
-	Nonce        uint64          `json:"nonce"`
-	StorageHash  common.Hash     `json:"storageHash"`
-	StorageProof []StorageResult `json:"storageProof"`
+	Address           common.Address  `json:"address"`
+	AccountProof      []string        `json:"accountProof"`
+	Balance           *big.Int        `json:"balance"`
+	CodeHash          common.Hash     `json:"codeHash"`
+	Nonce             uint64          `json:"nonce"`
+	StorageHash       common.Hash     `json:"storageHash"`
+	StorageProof      []StorageResult `json:"storageProof"`
+	StorageMultiProof []string        `json:"storageMultiProof"`
 }
 
 // StorageResult provides a proof for a key-value pair.
@@ -79,6 +80,18 @@ type StorageResult struct {
 // GetProof returns the account and storage values of the specified account including the Merkle-proof.
 // The block number can be nil, in which case the value is taken from the latest known block.
 func (ec *Client) GetProof(ctx context.Context, account common.Address, keys []string, blockNumber *big.Int) (*AccountResult, error) {
+	return ec.getProof(ctx, account, keys, blockNumber, nil)
+}
+
+// GetCompactProof returns the account and storage values of the specified account
+// including the Merkle-proof. Instead of a proof per storage key, a single merkle
+// multiproof of all the storage keys is returned in StorageMultiProof.
+// The block number can be nil, in which case the value is taken from the latest known block.
+func (ec *Client) GetCompactProof(ctx context.Context, account common.Address, keys []string, blockNumber *big.Int) (*AccountResult, error) {
+	return ec.getProof(ctx, account, keys, blockNumber, map[string]interface{}{"compact": true})
+}
+
+func (ec *Client) getProof(ctx context.Context, account common.Address, keys []string, blockNumber *big.Int, config interface{}) (*AccountResult, error) {
 	type storageResult struct {
 		Key   string       `json:"key"`
 		Value *hexutil.Big `json:"value"`
@@ -86,17 +99,25 @@ func (ec *Client) GetProof(ctx context.Context, account common.Address, keys []s
 	}
 
 	type accountResult struct {
-		Address      common.Address  `json:"address"`
-		AccountProof []string        `json:"accountProof"`
-		Balance      *hexutil.Big    `json:"balance"`
-		CodeHash     common.Hash     `json:"codeHash"`
-		Nonce        hexutil.Uint64  `json:"nonce"`
-		StorageHash  common.Hash     `json:"storageHash"`
-		StorageProof []storageResult `json:"storageProof"`
+		Address           common.Address  `json:"address"`
+		AccountProof      []string        `json:"accountProof"`
+		Balance           *hexutil.Big    `json:"balance"`
+		CodeHash          common.Hash     `json:"codeHash"`
+		Nonce             hexutil.Uint64  `json:"nonce"`
+		StorageHash       common.Hash     `json:"storageHash"`
+		StorageProof      []storageResult `json:"storageProof"`
+		StorageMultiProof []string        `json:"storageMultiProof"`
 	}
 
-	var res accountResult
-	err := ec.c.CallContext(ctx, &res, "eth_getProof", account, keys, toBlockNumArg(blockNumber))
+	var (
+		res accountResult
+		err error
+	)
+	if config == nil {
+		err = ec.c.CallContext(ctx, &res, "eth_getProof", account, keys, toBlockNumArg(blockNumber))
+	} else {
+		err = ec.c.CallContext(ctx, &res, "eth_getProof", account, keys, toBlockNumArg(blockNumber), config)
+	}
 	// Turn hexutils back to normal datatypes
 	storageResults := make([]StorageResult, 0, len(res.StorageProof))
 	for _, st := range res.StorageProof {
@@ -107,13 +128,14 @@ func (ec *Client) GetProof(ctx context.Context, account common.Address, keys []s
 		})
 	}
 	result := AccountResult{
-		Address:      res.Address,
-		AccountProof: res.AccountProof,
-		Balance:      res.Balance.ToInt(),
-		Nonce:        uint64(res.Nonce),
-		CodeHash:     res.CodeHash,
-		StorageHash:  res.StorageHash,
-		StorageProof: storageResults,
+		Address:           res.Address,
+		AccountProof:      res.AccountProof,
+		Balance:           res.Balance.ToInt(),
+		Nonce:             uint64(res.Nonce),
+		CodeHash:          res.CodeHash,
+		StorageHash:       res.StorageHash,
+		StorageProof:      storageResults,
+		StorageMultiProof: res.StorageMultiProof,
 	}
 	return &result, err
 }
@@ -35,7 +35,9 @@ import (
 	"github.com/confero-network/go-confero/ethclient"
 	"github.com/confero-network/go-confero/node"
 	"github.com/confero-network/go-confero/params"
+	"github.com/confero-network/go-confero/rlp"
 	"github.com/confero-network/go-confero/rpc"
+	"github.com/confero-network/go-confero/trie"
 )
 
 var (
@@ -117,6 +119,9 @@ func TestGcofeClient(t *testing.T) {
 		{
 			"TestGetProof",
 			func(t *testing.T) { testGetProof(t, client) },
+		}, {
+			"TestGetCompactProof",
+			func(t *testing.T) { testGetCompactProof(t, client) },
 		}, {
 			"TestGCStats",
 			func(t *testing.T) { testGCStats(t, client) },
@@ -231,6 +236,45 @@ func testGetProof(t *testing.T, client *rpc.Client) {
 	}
 }
 
+func testGetCompactProof(t *testing.T, client *rpc.Client) {
+	ec := New(client)
+	missing := common.HexToHash("0xdead")
+	result, err := ec.GetCompactProof(context.Background(), testAddr, []string{testSlot.String(), missing.String()}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.StorageProof) != 2 {
+		t.Fatalf("invalid storage results, want 2, got %v", len(result.StorageProof))
+	}
+	for _, res := range result.StorageProof {
+		if len(res.Proof) != 0 {
+			t.Fatalf("unexpected per-key proof for %v", res.Key)
+		}
+	}
+	proof := make(trie.MultiProof, len(result.StorageMultiProof))
+	for i, node := range result.StorageMultiProof {
+		proof[i] = common.FromHex(node)
+	}
+	keys := [][]byte{crypto.Keccak256(testSlot.Bytes()), crypto.Keccak256(missing.Bytes())}
+	values, err := trie.VerifyMultiProof(result.StorageHash, keys, proof)
+	if err != nil {
+		t.Fatalf("invalid storage multiproof: %v", err)
+	}
+	if values[1] != nil {
+		t.Fatalf("unexpected value for missing slot: %x", values[1])
+	}
+	_, content, _, err := rlp.Split(values[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if new(big.Int).SetBytes(content).Cmp(testValue.Big()) != 0 {
+		t.Fatalf("invalid storage proof value, want: %v, got: %x", testValue, content)
+	}
+	if result.StorageProof[0].Value.Cmp(testValue.Big()) != 0 {
+		t.Fatalf("invalid storage value, want: %v, got: %v", testValue, result.StorageProof[0].Value)
+	}
+}
+
 func testGCStats(t *testing.T, client *rpc.Client) {
 	ec := New(client)
 	_, err := ec.GCStats(context.Background())
@@ -0,0 +1,241 @@
+// Copyright 2022 The go-confero Authors
+// This file is part of the go-confero library.
+//
+// The go-confero library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-confero library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-confero library. If not, see <http://www.gnu.org/licenses/>.
+
+package ethclient
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math/big"
+
+	"github.com/confero-network/go-confero"
+	"github.com/confero-network/go-confero/common"
+	"github.com/confero-network/go-confero/core/types"
+	"github.com/confero-network/go-confero/event"
+	"github.com/confero-network/go-confero/protorpc/pb"
+	"github.com/confero-network/go-confero/rpc"
+)
+
+// ProtoClient retrieves chain data through the Protobuf-over-HTTP API of a node,
+// which is cheaper to encode and decode than JSON-RPC for bulk retrieval.
+type ProtoClient struct {
+	c *rpc.ProtoClient
+}
+
+// DialProto connects a client to the Protobuf-over-HTTP API at the given URL,
+// e.g. http://localhost:8545/proto.
+func DialProto(rawurl string) (*ProtoClient, error) {
+	c, err := rpc.DialProto(rawurl)
+	if err != nil {
+		return nil, err
+	}
+	return NewProtoClient(c), nil
+}
+
+// NewProtoClient creates a client that uses the given Protobuf-over-HTTP client.
+func NewProtoClient(c *rpc.ProtoClient) *ProtoClient {
+	return &ProtoClient{c}
+}
+
+// Close closes the idle connections of the client.
+func (ec *ProtoClient) Close() {
+	ec.c.Close()
+}
+
+// call calls a method, encoding the request and decoding the response.
+func (ec *ProtoClient) call(ctx context.Context, method string, req interface{ Marshal() []byte }, resp interface{ Unmarshal([]byte) error }) error {
+	result, err := ec.c.Call(ctx, method, req.Marshal())
+	if err != nil {
+		return err
+	}
+	return resp.Unmarshal(result)
+}
+
+// BlockByHash returns the given full block.
+func (ec *ProtoClient) BlockByHash(ctx context.Context, hash common.Hash) (*types.Block, error) {
+	return ec.getBlock(ctx, &pb.BlockRequest{Hash: hash})
+}
+
+// BlockByNumber returns a block from the current canonical chain. If number is nil, the
+// latest known block is returned.
+func (ec *ProtoClient) BlockByNumber(ctx context.Context, number *big.Int) (*types.Block, error) {
+	return ec.getBlock(ctx, &pb.BlockRequest{Number: toProtoBlockNumber(number)})
+}
+
+func (ec *ProtoClient) getBlock(ctx context.Context, req *pb.BlockRequest) (*types.Block, error) {
+	var resp pb.BlockResponse
+	if err := ec.call(ctx, pb.MethodGetBlock, req, &resp); err != nil {
+		return nil, err
+	}
+	if resp.Block == nil {
+		return nil, confero.NotFound
+	}
+	txs := resp.Block.Transactions()
+	if len(resp.Senders) != len(txs) {
+		return nil, errors.New("server returned wrong number of transaction senders")
+	}
+	for i, tx := range txs {
+		setSenderFromServer(tx, resp.Senders[i], resp.Block.Hash())
+	}
+	return resp.Block, nil
+}
+
+// TransactionByHash returns the transaction with the given hash.
+func (ec *ProtoClient) TransactionByHash(ctx context.Context, hash common.Hash) (tx *types.Transaction, isPending bool, err error) {
+	var resp pb.TransactionResponse
+	if err := ec.call(ctx, pb.MethodGetTransaction, &pb.TransactionRequest{Hash: hash}, &resp); err != nil {
+		return nil, false, err
+	}
+	if resp.Tx == nil {
+		return nil, false, confero.NotFound
+	}
+	if resp.BlockHash != (common.Hash{}) {
+		setSenderFromServer(resp.Tx, resp.Sender, resp.BlockHash)
+	}
+	return resp.Tx, resp.BlockHash == (common.Hash{}), nil
+}
+
+// TransactionReceipt returns the receipt of a transaction by transaction hash.
+// Note that the receipt is not available for pending transactions.
+func (ec *ProtoClient) TransactionReceipt(ctx context.Context, txHash common.Hash) (*types.Receipt, error) {
+	var resp pb.ReceiptResponse
+	if err := ec.call(ctx, pb.MethodGetReceipt, &pb.TransactionRequest{Hash: txHash}, &resp); err != nil {
+		return nil, err
+	}
+	if resp.Receipt == nil {
+		return nil, confero.NotFound
+	}
+	return resp.Receipt, nil
+}
+
+// BlockReceipts returns the receipts of all transactions of the given block.
+func (ec *ProtoClient) BlockReceipts(ctx context.Context, blockNrOrHash rpc.BlockNumberOrHash) (types.Receipts, error) {
+	var req pb.BlockRequest
+	if hash, ok := blockNrOrHash.Hash(); ok {
+		req.Hash = hash
+	} else if number, ok := blockNrOrHash.Number(); ok {
+		req.Number = number
+	}
+	var resp pb.BlockReceiptsResponse
+	if err := ec.call(ctx, pb.MethodGetBlockReceipts, &req, &resp); err != nil {
+		return nil, err
+	}
+	if resp.BlockHash == (common.Hash{}) {
+		return nil, confero.NotFound
+	}
+	return resp.Receipts, nil
+}
+
+// FilterLogs executes a filter query.
+func (ec *ProtoClient) FilterLogs(ctx context.Context, q confero.FilterQuery) ([]types.Log, error) {
+	req := &pb.LogsRequest{BlockHash: q.BlockHash, Addresses: q.Addresses, Topics: q.Topics}
+	if q.FromBlock != nil {
+		number := toProtoBlockNumber(q.FromBlock)
+		req.FromBlock = &number
+	}
+	if q.ToBlock != nil {
+		number := toProtoBlockNumber(q.ToBlock)
+		req.ToBlock = &number
+	}
+	var resp pb.LogsResponse
+	if err := ec.call(ctx, pb.MethodGetLogs, req, &resp); err != nil {
+		return nil, err
+	}
+	logs := make([]types.Log, len(resp.Logs))
+	for i, log := range resp.Logs {
+		logs[i] = *log
+	}
+	return logs, nil
+}
+
+// SubscribeNewHead subscribes to notifications about the current blockchain head
+// on the given channel.
+func (ec *ProtoClient) SubscribeNewHead(ctx context.Context, ch chan<- *types.Header) (confero.Subscription, error) {
+	// The stream is opened within ctx, but outlives it once established.
+	streamCtx, cancel := context.WithCancel(context.Background())
+	var (
+		dialed   = make(chan struct{})
+		detached = make(chan struct{})
+	)
+	go func() {
+		defer close(detached)
+		select {
+		case <-ctx.Done():
+			cancel()
+		case <-dialed:
+		}
+	}()
+	stream, err := ec.c.Stream(streamCtx, pb.MethodSubscribeNewHeads, nil)
+	close(dialed)
+	<-detached
+	if err == nil && streamCtx.Err() != nil {
+		stream.Close()
+		err = ctx.Err()
+	}
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	return event.NewSubscription(func(quit <-chan struct{}) error {
+		defer stream.Close()
+		defer cancel()
+
+		// Reading the stream is interrupted by canceling its context.
+		go func() {
+			select {
+			case <-quit:
+				cancel()
+			case <-streamCtx.Done():
+			}
+		}()
+		for {
+			msg, err := stream.Next()
+			if err != nil {
+				select {
+				case <-quit:
+					return nil
+				default:
+				}
+				if err == io.EOF {
+					err = errors.New("server ended the subscription")
+				}
+				return err
+			}
+			header, err := pb.UnmarshalHeader(msg)
+			if err != nil {
+				return err
+			}
+			select {
+			case ch <- header:
+			case <-quit:
+				return nil
+			}
+		}
+	}), nil
+}
+
+// toProtoBlockNumber converts a block number argument, using the conventions of
+// Client: nil is the latest block and -1 the pending block.
+func toProtoBlockNumber(number *big.Int) rpc.BlockNumber {
+	if number == nil {
+		return rpc.LatestBlockNumber
+	}
+	if number.Cmp(big.NewInt(-1)) == 0 {
+		return rpc.PendingBlockNumber
+	}
+	return rpc.BlockNumber(number.Int64())
+}
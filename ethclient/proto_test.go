@@ -0,0 +1,146 @@
+// Copyright 2022 The go-confero Authors
+// This file is part of the go-confero library.
+//
+// The go-confero library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-confero library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-confero library. If not, see <http://www.gnu.org/licenses/>.
+
+package ethclient
+
+import (
+	"context"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/confero-network/go-confero"
+	"github.com/confero-network/go-confero/common"
+	"github.com/confero-network/go-confero/consensus/ethash"
+	"github.com/confero-network/go-confero/core/types"
+	"github.com/confero-network/go-confero/eth"
+	"github.com/confero-network/go-confero/eth/ethconfig"
+	"github.com/confero-network/go-confero/eth/filters"
+	"github.com/confero-network/go-confero/node"
+	"github.com/confero-network/go-confero/protorpc"
+	"github.com/confero-network/go-confero/rpc"
+)
+
+func TestProtoClient(t *testing.T) {
+	chain := generateTestChain()
+
+	n, err := node.New(&node.Config{HTTPHost: "127.0.0.1"})
+	if err != nil {
+		t.Fatalf("can't create new node: %v", err)
+	}
+	defer n.Close()
+	config := &ethconfig.Config{Genesis: genesis}
+	config.Ethash.PowMode = ethash.ModeFake
+	ethservice, err := eth.New(n, config)
+	if err != nil {
+		t.Fatalf("can't create new confero service: %v", err)
+	}
+	protorpc.New(n, ethservice.APIBackend, filters.NewFilterSystem(ethservice.APIBackend, filters.Config{}), nil, []string{"*"})
+	if err := n.Start(); err != nil {
+		t.Fatalf("can't start test node: %v", err)
+	}
+	if _, err := ethservice.BlockChain().InsertChain(chain[1:2]); err != nil {
+		t.Fatalf("can't import test blocks: %v", err)
+	}
+
+	client, err := DialProto(n.HTTPEndpoint() + protorpc.Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	ctx := context.Background()
+
+	// New heads are streamed, the subscription outlives the context it was
+	// made with.
+	heads := make(chan *types.Header, 1)
+	subCtx, cancel := context.WithCancel(ctx)
+	sub, err := client.SubscribeNewHead(subCtx, heads)
+	cancel()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sub.Unsubscribe()
+	// Give the server time to subscribe to the chain events.
+	time.Sleep(100 * time.Millisecond)
+	if _, err := ethservice.BlockChain().InsertChain(chain[2:]); err != nil {
+		t.Fatalf("can't import test blocks: %v", err)
+	}
+	select {
+	case head := <-heads:
+		if head.Hash() != chain[2].Hash() {
+			t.Fatalf("wrong head %d %x", head.Number, head.Hash())
+		}
+	case err := <-sub.Err():
+		t.Fatal(err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("no new head received")
+	}
+
+	// Blocks are retrieved with their transactions and senders.
+	block, err := client.BlockByNumber(ctx, big.NewInt(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if block.Hash() != chain[2].Hash() || len(block.Transactions()) != 2 {
+		t.Fatalf("wrong block %x with %d transactions", block.Hash(), len(block.Transactions()))
+	}
+	sender, err := types.Sender(&senderFromServer{blockhash: block.Hash()}, block.Transactions()[0])
+	if err != nil || sender != testAddr {
+		t.Fatalf("wrong sender %x, err %v", sender, err)
+	}
+	if block, err = client.BlockByHash(ctx, chain[1].Hash()); err != nil || block.NumberU64() != 1 {
+		t.Fatalf("wrong block by hash %v, err %v", block, err)
+	}
+	if block, err = client.BlockByNumber(ctx, nil); err != nil || block.Hash() != chain[2].Hash() {
+		t.Fatalf("wrong latest block %v, err %v", block, err)
+	}
+	if _, err := client.BlockByNumber(ctx, big.NewInt(10)); err != confero.NotFound {
+		t.Fatalf("expected not found error, got %v", err)
+	}
+
+	// Transactions and receipts.
+	tx, pending, err := client.TransactionByHash(ctx, testTx2.Hash())
+	if err != nil || pending || tx.Hash() != testTx2.Hash() {
+		t.Fatalf("wrong transaction %v, pending %v, err %v", tx, pending, err)
+	}
+	receipt, err := client.TransactionReceipt(ctx, testTx2.Hash())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if receipt.TxHash != testTx2.Hash() || receipt.BlockHash != chain[2].Hash() || receipt.TransactionIndex != 1 || receipt.Status != types.ReceiptStatusSuccessful {
+		t.Fatalf("wrong receipt %+v", receipt)
+	}
+	if _, _, err := client.TransactionByHash(ctx, common.Hash{1}); err != confero.NotFound {
+		t.Fatalf("expected not found error, got %v", err)
+	}
+	receipts, err := client.BlockReceipts(ctx, rpc.BlockNumberOrHashWithHash(chain[2].Hash(), false))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(receipts) != 2 || receipts[1].TxHash != testTx2.Hash() {
+		t.Fatalf("wrong block receipts %v", receipts)
+	}
+
+	// Log queries are checked like JSON-RPC ones.
+	logs, err := client.FilterLogs(ctx, confero.FilterQuery{FromBlock: big.NewInt(0)})
+	if err != nil || len(logs) != 0 {
+		t.Fatalf("wrong logs %v, err %v", logs, err)
+	}
+	hash := chain[1].Hash()
+	if _, err := client.FilterLogs(ctx, confero.FilterQuery{BlockHash: &hash, FromBlock: big.NewInt(0)}); err == nil {
+		t.Fatal("expected error for block hash with range")
+	}
+}
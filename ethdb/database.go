@@ -129,6 +129,9 @@ type AncientWriter interface {
 	// The second argument is a function that takes a raw entry and returns it
 	// in the newest format.
 	MigrateTable(string, func([]byte) ([]byte, error)) error
+
+	// AncientReadOnly reports whether the ancient store rejects all writes.
+	AncientReadOnly() bool
 }
 
 // AncientWriteOp is given to the function argument of ModifyAncients.
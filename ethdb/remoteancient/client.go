@@ -0,0 +1,163 @@
+// Copyright 2022 The go-confero Authors
+// This file is part of the go-confero library.
+//
+// The go-confero library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-confero library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-confero library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package remoteancient implements a read-only ancient store which is backed by a
+// freezer living in a separate process. The client speaks a small JSON-RPC protocol
+// in the `ancient` namespace, which is served by the Server type of this package,
+// usually over IPC. This allows several nodes on the same host to share a single
+// copy of the immutable chain history. The store can't be modified by its clients,
+// it is populated by the process owning it and nodes freeze their newer chain
+// segments locally.
+package remoteancient
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/confero-network/go-confero/common/hexutil"
+	"github.com/confero-network/go-confero/ethdb"
+	"github.com/confero-network/go-confero/rpc"
+)
+
+// errReadOnly is returned for all write operations, the remote store is shared
+// by many nodes and must not be modified by any of them.
+var errReadOnly = errors.New("remote ancient store is read-only")
+
+// Client is a read-only ethdb.AncientStore which forwards all reads to a remote
+// ancient store server.
+type Client struct {
+	remote *rpc.Client
+}
+
+// Dial connects to the ancient store server at the given endpoint, which may be
+// an IPC socket path or an HTTP(S)/WS(S) URL.
+func Dial(endpoint string) (*Client, error) {
+	if endpoint == "" {
+		return nil, errors.New("endpoint must be specified")
+	}
+	if strings.HasPrefix(endpoint, "ipc:") {
+		endpoint = endpoint[4:]
+	}
+	client, err := rpc.Dial(endpoint)
+	if err != nil {
+		return nil, err
+	}
+	return NewClient(client), nil
+}
+
+// NewClient creates an ancient store on top of an already established RPC
+// connection. The client takes ownership of the connection and closes it when
+// the store is closed.
+func NewClient(client *rpc.Client) *Client {
+	return &Client{remote: client}
+}
+
+// HasAncient returns an indicator whether the specified data exists in the
+// remote ancient store.
+func (c *Client) HasAncient(kind string, number uint64) (bool, error) {
+	var has bool
+	err := c.remote.Call(&has, "ancient_hasAncient", kind, hexutil.Uint64(number))
+	return has, err
+}
+
+// Ancient retrieves an ancient binary blob from the remote store.
+func (c *Client) Ancient(kind string, number uint64) ([]byte, error) {
+	var blob hexutil.Bytes
+	if err := c.remote.Call(&blob, "ancient_ancient", kind, hexutil.Uint64(number)); err != nil {
+		return nil, err
+	}
+	return blob, nil
+}
+
+// AncientRange retrieves multiple items in sequence, starting from the index 'start'.
+// The size limits are applied by the remote store.
+func (c *Client) AncientRange(kind string, start, count, maxBytes uint64) ([][]byte, error) {
+	var blobs []hexutil.Bytes
+	if err := c.remote.Call(&blobs, "ancient_ancientRange", kind, hexutil.Uint64(start), hexutil.Uint64(count), hexutil.Uint64(maxBytes)); err != nil {
+		return nil, err
+	}
+	items := make([][]byte, len(blobs))
+	for i, blob := range blobs {
+		items[i] = blob
+	}
+	return items, nil
+}
+
+// Ancients returns the number of items in the remote ancient store.
+func (c *Client) Ancients() (uint64, error) {
+	var n hexutil.Uint64
+	err := c.remote.Call(&n, "ancient_ancients")
+	return uint64(n), err
+}
+
+// Tail returns the number of the first stored item in the remote ancient store.
+func (c *Client) Tail() (uint64, error) {
+	var n hexutil.Uint64
+	err := c.remote.Call(&n, "ancient_tail")
+	return uint64(n), err
+}
+
+// AncientSize returns the ancient size of the specified category.
+func (c *Client) AncientSize(kind string) (uint64, error) {
+	var n hexutil.Uint64
+	err := c.remote.Call(&n, "ancient_ancientSize", kind)
+	return uint64(n), err
+}
+
+// ReadAncients runs the given read operation against the remote store. Note,
+// unlike a local freezer, the reads are not isolated from concurrent appends of
+// the process owning the store, so callers must not rely on a consistent view
+// across calls.
+func (c *Client) ReadAncients(fn func(ethdb.AncientReaderOp) error) error {
+	return fn(c)
+}
+
+// ModifyAncients is not supported by the read-only store.
+func (c *Client) ModifyAncients(func(ethdb.AncientWriteOp) error) (int64, error) {
+	return 0, errReadOnly
+}
+
+// TruncateHead is not supported by the read-only store.
+func (c *Client) TruncateHead(n uint64) error {
+	return errReadOnly
+}
+
+// TruncateTail is not supported by the read-only store.
+func (c *Client) TruncateTail(n uint64) error {
+	return errReadOnly
+}
+
+// Sync is a no-op, nothing is ever written to the read-only store.
+func (c *Client) Sync() error {
+	return nil
+}
+
+// AncientReadOnly returns true, the remote store is never written by clients.
+func (c *Client) AncientReadOnly() bool {
+	return true
+}
+
+// MigrateTable is not supported by the read-only store.
+func (c *Client) MigrateTable(string, func([]byte) ([]byte, error)) error {
+	return errReadOnly
+}
+
+// Close terminates the connection to the remote store. The remote store itself
+// stays open.
+func (c *Client) Close() error {
+	c.remote.Close()
+	return nil
+}
@@ -0,0 +1,133 @@
+// Copyright 2022 The go-confero Authors
+// This file is part of the go-confero library.
+//
+// The go-confero library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-confero library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-confero library. If not, see <http://www.gnu.org/licenses/>.
+
+package remoteancient
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/confero-network/go-confero/common/hexutil"
+	"github.com/confero-network/go-confero/core/rawdb"
+	"github.com/confero-network/go-confero/ethdb"
+	"github.com/confero-network/go-confero/rpc"
+)
+
+func newTestClient(t *testing.T) (*Client, *rawdb.Freezer) {
+	freezer, err := rawdb.NewFreezer(t.TempDir(), "", false, 2048, map[string]bool{"a": true, "b": false})
+	if err != nil {
+		t.Fatal(err)
+	}
+	server, err := NewServer(freezer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := NewClient(rpc.DialInProc(server))
+	t.Cleanup(func() {
+		client.Close()
+		server.Stop()
+		freezer.Close()
+	})
+	return client, freezer
+}
+
+func TestRemoteAncientRead(t *testing.T) {
+	client, freezer := newTestClient(t)
+
+	_, err := freezer.ModifyAncients(func(op ethdb.AncientWriteOp) error {
+		for i := uint64(0); i < 10; i++ {
+			if err := op.AppendRaw("a", i, []byte{byte(i)}); err != nil {
+				return err
+			}
+			if err := op.Append("b", i, []uint64{i}); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal("ModifyAncients failed:", err)
+	}
+	if n, err := client.Ancients(); err != nil || n != 10 {
+		t.Fatalf("wrong remote item count: have %d, want 10 (err %v)", n, err)
+	}
+	blob, err := client.Ancient("a", 3)
+	if err != nil || !bytes.Equal(blob, []byte{3}) {
+		t.Fatalf("wrong item: have %x, want 03 (err %v)", blob, err)
+	}
+	items, err := client.AncientRange("a", 2, 3, 0)
+	if err != nil {
+		t.Fatal("AncientRange failed:", err)
+	}
+	if len(items) != 1 || !bytes.Equal(items[0], []byte{2}) {
+		t.Fatalf("wrong range with zero byte limit: %x", items)
+	}
+	items, err = client.AncientRange("a", 2, 3, 1024)
+	if err != nil || len(items) != 3 {
+		t.Fatalf("wrong range: %x (err %v)", items, err)
+	}
+	if has, _ := client.HasAncient("a", 9); !has {
+		t.Fatal("item 9 missing")
+	}
+	if has, _ := client.HasAncient("a", 10); has {
+		t.Fatal("item 10 should not exist")
+	}
+	if _, err := client.Ancient("c", 0); err == nil {
+		t.Fatal("expected error for unknown table")
+	}
+}
+
+func TestRemoteAncientReadOnly(t *testing.T) {
+	client, freezer := newTestClient(t)
+
+	_, err := freezer.ModifyAncients(func(op ethdb.AncientWriteOp) error {
+		op.AppendRaw("a", 0, []byte{0})
+		op.AppendRaw("b", 0, []byte{0})
+		return nil
+	})
+	if err != nil {
+		t.Fatal("ModifyAncients failed:", err)
+	}
+	if !client.AncientReadOnly() {
+		t.Fatal("client not read-only")
+	}
+	// Neither appends nor truncations may reach the shared store.
+	_, err = client.ModifyAncients(func(op ethdb.AncientWriteOp) error {
+		op.AppendRaw("a", 1, []byte{1})
+		op.AppendRaw("b", 1, []byte{1})
+		return nil
+	})
+	if err != errReadOnly {
+		t.Fatalf("wrong append error: have %v, want %v", err, errReadOnly)
+	}
+	if err := client.TruncateHead(0); err != errReadOnly {
+		t.Fatalf("wrong head truncation error: have %v, want %v", err, errReadOnly)
+	}
+	if err := client.TruncateTail(1); err != errReadOnly {
+		t.Fatalf("wrong tail truncation error: have %v, want %v", err, errReadOnly)
+	}
+	if n, _ := freezer.Ancients(); n != 1 {
+		t.Fatalf("store modified by client: have %d items, want 1", n)
+	}
+	if tail, _ := freezer.Tail(); tail != 0 {
+		t.Fatalf("store modified by client: have tail %d, want 0", tail)
+	}
+	// The write methods are not served at all.
+	var size hexutil.Uint64
+	if err := client.remote.Call(&size, "ancient_truncateHead", hexutil.Uint64(0)); err == nil {
+		t.Fatal("truncation served to remote client")
+	}
+}
@@ -0,0 +1,89 @@
+// Copyright 2022 The go-confero Authors
+// This file is part of the go-confero library.
+//
+// The go-confero library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-confero library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-confero library. If not, see <http://www.gnu.org/licenses/>.
+
+package remoteancient
+
+import (
+	"github.com/confero-network/go-confero/common/hexutil"
+	"github.com/confero-network/go-confero/ethdb"
+	"github.com/confero-network/go-confero/rpc"
+)
+
+// Namespace is the RPC namespace under which the ancient store API is served.
+const Namespace = "ancient"
+
+// API exposes a local ancient store to remote clients. Only reads are served, the
+// store is shared by all clients, so none of them may modify it.
+type API struct {
+	store ethdb.AncientStore
+}
+
+// NewAPI creates the RPC API for the given ancient store.
+func NewAPI(store ethdb.AncientStore) *API {
+	return &API{store: store}
+}
+
+// NewServer creates an RPC server serving the given ancient store. The server
+// can be attached to any of the rpc package transports.
+func NewServer(store ethdb.AncientStore) (*rpc.Server, error) {
+	server := rpc.NewServer()
+	if err := server.RegisterName(Namespace, NewAPI(store)); err != nil {
+		server.Stop()
+		return nil, err
+	}
+	return server, nil
+}
+
+// HasAncient returns an indicator whether the specified data exists.
+func (api *API) HasAncient(kind string, number hexutil.Uint64) (bool, error) {
+	return api.store.HasAncient(kind, uint64(number))
+}
+
+// Ancient retrieves an ancient binary blob.
+func (api *API) Ancient(kind string, number hexutil.Uint64) (hexutil.Bytes, error) {
+	return api.store.Ancient(kind, uint64(number))
+}
+
+// AncientRange retrieves multiple items in sequence, starting from the index 'start'.
+func (api *API) AncientRange(kind string, start, count, maxBytes hexutil.Uint64) ([]hexutil.Bytes, error) {
+	items, err := api.store.AncientRange(kind, uint64(start), uint64(count), uint64(maxBytes))
+	if err != nil {
+		return nil, err
+	}
+	blobs := make([]hexutil.Bytes, len(items))
+	for i, item := range items {
+		blobs[i] = item
+	}
+	return blobs, nil
+}
+
+// Ancients returns the number of items in the ancient store.
+func (api *API) Ancients() (hexutil.Uint64, error) {
+	n, err := api.store.Ancients()
+	return hexutil.Uint64(n), err
+}
+
+// Tail returns the number of the first stored item in the ancient store.
+func (api *API) Tail() (hexutil.Uint64, error) {
+	n, err := api.store.Tail()
+	return hexutil.Uint64(n), err
+}
+
+// AncientSize returns the ancient size of the specified category.
+func (api *API) AncientSize(kind string) (hexutil.Uint64, error) {
+	n, err := api.store.AncientSize(kind)
+	return hexutil.Uint64(n), err
+}
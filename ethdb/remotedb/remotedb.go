@@ -117,6 +117,10 @@ func (db *Database) MigrateTable(s string, f func([]byte) ([]byte, error)) error
 	panic("not supported")
 }
 
+func (db *Database) AncientReadOnly() bool {
+	return true
+}
+
 func (db *Database) NewBatch() ethdb.Batch {
 	panic("not supported")
 }
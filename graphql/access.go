@@ -0,0 +1,164 @@
+// Copyright 2022 The go-confero Authors
+// This file is part of the go-confero library.
+//
+// The go-confero library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-confero library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-confero library. If not, see <http://www.gnu.org/licenses/>.
+
+package graphql
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/confero-network/go-confero/rpc"
+	"github.com/graph-gophers/graphql-go"
+	"github.com/graph-gophers/graphql-go/errors"
+	"github.com/graph-gophers/graphql-go/types"
+)
+
+// fieldMethods are the JSON-RPC methods equivalent to fields, by type and field
+// name. Operations are checked against the method allow-lists of API keys by
+// these names. Fields which are not listed are served along with the object
+// they belong to.
+var fieldMethods = map[string]string{
+	"Query.block":                      "eth_getBlockByNumber",
+	"Query.blocks":                     "eth_getBlockByNumber",
+	"Query.pending":                    "eth_getBlockByNumber",
+	"Query.transaction":                "eth_getTransactionByHash",
+	"Query.logs":                       "eth_getLogs",
+	"Query.gasPrice":                   "eth_gasPrice",
+	"Query.maxPriorityFeePerGas":       "eth_maxPriorityFeePerGas",
+	"Query.syncing":                    "eth_syncing",
+	"Query.chainID":                    "eth_chainId",
+	"Mutation.sendRawTransaction":      "eth_sendRawTransaction",
+	"Subscription.newBlocks":           "eth_subscribe",
+	"Subscription.logs":                "eth_subscribe",
+	"Subscription.pendingTransactions": "eth_subscribe",
+	"Block.logs":                       "eth_getLogs",
+	"Block.call":                       "eth_call",
+	"Block.estimateGas":                "eth_estimateGas",
+	"Block.rawHeader":                  "debug_getRawHeader",
+	"Block.raw":                        "debug_getRawBlock",
+	"Pending.call":                     "eth_call",
+	"Pending.estimateGas":              "eth_estimateGas",
+	"Account.balance":                  "eth_getBalance",
+	"Account.transactionCount":         "eth_getTransactionCount",
+	"Account.code":                     "eth_getCode",
+	"Account.storage":                  "eth_getStorageAt",
+	"Transaction.status":               "eth_getTransactionReceipt",
+	"Transaction.gasUsed":              "eth_getTransactionReceipt",
+	"Transaction.cumulativeGasUsed":    "eth_getTransactionReceipt",
+	"Transaction.effectiveGasPrice":    "eth_getTransactionReceipt",
+	"Transaction.createdContract":      "eth_getTransactionReceipt",
+	"Transaction.logs":                 "eth_getTransactionReceipt",
+	"Transaction.raw":                  "debug_getRawTransaction",
+	"Transaction.rawReceipt":           "debug_getRawReceipts",
+}
+
+// operationMethods returns the JSON-RPC methods equivalent to the fields selected
+// by the named operation of a document, each listed once.
+func operationMethods(schema *types.Schema, doc *document, operationName string) ([]string, error) {
+	op := doc.operation(operationName)
+	if op == nil {
+		return nil, fmt.Errorf("operation %q not found", operationName)
+	}
+	root, ok := schema.EntryPoints[op.typ]
+	if !ok {
+		return nil, fmt.Errorf("%s operations not supported", op.typ)
+	}
+	a := &methodAnalysis{
+		schema:    schema,
+		fragments: doc.fragments,
+		visiting:  make(map[string]bool),
+		seen:      make(map[string]bool),
+	}
+	a.collect(root.TypeName(), op.selections)
+	return a.methods, nil
+}
+
+// methodAnalysis collects the JSON-RPC methods equivalent to an operation.
+type methodAnalysis struct {
+	schema    *types.Schema
+	fragments map[string]*fragment
+	visiting  map[string]bool
+	seen      map[string]bool
+	methods   []string
+}
+
+// collect adds the methods of a selection set on the given type.
+func (a *methodAnalysis) collect(typeName string, selections []*selection) {
+	object, ok := a.schema.Types[typeName].(*types.ObjectTypeDefinition)
+	if !ok {
+		return
+	}
+	for _, sel := range selections {
+		switch {
+		case sel.fragment != "":
+			frag, ok := a.fragments[sel.fragment]
+			if !ok || a.visiting[sel.fragment] {
+				continue
+			}
+			a.visiting[sel.fragment] = true
+			a.collect(frag.typeName, frag.selections)
+			delete(a.visiting, sel.fragment)
+
+		case sel.name == "":
+			// Inline fragment.
+			inlineType := sel.typeName
+			if inlineType == "" {
+				inlineType = typeName
+			}
+			a.collect(inlineType, sel.selections)
+
+		default:
+			field := object.Fields.Get(sel.name)
+			if field == nil {
+				continue
+			}
+			key := typeName + "." + sel.name
+			method, ok := fieldMethods[key]
+			if key == "Query.block" && sel.args["hash"] != nil {
+				method = "eth_getBlockByHash"
+			}
+			if ok && !a.seen[method] {
+				a.seen[method] = true
+				a.methods = append(a.methods, method)
+			}
+			fieldType, _ := unwrapType(field.Type)
+			a.collect(fieldType, sel.selections)
+		}
+	}
+}
+
+// checkAccess returns an error if the call filter of the request, set from its
+// API key, rejects any of the JSON-RPC methods equivalent to an operation.
+func (h *handler) checkAccess(ctx context.Context, schema *graphql.Schema, doc *document, operationName string) *errors.QueryError {
+	filter := rpc.CallFilterFromContext(ctx)
+	if filter == nil {
+		return nil
+	}
+	methods, err := operationMethods(schema.ASTSchema(), doc, operationName)
+	if err != nil {
+		return &errors.QueryError{Message: err.Error()}
+	}
+	for _, method := range methods {
+		if err := filter(ctx, method); err != nil {
+			qerr := &errors.QueryError{Message: err.Error()}
+			if ec, ok := err.(rpc.Error); ok {
+				qerr.Extensions = map[string]interface{}{"code": ec.ErrorCode(), "method": method}
+			}
+			return qerr
+		}
+	}
+	return nil
+}
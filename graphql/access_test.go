@@ -0,0 +1,112 @@
+// Copyright 2022 The go-confero Authors
+// This file is part of the go-confero library.
+//
+// The go-confero library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-confero library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-confero library. If not, see <http://www.gnu.org/licenses/>.
+
+package graphql
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/confero-network/go-confero/node"
+	"github.com/confero-network/go-confero/rpc"
+	"github.com/graph-gophers/graphql-go"
+)
+
+func TestOperationMethods(t *testing.T) {
+	s, err := graphql.ParseSchema(schema, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{query: `{ block { number } }`, want: []string{"eth_getBlockByNumber"}},
+		{query: `{ block(hash: "0x00") { number } }`, want: []string{"eth_getBlockByHash"}},
+		{query: `{ chainID gasPrice }`, want: []string{"eth_chainId", "eth_gasPrice"}},
+		{
+			query: `{ block { call(data: {to: "0x01"}) { status } account(address: "0x01") { balance code } } }`,
+			want:  []string{"eth_getBlockByNumber", "eth_call", "eth_getBalance", "eth_getCode"},
+		},
+		{
+			query: `query { pending { ...F } } fragment F on Pending { estimateGas(data: {}) }`,
+			want:  []string{"eth_getBlockByNumber", "eth_estimateGas"},
+		},
+		{
+			query: `{ transaction(hash: "0x00") { ... on Transaction { status logs { index } } } }`,
+			want:  []string{"eth_getTransactionByHash", "eth_getTransactionReceipt"},
+		},
+		{query: `mutation { sendRawTransaction(data: "0x00") }`, want: []string{"eth_sendRawTransaction"}},
+	}
+	for i, test := range tests {
+		doc, err := parseDocument(test.query)
+		if err != nil {
+			t.Errorf("test %d: error: %v", i, err)
+			continue
+		}
+		have, err := operationMethods(s.ASTSchema(), doc, "")
+		if err != nil {
+			t.Errorf("test %d: error: %v", i, err)
+			continue
+		}
+		if !reflect.DeepEqual(have, test.want) {
+			t.Errorf("test %d: wrong methods: have %v, want %v", i, have, test.want)
+		}
+	}
+}
+
+// deniedError is the error of a call filter rejecting a method.
+type deniedError struct{ method string }
+
+func (e *deniedError) Error() string  { return fmt.Sprintf("method %s not allowed", e.method) }
+func (e *deniedError) ErrorCode() int { return -32001 }
+
+func TestGraphQLAccess(t *testing.T) {
+	stack, err := node.New(&node.Config{})
+	if err != nil {
+		t.Fatalf("could not create new node: %v", err)
+	}
+	defer stack.Close()
+	h, err := newHandler(stack, nil, nil, []string{}, []string{}, Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := rpc.WithCallFilter(context.Background(), func(ctx context.Context, method string) error {
+		if method == "eth_call" || method == "eth_subscribe" {
+			return &deniedError{method}
+		}
+		return nil
+	})
+	// Operations selecting a denied field are rejected before running.
+	res := h.exec(ctx, `{ pending { call(data: {to: "0x01"}) { status } } }`, "", nil)
+	if len(res.Errors) != 1 || res.Errors[0].Message != "method eth_call not allowed" {
+		t.Fatalf("wrong errors: %v", res.Errors)
+	}
+	if res.Errors[0].Extensions["code"] != -32001 || res.Errors[0].Extensions["method"] != "eth_call" {
+		t.Fatalf("wrong error extensions: %v", res.Errors[0].Extensions)
+	}
+	// Subscriptions are checked as well.
+	responses, err := h.subscribe(ctx, wsQuery{Query: `subscription { newBlocks { number } }`})
+	if err == nil || !strings.Contains(err.Error(), "eth_subscribe not allowed") {
+		t.Fatalf("wrong subscription error: %v", err)
+	}
+	if responses != nil {
+		t.Fatal("subscription started")
+	}
+}
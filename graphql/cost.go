@@ -0,0 +1,274 @@
+// Copyright 2022 The go-confero Authors
+// This file is part of the go-confero library.
+//
+// The go-confero library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-confero library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-confero library. If not, see <http://www.gnu.org/licenses/>.
+
+package graphql
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+
+	"github.com/graph-gophers/graphql-go/types"
+)
+
+const (
+	// defaultFieldCost is the cost of resolving a field that isn't listed in
+	// fieldCosts.
+	defaultFieldCost = 1
+
+	// defaultListSize is the estimated length of lists that aren't listed in
+	// listSizes.
+	defaultListSize = 10
+)
+
+// Estimated list lengths used by the cost analysis.
+const (
+	estimatedBlockTransactions   = 200
+	estimatedTransactionLogs     = 10
+	estimatedBlockLogs           = estimatedBlockTransactions * estimatedTransactionLogs
+	estimatedBlockOmmers         = 2
+	estimatedPendingTransactions = 5000
+)
+
+// fieldCosts are the weights of the fields which are expensive to resolve, by
+// type and field name. Fields that need a database lookup are more expensive
+// than fields of objects already loaded, and fields executing the EVM are the
+// most expensive ones.
+var fieldCosts = map[string]uint64{
+	"Query.block":                   10,
+	"Query.blocks":                  10,
+	"Query.transaction":             10,
+	"Query.gasPrice":                10,
+	"Query.maxPriorityFeePerGas":    10,
+	"Mutation.sendRawTransaction":   100,
+	"Block.parent":                  10,
+	"Block.ommers":                  10,
+	"Block.ommerAt":                 10,
+	"Block.totalDifficulty":         10,
+	"Block.call":                    1000,
+	"Block.estimateGas":             1000,
+	"Transaction.block":             10,
+	"Transaction.status":            10,
+	"Transaction.gasUsed":           10,
+	"Transaction.cumulativeGasUsed": 10,
+	"Transaction.effectiveGasPrice": 10,
+	"Transaction.createdContract":   10,
+	"Transaction.logs":              10,
+	"Transaction.rawReceipt":        10,
+	"Account.balance":               10,
+	"Account.transactionCount":      10,
+	"Account.code":                  10,
+	"Account.storage":               10,
+	"Pending.transactionCount":      10,
+	"Pending.call":                  1000,
+	"Pending.estimateGas":           1000,
+}
+
+// listSizes are the estimated lengths of lists, by type and field name. Lists
+// whose length depends on the arguments are estimated in listSize.
+var listSizes = map[string]uint64{
+	"Block.transactions":   estimatedBlockTransactions,
+	"Block.logs":           estimatedBlockLogs,
+	"Block.ommers":         estimatedBlockOmmers,
+	"Transaction.logs":     estimatedTransactionLogs,
+	"Pending.transactions": estimatedPendingTransactions,
+}
+
+// costAnalysis estimates the cost of a GraphQL operation before running it.
+type costAnalysis struct {
+	schema    *types.Schema
+	vars      map[string]interface{}
+	fragments map[string]*fragment
+	head      uint64 // current head block, for block ranges ending at the head
+	visiting  map[string]bool
+}
+
+// queryCost returns the estimated cost of the named operation of a document. The
+// cost of a field is its weight plus the cost of its selections, multiplied by
+// the estimated length of the list it returns. Block ranges are estimated from
+// the arguments, using head for ranges ending at the latest block.
+func queryCost(schema *types.Schema, doc *document, operationName string, variables map[string]interface{}, head uint64) (uint64, error) {
+	op := doc.operation(operationName)
+	if op == nil {
+		return 0, fmt.Errorf("operation %q not found", operationName)
+	}
+	root, ok := schema.EntryPoints[op.typ]
+	if !ok {
+		return 0, fmt.Errorf("%s operations not supported", op.typ)
+	}
+	vars := make(map[string]interface{})
+	for name, value := range op.defaults {
+		vars[name] = value
+	}
+	for name, value := range variables {
+		vars[name] = value
+	}
+	a := &costAnalysis{
+		schema:    schema,
+		vars:      vars,
+		fragments: doc.fragments,
+		head:      head,
+		visiting:  make(map[string]bool),
+	}
+	return a.selectionCost(root.TypeName(), op.selections), nil
+}
+
+// selectionCost returns the cost of a selection set on the given type.
+func (a *costAnalysis) selectionCost(typeName string, selections []*selection) uint64 {
+	object, ok := a.schema.Types[typeName].(*types.ObjectTypeDefinition)
+	if !ok {
+		return 0
+	}
+	var cost uint64
+	for _, sel := range selections {
+		switch {
+		case sel.fragment != "":
+			frag, ok := a.fragments[sel.fragment]
+			if !ok || a.visiting[sel.fragment] {
+				continue
+			}
+			a.visiting[sel.fragment] = true
+			cost = addCost(cost, a.selectionCost(frag.typeName, frag.selections))
+			delete(a.visiting, sel.fragment)
+
+		case sel.name == "":
+			// Inline fragment.
+			inlineType := sel.typeName
+			if inlineType == "" {
+				inlineType = typeName
+			}
+			cost = addCost(cost, a.selectionCost(inlineType, sel.selections))
+
+		default:
+			field := object.Fields.Get(sel.name)
+			if field == nil {
+				continue
+			}
+			key := typeName + "." + sel.name
+			weight, ok := fieldCosts[key]
+			if !ok {
+				weight = defaultFieldCost
+			}
+			fieldType, isList := unwrapType(field.Type)
+			itemCost := addCost(weight, a.selectionCost(fieldType, sel.selections))
+			if isList {
+				itemCost = mulCost(itemCost, a.listSize(key, sel.args))
+			}
+			cost = addCost(cost, itemCost)
+		}
+	}
+	return cost
+}
+
+// listSize returns the estimated length of the list returned by a field.
+func (a *costAnalysis) listSize(key string, args map[string]interface{}) uint64 {
+	switch key {
+	case "Query.blocks":
+		from, ok := a.number(args["from"])
+		if !ok {
+			from = 0
+		}
+		return a.blockRange(from, args["to"])
+
+	case "Query.logs":
+		filter, _ := a.resolve(args["filter"]).(map[string]interface{})
+		from, ok := a.number(filter["fromBlock"])
+		if !ok {
+			from = a.head
+		}
+		return mulCost(a.blockRange(from, filter["toBlock"]), estimatedBlockLogs)
+	}
+	if size, ok := listSizes[key]; ok {
+		return size
+	}
+	return defaultListSize
+}
+
+// blockRange returns the number of blocks from the given block until the given
+// end value, or until the head if it's missing.
+func (a *costAnalysis) blockRange(from uint64, toValue interface{}) uint64 {
+	to, ok := a.number(toValue)
+	if !ok {
+		to = a.head
+	}
+	if to < from {
+		return 0
+	}
+	return addCost(to-from, 1)
+}
+
+// number converts an argument to a block number.
+func (a *costAnalysis) number(value interface{}) (uint64, bool) {
+	switch v := a.resolve(value).(type) {
+	case int64:
+		if v >= 0 {
+			return uint64(v), true
+		}
+	case float64:
+		if v >= 0 && v < math.MaxUint64 {
+			return uint64(v), true
+		}
+	case string:
+		n, ok := new(big.Int).SetString(v, 0)
+		if ok && n.IsUint64() {
+			return n.Uint64(), true
+		}
+	}
+	return 0, false
+}
+
+// resolve replaces a variable reference by the value of the variable.
+func (a *costAnalysis) resolve(value interface{}) interface{} {
+	if ref, ok := value.(variable); ok {
+		return a.vars[string(ref)]
+	}
+	return value
+}
+
+// unwrapType returns the name of the named type wrapped in a field type, and
+// whether the field returns a list.
+func unwrapType(t types.Type) (string, bool) {
+	var list bool
+	for {
+		switch w := t.(type) {
+		case *types.NonNull:
+			t = w.OfType
+		case *types.List:
+			list = true
+			t = w.OfType
+		case types.NamedType:
+			return w.TypeName(), list
+		default:
+			return "", list
+		}
+	}
+}
+
+// addCost adds two costs, saturating at the maximum cost.
+func addCost(a, b uint64) uint64 {
+	if a > math.MaxUint64-b {
+		return math.MaxUint64
+	}
+	return a + b
+}
+
+// mulCost multiplies two costs, saturating at the maximum cost.
+func mulCost(a, b uint64) uint64 {
+	if a != 0 && b > math.MaxUint64/a {
+		return math.MaxUint64
+	}
+	return a * b
+}
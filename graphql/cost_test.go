@@ -0,0 +1,162 @@
+// Copyright 2022 The go-confero Authors
+// This file is part of the go-confero library.
+//
+// The go-confero library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-confero library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-confero library. If not, see <http://www.gnu.org/licenses/>.
+
+package graphql
+
+import (
+	"context"
+	"math"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/confero-network/go-confero/core"
+	"github.com/confero-network/go-confero/node"
+	"github.com/confero-network/go-confero/params"
+	"github.com/graph-gophers/graphql-go"
+)
+
+func TestQueryCost(t *testing.T) {
+	s, err := graphql.ParseSchema(schema, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		query string
+		vars  map[string]interface{}
+		want  uint64
+	}{
+		{query: `{ block { number } }`, want: 11},
+		{query: `{ a: block { number } b: block(number: 1) { hash } }`, want: 22},
+		{query: `{ blocks(from: 0, to: 9) { number } }`, want: 110},
+		{query: `{ blocks(from: 0) { number } }`, want: 1100},
+		{query: `{ blocks(from: "0x5a", to: 10) { number } }`, want: 0},
+		{query: `query($to: Long) { blocks(from: 0, to: $to) { number } }`, vars: map[string]interface{}{"to": float64(4)}, want: 55},
+		{query: `query($to: Long = 4) { blocks(from: 0, to: $to) { number } }`, want: 55},
+		{query: `{ block { transactions { hash } } }`, want: 410},
+		{
+			query: `query { block { ...F } } fragment F on Block { transactions { logs { index } } }`,
+			want:  10 + 200*(1+10*(10+1)),
+		},
+		{query: `{ block { ... on Block { number } } }`, want: 11},
+		{query: `{ logs(filter: {fromBlock: 0, toBlock: 9}) { index } }`, want: 10 * 2000 * 2},
+		{query: `{ block { call(data: {to: "0x01"}) { status } } }`, want: 1011},
+		{
+			// Block ranges ending at the head are estimated from the head.
+			query: `{ blocks(from: 0) { transactions { logs { transaction { from { balance } } } } } }`,
+			want:  100 * (10 + 200*(1+10*(10+1+(1+10)))),
+		},
+		{query: `{ blocks(from: 0, to: 9000000000000000000) { transactions { logs { index } } } }`, want: math.MaxUint64},
+		// Comments and block strings.
+		{query: "# a comment { blocks(from: 0) { number } }\n{ block(hash: \"\"\"0x\"\"\") { number } }", want: 11},
+		// Escape sequences and byte order marks.
+		{query: "\uFEFF{ block(hash: \"\\/\\u0030\\u{78}\") { number } }", want: 11},
+		{query: `{ block(hash: """\""" { blocks(from: 0) { number } }""") { number } }`, want: 11},
+	}
+	for i, test := range tests {
+		doc, err := parseDocument(test.query)
+		if err != nil {
+			t.Errorf("test %d: error: %v", i, err)
+			continue
+		}
+		have, err := queryCost(s.ASTSchema(), doc, "", test.vars, 99)
+		if err != nil {
+			t.Errorf("test %d: error: %v", i, err)
+			continue
+		}
+		if have != test.want {
+			t.Errorf("test %d: wrong cost: have %d, want %d", i, have, test.want)
+		}
+	}
+	// Strings are unescaped.
+	doc, err := parseDocument(`{ a(s: "\"\\\/\u0030\u{78}\n", b: """ \""" "" """) }`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if args := doc.operations[0].selections[0].args; args["s"] != "\"\\/0x\n" || args["b"] != ` """ "" ` {
+		t.Errorf("wrong strings %q", args)
+	}
+	// Syntax errors are reported.
+	for _, query := range []string{`{ block { number }`, `{ block(number: ) { number } }`, `query Q($n Long) { chainID }`} {
+		if _, err := parseDocument(query); err == nil {
+			t.Errorf("no error for query %q", query)
+		}
+	}
+}
+
+func TestGraphQLLimits(t *testing.T) {
+	stack, err := node.New(&node.Config{})
+	if err != nil {
+		t.Fatalf("could not create new node: %v", err)
+	}
+	defer stack.Close()
+	h, err := newHandler(stack, nil, nil, []string{}, []string{}, Config{MaxCost: 1000, MaxDepth: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// A query exceeding the maximum cost is rejected before running.
+	res := h.exec(context.Background(), `{ blocks(from: 0, to: 1000) { number } }`, "", nil)
+	if len(res.Errors) != 1 || res.Errors[0].Message != "query cost 11011 exceeds the maximum of 1000" {
+		t.Fatalf("wrong errors: %v", res.Errors)
+	}
+	if res.Errors[0].Extensions["cost"] != uint64(11011) {
+		t.Fatalf("wrong cost extension: %v", res.Errors[0].Extensions)
+	}
+	// A query exceeding the maximum depth is rejected by the schema.
+	res = h.exec(context.Background(), `{ block { parent { parent { parent { number } } } } }`, "", nil)
+	if len(res.Errors) != 1 || !strings.Contains(res.Errors[0].Message, "exceeds max depth") {
+		t.Fatalf("wrong errors: %v", res.Errors)
+	}
+	// Malformed queries are reported by the schema, and never run.
+	res = h.exec(context.Background(), `{ blocks(from: 0, to: 1000) { number }`, "", nil)
+	if len(res.Errors) != 1 || !strings.Contains(res.Errors[0].Message, "syntax error") {
+		t.Fatalf("wrong errors: %v", res.Errors)
+	}
+	// Operations must exist to be checked.
+	res = h.exec(context.Background(), `query A { blocks(from: 0, to: 1000) { number } }`, "B", nil)
+	if len(res.Errors) != 1 || res.Errors[0].Message != `operation "B" not found` {
+		t.Fatalf("wrong errors: %v", res.Errors)
+	}
+	// Subscriptions are checked as well.
+	responses, err := h.subscribe(context.Background(), wsQuery{Query: `subscription { newBlocks { transactions { logs { transaction { logs { index } } } } } }`})
+	if err == nil || !strings.Contains(err.Error(), "exceeds the maximum") {
+		t.Fatalf("wrong subscription error: %v", err)
+	}
+	if responses != nil {
+		t.Fatal("subscription started")
+	}
+}
+
+func TestGraphQLTimeout(t *testing.T) {
+	stack := createNode(t)
+	defer stack.Close()
+	genesis := &core.Genesis{
+		Config:     params.AllEthashProtocolChanges,
+		GasLimit:   11500000,
+		Difficulty: big.NewInt(1048576),
+	}
+	h, _ := newGQLService(t, stack, genesis, 10, func(i int, gen *core.BlockGen) {})
+	if err := stack.Start(); err != nil {
+		t.Fatalf("could not start node: %v", err)
+	}
+	h.config.Timeout = time.Nanosecond
+
+	res := h.exec(context.Background(), `{ blocks(from: 0) { number } }`, "", nil)
+	if len(res.Errors) == 0 || !strings.Contains(res.Errors[0].Message, context.DeadlineExceeded.Error()) {
+		t.Fatalf("wrong errors: %v", res.Errors)
+	}
+}
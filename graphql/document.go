@@ -0,0 +1,455 @@
+// Copyright 2022 The go-confero Authors
+// This file is part of the go-confero library.
+//
+// The go-confero library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-confero library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-confero library. If not, see <http://www.gnu.org/licenses/>.
+
+package graphql
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"text/scanner"
+)
+
+// document is a simplified syntax tree of a GraphQL document. It contains what
+// is needed to inspect a query before running it, the schema validates the full
+// document when running it.
+
+type document struct {
+	operations []*operation
+	fragments  map[string]*fragment
+}
+
+// operation returns the named operation, or the first operation if no name is
+// given. It returns nil if there is no such operation.
+func (doc *document) operation(name string) *operation {
+	for _, op := range doc.operations {
+		if name == "" || op.name == name {
+			return op
+		}
+	}
+	return nil
+}
+
+type operation struct {
+	typ        string
+	name       string
+	defaults   map[string]interface{} // default values of variables
+	selections []*selection
+}
+
+type fragment struct {
+	typeName   string
+	selections []*selection
+}
+
+// selection is a field, a fragment spread or an inline fragment.
+type selection struct {
+	name       string // field name, empty for fragments
+	args       map[string]interface{}
+	fragment   string // name of a fragment spread
+	typeName   string // type condition of an inline fragment
+	selections []*selection
+}
+
+// variable is a reference to a variable in an argument value.
+type variable string
+
+var errSyntax = errors.New("syntax error")
+
+// parser parses GraphQL documents into their simplified syntax trees.
+type parser struct {
+	sc  scanner.Scanner
+	tok rune
+	err error
+}
+
+// parseDocument parses a GraphQL document.
+func parseDocument(query string) (doc *document, err error) {
+	p := new(parser)
+	p.sc.Init(strings.NewReader(query))
+	p.sc.Mode = scanner.ScanIdents | scanner.ScanInts | scanner.ScanFloats
+	p.sc.Error = func(s *scanner.Scanner, msg string) { p.fail(msg) }
+
+	// Syntax errors abort the parser by panicking with errSyntax.
+	defer func() {
+		if r := recover(); r != nil {
+			if r != errSyntax {
+				panic(r)
+			}
+			doc, err = nil, p.err
+		}
+	}()
+	p.next()
+
+	doc = &document{fragments: make(map[string]*fragment)}
+	for p.tok != scanner.EOF {
+		if p.tok == '{' {
+			doc.operations = append(doc.operations, &operation{typ: "query", selections: p.parseSelectionSet()})
+			continue
+		}
+		switch keyword := p.ident(); keyword {
+		case "query", "mutation", "subscription":
+			op := &operation{typ: keyword, defaults: make(map[string]interface{})}
+			if p.tok == scanner.Ident {
+				op.name = p.ident()
+			}
+			if p.tok == '(' {
+				p.parseVariableDefinitions(op.defaults)
+			}
+			p.parseDirectives()
+			op.selections = p.parseSelectionSet()
+			doc.operations = append(doc.operations, op)
+
+		case "fragment":
+			name := p.ident()
+			if p.ident() != "on" {
+				p.fail("expected type condition")
+			}
+			fragment := &fragment{typeName: p.ident()}
+			p.parseDirectives()
+			fragment.selections = p.parseSelectionSet()
+			doc.fragments[name] = fragment
+
+		default:
+			p.fail("unexpected " + keyword)
+		}
+	}
+	return doc, nil
+}
+
+// fail aborts parsing with the given error.
+func (p *parser) fail(msg string) {
+	if p.err == nil {
+		p.err = fmt.Errorf("%s: %s", p.sc.Position, msg)
+	}
+	panic(errSyntax)
+}
+
+// next advances to the next token, skipping comments, commas and byte order marks.
+func (p *parser) next() {
+	p.tok = p.sc.Scan()
+	for p.tok == '#' || p.tok == ',' || p.tok == '\uFEFF' {
+		if p.tok == '#' {
+			for ch := p.sc.Peek(); ch != '\n' && ch != scanner.EOF; ch = p.sc.Peek() {
+				p.sc.Next()
+			}
+		}
+		p.tok = p.sc.Scan()
+	}
+}
+
+// expect consumes the given token.
+func (p *parser) expect(tok rune) {
+	if p.tok != tok {
+		p.fail(fmt.Sprintf("expected %s, got %s", scanner.TokenString(tok), scanner.TokenString(p.tok)))
+	}
+	p.next()
+}
+
+// ident consumes a name.
+func (p *parser) ident() string {
+	name := p.sc.TokenText()
+	p.expect(scanner.Ident)
+	return name
+}
+
+// parseVariableDefinitions parses variable definitions, collecting the default
+// values of the variables.
+func (p *parser) parseVariableDefinitions(defaults map[string]interface{}) {
+	p.expect('(')
+	for p.tok != ')' {
+		p.expect('$')
+		name := p.ident()
+		p.expect(':')
+		p.parseType()
+		if p.tok == '=' {
+			p.next()
+			defaults[name] = p.parseValue()
+		}
+		p.parseDirectives()
+	}
+	p.next()
+}
+
+// parseType skips a type reference.
+func (p *parser) parseType() {
+	if p.tok == '[' {
+		p.next()
+		p.parseType()
+		p.expect(']')
+	} else {
+		p.ident()
+	}
+	if p.tok == '!' {
+		p.next()
+	}
+}
+
+// parseDirectives skips directives.
+func (p *parser) parseDirectives() {
+	for p.tok == '@' {
+		p.next()
+		p.ident()
+		if p.tok == '(' {
+			p.parseArguments()
+		}
+	}
+}
+
+// parseSelectionSet parses a selection set.
+func (p *parser) parseSelectionSet() []*selection {
+	var selections []*selection
+	p.expect('{')
+	for p.tok != '}' {
+		if p.tok == scanner.EOF {
+			p.fail("unexpected end of document")
+		}
+		if p.tok == '.' {
+			p.expect('.')
+			p.expect('.')
+			p.expect('.')
+
+			sel := new(selection)
+			if p.tok == scanner.Ident && p.sc.TokenText() != "on" {
+				sel.fragment = p.ident()
+				p.parseDirectives()
+			} else {
+				if p.tok == scanner.Ident {
+					p.next()
+					sel.typeName = p.ident()
+				}
+				p.parseDirectives()
+				sel.selections = p.parseSelectionSet()
+			}
+			selections = append(selections, sel)
+			continue
+		}
+		sel := &selection{name: p.ident()}
+		if p.tok == ':' {
+			p.next()
+			sel.name = p.ident()
+		}
+		if p.tok == '(' {
+			sel.args = p.parseArguments()
+		}
+		p.parseDirectives()
+		if p.tok == '{' {
+			sel.selections = p.parseSelectionSet()
+		}
+		selections = append(selections, sel)
+	}
+	p.next()
+	return selections
+}
+
+// parseArguments parses a list of arguments.
+func (p *parser) parseArguments() map[string]interface{} {
+	args := make(map[string]interface{})
+	p.expect('(')
+	for p.tok != ')' {
+		name := p.ident()
+		p.expect(':')
+		args[name] = p.parseValue()
+	}
+	p.next()
+	return args
+}
+
+// parseValue parses an argument value.
+func (p *parser) parseValue() interface{} {
+	switch p.tok {
+	case '$':
+		p.next()
+		return variable(p.ident())
+
+	case '-':
+		p.next()
+		switch v := p.parseValue().(type) {
+		case int64:
+			return -v
+		case float64:
+			return -v
+		}
+		p.fail("expected number")
+
+	case scanner.Int:
+		v, err := strconv.ParseInt(p.sc.TokenText(), 10, 64)
+		if err != nil {
+			// Out of range, leave it to the schema to reject it.
+			f, _ := strconv.ParseFloat(p.sc.TokenText(), 64)
+			p.next()
+			return f
+		}
+		p.next()
+		return v
+
+	case scanner.Float:
+		v, err := strconv.ParseFloat(p.sc.TokenText(), 64)
+		if err != nil {
+			p.fail(err.Error())
+		}
+		p.next()
+		return v
+
+	case '"':
+		return p.parseString()
+
+	case scanner.Ident:
+		switch name := p.ident(); name {
+		case "true":
+			return true
+		case "false":
+			return false
+		case "null":
+			return nil
+		default:
+			return name
+		}
+
+	case '[':
+		var list []interface{}
+		p.next()
+		for p.tok != ']' {
+			if p.tok == scanner.EOF {
+				p.fail("unexpected end of document")
+			}
+			list = append(list, p.parseValue())
+		}
+		p.next()
+		return list
+
+	case '{':
+		object := make(map[string]interface{})
+		p.next()
+		for p.tok != '}' {
+			name := p.ident()
+			p.expect(':')
+			object[name] = p.parseValue()
+		}
+		p.next()
+		return object
+	}
+	p.fail("unexpected " + scanner.TokenString(p.tok))
+	return nil
+}
+
+// parseString parses a string or block string. Strings are read by the parser
+// itself, as their escape sequences differ from the ones of Go.
+func (p *parser) parseString() string {
+	if p.sc.Peek() == '"' {
+		p.sc.Next()
+		if p.sc.Peek() != '"' {
+			p.next()
+			return ""
+		}
+		p.sc.Next()
+		return p.parseBlockString()
+	}
+	var b strings.Builder
+	for {
+		switch ch := p.sc.Next(); ch {
+		case scanner.EOF, '\n', '\r':
+			p.fail("unterminated string")
+		case '"':
+			p.next()
+			return b.String()
+		case '\\':
+			b.WriteRune(p.parseEscape())
+		default:
+			b.WriteRune(ch)
+		}
+	}
+}
+
+// parseEscape parses the escape sequence of a string after its backslash.
+func (p *parser) parseEscape() rune {
+	switch ch := p.sc.Next(); ch {
+	case '"', '\\', '/':
+		return ch
+	case 'b':
+		return '\b'
+	case 'f':
+		return '\f'
+	case 'n':
+		return '\n'
+	case 'r':
+		return '\r'
+	case 't':
+		return '\t'
+	case 'u':
+		var hex strings.Builder
+		if p.sc.Peek() == '{' {
+			// Variable-width escape sequence, e.g. \u{1F600}
+			for p.sc.Next(); p.sc.Peek() != '}'; {
+				if ch := p.sc.Peek(); ch == scanner.EOF || ch == '"' {
+					p.fail("unterminated escape sequence")
+				}
+				hex.WriteRune(p.sc.Next())
+			}
+			p.sc.Next()
+		} else {
+			for i := 0; i < 4; i++ {
+				hex.WriteRune(p.sc.Next())
+			}
+		}
+		v, err := strconv.ParseUint(hex.String(), 16, 32)
+		if err != nil {
+			p.fail("invalid escape sequence")
+		}
+		return rune(v)
+	}
+	p.fail("invalid escape sequence")
+	return 0
+}
+
+// parseBlockString parses a block string after its opening quotes.
+func (p *parser) parseBlockString() string {
+	var (
+		b      strings.Builder
+		quotes int
+	)
+	for quotes < 3 {
+		ch := p.sc.Next()
+		switch ch {
+		case scanner.EOF:
+			p.fail("unterminated block string")
+		case '"':
+			quotes++
+		default:
+			for ; quotes > 0; quotes-- {
+				b.WriteByte('"')
+			}
+			if ch == '\\' && p.sc.Peek() == '"' {
+				// Escaped triple quotes, other quotes are kept as they are.
+				for p.sc.Peek() == '"' && quotes < 3 {
+					p.sc.Next()
+					quotes++
+				}
+				if quotes < 3 {
+					b.WriteRune(ch)
+				}
+				for ; quotes > 0; quotes-- {
+					b.WriteByte('"')
+				}
+				continue
+			}
+			b.WriteRune(ch)
+		}
+	}
+	p.next()
+	return b.String()
+}
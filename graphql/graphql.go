@@ -1226,6 +1226,10 @@ func (r *Resolver) Blocks(ctx context.Context, args struct {
 	}
 	ret := make([]*Block, 0, to-from+1)
 	for i := from; i <= to; i++ {
+		// Stop when the query deadline is reached.
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
 		numberOrHash := rpc.BlockNumberOrHashWithNumber(i)
 		block := &Block{
 			r:            r,
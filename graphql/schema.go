@@ -16,7 +16,8 @@
 
 package graphql
 
-const schema string = `
+// schemaTypes contains the types shared by the query and subscription schemas.
+const schemaTypes string = `
     # Bytes32 is a 32 byte binary string, represented as 0x-prefixed hexadecimal.
     scalar Bytes32
     # Address is a 20 byte Confero address, represented as 0x-prefixed hexadecimal.
@@ -31,11 +32,6 @@ const schema string = `
     # Long is a 64 bit unsigned integer.
     scalar Long
 
-    schema {
-        query: Query
-        mutation: Mutation
-    }
-
     # Account is an Confero account at a particular block.
     type Account {
         # Address is the address owning the account.
@@ -329,6 +325,15 @@ const schema string = `
       estimateGas(data: CallData!): Long!
     }
 
+`
+
+// schema is the schema of the GraphQL endpoint.
+const schema string = schemaTypes + `
+    schema {
+        query: Query
+        mutation: Mutation
+    }
+
     type Query {
         # Block fetches an Confero block by number or by hash. If neither is
         # supplied, the most recent known block is returned.
@@ -359,3 +364,28 @@ const schema string = `
         sendRawTransaction(data: Bytes!): Bytes32!
     }
 `
+
+// subscriptionSchema is the schema of subscriptions, served over websockets.
+// The subscription resolvers share the names of the query resolvers, so the
+// subscriptions live in a schema of their own.
+const subscriptionSchema string = schemaTypes + `
+    schema {
+        query: Query
+        subscription: Subscription
+    }
+
+    type Query {
+        # ChainID returns the current chain ID for transaction replay protection.
+        chainID: BigInt!
+    }
+
+    type Subscription {
+        # NewBlocks returns the blocks imported into the canonical chain.
+        newBlocks: Block!
+        # Logs returns the log entries matching the provided filter, as they are
+        # included in new blocks. The block range of the filter is ignored.
+        logs(filter: FilterCriteria!): Log!
+        # PendingTransactions returns the transactions entering the transaction pool.
+        pendingTransactions: Transaction!
+    }
+`
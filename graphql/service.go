@@ -17,20 +17,40 @@
 package graphql
 
 import (
+	"context"
 	"encoding/json"
+	"fmt"
 	"net/http"
+	"time"
 
 	"github.com/confero-network/go-confero/eth/filters"
 	"github.com/confero-network/go-confero/internal/ethapi"
 	"github.com/confero-network/go-confero/node"
+	"github.com/gorilla/websocket"
 	"github.com/graph-gophers/graphql-go"
+	"github.com/graph-gophers/graphql-go/errors"
 )
 
+// Config contains the limits of the GraphQL service.
+type Config struct {
+	MaxCost  uint64        // Maximum estimated cost of a query, zero means no limit
+	MaxDepth int           // Maximum nesting depth of a query, zero means no limit
+	Timeout  time.Duration // Maximum execution time of a query, zero means no limit
+}
+
 type handler struct {
-	Schema *graphql.Schema
+	Schema        *graphql.Schema
+	Subscriptions *graphql.Schema
+	backend       ethapi.Backend
+	config        Config
+	origins       []string // origins allowed to open websocket connections
 }
 
-func (h handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+func (h *handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if websocket.IsWebSocketUpgrade(r) {
+		h.serveWebsocket(w, r)
+		return
+	}
 	var params struct {
 		Query         string                 `json:"query"`
 		OperationName string                 `json:"operationName"`
@@ -41,7 +61,7 @@ func (h handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	response := h.Schema.Exec(r.Context(), params.Query, params.OperationName, params.Variables)
+	response := h.exec(r.Context(), params.Query, params.OperationName, params.Variables)
 	responseJSON, err := json.Marshal(response)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
@@ -55,27 +75,102 @@ func (h handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	w.Write(responseJSON)
 }
 
+// exec runs a query or mutation against the main schema, after checking it
+// against the API key of the request and its cost. The execution is aborted when the deadline is reached.
+func (h *handler) exec(ctx context.Context, query string, operationName string, variables map[string]interface{}) *graphql.Response {
+	doc, errs := h.parseQuery(query)
+	if errs != nil {
+		return &graphql.Response{Errors: errs}
+	}
+	return h.execDocument(ctx, doc, query, operationName, variables)
+}
+
+// execDocument runs a query or mutation parsed by parseQuery.
+func (h *handler) execDocument(ctx context.Context, doc *document, query string, operationName string, variables map[string]interface{}) *graphql.Response {
+	if err := h.checkAccess(ctx, h.Schema, doc, operationName); err != nil {
+		return &graphql.Response{Errors: []*errors.QueryError{err}}
+	}
+	if err := h.checkCost(h.Schema, doc, operationName, variables); err != nil {
+		return &graphql.Response{Errors: []*errors.QueryError{err}}
+	}
+	if h.config.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, h.config.Timeout)
+		defer cancel()
+	}
+	return h.Schema.Exec(ctx, query, operationName, variables)
+}
+
+// parseQuery parses a query for inspection before running it. If the query is
+// malformed, the errors reported by the schemas are returned. Queries accepted
+// by a schema but not by the parser are rejected as well, such that no query
+// runs without checking its cost.
+func (h *handler) parseQuery(query string) (*document, []*errors.QueryError) {
+	doc, err := parseDocument(query)
+	if err == nil {
+		return doc, nil
+	}
+	errs := h.Schema.Validate(query)
+	if len(errs) == 0 || len(h.Subscriptions.Validate(query)) == 0 {
+		return nil, []*errors.QueryError{{Message: fmt.Sprintf("unsupported query: %v", err)}}
+	}
+	return nil, errs
+}
+
+// checkCost returns an error if the estimated cost of an operation exceeds
+// the configured maximum.
+func (h *handler) checkCost(schema *graphql.Schema, doc *document, operationName string, variables map[string]interface{}) *errors.QueryError {
+	if h.config.MaxCost == 0 {
+		return nil
+	}
+	var head uint64
+	if h.backend != nil {
+		head = h.backend.CurrentHeader().Number.Uint64()
+	}
+	cost, err := queryCost(schema.ASTSchema(), doc, operationName, variables, head)
+	if err != nil {
+		return &errors.QueryError{Message: err.Error()}
+	}
+	if cost > h.config.MaxCost {
+		return &errors.QueryError{
+			Message:    fmt.Sprintf("query cost %d exceeds the maximum of %d", cost, h.config.MaxCost),
+			Extensions: map[string]interface{}{"cost": cost, "maxCost": h.config.MaxCost},
+		}
+	}
+	return nil
+}
+
 // New constructs a new GraphQL service instance.
-func New(stack *node.Node, backend ethapi.Backend, filterSystem *filters.FilterSystem, cors, vhosts []string) error {
-	_, err := newHandler(stack, backend, filterSystem, cors, vhosts)
+func New(stack *node.Node, backend ethapi.Backend, filterSystem *filters.FilterSystem, cors, vhosts []string, config Config) error {
+	_, err := newHandler(stack, backend, filterSystem, cors, vhosts, config)
 	return err
 }
 
 // newHandler returns a new `http.Handler` that will answer GraphQL queries.
+// Websocket connections are served subscriptions using the graphql-ws protocol.
+// Requests require an API key of the HTTP-RPC server if API keys are configured.
 // It additionally exports an interactive query browser on the / endpoint.
-func newHandler(stack *node.Node, backend ethapi.Backend, filterSystem *filters.FilterSystem, cors, vhosts []string) (*handler, error) {
+func newHandler(stack *node.Node, backend ethapi.Backend, filterSystem *filters.FilterSystem, cors, vhosts []string, config Config) (*handler, error) {
 	q := Resolver{backend, filterSystem}
 
-	s, err := graphql.ParseSchema(schema, &q)
+	s, err := graphql.ParseSchema(schema, &q, graphql.MaxDepth(config.MaxDepth))
+	if err != nil {
+		return nil, err
+	}
+	sq := SubscriptionResolver{Resolver: &q}
+	if filterSystem != nil {
+		sq.events = filters.NewEventSystem(filterSystem, false)
+	}
+	ss, err := graphql.ParseSchema(subscriptionSchema, &sq, graphql.MaxDepth(config.MaxDepth))
 	if err != nil {
 		return nil, err
 	}
-	h := handler{Schema: s}
-	handler := node.NewHTTPHandlerStack(h, cors, vhosts, nil)
+	h := &handler{Schema: s, Subscriptions: ss, backend: backend, config: config, origins: cors}
+	handler := node.NewHTTPHandlerStack(stack.WithAPIKeys(h), cors, vhosts, nil)
 
 	stack.RegisterHandler("GraphQL UI", "/graphql/ui", GraphiQL{})
 	stack.RegisterHandler("GraphQL", "/graphql", handler)
 	stack.RegisterHandler("GraphQL", "/graphql/", handler)
 
-	return &h, nil
+	return h, nil
 }
@@ -0,0 +1,145 @@
+// Copyright 2022 The go-confero Authors
+// This file is part of the go-confero library.
+//
+// The go-confero library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-confero library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-confero library. If not, see <http://www.gnu.org/licenses/>.
+
+package graphql
+
+import (
+	"context"
+	"errors"
+
+	"github.com/confero-network/go-confero"
+	"github.com/confero-network/go-confero/common"
+	"github.com/confero-network/go-confero/core/types"
+	"github.com/confero-network/go-confero/eth/filters"
+	"github.com/confero-network/go-confero/rpc"
+)
+
+var errNoEventSystem = errors.New("subscriptions are not available")
+
+// SubscriptionResolver is the root resolver of the subscription schema.
+type SubscriptionResolver struct {
+	*Resolver
+	events *filters.EventSystem
+}
+
+func (r *SubscriptionResolver) NewBlocks(ctx context.Context) (<-chan *Block, error) {
+	if r.events == nil {
+		return nil, errNoEventSystem
+	}
+	var (
+		headers = make(chan *types.Header)
+		sub     = r.events.SubscribeNewHeads(headers)
+		results = make(chan *Block)
+	)
+	go func() {
+		defer close(results)
+		defer sub.Unsubscribe()
+
+		for {
+			select {
+			case header := <-headers:
+				hash := header.Hash()
+				numberOrHash := rpc.BlockNumberOrHashWithHash(hash, true)
+				block := &Block{r: r.Resolver, numberOrHash: &numberOrHash, hash: hash, header: header}
+				select {
+				case results <- block:
+				case <-ctx.Done():
+					return
+				}
+			case <-sub.Err():
+				return
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return results, nil
+}
+
+func (r *SubscriptionResolver) Logs(ctx context.Context, args struct{ Filter FilterCriteria }) (<-chan *Log, error) {
+	if r.events == nil {
+		return nil, errNoEventSystem
+	}
+	var crit confero.FilterQuery
+	if args.Filter.Addresses != nil {
+		crit.Addresses = *args.Filter.Addresses
+	}
+	if args.Filter.Topics != nil {
+		crit.Topics = *args.Filter.Topics
+	}
+	logs := make(chan []*types.Log)
+	sub, err := r.events.SubscribeLogs(crit, logs)
+	if err != nil {
+		return nil, err
+	}
+	results := make(chan *Log)
+	go func() {
+		defer close(results)
+		defer sub.Unsubscribe()
+
+		for {
+			select {
+			case batch := <-logs:
+				for _, log := range batch {
+					result := &Log{r: r.Resolver, transaction: &Transaction{r: r.Resolver, hash: log.TxHash}, log: log}
+					select {
+					case results <- result:
+					case <-ctx.Done():
+						return
+					}
+				}
+			case <-sub.Err():
+				return
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return results, nil
+}
+
+func (r *SubscriptionResolver) PendingTransactions(ctx context.Context) (<-chan *Transaction, error) {
+	if r.events == nil {
+		return nil, errNoEventSystem
+	}
+	var (
+		hashes  = make(chan []common.Hash)
+		sub     = r.events.SubscribePendingTxs(hashes)
+		results = make(chan *Transaction)
+	)
+	go func() {
+		defer close(results)
+		defer sub.Unsubscribe()
+
+		for {
+			select {
+			case batch := <-hashes:
+				for _, hash := range batch {
+					select {
+					case results <- &Transaction{r: r.Resolver, hash: hash}:
+					case <-ctx.Done():
+						return
+					}
+				}
+			case <-sub.Err():
+				return
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return results, nil
+}
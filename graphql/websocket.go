@@ -0,0 +1,277 @@
+// Copyright 2022 The go-confero Authors
+// This file is part of the go-confero library.
+//
+// The go-confero library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-confero library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-confero library. If not, see <http://www.gnu.org/licenses/>.
+
+package graphql
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/confero-network/go-confero/log"
+	"github.com/gorilla/websocket"
+	"github.com/graph-gophers/graphql-go"
+)
+
+// Message types of the graphql-ws protocol.
+const (
+	gqlConnectionInit      = "connection_init"
+	gqlConnectionAck       = "connection_ack"
+	gqlConnectionError     = "connection_error"
+	gqlConnectionKeepAlive = "ka"
+	gqlConnectionTerminate = "connection_terminate"
+	gqlStart               = "start"
+	gqlStop                = "stop"
+	gqlData                = "data"
+	gqlError               = "error"
+	gqlComplete            = "complete"
+)
+
+const (
+	wsProtocol          = "graphql-ws"
+	wsKeepAliveInterval = 15 * time.Second
+	wsWriteTimeout      = 10 * time.Second
+	wsMaxMessageSize    = 128 * 1024
+	wsMaxOperations     = 100 // maximum number of active operations per connection
+)
+
+// wsMessage is a message of the graphql-ws protocol.
+type wsMessage struct {
+	ID      string          `json:"id,omitempty"`
+	Type    string          `json:"type"`
+	Payload json.RawMessage `json:"payload,omitempty"`
+}
+
+// wsQuery is the payload of a start message.
+type wsQuery struct {
+	Query         string                 `json:"query"`
+	OperationName string                 `json:"operationName"`
+	Variables     map[string]interface{} `json:"variables"`
+}
+
+// wsConn is a websocket connection speaking the graphql-ws protocol.
+type wsConn struct {
+	h    *handler
+	conn *websocket.Conn
+
+	writeLock sync.Mutex
+	opsLock   sync.Mutex
+	ops       map[string]context.CancelFunc // cancel functions of active operations
+}
+
+// serveWebsocket upgrades the request and serves GraphQL operations over the
+// graphql-ws protocol. Subscriptions are served from the subscription schema,
+// queries and mutations from the main schema.
+func (h *handler) serveWebsocket(w http.ResponseWriter, r *http.Request) {
+	upgrader := websocket.Upgrader{
+		Subprotocols: []string{wsProtocol},
+		CheckOrigin:  h.checkOrigin,
+	}
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		log.Debug("GraphQL websocket upgrade failed", "err", err)
+		return
+	}
+	if conn.Subprotocol() != wsProtocol {
+		conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseProtocolError, "unsupported subprotocol"), time.Now().Add(wsWriteTimeout))
+		conn.Close()
+		return
+	}
+	conn.SetReadLimit(wsMaxMessageSize)
+
+	c := &wsConn{h: h, conn: conn, ops: make(map[string]context.CancelFunc)}
+	c.serve(r.Context())
+}
+
+// checkOrigin accepts requests without origin, from the same origin, or from
+// an origin allowed by the CORS configuration.
+func (h *handler) checkOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	if u, err := url.Parse(origin); err == nil && strings.EqualFold(u.Host, r.Host) {
+		return true
+	}
+	for _, allowed := range h.origins {
+		if allowed == "*" || strings.EqualFold(allowed, origin) {
+			return true
+		}
+	}
+	log.Warn("Rejected GraphQL websocket connection", "origin", origin)
+	return false
+}
+
+// serve runs the read loop of the connection until it is closed.
+func (c *wsConn) serve(ctx context.Context) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer func() {
+		cancel()
+		c.conn.Close()
+	}()
+	go c.keepAlive(ctx)
+
+	initialized := false
+	for {
+		var msg wsMessage
+		if err := c.conn.ReadJSON(&msg); err != nil {
+			return
+		}
+		switch msg.Type {
+		case gqlConnectionInit:
+			initialized = true
+			c.write(&wsMessage{Type: gqlConnectionAck})
+
+		case gqlStart:
+			if !initialized {
+				c.writeError(gqlConnectionError, "", "connection not initialized")
+				return
+			}
+			c.start(ctx, msg.ID, msg.Payload)
+
+		case gqlStop:
+			c.stop(msg.ID)
+
+		case gqlConnectionTerminate:
+			return
+
+		default:
+			c.writeError(gqlError, msg.ID, "unknown message type "+msg.Type)
+		}
+	}
+}
+
+// keepAlive periodically sends keep-alive messages until the context ends.
+func (c *wsConn) keepAlive(ctx context.Context) {
+	ticker := time.NewTicker(wsKeepAliveInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			c.write(&wsMessage{Type: gqlConnectionKeepAlive})
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// start runs an operation and streams its results to the client.
+func (c *wsConn) start(ctx context.Context, id string, payload json.RawMessage) {
+	var query wsQuery
+	if err := json.Unmarshal(payload, &query); err != nil {
+		c.writeError(gqlError, id, "invalid payload: "+err.Error())
+		return
+	}
+	c.opsLock.Lock()
+	if _, ok := c.ops[id]; ok || id == "" {
+		c.opsLock.Unlock()
+		c.writeError(gqlError, id, "invalid or duplicate operation id")
+		return
+	}
+	if len(c.ops) >= wsMaxOperations {
+		c.opsLock.Unlock()
+		c.writeError(gqlError, id, "too many active operations")
+		return
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	c.ops[id] = cancel
+	c.opsLock.Unlock()
+
+	responses, err := c.h.subscribe(ctx, query)
+	if err != nil {
+		c.stop(id)
+		c.writeError(gqlError, id, err.Error())
+		return
+	}
+	go func() {
+		for response := range responses {
+			payload, err := json.Marshal(response)
+			if err != nil {
+				log.Warn("Failed to encode GraphQL response", "err", err)
+				continue
+			}
+			c.write(&wsMessage{ID: id, Type: gqlData, Payload: payload})
+		}
+		// Only report completion if the operation was not stopped.
+		c.opsLock.Lock()
+		_, active := c.ops[id]
+		delete(c.ops, id)
+		c.opsLock.Unlock()
+
+		if active && ctx.Err() == nil {
+			c.write(&wsMessage{ID: id, Type: gqlComplete})
+		}
+		cancel()
+	}()
+}
+
+// stop cancels an active operation.
+func (c *wsConn) stop(id string) {
+	c.opsLock.Lock()
+	defer c.opsLock.Unlock()
+
+	if cancel, ok := c.ops[id]; ok {
+		cancel()
+		delete(c.ops, id)
+	}
+}
+
+// write sends a message to the client.
+func (c *wsConn) write(msg *wsMessage) {
+	c.writeLock.Lock()
+	defer c.writeLock.Unlock()
+
+	c.conn.SetWriteDeadline(time.Now().Add(wsWriteTimeout))
+	if err := c.conn.WriteJSON(msg); err != nil {
+		log.Debug("Failed to write GraphQL websocket message", "err", err)
+	}
+}
+
+// writeError sends an error message of the given type to the client.
+func (c *wsConn) writeError(typ string, id string, message string) {
+	payload, _ := json.Marshal(map[string]string{"message": message})
+	c.write(&wsMessage{ID: id, Type: typ, Payload: payload})
+}
+
+// subscribe runs an operation received over a websocket. Subscriptions are
+// handed to the subscription schema, queries and mutations are executed against
+// the main schema and produce a single response.
+func (h *handler) subscribe(ctx context.Context, query wsQuery) (<-chan interface{}, error) {
+	responses := make(chan interface{}, 1)
+	doc, errs := h.parseQuery(query.Query)
+	if errs != nil {
+		responses <- &graphql.Response{Errors: errs}
+		close(responses)
+		return responses, nil
+	}
+	if op := doc.operation(query.OperationName); op != nil && op.typ == "subscription" {
+		if err := h.checkAccess(ctx, h.Subscriptions, doc, query.OperationName); err != nil {
+			return nil, err
+		}
+		if err := h.checkCost(h.Subscriptions, doc, query.OperationName, query.Variables); err != nil {
+			return nil, err
+		}
+		return h.Subscriptions.Subscribe(ctx, query.Query, query.OperationName, query.Variables)
+	}
+	responses <- h.execDocument(ctx, doc, query.Query, query.OperationName, query.Variables)
+	close(responses)
+	return responses, nil
+}
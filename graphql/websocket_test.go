@@ -0,0 +1,208 @@
+// Copyright 2022 The go-confero Authors
+// This file is part of the go-confero library.
+//
+// The go-confero library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-confero library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-confero library. If not, see <http://www.gnu.org/licenses/>.
+
+package graphql
+
+import (
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/confero-network/go-confero/common"
+	"github.com/confero-network/go-confero/consensus/ethash"
+	"github.com/confero-network/go-confero/core"
+	"github.com/confero-network/go-confero/core/types"
+	"github.com/confero-network/go-confero/crypto"
+	"github.com/confero-network/go-confero/params"
+	"github.com/gorilla/websocket"
+)
+
+// dialGraphQLWS opens an initialized graphql-ws connection to the node.
+func dialGraphQLWS(t *testing.T, endpoint string) *websocket.Conn {
+	dialer := websocket.Dialer{Subprotocols: []string{wsProtocol}}
+	conn, _, err := dialer.Dial(strings.Replace(endpoint, "http://", "ws://", 1)+"/graphql", http.Header{"Accept-Encoding": {"gzip"}})
+	if err != nil {
+		t.Fatalf("could not dial websocket: %v", err)
+	}
+	if err := conn.WriteJSON(&wsMessage{Type: gqlConnectionInit}); err != nil {
+		t.Fatal(err)
+	}
+	if msg := readGraphQLWS(t, conn); msg.Type != gqlConnectionAck {
+		t.Fatalf("wrong message type %q, want %q", msg.Type, gqlConnectionAck)
+	}
+	return conn
+}
+
+// startGraphQLWS starts an operation on the connection.
+func startGraphQLWS(t *testing.T, conn *websocket.Conn, id string, query string) {
+	payload, _ := json.Marshal(&wsQuery{Query: query})
+	if err := conn.WriteJSON(&wsMessage{ID: id, Type: gqlStart, Payload: payload}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// readGraphQLWS reads the next message that isn't a keep-alive.
+func readGraphQLWS(t *testing.T, conn *websocket.Conn) *wsMessage {
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	for {
+		var msg wsMessage
+		if err := conn.ReadJSON(&msg); err != nil {
+			t.Fatalf("could not read message: %v", err)
+		}
+		if msg.Type != gqlConnectionKeepAlive {
+			return &msg
+		}
+	}
+}
+
+// expectGraphQLWS reads the next message and checks it against the expected one.
+func expectGraphQLWS(t *testing.T, conn *websocket.Conn, id, typ, payload string) {
+	msg := readGraphQLWS(t, conn)
+	if msg.ID != id || msg.Type != typ || string(msg.Payload) != payload {
+		t.Fatalf("wrong message: have %s %s %s, want %s %s %s", msg.ID, msg.Type, msg.Payload, id, typ, payload)
+	}
+}
+
+func TestGraphQLSubscriptions(t *testing.T) {
+	var (
+		key, _  = crypto.GenerateKey()
+		addr    = crypto.PubkeyToAddress(key.PublicKey)
+		dad     = common.HexToAddress("0x0000000000000000000000000000000000000dad")
+		genesis = &core.Genesis{
+			Config:     params.AllEthashProtocolChanges,
+			GasLimit:   11500000,
+			Difficulty: big.NewInt(1048576),
+			Alloc: core.GenesisAlloc{
+				addr: {Balance: big.NewInt(params.Cofe)},
+				dad: {
+					// LOG0(0, 0), LOG0(0, 0), RETURN(0, 0)
+					Code:    common.Hex2Bytes("60006000a060006000a060006000f3"),
+					Balance: big.NewInt(0),
+				},
+			},
+		}
+		signer = types.LatestSigner(genesis.Config)
+		stack  = createNode(t)
+	)
+	defer stack.Close()
+
+	_, backend := newGQLService(t, stack, genesis, 1, func(i int, gen *core.BlockGen) {})
+	if err := stack.Start(); err != nil {
+		t.Fatalf("could not start node: %v", err)
+	}
+	conn := dialGraphQLWS(t, stack.HTTPEndpoint())
+	defer conn.Close()
+
+	// Operations are processed in order, so once the query completes, the
+	// subscriptions are installed.
+	startGraphQLWS(t, conn, "blocks", `subscription { newBlocks { number } }`)
+	startGraphQLWS(t, conn, "logs", `subscription { logs(filter: {addresses: ["0x0000000000000000000000000000000000000dad"]}) { index account { address } } }`)
+	startGraphQLWS(t, conn, "txs", `subscription { pendingTransactions { nonce } }`)
+	startGraphQLWS(t, conn, "query", `{ block { number } }`)
+	expectGraphQLWS(t, conn, "query", gqlData, `{"data":{"block":{"number":1}}}`)
+	expectGraphQLWS(t, conn, "query", gqlComplete, ``)
+
+	// Import a block calling the logging contract.
+	blocks, _ := core.GenerateChain(genesis.Config, backend.BlockChain().CurrentBlock(), ethash.NewFaker(), backend.ChainDb(), 1, func(i int, gen *core.BlockGen) {
+		tx, _ := types.SignNewTx(key, signer, &types.LegacyTx{To: &dad, Gas: 100000, GasPrice: big.NewInt(params.InitialBaseFee)})
+		gen.AddTx(tx)
+	})
+	if _, err := backend.BlockChain().InsertChain(blocks); err != nil {
+		t.Fatalf("could not import block: %v", err)
+	}
+	want := map[string]string{
+		`{"data":{"newBlocks":{"number":2}}}`: "blocks",
+		`{"data":{"logs":{"index":0,"account":{"address":"0x0000000000000000000000000000000000000dad"}}}}`: "logs",
+		`{"data":{"logs":{"index":1,"account":{"address":"0x0000000000000000000000000000000000000dad"}}}}`: "logs",
+	}
+	for len(want) > 0 {
+		msg := readGraphQLWS(t, conn)
+		if id, ok := want[string(msg.Payload)]; !ok || id != msg.ID || msg.Type != gqlData {
+			t.Fatalf("unexpected message: %s %s %s", msg.ID, msg.Type, msg.Payload)
+		}
+		delete(want, string(msg.Payload))
+	}
+
+	// Add a transaction to the pool.
+	tx, _ := types.SignNewTx(key, signer, &types.LegacyTx{Nonce: 1, To: &dad, Gas: 100000, GasPrice: big.NewInt(2 * params.InitialBaseFee)})
+	if err := backend.TxPool().AddLocal(tx); err != nil {
+		t.Fatalf("could not add transaction: %v", err)
+	}
+	expectGraphQLWS(t, conn, "txs", gqlData, `{"data":{"pendingTransactions":{"nonce":"0x1"}}}`)
+
+	// Stopped subscriptions don't complete.
+	if err := conn.WriteJSON(&wsMessage{ID: "blocks", Type: gqlStop}); err != nil {
+		t.Fatal(err)
+	}
+	startGraphQLWS(t, conn, "invalid", `subscription { newBlocks { unknown } }`)
+	msg := readGraphQLWS(t, conn)
+	if msg.ID != "invalid" || msg.Type != gqlData || !strings.Contains(string(msg.Payload), `"errors"`) {
+		t.Fatalf("unexpected message: %s %s %s", msg.ID, msg.Type, msg.Payload)
+	}
+	expectGraphQLWS(t, conn, "invalid", gqlComplete, ``)
+}
+
+func TestGraphQLWebsocketOrigin(t *testing.T) {
+	stack := createNode(t)
+	defer stack.Close()
+	genesis := &core.Genesis{
+		Config:     params.AllEthashProtocolChanges,
+		GasLimit:   11500000,
+		Difficulty: big.NewInt(1048576),
+	}
+	newGQLService(t, stack, genesis, 1, func(i int, gen *core.BlockGen) {})
+	if err := stack.Start(); err != nil {
+		t.Fatalf("could not start node: %v", err)
+	}
+	dialer := websocket.Dialer{Subprotocols: []string{wsProtocol}}
+	url := strings.Replace(stack.HTTPEndpoint(), "http://", "ws://", 1) + "/graphql"
+	if _, _, err := dialer.Dial(url, http.Header{"Origin": {"http://evil.example"}}); err == nil {
+		t.Fatal("connection from foreign origin accepted")
+	}
+}
+
+func TestOperationType(t *testing.T) {
+	tests := []struct {
+		doc, name, want string
+	}{
+		{`{ block { number } }`, "", "query"},
+		{`query Q($n: Long = 1) { block(number: $n) { number } }`, "", "query"},
+		{`mutation { sendRawTransaction(data: "0x00") }`, "", "mutation"},
+		{`# subscription { newBlocks { number } }
+		subscription { newBlocks { number } }`, "", "subscription"},
+		{`fragment F on Block { number } subscription S { newBlocks { ...F } }`, "", "subscription"},
+		{`query A { chainID } subscription B { newBlocks { number } }`, "B", "subscription"},
+		{`query A { chainID } subscription B { newBlocks { number } }`, "A", "query"},
+		{`query A($s: String = "{ subscription") { chainID }`, "", "query"},
+		{`query A { chainID }`, "B", ""},
+	}
+	for i, test := range tests {
+		doc, err := parseDocument(test.doc)
+		if err != nil {
+			t.Fatalf("test %d: %v", i, err)
+		}
+		var have string
+		if op := doc.operation(test.name); op != nil {
+			have = op.typ
+		}
+		if have != test.want {
+			t.Errorf("test %d: wrong operation type: have %q, want %q", i, have, test.want)
+		}
+	}
+}
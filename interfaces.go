@@ -19,10 +19,13 @@ package confero
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
+	"math"
 	"math/big"
 
 	"github.com/confero-network/go-confero/common"
+	"github.com/confero-network/go-confero/common/hexutil"
 	"github.com/confero-network/go-confero/core/types"
 )
 
@@ -171,6 +174,74 @@ type FilterQuery struct {
 	// {{A}, {B}}         matches topic A in first position AND B in second position
 	// {{A, B}, {C, D}}   matches topic (A OR B) in first position AND (C OR D) in second position
 	Topics [][]common.Hash
+
+	// Limit and After page through large result sets of a one-off query. They are
+	// ignored by subscriptions, except for resumable log subscriptions which start
+	// after After. If Limit is non-zero, at most Limit logs are returned. If After
+	// is set, only logs following the log it refers to are returned, so the next
+	// page is requested using the position of the last log of the previous page.
+	Limit int
+	After *LogCursor
+
+	// Finality restricts subscriptions and filters to the logs of safe or finalized
+	// blocks, which are delivered as the safe or finalized block advances. It is
+	// FinalitySafe, FinalityFinalized or empty for the logs of all canonical blocks.
+	// One-off queries use the safe or finalized block as the default block range.
+	Finality string
+}
+
+// Finality levels of subscriptions and filters.
+const (
+	FinalitySafe      = "safe"
+	FinalityFinalized = "finalized"
+)
+
+// LogCursor is the position of a log in the chain, used to page through the results
+// of a log query. The block hash ensures that paging fails instead of skipping or
+// repeating logs if the chain is reorganized.
+type LogCursor struct {
+	BlockNumber uint64
+	BlockHash   common.Hash
+	Index       uint // index of the log in the block
+}
+
+// LogCursorBlockEnd is the log index of a cursor referring to the end of a block,
+// following all of its logs.
+const LogCursorBlockEnd = math.MaxUint32
+
+// CursorOf returns the position of a log.
+func CursorOf(log *types.Log) *LogCursor {
+	return &LogCursor{BlockNumber: log.BlockNumber, BlockHash: log.BlockHash, Index: log.Index}
+}
+
+type logCursorJSON struct {
+	BlockNumber hexutil.Uint64 `json:"blockNumber"`
+	BlockHash   common.Hash    `json:"blockHash"`
+	Index       hexutil.Uint   `json:"logIndex"`
+}
+
+// MarshalJSON encodes the cursor in the format of the "after" field of log queries.
+func (c LogCursor) MarshalJSON() ([]byte, error) {
+	return json.Marshal(logCursorJSON{hexutil.Uint64(c.BlockNumber), c.BlockHash, hexutil.Uint(c.Index)})
+}
+
+// UnmarshalJSON decodes a cursor in the format of the "after" field of log queries.
+func (c *LogCursor) UnmarshalJSON(input []byte) error {
+	var dec logCursorJSON
+	if err := json.Unmarshal(input, &dec); err != nil {
+		return err
+	}
+	*c = LogCursor{BlockNumber: uint64(dec.BlockNumber), BlockHash: dec.BlockHash, Index: uint(dec.Index)}
+	return nil
+}
+
+// LogEvent is a log delivered by a resumable log subscription. When the chain is
+// reorganized, the logs of the dropped blocks are delivered again with Removed
+// set. Resuming the subscription after the cursor delivers the events following
+// this one.
+type LogEvent struct {
+	Log    types.Log `json:"log"`
+	Cursor LogCursor `json:"cursor"`
 }
 
 // LogFilterer provides access to contract log events using a one-off query or continuous
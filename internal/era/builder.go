@@ -0,0 +1,187 @@
+// Copyright 2022 The go-confero Authors
+// This file is part of the go-confero library.
+//
+// The go-confero library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-confero library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-confero library. If not, see <http://www.gnu.org/licenses/>.
+
+package era
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+
+	"github.com/confero-network/go-confero/common"
+	"github.com/confero-network/go-confero/core/types"
+	"github.com/confero-network/go-confero/rlp"
+	"github.com/golang/snappy"
+)
+
+// Builder writes an era file.
+//
+// The file layout is
+//
+//	Version | block-tuple* | Accumulator | BlockIndex
+//	block-tuple := CompressedHeader | CompressedBody | CompressedReceipts | TotalDifficulty
+//
+// Headers, bodies and receipts are RLP encoded and snappy framed. The total
+// difficulty is a 32 byte little endian integer. The block index contains the
+// number of the first block, the offsets of the block tuples relative to the
+// index entry and the block count, each as 8 byte little endian integers.
+type Builder struct {
+	w        *Writer
+	startNum *uint64
+	indexes  []uint64
+	hashes   []common.Hash
+	tds      []*big.Int
+	written  int
+
+	buf    *bytes.Buffer
+	snappy *snappy.Writer
+}
+
+// NewBuilder returns a builder writing to w.
+func NewBuilder(w io.Writer) *Builder {
+	buf := bytes.NewBuffer(nil)
+	return &Builder{
+		w:      NewWriter(w),
+		buf:    buf,
+		snappy: snappy.NewBufferedWriter(buf),
+	}
+}
+
+// Add adds a block, its receipts and its total difficulty to the file.
+func (b *Builder) Add(block *types.Block, receipts types.Receipts, td *big.Int) error {
+	eh, err := rlp.EncodeToBytes(block.Header())
+	if err != nil {
+		return err
+	}
+	eb, err := rlp.EncodeToBytes(block.Body())
+	if err != nil {
+		return err
+	}
+	er, err := rlp.EncodeToBytes(receipts)
+	if err != nil {
+		return err
+	}
+	return b.AddRLP(eh, eb, er, block.NumberU64(), block.Hash(), td)
+}
+
+// AddRLP adds the RLP encoded header, body and receipts of a block to the file.
+func (b *Builder) AddRLP(header, body, receipts []byte, number uint64, hash common.Hash, td *big.Int) error {
+	if len(b.indexes) >= MaxEra1Size {
+		return fmt.Errorf("exceeds maximum batch size of %d", MaxEra1Size)
+	}
+	if b.written == 0 {
+		n, err := b.w.Write(TypeVersion, nil)
+		if err != nil {
+			return err
+		}
+		b.written += n
+	}
+	if b.startNum == nil {
+		b.startNum = &number
+	} else if want := *b.startNum + uint64(len(b.indexes)); number != want {
+		return fmt.Errorf("non-contiguous block: have %d, want %d", number, want)
+	}
+	b.indexes = append(b.indexes, uint64(b.written))
+	b.hashes = append(b.hashes, hash)
+	b.tds = append(b.tds, new(big.Int).Set(td))
+
+	for _, entry := range []struct {
+		typ  uint16
+		data []byte
+	}{
+		{TypeCompressedHeader, header},
+		{TypeCompressedBody, body},
+		{TypeCompressedReceipts, receipts},
+	} {
+		if err := b.snappyWrite(entry.typ, entry.data); err != nil {
+			return err
+		}
+	}
+	n, err := b.w.Write(TypeTotalDifficulty, bigToBytes32(td))
+	b.written += n
+	return err
+}
+
+// Finalize writes the accumulator and the block index and returns the
+// accumulator root.
+func (b *Builder) Finalize() (common.Hash, error) {
+	if b.startNum == nil {
+		return common.Hash{}, errors.New("finalize called on empty builder")
+	}
+	root, err := ComputeAccumulator(b.hashes, b.tds)
+	if err != nil {
+		return common.Hash{}, fmt.Errorf("error calculating accumulator root: %v", err)
+	}
+	n, err := b.w.Write(TypeAccumulator, root[:])
+	if err != nil {
+		return common.Hash{}, err
+	}
+	b.written += n
+
+	// Offsets are relative to the start of the index entry.
+	var (
+		base  = int64(b.written)
+		count = len(b.indexes)
+		index = make([]byte, 16+count*8)
+	)
+	binary.LittleEndian.PutUint64(index, *b.startNum)
+	for i, offset := range b.indexes {
+		binary.LittleEndian.PutUint64(index[8+i*8:], uint64(int64(offset)-base))
+	}
+	binary.LittleEndian.PutUint64(index[8+count*8:], uint64(count))
+
+	if n, err = b.w.Write(TypeBlockIndex, index); err != nil {
+		return common.Hash{}, err
+	}
+	b.written += n
+	return root, nil
+}
+
+// snappyWrite compresses the data and writes it as an entry of the given type.
+func (b *Builder) snappyWrite(typ uint16, data []byte) error {
+	b.buf.Reset()
+	b.snappy.Reset(b.buf)
+	if _, err := b.snappy.Write(data); err != nil {
+		return fmt.Errorf("error snappy encoding: %v", err)
+	}
+	if err := b.snappy.Flush(); err != nil {
+		return fmt.Errorf("error flushing snappy encoding: %v", err)
+	}
+	n, err := b.w.Write(typ, b.buf.Bytes())
+	b.written += n
+	return err
+}
+
+// bigToBytes32 encodes a big integer as 32 bytes, little endian.
+func bigToBytes32(n *big.Int) []byte {
+	b := n.FillBytes(make([]byte, 32))
+	for i, j := 0, len(b)-1; i < j; i, j = i+1, j-1 {
+		b[i], b[j] = b[j], b[i]
+	}
+	return b
+}
+
+// bytes32ToBig decodes a 32 byte little endian integer.
+func bytes32ToBig(b []byte) *big.Int {
+	rev := make([]byte, len(b))
+	for i := range b {
+		rev[len(b)-1-i] = b[i]
+	}
+	return new(big.Int).SetBytes(rev)
+}
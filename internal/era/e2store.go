@@ -0,0 +1,150 @@
+// Copyright 2022 The go-confero Authors
+// This file is part of the go-confero library.
+//
+// The go-confero library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-confero library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-confero library. If not, see <http://www.gnu.org/licenses/>.
+
+package era
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+const (
+	headerSize     = 8
+	valueSizeLimit = 1024 * 1024 * 50
+)
+
+// Entry is a variable-length encoded entry of an e2store file.
+//
+// An e2store file is a flat sequence of entries. Each entry has an 8 byte
+// header: the entry type (2 bytes, little endian), the value length (4 bytes,
+// little endian) and 2 reserved bytes which must be zero.
+type Entry struct {
+	Type  uint16
+	Value []byte
+}
+
+// Writer writes e2store entries.
+type Writer struct {
+	w io.Writer
+}
+
+// NewWriter creates a new e2store writer.
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{w: w}
+}
+
+// Write writes a single entry and returns the number of bytes written.
+func (w *Writer) Write(typ uint16, b []byte) (int, error) {
+	buf := make([]byte, headerSize)
+	binary.LittleEndian.PutUint16(buf, typ)
+	binary.LittleEndian.PutUint32(buf[2:], uint32(len(b)))
+
+	if n, err := w.w.Write(buf); err != nil {
+		return n, err
+	}
+	n, err := w.w.Write(b)
+	return headerSize + n, err
+}
+
+// Reader reads e2store entries.
+type Reader struct {
+	r      io.ReaderAt
+	offset int64
+}
+
+// NewReader creates a new e2store reader.
+func NewReader(r io.ReaderAt) *Reader {
+	return &Reader{r: r}
+}
+
+// Read reads the next entry. It returns io.EOF at the end of the file.
+func (r *Reader) Read() (*Entry, error) {
+	e, n, err := r.ReadAt(r.offset)
+	if err != nil {
+		return nil, err
+	}
+	r.offset += int64(n)
+	return e, nil
+}
+
+// ReadAt reads the entry at the given offset and returns the number of bytes
+// consumed.
+func (r *Reader) ReadAt(off int64) (*Entry, int, error) {
+	typ, length, err := r.ReadMetadataAt(off)
+	if err != nil {
+		return nil, 0, err
+	}
+	e := &Entry{Type: typ, Value: make([]byte, length)}
+	if length > 0 {
+		if _, err := r.r.ReadAt(e.Value, off+headerSize); err != nil {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return nil, 0, err
+		}
+	}
+	return e, headerSize + int(length), nil
+}
+
+// ReaderAt returns a reader of the value of the entry at the given offset.
+func (r *Reader) ReaderAt(expType uint16, off int64) (io.Reader, int, error) {
+	typ, length, err := r.ReadMetadataAt(off)
+	if err != nil {
+		return nil, 0, err
+	}
+	if typ != expType {
+		return nil, 0, fmt.Errorf("wrong entry type: have %#x, want %#x", typ, expType)
+	}
+	return io.NewSectionReader(r.r, off+headerSize, int64(length)), headerSize + int(length), nil
+}
+
+// ReadMetadataAt reads the header of the entry at the given offset.
+func (r *Reader) ReadMetadataAt(off int64) (typ uint16, length uint32, err error) {
+	b := make([]byte, headerSize)
+	if n, err := r.r.ReadAt(b, off); err != nil {
+		if err == io.EOF && n > 0 {
+			return 0, 0, io.ErrUnexpectedEOF
+		}
+		return 0, 0, err
+	}
+	typ = binary.LittleEndian.Uint16(b)
+	length = binary.LittleEndian.Uint32(b[2:])
+	if b[6] != 0 || b[7] != 0 {
+		return 0, 0, errors.New("reserved bytes are non-zero")
+	}
+	if length > valueSizeLimit {
+		return 0, 0, fmt.Errorf("entry too large: %d bytes", length)
+	}
+	return typ, length, nil
+}
+
+// Find returns the first entry of the given type.
+func (r *Reader) Find(want uint16) (*Entry, error) {
+	var off int64
+	for {
+		typ, length, err := r.ReadMetadataAt(off)
+		if err != nil {
+			return nil, err
+		}
+		if typ == want {
+			e, _, err := r.ReadAt(off)
+			return e, err
+		}
+		off += headerSize + int64(length)
+	}
+}
@@ -0,0 +1,390 @@
+// Copyright 2022 The go-confero Authors
+// This file is part of the go-confero library.
+//
+// The go-confero library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-confero library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-confero library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package era implements the era1 block history archive format.
+//
+// An era1 file contains a fixed-size epoch of consecutive blocks with their
+// receipts and total difficulties, an accumulator root committing to the block
+// hashes and total difficulties, and an index for random access to the blocks.
+package era
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/confero-network/go-confero/common"
+	"github.com/confero-network/go-confero/core/types"
+	"github.com/confero-network/go-confero/rlp"
+	"github.com/golang/snappy"
+)
+
+// Entry types of era1 files.
+const (
+	TypeVersion            uint16 = 0x3265
+	TypeCompressedHeader   uint16 = 0x03
+	TypeCompressedBody     uint16 = 0x04
+	TypeCompressedReceipts uint16 = 0x05
+	TypeTotalDifficulty    uint16 = 0x06
+	TypeAccumulator        uint16 = 0x07
+	TypeBlockIndex         uint16 = 0x3266
+
+	// MaxEra1Size is the number of blocks in an epoch.
+	MaxEra1Size = 8192
+)
+
+// Filename returns the name of an era1 file.
+func Filename(network string, epoch int, root common.Hash) string {
+	return fmt.Sprintf("%s-%05d-%s.era1", network, epoch, root.Hex()[2:10])
+}
+
+// ReadDir returns the era1 files of a network in the directory, ordered by
+// epoch. The epochs must be contiguous.
+func ReadDir(dir, network string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("error reading directory %s: %w", dir, err)
+	}
+	var (
+		next  = uint64(0)
+		files []string
+	)
+	for _, entry := range entries {
+		if filepath.Ext(entry.Name()) != ".era1" {
+			continue
+		}
+		parts := strings.Split(entry.Name(), "-")
+		if len(parts) != 3 || parts[0] != network {
+			// Invalid era1 filename, skip.
+			continue
+		}
+		epoch, err := strconv.ParseUint(parts[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("malformed era1 filename: %s", entry.Name())
+		}
+		if len(files) > 0 && epoch != next {
+			return nil, fmt.Errorf("missing epoch %d", next)
+		}
+		next = epoch + 1
+		files = append(files, entry.Name())
+	}
+	return files, nil
+}
+
+// ComputeAccumulator computes the accumulator root of an epoch, which is the
+// SSZ hash_tree_root of the list of header records:
+//
+//	HeaderRecord = Container[block_hash: Bytes32, total_difficulty: Uint256]
+//	Accumulator  = List[HeaderRecord, MaxEra1Size]
+func ComputeAccumulator(hashes []common.Hash, tds []*big.Int) (common.Hash, error) {
+	if len(hashes) != len(tds) {
+		return common.Hash{}, fmt.Errorf("must have equal number of hashes as td values: %d != %d", len(hashes), len(tds))
+	}
+	if len(hashes) > MaxEra1Size {
+		return common.Hash{}, fmt.Errorf("too many records: have %d, max %d", len(hashes), MaxEra1Size)
+	}
+	level := make([]common.Hash, len(hashes))
+	for i := range hashes {
+		level[i] = sha256Hash(hashes[i][:], bigToBytes32(tds[i]))
+	}
+	// Merkleize the records, padded with zero leaves to the list limit. The
+	// roots of the all zero subtrees are computed once per level.
+	var zero common.Hash
+	for size := MaxEra1Size; size > 1; size /= 2 {
+		if len(level)%2 == 1 {
+			level = append(level, zero)
+		}
+		for i := 0; i < len(level)/2; i++ {
+			level[i] = sha256Hash(level[2*i][:], level[2*i+1][:])
+		}
+		level = level[:len(level)/2]
+		zero = sha256Hash(zero[:], zero[:])
+	}
+	root := zero
+	if len(level) > 0 {
+		root = level[0]
+	}
+	// Mix in the length of the list.
+	return sha256Hash(root[:], bigToBytes32(big.NewInt(int64(len(hashes))))), nil
+}
+
+// sha256Hash returns the SHA-256 hash of the concatenated inputs.
+func sha256Hash(data ...[]byte) common.Hash {
+	h := sha256.New()
+	for _, b := range data {
+		h.Write(b)
+	}
+	return common.BytesToHash(h.Sum(nil))
+}
+
+// ReadAtSeekCloser is the file interface required by Era.
+type ReadAtSeekCloser interface {
+	io.ReaderAt
+	io.Seeker
+	io.Closer
+}
+
+// Era reads an era1 file.
+type Era struct {
+	f     ReadAtSeekCloser
+	s     *Reader
+	start uint64 // number of the first block
+	count uint64 // number of blocks
+	index int64  // offset of the block index entry
+}
+
+// Open opens an era1 file.
+func Open(filename string) (*Era, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	e, err := From(f)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return e, nil
+}
+
+// From reads an era1 file from f.
+func From(f ReadAtSeekCloser) (*Era, error) {
+	length, err := f.Seek(0, io.SeekEnd)
+	if err != nil {
+		return nil, err
+	}
+	if length < headerSize+16 {
+		return nil, fmt.Errorf("file too short: %d bytes", length)
+	}
+	e := &Era{f: f, s: NewReader(f)}
+	b := make([]byte, 8)
+	if _, err := f.ReadAt(b, length-8); err != nil {
+		return nil, err
+	}
+	e.count = binary.LittleEndian.Uint64(b)
+	if e.count == 0 || e.count > MaxEra1Size {
+		return nil, fmt.Errorf("invalid block count %d", e.count)
+	}
+	e.index = length - headerSize - 16 - int64(e.count)*8
+	if e.index < 0 {
+		return nil, fmt.Errorf("invalid block index")
+	}
+	typ, _, err := e.s.ReadMetadataAt(e.index)
+	if err != nil {
+		return nil, err
+	}
+	if typ != TypeBlockIndex {
+		return nil, fmt.Errorf("wrong index entry type: %#x", typ)
+	}
+	if _, err := f.ReadAt(b, e.index+headerSize); err != nil {
+		return nil, err
+	}
+	e.start = binary.LittleEndian.Uint64(b)
+	return e, nil
+}
+
+// Close closes the file.
+func (e *Era) Close() error {
+	return e.f.Close()
+}
+
+// Start returns the number of the first block in the file.
+func (e *Era) Start() uint64 {
+	return e.start
+}
+
+// Count returns the number of blocks in the file.
+func (e *Era) Count() uint64 {
+	return e.count
+}
+
+// GetBlockByNumber returns the block with the given number.
+func (e *Era) GetBlockByNumber(num uint64) (*types.Block, error) {
+	header, err := e.GetHeaderByNumber(num)
+	if err != nil {
+		return nil, err
+	}
+	var body types.Body
+	if err := e.readCompressed(num, 1, TypeCompressedBody, &body); err != nil {
+		return nil, err
+	}
+	return types.NewBlockWithHeader(header).WithBody(body.Transactions, body.Uncles), nil
+}
+
+// GetHeaderByNumber returns the header of the block with the given number.
+func (e *Era) GetHeaderByNumber(num uint64) (*types.Header, error) {
+	header := new(types.Header)
+	if err := e.readCompressed(num, 0, TypeCompressedHeader, header); err != nil {
+		return nil, err
+	}
+	return header, nil
+}
+
+// GetReceiptsByNumber returns the receipts of the block with the given number.
+func (e *Era) GetReceiptsByNumber(num uint64) (types.Receipts, error) {
+	var receipts types.Receipts
+	if err := e.readCompressed(num, 2, TypeCompressedReceipts, &receipts); err != nil {
+		return nil, err
+	}
+	return receipts, nil
+}
+
+// GetTD returns the total difficulty of the block with the given number.
+func (e *Era) GetTD(num uint64) (*big.Int, error) {
+	off, err := e.entryOffset(num, 3)
+	if err != nil {
+		return nil, err
+	}
+	entry, _, err := e.s.ReadAt(off)
+	if err != nil {
+		return nil, err
+	}
+	if entry.Type != TypeTotalDifficulty {
+		return nil, fmt.Errorf("wrong entry type: have %#x, want %#x", entry.Type, TypeTotalDifficulty)
+	}
+	return bytes32ToBig(entry.Value), nil
+}
+
+// GetRawHeaderByNumber returns the RLP encoded header of the block with the
+// given number.
+func (e *Era) GetRawHeaderByNumber(num uint64) ([]byte, error) {
+	return e.readRaw(num, 0, TypeCompressedHeader)
+}
+
+// GetRawBodyByNumber returns the RLP encoded body of the block with the given
+// number.
+func (e *Era) GetRawBodyByNumber(num uint64) ([]byte, error) {
+	return e.readRaw(num, 1, TypeCompressedBody)
+}
+
+// GetRawReceiptsByNumber returns the RLP encoded receipts of the block with the
+// given number.
+func (e *Era) GetRawReceiptsByNumber(num uint64) ([]byte, error) {
+	return e.readRaw(num, 2, TypeCompressedReceipts)
+}
+
+// Accumulator returns the accumulator root stored in the file.
+func (e *Era) Accumulator() (common.Hash, error) {
+	entry, err := e.s.Find(TypeAccumulator)
+	if err != nil {
+		return common.Hash{}, err
+	}
+	if len(entry.Value) != common.HashLength {
+		return common.Hash{}, fmt.Errorf("invalid accumulator length %d", len(entry.Value))
+	}
+	return common.BytesToHash(entry.Value), nil
+}
+
+// readCompressed decodes the snappy framed RLP entry at the given position of
+// the block tuple.
+func (e *Era) readCompressed(num uint64, pos int, typ uint16, val interface{}) error {
+	off, err := e.entryOffset(num, pos)
+	if err != nil {
+		return err
+	}
+	r, _, err := e.s.ReaderAt(typ, off)
+	if err != nil {
+		return err
+	}
+	return rlp.Decode(snappy.NewReader(r), val)
+}
+
+// readRaw returns the decompressed entry at the given position of the block
+// tuple.
+func (e *Era) readRaw(num uint64, pos int, typ uint16) ([]byte, error) {
+	off, err := e.entryOffset(num, pos)
+	if err != nil {
+		return nil, err
+	}
+	r, _, err := e.s.ReaderAt(typ, off)
+	if err != nil {
+		return nil, err
+	}
+	return io.ReadAll(snappy.NewReader(r))
+}
+
+// entryOffset returns the offset of the entry at the given position of the
+// block tuple of a block.
+func (e *Era) entryOffset(num uint64, pos int) (int64, error) {
+	if num < e.start || num >= e.start+e.count {
+		return 0, fmt.Errorf("block %d out of range [%d, %d)", num, e.start, e.start+e.count)
+	}
+	b := make([]byte, 8)
+	if _, err := e.f.ReadAt(b, e.index+headerSize+8+int64(num-e.start)*8); err != nil {
+		return 0, err
+	}
+	off := e.index + int64(binary.LittleEndian.Uint64(b))
+	for i := 0; i < pos; i++ {
+		_, length, err := e.s.ReadMetadataAt(off)
+		if err != nil {
+			return 0, err
+		}
+		off += headerSize + int64(length)
+	}
+	return off, nil
+}
+
+// Iterator iterates over the blocks of an era1 file.
+type Iterator struct {
+	e        *Era
+	next     uint64
+	block    *types.Block
+	receipts types.Receipts
+	td       *big.Int
+	err      error
+}
+
+// NewIterator returns an iterator over the blocks of e.
+func NewIterator(e *Era) *Iterator {
+	return &Iterator{e: e, next: e.start}
+}
+
+// Next moves the iterator to the next block. It returns false at the end of the
+// file or on error.
+func (it *Iterator) Next() bool {
+	if it.err != nil || it.next >= it.e.start+it.e.count {
+		return false
+	}
+	if it.block, it.err = it.e.GetBlockByNumber(it.next); it.err != nil {
+		return false
+	}
+	if it.receipts, it.err = it.e.GetReceiptsByNumber(it.next); it.err != nil {
+		return false
+	}
+	if it.td, it.err = it.e.GetTD(it.next); it.err != nil {
+		return false
+	}
+	it.next++
+	return true
+}
+
+// Block returns the current block.
+func (it *Iterator) Block() *types.Block { return it.block }
+
+// Receipts returns the receipts of the current block.
+func (it *Iterator) Receipts() types.Receipts { return it.receipts }
+
+// TotalDifficulty returns the total difficulty of the current block.
+func (it *Iterator) TotalDifficulty() *big.Int { return it.td }
+
+// Error returns the error which stopped the iteration, if any.
+func (it *Iterator) Error() error { return it.err }
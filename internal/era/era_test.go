@@ -0,0 +1,173 @@
+// Copyright 2022 The go-confero Authors
+// This file is part of the go-confero library.
+//
+// The go-confero library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-confero library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-confero library. If not, see <http://www.gnu.org/licenses/>.
+
+package era
+
+import (
+	"bytes"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/confero-network/go-confero/common"
+	"github.com/confero-network/go-confero/core/types"
+)
+
+func TestEra1Builder(t *testing.T) {
+	var (
+		file  = filepath.Join(t.TempDir(), Filename("test", 0, common.Hash{}))
+		start = uint64(100)
+		count = 128
+
+		blocks   []*types.Block
+		receipts []types.Receipts
+		tds      []*big.Int
+		hashes   []common.Hash
+	)
+	f, err := os.Create(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	builder := NewBuilder(f)
+	td := big.NewInt(1000)
+	for i := 0; i < count; i++ {
+		header := &types.Header{Number: new(big.Int).SetUint64(start + uint64(i)), Difficulty: big.NewInt(int64(i + 1)), Extra: []byte{byte(i)}}
+		block := types.NewBlockWithHeader(header)
+		receipt := &types.Receipt{Type: types.DynamicFeeTxType, Status: types.ReceiptStatusSuccessful, CumulativeGasUsed: uint64(i), Logs: []*types.Log{}}
+		td = new(big.Int).Add(td, header.Difficulty)
+
+		if err := builder.Add(block, types.Receipts{receipt}, td); err != nil {
+			t.Fatalf("error adding block %d: %v", i, err)
+		}
+		blocks = append(blocks, block)
+		receipts = append(receipts, types.Receipts{receipt})
+		tds = append(tds, td)
+		hashes = append(hashes, block.Hash())
+	}
+	root, err := builder.Finalize()
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+	if want, _ := ComputeAccumulator(hashes, tds); root != want {
+		t.Fatalf("wrong accumulator root: have %x, want %x", root, want)
+	}
+
+	e, err := Open(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer e.Close()
+	if e.Start() != start || e.Count() != uint64(count) {
+		t.Fatalf("wrong range: have [%d, %d], want [%d, %d]", e.Start(), e.Count(), start, count)
+	}
+	if stored, err := e.Accumulator(); err != nil || stored != root {
+		t.Fatalf("wrong stored accumulator: %x (err %v)", stored, err)
+	}
+	// Random access.
+	for _, i := range []int{count - 1, 0, count / 2} {
+		block, err := e.GetBlockByNumber(start + uint64(i))
+		if err != nil {
+			t.Fatalf("error reading block %d: %v", i, err)
+		}
+		if block.Hash() != hashes[i] {
+			t.Fatalf("block %d: wrong hash", i)
+		}
+	}
+	if _, err := e.GetBlockByNumber(start + uint64(count)); err == nil {
+		t.Fatal("no error for block out of range")
+	}
+	// Iteration.
+	it := NewIterator(e)
+	for i := 0; it.Next(); i++ {
+		if it.Block().Hash() != hashes[i] {
+			t.Fatalf("block %d: wrong hash", i)
+		}
+		if it.TotalDifficulty().Cmp(tds[i]) != 0 {
+			t.Fatalf("block %d: wrong td: have %v, want %v", i, it.TotalDifficulty(), tds[i])
+		}
+		have, want := it.Receipts(), receipts[i]
+		if len(have) != 1 || have[0].CumulativeGasUsed != want[0].CumulativeGasUsed || have[0].Type != want[0].Type {
+			t.Fatalf("block %d: wrong receipts", i)
+		}
+		if i == count-1 && it.Next() {
+			t.Fatal("iterator didn't stop at the end")
+		}
+	}
+	if err := it.Error(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestE2StoreReader(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	w.Write(0x1234, []byte("hello"))
+	w.Write(0x5678, nil)
+	w.Write(0x9abc, []byte("world"))
+
+	r := NewReader(bytes.NewReader(buf.Bytes()))
+	for _, want := range []Entry{{0x1234, []byte("hello")}, {0x5678, []byte{}}, {0x9abc, []byte("world")}} {
+		e, err := r.Read()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if e.Type != want.Type || !bytes.Equal(e.Value, want.Value) {
+			t.Fatalf("wrong entry: have %x %q, want %x %q", e.Type, e.Value, want.Type, want.Value)
+		}
+	}
+	if e, err := r.Find(0x9abc); err != nil || string(e.Value) != "world" {
+		t.Fatalf("find failed: %v", err)
+	}
+	// Reserved bytes must be zero.
+	b := buf.Bytes()
+	b[6] = 1
+	if _, err := NewReader(bytes.NewReader(b)).Read(); err == nil {
+		t.Fatal("no error for non-zero reserved bytes")
+	}
+}
+
+func TestComputeAccumulator(t *testing.T) {
+	// The expected roots are the SSZ hash_tree_root of the header records.
+	tests := []struct {
+		count int
+		root  common.Hash
+	}{
+		{0, common.HexToHash("4a8c3a07c8d23adc5bac61157555c3c784d53d9bc110c1370809bd23cd93777d")},
+		{3, common.HexToHash("537bac89bb60120cb20badc678432af05652df44b695f93715c36e69089f4a16")},
+	}
+	for _, test := range tests {
+		var (
+			hashes []common.Hash
+			tds    []*big.Int
+		)
+		for i := 0; i < test.count; i++ {
+			hashes = append(hashes, common.Hash{byte(i + 1)})
+			tds = append(tds, big.NewInt(int64(i+1)*1000))
+		}
+		root, err := ComputeAccumulator(hashes, tds)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if root != test.root {
+			t.Errorf("%d records: wrong root %x, want %x", test.count, root, test.root)
+		}
+	}
+	if _, err := ComputeAccumulator(make([]common.Hash, MaxEra1Size+1), make([]*big.Int, MaxEra1Size+1)); err == nil {
+		t.Fatal("no error for too many records")
+	}
+}
@@ -45,6 +45,7 @@ import (
 	"github.com/confero-network/go-confero/params"
 	"github.com/confero-network/go-confero/rlp"
 	"github.com/confero-network/go-confero/rpc"
+	"github.com/confero-network/go-confero/tracing"
 	"github.com/tyler-smith/go-bip39"
 )
 
@@ -633,18 +634,20 @@ func (s *BlockChainAPI) GetBalance(ctx context.Context, address common.Address,
 	if state == nil || err != nil {
 		return nil, err
 	}
+	traceState(ctx, state)
 	return (*hexutil.Big)(state.GetBalance(address)), state.Error()
 }
 
 // Result structs for GetProof
 type AccountResult struct {
-	Address      common.Address  `json:"address"`
-	AccountProof []string        `json:"accountProof"`
-	Balance      *hexutil.Big    `json:"balance"`
-	CodeHash     common.Hash     `json:"codeHash"`
-	Nonce        hexutil.Uint64  `json:"nonce"`
-	StorageHash  common.Hash     `json:"storageHash"`
-	StorageProof []StorageResult `json:"storageProof"`
+	Address           common.Address  `json:"address"`
+	AccountProof      []string        `json:"accountProof"`
+	Balance           *hexutil.Big    `json:"balance"`
+	CodeHash          common.Hash     `json:"codeHash"`
+	Nonce             hexutil.Uint64  `json:"nonce"`
+	StorageHash       common.Hash     `json:"storageHash"`
+	StorageProof      []StorageResult `json:"storageProof"`
+	StorageMultiProof []string        `json:"storageMultiProof,omitempty"`
 }
 
 type StorageResult struct {
@@ -653,12 +656,22 @@ type StorageResult struct {
 	Proof []string     `json:"proof"`
 }
 
+// ProofConfig holds the optional settings of GetProof.
+type ProofConfig struct {
+	// Compact requests a single multiproof for all the storage keys, returned in
+	// the storageMultiProof field, instead of one proof per storage key. Only the
+	// storage proofs are compacted, the account proof is a single path without
+	// duplicate nodes and is returned unchanged.
+	Compact bool `json:"compact"`
+}
+
 // GetProof returns the Merkle-proof for a given account and optionally some storage keys.
-func (s *BlockChainAPI) GetProof(ctx context.Context, address common.Address, storageKeys []string, blockNrOrHash rpc.BlockNumberOrHash) (*AccountResult, error) {
+func (s *BlockChainAPI) GetProof(ctx context.Context, address common.Address, storageKeys []string, blockNrOrHash rpc.BlockNumberOrHash, config *ProofConfig) (*AccountResult, error) {
 	state, _, err := s.b.StateAndHeaderByNumberOrHash(ctx, blockNrOrHash)
 	if state == nil || err != nil {
 		return nil, err
 	}
+	traceState(ctx, state)
 
 	storageTrie := state.StorageTrie(address)
 	storageHash := types.EmptyRootHash
@@ -674,8 +687,23 @@ func (s *BlockChainAPI) GetProof(ctx context.Context, address common.Address, st
 	}
 
 	// create the proof for the storageKeys
+	compact := config != nil && config.Compact
+	var multiProof []string
+	if compact && storageTrie != nil && len(storageKeys) > 0 {
+		keys := make([]common.Hash, len(storageKeys))
+		for i, key := range storageKeys {
+			keys[i] = common.HexToHash(key)
+		}
+		proof, storageError := state.GetStorageMultiProof(address, keys)
+		if storageError != nil {
+			return nil, storageError
+		}
+		multiProof = toHexSlice(proof)
+	}
 	for i, key := range storageKeys {
-		if storageTrie != nil {
+		if compact {
+			storageProof[i] = StorageResult{key, (*hexutil.Big)(state.GetState(address, common.HexToHash(key)).Big()), []string{}}
+		} else if storageTrie != nil {
 			proof, storageError := state.GetStorageProof(address, common.HexToHash(key))
 			if storageError != nil {
 				return nil, storageError
@@ -693,13 +721,14 @@ func (s *BlockChainAPI) GetProof(ctx context.Context, address common.Address, st
 	}
 
 	return &AccountResult{
-		Address:      address,
-		AccountProof: toHexSlice(accountProof),
-		Balance:      (*hexutil.Big)(state.GetBalance(address)),
-		CodeHash:     codeHash,
-		Nonce:        hexutil.Uint64(state.GetNonce(address)),
-		StorageHash:  storageHash,
-		StorageProof: storageProof,
+		Address:           address,
+		AccountProof:      toHexSlice(accountProof),
+		Balance:           (*hexutil.Big)(state.GetBalance(address)),
+		CodeHash:          codeHash,
+		Nonce:             hexutil.Uint64(state.GetNonce(address)),
+		StorageHash:       storageHash,
+		StorageProof:      storageProof,
+		StorageMultiProof: multiProof,
 	}, state.Error()
 }
 
@@ -814,6 +843,7 @@ func (s *BlockChainAPI) GetCode(ctx context.Context, address common.Address, blo
 	if state == nil || err != nil {
 		return nil, err
 	}
+	traceState(ctx, state)
 	code := state.GetCode(address)
 	return code, state.Error()
 }
@@ -826,6 +856,7 @@ func (s *BlockChainAPI) GetStorageAt(ctx context.Context, address common.Address
 	if state == nil || err != nil {
 		return nil, err
 	}
+	traceState(ctx, state)
 	res := state.GetState(address, common.HexToHash(key))
 	return res[:], state.Error()
 }
@@ -924,13 +955,23 @@ func (diff *BlockOverrides) Apply(blockCtx *vm.BlockContext) {
 func DoCall(ctx context.Context, b Backend, args TransactionArgs, blockNrOrHash rpc.BlockNumberOrHash, overrides *StateOverride, timeout time.Duration, globalGasCap uint64) (*core.ExecutionResult, error) {
 	defer func(start time.Time) { log.Debug("Executing EVM call finished", "runtime", time.Since(start)) }(time.Now())
 
+	ctx, span := tracing.StartSpan(ctx, "ethapi.DoCall")
+	defer span.End()
+
 	state, header, err := b.StateAndHeaderByNumberOrHash(ctx, blockNrOrHash)
 	if state == nil || err != nil {
+		span.SetError(err)
 		return nil, err
 	}
+	if span != nil {
+		span.SetAttributes(tracing.Big("block.number", header.Number), tracing.Stringer("block.hash", header.Hash()))
+	}
+	traceState(ctx, state)
 	if err := overrides.Apply(state); err != nil {
+		span.SetError(err)
 		return nil, err
 	}
+	traceCtx := ctx
 	// Setup context so it may be cancelled the call has completed
 	// or, in case of unmetered gas, setup a context with a timeout.
 	var cancel context.CancelFunc
@@ -946,10 +987,14 @@ func DoCall(ctx context.Context, b Backend, args TransactionArgs, blockNrOrHash
 	// Get a new instance of the EVM.
 	msg, err := args.ToMessage(globalGasCap, header.BaseFee)
 	if err != nil {
+		span.SetError(err)
 		return nil, err
 	}
+	span.SetAttributes(tracing.Uint64("call.gas", msg.Gas()))
+
 	evm, vmError, err := b.GetEVM(ctx, msg, state, header, &vm.Config{NoBaseFee: true})
 	if err != nil {
+		span.SetError(err)
 		return nil, err
 	}
 	// Wait for the context to be done and cancel the evm. Even if the
@@ -959,9 +1004,20 @@ func DoCall(ctx context.Context, b Backend, args TransactionArgs, blockNrOrHash
 		evm.Cancel()
 	}()
 
-	// Execute the message.
+	// Execute the message, tracing the state reads of the execution under its
+	// own span.
+	evmCtx, evmSpan := tracing.StartSpan(traceCtx, "evm.execute")
+	traceState(evmCtx, state)
 	gp := new(core.GasPool).AddGas(math.MaxUint64)
 	result, err := core.ApplyMessage(evm, msg, gp)
+	traceState(traceCtx, state)
+	if result != nil {
+		evmSpan.SetAttributes(tracing.Uint64("call.gas_used", result.UsedGas), tracing.Bool("call.failed", result.Failed()))
+		evmSpan.SetError(result.Err)
+	}
+	evmSpan.SetError(err)
+	evmSpan.End()
+
 	if err := vmError(); err != nil {
 		return nil, err
 	}
@@ -976,6 +1032,14 @@ func DoCall(ctx context.Context, b Backend, args TransactionArgs, blockNrOrHash
 	return result, nil
 }
 
+// traceState traces the reads of the state under the span carried by the
+// context, if any.
+func traceState(ctx context.Context, state *state.StateDB) {
+	if tracing.SpanFromContext(ctx) != nil {
+		state.SetTraceContext(ctx)
+	}
+}
+
 func newRevertError(result *core.ExecutionResult) *revertError {
 	reason, errUnpack := abi.UnpackRevert(result.Revert())
 	err := errors.New("execution reverted")
@@ -1529,6 +1593,7 @@ func (s *TransactionAPI) GetTransactionCount(ctx context.Context, address common
 	if state == nil || err != nil {
 		return nil, err
 	}
+	traceState(ctx, state)
 	nonce := state.GetNonce(address)
 	return (*hexutil.Uint64)(&nonce), state.Error()
 }
@@ -189,6 +189,11 @@ web3._extend({
 			name: 'stopWS',
 			call: 'admin_stopWS'
 		}),
+		new web3._extend.Method({
+			name: 'setPeerPolicy',
+			call: 'admin_setPeerPolicy',
+			params: 1
+		}),
 	],
 	properties: [
 		new web3._extend.Property({
@@ -203,6 +208,10 @@ web3._extend({
 			name: 'datadir',
 			getter: 'admin_datadir'
 		}),
+		new web3._extend.Property({
+			name: 'peerPolicy',
+			getter: 'admin_peerPolicy'
+		}),
 	]
 });
 `
@@ -485,6 +494,18 @@ web3._extend({
 			call: 'debug_dbAncients',
 			params: 0
 		}),
+		new web3._extend.Method({
+			name: 'executionWitness',
+			call: 'debug_executionWitness',
+			params: 1,
+			inputFormatter: [web3._extend.formatters.inputBlockNumberFormatter],
+		}),
+		new web3._extend.Method({
+			name: 'stateDiff',
+			call: 'debug_stateDiff',
+			params: 1,
+			inputFormatter: [web3._extend.formatters.inputBlockNumberFormatter],
+		}),
 	],
 	properties: []
 });
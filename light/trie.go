@@ -187,6 +187,17 @@ func (t *odrTrie) Prove(key []byte, fromLevel uint, proofDb ethdb.KeyValueWriter
 	return errors.New("not implemented, needs client/server interface split")
 }
 
+// EnableWitness is a no-op, witnesses can't be collected over ODR.
+func (t *odrTrie) EnableWitness() {}
+
+// SetTraceContext is a no-op, node retrievals over ODR aren't traced.
+func (t *odrTrie) SetTraceContext(ctx context.Context) {}
+
+// Witness returns nil, witnesses can't be collected over ODR.
+func (t *odrTrie) Witness() map[string]struct{} {
+	return nil
+}
+
 // do tries and retries to execute a function until it returns with no error or
 // an error type other than MissingNodeError
 func (t *odrTrie) do(key []byte, fn func() error) error {
@@ -307,6 +307,29 @@ func (api *adminAPI) NodeInfo() (*p2p.NodeInfo, error) {
 	return server.NodeInfo(), nil
 }
 
+// PeerPolicy retrieves the peer admission policy in effect.
+func (api *adminAPI) PeerPolicy() (*p2p.PeerPolicy, error) {
+	server := api.node.Server()
+	if server == nil {
+		return nil, ErrNodeStopped
+	}
+	policy := server.Policy()
+	return &policy, nil
+}
+
+// SetPeerPolicy replaces the peer admission policy. The policy applies to new
+// connections, existing peers stay connected.
+func (api *adminAPI) SetPeerPolicy(policy p2p.PeerPolicy) (bool, error) {
+	server := api.node.Server()
+	if server == nil {
+		return false, ErrNodeStopped
+	}
+	if err := server.SetPolicy(policy); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
 // Datadir retrieves the current data directory the node is using.
 func (api *adminAPI) Datadir() string {
 	return api.node.DataDir()
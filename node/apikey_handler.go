@@ -0,0 +1,248 @@
+// Copyright 2022 The go-confero Authors
+// This file is part of the go-confero library.
+//
+// The go-confero library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-confero library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-confero library. If not, see <http://www.gnu.org/licenses/>.
+
+package node
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/confero-network/go-confero/metrics"
+	"github.com/confero-network/go-confero/rpc"
+)
+
+const (
+	// apiKeyHeader is the HTTP header carrying the API key.
+	apiKeyHeader = "X-API-Key"
+
+	// apiKeyQuery is the URL query parameter carrying the API key, for clients
+	// which can't set headers, like browsers opening a websocket.
+	apiKeyQuery = "apikey"
+)
+
+// Error codes of calls rejected because of their API key. Calls exceeding the
+// rate limit of their key fail with the rate limit error of the rpc package.
+const (
+	errcodeAPIKeyDenied      = -32004
+	errcodeAPIKeyRateLimited = -32005
+)
+
+var (
+	errNoAPIKeys = errors.New("API keys file defines no keys")
+
+	errAPIKeyExpired = &apiKeyError{errcodeAPIKeyDenied, "API key expired"}
+)
+
+// apiKeyError is the JSON-RPC error returned for calls rejected because of
+// their API key.
+type apiKeyError struct {
+	code    int
+	message string
+}
+
+func (e *apiKeyError) Error() string  { return e.message }
+func (e *apiKeyError) ErrorCode() int { return e.code }
+
+// apiKeyConfig is an entry of the API keys file.
+type apiKeyConfig struct {
+	Name      string     `json:"name"`                // used in logs and metrics
+	Key       string     `json:"key"`                 // secret sent by the client
+	Methods   []string   `json:"methods"`             // allowed methods, "eth_*" allows a namespace, "*" all methods
+	RateLimit float64    `json:"rateLimit,omitempty"` // allowed calls per second, zero for no limit
+	Burst     int        `json:"burst,omitempty"`     // maximum burst of calls, defaults to the rate limit
+	Expiry    *time.Time `json:"expiry,omitempty"`    // time the key expires, nil if it doesn't
+}
+
+// apiKey is a loaded API key.
+type apiKey struct {
+	name    string
+	methods map[string]bool
+	limiter *rpc.RateLimiter // budget of the key by its name, nil if calls are not limited
+	expiry  time.Time        // zero if the key doesn't expire
+
+	requestMeter     metrics.Meter
+	deniedMeter      metrics.Meter
+	rateLimitedMeter metrics.Meter
+}
+
+// apiKeys is a set of API keys, indexed by the hash of the secret.
+type apiKeys struct {
+	keys map[[32]byte]*apiKey
+}
+
+// loadAPIKeys reads the API keys file. The file contains a JSON list of keys.
+func loadAPIKeys(file string) (*apiKeys, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	var configs []apiKeyConfig
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&configs); err != nil {
+		return nil, fmt.Errorf("invalid API keys file %s: %v", file, err)
+	}
+	if len(configs) == 0 {
+		return nil, errNoAPIKeys
+	}
+	return newAPIKeys(configs)
+}
+
+// newAPIKeys creates a set of API keys from their configuration.
+func newAPIKeys(configs []apiKeyConfig) (*apiKeys, error) {
+	var (
+		keys  = &apiKeys{keys: make(map[[32]byte]*apiKey)}
+		names = make(map[string]bool)
+	)
+	for _, config := range configs {
+		switch {
+		case config.Name == "" || strings.ContainsAny(config.Name, "/ "):
+			return nil, fmt.Errorf("invalid API key name %q", config.Name)
+		case names[config.Name]:
+			return nil, fmt.Errorf("duplicate API key name %q", config.Name)
+		case config.Key == "":
+			return nil, fmt.Errorf("API key %q has no key", config.Name)
+		case len(config.Methods) == 0:
+			return nil, fmt.Errorf("API key %q allows no methods", config.Name)
+		case config.RateLimit < 0 || config.Burst < 0:
+			return nil, fmt.Errorf("API key %q has a negative rate limit", config.Name)
+		}
+		hash := sha256.Sum256([]byte(config.Key))
+		if _, ok := keys.keys[hash]; ok {
+			return nil, fmt.Errorf("API key %q reuses the key of another entry", config.Name)
+		}
+		names[config.Name] = true
+
+		prefix := "rpc/apikeys/" + config.Name
+		key := &apiKey{
+			name:             config.Name,
+			methods:          make(map[string]bool),
+			requestMeter:     metrics.GetOrRegisterMeter(prefix+"/requests", nil),
+			deniedMeter:      metrics.GetOrRegisterMeter(prefix+"/denied", nil),
+			rateLimitedMeter: metrics.GetOrRegisterMeter(prefix+"/ratelimited", nil),
+		}
+		for _, method := range config.Methods {
+			key.methods[method] = true
+		}
+		if config.RateLimit > 0 {
+			key.limiter = rpc.NewRateLimiter(rpc.RateLimitConfig{Rate: config.RateLimit, Burst: config.Burst})
+		}
+		if config.Expiry != nil {
+			key.expiry = *config.Expiry
+		}
+		keys.keys[hash] = key
+	}
+	return keys, nil
+}
+
+// lookup returns the key with the given secret, or nil if there is none.
+func (keys *apiKeys) lookup(secret string) *apiKey {
+	return keys.keys[sha256.Sum256([]byte(secret))]
+}
+
+// expired reports whether the key is expired at the given time.
+func (k *apiKey) expired(now time.Time) bool {
+	return !k.expiry.IsZero() && !now.Before(k.expiry)
+}
+
+// allowed reports whether the key allows calling the given method. Allowing
+// a subscription also allows canceling it.
+func (k *apiKey) allowed(method string) bool {
+	if k.methods["*"] || k.methods[method] {
+		return true
+	}
+	namespace, name, ok := cutMethod(method)
+	if !ok {
+		return false
+	}
+	if k.methods[namespace+"_*"] {
+		return true
+	}
+	return name == "unsubscribe" && k.methods[namespace+"_subscribe"]
+}
+
+// cutMethod splits a method name into its namespace and name.
+func cutMethod(method string) (string, string, bool) {
+	i := strings.Index(method, "_")
+	if i < 0 {
+		return "", "", false
+	}
+	return method[:i], method[i+1:], true
+}
+
+// filter checks a call made with the key. It is installed as the call filter of
+// requests and connections authenticated by the key.
+func (k *apiKey) filter(ctx context.Context, method string) error {
+	k.requestMeter.Mark(1)
+	switch {
+	case k.expired(time.Now()):
+		k.deniedMeter.Mark(1)
+		return errAPIKeyExpired
+	case !k.allowed(method):
+		k.deniedMeter.Mark(1)
+		return &apiKeyError{errcodeAPIKeyDenied, fmt.Sprintf("method %s not allowed for API key", method)}
+	}
+	if k.limiter != nil {
+		if err := k.limiter.Allow(k.name, method); err != nil {
+			k.rateLimitedMeter.Mark(1)
+			return err
+		}
+	}
+	return nil
+}
+
+type apiKeyHandler struct {
+	keys *apiKeys
+	next http.Handler
+}
+
+// newAPIKeyHandler creates a http.Handler which requires an API key and checks
+// all calls of the request against it.
+func newAPIKeyHandler(keys *apiKeys, next http.Handler) http.Handler {
+	return &apiKeyHandler{keys: keys, next: next}
+}
+
+// ServeHTTP implements http.Handler
+func (handler *apiKeyHandler) ServeHTTP(out http.ResponseWriter, r *http.Request) {
+	secret := r.Header.Get(apiKeyHeader)
+	if secret == "" {
+		secret = r.URL.Query().Get(apiKeyQuery)
+	}
+	if secret == "" {
+		http.Error(out, "missing API key", http.StatusUnauthorized)
+		return
+	}
+	key := handler.keys.lookup(secret)
+	switch {
+	case key == nil:
+		http.Error(out, "invalid API key", http.StatusUnauthorized)
+	case key.expired(time.Now()):
+		key.deniedMeter.Mark(1)
+		http.Error(out, errAPIKeyExpired.Error(), http.StatusUnauthorized)
+	default:
+		ctx := rpc.WithCallFilter(r.Context(), key.filter)
+		ctx = rpc.WithRateLimitKey(ctx, key.name)
+		handler.next.ServeHTTP(out, r.WithContext(ctx))
+	}
+}
@@ -24,6 +24,7 @@ import (
 	"runtime"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/confero-network/go-confero/common"
 	"github.com/confero-network/go-confero/crypto"
@@ -191,6 +192,18 @@ type Config struct {
 	// Requests using ip address directly are not affected
 	GraphQLVirtualHosts []string `toml:",omitempty"`
 
+	// GraphQLMaxCost is the maximum estimated cost of a GraphQL query. Queries
+	// exceeding it are rejected without running them. Zero means no limit.
+	GraphQLMaxCost uint64 `toml:",omitempty"`
+
+	// GraphQLMaxDepth is the maximum nesting depth of a GraphQL query. Zero
+	// means no limit.
+	GraphQLMaxDepth int `toml:",omitempty"`
+
+	// GraphQLTimeout is the maximum execution time of a GraphQL query. Zero
+	// means no limit.
+	GraphQLTimeout time.Duration `toml:",omitempty"`
+
 	// Logger is a custom logger to use with the p2p.Server.
 	Logger log.Logger `toml:",omitempty"`
 
@@ -203,6 +216,17 @@ type Config struct {
 
 	// JWTSecret is the hex-encoded jwt secret.
 	JWTSecret string `toml:",omitempty"`
+
+	// APIKeysFile is the path of a JSON file listing the API keys accepted by the
+	// HTTP and WebSocket RPC endpoints. Each key restricts the methods it may call
+	// and optionally has a rate limit and an expiry. If set, requests without a
+	// valid key are rejected. The authenticated endpoints are not affected.
+	APIKeysFile string `toml:",omitempty"`
+
+	// RPCRateLimits are the per-client call limits of the HTTP and WebSocket RPC
+	// endpoints. Clients are identified by their API key if they use one, or by
+	// their IP address.
+	RPCRateLimits rpc.RateLimitConfig
 }
 
 // IPCEndpoint resolves an IPC endpoint based on a configured value, taking into
@@ -21,6 +21,7 @@ import (
 	"os/user"
 	"path/filepath"
 	"runtime"
+	"time"
 
 	"github.com/confero-network/go-confero/p2p"
 	"github.com/confero-network/go-confero/p2p/nat"
@@ -36,6 +37,10 @@ const (
 	DefaultGraphQLPort = 8547        // Default TCP port for the GraphQL server
 	DefaultAuthHost    = "localhost" // Default host interface for the authenticated apis
 	DefaultAuthPort    = 8551        // Default port for the authenticated apis
+
+	DefaultGraphQLMaxCost  = 1000000          // Default maximum estimated cost of a GraphQL query
+	DefaultGraphQLMaxDepth = 20               // Default maximum nesting depth of a GraphQL query
+	DefaultGraphQLTimeout  = 30 * time.Second // Default maximum execution time of a GraphQL query
 )
 
 var (
@@ -59,6 +64,9 @@ var DefaultConfig = Config{
 	WSPort:              DefaultWSPort,
 	WSModules:           []string{"net", "web3"},
 	GraphQLVirtualHosts: []string{"localhost"},
+	GraphQLMaxCost:      DefaultGraphQLMaxCost,
+	GraphQLMaxDepth:     DefaultGraphQLMaxDepth,
+	GraphQLTimeout:      DefaultGraphQLTimeout,
 	P2P: p2p.Config{
 		ListenAddr: ":30303",
 		MaxPeers:   50,
@@ -33,6 +33,8 @@ import (
 	"github.com/confero-network/go-confero/common/hexutil"
 	"github.com/confero-network/go-confero/core/rawdb"
 	"github.com/confero-network/go-confero/ethdb"
+	"github.com/confero-network/go-confero/ethdb/memorydb"
+	"github.com/confero-network/go-confero/ethdb/remoteancient"
 	"github.com/confero-network/go-confero/event"
 	"github.com/confero-network/go-confero/log"
 	"github.com/confero-network/go-confero/p2p"
@@ -394,7 +396,15 @@ func (n *Node) startRPC() error {
 	var (
 		servers   []*httpServer
 		open, all = n.GetAPIs()
+		keys      *apiKeys
 	)
+	if n.config.APIKeysFile != "" {
+		var err error
+		if keys, err = loadAPIKeys(n.config.APIKeysFile); err != nil {
+			return err
+		}
+		n.log.Info("Loaded RPC API keys", "path", n.config.APIKeysFile, "keys", len(keys.keys))
+	}
 
 	initHttp := func(server *httpServer, apis []rpc.API, port int) error {
 		if err := server.setListenAddr(n.config.HTTPHost, port); err != nil {
@@ -405,6 +415,8 @@ func (n *Node) startRPC() error {
 			Vhosts:             n.config.HTTPVirtualHosts,
 			Modules:            n.config.HTTPModules,
 			prefix:             n.config.HTTPPathPrefix,
+			apiKeys:            keys,
+			rateLimits:         n.config.RPCRateLimits,
 		}); err != nil {
 			return err
 		}
@@ -418,9 +430,11 @@ func (n *Node) startRPC() error {
 			return err
 		}
 		if err := server.enableWS(n.rpcAPIs, wsConfig{
-			Modules: n.config.WSModules,
-			Origins: n.config.WSOrigins,
-			prefix:  n.config.WSPathPrefix,
+			Modules:    n.config.WSModules,
+			Origins:    n.config.WSOrigins,
+			prefix:     n.config.WSPathPrefix,
+			apiKeys:    keys,
+			rateLimits: n.config.RPCRateLimits,
 		}); err != nil {
 			return err
 		}
@@ -597,6 +611,37 @@ func (n *Node) RegisterHandler(name, path string, handler http.Handler) {
 	n.http.handlerNames[path] = name
 }
 
+// RegisterProtoServer mounts a Protobuf-over-HTTP server on the given path of the
+// canonical HTTP server. The calls are subject to the API keys and rate limits of
+// the HTTP-RPC server, and the running ones are canceled when the HTTP server
+// stops.
+func (n *Node) RegisterProtoServer(name, path string, srv *rpc.ProtoServer, cors, vhosts []string) {
+	handler := &protoHandler{server: srv, cors: cors, vhosts: vhosts}
+	n.RegisterHandler(name, path, handler)
+
+	n.lock.Lock()
+	defer n.lock.Unlock()
+	n.http.protoHandlers = append(n.http.protoHandlers, handler)
+}
+
+// WithAPIKeys wraps a handler serving API calls outside of the RPC server, such
+// that its requests require an API key of the HTTP-RPC server if API keys are
+// configured. The call filter of the key is attached to the request context,
+// the handler must check its operations against it by the names of the
+// equivalent RPC methods, see rpc.CallFilterFromContext. The wrapped handler
+// only serves requests while JSON-RPC over HTTP is enabled.
+func (n *Node) WithAPIKeys(handler http.Handler) http.Handler {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+
+	if n.state != initializingState {
+		panic("can't wrap HTTP handler on running/stopped node")
+	}
+	keyed := &keyedHandler{next: handler}
+	n.http.keyedHandlers = append(n.http.keyedHandlers, keyed)
+	return keyed
+}
+
 // Attach creates an RPC client attached to an in-process API handler.
 func (n *Node) Attach() (*rpc.Client, error) {
 	return rpc.DialInProc(n.inprocHandler), nil
@@ -724,6 +769,66 @@ func (n *Node) OpenDatabaseWithFreezer(name string, cache, handles int, ancient
 	return db, err
 }
 
+// OpenDatabaseWithRemoteAncients opens an existing database with the given name
+// (or creates one if no previous can be found) from within the node's data
+// directory, also attaching a chain freezer to it, which is layered on top of
+// the read-only remote ancient store served at the given endpoint. The chain
+// segment held by the remote store is read from it, newer ancient chain data is
+// moved into the local freezer. If the node is an ephemeral one, a memory
+// database is used for the key-value data and nothing is frozen.
+func (n *Node) OpenDatabaseWithRemoteAncients(name string, cache, handles int, ancient string, endpoint string, namespace string, readonly bool) (ethdb.Database, error) {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+	if n.state == closedState {
+		return nil, ErrNodeStopped
+	}
+	ancients, err := remoteancient.Dial(endpoint)
+	if err != nil {
+		return nil, err
+	}
+	var db ethdb.Database
+	if n.config.DataDir == "" {
+		db, err = rawdb.NewDatabaseWithAncientStore(memorydb.New(), ancients)
+	} else {
+		db, err = rawdb.NewLevelDBDatabaseWithLayeredFreezer(n.ResolvePath(name), cache, handles, ancients, n.ResolveAncient(name, ancient), namespace, readonly)
+	}
+	if err != nil {
+		ancients.Close()
+		return nil, err
+	}
+	return n.wrapDatabase(db), nil
+}
+
+// OpenDatabaseWithEraAncients opens an existing database with the given name
+// (or creates one if no previous can be found) from within the node's data
+// directory, also attaching a chain freezer to it, which is layered on top of
+// the era1 history files of the given directory. The chain segment held by the
+// history files is read from them, newer ancient chain data is moved into the
+// local freezer. If the node is an ephemeral one, a memory database is used for
+// the key-value data and nothing is frozen.
+func (n *Node) OpenDatabaseWithEraAncients(name string, cache, handles int, ancient string, dir string, namespace string, readonly bool) (ethdb.Database, error) {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+	if n.state == closedState {
+		return nil, ErrNodeStopped
+	}
+	ancients, err := rawdb.NewEraStore(n.ResolvePath(dir))
+	if err != nil {
+		return nil, err
+	}
+	var db ethdb.Database
+	if n.config.DataDir == "" {
+		db, err = rawdb.NewDatabaseWithAncientStore(memorydb.New(), ancients)
+	} else {
+		db, err = rawdb.NewLevelDBDatabaseWithLayeredFreezer(n.ResolvePath(name), cache, handles, ancients, n.ResolveAncient(name, ancient), namespace, readonly)
+	}
+	if err != nil {
+		ancients.Close()
+		return nil, err
+	}
+	return n.wrapDatabase(db), nil
+}
+
 // ResolvePath returns the absolute path of a resource in the instance directory.
 func (n *Node) ResolvePath(x string) string {
 	return n.config.ResolvePath(x)
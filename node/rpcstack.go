@@ -39,16 +39,20 @@ type httpConfig struct {
 	Modules            []string
 	CorsAllowedOrigins []string
 	Vhosts             []string
-	prefix             string // path prefix on which to mount http handler
-	jwtSecret          []byte // optional JWT secret
+	prefix             string   // path prefix on which to mount http handler
+	jwtSecret          []byte   // optional JWT secret
+	apiKeys            *apiKeys // optional API keys
+	rateLimits         rpc.RateLimitConfig
 }
 
 // wsConfig is the JSON-RPC/Websocket configuration
 type wsConfig struct {
-	Origins   []string
-	Modules   []string
-	prefix    string // path prefix on which to mount ws handler
-	jwtSecret []byte // optional JWT secret
+	Origins    []string
+	Modules    []string
+	prefix     string   // path prefix on which to mount ws handler
+	jwtSecret  []byte   // optional JWT secret
+	apiKeys    *apiKeys // optional API keys
+	rateLimits rpc.RateLimitConfig
 }
 
 type rpcHandler struct {
@@ -79,7 +83,9 @@ type httpServer struct {
 	host     string
 	port     int
 
-	handlerNames map[string]string
+	handlerNames  map[string]string
+	protoHandlers []*protoHandler // mounted Protobuf-over-HTTP servers
+	keyedHandlers []*keyedHandler // mounted handlers serving API calls outside of the RPC server
 }
 
 const (
@@ -193,8 +199,13 @@ func (h *httpServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	if ws != nil && isWebsocket(r) {
 		if checkPath(r, h.wsConfig.prefix) {
 			ws.ServeHTTP(w, r)
+			return
+		}
+		// Websocket requests outside of the prefix may be meant for a
+		// handler registered in the mux.
+		if _, pattern := h.mux.Handler(r); pattern == "" {
+			return
 		}
-		return
 	}
 	// if http-rpc is enabled, try to serve request
 	rpc := h.httpHandler.Load().(*rpcHandler)
@@ -267,6 +278,10 @@ func (h *httpServer) doStop() {
 		h.wsHandler.Store((*rpcHandler)(nil))
 		wsHandler.server.Stop()
 	}
+	// Streams of the Protobuf-over-HTTP servers would keep the shutdown waiting.
+	for _, proto := range h.protoHandlers {
+		proto.server.Stop()
+	}
 	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
 	defer cancel()
 	err := h.server.Shutdown(ctx)
@@ -293,14 +308,25 @@ func (h *httpServer) enableRPC(apis []rpc.API, config httpConfig) error {
 
 	// Create RPC server and handler.
 	srv := rpc.NewServer()
+	srv.SetRateLimits(config.rateLimits)
 	if err := RegisterApis(apis, config.Modules, srv); err != nil {
 		return err
 	}
+	var handler http.Handler = srv
+	if config.apiKeys != nil {
+		handler = newAPIKeyHandler(config.apiKeys, handler)
+	}
 	h.httpConfig = config
 	h.httpHandler.Store(&rpcHandler{
-		Handler: NewHTTPHandlerStack(srv, config.CorsAllowedOrigins, config.Vhosts, config.jwtSecret),
+		Handler: NewHTTPHandlerStack(handler, config.CorsAllowedOrigins, config.Vhosts, config.jwtSecret),
 		server:  srv,
 	})
+	for _, proto := range h.protoHandlers {
+		proto.enable(config)
+	}
+	for _, keyed := range h.keyedHandlers {
+		keyed.enable(config)
+	}
 	return nil
 }
 
@@ -324,12 +350,17 @@ func (h *httpServer) enableWS(apis []rpc.API, config wsConfig) error {
 	}
 	// Create RPC server and handler.
 	srv := rpc.NewServer()
+	srv.SetRateLimits(config.rateLimits)
 	if err := RegisterApis(apis, config.Modules, srv); err != nil {
 		return err
 	}
+	handler := srv.WebsocketHandler(config.Origins)
+	if config.apiKeys != nil {
+		handler = newAPIKeyHandler(config.apiKeys, handler)
+	}
 	h.wsConfig = config
 	h.wsHandler.Store(&rpcHandler{
-		Handler: NewWSHandlerStack(srv.WebsocketHandler(config.Origins), config.jwtSecret),
+		Handler: NewWSHandlerStack(handler, config.jwtSecret),
 		server:  srv,
 	})
 	return nil
@@ -384,6 +415,76 @@ func NewHTTPHandlerStack(srv http.Handler, cors []string, vhosts []string, jwtSe
 	return newGzipHandler(handler)
 }
 
+// NewProtoHandlerStack returns the wrapped handler of a Protobuf-over-HTTP server.
+// Unlike JSON-RPC responses, the responses are not compressed, which would also
+// delay the messages of streams.
+func NewProtoHandlerStack(srv http.Handler, cors []string, vhosts []string, jwtSecret []byte) http.Handler {
+	handler := newCorsHandler(srv, cors)
+	handler = newVHostHandler(vhosts, handler)
+	if len(jwtSecret) != 0 {
+		handler = newJWTHandler(jwtSecret, handler)
+	}
+	return handler
+}
+
+// protoHandler is a Protobuf-over-HTTP server mounted on the HTTP server. Its
+// handler stack is created when JSON-RPC over HTTP is enabled, such that the
+// calls are checked against the same API keys and rate limits.
+type protoHandler struct {
+	server  *rpc.ProtoServer
+	cors    []string
+	vhosts  []string
+	handler atomic.Value // http.Handler
+}
+
+// ServeHTTP implements http.Handler
+func (h *protoHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	handler, _ := h.handler.Load().(http.Handler)
+	if handler == nil {
+		http.NotFound(w, r)
+		return
+	}
+	handler.ServeHTTP(w, r)
+}
+
+// enable creates the handler stack with the API keys and rate limits of the
+// JSON-RPC configuration.
+func (h *protoHandler) enable(config httpConfig) {
+	h.server.SetRateLimits(config.rateLimits)
+	var handler http.Handler = h.server
+	if config.apiKeys != nil {
+		handler = newAPIKeyHandler(config.apiKeys, handler)
+	}
+	h.handler.Store(NewProtoHandlerStack(handler, h.cors, h.vhosts, config.jwtSecret))
+}
+
+// keyedHandler is a handler serving API calls outside of the RPC server, which
+// requires the same API keys as JSON-RPC over HTTP. Its handler is created when
+// JSON-RPC over HTTP is enabled.
+type keyedHandler struct {
+	next    http.Handler
+	handler atomic.Value // http.Handler
+}
+
+// ServeHTTP implements http.Handler
+func (h *keyedHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	handler, _ := h.handler.Load().(http.Handler)
+	if handler == nil {
+		http.NotFound(w, r)
+		return
+	}
+	handler.ServeHTTP(w, r)
+}
+
+// enable creates the handler with the API keys of the JSON-RPC configuration.
+func (h *keyedHandler) enable(config httpConfig) {
+	var handler http.Handler = h.next
+	if config.apiKeys != nil {
+		handler = newAPIKeyHandler(config.apiKeys, handler)
+	}
+	h.handler.Store(handler)
+}
+
 // NewWSHandlerStack returns a wrapped ws-related handler.
 func NewWSHandlerStack(srv http.Handler, jwtSecret []byte) http.Handler {
 	if len(jwtSecret) != 0 {
@@ -475,7 +576,7 @@ func (w *gzipResponseWriter) Write(b []byte) (int, error) {
 
 func newGzipHandler(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		if !strings.Contains(r.Header.Get("Accept-Encoding"), "gzip") {
+		if !strings.Contains(r.Header.Get("Accept-Encoding"), "gzip") || isWebsocket(r) {
 			next.ServeHTTP(w, r)
 			return
 		}
@@ -18,6 +18,7 @@ package node
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"net/http"
 	"net/url"
@@ -419,3 +420,167 @@ func TestJWT(t *testing.T) {
 	}
 	srv.stop()
 }
+
+func TestAPIKeys(t *testing.T) {
+	expired := time.Now().Add(-time.Hour)
+	keys, err := newAPIKeys([]apiKeyConfig{
+		{Name: "rpc", Key: "secret-rpc", Methods: []string{"rpc_*"}},
+		{Name: "eth", Key: "secret-eth", Methods: []string{"eth_chainId", "eth_subscribe"}},
+		{Name: "limited", Key: "secret-limited", Methods: []string{"*"}, RateLimit: 0.001, Burst: 1},
+		{Name: "expired", Key: "secret-expired", Methods: []string{"*"}, Expiry: &expired},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := createAndStartServer(t, &httpConfig{apiKeys: keys}, true, &wsConfig{Origins: []string{"*"}, apiKeys: keys})
+	defer srv.stop()
+	wsUrl := fmt.Sprintf("ws://%v", srv.listenAddr())
+	htUrl := fmt.Sprintf("http://%v", srv.listenAddr())
+
+	// Requests without a valid key are rejected before dispatch.
+	for _, header := range [][]string{nil, {apiKeyHeader, "wrong"}, {apiKeyHeader, "secret-expired"}} {
+		if resp := rpcRequest(t, htUrl, header...); resp.StatusCode != http.StatusUnauthorized {
+			t.Errorf("http %v: expected unauthorized, got %v", header, resp.StatusCode)
+		}
+		if err := wsRequest(t, wsUrl, header...); err == nil {
+			t.Errorf("ws %v: expected not to allow, got ok", header)
+		}
+	}
+
+	// Calls are checked against the key.
+	call := func(client *rpc.Client) int {
+		var result interface{}
+		err := client.Call(&result, "rpc_modules")
+		if err == nil {
+			return 0
+		}
+		if rpcErr, ok := err.(rpc.Error); ok {
+			return rpcErr.ErrorCode()
+		}
+		t.Fatalf("unexpected error: %v", err)
+		return 0
+	}
+	tests := []struct {
+		key   string
+		codes []int
+	}{
+		{"secret-rpc", []int{0, 0}},
+		{"secret-eth", []int{errcodeAPIKeyDenied}},
+		{"secret-limited", []int{0, errcodeAPIKeyRateLimited}},
+	}
+	for _, test := range tests {
+		httpClient, err := rpc.DialHTTP(htUrl)
+		if err != nil {
+			t.Fatal(err)
+		}
+		httpClient.SetHeader(apiKeyHeader, test.key)
+		wsClient, err := rpc.DialWebsocket(context.Background(), wsUrl+"?"+apiKeyQuery+"="+test.key, "")
+		if err != nil {
+			t.Fatalf("key %s: could not dial websocket: %v", test.key, err)
+		}
+		for i, want := range test.codes {
+			if have := call(httpClient); have != want {
+				t.Errorf("key %s: http call %d: wrong error code %d, want %d", test.key, i, have, want)
+			}
+		}
+		if test.key != "secret-limited" {
+			for i, want := range test.codes {
+				if have := call(wsClient); have != want {
+					t.Errorf("key %s: ws call %d: wrong error code %d, want %d", test.key, i, have, want)
+				}
+			}
+		} else if have := call(wsClient); have != errcodeAPIKeyRateLimited {
+			t.Errorf("key %s: ws call: wrong error code %d, want %d", test.key, have, errcodeAPIKeyRateLimited)
+		}
+		httpClient.Close()
+		wsClient.Close()
+	}
+}
+
+func TestProtoAPIKeys(t *testing.T) {
+	keys, err := newAPIKeys([]apiKeyConfig{
+		{Name: "proto", Key: "secret-proto", Methods: []string{"proto_*"}},
+		{Name: "eth", Key: "secret-eth", Methods: []string{"eth_*"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	proto := rpc.NewProtoServer()
+	proto.RegisterMethod("Echo", func(ctx context.Context, req []byte) ([]byte, error) {
+		return req, nil
+	})
+	srv := newHTTPServer(testlog.Logger(t, log.LvlDebug), rpc.DefaultHTTPTimeouts)
+	handler := &protoHandler{server: proto}
+	srv.mux.Handle("/proto/", handler)
+	srv.protoHandlers = append(srv.protoHandlers, handler)
+	assert.NoError(t, srv.enableRPC(nil, httpConfig{apiKeys: keys, rateLimits: rpc.RateLimitConfig{Rate: 0.001, Burst: 2}}))
+	assert.NoError(t, srv.setListenAddr("localhost", 0))
+	assert.NoError(t, srv.start())
+	defer srv.stop()
+
+	call := func(key string) error {
+		client, err := rpc.DialProto(fmt.Sprintf("http://%v/proto", srv.listenAddr()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer client.Close()
+		if key != "" {
+			client.SetHeader(apiKeyHeader, key)
+		}
+		_, err = client.Call(context.Background(), "Echo", []byte("hello"))
+		return err
+	}
+	// Calls are checked against the key and the rate limits.
+	if err := call(""); err == nil || err.(rpc.HTTPError).StatusCode != http.StatusUnauthorized {
+		t.Fatalf("expected unauthorized error, got %v", err)
+	}
+	if err := call("secret-eth"); err == nil || err.(rpc.Error).ErrorCode() != errcodeAPIKeyDenied {
+		t.Fatalf("expected denied error, got %v", err)
+	}
+	for i := 0; i < 2; i++ {
+		if err := call("secret-proto"); err != nil {
+			t.Fatalf("call %d failed: %v", i, err)
+		}
+	}
+	if err := call("secret-proto"); err == nil || err.(rpc.Error).ErrorCode() != -32005 {
+		t.Fatalf("expected rate limit error, got %v", err)
+	}
+}
+
+func TestAPIKeyMethods(t *testing.T) {
+	keys, err := newAPIKeys([]apiKeyConfig{
+		{Name: "test", Key: "secret", Methods: []string{"eth_*", "net_version", "admin_subscribe"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	key := keys.lookup("secret")
+	for method, want := range map[string]bool{
+		"eth_call":          true,
+		"eth_subscribe":     true,
+		"net_version":       true,
+		"net_listening":     false,
+		"admin_subscribe":   true,
+		"admin_unsubscribe": true,
+		"admin_peers":       false,
+		"eth":               false,
+	} {
+		if have := key.allowed(method); have != want {
+			t.Errorf("method %s: allowed %v, want %v", method, have, want)
+		}
+	}
+	// Invalid configurations are rejected.
+	for _, configs := range [][]apiKeyConfig{
+		{{Name: "", Key: "a", Methods: []string{"*"}}},
+		{{Name: "a/b", Key: "a", Methods: []string{"*"}}},
+		{{Name: "a", Key: "", Methods: []string{"*"}}},
+		{{Name: "a", Key: "a"}},
+		{{Name: "a", Key: "a", Methods: []string{"*"}, RateLimit: -1}},
+		{{Name: "a", Key: "a", Methods: []string{"*"}}, {Name: "a", Key: "b", Methods: []string{"*"}}},
+		{{Name: "a", Key: "a", Methods: []string{"*"}}, {Name: "b", Key: "a", Methods: []string{"*"}}},
+	} {
+		if _, err := newAPIKeys(configs); err == nil {
+			t.Errorf("no error for configuration %+v", configs)
+		}
+	}
+}
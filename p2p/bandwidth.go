@@ -0,0 +1,251 @@
+// Copyright 2022 The go-confero Authors
+// This file is part of the go-confero library.
+//
+// The go-confero library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-confero library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-confero library. If not, see <http://www.gnu.org/licenses/>.
+
+package p2p
+
+import (
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/confero-network/go-confero/common/mclock"
+)
+
+// RateLimit is a pair of bandwidth limits in bytes per second. Zero means the
+// direction is not limited.
+type RateLimit struct {
+	Ingress int `toml:",omitempty"`
+	Egress  int `toml:",omitempty"`
+}
+
+// PeerTraffic is the amount of traffic exchanged with a peer, as reported in
+// admin_peers.
+type PeerTraffic struct {
+	Ingress   uint64                            `json:"ingress"`   // Bytes read from the connection
+	Egress    uint64                            `json:"egress"`    // Bytes written to the connection
+	Protocols map[string]map[string]*MsgTraffic `json:"protocols"` // Message traffic by protocol and code
+}
+
+// MsgTraffic is the amount of traffic of a single message code. Sizes are the
+// message sizes on the wire, excluding the frame overhead of RLPx.
+type MsgTraffic struct {
+	IngressPackets uint64 `json:"ingressPackets"`
+	IngressBytes   uint64 `json:"ingressBytes"`
+	EgressPackets  uint64 `json:"egressPackets"`
+	EgressBytes    uint64 `json:"egressBytes"`
+}
+
+// msgTrafficCounter is implemented by transports counting the traffic of the
+// individual message codes.
+type msgTrafficCounter interface {
+	msgTraffic() map[uint64]MsgTraffic
+}
+
+// msgCounter counts the traffic of the message codes on a connection. Codes
+// are the codes on the wire, i.e. they include the offset of the protocol.
+type msgCounter struct {
+	lock  sync.Mutex
+	codes map[uint64]*MsgTraffic
+}
+
+func (c *msgCounter) countIngress(code uint64, size uint32) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	t := c.get(code)
+	t.IngressPackets++
+	t.IngressBytes += uint64(size)
+}
+
+func (c *msgCounter) countEgress(code uint64, size uint32) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	t := c.get(code)
+	t.EgressPackets++
+	t.EgressBytes += uint64(size)
+}
+
+func (c *msgCounter) get(code uint64) *MsgTraffic {
+	if c.codes == nil {
+		c.codes = make(map[uint64]*MsgTraffic)
+	}
+	t := c.codes[code]
+	if t == nil {
+		t = new(MsgTraffic)
+		c.codes[code] = t
+	}
+	return t
+}
+
+func (c *msgCounter) msgTraffic() map[uint64]MsgTraffic {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	traffic := make(map[uint64]MsgTraffic, len(c.codes))
+	for code, t := range c.codes {
+		traffic[code] = *t
+	}
+	return traffic
+}
+
+// bandwidthLimits holds the token buckets enforcing the bandwidth limits of the
+// server. The buckets are shared by all peers.
+type bandwidthLimits struct {
+	ingress   *tokenBucket
+	egress    *tokenBucket
+	protocols map[string][2]*tokenBucket // ingress and egress buckets by protocol name
+}
+
+// newBandwidthLimits creates the token buckets for the configured limits. It
+// returns nil if no limits are configured.
+func newBandwidthLimits(global RateLimit, protocols map[string]RateLimit, clock mclock.Clock) *bandwidthLimits {
+	l := &bandwidthLimits{
+		ingress:   newTokenBucket(global.Ingress, clock),
+		egress:    newTokenBucket(global.Egress, clock),
+		protocols: make(map[string][2]*tokenBucket),
+	}
+	for name, limit := range protocols {
+		buckets := [2]*tokenBucket{newTokenBucket(limit.Ingress, clock), newTokenBucket(limit.Egress, clock)}
+		if buckets[0] != nil || buckets[1] != nil {
+			l.protocols[name] = buckets
+		}
+	}
+	if l.ingress == nil && l.egress == nil && len(l.protocols) == 0 {
+		return nil
+	}
+	return l
+}
+
+// waitGlobal blocks until size bytes of traffic in the given direction are
+// allowed by the global limit.
+func (l *bandwidthLimits) waitGlobal(egress bool, size uint32, abort <-chan struct{}) error {
+	if l == nil {
+		return nil
+	}
+	if egress {
+		return l.egress.wait(size, abort)
+	}
+	return l.ingress.wait(size, abort)
+}
+
+// waitProtocol blocks until size bytes of traffic in the given direction are
+// allowed by the limit of the protocol.
+func (l *bandwidthLimits) waitProtocol(proto string, egress bool, size uint32, abort <-chan struct{}) error {
+	if l == nil {
+		return nil
+	}
+	buckets, ok := l.protocols[proto]
+	if !ok {
+		return nil
+	}
+	if egress {
+		return buckets[1].wait(size, abort)
+	}
+	return buckets[0].wait(size, abort)
+}
+
+// tokenBucket is a token bucket rate limiter. Traffic is charged after the
+// fact, so messages larger than the bucket capacity can pass by putting the
+// bucket into debt, which is paid off by delaying subsequent traffic.
+type tokenBucket struct {
+	clock mclock.Clock
+	rate  float64 // Tokens (bytes) added per second
+	burst float64 // Maximum number of tokens in the bucket
+
+	lock   sync.Mutex
+	tokens float64
+	last   mclock.AbsTime
+}
+
+// newTokenBucket creates a token bucket allowing rate bytes per second, with a
+// capacity of one second of traffic. It returns nil if rate is not positive,
+// which is a valid bucket that never limits.
+func newTokenBucket(rate int, clock mclock.Clock) *tokenBucket {
+	if rate <= 0 {
+		return nil
+	}
+	return &tokenBucket{
+		clock:  clock,
+		rate:   float64(rate),
+		burst:  float64(rate),
+		tokens: float64(rate),
+		last:   clock.Now(),
+	}
+}
+
+// charge takes n tokens from the bucket, returning how long the caller has to
+// wait for the bucket to get out of debt.
+func (b *tokenBucket) charge(n uint32) time.Duration {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	now := b.clock.Now()
+	b.tokens += b.rate * time.Duration(now-b.last).Seconds()
+	if b.tokens > b.burst {
+		b.tokens = b.burst
+	}
+	b.last = now
+	b.tokens -= float64(n)
+	if b.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-b.tokens / b.rate * float64(time.Second))
+}
+
+// wait charges n tokens and blocks until the bucket is out of debt, or abort
+// is closed.
+func (b *tokenBucket) wait(n uint32, abort <-chan struct{}) error {
+	if b == nil {
+		return nil
+	}
+	delay := b.charge(n)
+	if delay <= 0 {
+		return nil
+	}
+	start := b.clock.Now()
+	timer := b.clock.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C():
+		throttleTimer.Update(time.Duration(b.clock.Now() - start))
+		return nil
+	case <-abort:
+		return io.EOF
+	}
+}
+
+// protocolTraffic groups the message traffic of a peer by protocol, mapping
+// the codes on the wire to the codes of the running protocols.
+func protocolTraffic(codes map[uint64]MsgTraffic, running map[string]*protoRW) map[string]map[string]*MsgTraffic {
+	traffic := make(map[string]map[string]*MsgTraffic)
+	for code, t := range codes {
+		for _, proto := range running {
+			if code < proto.offset || code >= proto.offset+proto.Length {
+				continue
+			}
+			name := proto.cap().String()
+			if traffic[name] == nil {
+				traffic[name] = make(map[string]*MsgTraffic)
+			}
+			t := t
+			traffic[name][fmt.Sprintf("%#02x", code-proto.offset)] = &t
+		}
+	}
+	return traffic
+}
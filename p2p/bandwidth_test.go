@@ -0,0 +1,174 @@
+// Copyright 2022 The go-confero Authors
+// This file is part of the go-confero library.
+//
+// The go-confero library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-confero library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-confero library. If not, see <http://www.gnu.org/licenses/>.
+
+package p2p
+
+import (
+	"bytes"
+	"io"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/confero-network/go-confero/common/mclock"
+	"github.com/confero-network/go-confero/crypto"
+	"github.com/confero-network/go-confero/p2p/simulations/pipes"
+)
+
+func TestTokenBucket(t *testing.T) {
+	var (
+		clock  = new(mclock.Simulated)
+		bucket = newTokenBucket(1000, clock)
+		abort  = make(chan struct{})
+	)
+	// The bucket starts full, so a burst of up to one second passes.
+	if delay := bucket.charge(1000); delay != 0 {
+		t.Fatalf("wrong delay for burst: %v", delay)
+	}
+	// Going into debt should delay by the time needed to pay it off.
+	if delay := bucket.charge(500); delay != 500*time.Millisecond {
+		t.Fatalf("wrong delay in debt: %v", delay)
+	}
+	clock.Run(time.Second)
+	if delay := bucket.charge(500); delay != 0 {
+		t.Fatalf("wrong delay after refill: %v", delay)
+	}
+
+	// Waiting should block until the debt is paid off.
+	done := make(chan error, 1)
+	go func() { done <- bucket.wait(750, abort) }()
+	clock.WaitForTimers(1)
+	clock.Run(700 * time.Millisecond)
+	select {
+	case <-done:
+		t.Fatal("wait returned before the debt was paid off")
+	default:
+	}
+	clock.Run(50 * time.Millisecond)
+	if err := <-done; err != nil {
+		t.Fatalf("wait failed: %v", err)
+	}
+
+	// Closing abort should interrupt the wait.
+	go func() { done <- bucket.wait(2000, abort) }()
+	clock.WaitForTimers(1)
+	close(abort)
+	if err := <-done; err != io.EOF {
+		t.Fatalf("wrong error for aborted wait: %v", err)
+	}
+
+	// Unset limits don't create buckets.
+	if l := newBandwidthLimits(RateLimit{}, map[string]RateLimit{"eth": {}}, clock); l != nil {
+		t.Fatal("limits created without any limit set")
+	}
+}
+
+func TestProtocolTraffic(t *testing.T) {
+	running := map[string]*protoRW{
+		"a": {Protocol: Protocol{Name: "a", Version: 1, Length: 5}, offset: baseProtocolLength},
+		"b": {Protocol: Protocol{Name: "b", Version: 2, Length: 3}, offset: baseProtocolLength + 5},
+	}
+	var counter msgCounter
+	counter.countIngress(pingMsg, 1)
+	counter.countIngress(baseProtocolLength+1, 100)
+	counter.countIngress(baseProtocolLength+1, 50)
+	counter.countEgress(baseProtocolLength+1, 10)
+	counter.countEgress(baseProtocolLength+5, 20)
+
+	want := map[string]map[string]*MsgTraffic{
+		"a/1": {"0x01": {IngressPackets: 2, IngressBytes: 150, EgressPackets: 1, EgressBytes: 10}},
+		"b/2": {"0x00": {EgressPackets: 1, EgressBytes: 20}},
+	}
+	if have := protocolTraffic(counter.msgTraffic(), running); !reflect.DeepEqual(have, want) {
+		t.Fatalf("wrong traffic:\nhave %v\nwant %v", have, want)
+	}
+}
+
+func TestTransportProtocolLimit(t *testing.T) {
+	var (
+		clock   = new(mclock.Simulated)
+		limits  = newBandwidthLimits(RateLimit{}, map[string]RateLimit{"a": {Ingress: 1000}}, clock)
+		prv0, _ = crypto.GenerateKey()
+		prv1, _ = crypto.GenerateKey()
+	)
+	fd0, fd1, err := pipes.TCPPipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fd0.Close()
+	defer fd1.Close()
+
+	writer := newRLPX(fd0, &prv1.PublicKey).(*rlpxTransport)
+	reader := newLimitedRLPX(limits)(fd1, nil).(*rlpxTransport)
+	defer reader.close(DiscQuitting)
+
+	errc := make(chan error, 1)
+	go func() {
+		_, err := writer.doEncHandshake(prv0)
+		errc <- err
+	}()
+	if _, err := reader.doEncHandshake(prv1); err != nil {
+		t.Fatalf("enc handshake failed: %v", err)
+	}
+	if err := <-errc; err != nil {
+		t.Fatalf("enc handshake failed: %v", err)
+	}
+	reader.setProtocols(map[string]*protoRW{
+		"a": {Protocol: Protocol{Name: "a", Version: 1, Length: 5}, offset: baseProtocolLength},
+		"b": {Protocol: Protocol{Name: "b", Version: 1, Length: 5}, offset: baseProtocolLength + 5},
+	})
+	send := func(code uint64) {
+		go func() {
+			errc <- writer.WriteMsg(Msg{Code: code, Size: 2000, Payload: bytes.NewReader(make([]byte, 2000))})
+		}()
+	}
+	read := make(chan Msg, 1)
+	receive := func() {
+		go func() {
+			msg, err := reader.ReadMsg()
+			if err != nil {
+				t.Errorf("read failed: %v", err)
+			}
+			read <- msg
+		}()
+	}
+	// Messages of unlimited protocols pass right away.
+	send(baseProtocolLength + 5)
+	receive()
+	if msg := <-read; msg.Code != baseProtocolLength+5 {
+		t.Fatalf("wrong message code: %d", msg.Code)
+	}
+	if err := <-errc; err != nil {
+		t.Fatalf("write failed: %v", err)
+	}
+	// Messages of a limited protocol are held back by the reader until the limit
+	// allows them.
+	send(baseProtocolLength + 1)
+	receive()
+	clock.WaitForTimers(1)
+	select {
+	case <-read:
+		t.Fatal("message over the protocol limit read without delay")
+	default:
+	}
+	clock.Run(2 * time.Second)
+	if msg := <-read; msg.Code != baseProtocolLength+1 {
+		t.Fatalf("wrong message code: %d", msg.Code)
+	}
+	if err := <-errc; err != nil {
+		t.Fatalf("write failed: %v", err)
+	}
+}
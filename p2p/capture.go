@@ -0,0 +1,198 @@
+// Copyright 2022 The go-confero Authors
+// This file is part of the go-confero library.
+//
+// The go-confero library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-confero library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-confero library. If not, see <http://www.gnu.org/licenses/>.
+
+package p2p
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/confero-network/go-confero/p2p/enode"
+	"github.com/confero-network/go-confero/rlp"
+)
+
+// captureMagic starts every capture file. The last byte is the format version.
+var captureMagic = []byte("devp2pcap\x01")
+
+var errCaptureMagic = errors.New("not a devp2p capture file")
+
+// CaptureRecord is a protocol message recorded by the traffic capture.
+type CaptureRecord struct {
+	Time     uint64   // Unix time in nanoseconds
+	Peer     enode.ID // Remote node of the connection
+	Protocol string   // Name of the protocol
+	Version  uint     // Version of the protocol
+	Ingress  bool     // Whether the message was received from the peer
+	Code     uint64   // Message code, relative to the protocol
+	Payload  []byte   // RLP payload of the message
+}
+
+// CaptureWriter writes protocol messages to a capture file. The file starts
+// with a magic string and contains a sequence of RLP encoded records.
+//
+// The writer is safe for concurrent use. After the first write error, all
+// further records are dropped and the error is returned by Close.
+type CaptureWriter struct {
+	mu  sync.Mutex
+	w   *bufio.Writer
+	c   io.Closer
+	err error
+}
+
+// NewCaptureWriter creates a capture writer. If w implements io.Closer, it is
+// closed by Close.
+func NewCaptureWriter(w io.Writer) (*CaptureWriter, error) {
+	cw := &CaptureWriter{w: bufio.NewWriter(w)}
+	if c, ok := w.(io.Closer); ok {
+		cw.c = c
+	}
+	if _, err := cw.w.Write(captureMagic); err != nil {
+		return nil, err
+	}
+	return cw, nil
+}
+
+// Write appends a record to the capture.
+func (cw *CaptureWriter) Write(rec *CaptureRecord) error {
+	cw.mu.Lock()
+	defer cw.mu.Unlock()
+
+	if cw.err != nil {
+		return cw.err
+	}
+	cw.err = rlp.Encode(cw.w, rec)
+	return cw.err
+}
+
+// Close flushes the capture and closes the underlying writer.
+func (cw *CaptureWriter) Close() error {
+	cw.mu.Lock()
+	defer cw.mu.Unlock()
+
+	err := cw.err
+	if ferr := cw.w.Flush(); err == nil {
+		err = ferr
+	}
+	if cw.c != nil {
+		if cerr := cw.c.Close(); err == nil {
+			err = cerr
+		}
+	}
+	if cw.err == nil {
+		cw.err = errors.New("capture closed")
+	}
+	return err
+}
+
+// CaptureReader reads the records of a capture file.
+type CaptureReader struct {
+	s *rlp.Stream
+}
+
+// NewCaptureReader creates a reader for a capture file.
+func NewCaptureReader(r io.Reader) (*CaptureReader, error) {
+	br := bufio.NewReader(r)
+	magic := make([]byte, len(captureMagic))
+	if _, err := io.ReadFull(br, magic); err != nil || !bytes.Equal(magic, captureMagic) {
+		return nil, errCaptureMagic
+	}
+	return &CaptureReader{s: rlp.NewStream(br, 0)}, nil
+}
+
+// Read returns the next record. It returns io.EOF at the end of the capture.
+func (cr *CaptureReader) Read() (*CaptureRecord, error) {
+	rec := new(CaptureRecord)
+	if err := cr.s.Decode(rec); err != nil {
+		return nil, err
+	}
+	return rec, nil
+}
+
+// msgCapturer wraps a MsgReadWriter and records all messages of a protocol
+// in the traffic capture.
+type msgCapturer struct {
+	MsgReadWriter
+
+	capture *CaptureWriter
+	peerID  enode.ID
+	proto   string
+	version uint
+}
+
+func newMsgCapturer(rw MsgReadWriter, capture *CaptureWriter, peerID enode.ID, proto string, version uint) *msgCapturer {
+	return &msgCapturer{
+		MsgReadWriter: rw,
+		capture:       capture,
+		peerID:        peerID,
+		proto:         proto,
+		version:       version,
+	}
+}
+
+// ReadMsg reads a message from the underlying MsgReadWriter and records it.
+func (c *msgCapturer) ReadMsg() (Msg, error) {
+	msg, err := c.MsgReadWriter.ReadMsg()
+	if err != nil {
+		return msg, err
+	}
+	payload, err := io.ReadAll(msg.Payload)
+	if err != nil {
+		return msg, err
+	}
+	msg.Payload = bytes.NewReader(payload)
+	c.record(msg.ReceivedAt, true, msg.Code, payload)
+	return msg, nil
+}
+
+// WriteMsg records a message and writes it to the underlying MsgReadWriter.
+func (c *msgCapturer) WriteMsg(msg Msg) error {
+	payload, err := io.ReadAll(msg.Payload)
+	if err != nil {
+		return err
+	}
+	msg.Payload = bytes.NewReader(payload)
+	c.record(time.Now(), false, msg.Code, payload)
+	return c.MsgReadWriter.WriteMsg(msg)
+}
+
+func (c *msgCapturer) record(t time.Time, ingress bool, code uint64, payload []byte) {
+	if t.IsZero() {
+		t = time.Now()
+	}
+	// Write errors are reported when the capture is closed.
+	c.capture.Write(&CaptureRecord{
+		Time:     uint64(t.UnixNano()),
+		Peer:     c.peerID,
+		Protocol: c.proto,
+		Version:  c.version,
+		Ingress:  ingress,
+		Code:     code,
+		Payload:  payload,
+	})
+}
+
+// Close closes the underlying MsgReadWriter if it implements the io.Closer
+// interface.
+func (c *msgCapturer) Close() error {
+	if v, ok := c.MsgReadWriter.(io.Closer); ok {
+		return v.Close()
+	}
+	return nil
+}
@@ -0,0 +1,119 @@
+// Copyright 2022 The go-confero Authors
+// This file is part of the go-confero library.
+//
+// The go-confero library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-confero library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-confero library. If not, see <http://www.gnu.org/licenses/>.
+
+package p2p
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/confero-network/go-confero/log"
+	"github.com/confero-network/go-confero/p2p/enode"
+	"github.com/confero-network/go-confero/rlp"
+)
+
+func TestCapture(t *testing.T) {
+	var (
+		buf    bytes.Buffer
+		id     = enode.ID{1}
+		r1, w1 = MsgPipe()
+	)
+	defer r1.Close()
+	capture, err := NewCaptureWriter(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rw := newMsgCapturer(w1, capture, id, "test", 2)
+
+	// Send a message and receive the reply through the capturer.
+	go func() {
+		msg, err := r1.ReadMsg()
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		msg.Discard()
+		Send(r1, 3, []uint{4})
+	}()
+	if err := Send(rw, 1, []string{"ping"}); err != nil {
+		t.Fatal(err)
+	}
+	msg, err := rw.ReadMsg()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The payload must still be readable after capturing.
+	var reply []uint
+	if err := msg.Decode(&reply); err != nil || len(reply) != 1 || reply[0] != 4 {
+		t.Fatalf("wrong reply %v, err %v", reply, err)
+	}
+	if err := capture.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Check the records.
+	enc1, _ := rlp.EncodeToBytes([]string{"ping"})
+	enc2, _ := rlp.EncodeToBytes([]uint{4})
+	want := []CaptureRecord{
+		{Peer: id, Protocol: "test", Version: 2, Ingress: false, Code: 1, Payload: enc1},
+		{Peer: id, Protocol: "test", Version: 2, Ingress: true, Code: 3, Payload: enc2},
+	}
+	cr, err := NewCaptureReader(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range want {
+		rec, err := cr.Read()
+		if err != nil {
+			t.Fatalf("record %d: %v", i, err)
+		}
+		if rec.Time == 0 {
+			t.Errorf("record %d: missing time", i)
+		}
+		rec.Time = 0
+		if rec.Peer != want[i].Peer || rec.Protocol != want[i].Protocol || rec.Version != want[i].Version ||
+			rec.Ingress != want[i].Ingress || rec.Code != want[i].Code || !bytes.Equal(rec.Payload, want[i].Payload) {
+			t.Errorf("record %d mismatch:\nhave %+v\nwant %+v", i, rec, want[i])
+		}
+	}
+	if _, err := cr.Read(); err != io.EOF {
+		t.Fatalf("expected EOF after last record, got %v", err)
+	}
+
+	// Other files are rejected.
+	if _, err := NewCaptureReader(bytes.NewReader([]byte("garbage"))); err != errCaptureMagic {
+		t.Fatalf("wrong error for invalid file: %v", err)
+	}
+}
+
+// Tests that the server refuses to overwrite an existing capture file.
+func TestCaptureFileExists(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "capture")
+	if err := os.WriteFile(file, []byte("previous"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	srv := &Server{Config: Config{CaptureFile: file}, log: log.Root()}
+	if err := srv.setupCapture(); err == nil {
+		srv.closeCapture()
+		t.Fatal("existing capture file overwritten")
+	}
+	if data, _ := os.ReadFile(file); string(data) != "previous" {
+		t.Fatalf("capture file modified: %q", data)
+	}
+}
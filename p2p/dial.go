@@ -79,6 +79,8 @@ var (
 	errRecentlyDialed   = errors.New("recently dialed")
 	errNetRestrict      = errors.New("not contained in netrestrict list")
 	errNoPort           = errors.New("node does not provide TCP port")
+	errBanned           = errors.New("temporarily banned")
+	errLowReputation    = errors.New("low reputation")
 )
 
 // dialer creates outbound connections and submits them into Server.
@@ -108,6 +110,7 @@ type dialScheduler struct {
 	// should only be accessed by code on the loop goroutine.
 	dialing   map[enode.ID]*dialTask // active tasks
 	peers     map[enode.ID]struct{}  // all connected peers
+	subnets   map[string]int         // number of connected peers by subnet
 	dialPeers int                    // current number of dialed peers
 
 	// The static map tracks all static dial tasks. The subset of usable static dial tasks
@@ -139,6 +142,9 @@ type dialConfig struct {
 	log            log.Logger
 	clock          mclock.Clock
 	rand           *mrand.Rand
+	reputation     *Reputation        // peer reputation tracker, disabled if nil
+	policy         func() *peerPolicy // peer policy for dynamic dials, disabled if nil
+	protocols      []Protocol         // protocols of the server, checked against the policy
 }
 
 func (cfg dialConfig) withDefaults() dialConfig {
@@ -167,6 +173,7 @@ func newDialScheduler(config dialConfig, it enode.Iterator, setupFunc dialSetupF
 		dialing:     make(map[enode.ID]*dialTask),
 		static:      make(map[enode.ID]*dialTask),
 		peers:       make(map[enode.ID]struct{}),
+		subnets:     make(map[string]int),
 		doneCh:      make(chan *dialTask),
 		nodesIn:     make(chan *enode.Node),
 		addStaticCh: make(chan *enode.Node),
@@ -242,10 +249,10 @@ loop:
 
 		select {
 		case node := <-nodesCh:
-			if err := d.checkDial(node); err != nil {
+			if err := d.checkCandidate(node); err != nil {
 				d.log.Trace("Discarding dial candidate", "id", node.ID(), "ip", node.IP(), "reason", err)
 			} else {
-				d.startDial(newDialTask(node, dynDialedConn))
+				d.startDial(newDialTask(d.preferCandidate(node), dynDialedConn))
 			}
 
 		case task := <-d.doneCh:
@@ -260,6 +267,9 @@ loop:
 			}
 			id := c.node.ID()
 			d.peers[id] = struct{}{}
+			if ip := c.node.IP(); ip != nil {
+				d.subnets[subnetKey(ip)]++
+			}
 			// Remove from static pool because the node is now connected.
 			task := d.static[id]
 			if task != nil && task.staticPoolIndex >= 0 {
@@ -272,6 +282,13 @@ loop:
 				d.dialPeers--
 			}
 			delete(d.peers, c.node.ID())
+			if ip := c.node.IP(); ip != nil {
+				if key := subnetKey(ip); d.subnets[key] <= 1 {
+					delete(d.subnets, key)
+				} else {
+					d.subnets[key]--
+				}
+			}
 			d.updateStaticPool(c.node.ID())
 
 		case node := <-d.addStaticCh:
@@ -410,10 +427,81 @@ func (d *dialScheduler) checkDial(n *enode.Node) error {
 	return nil
 }
 
+// checkCandidate returns an error if the dynamic dial candidate n should not be
+// dialed.
+func (d *dialScheduler) checkCandidate(n *enode.Node) error {
+	if err := d.checkDial(n); err != nil {
+		return err
+	}
+	if err := d.checkReputation(n); err != nil {
+		return err
+	}
+	return d.checkPolicy(n)
+}
+
+// preferCandidate picks the better of two dynamic dial candidates if reputation
+// is tracked: when another dialable candidate is readily available from
+// discovery and has a better score than n, it is dialed instead. The skipped
+// candidate is offered again by discovery later on.
+func (d *dialScheduler) preferCandidate(n *enode.Node) *enode.Node {
+	if d.reputation == nil {
+		return n
+	}
+	select {
+	case alt := <-d.nodesIn:
+		if d.checkCandidate(alt) == nil && d.reputation.Score(alt.ID()) > d.reputation.Score(n.ID()) {
+			return alt
+		}
+	default:
+	}
+	return n
+}
+
+// checkReputation returns an error if the dynamic dial candidate n should not be
+// dialed because of its reputation. Nodes with a negative score are skipped, so
+// that their score has to decay before they are dialed again.
+func (d *dialScheduler) checkReputation(n *enode.Node) error {
+	if d.reputation == nil {
+		return nil
+	}
+	if d.reputation.Banned(n.ID()) {
+		return errBanned
+	}
+	if d.reputation.Score(n.ID()) < 0 {
+		return errLowReputation
+	}
+	return nil
+}
+
+// checkPolicy returns an error if the dynamic dial candidate n is rejected by the
+// peer policy.
+func (d *dialScheduler) checkPolicy(n *enode.Node) error {
+	if d.policy == nil {
+		return nil
+	}
+	policy := d.policy()
+	if err := policy.checkRecord(n); err != nil {
+		return err
+	}
+	if ip := n.IP(); ip != nil {
+		if len(d.protocols) > 0 && len(policy.allowedProtocols(ip, d.protocols)) == 0 {
+			return errPolicyNetwork
+		}
+		return policy.checkSubnet(d.subnets[subnetKey(ip)])
+	}
+	return nil
+}
+
 // startStaticDials starts n static dial tasks.
 func (d *dialScheduler) startStaticDials(n int) (started int) {
 	for started = 0; started < n && len(d.staticPool) > 0; started++ {
 		idx := d.rand.Intn(len(d.staticPool))
+		if d.reputation != nil && len(d.staticPool) > 1 {
+			// Prefer the better of two random picks if reputation is tracked
+			if alt := d.rand.Intn(len(d.staticPool)); d.reputation.Score(d.staticPool[alt].dest.ID()) > d.reputation.Score(d.staticPool[idx].dest.ID()) {
+				idx = alt
+			}
+		}
 		task := d.staticPool[idx]
 		d.startDial(task)
 		d.removeFromStaticPool(idx)
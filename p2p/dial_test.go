@@ -394,6 +394,46 @@ func TestDialSchedResolve(t *testing.T) {
 	})
 }
 
+// This test checks that the better of two dynamic dial candidates is dialed when
+// reputation is tracked.
+func TestDialSchedPreferCandidate(t *testing.T) {
+	t.Parallel()
+
+	db, _ := enode.OpenDB("")
+	defer db.Close()
+
+	var (
+		rep     = newReputation(db)
+		good    = newNode(uintID(1), "127.0.0.1:30303")
+		neutral = newNode(uintID(2), "127.0.0.2:30303")
+		d       = &dialScheduler{
+			dialConfig: dialConfig{reputation: rep},
+			nodesIn:    make(chan *enode.Node, 1),
+			dialing:    make(map[enode.ID]*dialTask),
+			peers:      make(map[enode.ID]struct{}),
+		}
+	)
+	rep.report(good.ID(), ScoreUseful)
+
+	if n := d.preferCandidate(neutral); n != neutral {
+		t.Fatalf("wrong candidate without alternative: %v", n.ID())
+	}
+	d.nodesIn <- good
+	if n := d.preferCandidate(neutral); n != good {
+		t.Fatalf("better alternative not preferred: %v", n.ID())
+	}
+	d.nodesIn <- neutral
+	if n := d.preferCandidate(good); n != good {
+		t.Fatalf("worse alternative preferred: %v", n.ID())
+	}
+	// Alternatives which can't be dialed are skipped.
+	d.peers[good.ID()] = struct{}{}
+	d.nodesIn <- good
+	if n := d.preferCandidate(neutral); n != neutral {
+		t.Fatalf("connected alternative preferred: %v", n.ID())
+	}
+}
+
 // -------
 // Code below here is the framework for the tests above.
 
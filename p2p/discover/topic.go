@@ -0,0 +1,239 @@
+// Copyright 2022 The go-confero Authors
+// This file is part of the go-confero library.
+//
+// The go-confero library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-confero library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-confero library. If not, see <http://www.gnu.org/licenses/>.
+
+package discover
+
+import (
+	"crypto/hmac"
+	crand "crypto/rand"
+	"crypto/sha256"
+	"errors"
+	"math"
+	"math/rand"
+	"net"
+	"time"
+
+	"github.com/confero-network/go-confero/common/mclock"
+	"github.com/confero-network/go-confero/p2p/discover/v5wire"
+	"github.com/confero-network/go-confero/p2p/enode"
+	"github.com/confero-network/go-confero/rlp"
+)
+
+const (
+	adLifetime        = 15 * time.Minute // time an advertisement stays in the cache
+	adCacheLimit      = 5000             // max number of advertisements in the cache
+	adOccupancyPower  = 10               // steepness of the waiting time as the cache fills up
+	adWaitBase        = 1e-7             // waiting time factor for an empty cache
+	ticketTolerance   = time.Second      // allowed early return of a ticket
+	ticketValidity    = 10 * time.Second // time a ticket can be used after its waiting time
+	ticketMACLength   = sha256.Size
+	subnetPrefixBits4 = 24
+	subnetPrefixBits6 = 64
+)
+
+var (
+	errTicketMAC   = errors.New("invalid ticket MAC")
+	errTicketOwner = errors.New("ticket issued to another node or topic")
+	errTicketEarly = errors.New("ticket used before its waiting time")
+	errTicketLate  = errors.New("ticket expired")
+)
+
+// adCache is the advertisement cache of a registrar. It holds the nodes which
+// placed an advertisement for a topic and computes the waiting time of new
+// registrations, which grows as the cache fills up, as a topic becomes more
+// popular and as more advertisements come from the same subnet.
+//
+// The waiting time is not stored on the registrar. Registrants are instead
+// handed out tickets which are authenticated by a local secret and record the
+// time waited so far. The cache is only accessed by the dispatch loop.
+type adCache struct {
+	clock  mclock.Clock
+	key    []byte
+	topics map[v5wire.TopicID][]*topicAd
+	total  int
+}
+
+// topicAd is an advertisement in the cache.
+type topicAd struct {
+	node    *enode.Node
+	expires mclock.AbsTime
+}
+
+// ticket is the content of a TICKET. It is sent to the registrant RLP encoded
+// and followed by its MAC.
+type ticket struct {
+	Topic  v5wire.TopicID
+	ID     enode.ID
+	IP     net.IP
+	Issued uint64 // Time the ticket was issued
+	Wait   uint64 // Waiting time before the ticket can be used
+	Total  uint64 // Total time waited for the registration, including Wait
+}
+
+func newAdCache(clock mclock.Clock) *adCache {
+	key := make([]byte, 32)
+	crand.Read(key)
+	return &adCache{
+		clock:  clock,
+		key:    key,
+		topics: make(map[v5wire.TopicID][]*topicAd),
+	}
+}
+
+// register places an advertisement of n for a topic, if the registrant has
+// waited long enough. Otherwise it returns a ticket and the time to wait until
+// the registration can be retried with it.
+func (c *adCache) register(topic v5wire.TopicID, n *enode.Node, waited time.Duration) ([]byte, time.Duration, bool) {
+	now := c.clock.Now()
+	c.expire(now)
+
+	for _, ad := range c.topics[topic] {
+		if ad.node.ID() == n.ID() {
+			return nil, 0, true
+		}
+	}
+	wait := c.waitTime(topic, n.IP())
+	if c.total >= adCacheLimit {
+		// The cache is full, time waited so far doesn't count.
+		waited = 0
+	} else if wait <= waited {
+		c.topics[topic] = append(c.topics[topic], &topicAd{node: n, expires: now.Add(adLifetime)})
+		c.total++
+		return nil, 0, true
+	}
+	if wait -= waited; wait < ticketTolerance {
+		wait = ticketTolerance
+	}
+	tk := &ticket{
+		Topic:  topic,
+		ID:     n.ID(),
+		IP:     n.IP(),
+		Issued: uint64(now),
+		Wait:   uint64(wait),
+		Total:  uint64(waited + wait),
+	}
+	return c.sealTicket(tk), wait, false
+}
+
+// nodes returns the nodes advertising a topic, in random order.
+func (c *adCache) nodes(topic v5wire.TopicID) []*enode.Node {
+	c.expire(c.clock.Now())
+
+	ads := c.topics[topic]
+	nodes := make([]*enode.Node, len(ads))
+	for i, j := range rand.Perm(len(ads)) {
+		nodes[i] = ads[j].node
+	}
+	return nodes
+}
+
+// expire drops the advertisements which have expired.
+func (c *adCache) expire(now mclock.AbsTime) {
+	for topic, ads := range c.topics {
+		n := 0
+		for n < len(ads) && ads[n].expires <= now {
+			n++
+		}
+		if n == len(ads) {
+			delete(c.topics, topic)
+		} else if n > 0 {
+			c.topics[topic] = ads[n:]
+		}
+		c.total -= n
+	}
+}
+
+// waitTime computes the waiting time of a new advertisement, following the
+// formula of the topic advertisement spec:
+//
+//	wait = lifetime * (topicShare + subnetScore + base) / (1 - occupancy)^power
+//
+// The result is capped at the advertisement lifetime.
+func (c *adCache) waitTime(topic v5wire.TopicID, ip net.IP) time.Duration {
+	if c.total >= adCacheLimit {
+		return adLifetime
+	}
+	var (
+		ads         = c.topics[topic]
+		occupancy   = float64(c.total) / adCacheLimit
+		topicShare  = float64(len(ads)) / adCacheLimit
+		subnetScore float64
+	)
+	if len(ads) > 0 {
+		same := 0
+		for _, ad := range ads {
+			if sameSubnet(ad.node.IP(), ip) {
+				same++
+			}
+		}
+		subnetScore = float64(same) / float64(len(ads))
+	}
+	factor := (topicShare + subnetScore + adWaitBase) / math.Pow(1-occupancy, adOccupancyPower)
+	if factor >= 1 {
+		return adLifetime
+	}
+	return time.Duration(factor * float64(adLifetime))
+}
+
+// sealTicket encodes a ticket and appends its MAC.
+func (c *adCache) sealTicket(tk *ticket) []byte {
+	enc, _ := rlp.EncodeToBytes(tk)
+	mac := hmac.New(sha256.New, c.key)
+	mac.Write(enc)
+	return mac.Sum(enc)
+}
+
+// openTicket verifies and decodes a ticket presented for a registration.
+func (c *adCache) openTicket(data []byte, topic v5wire.TopicID, id enode.ID, ip net.IP) (*ticket, error) {
+	if len(data) < ticketMACLength {
+		return nil, errTicketMAC
+	}
+	enc, sum := data[:len(data)-ticketMACLength], data[len(data)-ticketMACLength:]
+	mac := hmac.New(sha256.New, c.key)
+	mac.Write(enc)
+	if !hmac.Equal(mac.Sum(nil), sum) {
+		return nil, errTicketMAC
+	}
+	var tk ticket
+	if err := rlp.DecodeBytes(enc, &tk); err != nil {
+		return nil, err
+	}
+	if tk.Topic != topic || tk.ID != id || !tk.IP.Equal(ip) {
+		return nil, errTicketOwner
+	}
+	var (
+		now   = c.clock.Now()
+		ready = mclock.AbsTime(tk.Issued).Add(time.Duration(tk.Wait))
+	)
+	if now < ready.Add(-ticketTolerance) {
+		return nil, errTicketEarly
+	}
+	if now > ready.Add(ticketValidity) {
+		return nil, errTicketLate
+	}
+	return &tk, nil
+}
+
+// sameSubnet reports whether two IPs are in the same /24 (IPv4) or /64 (IPv6)
+// subnet.
+func sameSubnet(a, b net.IP) bool {
+	if a4, b4 := a.To4(), b.To4(); a4 != nil && b4 != nil {
+		mask := net.CIDRMask(subnetPrefixBits4, 32)
+		return a4.Mask(mask).Equal(b4.Mask(mask))
+	}
+	mask := net.CIDRMask(subnetPrefixBits6, 128)
+	return a.Mask(mask).Equal(b.Mask(mask))
+}
@@ -0,0 +1,304 @@
+// Copyright 2022 The go-confero Authors
+// This file is part of the go-confero library.
+//
+// The go-confero library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-confero library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-confero library. If not, see <http://www.gnu.org/licenses/>.
+
+package discover
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/confero-network/go-confero/common/mclock"
+	"github.com/confero-network/go-confero/p2p/discover/v5wire"
+	"github.com/confero-network/go-confero/p2p/enode"
+	"github.com/confero-network/go-confero/p2p/netutil"
+)
+
+const (
+	topicRegistrarLimit   = 8                // max registrars used to advertise a topic
+	topicQueryResultLimit = 16               // applies in TOPICQUERY handler
+	topicRetryInterval    = 30 * time.Second // delay before retrying failed registrations
+	topicQueryInterval    = 30 * time.Second // min time between query rounds of a topic iterator
+)
+
+// TopicHash returns the identifier of a topic name, which is its SHA256 hash.
+// The identifier also determines the region of the DHT in which the topic is
+// advertised.
+func TopicHash(topic string) v5wire.TopicID {
+	return sha256.Sum256([]byte(topic))
+}
+
+// RegisterTopic starts advertising the local node for the given topic. The
+// node is advertised at the registrars closest to the topic hash until the
+// registration is stopped with StopRegisterTopic or the transport is closed.
+func (t *UDPv5) RegisterTopic(topic string) {
+	t.topicLock.Lock()
+	defer t.topicLock.Unlock()
+
+	id := TopicHash(topic)
+	if _, ok := t.topicRegs[id]; ok {
+		return
+	}
+	ctx, cancel := context.WithCancel(t.closeCtx)
+	t.topicRegs[id] = cancel
+	go t.registerTopicLoop(ctx, id)
+}
+
+// StopRegisterTopic stops advertising the local node for the given topic. The
+// advertisements already placed remain until they expire.
+func (t *UDPv5) StopRegisterTopic(topic string) {
+	t.topicLock.Lock()
+	defer t.topicLock.Unlock()
+
+	id := TopicHash(topic)
+	if cancel, ok := t.topicRegs[id]; ok {
+		cancel()
+		delete(t.topicRegs, id)
+	}
+}
+
+// TopicNodes returns an iterator over the nodes advertising the given topic.
+// The iterator repeatedly looks up the registrars of the topic and queries
+// them for advertisements.
+func (t *UDPv5) TopicNodes(topic string) enode.Iterator {
+	ctx, cancel := context.WithCancel(t.closeCtx)
+	return &topicIterator{
+		t:      t,
+		topic:  TopicHash(topic),
+		ctx:    ctx,
+		cancel: cancel,
+		seen:   make(map[enode.ID]struct{}),
+	}
+}
+
+// registerTopicLoop keeps the local node advertised for a topic.
+func (t *UDPv5) registerTopicLoop(ctx context.Context, topic v5wire.TopicID) {
+	for {
+		var (
+			registrars = t.newLookup(ctx, enode.ID(topic)).run()
+			registered = make(chan bool, len(registrars))
+			wg         sync.WaitGroup
+		)
+		if len(registrars) > topicRegistrarLimit {
+			registrars = registrars[:topicRegistrarLimit]
+		}
+		for _, n := range registrars {
+			wg.Add(1)
+			go func(n *enode.Node) {
+				defer wg.Done()
+				err := t.registerTopicAt(ctx, n, topic)
+				if err != nil && !errors.Is(err, errClosed) && ctx.Err() == nil {
+					t.log.Debug("Topic registration failed", "id", n.ID(), "addr", n.IP(), "err", err)
+				}
+				registered <- err == nil
+			}(n)
+		}
+		wg.Wait()
+		close(registered)
+
+		// Renew the advertisements when they expire. If no registration
+		// succeeded, retry sooner.
+		delay := topicRetryInterval
+		for ok := range registered {
+			if ok {
+				delay = adLifetime
+				break
+			}
+		}
+		select {
+		case <-t.clock.After(delay):
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// registerTopicAt places an advertisement for the local node at a registrar,
+// waiting for the tickets handed out by the registrar to mature.
+func (t *UDPv5) registerTopicAt(ctx context.Context, n *enode.Node, topic v5wire.TopicID) error {
+	var ticket []byte
+	for {
+		resp, err := t.regtopic(n, topic, ticket)
+		if err != nil {
+			return err
+		}
+		switch resp := resp.(type) {
+		case *v5wire.Regconfirmation:
+			if resp.Topic != topic {
+				return errors.New("confirmation for wrong topic")
+			}
+			return nil
+		case *v5wire.Ticket:
+			wait := time.Duration(resp.WaitTime) * time.Second
+			if wait > adLifetime {
+				return errors.New("waiting time too long")
+			}
+			ticket = resp.Ticket
+			select {
+			case <-t.clock.After(wait):
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+	}
+}
+
+// regtopic calls REGTOPIC on a node and waits for a TICKET or REGCONFIRMATION
+// response.
+func (t *UDPv5) regtopic(n *enode.Node, topic v5wire.TopicID, ticket []byte) (v5wire.Packet, error) {
+	req := &v5wire.Regtopic{Topic: topic, ENR: t.Self().Record(), Ticket: ticket}
+	resp := t.call(n, v5wire.TicketMsg, req)
+	defer t.callDone(resp)
+
+	select {
+	case p := <-resp.ch:
+		return p, nil
+	case err := <-resp.err:
+		return nil, err
+	}
+}
+
+// topicQuery calls TOPICQUERY on a node and waits for the NODES responses.
+func (t *UDPv5) topicQuery(n *enode.Node, topic v5wire.TopicID) ([]*enode.Node, error) {
+	resp := t.call(n, v5wire.NodesMsg, &v5wire.TopicQuery{Topic: topic})
+	return t.waitForNodes(resp, nil)
+}
+
+// handleRegtopic places an advertisement if the ticket presented by the node
+// has waited long enough, or hands out a new ticket otherwise.
+func (t *UDPv5) handleRegtopic(p *v5wire.Regtopic, fromID enode.ID, fromAddr *net.UDPAddr) {
+	if p.ENR == nil {
+		t.log.Debug("Missing record in "+p.Name(), "id", fromID, "addr", fromAddr)
+		return
+	}
+	n, err := enode.New(t.validSchemes, p.ENR)
+	if err != nil || n.ID() != fromID {
+		t.log.Debug("Invalid record in "+p.Name(), "id", fromID, "addr", fromAddr, "err", err)
+		return
+	}
+	if !n.IP().Equal(fromAddr.IP) {
+		t.log.Debug("Mismatching endpoint in "+p.Name(), "id", fromID, "addr", fromAddr, "enr", n.IP())
+		return
+	}
+	var waited time.Duration
+	if len(p.Ticket) > 0 {
+		tk, err := t.ads.openTicket(p.Ticket, p.Topic, fromID, fromAddr.IP)
+		if err != nil {
+			t.log.Debug("Invalid ticket in "+p.Name(), "id", fromID, "addr", fromAddr, "err", err)
+		} else {
+			waited = time.Duration(tk.Total)
+		}
+	}
+	if ticket, wait, ok := t.ads.register(p.Topic, n, waited); ok {
+		t.sendResponse(fromID, fromAddr, &v5wire.Regconfirmation{ReqID: p.ReqID, Topic: p.Topic})
+	} else {
+		secs := uint((wait + time.Second - 1) / time.Second)
+		t.sendResponse(fromID, fromAddr, &v5wire.Ticket{ReqID: p.ReqID, Ticket: ticket, WaitTime: secs})
+	}
+}
+
+// handleTopicQuery returns the nodes advertising the topic to the requester.
+func (t *UDPv5) handleTopicQuery(p *v5wire.TopicQuery, fromID enode.ID, fromAddr *net.UDPAddr) {
+	var nodes []*enode.Node
+	for _, n := range t.ads.nodes(p.Topic) {
+		if netutil.CheckRelayIP(fromAddr.IP, n.IP()) != nil {
+			continue
+		}
+		if nodes = append(nodes, n); len(nodes) >= topicQueryResultLimit {
+			break
+		}
+	}
+	for _, resp := range packNodes(p.ReqID, nodes) {
+		t.sendResponse(fromID, fromAddr, resp)
+	}
+}
+
+// topicIterator is the iterator returned by TopicNodes.
+type topicIterator struct {
+	t      *UDPv5
+	topic  v5wire.TopicID
+	ctx    context.Context
+	cancel func()
+
+	registrars []*enode.Node         // registrars left to query in the current round
+	buffer     []*enode.Node         // nodes returned by the last query
+	seen       map[enode.ID]struct{} // nodes yielded in the current round
+	lastRound  mclock.AbsTime
+}
+
+// Node returns the current node.
+func (it *topicIterator) Node() *enode.Node {
+	if len(it.buffer) == 0 {
+		return nil
+	}
+	return it.buffer[0]
+}
+
+// Next moves to the next node.
+func (it *topicIterator) Next() bool {
+	if len(it.buffer) > 0 {
+		it.buffer = it.buffer[1:]
+	}
+	for len(it.buffer) == 0 {
+		if it.ctx.Err() != nil {
+			it.buffer = nil
+			return false
+		}
+		if len(it.registrars) == 0 {
+			it.startRound()
+			continue
+		}
+		n := it.registrars[0]
+		it.registrars = it.registrars[1:]
+		nodes, err := it.t.topicQuery(n, it.topic)
+		if err != nil && !errors.Is(err, errClosed) {
+			it.t.log.Trace("TOPICQUERY failed", "id", n.ID(), "err", err)
+		}
+		for _, rn := range nodes {
+			if _, ok := it.seen[rn.ID()]; ok || rn.ID() == it.t.Self().ID() {
+				continue
+			}
+			it.seen[rn.ID()] = struct{}{}
+			it.buffer = append(it.buffer, rn)
+		}
+	}
+	return true
+}
+
+// startRound looks up the registrars of the topic, rate limiting the rounds so
+// the registrars are not queried in a tight loop.
+func (it *topicIterator) startRound() {
+	if it.lastRound != 0 {
+		if wait := topicQueryInterval - time.Duration(it.t.clock.Now()-it.lastRound); wait > 0 {
+			select {
+			case <-it.t.clock.After(wait):
+			case <-it.ctx.Done():
+				return
+			}
+		}
+	}
+	it.lastRound = it.t.clock.Now()
+	it.seen = make(map[enode.ID]struct{})
+	it.registrars = it.t.newLookup(it.ctx, enode.ID(it.topic)).run()
+}
+
+// Close ends the iterator.
+func (it *topicIterator) Close() {
+	it.cancel()
+}
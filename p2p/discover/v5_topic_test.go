@@ -0,0 +1,130 @@
+// Copyright 2022 The go-confero Authors
+// This file is part of the go-confero library.
+//
+// The go-confero library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-confero library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-confero library. If not, see <http://www.gnu.org/licenses/>.
+
+package discover
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/confero-network/go-confero/common/mclock"
+	"github.com/confero-network/go-confero/p2p/discover/v5wire"
+	"github.com/confero-network/go-confero/p2p/enode"
+)
+
+// This test checks that incoming REGTOPIC and TOPICQUERY calls are handled correctly.
+func TestUDPv5_topicHandling(t *testing.T) {
+	t.Parallel()
+	test := newUDPV5Test(t)
+	defer test.close()
+
+	clock := new(mclock.Simulated)
+	test.udp.ads.clock = clock
+	var (
+		topic  = TopicHash("test")
+		remote = test.getNode(test.remotekey, test.remoteaddr).Node()
+		ticket []byte
+	)
+
+	// The first registration attempt gets a ticket.
+	test.packetIn(&v5wire.Regtopic{ReqID: []byte{0}, Topic: topic, ENR: remote.Record()})
+	test.waitPacketOut(func(p *v5wire.Ticket, addr *net.UDPAddr, _ v5wire.Nonce) {
+		if p.WaitTime != 1 {
+			t.Fatalf("wrong waiting time %d", p.WaitTime)
+		}
+		ticket = p.Ticket
+	})
+
+	// Returning with a tampered ticket doesn't count the time waited.
+	clock.Run(time.Second)
+	bad := append([]byte{}, ticket...)
+	bad[0]++
+	test.packetIn(&v5wire.Regtopic{ReqID: []byte{1}, Topic: topic, ENR: remote.Record(), Ticket: bad})
+	test.waitPacketOut(func(p *v5wire.Ticket, addr *net.UDPAddr, _ v5wire.Nonce) {})
+
+	// Returning with the ticket places the advertisement.
+	test.packetIn(&v5wire.Regtopic{ReqID: []byte{2}, Topic: topic, ENR: remote.Record(), Ticket: ticket})
+	test.waitPacketOut(func(p *v5wire.Regconfirmation, addr *net.UDPAddr, _ v5wire.Nonce) {
+		if p.Topic != topic {
+			t.Fatalf("wrong topic in confirmation")
+		}
+	})
+
+	// Another node in the same subnet has to wait for a long time.
+	key2, addr2 := newkey(), &net.UDPAddr{IP: net.IP{10, 0, 1, 100}, Port: 30303}
+	node2 := test.getNode(key2, addr2).Node()
+	test.packetInFrom(key2, addr2, &v5wire.Regtopic{ReqID: []byte{3}, Topic: topic, ENR: node2.Record()})
+	test.waitPacketOut(func(p *v5wire.Ticket, addr *net.UDPAddr, _ v5wire.Nonce) {
+		if p.WaitTime != uint(adLifetime/time.Second) {
+			t.Fatalf("wrong waiting time %d", p.WaitTime)
+		}
+	})
+
+	// The advertisement is returned by TOPICQUERY, until it expires.
+	test.packetIn(&v5wire.TopicQuery{ReqID: []byte{4}, Topic: topic})
+	test.expectNodes([]byte{4}, 1, []*enode.Node{remote})
+	test.packetIn(&v5wire.TopicQuery{ReqID: []byte{5}, Topic: TopicHash("other")})
+	test.expectNodes([]byte{5}, 1, nil)
+
+	clock.Run(adLifetime)
+	test.packetIn(&v5wire.TopicQuery{ReqID: []byte{6}, Topic: topic})
+	test.expectNodes([]byte{6}, 1, nil)
+}
+
+// This test checks that topic registration and search work across nodes.
+func TestUDPv5_topicE2E(t *testing.T) {
+	t.Parallel()
+
+	var (
+		registrar  = startLocalhostV5(t, Config{})
+		advertiser = startLocalhostV5(t, Config{Bootnodes: []*enode.Node{registrar.Self()}})
+		searcher   = startLocalhostV5(t, Config{Bootnodes: []*enode.Node{registrar.Self()}})
+	)
+	defer registrar.Close()
+	defer advertiser.Close()
+	defer searcher.Close()
+
+	// Wait for the advertisement to be placed at the registrar.
+	advertiser.RegisterTopic("test")
+	for deadline := time.Now().Add(10 * time.Second); ; {
+		nodes, _ := searcher.topicQuery(registrar.Self(), TopicHash("test"))
+		if len(nodes) > 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("advertisement not placed")
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	it := searcher.TopicNodes("test")
+	defer it.Close()
+
+	found := make(chan *enode.Node, 1)
+	go func() {
+		if it.Next() {
+			found <- it.Node()
+		}
+	}()
+	select {
+	case n := <-found:
+		if n.ID() != advertiser.Self().ID() {
+			t.Fatalf("found wrong node %v", n.ID())
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("advertiser not found")
+	}
+}
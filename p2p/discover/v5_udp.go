@@ -76,6 +76,11 @@ type UDPv5 struct {
 	trlock     sync.Mutex
 	trhandlers map[string]TalkRequestHandler
 
+	// topic advertisement
+	topicLock sync.Mutex
+	topicRegs map[v5wire.TopicID]context.CancelFunc // topics advertised by the local node
+	ads       *adCache                              // advertisements placed by other nodes, dispatch only
+
 	// channels into dispatch
 	packetInCh    chan ReadPacket
 	readNextCh    chan struct{}
@@ -149,6 +154,8 @@ func newUDPv5(conn UDPConn, ln *enode.LocalNode, cfg Config) (*UDPv5, error) {
 		validSchemes: cfg.ValidSchemes,
 		clock:        cfg.Clock,
 		trhandlers:   make(map[string]TalkRequestHandler),
+		topicRegs:    make(map[v5wire.TopicID]context.CancelFunc),
+		ads:          newAdCache(cfg.Clock),
 		// channels into dispatch
 		packetInCh:    make(chan ReadPacket, 1),
 		readNextCh:    make(chan struct{}, 1),
@@ -676,7 +683,7 @@ func (t *UDPv5) handleCallResponse(fromID enode.ID, fromAddr *net.UDPAddr, p v5w
 		t.log.Debug(fmt.Sprintf("%s from wrong endpoint", p.Name()), "id", fromID, "addr", fromAddr)
 		return false
 	}
-	if p.Kind() != ac.responseType {
+	if p.Kind() != ac.responseType && !(ac.responseType == v5wire.TicketMsg && p.Kind() == v5wire.RegconfirmationMsg) {
 		t.log.Debug(fmt.Sprintf("Wrong discv5 response type %s", p.Name()), "id", fromID, "addr", fromAddr)
 		return false
 	}
@@ -717,6 +724,14 @@ func (t *UDPv5) handle(p v5wire.Packet, fromID enode.ID, fromAddr *net.UDPAddr)
 		t.handleTalkRequest(p, fromID, fromAddr)
 	case *v5wire.TalkResponse:
 		t.handleCallResponse(fromID, fromAddr, p)
+	case *v5wire.Regtopic:
+		t.handleRegtopic(p, fromID, fromAddr)
+	case *v5wire.Ticket:
+		t.handleCallResponse(fromID, fromAddr, p)
+	case *v5wire.Regconfirmation:
+		t.handleCallResponse(fromID, fromAddr, p)
+	case *v5wire.TopicQuery:
+		t.handleTopicQuery(p, fromID, fromAddr)
 	}
 }
 
@@ -42,9 +42,8 @@ const (
 	NodesMsg
 	TalkRequestMsg
 	TalkResponseMsg
-	RequestTicketMsg
-	TicketMsg
 	RegtopicMsg
+	TicketMsg
 	RegconfirmationMsg
 	TopicQueryMsg
 
@@ -113,38 +112,40 @@ type (
 		Message []byte
 	}
 
-	// REQUESTTICKET requests a ticket for a topic queue.
-	RequestTicket struct {
-		ReqID []byte
-		Topic []byte
-	}
-
-	// TICKET is the response to REQUESTTICKET.
-	Ticket struct {
+	// REGTOPIC requests an advertisement of the sender for a topic. The ticket
+	// is empty on the first attempt and set to the last received ticket when
+	// retrying after the waiting time.
+	Regtopic struct {
 		ReqID  []byte
+		Topic  TopicID
+		ENR    *enr.Record
 		Ticket []byte
 	}
 
-	// REGTOPIC registers the sender in a topic queue using a ticket.
-	Regtopic struct {
-		ReqID  []byte
-		Ticket []byte
-		ENR    *enr.Record
+	// TICKET is the reply to REGTOPIC if the advertisement was not placed. It
+	// holds the ticket to present after WaitTime seconds.
+	Ticket struct {
+		ReqID    []byte
+		Ticket   []byte
+		WaitTime uint
 	}
 
-	// REGCONFIRMATION is the reply to REGTOPIC.
+	// REGCONFIRMATION is the reply to REGTOPIC if the advertisement was placed.
 	Regconfirmation struct {
-		ReqID      []byte
-		Registered bool
+		ReqID []byte
+		Topic TopicID
 	}
 
-	// TOPICQUERY asks for nodes with the given topic.
+	// TOPICQUERY asks for nodes advertising the given topic. The reply is NODES.
 	TopicQuery struct {
 		ReqID []byte
-		Topic []byte
+		Topic TopicID
 	}
 )
 
+// TopicID is the hash identifying a topic.
+type TopicID [32]byte
+
 // DecodeMessage decodes the message body of a packet.
 func DecodeMessage(ptype byte, body []byte) (Packet, error) {
 	var dec Packet
@@ -161,12 +162,10 @@ func DecodeMessage(ptype byte, body []byte) (Packet, error) {
 		dec = new(TalkRequest)
 	case TalkResponseMsg:
 		dec = new(TalkResponse)
-	case RequestTicketMsg:
-		dec = new(RequestTicket)
-	case TicketMsg:
-		dec = new(Ticket)
 	case RegtopicMsg:
 		dec = new(Regtopic)
+	case TicketMsg:
+		dec = new(Ticket)
 	case RegconfirmationMsg:
 		dec = new(Regconfirmation)
 	case TopicQueryMsg:
@@ -223,11 +222,6 @@ func (*TalkResponse) Kind() byte               { return TalkResponseMsg }
 func (p *TalkResponse) RequestID() []byte      { return p.ReqID }
 func (p *TalkResponse) SetRequestID(id []byte) { p.ReqID = id }
 
-func (*RequestTicket) Name() string             { return "REQTICKET/v5" }
-func (*RequestTicket) Kind() byte               { return RequestTicketMsg }
-func (p *RequestTicket) RequestID() []byte      { return p.ReqID }
-func (p *RequestTicket) SetRequestID(id []byte) { p.ReqID = id }
-
 func (*Regtopic) Name() string             { return "REGTOPIC/v5" }
 func (*Regtopic) Kind() byte               { return RegtopicMsg }
 func (p *Regtopic) RequestID() []byte      { return p.ReqID }
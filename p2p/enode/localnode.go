@@ -64,6 +64,7 @@ type lnEndpoint struct {
 	track                *netutil.IPTracker
 	staticIP, fallbackIP net.IP
 	fallbackUDP          uint16 // port
+	noPrediction         bool   // whether the predicted endpoint is kept out of the record
 }
 
 // NewLocalNode creates a local node.
@@ -213,6 +214,18 @@ func (ln *LocalNode) SetFallbackUDP(port int) {
 	ln.updateEndpoints()
 }
 
+// SetPredictionDisabled controls whether the endpoint predicted from the statements
+// of other nodes is put into the record. When disabled, the record falls back to
+// the static or fallback endpoint. Prediction is enabled by default.
+func (ln *LocalNode) SetPredictionDisabled(disabled bool) {
+	ln.mu.Lock()
+	defer ln.mu.Unlock()
+
+	ln.endpoint4.noPrediction = disabled
+	ln.endpoint6.noPrediction = disabled
+	ln.updateEndpoints()
+}
+
 // UDPEndpointStatement should be called whenever a statement about the local node's
 // UDP endpoint is received. It feeds the local endpoint predictor.
 func (ln *LocalNode) UDPEndpointStatement(fromaddr, endpoint *net.UDPAddr) {
@@ -233,6 +246,24 @@ func (ln *LocalNode) UDPContact(toaddr *net.UDPAddr) {
 	ln.updateEndpoints()
 }
 
+// PredictedEndpoint returns the UDP endpoint of the local node predicted from the
+// endpoint statements of other nodes, preferring IPv4. It returns nil if there
+// are not enough statements to make a prediction.
+func (ln *LocalNode) PredictedEndpoint() *net.UDPAddr {
+	ln.mu.Lock()
+	defer ln.mu.Unlock()
+
+	for _, e := range []*lnEndpoint{&ln.endpoint4, &ln.endpoint6} {
+		if ip, port := predictAddr(e.track); ip != nil {
+			if ip4 := ip.To4(); ip4 != nil {
+				ip = ip4
+			}
+			return &net.UDPAddr{IP: ip, Port: int(port)}
+		}
+	}
+	return nil
+}
+
 // updateEndpoints updates the record with predicted endpoints.
 func (ln *LocalNode) updateEndpoints() {
 	ip4, udp4 := ln.endpoint4.get()
@@ -268,6 +299,8 @@ func (e *lnEndpoint) get() (newIP net.IP, newPort uint16) {
 	}
 	if e.staticIP != nil {
 		newIP = e.staticIP
+	} else if e.noPrediction {
+		return newIP, newPort
 	} else if ip, port := predictAddr(e.track); ip != nil {
 		newIP = ip
 		newPort = port
@@ -108,6 +108,7 @@ func TestLocalNodeEndpoint(t *testing.T) {
 	assert.Equal(t, initialSeq+1, ln.Node().Seq())
 
 	// Add endpoint statements from random hosts.
+	assert.Nil(t, ln.PredictedEndpoint())
 	for i := 0; i < iptrackMinStatements; i++ {
 		assert.Equal(t, fallback.IP, ln.Node().IP())
 		assert.Equal(t, fallback.Port, ln.Node().UDP())
@@ -120,6 +121,7 @@ func TestLocalNodeEndpoint(t *testing.T) {
 	assert.Equal(t, predicted.IP, ln.Node().IP())
 	assert.Equal(t, predicted.Port, ln.Node().UDP())
 	assert.Equal(t, initialSeq+2, ln.Node().Seq())
+	assert.Equal(t, predicted, ln.PredictedEndpoint())
 
 	// Static IP overrides prediction.
 	ln.SetStaticIP(staticIP)
@@ -21,6 +21,7 @@ import (
 	"crypto/rand"
 	"encoding/binary"
 	"fmt"
+	"math"
 	"net"
 	"os"
 	"sync"
@@ -40,6 +41,7 @@ const (
 	dbVersionKey   = "version" // Version of the database to flush if changes
 	dbNodePrefix   = "n:"      // Identifier to prefix node entries with
 	dbLocalPrefix  = "local:"
+	dbRepPrefix    = "rep:" // Identifier to prefix node reputation entries with
 	dbDiscoverRoot = "v4"
 	dbDiscv5Root   = "v5"
 
@@ -427,6 +429,54 @@ func (db *DB) UpdateFindFailsV5(id ID, ip net.IP, fails int) error {
 	return db.storeInt64(v5Key(id, ip, dbNodeFindFails), int64(fails))
 }
 
+// nodeReputation is the database encoding of a node's reputation.
+type nodeReputation struct {
+	Score       uint64 // IEEE 754 bits of the score
+	Updated     uint64 // Unix time of the last score update
+	BannedUntil uint64 // Unix time of the end of the ban, zero if not banned
+}
+
+// NodeReputation retrieves the reputation stored for a node: its score, the time
+// of its last update and the end of the ban on the node, if any.
+func (db *DB) NodeReputation(id ID) (score float64, updated, bannedUntil time.Time) {
+	blob, err := db.lvl.Get(append([]byte(dbRepPrefix), id[:]...), nil)
+	if err != nil {
+		return 0, time.Time{}, time.Time{}
+	}
+	var rep nodeReputation
+	if err := rlp.DecodeBytes(blob, &rep); err != nil {
+		return 0, time.Time{}, time.Time{}
+	}
+	return math.Float64frombits(rep.Score), unixTime(rep.Updated), unixTime(rep.BannedUntil)
+}
+
+// UpdateNodeReputation stores the reputation of a node. Neutral entries, having
+// a zero score and no ban, are deleted instead.
+func (db *DB) UpdateNodeReputation(id ID, score float64, updated, bannedUntil time.Time) error {
+	key := append([]byte(dbRepPrefix), id[:]...)
+	if score == 0 && bannedUntil.IsZero() {
+		return db.lvl.Delete(key, nil)
+	}
+	rep := nodeReputation{Score: math.Float64bits(score), Updated: uint64(updated.Unix())}
+	if !bannedUntil.IsZero() {
+		rep.BannedUntil = uint64(bannedUntil.Unix())
+	}
+	blob, err := rlp.EncodeToBytes(&rep)
+	if err != nil {
+		return err
+	}
+	return db.lvl.Put(key, blob, nil)
+}
+
+// unixTime converts a stored unix timestamp into a time, mapping zero to the
+// zero time.
+func unixTime(t uint64) time.Time {
+	if t == 0 {
+		return time.Time{}
+	}
+	return time.Unix(int64(t), 0)
+}
+
 // localSeq retrieves the local record sequence counter, defaulting to the current
 // timestamp if no previous exists. This ensures that wiping all data associated
 // with a node (apart from its key) will not generate already used sequence nums.
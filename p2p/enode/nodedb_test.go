@@ -467,3 +467,34 @@ func TestDBExpireV5(t *testing.T) {
 	db.UpdateFindFailsV5(ID{}, ip, 4)
 	db.expireNodes()
 }
+
+func TestDBReputation(t *testing.T) {
+	db, _ := OpenDB("")
+	defer db.Close()
+
+	id := ID{1}
+	if score, updated, banned := db.NodeReputation(id); score != 0 || !updated.IsZero() || !banned.IsZero() {
+		t.Errorf("non-existing reputation: score %v, updated %v, banned %v", score, updated, banned)
+	}
+	var (
+		now   = time.Unix(1000, 0)
+		until = time.Unix(2000, 0)
+	)
+	if err := db.UpdateNodeReputation(id, -12.5, now, until); err != nil {
+		t.Fatalf("failed to update reputation: %v", err)
+	}
+	// The reputation entries must survive expiration and not trip up seeding
+	db.expireNodes()
+	db.QuerySeeds(1, time.Hour)
+
+	if score, updated, banned := db.NodeReputation(id); score != -12.5 || !updated.Equal(now) || !banned.Equal(until) {
+		t.Errorf("reputation mismatch: score %v, updated %v, banned %v", score, updated, banned)
+	}
+	// Neutral reputations should be deleted
+	if err := db.UpdateNodeReputation(id, 0, now, time.Time{}); err != nil {
+		t.Fatalf("failed to update reputation: %v", err)
+	}
+	if ok, _ := db.lvl.Has(append([]byte(dbRepPrefix), id[:]...), nil); ok {
+		t.Errorf("neutral reputation not deleted")
+	}
+}
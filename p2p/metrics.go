@@ -20,8 +20,11 @@ package p2p
 
 import (
 	"net"
+	"sync"
+	"sync/atomic"
 
 	"github.com/confero-network/go-confero/metrics"
+	"github.com/confero-network/go-confero/p2p/enode"
 )
 
 const (
@@ -41,21 +44,35 @@ var (
 	egressConnectMeter  = metrics.NewRegisteredMeter("p2p/dials", nil)
 	egressTrafficMeter  = metrics.NewRegisteredMeter(egressMeterName, nil)
 	activePeerGauge     = metrics.NewRegisteredGauge("p2p/peers", nil)
+	throttleTimer       = metrics.NewRegisteredTimer("p2p/throttle", nil)
+
+	// peerIngressRegistry and peerEgressRegistry hold the traffic meters of the
+	// connected peers, named by node ID.
+	peerIngressRegistry = metrics.NewPrefixedChildRegistry(metrics.DefaultRegistry, ingressMeterName+"/peers/")
+	peerEgressRegistry  = metrics.NewPrefixedChildRegistry(metrics.DefaultRegistry, egressMeterName+"/peers/")
 )
 
 // meteredConn is a wrapper around a net.Conn that meters both the
-// inbound and outbound network traffic.
+// inbound and outbound network traffic. The traffic of the connection
+// is counted even if the metrics system is disabled.
 type meteredConn struct {
 	net.Conn
+
+	ingress uint64 // Total bytes read (atomic access)
+	egress  uint64 // Total bytes written (atomic access)
+
+	lock        sync.Mutex
+	id          string        // Node ID of the peer, empty until the handshake is done
+	peerIngress metrics.Meter // Ingress meter of the peer, nil until the handshake is done
+	peerEgress  metrics.Meter // Egress meter of the peer, nil until the handshake is done
 }
 
 // newMeteredConn creates a new metered connection, bumps the ingress or egress
-// connection meter and also increases the metered peer count. If the metrics
-// system is disabled, function returns the original connection.
+// connection meter and also increases the metered peer count.
 func newMeteredConn(conn net.Conn, ingress bool, addr *net.TCPAddr) net.Conn {
-	// Short circuit if metrics are disabled
+	// If metrics are disabled, only count the traffic of the connection
 	if !metrics.Enabled {
-		return conn
+		return &meteredConn{Conn: conn}
 	}
 	// Bump the connection counters and wrap the connection
 	if ingress {
@@ -71,7 +88,11 @@ func newMeteredConn(conn net.Conn, ingress bool, addr *net.TCPAddr) net.Conn {
 // and the peer ingress traffic meters along the way.
 func (c *meteredConn) Read(b []byte) (n int, err error) {
 	n, err = c.Conn.Read(b)
+	atomic.AddUint64(&c.ingress, uint64(n))
 	ingressTrafficMeter.Mark(int64(n))
+	if c.peerIngress != nil {
+		c.peerIngress.Mark(int64(n))
+	}
 	return n, err
 }
 
@@ -79,7 +100,11 @@ func (c *meteredConn) Read(b []byte) (n int, err error) {
 // and the peer egress traffic meters along the way.
 func (c *meteredConn) Write(b []byte) (n int, err error) {
 	n, err = c.Conn.Write(b)
+	atomic.AddUint64(&c.egress, uint64(n))
 	egressTrafficMeter.Mark(int64(n))
+	if c.peerEgress != nil {
+		c.peerEgress.Mark(int64(n))
+	}
 	return n, err
 }
 
@@ -87,8 +112,36 @@ func (c *meteredConn) Write(b []byte) (n int, err error) {
 // the peer from the traffic registries and emits close event.
 func (c *meteredConn) Close() error {
 	err := c.Conn.Close()
-	if err == nil {
+	if err == nil && metrics.Enabled {
 		activePeerGauge.Dec(1)
 	}
+	c.lock.Lock()
+	if c.id != "" {
+		peerIngressRegistry.Unregister(c.id)
+		peerEgressRegistry.Unregister(c.id)
+		c.id = ""
+	}
+	c.lock.Unlock()
 	return err
 }
+
+// handshakeDone is called after the handshakes of the connection are done,
+// registering the traffic meters of the peer. It must be called before the
+// connection is used by other goroutines.
+func (c *meteredConn) handshakeDone(id enode.ID) {
+	if !metrics.Enabled {
+		return
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.id = id.String()
+	c.peerIngress = metrics.GetOrRegisterMeter(c.id, peerIngressRegistry)
+	c.peerEgress = metrics.GetOrRegisterMeter(c.id, peerEgressRegistry)
+}
+
+// traffic returns the total number of bytes read from and written to the
+// connection.
+func (c *meteredConn) traffic() (ingress, egress uint64) {
+	return atomic.LoadUint64(&c.ingress), atomic.LoadUint64(&c.egress)
+}
@@ -59,6 +59,7 @@ type Interface interface {
 //     "upnp"               uses the Universal Plug and Play protocol
 //     "pmp"                uses NAT-PMP with an auto-detected gateway address
 //     "pmp:192.168.0.1"    uses NAT-PMP with the given gateway address
+//     "discover"           predicts the external endpoint from node discovery
 func Parse(spec string) (Interface, error) {
 	var (
 		parts = strings.SplitN(spec, ":", 2)
@@ -85,6 +86,8 @@ func Parse(spec string) (Interface, error) {
 		return UPnP(), nil
 	case "pmp", "natpmp", "nat-pmp":
 		return PMP(ip), nil
+	case "discover":
+		return Discover{}, nil
 	default:
 		return nil, fmt.Errorf("unknown mechanism %q", parts[0])
 	}
@@ -138,6 +141,19 @@ func (n ExtIP) String() string              { return fmt.Sprintf("ExtIP(%v)", ne
 func (ExtIP) AddMapping(string, int, int, string, time.Duration) error { return nil }
 func (ExtIP) DeleteMapping(string, int, int) error                     { return nil }
 
+// Discover doesn't talk to the router. Instead, the external IP and UDP port are
+// predicted from the endpoints reported by other nodes in discovery, and the
+// user of the interface is expected to check TCP reachability by dialing itself.
+type Discover struct{}
+
+func (Discover) ExternalIP() (net.IP, error) { return nil, errors.New("external IP is discovered") }
+func (Discover) String() string              { return "Discover" }
+
+// These do nothing.
+
+func (Discover) AddMapping(string, int, int, string, time.Duration) error { return nil }
+func (Discover) DeleteMapping(string, int, int) error                     { return nil }
+
 // Any returns a port mapper that tries to discover any supported
 // mechanism on the local network.
 func Any() Interface {
@@ -0,0 +1,179 @@
+// Copyright 2022 The go-confero Authors
+// This file is part of the go-confero library.
+//
+// The go-confero library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-confero library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-confero library. If not, see <http://www.gnu.org/licenses/>.
+
+package p2p
+
+import (
+	"net"
+	"sync"
+	"time"
+
+	"github.com/confero-network/go-confero/common/mclock"
+	"github.com/confero-network/go-confero/crypto"
+	"github.com/confero-network/go-confero/p2p/enr"
+	"github.com/confero-network/go-confero/p2p/rlpx"
+)
+
+const (
+	natPollInterval    = 30 * time.Second // interval of checking for a new predicted endpoint
+	natRecheckInterval = 30 * time.Minute // interval of repeating the reachability check
+	natDialTimeout     = 10 * time.Second
+)
+
+// NAT reachability states reported in NATStatus.
+const (
+	NATPending     = "pending"     // no external endpoint predicted yet
+	NATReachable   = "reachable"   // the external TCP endpoint accepted a self-dial
+	NATUnreachable = "unreachable" // the self-dial failed on all candidate ports
+)
+
+// NATStatus describes the external endpoint detected in "discover" NAT mode.
+type NATStatus struct {
+	Status    string     `json:"status"`
+	IP        string     `json:"ip,omitempty"`
+	TCP       int        `json:"tcp,omitempty"`
+	UDP       int        `json:"udp,omitempty"`
+	LastCheck *time.Time `json:"lastCheck,omitempty"`
+}
+
+// natDetector detects the external endpoint of the server. The IP and UDP port
+// are predicted by the local node from the endpoints reported by other nodes
+// in discv4 and discv5. The detector validates the prediction by dialing the
+// external IP over TCP and performing the encryption handshake with itself,
+// which only succeeds if the connection arrives at the local listener. The TCP
+// port found reachable is put into the local node record. While the node is
+// unreachable, the record has neither the TCP port nor the predicted IP.
+type natDetector struct {
+	srv        *Server
+	listenPort int
+
+	mu      sync.Mutex
+	status  NATStatus
+	checked mclock.AbsTime // time of the last check, set along with the status
+	dialing net.IP         // IP of an ongoing self-dial
+}
+
+func newNATDetector(srv *Server, listenPort int) *natDetector {
+	return &natDetector{
+		srv:        srv,
+		listenPort: listenPort,
+		status:     NATStatus{Status: NATPending, TCP: listenPort},
+	}
+}
+
+// loop runs the detector until the server is stopped.
+func (d *natDetector) loop() {
+	defer d.srv.loopWG.Done()
+
+	var (
+		last     *net.UDPAddr
+		lastTime mclock.AbsTime
+	)
+	for {
+		ep := d.srv.localnode.PredictedEndpoint()
+		if ep != nil && (last == nil || !ep.IP.Equal(last.IP) || ep.Port != last.Port || d.srv.clock.Now()-lastTime >= mclock.AbsTime(natRecheckInterval)) {
+			d.check(ep)
+			last, lastTime = ep, d.srv.clock.Now()
+		}
+		select {
+		case <-d.srv.clock.After(natPollInterval):
+		case <-d.srv.quit:
+			return
+		}
+	}
+}
+
+// check tests TCP reachability on the predicted IP. The listening port and the
+// predicted UDP port are tried, covering manually forwarded ports and NATs
+// which preserve the port of outgoing traffic. If none of them is reachable,
+// the TCP port and the predicted IP are removed from the local node record.
+func (d *natDetector) check(ep *net.UDPAddr) {
+	candidates := []int{d.listenPort}
+	if ep.Port != d.listenPort && ep.Port != 0 {
+		candidates = append(candidates, ep.Port)
+	}
+	d.mu.Lock()
+	d.dialing = ep.IP
+	d.mu.Unlock()
+
+	status := NATStatus{Status: NATUnreachable, IP: ep.IP.String(), TCP: d.listenPort, UDP: ep.Port}
+	for _, port := range candidates {
+		addr := &net.TCPAddr{IP: ep.IP, Port: port}
+		err := d.dialSelf(addr)
+		if err == nil {
+			status.Status, status.TCP = NATReachable, port
+			break
+		}
+		d.srv.log.Debug("NAT self-dial failed", "addr", addr, "err", err)
+	}
+	if status.Status == NATReachable {
+		d.srv.localnode.SetPredictionDisabled(false)
+		d.srv.localnode.Set(enr.TCP(status.TCP))
+	} else {
+		d.srv.localnode.Delete(enr.TCP(0))
+		d.srv.localnode.SetPredictionDisabled(true)
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.status.Status != status.Status || d.status.TCP != status.TCP {
+		d.srv.log.Info("Detected NAT status", "status", status.Status, "ip", status.IP, "tcp", status.TCP, "udp", status.UDP)
+	}
+	d.status = status
+	d.checked = d.srv.clock.Now()
+	d.dialing = nil
+}
+
+// dialSelf connects to the given address and performs the encryption handshake
+// with the local node key.
+func (d *natDetector) dialSelf(addr *net.TCPAddr) error {
+	fd, err := net.DialTimeout("tcp", addr.String(), natDialTimeout)
+	if err != nil {
+		return err
+	}
+	defer fd.Close()
+	fd.SetDeadline(time.Now().Add(natDialTimeout))
+
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		return err
+	}
+	_, err = rlpx.NewConn(fd, &d.srv.PrivateKey.PublicKey).Handshake(key)
+	return err
+}
+
+// isSelfDial reports whether an inbound connection from ip may be a self-dial.
+func (d *natDetector) isSelfDial(ip net.IP) bool {
+	if d == nil {
+		return false
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	return d.dialing != nil && d.dialing.Equal(ip)
+}
+
+// Status returns the current NAT status.
+func (d *natDetector) Status() *NATStatus {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	status := d.status
+	if status.Status != NATPending {
+		last := time.Now().Add(-time.Duration(d.srv.clock.Now() - d.checked))
+		status.LastCheck = &last
+	}
+	return &status
+}
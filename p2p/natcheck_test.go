@@ -0,0 +1,109 @@
+// Copyright 2022 The go-confero Authors
+// This file is part of the go-confero library.
+//
+// The go-confero library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-confero library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-confero library. If not, see <http://www.gnu.org/licenses/>.
+
+package p2p
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/confero-network/go-confero/common/mclock"
+	"github.com/confero-network/go-confero/internal/testlog"
+	"github.com/confero-network/go-confero/log"
+	"github.com/confero-network/go-confero/p2p/nat"
+)
+
+func TestNATDetector(t *testing.T) {
+	clock := new(mclock.Simulated)
+	srv := &Server{Config: Config{
+		PrivateKey:  newkey(),
+		MaxPeers:    10,
+		NoDial:      true,
+		NoDiscovery: true,
+		ListenAddr:  "127.0.0.1:0",
+		NAT:         nat.Discover{},
+		Logger:      testlog.Logger(t, log.LvlTrace),
+		clock:       clock,
+	}}
+	if err := srv.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer srv.Stop()
+
+	listenPort := srv.listener.Addr().(*net.TCPAddr).Port
+	if info := srv.NodeInfo(); info.NAT == nil || info.NAT.Status != NATPending {
+		t.Fatalf("wrong initial status: %+v", info.NAT)
+	}
+	if srv.Self().TCP() != 0 {
+		t.Fatalf("TCP port in record before check: %d", srv.Self().TCP())
+	}
+
+	// The listener is reachable on the predicted IP.
+	srv.nat.check(&net.UDPAddr{IP: net.IP{127, 0, 0, 1}, Port: 40000})
+	status := srv.NodeInfo().NAT
+	if status.Status != NATReachable || status.TCP != listenPort || status.UDP != 40000 || status.LastCheck == nil {
+		t.Fatalf("wrong status after successful check: %+v", status)
+	}
+	if srv.Self().TCP() != listenPort {
+		t.Fatalf("wrong TCP port in record: %d", srv.Self().TCP())
+	}
+	clock.Run(time.Minute)
+	if status := srv.NodeInfo().NAT; time.Since(*status.LastCheck) < time.Minute {
+		t.Fatalf("wrong time of last check: %v", status.LastCheck)
+	}
+
+	// Nothing listens on another IP, the port and the predicted IP are removed
+	// from the record.
+	predicted := &net.UDPAddr{IP: net.IP{127, 0, 0, 2}, Port: 40000}
+	for i := 0; i < 10; i++ {
+		srv.localnode.UDPEndpointStatement(&net.UDPAddr{IP: net.IP{10, 0, 0, byte(i)}, Port: 30303}, predicted)
+	}
+	if !srv.Self().IP().Equal(predicted.IP) {
+		t.Fatalf("predicted IP not in record: %v", srv.Self().IP())
+	}
+	srv.nat.check(predicted)
+	if status := srv.NodeInfo().NAT; status.Status != NATUnreachable || status.IP != "127.0.0.2" {
+		t.Fatalf("wrong status after failed check: %+v", status)
+	}
+	if srv.Self().TCP() != 0 {
+		t.Fatalf("TCP port in record after failed check: %d", srv.Self().TCP())
+	}
+	if srv.Self().IP().Equal(predicted.IP) {
+		t.Fatal("predicted IP in record after failed check")
+	}
+
+	// A listener which isn't the local node doesn't count.
+	other, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer other.Close()
+	go func() {
+		for {
+			fd, err := other.Accept()
+			if err != nil {
+				return
+			}
+			fd.Close()
+		}
+	}()
+	d := newNATDetector(srv, other.Addr().(*net.TCPAddr).Port)
+	d.check(&net.UDPAddr{IP: net.IP{127, 0, 0, 1}, Port: 40000})
+	if status := d.Status(); status.Status != NATUnreachable {
+		t.Fatalf("foreign listener accepted: %+v", status)
+	}
+}
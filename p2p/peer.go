@@ -117,6 +117,10 @@ type Peer struct {
 	// events receives message send / receive events if set
 	events   *event.Feed
 	testPipe *MsgPipeRW // for testing
+
+	reputation *Reputation    // reputation tracker of the server, nil if not tracked
+	capture    *CaptureWriter // traffic capture of the server, nil if not capturing
+	evicted    bool           // set if disconnected to make room, only accessed by Server.run
 }
 
 // NewPeer returns a peer for testing purposes.
@@ -238,6 +242,20 @@ func newPeer(log log.Logger, conn *conn, protocols []Protocol) *Peer {
 	return p
 }
 
+// Report adjusts the reputation score of the peer by the given amount, see the
+// Score constants for the common behaviours. A peer whose score falls below the
+// ban threshold is disconnected and temporarily banned, unless it's trusted or
+// static.
+func (p *Peer) Report(score float64) {
+	if p.reputation == nil {
+		return
+	}
+	if p.reputation.report(p.ID(), score) && !p.rw.is(trustedConn) && !p.rw.is(staticDialedConn) {
+		p.log.Debug("Banning peer with bad reputation", "score", p.reputation.Score(p.ID()))
+		p.Disconnect(DiscUselessPeer)
+	}
+}
+
 func (p *Peer) Log() log.Logger {
 	return p.log
 }
@@ -406,6 +424,9 @@ func (p *Peer) startProtocols(writeStart <-chan struct{}, writeErr chan<- error)
 		proto.wstart = writeStart
 		proto.werr = writeErr
 		var rw MsgReadWriter = proto
+		if p.capture != nil {
+			rw = newMsgCapturer(rw, p.capture, p.ID(), proto.Name, proto.Version)
+		}
 		if p.events != nil {
 			rw = newMsgEventer(rw, p.events, p.ID(), proto.Name, p.Info().Network.RemoteAddress, p.Info().Network.LocalAddress)
 		}
@@ -495,6 +516,8 @@ type PeerInfo struct {
 		Static        bool   `json:"static"`
 	} `json:"network"`
 	Protocols map[string]interface{} `json:"protocols"` // Sub-protocol specific metadata fields
+	Score     float64                `json:"score"`     // Reputation score of the peer
+	Traffic   *PeerTraffic           `json:"traffic"`   // Traffic exchanged with the peer
 }
 
 // Info gathers and returns a collection of metadata known about a peer.
@@ -520,6 +543,16 @@ func (p *Peer) Info() *PeerInfo {
 	info.Network.Inbound = p.rw.is(inboundConn)
 	info.Network.Trusted = p.rw.is(trustedConn)
 	info.Network.Static = p.rw.is(staticDialedConn)
+	if p.reputation != nil {
+		info.Score = p.reputation.Score(p.ID())
+	}
+	info.Traffic = new(PeerTraffic)
+	if mc, ok := p.rw.fd.(*meteredConn); ok {
+		info.Traffic.Ingress, info.Traffic.Egress = mc.traffic()
+	}
+	if counter, ok := p.rw.transport.(msgTrafficCounter); ok {
+		info.Traffic.Protocols = protocolTraffic(counter.msgTraffic(), p.running)
+	}
 
 	// Gather all the running protocol infos
 	for _, proto := range p.running {
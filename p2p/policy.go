@@ -0,0 +1,235 @@
+// Copyright 2022 The go-confero Authors
+// This file is part of the go-confero library.
+//
+// The go-confero library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-confero library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-confero library. If not, see <http://www.gnu.org/licenses/>.
+
+package p2p
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"regexp"
+
+	"github.com/confero-network/go-confero/common/hexutil"
+	"github.com/confero-network/go-confero/p2p/enode"
+	"github.com/confero-network/go-confero/p2p/enr"
+	"github.com/confero-network/go-confero/p2p/netutil"
+	"github.com/confero-network/go-confero/rlp"
+)
+
+// PeerPolicy is a set of rules deciding which remote nodes may become peers. The
+// rules apply to dynamically dialed and inbound connections. Static and trusted
+// nodes are exempt from them.
+type PeerPolicy struct {
+	// RequiredENRKeys lists the keys which must be present in the node record.
+	RequiredENRKeys []string `json:"requiredENRKeys,omitempty" toml:",omitempty"`
+
+	// ForkIDs lists the accepted fork hashes in the "eth" entry of the node
+	// record, as hex strings. If set, nodes without the entry are rejected.
+	ForkIDs []string `json:"forkIDs,omitempty" toml:",omitempty"`
+
+	// ClientNames lists regular expressions of which the client name of the peer
+	// must match at least one. DenyClientNames lists expressions of which the
+	// name must match none.
+	ClientNames     []string `json:"clientNames,omitempty" toml:",omitempty"`
+	DenyClientNames []string `json:"denyClientNames,omitempty" toml:",omitempty"`
+
+	// Protocols restricts the IP networks allowed to run a protocol, keyed by
+	// protocol name. Peers not allowed to run any protocol are rejected.
+	Protocols map[string]ProtocolPolicy `json:"protocols,omitempty" toml:",omitempty"`
+
+	// MaxPeersPerSubnet limits the number of peers in the same /24 (IPv4)
+	// or /64 (IPv6) subnet. Zero means no limit.
+	MaxPeersPerSubnet int `json:"maxPeersPerSubnet,omitempty" toml:",omitempty"`
+}
+
+// ProtocolPolicy restricts the IP networks allowed to run a protocol, given as
+// CIDR masks. Nodes need to be contained in Allow, if set, and must not be
+// contained in Deny.
+type ProtocolPolicy struct {
+	Allow []string `json:"allow,omitempty" toml:",omitempty"`
+	Deny  []string `json:"deny,omitempty" toml:",omitempty"`
+}
+
+var (
+	errPolicyENRKey   = errors.New("missing required ENR entry")
+	errPolicyNoRecord = errors.New("node record unavailable")
+	errPolicyForkID   = errors.New("fork ID not accepted")
+	errPolicySubnet   = errors.New("too many peers in subnet")
+	errPolicyNetwork  = errors.New("not allowed to run any protocol")
+)
+
+// peerPolicy is a compiled PeerPolicy.
+type peerPolicy struct {
+	PeerPolicy
+
+	forkIDs   map[[4]byte]bool
+	names     []*regexp.Regexp
+	denyNames []*regexp.Regexp
+	protocols map[string]protocolNets
+}
+
+type protocolNets struct {
+	allow, deny *netutil.Netlist
+}
+
+// compilePeerPolicy validates and compiles a policy.
+func compilePeerPolicy(policy PeerPolicy) (*peerPolicy, error) {
+	p := &peerPolicy{PeerPolicy: policy, protocols: make(map[string]protocolNets)}
+	if len(policy.ForkIDs) > 0 {
+		p.forkIDs = make(map[[4]byte]bool)
+		for _, s := range policy.ForkIDs {
+			b, err := hexutil.Decode(s)
+			if err != nil || len(b) != 4 {
+				return nil, fmt.Errorf("invalid fork ID %q", s)
+			}
+			var hash [4]byte
+			copy(hash[:], b)
+			p.forkIDs[hash] = true
+		}
+	}
+	var err error
+	if p.names, err = compilePatterns(policy.ClientNames); err != nil {
+		return nil, err
+	}
+	if p.denyNames, err = compilePatterns(policy.DenyClientNames); err != nil {
+		return nil, err
+	}
+	for name, pp := range policy.Protocols {
+		var nets protocolNets
+		if nets.allow, err = parseNetlist(pp.Allow); err != nil {
+			return nil, fmt.Errorf("protocol %s: %v", name, err)
+		}
+		if nets.deny, err = parseNetlist(pp.Deny); err != nil {
+			return nil, fmt.Errorf("protocol %s: %v", name, err)
+		}
+		p.protocols[name] = nets
+	}
+	if policy.MaxPeersPerSubnet < 0 {
+		return nil, errors.New("negative subnet peer limit")
+	}
+	return p, nil
+}
+
+func compilePatterns(patterns []string) ([]*regexp.Regexp, error) {
+	var res []*regexp.Regexp
+	for _, s := range patterns {
+		re, err := regexp.Compile(s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid client name pattern %q: %v", s, err)
+		}
+		res = append(res, re)
+	}
+	return res, nil
+}
+
+func parseNetlist(cidrs []string) (*netutil.Netlist, error) {
+	if len(cidrs) == 0 {
+		return nil, nil
+	}
+	list := new(netutil.Netlist)
+	for _, cidr := range cidrs {
+		if _, _, err := net.ParseCIDR(cidr); err != nil {
+			return nil, err
+		}
+		list.Add(cidr)
+	}
+	return list, nil
+}
+
+// checksRecord reports whether the policy has any ENR rules.
+func (p *peerPolicy) checksRecord() bool {
+	return len(p.RequiredENRKeys) > 0 || p.forkIDs != nil
+}
+
+// checkRecord checks the node record against the ENR rules.
+func (p *peerPolicy) checkRecord(n *enode.Node) error {
+	for _, key := range p.RequiredENRKeys {
+		var value rlp.RawValue
+		if err := n.Load(enr.WithEntry(key, &value)); err != nil {
+			return fmt.Errorf("%w %q", errPolicyENRKey, key)
+		}
+	}
+	if p.forkIDs != nil {
+		var entry struct {
+			ForkID struct {
+				Hash [4]byte
+				Next uint64
+			}
+			Rest []rlp.RawValue `rlp:"tail"`
+		}
+		if err := n.Load(enr.WithEntry("eth", &entry)); err != nil {
+			return fmt.Errorf("%w \"eth\"", errPolicyENRKey)
+		}
+		if !p.forkIDs[entry.ForkID.Hash] {
+			return errPolicyForkID
+		}
+	}
+	return nil
+}
+
+// checkName checks the client name of a peer against the name rules.
+func (p *peerPolicy) checkName(name string) error {
+	for _, re := range p.denyNames {
+		if re.MatchString(name) {
+			return fmt.Errorf("client name %q denied", name)
+		}
+	}
+	if len(p.names) == 0 {
+		return nil
+	}
+	for _, re := range p.names {
+		if re.MatchString(name) {
+			return nil
+		}
+	}
+	return fmt.Errorf("client name %q not allowed", name)
+}
+
+// allowedProtocols returns the protocols which a node with the given IP may run.
+func (p *peerPolicy) allowedProtocols(ip net.IP, protocols []Protocol) []Protocol {
+	if len(p.protocols) == 0 || ip == nil {
+		return protocols
+	}
+	allowed := make([]Protocol, 0, len(protocols))
+	for _, proto := range protocols {
+		nets := p.protocols[proto.Name]
+		if nets.allow != nil && !nets.allow.Contains(ip) {
+			continue
+		}
+		if nets.deny != nil && nets.deny.Contains(ip) {
+			continue
+		}
+		allowed = append(allowed, proto)
+	}
+	return allowed
+}
+
+// checkSubnet returns an error if a node with the given IP would exceed the
+// subnet limit, given the number of peers already connected in its subnet.
+func (p *peerPolicy) checkSubnet(peers int) error {
+	if p.MaxPeersPerSubnet > 0 && peers >= p.MaxPeersPerSubnet {
+		return errPolicySubnet
+	}
+	return nil
+}
+
+// subnetKey returns the /24 (IPv4) or /64 (IPv6) subnet of an IP.
+func subnetKey(ip net.IP) string {
+	if ip4 := ip.To4(); ip4 != nil {
+		return ip4.Mask(net.CIDRMask(24, 32)).String()
+	}
+	return ip.Mask(net.CIDRMask(64, 128)).String()
+}
@@ -0,0 +1,152 @@
+// Copyright 2022 The go-confero Authors
+// This file is part of the go-confero library.
+//
+// The go-confero library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-confero library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-confero library. If not, see <http://www.gnu.org/licenses/>.
+
+package p2p
+
+import (
+	"errors"
+	"net"
+	"testing"
+
+	"github.com/confero-network/go-confero/p2p/enode"
+	"github.com/confero-network/go-confero/p2p/enr"
+)
+
+func TestPeerPolicyRecord(t *testing.T) {
+	policy, err := compilePeerPolicy(PeerPolicy{
+		RequiredENRKeys: []string{"snap"},
+		ForkIDs:         []string{"0x01020304"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	type forkID struct {
+		Hash [4]byte
+		Next uint64
+	}
+	type ethEntry struct {
+		ForkID forkID
+	}
+	newNode := func(entries ...enr.Entry) *enode.Node {
+		var r enr.Record
+		for _, e := range entries {
+			r.Set(e)
+		}
+		if err := enode.SignV4(&r, newkey()); err != nil {
+			t.Fatal(err)
+		}
+		n, err := enode.New(enode.ValidSchemes, &r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return n
+	}
+	var (
+		snap  = enr.WithEntry("snap", []interface{}{})
+		good  = enr.WithEntry("eth", ethEntry{forkID{Hash: [4]byte{1, 2, 3, 4}}})
+		wrong = enr.WithEntry("eth", ethEntry{forkID{Hash: [4]byte{4, 3, 2, 1}}})
+	)
+	tests := []struct {
+		node *enode.Node
+		err  error
+	}{
+		{newNode(snap, good), nil},
+		{newNode(good), errPolicyENRKey},
+		{newNode(snap), errPolicyENRKey},
+		{newNode(snap, wrong), errPolicyForkID},
+	}
+	for i, test := range tests {
+		if err := policy.checkRecord(test.node); !errors.Is(err, test.err) {
+			t.Errorf("test %d: wrong error %v, want %v", i, err, test.err)
+		}
+	}
+}
+
+func TestPeerPolicyName(t *testing.T) {
+	policy, err := compilePeerPolicy(PeerPolicy{
+		ClientNames:     []string{"^Gcofe/", "^Confero/"},
+		DenyClientNames: []string{"v0\\.9\\."},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, ok := range map[string]bool{
+		"Gcofe/v1.0.0/linux":   true,
+		"Confero/v2.1.0":       true,
+		"Gcofe/v0.9.1/linux":   false,
+		"OtherClient/v1.0.0/x": false,
+	} {
+		if err := policy.checkName(name); (err == nil) != ok {
+			t.Errorf("name %q: wrong result %v", name, err)
+		}
+	}
+}
+
+func TestPeerPolicyProtocols(t *testing.T) {
+	policy, err := compilePeerPolicy(PeerPolicy{
+		Protocols: map[string]ProtocolPolicy{
+			"snap": {Allow: []string{"10.0.0.0/8"}},
+			"les":  {Deny: []string{"10.1.0.0/16"}},
+		},
+		MaxPeersPerSubnet: 2,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	protocols := []Protocol{{Name: "eth"}, {Name: "snap"}, {Name: "les"}}
+	names := func(ip string) (res []string) {
+		for _, p := range policy.allowedProtocols(net.ParseIP(ip), protocols) {
+			res = append(res, p.Name)
+		}
+		return res
+	}
+	if have := names("10.0.0.1"); len(have) != 3 {
+		t.Errorf("wrong protocols for 10.0.0.1: %v", have)
+	}
+	if have := names("10.1.0.1"); len(have) != 2 || have[0] != "eth" || have[1] != "snap" {
+		t.Errorf("wrong protocols for 10.1.0.1: %v", have)
+	}
+	if have := names("192.168.0.1"); len(have) != 2 || have[0] != "eth" || have[1] != "les" {
+		t.Errorf("wrong protocols for 192.168.0.1: %v", have)
+	}
+
+	if err := policy.checkSubnet(1); err != nil {
+		t.Errorf("subnet limit exceeded too early: %v", err)
+	}
+	if err := policy.checkSubnet(2); err != errPolicySubnet {
+		t.Errorf("subnet limit not enforced: %v", err)
+	}
+	if subnetKey(net.ParseIP("10.0.0.1")) != subnetKey(net.ParseIP("10.0.0.200")) {
+		t.Error("IPs in the same /24 have different subnet keys")
+	}
+	if subnetKey(net.ParseIP("10.0.0.1")) == subnetKey(net.ParseIP("10.0.1.1")) {
+		t.Error("IPs in different /24 have the same subnet key")
+	}
+}
+
+func TestPeerPolicyInvalid(t *testing.T) {
+	invalid := []PeerPolicy{
+		{ForkIDs: []string{"0x0102"}},
+		{ClientNames: []string{"("}},
+		{Protocols: map[string]ProtocolPolicy{"eth": {Allow: []string{"10.0.0.0"}}}},
+		{MaxPeersPerSubnet: -1},
+	}
+	for i, p := range invalid {
+		if _, err := compilePeerPolicy(p); err == nil {
+			t.Errorf("policy %d: no error", i)
+		}
+	}
+}
@@ -59,6 +59,10 @@ type Protocol struct {
 	// attempts to create connections to them.
 	DialCandidates enode.Iterator
 
+	// DiscoveryTopic, if set, is advertised through discovery v5 topic advertisement
+	// when discovery v5 is enabled. Nodes advertising the topic are dialed as well.
+	DiscoveryTopic string
+
 	// Attributes contains protocol specific information for the node record.
 	Attributes []enr.Entry
 }
@@ -0,0 +1,179 @@
+// Copyright 2022 The go-confero Authors
+// This file is part of the go-confero library.
+//
+// The go-confero library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-confero library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-confero library. If not, see <http://www.gnu.org/licenses/>.
+
+package p2p
+
+import (
+	"math"
+	"sync"
+	"time"
+
+	"github.com/confero-network/go-confero/p2p/enode"
+)
+
+// Score adjustments for common peer behaviours, to be reported by protocols
+// through Peer.Report.
+const (
+	ScoreUseful     = 1.0   // Useful response or announcement delivered
+	ScoreTimeout    = -5.0  // Request timed out or was served too slowly
+	ScoreBadMessage = -25.0 // Undecodable, unexpected or otherwise invalid message
+	ScoreBadData    = -50.0 // Invalid chain data, e.g. headers failing verification
+)
+
+const (
+	reputationHalfLife = time.Hour // Time it takes for a score to decay to half
+	reputationMaxScore = 100.0     // Cap on the positive score to bound the credit of a peer
+	reputationMinScore = -200.0    // Floor on the negative score to bound the penalty of a peer
+	reputationBanScore = -100.0    // Score below which a peer is banned
+	reputationBanTime  = time.Hour // Duration of the ban on a peer
+
+	// Inbound peers having at least evictScore may take the slot of a connected
+	// inbound peer with a score worse by evictMargin when the server is full.
+	reputationEvictScore  = 20.0
+	reputationEvictMargin = 20.0
+)
+
+// Reputation keeps track of the behaviour of remote nodes, as reported by the
+// protocols running on top of the server. Scores decay towards zero over time
+// and are persisted in the node database, so they outlive connections.
+//
+// The scores of connected peers are cached in memory and only written to the
+// database on bans, disconnects and shutdown.
+type Reputation struct {
+	db  *enode.DB
+	now func() time.Time
+
+	lock  sync.Mutex
+	cache map[enode.ID]*nodeReputation
+}
+
+// nodeReputation is the reputation of a single node.
+type nodeReputation struct {
+	score       float64   // Score as of the last update
+	updated     time.Time // Time of the last score update
+	bannedUntil time.Time // End of the ban on the node, zero if not banned
+}
+
+func newReputation(db *enode.DB) *Reputation {
+	return &Reputation{
+		db:    db,
+		now:   time.Now,
+		cache: make(map[enode.ID]*nodeReputation),
+	}
+}
+
+// Score returns the current score of a node.
+func (r *Reputation) Score(id enode.ID) float64 {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	return r.decayed(r.get(id))
+}
+
+// Banned reports whether a node is currently banned.
+func (r *Reputation) Banned(id enode.ID) bool {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	return r.now().Before(r.get(id).bannedUntil)
+}
+
+// report adjusts the score of a node, returning whether the node got banned by
+// the change.
+func (r *Reputation) report(id enode.ID, delta float64) bool {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	var (
+		now = r.now()
+		rep = r.get(id)
+	)
+	rep.score = math.Max(reputationMinScore, math.Min(reputationMaxScore, r.decayed(rep)+delta))
+	rep.updated = now
+	r.cache[id] = rep
+
+	if rep.score >= reputationBanScore || now.Before(rep.bannedUntil) {
+		if delta < 0 {
+			r.store(id, rep)
+		}
+		return false
+	}
+	rep.bannedUntil = now.Add(reputationBanTime)
+	r.store(id, rep)
+	return true
+}
+
+// release persists the reputation of a node and drops it from the cache. It is
+// called when a peer disconnects.
+func (r *Reputation) release(id enode.ID) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if rep, ok := r.cache[id]; ok {
+		r.store(id, rep)
+		delete(r.cache, id)
+	}
+}
+
+// close persists the reputation of all cached nodes.
+func (r *Reputation) close() {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	for id, rep := range r.cache {
+		r.store(id, rep)
+	}
+	r.cache = make(map[enode.ID]*nodeReputation)
+}
+
+// get retrieves the reputation of a node from the cache, falling back to the
+// database. The returned entry is only cached if reported on.
+func (r *Reputation) get(id enode.ID) *nodeReputation {
+	if rep, ok := r.cache[id]; ok {
+		return rep
+	}
+	score, updated, bannedUntil := r.db.NodeReputation(id)
+	return &nodeReputation{score: score, updated: updated, bannedUntil: bannedUntil}
+}
+
+// decayed returns the score of a reputation entry, decayed to the current time.
+func (r *Reputation) decayed(rep *nodeReputation) float64 {
+	if rep.score == 0 || rep.updated.IsZero() {
+		return rep.score
+	}
+	elapsed := r.now().Sub(rep.updated)
+	if elapsed <= 0 {
+		return rep.score
+	}
+	return rep.score * math.Exp2(-float64(elapsed)/float64(reputationHalfLife))
+}
+
+// store writes the reputation of a node into the database, folding the decay
+// into the score and dropping expired bans.
+func (r *Reputation) store(id enode.ID, rep *nodeReputation) {
+	var (
+		now   = r.now()
+		score = r.decayed(rep)
+		until = rep.bannedUntil
+	)
+	if math.Abs(score) < 0.01 {
+		score = 0
+	}
+	if !now.Before(until) {
+		until = time.Time{}
+	}
+	r.db.UpdateNodeReputation(id, score, now, until)
+}
@@ -0,0 +1,84 @@
+// Copyright 2022 The go-confero Authors
+// This file is part of the go-confero library.
+//
+// The go-confero library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-confero library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-confero library. If not, see <http://www.gnu.org/licenses/>.
+
+package p2p
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/confero-network/go-confero/p2p/enode"
+)
+
+func TestReputation(t *testing.T) {
+	db, _ := enode.OpenDB("")
+	defer db.Close()
+
+	var (
+		now    = time.Unix(1000000, 0)
+		reopen = func() *Reputation {
+			rep := newReputation(db)
+			rep.now = func() time.Time { return now }
+			return rep
+		}
+		rep = reopen()
+		id  = enode.ID{1}
+	)
+
+	// Scores should accumulate and decay with the configured half-life
+	for i := 0; i < 10; i++ {
+		rep.report(id, ScoreUseful)
+	}
+	if score := rep.Score(id); score != 10 {
+		t.Fatalf("score mismatch: have %v, want %v", score, 10)
+	}
+	now = now.Add(reputationHalfLife)
+	if score := rep.Score(id); math.Abs(score-5) > 1e-9 {
+		t.Fatalf("decayed score mismatch: have %v, want %v", score, 5)
+	}
+	// Scores should survive the peer being dropped from the cache
+	rep.release(id)
+	if score := reopen().Score(id); score == 0 {
+		t.Fatalf("score not persisted")
+	}
+	// Bad behaviour should ban the node until the ban expires
+	for i := 0; i < 2; i++ {
+		if rep.report(id, ScoreBadData) {
+			t.Fatalf("banned after %d bad reports", i+1)
+		}
+	}
+	if !rep.report(id, ScoreBadData) {
+		t.Fatalf("not banned after repeated bad reports")
+	}
+	if rep.report(id, ScoreBadData) {
+		t.Fatalf("banned node banned again")
+	}
+	if !rep.Banned(id) {
+		t.Fatalf("node not banned")
+	}
+	rep.release(id)
+	if !reopen().Banned(id) {
+		t.Fatalf("ban not persisted")
+	}
+	now = now.Add(reputationBanTime)
+	if rep.Banned(id) {
+		t.Fatalf("ban not expired")
+	}
+	if score := rep.Score(id); score >= 0 {
+		t.Fatalf("bad score forgotten with the ban: %v", score)
+	}
+}
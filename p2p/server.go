@@ -24,6 +24,7 @@ import (
 	"errors"
 	"fmt"
 	"net"
+	"os"
 	"sort"
 	"sync"
 	"sync/atomic"
@@ -114,6 +115,23 @@ type Config struct {
 	// allowed to connect, even above the peer limit.
 	TrustedNodes []*enode.Node
 
+	// BandwidthLimit limits the total bandwidth of all peer connections, in
+	// bytes per second. Zero values disable the limit.
+	BandwidthLimit RateLimit `toml:",omitempty"`
+
+	// ProtocolBandwidthLimits limits the bandwidth used by the messages of a
+	// subprotocol across all peers, keyed by protocol name.
+	ProtocolBandwidthLimits map[string]RateLimit `toml:",omitempty"`
+
+	// CaptureFile is the path of a file which all protocol messages exchanged
+	// with peers are recorded to, see CaptureWriter. The file must not exist, to
+	// keep previous captures from being overwritten. Empty disables capturing.
+	CaptureFile string `toml:",omitempty"`
+
+	// PeerPolicy restricts the nodes which may become peers, see PeerPolicy.
+	// It can be changed while the server is running with SetPolicy.
+	PeerPolicy PeerPolicy `toml:",omitempty"`
+
 	// Connectivity can be restricted to certain IP networks.
 	// If this option is set to a non-nil value, only hosts which match one of the
 	// IP networks contained in the list are considered.
@@ -182,12 +200,18 @@ type Server struct {
 	peerFeed     event.Feed
 	log          log.Logger
 
-	nodedb    *enode.DB
-	localnode *enode.LocalNode
-	ntab      *discover.UDPv4
-	DiscV5    *discover.UDPv5
-	discmix   *enode.FairMix
-	dialsched *dialScheduler
+	nodedb     *enode.DB
+	reputation *Reputation
+	bandwidth  *bandwidthLimits
+	capture    *CaptureWriter
+	nat        *natDetector // set in "discover" NAT mode
+	policyLock sync.RWMutex
+	policy     *peerPolicy
+	localnode  *enode.LocalNode
+	ntab       *discover.UDPv4
+	DiscV5     *discover.UDPv5
+	discmix    *enode.FairMix
+	dialsched  *dialScheduler
 
 	// Channels into the run loop.
 	quit                    chan struct{}
@@ -230,6 +254,8 @@ type conn struct {
 	cont  chan error // The run loop uses cont to signal errors to SetupConn.
 	caps  []Cap      // valid after the protocol handshake
 	name  string     // valid after the protocol handshake
+
+	record *enode.Node // record of an inbound node, if required by the peer policy
 }
 
 type transport interface {
@@ -459,8 +485,12 @@ func (srv *Server) Start() (err error) {
 	if srv.PrivateKey == nil {
 		return errors.New("Server.PrivateKey must be set to a non-nil key")
 	}
+	if srv.policy, err = compilePeerPolicy(srv.PeerPolicy); err != nil {
+		return fmt.Errorf("invalid peer policy: %v", err)
+	}
+	srv.bandwidth = newBandwidthLimits(srv.BandwidthLimit, srv.ProtocolBandwidthLimits, srv.clock)
 	if srv.newTransport == nil {
-		srv.newTransport = newRLPX
+		srv.newTransport = newLimitedRLPX(srv.bandwidth)
 	}
 	if srv.listenFunc == nil {
 		srv.listenFunc = net.Listen
@@ -486,6 +516,11 @@ func (srv *Server) Start() (err error) {
 		return err
 	}
 	srv.setupDialScheduler()
+	if srv.CaptureFile != "" {
+		if err := srv.setupCapture(); err != nil {
+			return err
+		}
+	}
 
 	srv.loopWG.Add(1)
 	go srv.run()
@@ -507,6 +542,7 @@ func (srv *Server) setupLocalNode() error {
 		return err
 	}
 	srv.nodedb = db
+	srv.reputation = newReputation(db)
 	srv.localnode = enode.NewLocalNode(db, srv.PrivateKey)
 	srv.localnode.SetFallbackIP(net.IP{127, 0, 0, 1})
 	// TODO: check conflicts
@@ -522,6 +558,8 @@ func (srv *Server) setupLocalNode() error {
 		// ExtIP doesn't block, set the IP right away.
 		ip, _ := srv.NAT.ExternalIP()
 		srv.localnode.SetStaticIP(ip)
+	case nat.Discover:
+		// The endpoint is predicted by discovery and checked once listening.
 	default:
 		// Ask the router about the IP. This takes a while and blocks startup,
 		// do it in the background.
@@ -622,10 +660,40 @@ func (srv *Server) setupDiscovery() error {
 		if err != nil {
 			return err
 		}
+		topics := make(map[string]bool)
+		for _, proto := range srv.Protocols {
+			if proto.DiscoveryTopic != "" && !topics[proto.DiscoveryTopic] {
+				srv.DiscV5.RegisterTopic(proto.DiscoveryTopic)
+				srv.discmix.AddSource(srv.DiscV5.TopicNodes(proto.DiscoveryTopic))
+				topics[proto.DiscoveryTopic] = true
+			}
+		}
+	}
+	return nil
+}
+
+func (srv *Server) setupCapture() error {
+	f, err := os.OpenFile(srv.CaptureFile, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return fmt.Errorf("can't create capture file: %v", err)
+	}
+	if srv.capture, err = NewCaptureWriter(f); err != nil {
+		f.Close()
+		return err
 	}
+	srv.log.Warn("Capturing p2p traffic", "file", srv.CaptureFile)
 	return nil
 }
 
+func (srv *Server) closeCapture() {
+	if srv.capture == nil {
+		return
+	}
+	if err := srv.capture.Close(); err != nil {
+		srv.log.Error("Failed to write p2p traffic capture", "err", err)
+	}
+}
+
 func (srv *Server) setupDialScheduler() {
 	config := dialConfig{
 		self:           srv.localnode.ID(),
@@ -635,6 +703,9 @@ func (srv *Server) setupDialScheduler() {
 		netRestrict:    srv.NetRestrict,
 		dialer:         srv.Dialer,
 		clock:          srv.clock,
+		reputation:     srv.reputation,
+		policy:         srv.peerPolicy,
+		protocols:      srv.Protocols,
 	}
 	if srv.ntab != nil {
 		config.resolver = srv.ntab
@@ -677,8 +748,16 @@ func (srv *Server) setupListening() error {
 	srv.ListenAddr = listener.Addr().String()
 
 	// Update the local node record and map the TCP listening port if NAT is configured.
+	// In "discover" NAT mode, the TCP port is only put into the record once it is
+	// found reachable.
 	if tcp, ok := listener.Addr().(*net.TCPAddr); ok {
-		srv.localnode.Set(enr.TCP(tcp.Port))
+		if _, ok := srv.NAT.(nat.Discover); ok {
+			srv.nat = newNATDetector(srv, tcp.Port)
+			srv.loopWG.Add(1)
+			go srv.nat.loop()
+		} else {
+			srv.localnode.Set(enr.TCP(tcp.Port))
+		}
 		if !tcp.IP.IsLoopback() && srv.NAT != nil {
 			srv.loopWG.Add(1)
 			go func() {
@@ -707,6 +786,8 @@ func (srv *Server) run() {
 	srv.log.Info("Started P2P networking", "self", srv.localnode.Node().URLv4())
 	defer srv.loopWG.Done()
 	defer srv.nodedb.Close()
+	defer srv.reputation.close()
+	defer srv.closeCapture()
 	defer srv.discmix.Close()
 	defer srv.dialsched.stop()
 
@@ -781,6 +862,7 @@ running:
 			// A peer disconnected.
 			d := common.PrettyDuration(mclock.Now() - pd.created)
 			delete(peers, pd.ID())
+			srv.reputation.release(pd.ID())
 			srv.log.Debug("Removing p2p peer", "peercount", len(peers), "id", pd.ID(), "duration", d, "req", pd.requested, "err", pd.err)
 			srv.dialsched.peerRemoved(pd.rw)
 			if pd.Inbound() {
@@ -813,28 +895,188 @@ running:
 }
 
 func (srv *Server) postHandshakeChecks(peers map[enode.ID]*Peer, inboundCount int, c *conn) error {
+	_, err := srv.limitChecks(peers, inboundCount, c)
+	return err
+}
+
+// limitChecks checks the connection against the peer limits and bans. When the
+// server is full, a well-behaving inbound connection is admitted if a connected
+// peer can be evicted for it, which is returned. The eviction is left to the
+// caller, so that it only happens once all checks passed.
+func (srv *Server) limitChecks(peers map[enode.ID]*Peer, inboundCount int, c *conn) (*Peer, error) {
+	// Peers being evicted don't count against the limits anymore
+	count := len(peers)
+	for _, p := range peers {
+		if p.evicted {
+			count--
+			if p.Inbound() {
+				inboundCount--
+			}
+		}
+	}
+	var evict *Peer
+	full := !c.is(trustedConn) && (count >= srv.MaxPeers || (c.is(inboundConn) && inboundCount >= srv.maxInboundConns()))
+	if full && c.is(inboundConn) && peers[c.node.ID()] == nil {
+		// Let well-behaving inbound peers take the slot of the worst one
+		if evict = srv.evictionCandidate(peers, srv.reputation.Score(c.node.ID())); evict != nil {
+			full = false
+		}
+	}
 	switch {
-	case !c.is(trustedConn) && len(peers) >= srv.MaxPeers:
-		return DiscTooManyPeers
-	case !c.is(trustedConn) && c.is(inboundConn) && inboundCount >= srv.maxInboundConns():
-		return DiscTooManyPeers
+	case full:
+		return nil, DiscTooManyPeers
+	case !c.is(trustedConn) && !c.is(staticDialedConn) && srv.reputation.Banned(c.node.ID()):
+		return nil, DiscUselessPeer
 	case peers[c.node.ID()] != nil:
-		return DiscAlreadyConnected
+		return nil, DiscAlreadyConnected
 	case c.node.ID() == srv.localnode.ID():
-		return DiscSelf
+		return nil, DiscSelf
 	default:
+		return evict, nil
+	}
+}
+
+// evictionCandidate returns the connected inbound peer with the worst score that
+// may be disconnected to make room for a node with the given score, or nil if no
+// peer qualifies.
+func (srv *Server) evictionCandidate(peers map[enode.ID]*Peer, score float64) *Peer {
+	if score < reputationEvictScore {
 		return nil
 	}
+	var (
+		worst      *Peer
+		worstScore float64
+	)
+	for _, p := range peers {
+		if p.evicted || !p.Inbound() || p.rw.is(trustedConn) {
+			continue
+		}
+		if s := srv.reputation.Score(p.ID()); s+reputationEvictMargin <= score && (worst == nil || s < worstScore) {
+			worst, worstScore = p, s
+		}
+	}
+	return worst
 }
 
 func (srv *Server) addPeerChecks(peers map[enode.ID]*Peer, inboundCount int, c *conn) error {
 	// Drop connections with no matching protocols.
-	if len(srv.Protocols) > 0 && countMatchingProtocols(srv.Protocols, c.caps) == 0 {
+	if len(srv.Protocols) > 0 && countMatchingProtocols(srv.peerProtocols(c), c.caps) == 0 {
 		return DiscUselessPeer
 	}
+	if err := srv.checkPeerPolicy(peers, c); err != nil {
+		return err
+	}
 	// Repeat the post-handshake checks because the
 	// peer set might have changed since those checks were performed.
-	return srv.postHandshakeChecks(peers, inboundCount, c)
+	evict, err := srv.limitChecks(peers, inboundCount, c)
+	if err != nil {
+		return err
+	}
+	// All checks passed, make room for the peer if needed
+	if evict != nil {
+		srv.log.Debug("Evicting p2p peer for better one", "id", evict.ID(), "score", srv.reputation.Score(evict.ID()), "new", c.node.ID())
+		evict.evicted = true
+		go evict.Disconnect(DiscTooManyPeers)
+	}
+	return nil
+}
+
+// checkPeerPolicy checks a connection against the peer policy. Trusted and static
+// nodes are exempt from it.
+func (srv *Server) checkPeerPolicy(peers map[enode.ID]*Peer, c *conn) error {
+	if c.is(trustedConn) || c.is(staticDialedConn) {
+		return nil
+	}
+	var (
+		policy = srv.peerPolicy()
+		clog   = srv.log.New("id", c.node.ID(), "addr", c.fd.RemoteAddr(), "conn", c.flags)
+	)
+	// Inbound connections don't carry the node record, use the one retrieved
+	// during the handshake or found by discovery. Without a record, the node
+	// can't satisfy any ENR rules.
+	if policy.checksRecord() {
+		record := c.node
+		if record.Seq() == 0 {
+			record = c.record
+		}
+		if record == nil {
+			record = srv.nodedb.Node(c.node.ID())
+		}
+		if record == nil {
+			clog.Trace("Peer rejected by policy", "err", errPolicyNoRecord)
+			return DiscUselessPeer
+		}
+		if err := policy.checkRecord(record); err != nil {
+			clog.Trace("Peer rejected by policy", "err", err)
+			return DiscUselessPeer
+		}
+	}
+	if err := policy.checkName(c.name); err != nil {
+		clog.Trace("Peer rejected by policy", "err", err)
+		return DiscUselessPeer
+	}
+	if ip := connIP(c); ip != nil && policy.MaxPeersPerSubnet > 0 {
+		subnet, count := subnetKey(ip), 0
+		for _, p := range peers {
+			if pip := connIP(p.rw); !p.evicted && pip != nil && subnetKey(pip) == subnet {
+				count++
+			}
+		}
+		if err := policy.checkSubnet(count); err != nil {
+			clog.Trace("Peer rejected by policy", "err", err)
+			return DiscTooManyPeers
+		}
+	}
+	return nil
+}
+
+// peerProtocols returns the protocols a connection may run under the peer policy.
+func (srv *Server) peerProtocols(c *conn) []Protocol {
+	if c.is(trustedConn) || c.is(staticDialedConn) {
+		return srv.Protocols
+	}
+	return srv.peerPolicy().allowedProtocols(connIP(c), srv.Protocols)
+}
+
+// connIP returns the remote IP of a connection.
+func connIP(c *conn) net.IP {
+	if tcp, ok := c.fd.RemoteAddr().(*net.TCPAddr); ok {
+		return tcp.IP
+	}
+	return c.node.IP()
+}
+
+// Policy returns the peer policy in effect.
+func (srv *Server) Policy() PeerPolicy {
+	srv.policyLock.RLock()
+	defer srv.policyLock.RUnlock()
+
+	if srv.policy == nil {
+		return srv.Config.PeerPolicy
+	}
+	return srv.policy.PeerPolicy
+}
+
+// SetPolicy replaces the peer policy of a running server. The new policy
+// applies to connections established from now on, connected peers are not
+// affected.
+func (srv *Server) SetPolicy(policy PeerPolicy) error {
+	compiled, err := compilePeerPolicy(policy)
+	if err != nil {
+		return err
+	}
+	srv.policyLock.Lock()
+	defer srv.policyLock.Unlock()
+
+	srv.policy = compiled
+	return nil
+}
+
+func (srv *Server) peerPolicy() *peerPolicy {
+	srv.policyLock.RLock()
+	defer srv.policyLock.RUnlock()
+
+	return srv.policy
 }
 
 // listenLoop runs in its own goroutine and accepts
@@ -920,7 +1162,7 @@ func (srv *Server) checkInboundConn(remoteIP net.IP) error {
 	// Reject Internet peers that try too often.
 	now := srv.clock.Now()
 	srv.inboundHistory.expire(now, nil)
-	if !netutil.IsLAN(remoteIP) && srv.inboundHistory.contains(remoteIP.String()) {
+	if !netutil.IsLAN(remoteIP) && !srv.nat.isSelfDial(remoteIP) && srv.inboundHistory.contains(remoteIP.String()) {
 		return fmt.Errorf("too many attempts")
 	}
 	srv.inboundHistory.add(remoteIP.String(), now.Add(inboundThrottleTime))
@@ -993,6 +1235,12 @@ func (srv *Server) setupConn(c *conn, flags connFlag, dialDest *enode.Node) erro
 		return DiscUnexpectedIdentity
 	}
 	c.caps, c.name = phs.Caps, phs.Name
+	if mc, ok := c.fd.(*meteredConn); ok {
+		mc.handshakeDone(c.node.ID())
+	}
+	if dialDest == nil {
+		c.record = srv.resolveRecord(c)
+	}
 	err = srv.checkpoint(c, srv.checkpointAddPeer)
 	if err != nil {
 		clog.Trace("Rejected peer", "err", err)
@@ -1002,6 +1250,35 @@ func (srv *Server) setupConn(c *conn, flags connFlag, dialDest *enode.Node) erro
 	return nil
 }
 
+// resolveRecord retrieves the node record of an inbound connection if the peer
+// policy checks records. The record is taken from the node database, or requested
+// from the node through discovery.
+func (srv *Server) resolveRecord(c *conn) *enode.Node {
+	if c.is(trustedConn) || !srv.peerPolicy().checksRecord() {
+		return nil
+	}
+	if n := srv.nodedb.Node(c.node.ID()); n != nil {
+		return n
+	}
+	var (
+		n   *enode.Node
+		err error
+	)
+	switch {
+	case srv.ntab != nil:
+		n, err = srv.ntab.RequestENR(c.node)
+	case srv.DiscV5 != nil:
+		n, err = srv.DiscV5.RequestENR(c.node)
+	default:
+		return nil
+	}
+	if err != nil {
+		srv.log.Trace("Failed to retrieve node record", "id", c.node.ID(), "addr", c.fd.RemoteAddr(), "err", err)
+		return nil
+	}
+	return n
+}
+
 func nodeFromConn(pubkey *ecdsa.PublicKey, conn net.Conn) *enode.Node {
 	var ip net.IP
 	var port int
@@ -1024,7 +1301,12 @@ func (srv *Server) checkpoint(c *conn, stage chan<- *conn) error {
 }
 
 func (srv *Server) launchPeer(c *conn) *Peer {
-	p := newPeer(srv.log, c, srv.Protocols)
+	p := newPeer(srv.log, c, srv.peerProtocols(c))
+	if t, ok := c.transport.(*rlpxTransport); ok {
+		t.setProtocols(p.running)
+	}
+	p.reputation = srv.reputation
+	p.capture = srv.capture
 	if srv.EnableMsgEvents {
 		// If message events are enabled, pass the peerFeed
 		// to the peer.
@@ -1080,6 +1362,7 @@ type NodeInfo struct {
 	} `json:"ports"`
 	ListenAddr string                 `json:"listenAddr"`
 	Protocols  map[string]interface{} `json:"protocols"`
+	NAT        *NATStatus             `json:"nat,omitempty"` // Detected endpoint in "discover" NAT mode
 }
 
 // NodeInfo gathers and returns a collection of metadata known about the host.
@@ -1097,6 +1380,9 @@ func (srv *Server) NodeInfo() *NodeInfo {
 	info.Ports.Discovery = node.UDP()
 	info.Ports.Listener = node.TCP()
 	info.ENR = node.String()
+	if srv.nat != nil {
+		info.NAT = srv.nat.Status()
+	}
 
 	// Gather all the running protocol infos (only once per protocol type)
 	for _, proto := range srv.Protocols {
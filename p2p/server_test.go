@@ -621,3 +621,125 @@ func syncAddPeer(srv *Server, node *enode.Node) bool {
 		}
 	}
 }
+
+func TestServerBannedPeer(t *testing.T) {
+	srvkey := newkey()
+	clientkey := newkey()
+	clientnode := enode.NewV4(&clientkey.PublicKey, nil, 0, 0)
+
+	var tp = &setupTransport{
+		pubkey: &clientkey.PublicKey,
+		phs: protoHandshake{
+			ID:   crypto.FromECDSAPub(&clientkey.PublicKey)[1:],
+			Caps: []Cap{discard.cap()},
+		},
+	}
+	srv := &Server{
+		Config: Config{
+			PrivateKey:  srvkey,
+			MaxPeers:    10,
+			NoDial:      true,
+			NoDiscovery: true,
+			Protocols:   []Protocol{discard},
+			Logger:      testlog.Logger(t, log.LvlTrace),
+		},
+		newTransport: func(fd net.Conn, dialDest *ecdsa.PublicKey) transport { return tp },
+	}
+	if err := srv.Start(); err != nil {
+		t.Fatalf("couldn't start server: %v", err)
+	}
+	defer srv.Stop()
+
+	// Ban the client and check that its connection is rejected
+	for !srv.reputation.report(clientnode.ID(), ScoreBadData) {
+	}
+	conn, _ := net.Pipe()
+	srv.SetupConn(conn, inboundConn, nil)
+	if tp.closeErr != DiscUselessPeer {
+		t.Errorf("unexpected close error: %q", tp.closeErr)
+	}
+	conn.Close()
+
+	if srv.PeerCount() != 0 {
+		t.Errorf("banned peer accepted")
+	}
+	if info := srv.PeersInfo(); len(info) != 0 {
+		t.Errorf("banned peer listed: %v", info)
+	}
+}
+
+func TestServerPeerPolicy(t *testing.T) {
+	srvkey := newkey()
+	clientkey := newkey()
+	tp := &setupTransport{
+		pubkey: &clientkey.PublicKey,
+		phs: protoHandshake{
+			ID:   crypto.FromECDSAPub(&clientkey.PublicKey)[1:],
+			Name: "badclient/v1.0.0",
+			Caps: []Cap{discard.cap()},
+		},
+	}
+	srv := &Server{
+		Config: Config{
+			PrivateKey:  srvkey,
+			MaxPeers:    10,
+			NoDial:      true,
+			NoDiscovery: true,
+			Protocols:   []Protocol{discard},
+			PeerPolicy:  PeerPolicy{DenyClientNames: []string{"^badclient/"}},
+			Logger:      testlog.Logger(t, log.LvlTrace),
+		},
+		newTransport: func(fd net.Conn, dialDest *ecdsa.PublicKey) transport { return tp },
+	}
+	if err := srv.Start(); err != nil {
+		t.Fatalf("couldn't start server: %v", err)
+	}
+	defer srv.Stop()
+
+	// The client name is denied by the policy.
+	fd, _ := net.Pipe()
+	srv.SetupConn(fd, inboundConn, nil)
+	if tp.closeErr != DiscUselessPeer {
+		t.Errorf("unexpected close error: %q", tp.closeErr)
+	}
+	fd.Close()
+
+	// Invalid policies are rejected.
+	if err := srv.SetPolicy(PeerPolicy{ClientNames: []string{"("}}); err == nil {
+		t.Fatal("invalid policy accepted")
+	}
+	// After lifting the restriction, the client can connect.
+	if err := srv.SetPolicy(PeerPolicy{}); err != nil {
+		t.Fatalf("can't set policy: %v", err)
+	}
+	fd, _ = net.Pipe()
+	defer fd.Close()
+	c := &conn{fd: fd, node: enode.NewV4(&clientkey.PublicKey, nil, 0, 0), flags: inboundConn, name: tp.phs.Name}
+	if err := srv.checkPeerPolicy(nil, c); err != nil {
+		t.Fatalf("connection rejected: %v", err)
+	}
+	if len(srv.Policy().DenyClientNames) != 0 {
+		t.Fatal("policy not replaced")
+	}
+
+	// With ENR rules, inbound nodes without a known record are rejected.
+	if err := srv.SetPolicy(PeerPolicy{RequiredENRKeys: []string{"snap"}}); err != nil {
+		t.Fatalf("can't set policy: %v", err)
+	}
+	if err := srv.checkPeerPolicy(nil, c); err != DiscUselessPeer {
+		t.Fatalf("connection without record: have %v, want %v", err, DiscUselessPeer)
+	}
+	var r enr.Record
+	r.Set(enr.WithEntry("snap", []interface{}{}))
+	if err := enode.SignV4(&r, clientkey); err != nil {
+		t.Fatal(err)
+	}
+	record, err := enode.New(enode.ValidSchemes, &r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.record = record
+	if err := srv.checkPeerPolicy(nil, c); err != nil {
+		t.Fatalf("connection with record rejected: %v", err)
+	}
+}
@@ -43,16 +43,38 @@ const (
 	discWriteTimeout = 1 * time.Second
 )
 
+// protoRange is the range of message codes of a protocol on a connection.
+type protoRange struct {
+	name           string
+	offset, length uint64
+}
+
 // rlpxTransport is the transport used by actual (non-test) connections.
 // It wraps an RLPx connection with locks and read/write deadlines.
 type rlpxTransport struct {
 	rmu, wmu sync.Mutex
 	wbuf     bytes.Buffer
 	conn     *rlpx.Conn
+
+	limits    *bandwidthLimits // Bandwidth limits shared with other connections, nil if unlimited
+	protocols []protoRange     // Message codes of the running protocols, for the protocol limits
+	counter   msgCounter       // Traffic counters of the message codes
+	closeOnce sync.Once
+	closed    chan struct{} // Closed when the transport is closed, aborts throttling
 }
 
 func newRLPX(conn net.Conn, dialDest *ecdsa.PublicKey) transport {
-	return &rlpxTransport{conn: rlpx.NewConn(conn, dialDest)}
+	return &rlpxTransport{conn: rlpx.NewConn(conn, dialDest), closed: make(chan struct{})}
+}
+
+// newLimitedRLPX returns a transport constructor creating RLPx transports that
+// enforce the given bandwidth limits.
+func newLimitedRLPX(limits *bandwidthLimits) func(net.Conn, *ecdsa.PublicKey) transport {
+	return func(conn net.Conn, dialDest *ecdsa.PublicKey) transport {
+		t := newRLPX(conn, dialDest).(*rlpxTransport)
+		t.limits = limits
+		return t
+	}
 }
 
 func (t *rlpxTransport) ReadMsg() (Msg, error) {
@@ -74,26 +96,51 @@ func (t *rlpxTransport) ReadMsg() (Msg, error) {
 			meterSize:  uint32(wireSize),
 			Payload:    bytes.NewReader(data),
 		}
+		t.counter.countIngress(code, msg.meterSize)
+
+		// Throttle reading from the connection if over the bandwidth limits.
+		// The next message isn't read from the socket until the wait is over.
+		if err := t.limits.waitGlobal(false, msg.meterSize, t.closed); err != nil {
+			return Msg{}, err
+		}
+		if t.limits != nil {
+			if err := t.limits.waitProtocol(t.protocolOf(code), false, msg.meterSize, t.closed); err != nil {
+				return Msg{}, err
+			}
+		}
 	}
 	return msg, err
 }
 
-func (t *rlpxTransport) WriteMsg(msg Msg) error {
-	t.wmu.Lock()
-	defer t.wmu.Unlock()
+// setProtocols sets the protocols running on the connection, so the messages
+// read can be charged to the limits of their protocol.
+func (t *rlpxTransport) setProtocols(running map[string]*protoRW) {
+	t.rmu.Lock()
+	defer t.rmu.Unlock()
 
-	// Copy message data to write buffer.
-	t.wbuf.Reset()
-	if _, err := io.CopyN(&t.wbuf, msg.Payload, int64(msg.Size)); err != nil {
-		return err
+	t.protocols = make([]protoRange, 0, len(running))
+	for _, proto := range running {
+		t.protocols = append(t.protocols, protoRange{name: proto.Name, offset: proto.offset, length: proto.Length})
 	}
+}
 
-	// Write the message.
-	t.conn.SetWriteDeadline(time.Now().Add(frameWriteTimeout))
-	size, err := t.conn.Write(msg.Code, t.wbuf.Bytes())
+// protocolOf returns the name of the protocol a message code belongs to, or an
+// empty string if the code isn't one of a running protocol.
+func (t *rlpxTransport) protocolOf(code uint64) string {
+	for _, proto := range t.protocols {
+		if code >= proto.offset && code < proto.offset+proto.length {
+			return proto.name
+		}
+	}
+	return ""
+}
+
+func (t *rlpxTransport) WriteMsg(msg Msg) error {
+	size, err := t.writeMsg(msg)
 	if err != nil {
 		return err
 	}
+	t.counter.countEgress(msg.Code, size)
 
 	// Set metrics.
 	msg.meterSize = size
@@ -102,10 +149,36 @@ func (t *rlpxTransport) WriteMsg(msg Msg) error {
 		metrics.GetOrRegisterMeter(m, nil).Mark(int64(msg.meterSize))
 		metrics.GetOrRegisterMeter(m+"/packets", nil).Mark(1)
 	}
-	return nil
+	// Throttle the writer if over the bandwidth limits. This is done without
+	// holding the write lock, so the connection can be closed meanwhile.
+	if err := t.limits.waitGlobal(true, size, t.closed); err != nil {
+		return err
+	}
+	return t.limits.waitProtocol(msg.meterCap.Name, true, size, t.closed)
+}
+
+func (t *rlpxTransport) writeMsg(msg Msg) (uint32, error) {
+	t.wmu.Lock()
+	defer t.wmu.Unlock()
+
+	// Copy message data to write buffer.
+	t.wbuf.Reset()
+	if _, err := io.CopyN(&t.wbuf, msg.Payload, int64(msg.Size)); err != nil {
+		return 0, err
+	}
+
+	// Write the message.
+	t.conn.SetWriteDeadline(time.Now().Add(frameWriteTimeout))
+	return t.conn.Write(msg.Code, t.wbuf.Bytes())
+}
+
+func (t *rlpxTransport) msgTraffic() map[uint64]MsgTraffic {
+	return t.counter.msgTraffic()
 }
 
 func (t *rlpxTransport) close(err error) {
+	t.closeOnce.Do(func() { close(t.closed) })
+
 	t.wmu.Lock()
 	defer t.wmu.Unlock()
 
@@ -0,0 +1,552 @@
+// Copyright 2022 The go-confero Authors
+// This file is part of the go-confero library.
+//
+// The go-confero library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-confero library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-confero library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package pb implements the messages of the Protobuf-over-HTTP API, as defined
+// in confero.proto.
+package pb
+
+import (
+	"math/big"
+
+	"github.com/confero-network/go-confero/common"
+	"github.com/confero-network/go-confero/core/types"
+	"github.com/confero-network/go-confero/rpc"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// Names of the methods of the API.
+const (
+	MethodGetBlock          = "GetBlock"
+	MethodGetTransaction    = "GetTransaction"
+	MethodGetReceipt        = "GetReceipt"
+	MethodGetBlockReceipts  = "GetBlockReceipts"
+	MethodGetLogs           = "GetLogs"
+	MethodSubscribeNewHeads = "SubscribeNewHeads"
+)
+
+// MarshalHeader encodes a Header message.
+func MarshalHeader(h *types.Header) []byte {
+	return appendHeader(nil, h)
+}
+
+func appendHeader(b []byte, h *types.Header) []byte {
+	b = appendHash(b, 1, h.ParentHash)
+	b = appendHash(b, 2, h.UncleHash)
+	b = appendAddress(b, 3, h.Coinbase)
+	b = appendHash(b, 4, h.Root)
+	b = appendHash(b, 5, h.TxHash)
+	b = appendHash(b, 6, h.ReceiptHash)
+	if h.Bloom != (types.Bloom{}) {
+		b = appendMessage(b, 7, h.Bloom[:])
+	}
+	b = appendBig(b, 8, h.Difficulty)
+	if h.Number != nil {
+		b = appendUint(b, 9, h.Number.Uint64())
+	}
+	b = appendUint(b, 10, h.GasLimit)
+	b = appendUint(b, 11, h.GasUsed)
+	b = appendUint(b, 12, h.Time)
+	b = appendBytes(b, 13, h.Extra)
+	b = appendHash(b, 14, h.MixDigest)
+	if nonce := h.Nonce.Uint64(); nonce != 0 {
+		b = protowire.AppendTag(b, 15, protowire.Fixed64Type)
+		b = protowire.AppendFixed64(b, nonce)
+	}
+	// The presence of the base fee matters for the hash of the header.
+	if h.BaseFee != nil {
+		b = appendMessage(b, 16, h.BaseFee.Bytes())
+	}
+	return b
+}
+
+// UnmarshalHeader decodes a Header message.
+func UnmarshalHeader(b []byte) (*types.Header, error) {
+	h := &types.Header{Difficulty: new(big.Int), Number: new(big.Int)}
+	err := decode(b, func(f *field) (err error) {
+		switch f.num {
+		case 1:
+			h.ParentHash, err = f.hash()
+		case 2:
+			h.UncleHash, err = f.hash()
+		case 3:
+			h.Coinbase, err = f.address()
+		case 4:
+			h.Root, err = f.hash()
+		case 5:
+			h.TxHash, err = f.hash()
+		case 6:
+			h.ReceiptHash, err = f.hash()
+		case 7:
+			err = f.fixed(h.Bloom[:])
+		case 8:
+			h.Difficulty = f.big()
+		case 9:
+			h.Number = new(big.Int).SetUint64(f.value)
+		case 10:
+			h.GasLimit = f.value
+		case 11:
+			h.GasUsed = f.value
+		case 12:
+			h.Time = f.value
+		case 13:
+			h.Extra = f.bytes()
+		case 14:
+			h.MixDigest, err = f.hash()
+		case 15:
+			h.Nonce = types.EncodeNonce(f.value)
+		case 16:
+			h.BaseFee = f.big()
+		}
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return h, nil
+}
+
+func appendLog(b []byte, log *types.Log) []byte {
+	b = appendAddress(b, 1, log.Address)
+	for _, topic := range log.Topics {
+		b = appendMessage(b, 2, topic[:])
+	}
+	b = appendBytes(b, 3, log.Data)
+	b = appendUint(b, 4, log.BlockNumber)
+	b = appendHash(b, 5, log.TxHash)
+	b = appendUint(b, 6, uint64(log.TxIndex))
+	b = appendHash(b, 7, log.BlockHash)
+	b = appendUint(b, 8, uint64(log.Index))
+	b = appendBool(b, 9, log.Removed)
+	return b
+}
+
+func decodeLog(b []byte) (*types.Log, error) {
+	log := new(types.Log)
+	err := decode(b, func(f *field) (err error) {
+		switch f.num {
+		case 1:
+			log.Address, err = f.address()
+		case 2:
+			var topic common.Hash
+			topic, err = f.hash()
+			log.Topics = append(log.Topics, topic)
+		case 3:
+			log.Data = f.bytes()
+		case 4:
+			log.BlockNumber = f.value
+		case 5:
+			log.TxHash, err = f.hash()
+		case 6:
+			log.TxIndex = uint(f.value)
+		case 7:
+			log.BlockHash, err = f.hash()
+		case 8:
+			log.Index = uint(f.value)
+		case 9:
+			log.Removed = f.value != 0
+		}
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	if log.Data == nil {
+		log.Data = []byte{}
+	}
+	return log, nil
+}
+
+func appendReceipt(b []byte, r *types.Receipt) []byte {
+	b = appendUint(b, 1, uint64(r.Type))
+	b = appendBytes(b, 2, r.PostState)
+	b = appendUint(b, 3, r.Status)
+	b = appendUint(b, 4, r.CumulativeGasUsed)
+	if r.Bloom != (types.Bloom{}) {
+		b = appendMessage(b, 5, r.Bloom[:])
+	}
+	for _, log := range r.Logs {
+		b = appendMessage(b, 6, appendLog(nil, log))
+	}
+	b = appendHash(b, 7, r.TxHash)
+	b = appendAddress(b, 8, r.ContractAddress)
+	b = appendUint(b, 9, r.GasUsed)
+	b = appendHash(b, 10, r.BlockHash)
+	if r.BlockNumber != nil {
+		b = appendUint(b, 11, r.BlockNumber.Uint64())
+	}
+	b = appendUint(b, 12, uint64(r.TransactionIndex))
+	return b
+}
+
+func decodeReceipt(b []byte) (*types.Receipt, error) {
+	r := &types.Receipt{Logs: []*types.Log{}, BlockNumber: new(big.Int)}
+	err := decode(b, func(f *field) (err error) {
+		switch f.num {
+		case 1:
+			r.Type = uint8(f.value)
+		case 2:
+			r.PostState = f.bytes()
+		case 3:
+			r.Status = f.value
+		case 4:
+			r.CumulativeGasUsed = f.value
+		case 5:
+			err = f.fixed(r.Bloom[:])
+		case 6:
+			var log *types.Log
+			if log, err = decodeLog(f.data); err == nil {
+				r.Logs = append(r.Logs, log)
+			}
+		case 7:
+			r.TxHash, err = f.hash()
+		case 8:
+			r.ContractAddress, err = f.address()
+		case 9:
+			r.GasUsed = f.value
+		case 10:
+			r.BlockHash, err = f.hash()
+		case 11:
+			r.BlockNumber = new(big.Int).SetUint64(f.value)
+		case 12:
+			r.TransactionIndex = uint(f.value)
+		}
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// BlockRequest selects a block by hash if set, or by number.
+type BlockRequest struct {
+	Number rpc.BlockNumber
+	Hash   common.Hash
+}
+
+// Marshal encodes the message.
+func (m *BlockRequest) Marshal() []byte {
+	var b []byte
+	if m.Number != 0 {
+		b = appendInt(b, 1, m.Number.Int64())
+	}
+	return appendHash(b, 2, m.Hash)
+}
+
+// Unmarshal decodes the message.
+func (m *BlockRequest) Unmarshal(b []byte) error {
+	*m = BlockRequest{}
+	return decode(b, func(f *field) (err error) {
+		switch f.num {
+		case 1:
+			m.Number = rpc.BlockNumber(f.int())
+		case 2:
+			m.Hash, err = f.hash()
+		}
+		return err
+	})
+}
+
+// BlockResponse carries a block along with the senders of its transactions.
+// The block is nil if it was not found.
+type BlockResponse struct {
+	Block   *types.Block
+	Senders []common.Address
+}
+
+// Marshal encodes the message.
+func (m *BlockResponse) Marshal() []byte {
+	if m.Block == nil {
+		return nil
+	}
+	block := appendMessage(nil, 1, appendHeader(nil, m.Block.Header()))
+	for _, tx := range m.Block.Transactions() {
+		enc, _ := tx.MarshalBinary()
+		block = appendMessage(block, 2, enc)
+	}
+	for _, uncle := range m.Block.Uncles() {
+		block = appendMessage(block, 3, appendHeader(nil, uncle))
+	}
+	for _, sender := range m.Senders {
+		block = appendMessage(block, 4, sender[:])
+	}
+	return appendMessage(nil, 1, block)
+}
+
+// Unmarshal decodes the message.
+func (m *BlockResponse) Unmarshal(b []byte) error {
+	*m = BlockResponse{}
+	return decode(b, func(f *field) error {
+		if f.num != 1 {
+			return nil
+		}
+		var (
+			header *types.Header
+			txs    []*types.Transaction
+			uncles []*types.Header
+		)
+		err := decode(f.data, func(f *field) (err error) {
+			switch f.num {
+			case 1:
+				header, err = UnmarshalHeader(f.data)
+			case 2:
+				tx := new(types.Transaction)
+				if err = tx.UnmarshalBinary(f.data); err == nil {
+					txs = append(txs, tx)
+				}
+			case 3:
+				var uncle *types.Header
+				if uncle, err = UnmarshalHeader(f.data); err == nil {
+					uncles = append(uncles, uncle)
+				}
+			case 4:
+				var sender common.Address
+				sender, err = f.address()
+				m.Senders = append(m.Senders, sender)
+			}
+			return err
+		})
+		if err != nil {
+			return err
+		}
+		if header == nil {
+			header = &types.Header{Difficulty: new(big.Int), Number: new(big.Int)}
+		}
+		m.Block = types.NewBlockWithHeader(header).WithBody(txs, uncles)
+		return nil
+	})
+}
+
+// TransactionRequest selects a transaction by hash.
+type TransactionRequest struct {
+	Hash common.Hash
+}
+
+// Marshal encodes the message.
+func (m *TransactionRequest) Marshal() []byte {
+	return appendHash(nil, 1, m.Hash)
+}
+
+// Unmarshal decodes the message.
+func (m *TransactionRequest) Unmarshal(b []byte) error {
+	*m = TransactionRequest{}
+	return decode(b, func(f *field) (err error) {
+		if f.num == 1 {
+			m.Hash, err = f.hash()
+		}
+		return err
+	})
+}
+
+// TransactionResponse carries a transaction and its position in the chain. The
+// transaction is nil if it was not found, and the block hash is zero if it is
+// pending.
+type TransactionResponse struct {
+	Tx          *types.Transaction
+	BlockHash   common.Hash
+	BlockNumber uint64
+	Index       uint64
+	Sender      common.Address
+}
+
+// Marshal encodes the message.
+func (m *TransactionResponse) Marshal() []byte {
+	if m.Tx == nil {
+		return nil
+	}
+	enc, _ := m.Tx.MarshalBinary()
+	b := appendMessage(nil, 1, enc)
+	b = appendHash(b, 2, m.BlockHash)
+	b = appendUint(b, 3, m.BlockNumber)
+	b = appendUint(b, 4, m.Index)
+	return appendAddress(b, 5, m.Sender)
+}
+
+// Unmarshal decodes the message.
+func (m *TransactionResponse) Unmarshal(b []byte) error {
+	*m = TransactionResponse{}
+	return decode(b, func(f *field) (err error) {
+		switch f.num {
+		case 1:
+			m.Tx = new(types.Transaction)
+			err = m.Tx.UnmarshalBinary(f.data)
+		case 2:
+			m.BlockHash, err = f.hash()
+		case 3:
+			m.BlockNumber = f.value
+		case 4:
+			m.Index = f.value
+		case 5:
+			m.Sender, err = f.address()
+		}
+		return err
+	})
+}
+
+// ReceiptResponse carries a receipt, which is nil if it was not found.
+type ReceiptResponse struct {
+	Receipt *types.Receipt
+}
+
+// Marshal encodes the message.
+func (m *ReceiptResponse) Marshal() []byte {
+	if m.Receipt == nil {
+		return nil
+	}
+	return appendMessage(nil, 1, appendReceipt(nil, m.Receipt))
+}
+
+// Unmarshal decodes the message.
+func (m *ReceiptResponse) Unmarshal(b []byte) error {
+	*m = ReceiptResponse{}
+	return decode(b, func(f *field) (err error) {
+		if f.num == 1 {
+			m.Receipt, err = decodeReceipt(f.data)
+		}
+		return err
+	})
+}
+
+// BlockReceiptsResponse carries the receipts of a block. The block hash is zero
+// if the block was not found.
+type BlockReceiptsResponse struct {
+	BlockHash common.Hash
+	Receipts  types.Receipts
+}
+
+// Marshal encodes the message.
+func (m *BlockReceiptsResponse) Marshal() []byte {
+	b := appendHash(nil, 1, m.BlockHash)
+	for _, receipt := range m.Receipts {
+		b = appendMessage(b, 2, appendReceipt(nil, receipt))
+	}
+	return b
+}
+
+// Unmarshal decodes the message.
+func (m *BlockReceiptsResponse) Unmarshal(b []byte) error {
+	*m = BlockReceiptsResponse{}
+	return decode(b, func(f *field) (err error) {
+		switch f.num {
+		case 1:
+			m.BlockHash, err = f.hash()
+		case 2:
+			var receipt *types.Receipt
+			if receipt, err = decodeReceipt(f.data); err == nil {
+				m.Receipts = append(m.Receipts, receipt)
+			}
+		}
+		return err
+	})
+}
+
+// LogsRequest selects the logs of the block with the given hash if set, or of the
+// block range, which defaults to the latest block.
+type LogsRequest struct {
+	FromBlock *rpc.BlockNumber
+	ToBlock   *rpc.BlockNumber
+	BlockHash *common.Hash
+	Addresses []common.Address
+	Topics    [][]common.Hash
+}
+
+// Marshal encodes the message.
+func (m *LogsRequest) Marshal() []byte {
+	var b []byte
+	if m.FromBlock != nil {
+		b = appendInt(b, 1, m.FromBlock.Int64())
+	}
+	if m.ToBlock != nil {
+		b = appendInt(b, 2, m.ToBlock.Int64())
+	}
+	if m.BlockHash != nil {
+		b = appendMessage(b, 3, m.BlockHash[:])
+	}
+	for _, addr := range m.Addresses {
+		b = appendMessage(b, 4, addr[:])
+	}
+	for _, topics := range m.Topics {
+		var enc []byte
+		for _, topic := range topics {
+			enc = appendMessage(enc, 1, topic[:])
+		}
+		b = appendMessage(b, 5, enc)
+	}
+	return b
+}
+
+// Unmarshal decodes the message.
+func (m *LogsRequest) Unmarshal(b []byte) error {
+	*m = LogsRequest{}
+	return decode(b, func(f *field) (err error) {
+		switch f.num {
+		case 1:
+			number := rpc.BlockNumber(f.int())
+			m.FromBlock = &number
+		case 2:
+			number := rpc.BlockNumber(f.int())
+			m.ToBlock = &number
+		case 3:
+			var hash common.Hash
+			hash, err = f.hash()
+			m.BlockHash = &hash
+		case 4:
+			var addr common.Address
+			addr, err = f.address()
+			m.Addresses = append(m.Addresses, addr)
+		case 5:
+			var topics []common.Hash
+			err = decode(f.data, func(f *field) error {
+				if f.num != 1 {
+					return nil
+				}
+				topic, err := f.hash()
+				topics = append(topics, topic)
+				return err
+			})
+			m.Topics = append(m.Topics, topics)
+		}
+		return err
+	})
+}
+
+// LogsResponse carries the logs matching a LogsRequest.
+type LogsResponse struct {
+	Logs []*types.Log
+}
+
+// Marshal encodes the message.
+func (m *LogsResponse) Marshal() []byte {
+	var b []byte
+	for _, log := range m.Logs {
+		b = appendMessage(b, 1, appendLog(nil, log))
+	}
+	return b
+}
+
+// Unmarshal decodes the message.
+func (m *LogsResponse) Unmarshal(b []byte) error {
+	*m = LogsResponse{}
+	return decode(b, func(f *field) (err error) {
+		if f.num == 1 {
+			var log *types.Log
+			if log, err = decodeLog(f.data); err == nil {
+				m.Logs = append(m.Logs, log)
+			}
+		}
+		return err
+	})
+}
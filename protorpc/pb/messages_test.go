@@ -0,0 +1,170 @@
+// Copyright 2022 The go-confero Authors
+// This file is part of the go-confero library.
+//
+// The go-confero library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-confero library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-confero library. If not, see <http://www.gnu.org/licenses/>.
+
+package pb
+
+import (
+	"math/big"
+	"reflect"
+	"testing"
+
+	"github.com/confero-network/go-confero/common"
+	"github.com/confero-network/go-confero/core/types"
+	"github.com/confero-network/go-confero/crypto"
+	"github.com/confero-network/go-confero/params"
+	"github.com/confero-network/go-confero/rpc"
+	"github.com/confero-network/go-confero/trie"
+)
+
+func TestHeaderEncoding(t *testing.T) {
+	headers := []*types.Header{
+		{
+			ParentHash: common.Hash{1},
+			Coinbase:   common.Address{2},
+			Bloom:      types.Bloom{3},
+			Difficulty: big.NewInt(131072),
+			Number:     big.NewInt(100),
+			GasLimit:   30000000,
+			GasUsed:    21000,
+			Time:       1650000000,
+			Extra:      []byte("extra"),
+			Nonce:      types.EncodeNonce(0xdeadbeef),
+		},
+		// Post-merge headers with a zero difficulty and nonce.
+		{
+			ParentHash: common.Hash{1},
+			Difficulty: new(big.Int),
+			Number:     big.NewInt(1),
+			MixDigest:  common.Hash{4},
+			BaseFee:    big.NewInt(params.InitialBaseFee),
+		},
+		// A zero base fee is distinct from a missing one.
+		{Difficulty: new(big.Int), Number: new(big.Int), BaseFee: new(big.Int)},
+	}
+	for i, header := range headers {
+		dec, err := UnmarshalHeader(MarshalHeader(header))
+		if err != nil {
+			t.Fatalf("header %d: %v", i, err)
+		}
+		if dec.Hash() != header.Hash() {
+			t.Errorf("header %d: hash mismatch:\nhave %+v\nwant %+v", i, dec, header)
+		}
+	}
+	if _, err := UnmarshalHeader([]byte{0x0a, 0x01, 0x00}); err == nil {
+		t.Error("expected error for truncated parent hash")
+	}
+}
+
+func TestBlockEncoding(t *testing.T) {
+	var (
+		key, _ = crypto.GenerateKey()
+		signer = types.LatestSigner(params.TestChainConfig)
+		sender = crypto.PubkeyToAddress(key.PublicKey)
+		txs    []*types.Transaction
+	)
+	txs = append(txs, types.MustSignNewTx(key, signer, &types.LegacyTx{Nonce: 0, To: &common.Address{1}, Gas: 21000, GasPrice: big.NewInt(1)}))
+	txs = append(txs, types.MustSignNewTx(key, signer, &types.DynamicFeeTx{ChainID: params.TestChainConfig.ChainID, Nonce: 1, Gas: 21000, GasFeeCap: big.NewInt(2), Data: []byte{1}}))
+
+	header := &types.Header{Difficulty: big.NewInt(1), Number: big.NewInt(5), BaseFee: big.NewInt(1)}
+	uncle := &types.Header{Difficulty: big.NewInt(1), Number: big.NewInt(4)}
+	block := types.NewBlock(header, txs, []*types.Header{uncle}, nil, trie.NewStackTrie(nil))
+
+	var dec BlockResponse
+	resp := &BlockResponse{Block: block, Senders: []common.Address{sender, sender}}
+	if err := dec.Unmarshal(resp.Marshal()); err != nil {
+		t.Fatal(err)
+	}
+	if dec.Block.Hash() != block.Hash() {
+		t.Fatal("block hash mismatch")
+	}
+	if len(dec.Block.Transactions()) != 2 || dec.Block.Transactions()[1].Hash() != txs[1].Hash() {
+		t.Fatal("transaction mismatch")
+	}
+	if len(dec.Block.Uncles()) != 1 || dec.Block.Uncles()[0].Hash() != uncle.Hash() {
+		t.Fatal("uncle mismatch")
+	}
+	if !reflect.DeepEqual(dec.Senders, resp.Senders) {
+		t.Fatalf("wrong senders %v", dec.Senders)
+	}
+	// Missing blocks are encoded as empty responses.
+	if err := dec.Unmarshal((&BlockResponse{}).Marshal()); err != nil || dec.Block != nil {
+		t.Fatalf("wrong missing block %v, err %v", dec.Block, err)
+	}
+}
+
+func TestReceiptEncoding(t *testing.T) {
+	receipts := types.Receipts{
+		{
+			Type:              types.DynamicFeeTxType,
+			Status:            types.ReceiptStatusSuccessful,
+			CumulativeGasUsed: 50000,
+			Bloom:             types.Bloom{1},
+			Logs: []*types.Log{{
+				Address:     common.Address{1},
+				Topics:      []common.Hash{{2}, {3}},
+				Data:        []byte{4},
+				BlockNumber: 7,
+				TxHash:      common.Hash{5},
+				TxIndex:     1,
+				BlockHash:   common.Hash{6},
+				Index:       2,
+			}},
+			TxHash:           common.Hash{5},
+			ContractAddress:  common.Address{7},
+			GasUsed:          30000,
+			BlockHash:        common.Hash{6},
+			BlockNumber:      big.NewInt(7),
+			TransactionIndex: 1,
+		},
+		{
+			PostState:   []byte{8},
+			Logs:        []*types.Log{},
+			BlockNumber: new(big.Int),
+		},
+	}
+	var dec BlockReceiptsResponse
+	resp := &BlockReceiptsResponse{BlockHash: common.Hash{6}, Receipts: receipts}
+	if err := dec.Unmarshal(resp.Marshal()); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(dec.Receipts, receipts) || dec.BlockHash != resp.BlockHash {
+		t.Fatalf("receipt mismatch:\nhave %+v\nwant %+v", dec.Receipts, receipts)
+	}
+}
+
+func TestLogsRequestEncoding(t *testing.T) {
+	var (
+		from = rpc.BlockNumber(0)
+		to   = rpc.FinalizedBlockNumber
+	)
+	req := &LogsRequest{
+		FromBlock: &from,
+		ToBlock:   &to,
+		Addresses: []common.Address{{1}, {2}},
+		Topics:    [][]common.Hash{nil, {{3}, {4}}},
+	}
+	var dec LogsRequest
+	if err := dec.Unmarshal(req.Marshal()); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(&dec, req) {
+		t.Fatalf("request mismatch:\nhave %+v\nwant %+v", dec, req)
+	}
+	// Missing block numbers default to nil.
+	if err := dec.Unmarshal((&LogsRequest{}).Marshal()); err != nil || dec.FromBlock != nil || dec.ToBlock != nil {
+		t.Fatalf("wrong empty request %+v, err %v", dec, err)
+	}
+}
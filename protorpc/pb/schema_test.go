@@ -0,0 +1,308 @@
+// Copyright 2022 The go-confero Authors
+// This file is part of the go-confero library.
+//
+// The go-confero library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-confero library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-confero library. If not, see <http://www.gnu.org/licenses/>.
+
+package pb
+
+import (
+	"bytes"
+	"fmt"
+	"math/big"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/confero-network/go-confero/common"
+	"github.com/confero-network/go-confero/core/types"
+	"github.com/confero-network/go-confero/crypto"
+	"github.com/confero-network/go-confero/params"
+	"github.com/confero-network/go-confero/rpc"
+	"github.com/confero-network/go-confero/trie"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+// scalarTypes are the scalar types used in confero.proto.
+var scalarTypes = map[string]descriptorpb.FieldDescriptorProto_Type{
+	"bool":    descriptorpb.FieldDescriptorProto_TYPE_BOOL,
+	"bytes":   descriptorpb.FieldDescriptorProto_TYPE_BYTES,
+	"string":  descriptorpb.FieldDescriptorProto_TYPE_STRING,
+	"uint32":  descriptorpb.FieldDescriptorProto_TYPE_UINT32,
+	"uint64":  descriptorpb.FieldDescriptorProto_TYPE_UINT64,
+	"int64":   descriptorpb.FieldDescriptorProto_TYPE_INT64,
+	"sint64":  descriptorpb.FieldDescriptorProto_TYPE_SINT64,
+	"fixed64": descriptorpb.FieldDescriptorProto_TYPE_FIXED64,
+}
+
+// loadSchema parses the messages of confero.proto. Only the subset of the
+// language used by the schema is supported.
+func loadSchema(t *testing.T) protoreflect.FileDescriptor {
+	t.Helper()
+
+	src, err := os.ReadFile("confero.proto")
+	if err != nil {
+		t.Fatal(err)
+	}
+	file := &descriptorpb.FileDescriptorProto{
+		Name:    proto.String("confero.proto"),
+		Package: proto.String("confero.v1"),
+		Syntax:  proto.String("proto3"),
+	}
+	var msg *descriptorpb.DescriptorProto
+	for i, line := range strings.Split(string(src), "\n") {
+		if comment := strings.Index(line, "//"); comment >= 0 {
+			line = line[:comment]
+		}
+		tokens := strings.Fields(strings.NewReplacer("=", " ", ";", " ").Replace(line))
+		switch {
+		case len(tokens) == 0:
+		case tokens[0] == "message":
+			msg = &descriptorpb.DescriptorProto{Name: proto.String(tokens[1])}
+			file.MessageType = append(file.MessageType, msg)
+			if strings.HasSuffix(line, "}") {
+				msg = nil
+			}
+		case tokens[0] == "}":
+			msg = nil
+		case msg != nil:
+			field := &descriptorpb.FieldDescriptorProto{Label: descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum()}
+			switch tokens[0] {
+			case "repeated":
+				field.Label = descriptorpb.FieldDescriptorProto_LABEL_REPEATED.Enum()
+				tokens = tokens[1:]
+			case "optional":
+				field.Proto3Optional = proto.Bool(true)
+				field.OneofIndex = proto.Int32(int32(len(msg.OneofDecl)))
+				msg.OneofDecl = append(msg.OneofDecl, &descriptorpb.OneofDescriptorProto{Name: proto.String("_" + tokens[2])})
+				tokens = tokens[1:]
+			}
+			if len(tokens) != 3 {
+				t.Fatalf("line %d: invalid field %q", i+1, line)
+			}
+			num, err := strconv.Atoi(tokens[2])
+			if err != nil {
+				t.Fatalf("line %d: invalid field number: %v", i+1, err)
+			}
+			field.Name, field.Number = proto.String(tokens[1]), proto.Int32(int32(num))
+			if typ, ok := scalarTypes[tokens[0]]; ok {
+				field.Type = typ.Enum()
+			} else {
+				field.Type = descriptorpb.FieldDescriptorProto_TYPE_MESSAGE.Enum()
+				field.TypeName = proto.String(".confero.v1." + tokens[0])
+			}
+			msg.Field = append(msg.Field, field)
+		}
+	}
+	schema, err := protodesc.NewFile(file, nil)
+	if err != nil {
+		t.Fatalf("invalid schema: %v", err)
+	}
+	return schema
+}
+
+// schemaChecker checks encoded messages against the schema.
+type schemaChecker struct {
+	t       *testing.T
+	schema  protoreflect.FileDescriptor
+	checked map[protoreflect.Name]bool
+}
+
+// check decodes enc with the standard implementation as the named message of the
+// schema. All fields must be known and set. The message is then encoded by the
+// standard implementation, which must be re-encoded to enc by reencode.
+func (c *schemaChecker) check(name string, enc []byte, reencode func([]byte) ([]byte, error)) protoreflect.Message {
+	desc := c.schema.Messages().ByName(protoreflect.Name(name))
+	if desc == nil {
+		c.t.Fatalf("message %s not in schema", name)
+	}
+	msg := dynamicpb.NewMessage(desc)
+	if err := proto.Unmarshal(enc, msg); err != nil {
+		c.t.Fatalf("%s: can't decode: %v", name, err)
+	}
+	c.checkFields(name, msg)
+
+	std, err := proto.MarshalOptions{Deterministic: true}.Marshal(msg)
+	if err != nil {
+		c.t.Fatalf("%s: can't encode: %v", name, err)
+	}
+	have, err := reencode(std)
+	if err != nil {
+		c.t.Fatalf("%s: can't decode standard encoding: %v", name, err)
+	}
+	if !bytes.Equal(have, enc) {
+		c.t.Errorf("%s: wrong encoding after round trip\nhave %x\nwant %x", name, have, enc)
+	}
+	return msg
+}
+
+// checkFields checks that all fields of the message are set and that there are no
+// unknown fields, recursing into the nested messages.
+func (c *schemaChecker) checkFields(path string, msg protoreflect.Message) {
+	c.checked[msg.Descriptor().Name()] = true
+	if unknown := msg.GetUnknown(); len(unknown) > 0 {
+		c.t.Errorf("%s: unknown fields %x", path, unknown)
+	}
+	fields := msg.Descriptor().Fields()
+	for i := 0; i < fields.Len(); i++ {
+		field := fields.Get(i)
+		path := path + "." + string(field.Name())
+		if !msg.Has(field) {
+			c.t.Errorf("%s: not set", path)
+			continue
+		}
+		switch {
+		case field.IsList() && field.Message() != nil:
+			list := msg.Get(field).List()
+			for j := 0; j < list.Len(); j++ {
+				c.checkFields(fmt.Sprintf("%s[%d]", path, j), list.Get(j).Message())
+			}
+		case field.Message() != nil:
+			c.checkFields(path, msg.Get(field).Message())
+		}
+	}
+}
+
+// Tests that the hand-written encoding matches the schema in confero.proto.
+func TestSchema(t *testing.T) {
+	c := &schemaChecker{t: t, schema: loadSchema(t), checked: make(map[protoreflect.Name]bool)}
+
+	var (
+		key, _ = crypto.GenerateKey()
+		signer = types.LatestSigner(params.TestChainConfig)
+		tx     = types.MustSignNewTx(key, signer, &types.DynamicFeeTx{ChainID: params.TestChainConfig.ChainID, Nonce: 1, Gas: 21000, GasFeeCap: big.NewInt(2), Data: []byte{1}})
+		header = func(n int64) *types.Header {
+			return &types.Header{
+				ParentHash:  common.Hash{1},
+				UncleHash:   common.Hash{2},
+				Coinbase:    common.Address{3},
+				Root:        common.Hash{4},
+				TxHash:      common.Hash{5},
+				ReceiptHash: common.Hash{6},
+				Bloom:       types.Bloom{7},
+				Difficulty:  big.NewInt(8),
+				Number:      big.NewInt(n),
+				GasLimit:    30000000,
+				GasUsed:     21000,
+				Time:        1650000000,
+				Extra:       []byte("extra"),
+				MixDigest:   common.Hash{9},
+				Nonce:       types.EncodeNonce(10),
+				BaseFee:     big.NewInt(11),
+			}
+		}
+		log = &types.Log{
+			Address:     common.Address{1},
+			Topics:      []common.Hash{{2}},
+			Data:        []byte{3},
+			BlockNumber: 4,
+			TxHash:      common.Hash{5},
+			TxIndex:     6,
+			BlockHash:   common.Hash{7},
+			Index:       8,
+			Removed:     true,
+		}
+		receipt = &types.Receipt{
+			Type:              types.DynamicFeeTxType,
+			PostState:         []byte{1},
+			Status:            types.ReceiptStatusSuccessful,
+			CumulativeGasUsed: 50000,
+			Bloom:             types.Bloom{2},
+			Logs:              []*types.Log{log},
+			TxHash:            common.Hash{3},
+			ContractAddress:   common.Address{4},
+			GasUsed:           30000,
+			BlockHash:         common.Hash{5},
+			BlockNumber:       big.NewInt(6),
+			TransactionIndex:  7,
+		}
+	)
+	c.check("Header", MarshalHeader(header(1)), func(b []byte) ([]byte, error) {
+		h, err := UnmarshalHeader(b)
+		if err != nil {
+			return nil, err
+		}
+		return MarshalHeader(h), nil
+	})
+
+	blockReq := c.check("BlockRequest", (&BlockRequest{Number: rpc.FinalizedBlockNumber, Hash: common.Hash{1}}).Marshal(), func(b []byte) ([]byte, error) {
+		var m BlockRequest
+		err := m.Unmarshal(b)
+		return m.Marshal(), err
+	})
+	if number := blockReq.Get(blockReq.Descriptor().Fields().ByName("number")).Int(); number != int64(rpc.FinalizedBlockNumber) {
+		t.Errorf("BlockRequest: wrong number %d", number)
+	}
+
+	block := types.NewBlock(header(2), []*types.Transaction{tx}, []*types.Header{header(1)}, nil, trie.NewStackTrie(nil))
+	c.check("BlockResponse", (&BlockResponse{Block: block, Senders: []common.Address{{1}}}).Marshal(), func(b []byte) ([]byte, error) {
+		var m BlockResponse
+		err := m.Unmarshal(b)
+		return m.Marshal(), err
+	})
+	c.check("TransactionRequest", (&TransactionRequest{Hash: common.Hash{1}}).Marshal(), func(b []byte) ([]byte, error) {
+		var m TransactionRequest
+		err := m.Unmarshal(b)
+		return m.Marshal(), err
+	})
+	c.check("TransactionResponse", (&TransactionResponse{Tx: tx, BlockHash: common.Hash{1}, BlockNumber: 2, Index: 3, Sender: common.Address{4}}).Marshal(), func(b []byte) ([]byte, error) {
+		var m TransactionResponse
+		err := m.Unmarshal(b)
+		return m.Marshal(), err
+	})
+	c.check("ReceiptResponse", (&ReceiptResponse{Receipt: receipt}).Marshal(), func(b []byte) ([]byte, error) {
+		var m ReceiptResponse
+		err := m.Unmarshal(b)
+		return m.Marshal(), err
+	})
+	c.check("BlockReceiptsResponse", (&BlockReceiptsResponse{BlockHash: common.Hash{1}, Receipts: types.Receipts{receipt}}).Marshal(), func(b []byte) ([]byte, error) {
+		var m BlockReceiptsResponse
+		err := m.Unmarshal(b)
+		return m.Marshal(), err
+	})
+
+	var (
+		from = rpc.BlockNumber(1)
+		to   = rpc.LatestBlockNumber
+		hash = common.Hash{1}
+	)
+	logsReq := c.check("LogsRequest", (&LogsRequest{FromBlock: &from, ToBlock: &to, BlockHash: &hash, Addresses: []common.Address{{2}}, Topics: [][]common.Hash{{{3}}}}).Marshal(), func(b []byte) ([]byte, error) {
+		var m LogsRequest
+		err := m.Unmarshal(b)
+		return m.Marshal(), err
+	})
+	if number := logsReq.Get(logsReq.Descriptor().Fields().ByName("to_block")).Int(); number != int64(rpc.LatestBlockNumber) {
+		t.Errorf("LogsRequest: wrong to block %d", number)
+	}
+	c.check("LogsResponse", (&LogsResponse{Logs: []*types.Log{log}}).Marshal(), func(b []byte) ([]byte, error) {
+		var m LogsResponse
+		err := m.Unmarshal(b)
+		return m.Marshal(), err
+	})
+	c.check("HeadsRequest", nil, func(b []byte) ([]byte, error) {
+		return nil, nil
+	})
+
+	// All messages of the schema must be covered.
+	messages := c.schema.Messages()
+	for i := 0; i < messages.Len(); i++ {
+		if name := messages.Get(i).Name(); !c.checked[name] {
+			t.Errorf("message %s not checked", name)
+		}
+	}
+}
@@ -0,0 +1,165 @@
+// Copyright 2022 The go-confero Authors
+// This file is part of the go-confero library.
+//
+// The go-confero library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-confero library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-confero library. If not, see <http://www.gnu.org/licenses/>.
+
+package pb
+
+import (
+	"fmt"
+	"math/big"
+
+	"github.com/confero-network/go-confero/common"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// field is a decoded field of a message.
+type field struct {
+	num   protowire.Number
+	typ   protowire.Type
+	value uint64 // value of varint and fixed fields
+	data  []byte // value of length-delimited fields
+}
+
+// decode calls fn for every field of the message, in encoding order.
+func decode(b []byte, fn func(f *field) error) error {
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		b = b[n:]
+
+		f := &field{num: num, typ: typ}
+		switch typ {
+		case protowire.VarintType:
+			f.value, n = protowire.ConsumeVarint(b)
+		case protowire.Fixed64Type:
+			f.value, n = protowire.ConsumeFixed64(b)
+		case protowire.Fixed32Type:
+			var v uint32
+			v, n = protowire.ConsumeFixed32(b)
+			f.value = uint64(v)
+		case protowire.BytesType:
+			f.data, n = protowire.ConsumeBytes(b)
+		default:
+			n = protowire.ConsumeFieldValue(num, typ, b)
+		}
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		b = b[n:]
+		if err := fn(f); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// bytes returns a copy of the value of a length-delimited field.
+func (f *field) bytes() []byte {
+	return common.CopyBytes(f.data)
+}
+
+// fixed copies the value of a length-delimited field of a fixed size into dst.
+func (f *field) fixed(dst []byte) error {
+	if len(f.data) != len(dst) {
+		return fmt.Errorf("field %d has length %d, want %d", f.num, len(f.data), len(dst))
+	}
+	copy(dst, f.data)
+	return nil
+}
+
+// hash returns the value of a hash field.
+func (f *field) hash() (h common.Hash, err error) {
+	err = f.fixed(h[:])
+	return h, err
+}
+
+// address returns the value of an address field.
+func (f *field) address() (a common.Address, err error) {
+	err = f.fixed(a[:])
+	return a, err
+}
+
+// big returns the value of a big integer field.
+func (f *field) big() *big.Int {
+	return new(big.Int).SetBytes(f.data)
+}
+
+// int returns the value of a signed integer field.
+func (f *field) int() int64 {
+	return protowire.DecodeZigZag(f.value)
+}
+
+// appendBytes appends a length-delimited field, unless it is empty.
+func appendBytes(b []byte, num protowire.Number, v []byte) []byte {
+	if len(v) == 0 {
+		return b
+	}
+	return appendMessage(b, num, v)
+}
+
+// appendMessage appends a length-delimited field, even if it is empty.
+func appendMessage(b []byte, num protowire.Number, v []byte) []byte {
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendBytes(b, v)
+}
+
+// appendHash appends a hash field, unless it is zero.
+func appendHash(b []byte, num protowire.Number, h common.Hash) []byte {
+	if h == (common.Hash{}) {
+		return b
+	}
+	return appendMessage(b, num, h[:])
+}
+
+// appendAddress appends an address field, unless it is zero.
+func appendAddress(b []byte, num protowire.Number, a common.Address) []byte {
+	if a == (common.Address{}) {
+		return b
+	}
+	return appendMessage(b, num, a[:])
+}
+
+// appendBig appends a big integer field, unless it is nil or zero.
+func appendBig(b []byte, num protowire.Number, v *big.Int) []byte {
+	if v == nil || v.Sign() == 0 {
+		return b
+	}
+	return appendMessage(b, num, v.Bytes())
+}
+
+// appendUint appends an unsigned integer field, unless it is zero.
+func appendUint(b []byte, num protowire.Number, v uint64) []byte {
+	if v == 0 {
+		return b
+	}
+	b = protowire.AppendTag(b, num, protowire.VarintType)
+	return protowire.AppendVarint(b, v)
+}
+
+// appendInt appends a signed integer field, even if it is zero.
+func appendInt(b []byte, num protowire.Number, v int64) []byte {
+	b = protowire.AppendTag(b, num, protowire.VarintType)
+	return protowire.AppendVarint(b, protowire.EncodeZigZag(v))
+}
+
+// appendBool appends a boolean field, unless it is false.
+func appendBool(b []byte, num protowire.Number, v bool) []byte {
+	if !v {
+		return b
+	}
+	return appendUint(b, num, 1)
+}
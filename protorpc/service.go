@@ -0,0 +1,258 @@
+// Copyright 2022 The go-confero Authors
+// This file is part of the go-confero library.
+//
+// The go-confero library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-confero library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-confero library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package protorpc implements the Protobuf-over-HTTP API of the node, a binary
+// alternative to JSON-RPC for the bulk retrieval of blocks, transactions,
+// receipts and logs. The API is defined in pb/confero.proto.
+package protorpc
+
+import (
+	"context"
+	"errors"
+	"math/big"
+
+	"github.com/confero-network/go-confero/common"
+	"github.com/confero-network/go-confero/core/types"
+	"github.com/confero-network/go-confero/eth/filters"
+	"github.com/confero-network/go-confero/internal/ethapi"
+	"github.com/confero-network/go-confero/node"
+	"github.com/confero-network/go-confero/protorpc/pb"
+	"github.com/confero-network/go-confero/rpc"
+)
+
+// Path is the path on which the API is served by the HTTP server.
+const Path = "/proto/"
+
+// headQueueSize is the number of headers buffered for a head subscription.
+// Subscriptions of clients falling further behind are ended.
+const headQueueSize = 128
+
+var errSlowClient = errors.New("client too slow to receive the new heads")
+
+// service answers the calls of the API.
+type service struct {
+	backend      ethapi.Backend
+	filterSystem *filters.FilterSystem
+	events       *filters.EventSystem
+}
+
+// New registers the Protobuf-over-HTTP API on the HTTP server of the node.
+func New(stack *node.Node, backend ethapi.Backend, filterSystem *filters.FilterSystem, cors, vhosts []string) {
+	stack.RegisterProtoServer("Protobuf API", Path, newServer(backend, filterSystem), cors, vhosts)
+}
+
+// newServer creates the server of the API.
+func newServer(backend ethapi.Backend, filterSystem *filters.FilterSystem) *rpc.ProtoServer {
+	s := &service{
+		backend:      backend,
+		filterSystem: filterSystem,
+		events:       filters.NewEventSystem(filterSystem, false),
+	}
+	srv := rpc.NewProtoServer()
+	srv.RegisterMethod(pb.MethodGetBlock, s.getBlock)
+	srv.RegisterMethod(pb.MethodGetTransaction, s.getTransaction)
+	srv.RegisterMethod(pb.MethodGetReceipt, s.getReceipt)
+	srv.RegisterMethod(pb.MethodGetBlockReceipts, s.getBlockReceipts)
+	srv.RegisterMethod(pb.MethodGetLogs, s.getLogs)
+	srv.RegisterStream(pb.MethodSubscribeNewHeads, s.subscribeNewHeads)
+	return srv
+}
+
+// block returns the block selected by a request, or nil if it doesn't exist.
+func (s *service) block(ctx context.Context, req *pb.BlockRequest) (*types.Block, error) {
+	if req.Hash != (common.Hash{}) {
+		return s.backend.BlockByHash(ctx, req.Hash)
+	}
+	block, err := s.backend.BlockByNumber(ctx, req.Number)
+	if block == nil && req.Number < 0 {
+		// Missing tagged blocks are reported as errors by the backend.
+		return nil, nil
+	}
+	return block, err
+}
+
+// getBlock returns a block along with the senders of its transactions.
+func (s *service) getBlock(ctx context.Context, msg []byte) ([]byte, error) {
+	var req pb.BlockRequest
+	if err := req.Unmarshal(msg); err != nil {
+		return nil, &invalidRequestError{err}
+	}
+	block, err := s.block(ctx, &req)
+	if err != nil || block == nil {
+		return nil, err
+	}
+	var (
+		signer  = types.MakeSigner(s.backend.ChainConfig(), block.Number())
+		senders = make([]common.Address, len(block.Transactions()))
+	)
+	for i, tx := range block.Transactions() {
+		if senders[i], err = types.Sender(signer, tx); err != nil {
+			return nil, err
+		}
+	}
+	resp := &pb.BlockResponse{Block: block, Senders: senders}
+	return resp.Marshal(), nil
+}
+
+// getTransaction returns a transaction of the chain or of the transaction pool.
+func (s *service) getTransaction(ctx context.Context, msg []byte) ([]byte, error) {
+	var req pb.TransactionRequest
+	if err := req.Unmarshal(msg); err != nil {
+		return nil, &invalidRequestError{err}
+	}
+	tx, blockHash, blockNumber, index, err := s.backend.GetTransaction(ctx, req.Hash)
+	if err != nil {
+		return nil, err
+	}
+	resp := &pb.TransactionResponse{Tx: tx, BlockHash: blockHash, BlockNumber: blockNumber, Index: index}
+	if tx == nil {
+		if resp.Tx = s.backend.GetPoolTransaction(req.Hash); resp.Tx == nil {
+			return nil, nil
+		}
+	}
+	number := s.backend.CurrentHeader().Number
+	if tx != nil {
+		number = new(big.Int).SetUint64(blockNumber)
+	}
+	if resp.Sender, err = types.Sender(types.MakeSigner(s.backend.ChainConfig(), number), resp.Tx); err != nil {
+		return nil, err
+	}
+	return resp.Marshal(), nil
+}
+
+// getReceipt returns the receipt of a transaction.
+func (s *service) getReceipt(ctx context.Context, msg []byte) ([]byte, error) {
+	var req pb.TransactionRequest
+	if err := req.Unmarshal(msg); err != nil {
+		return nil, &invalidRequestError{err}
+	}
+	tx, blockHash, _, index, err := s.backend.GetTransaction(ctx, req.Hash)
+	if err != nil || tx == nil {
+		return nil, err
+	}
+	receipts, err := s.backend.GetReceipts(ctx, blockHash)
+	if err != nil {
+		return nil, err
+	}
+	if uint64(len(receipts)) <= index {
+		return nil, nil
+	}
+	resp := &pb.ReceiptResponse{Receipt: receipts[index]}
+	return resp.Marshal(), nil
+}
+
+// getBlockReceipts returns the receipts of all transactions of a block.
+func (s *service) getBlockReceipts(ctx context.Context, msg []byte) ([]byte, error) {
+	var req pb.BlockRequest
+	if err := req.Unmarshal(msg); err != nil {
+		return nil, &invalidRequestError{err}
+	}
+	block, err := s.block(ctx, &req)
+	if err != nil || block == nil {
+		return nil, err
+	}
+	receipts, err := s.backend.GetReceipts(ctx, block.Hash())
+	if err != nil {
+		return nil, err
+	}
+	resp := &pb.BlockReceiptsResponse{BlockHash: block.Hash(), Receipts: receipts}
+	return resp.Marshal(), nil
+}
+
+// getLogs returns the logs matching a filter, within the limits of the filter
+// system.
+func (s *service) getLogs(ctx context.Context, msg []byte) ([]byte, error) {
+	var req pb.LogsRequest
+	if err := req.Unmarshal(msg); err != nil {
+		return nil, &invalidRequestError{err}
+	}
+	var filter *filters.Filter
+	if req.BlockHash != nil {
+		if req.FromBlock != nil || req.ToBlock != nil {
+			return nil, &invalidRequestError{errors.New("cannot specify both block hash and block range")}
+		}
+		filter = s.filterSystem.NewBlockFilter(*req.BlockHash, req.Addresses, req.Topics)
+	} else {
+		begin, end := rpc.LatestBlockNumber, rpc.LatestBlockNumber
+		if req.FromBlock != nil {
+			begin = *req.FromBlock
+		}
+		if req.ToBlock != nil {
+			end = *req.ToBlock
+		}
+		filter = s.filterSystem.NewRangeFilter(begin.Int64(), end.Int64(), req.Addresses, req.Topics)
+	}
+	logs, err := filter.Logs(ctx)
+	if err != nil {
+		return nil, err
+	}
+	resp := &pb.LogsResponse{Logs: logs}
+	return resp.Marshal(), nil
+}
+
+// subscribeNewHeads delivers the headers of the new heads of the chain.
+func (s *service) subscribeNewHeads(ctx context.Context, msg []byte, send func([]byte) error) error {
+	var (
+		headers = make(chan *types.Header)
+		sub     = s.events.SubscribeNewHeads(headers)
+		queue   = make(chan *types.Header, headQueueSize)
+		failed  = make(chan error, 1)
+		done    = make(chan struct{})
+	)
+	defer sub.Unsubscribe()
+
+	// The headers are written by a separate goroutine, so the event system
+	// doesn't wait for the client. It must be done writing when returning.
+	ctx, cancel := context.WithCancel(ctx)
+	defer func() {
+		cancel()
+		<-done
+	}()
+	go func() {
+		defer close(done)
+		for {
+			select {
+			case header := <-queue:
+				if err := send(pb.MarshalHeader(header)); err != nil {
+					failed <- err
+					return
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	for {
+		select {
+		case header := <-headers:
+			select {
+			case queue <- header:
+			default:
+				return errSlowClient
+			}
+		case err := <-failed:
+			return err
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// invalidRequestError is returned for requests which can't be decoded.
+type invalidRequestError struct{ err error }
+
+func (e *invalidRequestError) Error() string  { return "invalid request: " + e.err.Error() }
+func (e *invalidRequestError) ErrorCode() int { return -32602 }
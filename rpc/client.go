@@ -77,6 +77,7 @@ type Client struct {
 	idgen    func() ID // for subscriptions
 	isHTTP   bool      // connection type: http, ws or ipc
 	services *serviceRegistry
+	config   connConfig // settings of connections served by a Server
 
 	idCounter uint32
 
@@ -113,7 +114,14 @@ func (c *Client) newClientConn(conn ServerCodec) *clientConn {
 	ctx := context.Background()
 	ctx = context.WithValue(ctx, clientContextKey{}, c)
 	ctx = context.WithValue(ctx, peerInfoContextKey{}, conn.peerInfo())
+	if c.config.filter != nil {
+		ctx = WithCallFilter(ctx, c.config.filter)
+	}
+	if c.config.limitKey != "" {
+		ctx = WithRateLimitKey(ctx, c.config.limitKey)
+	}
 	handler := newHandler(ctx, conn, c.idgen, c.services)
+	handler.limiter = c.config.limiter
 	return &clientConn{conn, handler}
 }
 
@@ -199,17 +207,18 @@ func newClient(initctx context.Context, connect reconnectFunc) (*Client, error)
 	if err != nil {
 		return nil, err
 	}
-	c := initClient(conn, randomIDGenerator(), new(serviceRegistry))
+	c := initClient(conn, randomIDGenerator(), new(serviceRegistry), connConfig{})
 	c.reconnectFunc = connect
 	return c, nil
 }
 
-func initClient(conn ServerCodec, idgen func() ID, services *serviceRegistry) *Client {
+func initClient(conn ServerCodec, idgen func() ID, services *serviceRegistry, config connConfig) *Client {
 	_, isHTTP := conn.(*httpConn)
 	c := &Client{
 		isHTTP:      isHTTP,
 		idgen:       idgen,
 		services:    services,
+		config:      config,
 		writeConn:   conn,
 		close:       make(chan struct{}),
 		closing:     make(chan struct{}),
@@ -26,6 +26,7 @@ import (
 	"time"
 
 	"github.com/confero-network/go-confero/log"
+	"github.com/confero-network/go-confero/tracing"
 )
 
 // handler handles JSON-RPC messages. There is one handler per connection. Note that
@@ -61,6 +62,7 @@ type handler struct {
 	conn           jsonWriter                     // where responses will be sent
 	log            log.Logger
 	allowSubscribe bool
+	limiter        *rateLimiter // per-client call limits, nil if disabled
 
 	subLock    sync.Mutex
 	serverSubs map[ID]*Subscription
@@ -112,11 +114,41 @@ func (h *handler) handleBatch(msgs []*jsonrpcMessage) {
 	if len(calls) == 0 {
 		return
 	}
+	// Reject calls over the limits before starting a goroutine for them:
+	release, ok := h.acquireCallSlot()
+	if !ok {
+		var answers []*jsonrpcMessage
+		for _, msg := range calls {
+			if msg.isCall() {
+				answers = append(answers, msg.errorResponse(concurrencyLimitError()))
+			}
+		}
+		if len(answers) > 0 {
+			h.conn.writeJSON(h.rootCtx, answers)
+		}
+		return
+	}
+	rejected := make(map[*jsonrpcMessage]error)
+	for _, msg := range calls {
+		if err := h.limitCall(msg); err != nil {
+			rejected[msg] = err
+		}
+	}
 	// Process calls on a goroutine because they may block indefinitely:
 	h.startCallProc(func(cp *callProc) {
+		defer release()
+
 		answers := make([]*jsonrpcMessage, 0, len(msgs))
 		for _, msg := range calls {
-			if answer := h.handleCallMsg(cp, msg); answer != nil {
+			var answer *jsonrpcMessage
+			if err := rejected[msg]; err != nil {
+				if msg.isCall() {
+					answer = msg.errorResponse(err)
+				}
+			} else {
+				answer = h.handleCallMsg(cp, msg)
+			}
+			if answer != nil {
 				answers = append(answers, answer)
 			}
 		}
@@ -135,7 +167,20 @@ func (h *handler) handleMsg(msg *jsonrpcMessage) {
 	if ok := h.handleImmediate(msg); ok {
 		return
 	}
+	// Reject calls over the limits before starting a goroutine for them:
+	release, ok := h.acquireCallSlot()
+	if !ok {
+		h.rejectCall(msg, concurrencyLimitError())
+		return
+	}
+	if err := h.limitCall(msg); err != nil {
+		release()
+		h.rejectCall(msg, err)
+		return
+	}
 	h.startCallProc(func(cp *callProc) {
+		defer release()
+
 		answer := h.handleCallMsg(cp, msg)
 		h.addSubscriptions(cp.notifiers)
 		if answer != nil {
@@ -227,6 +272,34 @@ func (h *handler) startCallProc(fn func(*callProc)) {
 	}()
 }
 
+// acquireCallSlot reserves a slot for running a call goroutine. It returns the
+// function releasing the slot, or false if the client already runs the maximum
+// number of calls.
+func (h *handler) acquireCallSlot() (func(), bool) {
+	if h.limiter == nil {
+		return func() {}, true
+	}
+	return h.limiter.acquire(rateLimitKey(h.rootCtx))
+}
+
+// limitCall takes the call from the budgets of the client. It returns an error if
+// the client is over its limits.
+func (h *handler) limitCall(msg *jsonrpcMessage) error {
+	if h.limiter == nil || msg.Method == "" {
+		return nil
+	}
+	return h.limiter.allow(rateLimitKey(h.rootCtx), msg.Method)
+}
+
+// rejectCall answers a call rejected because of the limits. Rejected notifications
+// are dropped.
+func (h *handler) rejectCall(msg *jsonrpcMessage, err error) {
+	h.log.Debug("Rejected "+msg.Method, "reqid", idForLog{msg.ID}, "err", err)
+	if msg.isCall() {
+		h.conn.writeJSON(h.rootCtx, msg.errorResponse(err))
+	}
+}
+
 // handleImmediate executes non-call messages. It returns false if the message is a
 // call or requires a reply.
 func (h *handler) handleImmediate(msg *jsonrpcMessage) bool {
@@ -317,6 +390,11 @@ func (h *handler) handleCallMsg(ctx *callProc, msg *jsonrpcMessage) *jsonrpcMess
 
 // handleCall processes method calls.
 func (h *handler) handleCall(cp *callProc, msg *jsonrpcMessage) *jsonrpcMessage {
+	if filter := CallFilterFromContext(cp.ctx); filter != nil {
+		if err := filter(cp.ctx, msg.Method); err != nil {
+			return msg.errorResponse(err)
+		}
+	}
 	if msg.isSubscribe() {
 		return h.handleSubscribe(cp, msg)
 	}
@@ -334,7 +412,9 @@ func (h *handler) handleCall(cp *callProc, msg *jsonrpcMessage) *jsonrpcMessage
 		return msg.errorResponse(&invalidParamsError{err.Error()})
 	}
 	start := time.Now()
-	answer := h.runMethod(cp.ctx, msg, callb, args)
+	ctx, span := startCallTrace(cp.ctx, msg)
+	answer := h.runMethod(ctx, msg, callb, args)
+	endCallTrace(span, answer)
 
 	// Collect the statistics for RPC calls if metrics is enabled.
 	// We only care about pure rpc call. Filter out subscription.
@@ -393,6 +473,32 @@ func (h *handler) runMethod(ctx context.Context, msg *jsonrpcMessage, callb *cal
 	return msg.response(result)
 }
 
+// startCallTrace starts the trace of a method call if tracing is enabled.
+func startCallTrace(ctx context.Context, msg *jsonrpcMessage) (context.Context, *tracing.Span) {
+	if !tracing.Enabled() {
+		return ctx, nil
+	}
+	info := PeerInfoFromContext(ctx)
+	return tracing.StartTrace(ctx, msg.Method, info.HTTP.TraceParent,
+		tracing.String("rpc.system", "jsonrpc"),
+		tracing.String("rpc.method", msg.Method),
+		tracing.String("rpc.transport", info.Transport),
+	)
+}
+
+// endCallTrace finishes the trace of a method call, recording the error of
+// the answer.
+func endCallTrace(span *tracing.Span, answer *jsonrpcMessage) {
+	if span == nil {
+		return
+	}
+	if answer.Error != nil {
+		span.SetAttributes(tracing.Int("rpc.jsonrpc.error_code", answer.Error.Code))
+		span.SetError(answer.Error)
+	}
+	span.End()
+}
+
 // unsubscribe is the callback function for all *_unsubscribe calls.
 func (h *handler) unsubscribe(ctx context.Context, id ID) (bool, error) {
 	h.subLock.Lock()
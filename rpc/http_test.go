@@ -17,10 +17,15 @@
 package rpc
 
 import (
+	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
+
+	"github.com/confero-network/go-confero/tracing"
 )
 
 func confirmStatusCode(t *testing.T, got, want int) {
@@ -198,3 +203,71 @@ func TestHTTPPeerInfo(t *testing.T) {
 		t.Errorf("wrong HTTP.Origin %q", info.HTTP.UserAgent)
 	}
 }
+
+func TestHTTPTracing(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "traces.json")
+	if err := tracing.Setup(tracing.Config{File: file, SampleRatio: 1}); err != nil {
+		t.Fatal(err)
+	}
+	defer tracing.Stop()
+
+	s := newTestServer()
+	defer s.Stop()
+	ts := httptest.NewServer(s)
+	defer ts.Close()
+
+	c, err := Dial(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	traceparent := "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	c.SetHeader("traceparent", traceparent)
+
+	var info PeerInfo
+	if err := c.Call(&info, "test_peerInfo"); err != nil {
+		t.Fatal(err)
+	}
+	if info.HTTP.TraceParent != traceparent {
+		t.Errorf("wrong HTTP.TraceParent %q", info.HTTP.TraceParent)
+	}
+	if err := c.Call(nil, "test_returnError"); err == nil {
+		t.Fatal("expected error")
+	}
+	tracing.Stop()
+
+	// Check the exported spans, continuing the trace of the caller.
+	data, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var req struct {
+		ResourceSpans []struct {
+			ScopeSpans []struct {
+				Spans []struct {
+					TraceID      string `json:"traceId"`
+					ParentSpanID string `json:"parentSpanId"`
+					Name         string `json:"name"`
+					Status       struct {
+						Code int `json:"code"`
+					} `json:"status"`
+				} `json:"spans"`
+			} `json:"scopeSpans"`
+		} `json:"resourceSpans"`
+	}
+	if err := json.Unmarshal(data, &req); err != nil {
+		t.Fatal(err)
+	}
+	spans := req.ResourceSpans[0].ScopeSpans[0].Spans
+	if len(spans) != 2 {
+		t.Fatalf("wrong number of spans %d", len(spans))
+	}
+	for i, name := range []string{"test_peerInfo", "test_returnError"} {
+		span := spans[i]
+		if span.Name != name || span.TraceID != "4bf92f3577b34da6a3ce929d0e0e4736" || span.ParentSpanID != "00f067aa0ba902b7" {
+			t.Errorf("wrong span %d: %+v", i, span)
+		}
+	}
+	if spans[0].Status.Code != 0 || spans[1].Status.Code != 2 {
+		t.Errorf("wrong span status %d, %d", spans[0].Status.Code, spans[1].Status.Code)
+	}
+}
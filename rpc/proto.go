@@ -0,0 +1,516 @@
+// Copyright 2022 The go-confero Authors
+// This file is part of the go-confero library.
+//
+// The go-confero library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-confero library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-confero library. If not, see <http://www.gnu.org/licenses/>.
+
+package rpc
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"sync"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// The Protobuf-over-HTTP transport is a binary alternative to JSON-RPC for bulk
+// data retrieval. Every call is a POST request to the path of the transport
+// followed by the method name, with the encoded request message as body. The
+// response body is a Response message:
+//
+//	message Response {
+//	  bytes result = 1; // encoded response message
+//	  Error error = 2;  // set if the call failed
+//	}
+//
+//	message Error {
+//	  sint64 code = 1;
+//	  string message = 2;
+//	}
+//
+// Streaming methods answer with a sequence of Response messages, each preceded
+// by its length as a varint. The stream ends with the response body, after a
+// Response carrying an error if the method failed or the server is stopping.
+//
+// Call filters and rate limits check the methods as JSON-RPC methods of the
+// proto namespace, e.g. proto_GetBlock.
+const (
+	protoContentType       = "application/x-protobuf"
+	protoStreamContentType = "application/x-protobuf-stream"
+
+	// maxProtoResponseLength is the maximum size of a response message accepted
+	// by the client.
+	maxProtoResponseLength = 256 * 1024 * 1024
+
+	// protoNamespace is the namespace of the methods checked by call filters and
+	// rate limits.
+	protoNamespace = "proto"
+)
+
+// errProtoShutdown ends the streams running when the server stops.
+var errProtoShutdown = &protoError{code: defaultErrorCode, message: "server is shutting down"}
+
+// ProtoMethod is a method of the Protobuf-over-HTTP transport. It receives the
+// encoded request message and returns the encoded response message.
+type ProtoMethod func(ctx context.Context, req []byte) ([]byte, error)
+
+// ProtoStreamMethod is a streaming method of the Protobuf-over-HTTP transport.
+// It delivers encoded messages through send until it fails or the context is
+// canceled, which happens when the client disconnects or the server stops.
+type ProtoStreamMethod func(ctx context.Context, req []byte, send func([]byte) error) error
+
+// ProtoServer serves the methods of the Protobuf-over-HTTP transport.
+type ProtoServer struct {
+	mu      sync.RWMutex
+	methods map[string]ProtoMethod
+	streams map[string]ProtoStreamMethod
+	quit    chan struct{} // closed when the running calls are stopped
+	limiter *rateLimiter  // per-client call limits, nil if disabled
+}
+
+// NewProtoServer creates a server without any methods.
+func NewProtoServer() *ProtoServer {
+	return &ProtoServer{
+		methods: make(map[string]ProtoMethod),
+		streams: make(map[string]ProtoStreamMethod),
+		quit:    make(chan struct{}),
+	}
+}
+
+// RegisterMethod registers a method under the given name.
+func (s *ProtoServer) RegisterMethod(name string, method ProtoMethod) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.methods[name] = method
+}
+
+// RegisterStream registers a streaming method under the given name.
+func (s *ProtoServer) RegisterStream(name string, method ProtoStreamMethod) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.streams[name] = method
+}
+
+// SetRateLimits configures the per-client call limits of the server. A streaming
+// call only counts against the concurrent call limit while it is being started.
+func (s *ProtoServer) SetRateLimits(config RateLimitConfig) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if config.enabled() {
+		s.limiter = newRateLimiter(config)
+	} else {
+		s.limiter = nil
+	}
+}
+
+// Stop cancels the running calls, ending all streams. It is called before the
+// HTTP server shuts down, which waits for the running requests.
+func (s *ProtoServer) Stop() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	close(s.quit)
+	s.quit = make(chan struct{})
+}
+
+// ServeHTTP serves a call of the Protobuf-over-HTTP transport.
+func (s *ProtoServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if code, err := validateProtoRequest(r); err != nil {
+		http.Error(w, err.Error(), code)
+		return
+	}
+	name := path.Base(r.URL.Path)
+	s.mu.RLock()
+	method, stream, quit, limiter := s.methods[name], s.streams[name], s.quit, s.limiter
+	s.mu.RUnlock()
+
+	req, err := io.ReadAll(io.LimitReader(r.Body, maxRequestContentLength+1))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if len(req) > maxRequestContentLength {
+		http.Error(w, fmt.Sprintf("content length too large (>%d)", maxRequestContentLength), http.StatusRequestEntityTooLarge)
+		return
+	}
+	// The call is canceled when the client disconnects or the server stops.
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+	go func() {
+		select {
+		case <-quit:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	connInfo := PeerInfo{Transport: "proto", RemoteAddr: r.RemoteAddr}
+	connInfo.HTTP.Version = r.Proto
+	connInfo.HTTP.Host = r.Host
+	connInfo.HTTP.Origin = r.Header.Get("Origin")
+	connInfo.HTTP.UserAgent = r.Header.Get("User-Agent")
+	ctx = context.WithValue(ctx, peerInfoContextKey{}, connInfo)
+
+	if method == nil && stream == nil {
+		w.Header().Set("content-type", protoContentType)
+		w.Write(encodeProtoResponse(nil, &methodNotFoundError{method: name}))
+		return
+	}
+	release, err := admitProtoCall(ctx, limiter, name)
+	switch {
+	case err != nil && stream != nil:
+		// Rejected streams end with the error, like failing ones.
+		s.serveStream(ctx, w, req, quit, func(context.Context, []byte, func([]byte) error) error { return err })
+	case err != nil:
+		w.Header().Set("content-type", protoContentType)
+		w.Write(encodeProtoResponse(nil, err))
+	case stream != nil:
+		release()
+		s.serveStream(ctx, w, req, quit, stream)
+	default:
+		defer release()
+		result, err := method(ctx, req)
+		w.Header().Set("content-type", protoContentType)
+		w.Write(encodeProtoResponse(result, err))
+	}
+}
+
+// admitProtoCall checks a call against the call filter of the request and the
+// limits of the server. It returns the function releasing the call slot taken
+// from the client.
+func admitProtoCall(ctx context.Context, limiter *rateLimiter, name string) (func(), error) {
+	method := protoNamespace + serviceMethodSeparator + name
+	if filter := CallFilterFromContext(ctx); filter != nil {
+		if err := filter(ctx, method); err != nil {
+			return nil, err
+		}
+	}
+	if limiter == nil {
+		return func() {}, nil
+	}
+	client := rateLimitKey(ctx)
+	release, ok := limiter.acquire(client)
+	if !ok {
+		return nil, concurrencyLimitError()
+	}
+	if err := limiter.allow(client, method); err != nil {
+		release()
+		return nil, err
+	}
+	return release, nil
+}
+
+// serveStream runs a streaming method, writing its messages as they are sent.
+// Streams ended by stopping the server are closed with errProtoShutdown.
+func (s *ProtoServer) serveStream(ctx context.Context, w http.ResponseWriter, req []byte, quit <-chan struct{}, method ProtoStreamMethod) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming is not supported", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("content-type", protoStreamContentType)
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	write := func(result []byte, err error) error {
+		msg := encodeProtoResponse(result, err)
+		frame := protowire.AppendVarint(make([]byte, 0, len(msg)+binary.MaxVarintLen64), uint64(len(msg)))
+		if _, err := w.Write(append(frame, msg...)); err != nil {
+			return err
+		}
+		flusher.Flush()
+		return nil
+	}
+	err := method(ctx, req, func(result []byte) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		return write(result, nil)
+	})
+	switch {
+	case err != nil && ctx.Err() == nil:
+		write(nil, err)
+	case isClosed(quit):
+		write(nil, errProtoShutdown)
+	}
+}
+
+// isClosed reports whether the given channel is closed.
+func isClosed(ch <-chan struct{}) bool {
+	select {
+	case <-ch:
+		return true
+	default:
+		return false
+	}
+}
+
+// validateProtoRequest returns a non-zero response code and error message if
+// the request is invalid.
+func validateProtoRequest(r *http.Request) (int, error) {
+	if r.Method != http.MethodPost {
+		return http.StatusMethodNotAllowed, errors.New("method not allowed")
+	}
+	if r.ContentLength > maxRequestContentLength {
+		err := fmt.Errorf("content length too large (%d>%d)", r.ContentLength, maxRequestContentLength)
+		return http.StatusRequestEntityTooLarge, err
+	}
+	if mt, _, err := mime.ParseMediaType(r.Header.Get("content-type")); err != nil || mt != protoContentType {
+		err := fmt.Errorf("invalid content type, only %s is supported", protoContentType)
+		return http.StatusUnsupportedMediaType, err
+	}
+	return 0, nil
+}
+
+// protoError is an error returned by a method of the Protobuf-over-HTTP transport.
+type protoError struct {
+	code    int
+	message string
+}
+
+func (err *protoError) Error() string {
+	if err.message == "" {
+		return fmt.Sprintf("proto error %d", err.code)
+	}
+	return err.message
+}
+
+func (err *protoError) ErrorCode() int {
+	return err.code
+}
+
+// encodeProtoResponse encodes the Response message of a call.
+func encodeProtoResponse(result []byte, err error) []byte {
+	if err == nil {
+		b := protowire.AppendTag(make([]byte, 0, len(result)+binary.MaxVarintLen64+1), 1, protowire.BytesType)
+		return protowire.AppendBytes(b, result)
+	}
+	code := defaultErrorCode
+	if e, ok := err.(Error); ok {
+		code = e.ErrorCode()
+	}
+	var msg []byte
+	msg = protowire.AppendTag(msg, 1, protowire.VarintType)
+	msg = protowire.AppendVarint(msg, protowire.EncodeZigZag(int64(code)))
+	msg = protowire.AppendTag(msg, 2, protowire.BytesType)
+	msg = protowire.AppendString(msg, err.Error())
+
+	b := protowire.AppendTag(nil, 2, protowire.BytesType)
+	return protowire.AppendBytes(b, msg)
+}
+
+// decodeProtoResponse decodes the Response message of a call.
+func decodeProtoResponse(b []byte) ([]byte, error) {
+	var (
+		result  []byte
+		failure *protoError
+	)
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			return nil, protowire.ParseError(n)
+		}
+		b = b[n:]
+		switch {
+		case num == 1 && typ == protowire.BytesType:
+			result, n = protowire.ConsumeBytes(b)
+		case num == 2 && typ == protowire.BytesType:
+			var msg []byte
+			if msg, n = protowire.ConsumeBytes(b); n >= 0 {
+				failure = new(protoError)
+				if err := decodeProtoError(msg, failure); err != nil {
+					return nil, err
+				}
+			}
+		default:
+			n = protowire.ConsumeFieldValue(num, typ, b)
+		}
+		if n < 0 {
+			return nil, protowire.ParseError(n)
+		}
+		b = b[n:]
+	}
+	if failure != nil {
+		return nil, failure
+	}
+	return result, nil
+}
+
+// decodeProtoError decodes an Error message.
+func decodeProtoError(b []byte, err *protoError) error {
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		b = b[n:]
+		switch {
+		case num == 1 && typ == protowire.VarintType:
+			var v uint64
+			if v, n = protowire.ConsumeVarint(b); n >= 0 {
+				err.code = int(protowire.DecodeZigZag(v))
+			}
+		case num == 2 && typ == protowire.BytesType:
+			err.message, n = protowire.ConsumeString(b)
+		default:
+			n = protowire.ConsumeFieldValue(num, typ, b)
+		}
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		b = b[n:]
+	}
+	return nil
+}
+
+// ProtoClient is a client of the Protobuf-over-HTTP transport.
+type ProtoClient struct {
+	client *http.Client
+	url    string
+
+	mu      sync.Mutex // protects headers
+	headers http.Header
+}
+
+// DialProto creates a client of the Protobuf-over-HTTP transport served at the
+// given URL.
+func DialProto(endpoint string) (*ProtoClient, error) {
+	return DialProtoWithClient(endpoint, new(http.Client))
+}
+
+// DialProtoWithClient creates a client of the Protobuf-over-HTTP transport served
+// at the given URL, using the provided HTTP client.
+func DialProtoWithClient(endpoint string, client *http.Client) (*ProtoClient, error) {
+	// Sanity check URL so we don't end up with a client that will fail every request.
+	if _, err := url.Parse(endpoint); err != nil {
+		return nil, err
+	}
+	headers := make(http.Header, 2)
+	headers.Set("accept", protoContentType)
+	headers.Set("content-type", protoContentType)
+	return &ProtoClient{
+		client:  client,
+		url:     strings.TrimSuffix(endpoint, "/"),
+		headers: headers,
+	}, nil
+}
+
+// SetHeader adds a custom HTTP header to the client's requests.
+func (c *ProtoClient) SetHeader(key, value string) {
+	c.mu.Lock()
+	c.headers.Set(key, value)
+	c.mu.Unlock()
+}
+
+// Close closes the idle connections of the client.
+func (c *ProtoClient) Close() {
+	c.client.CloseIdleConnections()
+}
+
+// Call calls the given method with the encoded request message and returns the
+// encoded response message.
+func (c *ProtoClient) Call(ctx context.Context, method string, req []byte) ([]byte, error) {
+	body, err := c.doRequest(ctx, method, req)
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+
+	resp, err := io.ReadAll(io.LimitReader(body, maxProtoResponseLength+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(resp) > maxProtoResponseLength {
+		return nil, fmt.Errorf("response too large (>%d)", maxProtoResponseLength)
+	}
+	return decodeProtoResponse(resp)
+}
+
+// Stream calls the given streaming method with the encoded request message. The
+// messages are read from the returned stream, which must be closed by the caller.
+// Canceling the context also ends the stream.
+func (c *ProtoClient) Stream(ctx context.Context, method string, req []byte) (*ProtoStream, error) {
+	body, err := c.doRequest(ctx, method, req)
+	if err != nil {
+		return nil, err
+	}
+	return &ProtoStream{body: body, r: bufio.NewReader(body)}, nil
+}
+
+func (c *ProtoClient) doRequest(ctx context.Context, method string, msg []byte) (io.ReadCloser, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.url+"/"+method, io.NopCloser(bytes.NewReader(msg)))
+	if err != nil {
+		return nil, err
+	}
+	req.ContentLength = int64(len(msg))
+	req.GetBody = func() (io.ReadCloser, error) { return io.NopCloser(bytes.NewReader(msg)), nil }
+
+	c.mu.Lock()
+	req.Header = c.headers.Clone()
+	c.mu.Unlock()
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, maxRequestContentLength))
+		return nil, HTTPError{
+			Status:     resp.Status,
+			StatusCode: resp.StatusCode,
+			Body:       body,
+		}
+	}
+	return resp.Body, nil
+}
+
+// ProtoStream reads the messages of a streaming method.
+type ProtoStream struct {
+	body io.ReadCloser
+	r    *bufio.Reader
+}
+
+// Next returns the next encoded message of the stream. It returns io.EOF when
+// the server ends the stream, or the error of the method if it failed.
+func (s *ProtoStream) Next() ([]byte, error) {
+	size, err := binary.ReadUvarint(s.r)
+	if err != nil {
+		return nil, err
+	}
+	if size > maxProtoResponseLength {
+		return nil, fmt.Errorf("response too large (%d>%d)", size, maxProtoResponseLength)
+	}
+	msg := make([]byte, size)
+	if _, err := io.ReadFull(s.r, msg); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	return decodeProtoResponse(msg)
+}
+
+// Close ends the stream.
+func (s *ProtoStream) Close() error {
+	return s.body.Close()
+}
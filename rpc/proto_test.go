@@ -0,0 +1,165 @@
+// Copyright 2022 The go-confero Authors
+// This file is part of the go-confero library.
+//
+// The go-confero library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-confero library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-confero library. If not, see <http://www.gnu.org/licenses/>.
+
+package rpc
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newProtoTestServer() *ProtoServer {
+	srv := NewProtoServer()
+	srv.RegisterMethod("echo", func(ctx context.Context, req []byte) ([]byte, error) {
+		return req, nil
+	})
+	srv.RegisterMethod("fail", func(ctx context.Context, req []byte) ([]byte, error) {
+		return nil, &invalidParamsError{"bad request"}
+	})
+	srv.RegisterStream("count", func(ctx context.Context, req []byte, send func([]byte) error) error {
+		for i := byte(0); i < req[0]; i++ {
+			if err := send([]byte{i}); err != nil {
+				return err
+			}
+		}
+		if len(req) > 1 {
+			return errors.New("stream failed")
+		}
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	return srv
+}
+
+func TestProtoCall(t *testing.T) {
+	srv := newProtoTestServer()
+	httpsrv := httptest.NewServer(srv)
+	defer httpsrv.Close()
+
+	client, err := DialProto(httpsrv.URL + "/proto/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	result, err := client.Call(context.Background(), "echo", []byte("hello"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(result, []byte("hello")) {
+		t.Fatalf("wrong result %q", result)
+	}
+	// Errors carry their code.
+	_, err = client.Call(context.Background(), "fail", nil)
+	if e, ok := err.(Error); !ok || e.ErrorCode() != -32602 || e.Error() != "bad request" {
+		t.Fatalf("wrong error %v", err)
+	}
+	_, err = client.Call(context.Background(), "missing", nil)
+	if e, ok := err.(Error); !ok || e.ErrorCode() != -32601 {
+		t.Fatalf("wrong error for unknown method: %v", err)
+	}
+}
+
+func TestProtoStream(t *testing.T) {
+	srv := newProtoTestServer()
+	httpsrv := httptest.NewServer(srv)
+	defer httpsrv.Close()
+
+	client, _ := DialProto(httpsrv.URL)
+	defer client.Close()
+
+	// A failing stream ends with the error of the method.
+	stream, err := client.Stream(context.Background(), "count", []byte{3, 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := byte(0); i < 3; i++ {
+		msg, err := stream.Next()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(msg, []byte{i}) {
+			t.Fatalf("wrong message %x, want %x", msg, i)
+		}
+	}
+	if _, err := stream.Next(); err == nil || err.Error() != "stream failed" {
+		t.Fatalf("wrong stream error %v", err)
+	}
+	if _, err := stream.Next(); err != io.EOF {
+		t.Fatalf("expected end of stream, got %v", err)
+	}
+	stream.Close()
+
+	// Stopping the server ends the streams.
+	if stream, err = client.Stream(context.Background(), "count", []byte{1}); err != nil {
+		t.Fatal(err)
+	}
+	defer stream.Close()
+	if _, err := stream.Next(); err != nil {
+		t.Fatal(err)
+	}
+	srv.Stop()
+	done := make(chan error, 1)
+	go func() {
+		_, err := stream.Next()
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if err == nil || err.Error() != errProtoShutdown.Error() {
+			t.Fatalf("expected shutdown error, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("stream not ended by server stop")
+	}
+	if _, err := stream.Next(); err != io.EOF {
+		t.Fatalf("expected end of stream, got %v", err)
+	}
+	// Calls made after stopping are served.
+	if _, err := client.Call(context.Background(), "echo", nil); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestProtoRequestValidation(t *testing.T) {
+	srv := newProtoTestServer()
+	tests := []struct {
+		method, contentType string
+		body                string
+		code                int
+	}{
+		{http.MethodGet, protoContentType, "", http.StatusMethodNotAllowed},
+		{http.MethodPost, contentType, "", http.StatusUnsupportedMediaType},
+		{http.MethodPost, protoContentType, string(make([]byte, maxRequestContentLength+1)), http.StatusRequestEntityTooLarge},
+		{http.MethodPost, protoContentType, "", http.StatusOK},
+	}
+	for i, test := range tests {
+		req := httptest.NewRequest(test.method, "http://url.com/echo", strings.NewReader(test.body))
+		req.Header.Set("content-type", test.contentType)
+		resp := httptest.NewRecorder()
+		srv.ServeHTTP(resp, req)
+		if resp.Code != test.code {
+			t.Errorf("test %d: wrong status code %d, want %d", i, resp.Code, test.code)
+		}
+	}
+}
@@ -0,0 +1,252 @@
+// Copyright 2022 The go-confero Authors
+// This file is part of the go-confero library.
+//
+// The go-confero library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-confero library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-confero library. If not, see <http://www.gnu.org/licenses/>.
+
+package rpc
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	lru "github.com/hashicorp/golang-lru"
+	"golang.org/x/time/rate"
+)
+
+const (
+	// maxRateLimitClients is the number of idle clients whose budgets are tracked.
+	// The budgets of the least recently seen clients are dropped when it is
+	// exceeded. Clients with running calls are always tracked.
+	maxRateLimitClients = 10000
+
+	errcodeLimitExceeded = -32005
+)
+
+// DefaultExpensiveMethods are the methods limited by the expensive call budget
+// if no other methods are configured. A trailing "*" matches any method with the
+// given prefix.
+var DefaultExpensiveMethods = []string{"eth_call", "eth_estimateGas", "eth_getLogs", "debug_trace*", "proto_GetLogs"}
+
+// RateLimitConfig is the configuration of the per-client limits of a Server.
+// Clients are identified by the key set with WithRateLimitKey, or by their
+// remote IP address.
+type RateLimitConfig struct {
+	Rate  float64 // calls per second allowed per client, zero for no limit
+	Burst int     // maximum burst of calls per client, defaults to the rate
+
+	ExpensiveRate    float64  // expensive calls per second allowed per client, zero for no limit
+	ExpensiveBurst   int      // maximum burst of expensive calls per client, defaults to the rate
+	ExpensiveMethods []string `toml:",omitempty"` // methods counted as expensive, defaults to DefaultExpensiveMethods
+
+	MaxConcurrent int // maximum number of calls processed at once per client, zero for no limit
+}
+
+// enabled reports whether any limit is configured.
+func (c RateLimitConfig) enabled() bool {
+	return c.Rate > 0 || c.ExpensiveRate > 0 || c.MaxConcurrent > 0
+}
+
+// rateLimitError is returned for calls exceeding a limit of the server.
+type rateLimitError struct {
+	message    string
+	retryAfter time.Duration
+}
+
+func (e *rateLimitError) Error() string {
+	return fmt.Sprintf("%s, retry after %v", e.message, e.retryAfter)
+}
+
+func (e *rateLimitError) ErrorCode() int { return errcodeLimitExceeded }
+
+// ErrorData returns the retry-after hint in seconds.
+func (e *rateLimitError) ErrorData() interface{} {
+	return map[string]interface{}{"retryAfter": math.Ceil(e.retryAfter.Seconds()*1000) / 1000}
+}
+
+// clientLimits are the call budgets of a single client.
+type clientLimits struct {
+	calls     *rate.Limiter // nil if calls are not limited
+	expensive *rate.Limiter // nil if expensive calls are not limited
+	inflight  int           // number of running calls
+}
+
+// rateLimiter enforces the limits of a RateLimitConfig.
+type rateLimiter struct {
+	config RateLimitConfig
+
+	mu      sync.Mutex
+	clients *lru.Cache               // client key -> *clientLimits of recently seen clients
+	active  map[string]*clientLimits // clients with running calls, exempt from eviction
+}
+
+func newRateLimiter(config RateLimitConfig) *rateLimiter {
+	if config.ExpensiveMethods == nil {
+		config.ExpensiveMethods = DefaultExpensiveMethods
+	}
+	clients, _ := lru.New(maxRateLimitClients)
+	return &rateLimiter{config: config, clients: clients, active: make(map[string]*clientLimits)}
+}
+
+// limits returns the budgets of the client. The caller must hold l.mu.
+func (l *rateLimiter) limits(client string) *clientLimits {
+	if limits, ok := l.active[client]; ok {
+		return limits
+	}
+	if limits, ok := l.clients.Get(client); ok {
+		return limits.(*clientLimits)
+	}
+	limits := &clientLimits{
+		calls:     newLimiter(l.config.Rate, l.config.Burst),
+		expensive: newLimiter(l.config.ExpensiveRate, l.config.ExpensiveBurst),
+	}
+	l.clients.Add(client, limits)
+	return limits
+}
+
+// newLimiter creates a token bucket, or returns nil if rate is not positive.
+func newLimiter(r float64, burst int) *rate.Limiter {
+	if r <= 0 {
+		return nil
+	}
+	if burst <= 0 {
+		burst = int(math.Ceil(r))
+	}
+	return rate.NewLimiter(rate.Limit(r), burst)
+}
+
+// expensive reports whether the method is limited by the expensive call budget.
+func (l *rateLimiter) expensive(method string) bool {
+	for _, m := range l.config.ExpensiveMethods {
+		if prefix := strings.TrimSuffix(m, "*"); prefix != m {
+			if strings.HasPrefix(method, prefix) {
+				return true
+			}
+		} else if m == method {
+			return true
+		}
+	}
+	return false
+}
+
+// allow takes a call to the method from the client's budgets. If the client is
+// over its limit, no budget is taken and an error with the time until the call
+// would be allowed is returned.
+func (l *rateLimiter) allow(client string, method string) error {
+	l.mu.Lock()
+	limits := l.limits(client)
+	l.mu.Unlock()
+	now := time.Now()
+
+	var reservations []*rate.Reservation
+	if limits.calls != nil {
+		reservations = append(reservations, limits.calls.ReserveN(now, 1))
+	}
+	if limits.expensive != nil && l.expensive(method) {
+		reservations = append(reservations, limits.expensive.ReserveN(now, 1))
+	}
+	var wait time.Duration
+	for _, r := range reservations {
+		if d := r.DelayFrom(now); d > wait {
+			wait = d
+		}
+	}
+	if wait == 0 {
+		return nil
+	}
+	for _, r := range reservations {
+		r.CancelAt(now)
+	}
+	return &rateLimitError{message: "rate limit exceeded", retryAfter: wait}
+}
+
+// acquire reserves a slot for running a call of the client. The slots are shared
+// by all connections and requests of the client. It returns the function
+// releasing the slot, or false if the client already runs the maximum number of
+// calls. While the client has running calls, its budgets aren't evicted.
+func (l *rateLimiter) acquire(client string) (func(), bool) {
+	if l.config.MaxConcurrent <= 0 {
+		return func() {}, true
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	limits := l.limits(client)
+	if limits.inflight >= l.config.MaxConcurrent {
+		return nil, false
+	}
+	limits.inflight++
+	l.active[client] = limits
+	return func() {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+
+		if limits.inflight--; limits.inflight == 0 {
+			delete(l.active, client)
+			l.clients.Add(client, limits)
+		}
+	}, true
+}
+
+// RateLimiter enforces the call budgets of a RateLimitConfig per client, for
+// limiting calls outside of the RPC server. Budgets are kept for the clients
+// identified by the given keys, like the ones of the server.
+type RateLimiter struct {
+	limiter *rateLimiter
+}
+
+// NewRateLimiter creates a limiter with the given configuration.
+func NewRateLimiter(config RateLimitConfig) *RateLimiter {
+	return &RateLimiter{newRateLimiter(config)}
+}
+
+// Allow takes a call to the method from the budgets of the client. It returns
+// an error carrying the time until the call would be allowed if the client is
+// over its limit.
+func (l *RateLimiter) Allow(client string, method string) error {
+	return l.limiter.allow(client, method)
+}
+
+// concurrencyLimitError is returned for calls exceeding the per-client
+// concurrency limit.
+func concurrencyLimitError() error {
+	return &rateLimitError{message: "too many concurrent calls", retryAfter: time.Second}
+}
+
+type rateLimitKeyContextKey struct{}
+
+// WithRateLimitKey returns a copy of ctx which identifies the client by the given
+// key instead of its remote address when rate limiting its calls. This is used
+// when clients authenticate, such that clients sharing an address have separate
+// budgets, and clients using several addresses share one.
+func WithRateLimitKey(ctx context.Context, key string) context.Context {
+	return context.WithValue(ctx, rateLimitKeyContextKey{}, key)
+}
+
+// rateLimitKey returns the key identifying the client of a connection or request
+// for rate limiting.
+func rateLimitKey(ctx context.Context) string {
+	if key, ok := ctx.Value(rateLimitKeyContextKey{}).(string); ok {
+		return "key:" + key
+	}
+	addr := PeerInfoFromContext(ctx).RemoteAddr
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		addr = host
+	}
+	return "addr:" + addr
+}
@@ -0,0 +1,196 @@
+// Copyright 2022 The go-confero Authors
+// This file is part of the go-confero library.
+//
+// The go-confero library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-confero library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-confero library. If not, see <http://www.gnu.org/licenses/>.
+
+package rpc
+
+import (
+	"context"
+	"fmt"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// limitErrorCode returns the error code of a call, or zero if it succeeded.
+func limitErrorCode(t *testing.T, err error) int {
+	t.Helper()
+	if err == nil {
+		return 0
+	}
+	rpcErr, ok := err.(Error)
+	if !ok {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	return rpcErr.ErrorCode()
+}
+
+func TestRateLimit(t *testing.T) {
+	server := newTestServer()
+	server.SetRateLimits(RateLimitConfig{
+		Rate:             0.001,
+		Burst:            3,
+		ExpensiveRate:    0.001,
+		ExpensiveBurst:   1,
+		ExpensiveMethods: []string{"test_sleep*"},
+	})
+	defer server.Stop()
+	httpsrv := httptest.NewServer(server)
+	defer httpsrv.Close()
+
+	client, err := DialHTTP(httpsrv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	// The expensive budget allows a single call.
+	if err := client.Call(nil, "test_sleep", 0); err != nil {
+		t.Fatal(err)
+	}
+	err = client.Call(nil, "test_sleep", 0)
+	if code := limitErrorCode(t, err); code != errcodeLimitExceeded {
+		t.Fatalf("wrong error code %d for expensive call", code)
+	}
+	// Rejected calls don't take from the budget of cheap calls.
+	if err := client.Call(nil, "test_noArgsRets"); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.Call(nil, "test_noArgsRets"); err != nil {
+		t.Fatal(err)
+	}
+	err = client.Call(nil, "test_noArgsRets")
+	if code := limitErrorCode(t, err); code != errcodeLimitExceeded {
+		t.Fatalf("wrong error code %d for cheap call", code)
+	}
+	data, ok := err.(DataError).ErrorData().(map[string]interface{})
+	if !ok || data["retryAfter"].(float64) <= 0 {
+		t.Fatalf("missing retry-after hint: %v", err.(DataError).ErrorData())
+	}
+	if !strings.Contains(err.Error(), "retry after") {
+		t.Fatalf("wrong error message: %v", err)
+	}
+
+	// Clients identified by a key have separate budgets.
+	ctx := WithRateLimitKey(context.Background(), "other")
+	if have := rateLimitKey(ctx); have != "key:other" {
+		t.Fatalf("wrong rate limit key %q", have)
+	}
+	if err := server.limiter.allow(rateLimitKey(ctx), "test_noArgsRets"); err != nil {
+		t.Fatalf("keyed client limited: %v", err)
+	}
+}
+
+func TestConcurrencyLimit(t *testing.T) {
+	server := newTestServer()
+	server.SetRateLimits(RateLimitConfig{MaxConcurrent: 1})
+	defer server.Stop()
+	httpsrv := httptest.NewServer(server.WebsocketHandler([]string{"*"}))
+	defer httpsrv.Close()
+
+	client, err := DialWebsocket(context.Background(), "ws"+strings.TrimPrefix(httpsrv.URL, "http"), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	// Occupy the only call slot of the connection.
+	done := make(chan error, 1)
+	go func() {
+		done <- client.Call(nil, "test_sleep", 500*time.Millisecond)
+	}()
+	time.Sleep(100 * time.Millisecond)
+
+	err = client.Call(nil, "test_noArgsRets")
+	if code := limitErrorCode(t, err); code != errcodeLimitExceeded {
+		t.Fatalf("wrong error code %d for concurrent call", code)
+	}
+	var results []BatchElem
+	for i := 0; i < 2; i++ {
+		results = append(results, BatchElem{Method: "test_noArgsRets"})
+	}
+	if err := client.BatchCall(results); err != nil {
+		t.Fatal(err)
+	}
+	for i, result := range results {
+		if code := limitErrorCode(t, result.Error); code != errcodeLimitExceeded {
+			t.Fatalf("wrong error code %d for concurrent batch call %d", code, i)
+		}
+	}
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	// The slot is released when the call is done.
+	if err := client.Call(nil, "test_noArgsRets"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestConcurrencyLimitHTTP(t *testing.T) {
+	server := newTestServer()
+	server.SetRateLimits(RateLimitConfig{MaxConcurrent: 1})
+	defer server.Stop()
+	httpsrv := httptest.NewServer(server)
+	defer httpsrv.Close()
+
+	// Every request is served by a new handler, the slots are shared by the
+	// requests of the client.
+	client, err := DialHTTP(httpsrv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	done := make(chan error, 1)
+	go func() {
+		done <- client.Call(nil, "test_sleep", 500*time.Millisecond)
+	}()
+	time.Sleep(100 * time.Millisecond)
+
+	err = client.Call(nil, "test_noArgsRets")
+	if code := limitErrorCode(t, err); code != errcodeLimitExceeded {
+		t.Fatalf("wrong error code %d for concurrent call", code)
+	}
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	if err := client.Call(nil, "test_noArgsRets"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// Tests that the budgets of clients with running calls aren't evicted, so they
+// can't exceed the concurrency limit when many other clients are seen.
+func TestConcurrencyLimitEviction(t *testing.T) {
+	limiter := newRateLimiter(RateLimitConfig{MaxConcurrent: 1})
+
+	release, ok := limiter.acquire("busy")
+	if !ok {
+		t.Fatal("first call rejected")
+	}
+	for i := 0; i < maxRateLimitClients+1; i++ {
+		limiter.allow(fmt.Sprintf("client-%d", i), "test_noArgsRets")
+	}
+	if _, ok := limiter.acquire("busy"); ok {
+		t.Fatal("call slot limit reset by eviction")
+	}
+	release()
+	if release, ok := limiter.acquire("busy"); !ok {
+		t.Fatal("call rejected after release")
+	} else {
+		release()
+	}
+}
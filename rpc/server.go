@@ -47,6 +47,14 @@ type Server struct {
 	idgen    func() ID
 	run      int32
 	codecs   mapset.Set
+	limiter  *rateLimiter // per-client call limits, nil if disabled
+}
+
+// connConfig holds the settings of a connection served by a Server.
+type connConfig struct {
+	filter   CallFilter   // checks incoming calls, may be nil
+	limiter  *rateLimiter // per-client call limits, may be nil
+	limitKey string       // identifies the client to the limiter instead of its address
 }
 
 // NewServer creates a new server instance with no registered handlers.
@@ -59,6 +67,16 @@ func NewServer() *Server {
 	return server
 }
 
+// SetRateLimits configures the per-client call limits of the server. It must be
+// called before the server starts serving requests.
+func (s *Server) SetRateLimits(config RateLimitConfig) {
+	if config.enabled() {
+		s.limiter = newRateLimiter(config)
+	} else {
+		s.limiter = nil
+	}
+}
+
 // RegisterName creates a service for the given receiver type under the given name. When no
 // methods on the given receiver match the criteria to be either a RPC method or a
 // subscription an error is returned. Otherwise a new service is created and added to the
@@ -73,6 +91,11 @@ func (s *Server) RegisterName(name string, receiver interface{}) error {
 //
 // Note that codec options are no longer supported.
 func (s *Server) ServeCodec(codec ServerCodec, options CodecOption) {
+	s.serveCodec(codec, connConfig{limiter: s.limiter})
+}
+
+// serveCodec serves the codec like ServeCodec, with the given connection settings.
+func (s *Server) serveCodec(codec ServerCodec, config connConfig) {
 	defer codec.close()
 
 	// Don't serve if server is stopped.
@@ -84,7 +107,7 @@ func (s *Server) ServeCodec(codec ServerCodec, options CodecOption) {
 	s.codecs.Add(codec)
 	defer s.codecs.Remove(codec)
 
-	c := initClient(codec, s.idgen, &s.services)
+	c := initClient(codec, s.idgen, &s.services, config)
 	<-codec.closed()
 	c.Close()
 }
@@ -99,6 +122,7 @@ func (s *Server) serveSingleRequest(ctx context.Context, codec ServerCodec) {
 	}
 
 	h := newHandler(ctx, codec, s.idgen, &s.services)
+	h.limiter = s.limiter
 	h.allowSubscribe = false
 	defer h.close(io.EOF, nil)
 
@@ -168,6 +192,11 @@ type PeerInfo struct {
 		UserAgent string
 		Origin    string
 		Host      string
+
+		// W3C Trace Context traceparent header sent by the client, which is
+		// used as the parent of the traces of the calls. This is not set for
+		// WebSocket, where it would apply to all calls of the connection.
+		TraceParent string
 	}
 }
 
@@ -181,3 +210,25 @@ func PeerInfoFromContext(ctx context.Context) PeerInfo {
 	info, _ := ctx.Value(peerInfoContextKey{}).(PeerInfo)
 	return info
 }
+
+// CallFilter is checked before every method call of a request. If it returns an
+// error, the call is rejected and the error is sent to the client instead of the
+// result.
+type CallFilter func(ctx context.Context, method string) error
+
+type callFilterContextKey struct{}
+
+// WithCallFilter returns a copy of ctx carrying the given call filter. The filter
+// applies to calls of HTTP requests and WebSocket connections whose request
+// context carries it.
+func WithCallFilter(ctx context.Context, filter CallFilter) context.Context {
+	return context.WithValue(ctx, callFilterContextKey{}, filter)
+}
+
+// CallFilterFromContext returns the call filter carried by ctx, if any. Services
+// served over HTTP outside of the RPC server use it to check their operations
+// against the filter, by the names of the equivalent RPC methods.
+func CallFilterFromContext(ctx context.Context) CallFilter {
+	filter, _ := ctx.Value(callFilterContextKey{}).(CallFilter)
+	return filter
+}
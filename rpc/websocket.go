@@ -61,7 +61,9 @@ func (s *Server) WebsocketHandler(allowedOrigins []string) http.Handler {
 			return
 		}
 		codec := newWebsocketCodec(conn, r.Host, r.Header)
-		s.ServeCodec(codec, 0)
+		config := connConfig{filter: CallFilterFromContext(r.Context()), limiter: s.limiter}
+		config.limitKey, _ = r.Context().Value(rateLimitKeyContextKey{}).(string)
+		s.serveCodec(codec, config)
 	})
 }
 
@@ -94,6 +94,7 @@ func (d *dummyBackend) Chain() *core.BlockChain                { return d.chain
 func (d *dummyBackend) RunPeer(*snap.Peer, snap.Handler) error { return nil }
 func (d *dummyBackend) PeerInfo(enode.ID) interface{}          { return "Foo" }
 func (d *dummyBackend) Handle(*snap.Peer, snap.Packet) error   { return nil }
+func (d *dummyBackend) ServingQueue() *snap.ServingQueue       { return nil }
 
 type dummyRW struct {
 	code       uint64
@@ -0,0 +1,255 @@
+// Copyright 2022 The go-confero Authors
+// This file is part of the go-confero library.
+//
+// The go-confero library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-confero library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-confero library. If not, see <http://www.gnu.org/licenses/>.
+
+package tracing
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"time"
+)
+
+// exporter delivers finished spans.
+type exporter interface {
+	export(service string, spans []*Span) error
+	close() error
+}
+
+// multiExporter delivers the spans to several exporters.
+type multiExporter []exporter
+
+func (m multiExporter) export(service string, spans []*Span) error {
+	var errs []error
+	for _, exp := range m {
+		if err := exp.export(service, spans); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if len(errs) > 0 {
+		return errs[0]
+	}
+	return nil
+}
+
+func (m multiExporter) close() error {
+	var errs []error
+	for _, exp := range m {
+		if err := exp.close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if len(errs) > 0 {
+		return errs[0]
+	}
+	return nil
+}
+
+// otlpExporter sends the spans to an OTLP/HTTP collector, using the JSON
+// encoding of the protocol.
+type otlpExporter struct {
+	endpoint string
+	client   *http.Client
+}
+
+func newOTLPExporter(endpoint string) (*otlpExporter, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("invalid OTLP endpoint %q, must be an http or https URL", endpoint)
+	}
+	return &otlpExporter{endpoint: endpoint, client: &http.Client{Timeout: 10 * time.Second}}, nil
+}
+
+func (e *otlpExporter) export(service string, spans []*Span) error {
+	body, err := json.Marshal(encodeSpans(service, spans))
+	if err != nil {
+		return err
+	}
+	resp, err := e.client.Post(e.endpoint, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("collector returned %s", resp.Status)
+	}
+	return nil
+}
+
+func (e *otlpExporter) close() error {
+	e.client.CloseIdleConnections()
+	return nil
+}
+
+// fileExporter appends the spans to a file, one OTLP JSON request per line.
+type fileExporter struct {
+	file *os.File
+}
+
+func newFileExporter(path string) (*fileExporter, error) {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+	return &fileExporter{file: file}, nil
+}
+
+func (e *fileExporter) export(service string, spans []*Span) error {
+	body, err := json.Marshal(encodeSpans(service, spans))
+	if err != nil {
+		return err
+	}
+	_, err = e.file.Write(append(body, '\n'))
+	return err
+}
+
+func (e *fileExporter) close() error {
+	return e.file.Close()
+}
+
+// The types below are the JSON encoding of the OTLP ExportTraceServiceRequest.
+
+type otlpRequest struct {
+	ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+}
+
+type otlpResourceSpans struct {
+	Resource   otlpResource     `json:"resource"`
+	ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+}
+
+type otlpResource struct {
+	Attributes []otlpAttribute `json:"attributes"`
+}
+
+type otlpScopeSpans struct {
+	Scope otlpScope  `json:"scope"`
+	Spans []otlpSpan `json:"spans"`
+}
+
+type otlpScope struct {
+	Name string `json:"name"`
+}
+
+type otlpSpan struct {
+	TraceID           string          `json:"traceId"`
+	SpanID            string          `json:"spanId"`
+	ParentSpanID      string          `json:"parentSpanId,omitempty"`
+	Name              string          `json:"name"`
+	Kind              int             `json:"kind"`
+	StartTimeUnixNano string          `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string          `json:"endTimeUnixNano"`
+	Attributes        []otlpAttribute `json:"attributes,omitempty"`
+	Status            otlpStatus      `json:"status"`
+}
+
+type otlpAttribute struct {
+	Key   string    `json:"key"`
+	Value otlpValue `json:"value"`
+}
+
+type otlpValue struct {
+	StringValue *string  `json:"stringValue,omitempty"`
+	IntValue    *string  `json:"intValue,omitempty"`
+	BoolValue   *bool    `json:"boolValue,omitempty"`
+	DoubleValue *float64 `json:"doubleValue,omitempty"`
+}
+
+type otlpStatus struct {
+	Code    int    `json:"code,omitempty"`
+	Message string `json:"message,omitempty"`
+}
+
+// Span kinds and status codes of OTLP.
+const (
+	otlpKindInternal = 1
+	otlpKindServer   = 2
+
+	otlpStatusError = 2
+)
+
+// encodeSpans creates the OTLP request exporting the given spans.
+func encodeSpans(service string, spans []*Span) *otlpRequest {
+	encoded := make([]otlpSpan, len(spans))
+	for i, span := range spans {
+		encoded[i] = encodeSpan(span)
+	}
+	return &otlpRequest{
+		ResourceSpans: []otlpResourceSpans{{
+			Resource:   otlpResource{Attributes: []otlpAttribute{encodeAttribute(String("service.name", service))}},
+			ScopeSpans: []otlpScopeSpans{{Scope: otlpScope{Name: "github.com/confero-network/go-confero"}, Spans: encoded}},
+		}},
+	}
+}
+
+func encodeSpan(span *Span) otlpSpan {
+	span.lock.Lock()
+	defer span.lock.Unlock()
+
+	s := otlpSpan{
+		TraceID:           hex.EncodeToString(span.trace.id[:]),
+		SpanID:            hex.EncodeToString(span.id[:]),
+		Name:              span.name,
+		Kind:              otlpKindInternal,
+		StartTimeUnixNano: strconv.FormatInt(span.start.UnixNano(), 10),
+		EndTimeUnixNano:   strconv.FormatInt(span.end.UnixNano(), 10),
+	}
+	if span.parent != (SpanID{}) {
+		s.ParentSpanID = hex.EncodeToString(span.parent[:])
+	}
+	if span.root {
+		s.Kind = otlpKindServer
+	}
+	for _, attr := range span.attrs {
+		s.Attributes = append(s.Attributes, encodeAttribute(attr))
+	}
+	if span.err != "" {
+		s.Status = otlpStatus{Code: otlpStatusError, Message: span.err}
+	}
+	return s
+}
+
+func encodeAttribute(attr Attribute) otlpAttribute {
+	var v otlpValue
+	switch value := attr.Value.(type) {
+	case string:
+		v.StringValue = &value
+	case int64:
+		s := strconv.FormatInt(value, 10)
+		v.IntValue = &s
+	case bool:
+		v.BoolValue = &value
+	case float64:
+		v.DoubleValue = &value
+	case fmt.Stringer:
+		s := value.String()
+		v.StringValue = &s
+	default:
+		s := fmt.Sprint(value)
+		v.StringValue = &s
+	}
+	return otlpAttribute{Key: attr.Key, Value: v}
+}
@@ -0,0 +1,441 @@
+// Copyright 2022 The go-confero Authors
+// This file is part of the go-confero library.
+//
+// The go-confero library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-confero library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-confero library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package tracing implements optional distributed tracing of RPC calls.
+//
+// Traces are started by the RPC server for every call and the spans are carried
+// down through context.Context into the API implementations, the EVM, state and
+// trie reads and database accesses. Finished spans are exported in the
+// OpenTelemetry (OTLP) JSON encoding, to an OTLP/HTTP collector or to a file.
+//
+// Tracing is disabled until Setup is called, and all functions of the package
+// are cheap no-ops while it is.
+package tracing
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/confero-network/go-confero/log"
+)
+
+const (
+	// maxSpansPerTrace bounds the number of spans recorded in a single trace,
+	// so that calls touching a lot of state don't flood the exporter. Spans
+	// beyond the limit are counted in the root span.
+	maxSpansPerTrace = 4096
+
+	// spanQueueSize is the number of finished spans buffered for export.
+	// Spans are dropped if the exporter can't keep up.
+	spanQueueSize = 8192
+
+	// exportBatchSize is the maximum number of spans exported at once.
+	exportBatchSize = 512
+
+	// exportInterval is the time after which a partial batch is exported.
+	exportInterval = 5 * time.Second
+)
+
+// Config contains the settings of tracing.
+type Config struct {
+	Endpoint    string  // URL of the OTLP/HTTP traces endpoint, e.g. http://localhost:4318/v1/traces
+	File        string  // File to append the exported traces to
+	SampleRatio float64 // Fraction of the calls which are traced, between 0 and 1
+	ServiceName string  // Name of the traced service reported to the collector
+}
+
+// TraceID identifies a trace.
+type TraceID [16]byte
+
+// SpanID identifies a span within a trace.
+type SpanID [8]byte
+
+// Attribute is a key-value pair annotating a span.
+type Attribute struct {
+	Key   string
+	Value interface{} // string, int64, bool, float64 or fmt.Stringer
+}
+
+// String creates a string attribute.
+func String(key, value string) Attribute { return Attribute{key, value} }
+
+// Int creates an integer attribute.
+func Int(key string, value int) Attribute { return Attribute{key, int64(value)} }
+
+// Int64 creates an integer attribute.
+func Int64(key string, value int64) Attribute { return Attribute{key, value} }
+
+// Uint64 creates an integer attribute. Values exceeding the range of int64
+// are capped.
+func Uint64(key string, value uint64) Attribute {
+	if value > math.MaxInt64 {
+		value = math.MaxInt64
+	}
+	return Attribute{key, int64(value)}
+}
+
+// Bool creates a boolean attribute.
+func Bool(key string, value bool) Attribute { return Attribute{key, value} }
+
+// Stringer creates a string attribute from a value with a String method. The
+// value is only formatted when the span is exported, so it must not be modified
+// afterwards.
+func Stringer(key string, value fmt.Stringer) Attribute {
+	return Attribute{key, value}
+}
+
+// Big creates a string attribute from a big integer. The integer is only
+// formatted when the span is exported, so it must not be modified afterwards.
+func Big(key string, value *big.Int) Attribute {
+	if value == nil {
+		return Attribute{key, ""}
+	}
+	return Attribute{key, value}
+}
+
+// tracer records the spans and passes them to the exporter.
+type tracer struct {
+	config   Config
+	exporter exporter
+	spans    chan *Span
+	quit     chan chan struct{}
+
+	dropped uint64 // number of spans dropped because the queue was full
+}
+
+// active is the tracer in use, it holds a nil *tracer if tracing is disabled.
+var active atomic.Value
+
+func init() {
+	active.Store((*tracer)(nil))
+}
+
+func current() *tracer {
+	return active.Load().(*tracer)
+}
+
+// Enabled reports whether tracing is enabled.
+func Enabled() bool {
+	return current() != nil
+}
+
+// Setup enables tracing with the given configuration. Any previously configured
+// tracing is stopped.
+func Setup(config Config) error {
+	var exporters multiExporter
+	if config.Endpoint != "" {
+		exp, err := newOTLPExporter(config.Endpoint)
+		if err != nil {
+			return err
+		}
+		exporters = append(exporters, exp)
+	}
+	if config.File != "" {
+		exp, err := newFileExporter(config.File)
+		if err != nil {
+			return err
+		}
+		exporters = append(exporters, exp)
+	}
+	if len(exporters) == 0 {
+		return errors.New("no trace exporter configured")
+	}
+	if config.SampleRatio < 0 || config.SampleRatio > 1 {
+		return errors.New("trace sample ratio must be between 0 and 1")
+	}
+	if config.ServiceName == "" {
+		config.ServiceName = "gcofe"
+	}
+	start(config, exporters)
+	return nil
+}
+
+// start enables tracing with the given exporter.
+func start(config Config, exp exporter) *tracer {
+	t := &tracer{
+		config:   config,
+		exporter: exp,
+		spans:    make(chan *Span, spanQueueSize),
+		quit:     make(chan chan struct{}),
+	}
+	go t.loop()
+	if prev := active.Swap(t).(*tracer); prev != nil {
+		prev.stop()
+	}
+	return t
+}
+
+// Stop disables tracing, exporting the spans which are still pending.
+func Stop() {
+	if t := active.Swap((*tracer)(nil)).(*tracer); t != nil {
+		t.stop()
+	}
+}
+
+func (t *tracer) stop() {
+	done := make(chan struct{})
+	t.quit <- done
+	<-done
+}
+
+// loop exports the finished spans in batches.
+func (t *tracer) loop() {
+	var (
+		batch = make([]*Span, 0, exportBatchSize)
+		timer = time.NewTimer(exportInterval)
+	)
+	defer timer.Stop()
+
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		if err := t.exporter.export(t.config.ServiceName, batch); err != nil {
+			log.Warn("Failed to export traces", "spans", len(batch), "err", err)
+		}
+		batch = make([]*Span, 0, exportBatchSize)
+	}
+	for {
+		select {
+		case span := <-t.spans:
+			if batch = append(batch, span); len(batch) == exportBatchSize {
+				flush()
+			}
+		case <-timer.C:
+			flush()
+			timer.Reset(exportInterval)
+
+		case done := <-t.quit:
+			for len(t.spans) > 0 {
+				if batch = append(batch, <-t.spans); len(batch) == exportBatchSize {
+					flush()
+				}
+			}
+			flush()
+			if dropped := atomic.LoadUint64(&t.dropped); dropped > 0 {
+				log.Warn("Dropped trace spans, exporter too slow", "spans", dropped)
+			}
+			if err := t.exporter.close(); err != nil {
+				log.Warn("Failed to close trace exporter", "err", err)
+			}
+			close(done)
+			return
+		}
+	}
+}
+
+// finish queues a finished span for export.
+func (t *tracer) finish(span *Span) {
+	select {
+	case t.spans <- span:
+	default:
+		atomic.AddUint64(&t.dropped, 1)
+	}
+}
+
+// trace is the state shared by the spans of a trace.
+type trace struct {
+	tracer  *tracer
+	id      TraceID
+	spans   int32 // number of spans started in the trace
+	dropped int32 // number of spans not recorded because of the limit
+}
+
+// Span is a timed operation within a trace. A nil span is valid and ignores
+// all calls, it's returned whenever the operation isn't traced.
+type Span struct {
+	trace  *trace
+	id     SpanID
+	parent SpanID
+	name   string
+	root   bool
+	start  time.Time
+
+	lock  sync.Mutex
+	end   time.Time
+	attrs []Attribute
+	err   string
+}
+
+type spanContextKey struct{}
+
+// SpanFromContext returns the span carried by the context, or nil.
+func SpanFromContext(ctx context.Context) *Span {
+	if ctx == nil {
+		return nil
+	}
+	span, _ := ctx.Value(spanContextKey{}).(*Span)
+	return span
+}
+
+// Traced reports whether operations started with the context are traced. Hot
+// paths check it to avoid building the attributes of spans which are not
+// recorded.
+func Traced(ctx context.Context) bool {
+	return SpanFromContext(ctx) != nil
+}
+
+// ContextWithSpan returns a copy of the context carrying the given span, so
+// that operations started with it are recorded as children of the span.
+func ContextWithSpan(ctx context.Context, span *Span) context.Context {
+	if span == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, spanContextKey{}, span)
+}
+
+// StartTrace starts the root span of a new trace, if the trace is sampled. The
+// optional traceparent is the value of a W3C Trace Context header sent by the
+// caller, the trace then continues the caller's trace.
+func StartTrace(ctx context.Context, name string, traceparent string, attrs ...Attribute) (context.Context, *Span) {
+	t := current()
+	if t == nil {
+		return ctx, nil
+	}
+	tr := &trace{tracer: t, spans: 1}
+	var parent SpanID
+	if id, pid, sampled, ok := parseTraceParent(traceparent); ok {
+		if !sampled {
+			return ctx, nil
+		}
+		tr.id, parent = id, pid
+	} else {
+		if !t.sample() {
+			return ctx, nil
+		}
+		rand.Read(tr.id[:])
+	}
+	span := &Span{trace: tr, parent: parent, name: name, root: true, start: time.Now(), attrs: attrs}
+	rand.Read(span.id[:])
+	return ContextWithSpan(ctx, span), span
+}
+
+// sample decides whether a new trace is recorded.
+func (t *tracer) sample() bool {
+	if t.config.SampleRatio >= 1 {
+		return true
+	}
+	var b [8]byte
+	rand.Read(b[:])
+	n := uint64(b[0]) | uint64(b[1])<<8 | uint64(b[2])<<16 | uint64(b[3])<<24 |
+		uint64(b[4])<<32 | uint64(b[5])<<40 | uint64(b[6])<<48 | uint64(b[7])<<56
+	return float64(n>>11)/(1<<53) < t.config.SampleRatio
+}
+
+// StartSpan starts a span as a child of the span carried by the context. If
+// the context carries no span, the operation isn't traced and a nil span is
+// returned along with the unchanged context.
+func StartSpan(ctx context.Context, name string, attrs ...Attribute) (context.Context, *Span) {
+	parent := SpanFromContext(ctx)
+	if parent == nil {
+		return ctx, nil
+	}
+	tr := parent.trace
+	if atomic.AddInt32(&tr.spans, 1) > maxSpansPerTrace {
+		atomic.AddInt32(&tr.dropped, 1)
+		return ctx, nil
+	}
+	span := &Span{trace: tr, parent: parent.id, name: name, start: time.Now(), attrs: attrs}
+	rand.Read(span.id[:])
+	return ContextWithSpan(ctx, span), span
+}
+
+// SetAttributes adds attributes to the span.
+func (s *Span) SetAttributes(attrs ...Attribute) {
+	if s == nil {
+		return
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.attrs = append(s.attrs, attrs...)
+}
+
+// SetError marks the operation of the span as failed. Nil errors are ignored.
+func (s *Span) SetError(err error) {
+	if s == nil || err == nil {
+		return
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.err = err.Error()
+}
+
+// End finishes the span and queues it for export. Calls after the first are
+// ignored.
+func (s *Span) End() {
+	if s == nil {
+		return
+	}
+	s.lock.Lock()
+	if !s.end.IsZero() {
+		s.lock.Unlock()
+		return
+	}
+	s.end = time.Now()
+	if s.root {
+		if dropped := atomic.LoadInt32(&s.trace.dropped); dropped > 0 {
+			s.attrs = append(s.attrs, Int("tracing.dropped_spans", int(dropped)))
+		}
+	}
+	s.lock.Unlock()
+
+	s.trace.tracer.finish(s)
+}
+
+// TraceID returns the identifier of the trace of the span.
+func (s *Span) TraceID() TraceID {
+	if s == nil {
+		return TraceID{}
+	}
+	return s.trace.id
+}
+
+// parseTraceParent parses a W3C Trace Context traceparent header value, which
+// has the form version-traceid-parentid-flags.
+func parseTraceParent(value string) (id TraceID, parent SpanID, sampled bool, ok bool) {
+	parts := strings.Split(strings.TrimSpace(value), "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || parts[0] == "ff" || (parts[0] == "00" && len(parts) != 4) {
+		return id, parent, false, false
+	}
+	var flags [1]byte
+	if !decodeHex(id[:], parts[1]) || !decodeHex(parent[:], parts[2]) || !decodeHex(flags[:], parts[3]) {
+		return id, parent, false, false
+	}
+	if id == (TraceID{}) || parent == (SpanID{}) {
+		return id, parent, false, false
+	}
+	return id, parent, flags[0]&1 == 1, true
+}
+
+// decodeHex decodes a lowercase hex string of exactly len(dst) bytes.
+func decodeHex(dst []byte, s string) bool {
+	if len(s) != 2*len(dst) || strings.ToLower(s) != s {
+		return false
+	}
+	_, err := hex.Decode(dst, []byte(s))
+	return err == nil
+}
@@ -0,0 +1,260 @@
+// Copyright 2022 The go-confero Authors
+// This file is part of the go-confero library.
+//
+// The go-confero library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-confero library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-confero library. If not, see <http://www.gnu.org/licenses/>.
+
+package tracing
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+// memExporter collects the exported spans.
+type memExporter struct {
+	mu    sync.Mutex
+	spans []*Span
+}
+
+func (e *memExporter) export(service string, spans []*Span) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.spans = append(e.spans, spans...)
+	return nil
+}
+
+func (e *memExporter) close() error { return nil }
+
+func TestSpans(t *testing.T) {
+	// Nothing is traced while disabled.
+	if _, span := StartTrace(context.Background(), "call", ""); span != nil {
+		t.Fatal("span started while tracing is disabled")
+	}
+	exp := new(memExporter)
+	start(Config{SampleRatio: 1}, exp)
+
+	ctx, root := StartTrace(context.Background(), "call", "", String("method", "eth_call"))
+	if root == nil {
+		t.Fatal("no root span")
+	}
+	childCtx, child := StartSpan(ctx, "read", Int("n", 1))
+	_, grandchild := StartSpan(childCtx, "resolve")
+	grandchild.SetError(errors.New("missing"))
+	grandchild.End()
+	child.End()
+	root.End()
+	root.End()
+
+	// Spans are only started within a trace.
+	if _, span := StartSpan(context.Background(), "read"); span != nil {
+		t.Fatal("span started without a trace")
+	}
+	Stop()
+
+	if len(exp.spans) != 3 {
+		t.Fatalf("wrong number of exported spans %d", len(exp.spans))
+	}
+	if exp.spans[0] != grandchild || exp.spans[1] != child || exp.spans[2] != root {
+		t.Fatal("wrong export order")
+	}
+	if child.parent != root.id || grandchild.parent != child.id || root.parent != (SpanID{}) {
+		t.Fatal("wrong span parents")
+	}
+	if child.TraceID() != root.TraceID() || grandchild.TraceID() != root.TraceID() {
+		t.Fatal("spans not in the same trace")
+	}
+	enc := encodeSpan(grandchild)
+	if enc.Status.Code != otlpStatusError || enc.Status.Message != "missing" || enc.Kind != otlpKindInternal {
+		t.Fatalf("wrong encoded span %+v", enc)
+	}
+	if enc := encodeSpan(root); enc.Kind != otlpKindServer || enc.ParentSpanID != "" || *enc.Attributes[0].Value.StringValue != "eth_call" {
+		t.Fatalf("wrong encoded root span %+v", enc)
+	}
+}
+
+// countingStringer counts the calls of its String method.
+type countingStringer struct{ calls int }
+
+func (s *countingStringer) String() string {
+	s.calls++
+	return "value"
+}
+
+func TestLazyAttributes(t *testing.T) {
+	value := new(countingStringer)
+
+	// Attributes are not formatted while tracing is disabled.
+	ctx := context.Background()
+	if Traced(ctx) {
+		t.Fatal("context traced while tracing is disabled")
+	}
+	_, span := StartSpan(ctx, "read", Stringer("value", value), Big("number", big.NewInt(1)))
+	span.End()
+	if value.calls != 0 {
+		t.Fatalf("attribute formatted %d times while tracing is disabled", value.calls)
+	}
+
+	// Attributes are formatted when the span is exported.
+	exp := new(memExporter)
+	start(Config{SampleRatio: 1}, exp)
+	ctx, root := StartTrace(ctx, "call", "")
+	if !Traced(ctx) {
+		t.Fatal("context not traced")
+	}
+	_, span = StartSpan(ctx, "read", Stringer("value", value), Big("number", big.NewInt(1)), Big("nil", nil))
+	span.End()
+	root.End()
+	Stop()
+	if value.calls != 0 {
+		t.Fatalf("attribute formatted %d times before export", value.calls)
+	}
+	enc := encodeSpan(span)
+	if *enc.Attributes[0].Value.StringValue != "value" || *enc.Attributes[1].Value.StringValue != "1" || *enc.Attributes[2].Value.StringValue != "" {
+		t.Fatalf("wrong encoded attributes %+v", enc.Attributes)
+	}
+}
+
+func TestSpanLimit(t *testing.T) {
+	exp := new(memExporter)
+	start(Config{SampleRatio: 1}, exp)
+	defer Stop()
+
+	ctx, root := StartTrace(context.Background(), "call", "")
+	for i := 0; i < maxSpansPerTrace+10; i++ {
+		_, span := StartSpan(ctx, "read")
+		if i < maxSpansPerTrace-1 && span == nil {
+			t.Fatalf("span %d not started", i)
+		}
+		if i >= maxSpansPerTrace-1 && span != nil {
+			t.Fatalf("span %d started beyond the limit", i)
+		}
+		span.End()
+	}
+	root.End()
+	attr := root.attrs[len(root.attrs)-1]
+	if attr.Key != "tracing.dropped_spans" || attr.Value != int64(11) {
+		t.Fatalf("wrong dropped spans attribute %v", attr)
+	}
+}
+
+func TestSampling(t *testing.T) {
+	start(Config{SampleRatio: 0}, new(memExporter))
+	defer Stop()
+
+	if _, span := StartTrace(context.Background(), "call", ""); span != nil {
+		t.Fatal("unsampled trace started")
+	}
+	// The sampling decision of the caller is followed.
+	_, span := StartTrace(context.Background(), "call", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	if span == nil {
+		t.Fatal("sampled remote trace not started")
+	}
+	if span.TraceID() != (TraceID{0x4b, 0xf9, 0x2f, 0x35, 0x77, 0xb3, 0x4d, 0xa6, 0xa3, 0xce, 0x92, 0x9d, 0x0e, 0x0e, 0x47, 0x36}) {
+		t.Fatalf("wrong trace id %x", span.TraceID())
+	}
+	if span.parent != (SpanID{0x00, 0xf0, 0x67, 0xaa, 0x0b, 0xa9, 0x02, 0xb7}) {
+		t.Fatalf("wrong parent id %x", span.parent)
+	}
+	if _, span := StartTrace(context.Background(), "call", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00"); span != nil {
+		t.Fatal("unsampled remote trace started")
+	}
+}
+
+func TestParseTraceParent(t *testing.T) {
+	tests := []struct {
+		value   string
+		ok      bool
+		sampled bool
+	}{
+		{"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", true, true},
+		{"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00", true, false},
+		{"01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra", true, true},
+		{"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra", false, false},
+		{"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", false, false},
+		{"00-00000000000000000000000000000000-00f067aa0ba902b7-01", false, false},
+		{"00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01", false, false},
+		{"00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01", false, false},
+		{"00-4bf92f3577b34da6a3ce929d0e0e47-00f067aa0ba902b7-01", false, false},
+		{"", false, false},
+	}
+	for _, test := range tests {
+		_, _, sampled, ok := parseTraceParent(test.value)
+		if ok != test.ok || sampled != test.sampled {
+			t.Errorf("%q: got ok %v sampled %v, want ok %v sampled %v", test.value, ok, sampled, test.ok, test.sampled)
+		}
+	}
+}
+
+func TestFileExporter(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "traces.json")
+	if err := Setup(Config{File: path, SampleRatio: 1}); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		ctx, root := StartTrace(context.Background(), "call", "")
+		_, span := StartSpan(ctx, "read", Uint64("gas", 21000), Bool("ok", true))
+		span.End()
+		root.End()
+	}
+	Stop()
+
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	var spans []otlpSpan
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var req otlpRequest
+		if err := json.Unmarshal(scanner.Bytes(), &req); err != nil {
+			t.Fatal(err)
+		}
+		if name := req.ResourceSpans[0].Resource.Attributes[0].Value.StringValue; *name != "gcofe" {
+			t.Fatalf("wrong service name %q", *name)
+		}
+		spans = append(spans, req.ResourceSpans[0].ScopeSpans[0].Spans...)
+	}
+	if len(spans) != 4 {
+		t.Fatalf("wrong number of spans %d", len(spans))
+	}
+	if spans[0].Name != "read" || *spans[0].Attributes[0].Value.IntValue != "21000" || !*spans[0].Attributes[1].Value.BoolValue {
+		t.Fatalf("wrong span %+v", spans[0])
+	}
+	if spans[0].ParentSpanID != spans[1].SpanID || spans[0].TraceID != spans[1].TraceID || len(spans[0].TraceID) != 32 {
+		t.Fatalf("wrong span ids %+v %+v", spans[0], spans[1])
+	}
+}
+
+func TestSetupErrors(t *testing.T) {
+	if err := Setup(Config{}); err == nil {
+		t.Fatal("expected error without exporter")
+	}
+	if err := Setup(Config{Endpoint: "localhost:4318"}); err == nil {
+		t.Fatal("expected error for endpoint without scheme")
+	}
+	if err := Setup(Config{Endpoint: "http://localhost:4318/v1/traces", SampleRatio: 2}); err == nil {
+		t.Fatal("expected error for invalid sample ratio")
+	}
+	if Enabled() {
+		t.Fatal("tracing enabled by failed setup")
+	}
+}
@@ -17,6 +17,7 @@
 package trie
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"io"
@@ -342,8 +343,9 @@ func (db *Database) insert(hash common.Hash, size int, node node) {
 }
 
 // node retrieves a cached trie node from memory, or returns nil if none can be
-// found in the memory cache.
-func (db *Database) node(hash common.Hash) node {
+// found in the memory cache. Database reads are traced under the span carried
+// by ctx, if any.
+func (db *Database) node(ctx context.Context, hash common.Hash) node {
 	// Retrieve the node from the clean cache if available
 	if db.cleans != nil {
 		if enc := db.cleans.Get(nil, hash[:]); enc != nil {
@@ -368,7 +370,7 @@ func (db *Database) node(hash common.Hash) node {
 	memcacheDirtyMissMeter.Mark(1)
 
 	// Content unavailable in memory, attempt to retrieve from disk
-	enc, err := db.diskdb.Get(hash[:])
+	enc, err := rawdb.NewTracedReader(ctx, db.diskdb, "trie").Get(hash[:])
 	if err != nil || enc == nil {
 		return nil
 	}
@@ -0,0 +1,123 @@
+// Copyright 2022 The go-confero Authors
+// This file is part of the go-confero library.
+//
+// The go-confero library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-confero library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-confero library. If not, see <http://www.gnu.org/licenses/>.
+
+package trie
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/confero-network/go-confero/common"
+	"github.com/confero-network/go-confero/crypto"
+	"github.com/confero-network/go-confero/ethdb"
+)
+
+// Prover is implemented by tries able to construct merkle proofs for single keys.
+type Prover interface {
+	Prove(key []byte, fromLevel uint, proofDb ethdb.KeyValueWriter) error
+}
+
+// MultiProof is a merkle proof of multiple keys against a single trie root. The
+// nodes shared between the paths of the proven keys are only contained once, in
+// the order they are first encountered while proving the keys in sequence.
+type MultiProof [][]byte
+
+// multiProofWriter is a proof database collecting a deduplicated, ordered list
+// of proof nodes.
+type multiProofWriter struct {
+	seen  map[string]struct{}
+	nodes MultiProof
+}
+
+// Put implements ethdb.KeyValueWriter, inserting a proof node if not yet present.
+func (w *multiProofWriter) Put(key []byte, value []byte) error {
+	if _, ok := w.seen[string(key)]; ok {
+		return nil
+	}
+	w.seen[string(key)] = struct{}{}
+	w.nodes = append(w.nodes, common.CopyBytes(value))
+	return nil
+}
+
+// Delete implements ethdb.KeyValueWriter, it is not supported.
+func (w *multiProofWriter) Delete(key []byte) error {
+	return errors.New("deleting multiproof nodes not supported")
+}
+
+// ProveMulti constructs a multiproof for all the given keys of the trie. Similar
+// to Prove, keys not contained in the trie are proven absent.
+func ProveMulti(t Prover, keys [][]byte) (MultiProof, error) {
+	w := &multiProofWriter{seen: make(map[string]struct{})}
+	for _, key := range keys {
+		if err := t.Prove(key, 0, w); err != nil {
+			return nil, err
+		}
+	}
+	return w.nodes, nil
+}
+
+// multiProofReader is a proof database serving the nodes of a multiproof and
+// tracking which of them were accessed.
+type multiProofReader struct {
+	nodes map[common.Hash][]byte
+	used  map[common.Hash]struct{}
+}
+
+// Has implements ethdb.KeyValueReader.
+func (r *multiProofReader) Has(key []byte) (bool, error) {
+	_, ok := r.nodes[common.BytesToHash(key)]
+	return ok, nil
+}
+
+// Get implements ethdb.KeyValueReader, marking the retrieved node as used.
+func (r *multiProofReader) Get(key []byte) ([]byte, error) {
+	hash := common.BytesToHash(key)
+	blob, ok := r.nodes[hash]
+	if !ok {
+		return nil, errors.New("not found")
+	}
+	r.used[hash] = struct{}{}
+	return blob, nil
+}
+
+// VerifyMultiProof checks a multiproof for the given keys against the trie root
+// hash, returning the proven value for every key (nil if the key is proven to be
+// absent). An error is returned if any of the keys can't be proven, or if the
+// proof contains nodes not needed by any of the keys.
+func VerifyMultiProof(rootHash common.Hash, keys [][]byte, proof MultiProof) ([][]byte, error) {
+	reader := &multiProofReader{
+		nodes: make(map[common.Hash][]byte, len(proof)),
+		used:  make(map[common.Hash]struct{}, len(proof)),
+	}
+	for _, node := range proof {
+		reader.nodes[crypto.Keccak256Hash(node)] = node
+	}
+	if len(reader.nodes) != len(proof) {
+		return nil, errors.New("duplicate proof nodes")
+	}
+	values := make([][]byte, len(keys))
+	for i, key := range keys {
+		value, err := VerifyProof(rootHash, key, reader)
+		if err != nil {
+			return nil, fmt.Errorf("key %x: %v", key, err)
+		}
+		values[i] = value
+	}
+	if len(reader.used) != len(reader.nodes) {
+		return nil, fmt.Errorf("proof contains %d unused nodes", len(reader.nodes)-len(reader.used))
+	}
+	return values, nil
+}
@@ -0,0 +1,112 @@
+// Copyright 2022 The go-confero Authors
+// This file is part of the go-confero library.
+//
+// The go-confero library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-confero library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-confero library. If not, see <http://www.gnu.org/licenses/>.
+
+package trie
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/confero-network/go-confero/ethdb/memorydb"
+)
+
+func TestMultiProof(t *testing.T) {
+	trie, vals := randomTrie(500)
+	root := trie.Hash()
+
+	var (
+		keys   [][]byte
+		values [][]byte
+	)
+	for _, kv := range vals {
+		keys = append(keys, kv.k)
+		values = append(values, kv.v)
+		if len(keys) == 64 {
+			break
+		}
+	}
+	// Mix in a few keys missing from the trie
+	for i := 0; i < 8; i++ {
+		keys = append(keys, randBytes(32))
+		values = append(values, nil)
+	}
+	proof, err := ProveMulti(trie, keys)
+	if err != nil {
+		t.Fatalf("failed to create multiproof: %v", err)
+	}
+	have, err := VerifyMultiProof(root, keys, proof)
+	if err != nil {
+		t.Fatalf("failed to verify multiproof: %v", err)
+	}
+	for i := range keys {
+		if !bytes.Equal(have[i], values[i]) {
+			t.Fatalf("key %x: value mismatch: have %x, want %x", keys[i], have[i], values[i])
+		}
+	}
+	// The multiproof must be smaller than the individual proofs combined
+	var single int
+	for _, key := range keys {
+		proof := memorydb.New()
+		trie.Prove(key, 0, proof)
+		single += proof.Len()
+	}
+	if len(proof) >= single {
+		t.Errorf("multiproof not deduplicated: %d nodes, %d in single proofs", len(proof), single)
+	}
+}
+
+func TestBadMultiProof(t *testing.T) {
+	trie, vals := randomTrie(500)
+	root := trie.Hash()
+
+	var keys [][]byte
+	for _, kv := range vals {
+		keys = append(keys, kv.k)
+		if len(keys) == 16 {
+			break
+		}
+	}
+	proof, err := ProveMulti(trie, keys)
+	if err != nil {
+		t.Fatalf("failed to create multiproof: %v", err)
+	}
+	// Dropping any node must fail verification
+	for i := range proof {
+		broken := append(append(MultiProof{}, proof[:i]...), proof[i+1:]...)
+		if _, err := VerifyMultiProof(root, keys, broken); err == nil {
+			t.Fatalf("verification succeeded without node %d", i)
+		}
+	}
+	// Unused and duplicate nodes must fail verification
+	extra, _ := ProveMulti(trie, [][]byte{randBytes(32), randBytes(32)})
+	padded := append(append(MultiProof{}, proof...), extra...)
+	if _, err := VerifyMultiProof(root, keys[:1], padded); err == nil {
+		t.Fatal("verification succeeded with unused nodes")
+	}
+	duplicated := append(append(MultiProof{}, proof...), proof[0])
+	if _, err := VerifyMultiProof(root, keys, duplicated); err == nil {
+		t.Fatal("verification succeeded with duplicate nodes")
+	}
+}
+
+// Tests that deleting from a multiproof under construction fails instead of
+// crashing.
+func TestMultiProofWriterDelete(t *testing.T) {
+	w := &multiProofWriter{seen: make(map[string]struct{})}
+	if err := w.Delete([]byte{0x01}); err == nil {
+		t.Fatal("delete succeeded")
+	}
+}
@@ -17,6 +17,7 @@
 package trie
 
 import (
+	"context"
 	"fmt"
 
 	"github.com/confero-network/go-confero/common"
@@ -237,6 +238,23 @@ func (t *StateTrie) Hash() common.Hash {
 	return t.trie.Hash()
 }
 
+// EnableWitness starts tracking all the trie nodes resolved from the database.
+func (t *StateTrie) EnableWitness() {
+	t.trie.EnableWitness()
+}
+
+// SetTraceContext sets the context carrying the trace span under which the
+// node resolutions of the trie are traced.
+func (t *StateTrie) SetTraceContext(ctx context.Context) {
+	t.trie.SetTraceContext(ctx)
+}
+
+// Witness returns the rlp-encoded blobs of all the trie nodes resolved from the
+// database since witness tracking was enabled.
+func (t *StateTrie) Witness() map[string]struct{} {
+	return t.trie.Witness()
+}
+
 // Copy returns a copy of StateTrie.
 func (t *StateTrie) Copy() *StateTrie {
 	return &StateTrie{
@@ -19,12 +19,14 @@ package trie
 
 import (
 	"bytes"
+	"context"
 	"errors"
 	"fmt"
 
 	"github.com/confero-network/go-confero/common"
 	"github.com/confero-network/go-confero/crypto"
 	"github.com/confero-network/go-confero/log"
+	"github.com/confero-network/go-confero/tracing"
 )
 
 var (
@@ -74,6 +76,14 @@ type Trie struct {
 	// tracer is the tool to track the trie changes.
 	// It will be reset after each commit operation.
 	tracer *tracer
+
+	// witness is the set of node hashes resolved from the database since
+	// witness tracking was enabled. It's nil if tracking is disabled.
+	witness map[common.Hash]struct{}
+
+	// traceCtx carries the trace span under which node resolutions are
+	// traced. It's nil if the trie accesses aren't traced.
+	traceCtx context.Context
 }
 
 // newFlag returns the cache flag value for a newly created node.
@@ -83,12 +93,20 @@ func (t *Trie) newFlag() nodeFlag {
 
 // Copy returns a copy of Trie.
 func (t *Trie) Copy() *Trie {
+	var witness map[common.Hash]struct{}
+	if t.witness != nil {
+		witness = make(map[common.Hash]struct{}, len(t.witness))
+		for hash := range t.witness {
+			witness[hash] = struct{}{}
+		}
+	}
 	return &Trie{
 		root:     t.root,
 		owner:    t.owner,
 		unhashed: t.unhashed,
 		db:       t.db,
 		tracer:   t.tracer.copy(),
+		witness:  witness,
 	}
 }
 
@@ -557,7 +575,19 @@ func (t *Trie) resolve(n node, prefix []byte) (node, error) {
 // node hash and path prefix.
 func (t *Trie) resolveHash(n hashNode, prefix []byte) (node, error) {
 	hash := common.BytesToHash(n)
-	if node := t.db.node(hash); node != nil {
+	var (
+		ctx  = t.traceCtx
+		span *tracing.Span
+	)
+	if tracing.Traced(ctx) {
+		ctx, span = tracing.StartSpan(ctx, "trie.resolve", tracing.Stringer("trie.owner", t.owner), tracing.Stringer("trie.node", hash), tracing.Int("trie.depth", len(prefix)))
+		defer span.End()
+	}
+
+	if node := t.db.node(ctx, hash); node != nil {
+		if t.witness != nil {
+			t.witness[hash] = struct{}{}
+		}
 		return node, nil
 	}
 	return nil, &MissingNodeError{Owner: t.owner, NodeHash: hash, Path: prefix}
@@ -574,6 +604,42 @@ func (t *Trie) resolveBlob(n hashNode, prefix []byte) ([]byte, error) {
 	return nil, &MissingNodeError{Owner: t.owner, NodeHash: hash, Path: prefix}
 }
 
+// EnableWitness starts tracking all the trie nodes resolved from the database,
+// including the already resolved root node. The tracked nodes can be retrieved
+// with Witness.
+func (t *Trie) EnableWitness() {
+	if t.witness != nil {
+		return
+	}
+	t.witness = make(map[common.Hash]struct{})
+	if t.root != nil {
+		if hash, dirty := t.root.cache(); hash != nil && !dirty {
+			t.witness[common.BytesToHash(hash)] = struct{}{}
+		}
+	}
+}
+
+// SetTraceContext sets the context carrying the trace span under which the
+// node resolutions of the trie are traced.
+func (t *Trie) SetTraceContext(ctx context.Context) {
+	t.traceCtx = ctx
+}
+
+// Witness returns the rlp-encoded blobs of all the trie nodes resolved from the
+// database since witness tracking was enabled, keyed by the blob itself.
+func (t *Trie) Witness() map[string]struct{} {
+	if len(t.witness) == 0 {
+		return nil
+	}
+	witness := make(map[string]struct{}, len(t.witness))
+	for hash := range t.witness {
+		if blob, _ := t.db.Node(hash); len(blob) > 0 {
+			witness[string(blob)] = struct{}{}
+		}
+	}
+	return witness
+}
+
 // Hash returns the root hash of the trie. It does not write to the
 // database and can be used even if the trie doesn't have one.
 func (t *Trie) Hash() common.Hash {